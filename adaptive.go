@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"time"
+
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func (s *server) Feedback(namespace, name string, errorRate float64, latency time.Duration) (int64, error) {
+	b, e := s.bucketContainer.FindBucket(namespace, name)
+	if e != nil || b == nil {
+		return 0, newError("No such bucket "+config.FullyQualifiedName(namespace, name), ER_NO_BUCKET)
+	}
+
+	a := b.Config().Adaptive
+	if _, ok := b.Bucket.(RateAdjuster); !ok || a == nil {
+		return 0, newError("Bucket "+config.FullyQualifiedName(namespace, name)+" doesn't adapt its fill rate", ER_NOT_ADAPTIVE)
+	}
+
+	return b.adapt(a.Healthy(errorRate, latency)), nil
+}
+
+// adapt backs the fill rate of an adaptive bucket off if its downstream is unhealthy, or recovers it
+// if healthy, returning the new fill rate. The underlying bucket must be a RateAdjuster.
+func (e *expirableBucket) adapt(healthy bool) int64 {
+	cfg := e.Config()
+	e.rateLock.Lock()
+	defer e.rateLock.Unlock()
+
+	rate := e.fillRate
+	if rate == 0 {
+		rate = cfg.FillRate
+	}
+
+	previous := rate
+	if healthy {
+		rate += cfg.Adaptive.Increase
+		if rate > cfg.FillRate {
+			rate = cfg.FillRate
+		}
+	} else {
+		rate = rate * cfg.Adaptive.BackoffPercent / 100
+		if rate < cfg.Adaptive.MinFillRate {
+			rate = cfg.Adaptive.MinFillRate
+		}
+	}
+
+	if rate != previous {
+		e.Bucket.(RateAdjuster).SetFillRate(rate)
+	}
+
+	e.fillRate = rate
+	return rate
+}
+
+// currentFillRate returns the fill rate of an adaptive bucket.
+func (e *expirableBucket) currentFillRate() int64 {
+	e.rateLock.Lock()
+	defer e.rateLock.Unlock()
+
+	if e.fillRate == 0 {
+		return e.Config().FillRate
+	}
+
+	return e.fillRate
+}
@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// statusRecorder records the status of the response written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+type callerKey struct{}
+
+// setCaller records who made a request once it's authenticated, for logged to log.
+func setCaller(r *http.Request, id string) {
+	if caller, ok := r.Context().Value(callerKey{}).(*string); ok {
+		*caller = id
+	}
+}
+
+// logged logs the method, path, status and latency of each request passed on to h, along with who
+// made it. Panics in h are logged and answered with a 500 response, rather than dropping the
+// connection.
+func logged(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		caller := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), callerKey{}, caller))
+
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				logging.Printf("Recovered from panic serving %v %v: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+				if rec.status == 0 {
+					writeErrorStatus(rec, http.StatusInternalServerError, errors.New("Internal server error"))
+				}
+			}
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			if *caller == "" {
+				*caller = clientCertName(r)
+			}
+			if *caller == "" {
+				*caller = r.RemoteAddr
+			}
+
+			logging.Printf("%v %v %v %v by %v", r.Method, r.URL.Path, rec.status, time.Since(start), *caller)
+		}()
+
+		h.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+func TestLogged(t *testing.T) {
+	var buf bytes.Buffer
+	defer logging.SetLogger(logging.CurrentLogger())
+	logging.SetLogger(log.New(&buf, "", 0))
+
+	mux := http.NewServeMux()
+	mux.Handle("/panic", logged(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("Boom")
+	})))
+	mux.Handle("/teapot", logged(authenticated(NewBasicAuthenticator(map[string]string{"admin": "secret"}),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rsp, e := http.Get(srv.URL + "/panic")
+	if e != nil {
+		t.Fatal(e)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expecting panic to be answered with a 500. Status %v", rsp.Status)
+	}
+
+	if !strings.Contains(buf.String(), "Recovered from panic serving GET /panic: Boom") ||
+		!strings.Contains(buf.String(), "GET /panic 500 ") {
+		t.Fatalf("Expecting panic to be logged. Log:\n%v", buf.String())
+	}
+
+	req, _ := http.NewRequest("PUT", srv.URL+"/teapot", nil)
+	req.SetBasicAuth("admin", "secret")
+	rsp, e = http.DefaultClient.Do(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusTeapot {
+		t.Fatalf("Unexpected status %v", rsp.Status)
+	}
+
+	if !strings.Contains(buf.String(), "PUT /teapot 418 ") || !strings.Contains(buf.String(), " by admin\n") {
+		t.Fatalf("Expecting request to be logged along with its caller. Log:\n%v", buf.String())
+	}
+
+	// Unauthenticated requests are logged as coming from the client's address.
+	buf.Reset()
+	rsp, e = http.Get(srv.URL + "/teapot")
+	if e != nil {
+		t.Fatal(e)
+	}
+	rsp.Body.Close()
+	if !strings.Contains(buf.String(), "GET /teapot 401 ") || !strings.Contains(buf.String(), " by 127.0.0.1:") {
+		t.Fatalf("Expecting rejected request to be logged. Log:\n%v", buf.String())
+	}
+}
@@ -5,72 +5,225 @@
 package admin
 
 import (
+	"bytes"
 	"html/template"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 
+	"github.com/maniksurtani/quotaservice/compress"
 	"github.com/maniksurtani/quotaservice/config"
 	"github.com/maniksurtani/quotaservice/logging"
 	pb "github.com/maniksurtani/quotaservice/protos/config"
 )
 
-// Administrable defines something that can be administered via this package.
+// Administrable defines something that can be administered via this package. Updates take the
+// version of the ServiceConfig the change is based on, and fail with a config.VersionMismatchError
+// if the live config has since moved on. Changes take the identity of the user making them, if
+// known, which is recorded in the resulting config.
 type Administrable interface {
 	Configs() *config.ServiceConfig
+	// ReplaceConfig replaces the whole live config with cfg, which should be validated and have
+	// defaults applied, applying and persisting it as the next version.
+	ReplaceConfig(cfg *config.ServiceConfig, version int, user string) error
+	// HistoricalConfigs returns the most recently persisted configs, most recent first.
+	HistoricalConfigs() ([]*config.ServiceConfig, error)
+	// RollbackConfig replaces the live config with a historical version, applied and persisted as
+	// the next version. Fails with a config.UnknownVersionError if the version isn't in the history.
+	RollbackConfig(version int, user string) error
+	// VerifySignature returns a config.InvalidSignatureError if configs must be signed, and
+	// signature isn't the signature of payload. Returns nil if configs needn't be signed.
+	VerifySignature(payload []byte, signature string) error
 
-	DeleteBucket(namespace, name string) error
-	AddBucket(namespace string, b *pb.BucketConfig) error
-	UpdateBucket(namespace string, b *pb.BucketConfig) error
+	DeleteBucket(namespace, name, user string) error
+	AddBucket(namespace string, b *pb.BucketConfig, user string) error
+	UpdateBucket(namespace string, b *pb.BucketConfig, version int, user string) error
 
-	DeleteNamespace(namespace string) error
-	AddNamespace(n *pb.NamespaceConfig) error
-	UpdateNamespace(n *pb.NamespaceConfig) error
+	DeleteNamespace(namespace, user string) error
+	AddNamespace(n *pb.NamespaceConfig, user string) error
+	UpdateNamespace(n *pb.NamespaceConfig, version int, user string) error
+
+	// BucketStatus returns the runtime state of a live bucket, failing with a
+	// config.NotFoundError if there isn't one, or a NotImplementedError if buckets can't report it.
+	BucketStatus(namespace, name string) (*BucketStatus, error)
+	// DynamicBuckets returns the status of the namespace's live dynamic buckets, sorted by name.
+	DynamicBuckets(namespace string) ([]*BucketStatus, error)
+	// EvictDynamicBucket removes a live dynamic bucket and its state straight away, rather than
+	// when it has been idle for long enough, failing with a config.NotFoundError if there isn't one.
+	EvictDynamicBucket(namespace, name, user string) error
+	// BucketUsage returns the usage stats of a live bucket, failing with a config.NotFoundError if
+	// there isn't one.
+	BucketUsage(namespace, name string) (*BucketUsage, error)
+	// AllBucketUsage returns the usage stats of every live bucket.
+	AllBucketUsage() []*BucketUsage
+}
+
+// Options configure how ServeAdminConsole serves the admin console.
+type Options struct {
+	// Authenticator, if set, must authenticate requests under /admin/ and /api/.
+	Authenticator Authenticator
+	// CORS, if set, allows browsers to call the REST API from the origins it lists.
+	CORS *CORSConfig
+	// RateLimiter, if set, limits how often each client may change configs via the REST API.
+	RateLimiter RateLimiter
+	// Staging, if set, configures how changes staged via /api/staged are committed, and whether
+	// changes must be staged.
+	Staging *StagingConfig
+	// Development reloads the UI's templates whenever they change, if they're read from an assets
+	// directory. Otherwise they're parsed once, when the admin console is first served.
+	Development bool
 }
 
-// ServeAdminConsole serves up an admin console for an Administrable over a http server. assetsDirectory contains
-// HTML templates and other UI assets. If empty, no UI will be served, and only REST endpoints under /api/ will be
-// served instead.
-func ServeAdminConsole(a Administrable, mux *http.ServeMux, assetsDirectory string) {
+//go:generate go run gen_assets.go
+
+// ServeAdminConsole serves up an admin console for an Administrable over a http server, along with
+// REST endpoints under /api/. The UI's HTML templates and other assets are embedded in the binary,
+// unless assetsDirectory is set, in which case they're read from there instead.
+func ServeAdminConsole(a Administrable, mux *http.ServeMux, assetsDirectory string, opts Options) {
 	logging.Print("Serving admin console.")
+	ui := newUIHandler(a, assetsDirectory, opts.Development)
 	if assetsDirectory != "" {
-		files, err := ioutil.ReadDir(assetsDirectory)
-		check(err)
-		htmlFiles := make([]string, 0)
-		for _, f := range files {
-			if !f.IsDir() && strings.HasSuffix(f.Name(), ".html") {
-				htmlFiles = append(htmlFiles, assetsDirectory+"/"+f.Name())
-			}
-		}
-		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			http.Redirect(w, r, "/admin/", 301)
-		})
-		mux.Handle("/admin/", &uiHandler{a, reloadTemplates(htmlFiles), htmlFiles})
-		mux.Handle("/js/", http.FileServer(http.Dir(assetsDirectory)))
+		logging.Printf("Serving UI from %v.", assetsDirectory)
+		mux.Handle("/js/", compress.Handler(http.FileServer(http.Dir(assetsDirectory))))
 	} else {
-		logging.Print("Not serving UI.")
+		mux.Handle("/js/", compress.Handler(http.HandlerFunc(serveEmbeddedAsset)))
 	}
-	mux.Handle("/api/", &apiHandler{a})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/admin/", 301)
+	})
+	mux.Handle("/admin/", logged(compress.Handler(authenticated(opts.Authenticator, ui))))
+
+	api := func(h http.Handler) http.Handler {
+		// CORS preflight requests don't carry credentials, so are answered before authenticating.
+		// Responses are compressed as clients negotiate, as whole configs can be megabytes of JSON.
+		return logged(compress.Handler(withCORS(opts.CORS, authenticated(opts.Authenticator, rateLimited(opts.RateLimiter, h)))))
+	}
+	rest := http.NewServeMux()
+	rest.Handle("/api/", &apiHandler{a})
+	// Takes precedence over /api/, so namespaces named "config" aren't reachable via the API.
+	rest.Handle("/api/config", &configHandler{a})
+	rest.Handle("/api/config/", &configHandler{a})
+	// Likewise, namespaces named "search", "loglevel", "stats" and "staged" aren't reachable via the API.
+	rest.Handle("/api/search", &searchHandler{a})
+	rest.Handle(LogLevelPath, &logLevelHandler{})
+	rest.Handle(StatsPath, &statsHandler{a})
+	rest.Handle(OpenAPIPath, newOpenAPIHandler())
+	mux.Handle("/api/", api(newStagingHandler(opts.Staging, rest)))
 }
 
 type uiHandler struct {
 	a Administrable
+	// Directory the templates are read from, if they aren't embedded.
+	dir         string
+	development bool
+
+	sync.Mutex
 	t *template.Template
-	h []string
+	// Identifies the versions of the files t was parsed from, to tell when they change.
+	stamp string
+}
+
+func newUIHandler(a Administrable, dir string, development bool) *uiHandler {
+	h := &uiHandler{a: a, dir: dir, development: development}
+	if dir == "" {
+		h.t = embeddedTemplates()
+		return h
+	}
+
+	files, stamp, e := templateFiles(dir)
+	check(e)
+	h.t, h.stamp = reloadTemplates(files), stamp
+	return h
 }
 
 func reloadTemplates(files []string) *template.Template {
 	return template.Must(template.New("admin").ParseFiles(files...))
 }
 
-func (h *uiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// TODO(manik) remove this
-	h.t = reloadTemplates(h.h)
+// templateFiles lists the HTML templates in dir, along with a stamp that changes whenever any of
+// them are added, removed or modified.
+func templateFiles(dir string) ([]string, string, error) {
+	infos, e := ioutil.ReadDir(dir)
+	if e != nil {
+		return nil, "", e
+	}
+
+	var files []string
+	var stamp bytes.Buffer
+	for _, f := range infos {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".html") {
+			files = append(files, dir+"/"+f.Name())
+			fmt.Fprintf(&stamp, "%v:%v:%v;", f.Name(), f.Size(), f.ModTime().UnixNano())
+		}
+	}
+
+	return files, stamp.String(), nil
+}
+
+// templates returns the parsed templates, reloading them first if in development and they've
+// changed. Templates that fail to parse are logged, and the previous ones used instead.
+func (h *uiHandler) templates() *template.Template {
+	h.Lock()
+	defer h.Unlock()
+	if !h.development || h.dir == "" {
+		return h.t
+	}
+
+	files, stamp, e := templateFiles(h.dir)
+	if e != nil {
+		logging.Warnf("Unable to list templates in %v: %v", h.dir, e)
+		return h.t
+	}
+
+	if stamp != h.stamp {
+		t, e := template.New("admin").ParseFiles(files...)
+		if e != nil {
+			logging.Warnf("Unable to reload templates: %v", e)
+			return h.t
+		}
+
+		logging.Printf("Reloaded templates from %v.", h.dir)
+		h.t, h.stamp = t, stamp
+	}
+
+	return h.t
+}
+
+// embeddedTemplates parses the HTML templates embedded in the binary, named after their files like
+// ParseFiles names them.
+func embeddedTemplates() *template.Template {
+	t := template.New("admin")
+	for name, contents := range assets {
+		if strings.HasSuffix(name, ".html") {
+			template.Must(t.New(name).Parse(contents))
+		}
+	}
+
+	return t
+}
 
+// serveEmbeddedAsset serves the asset embedded in the binary at the request's path.
+func serveEmbeddedAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	contents, ok := assets[name]
+	if !ok || strings.HasSuffix(name, ".html") {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeContent(w, r, name, time.Time{}, strings.NewReader(contents))
+}
+
+func (h *uiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path[len("/admin/"):]
 
 	var tpl string
@@ -81,7 +234,7 @@ func (h *uiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		tpl = path
 	}
 
-	err := h.t.ExecuteTemplate(w, tpl, h.a.Configs())
+	err := h.templates().ExecuteTemplate(w, tpl, h.a.Configs())
 	if err != nil {
 		logging.Printf("Caught error %v serving URL %v", err, r.URL.Path)
 		http.NotFound(w, r)
@@ -99,96 +252,601 @@ type apiHandler struct {
 }
 
 func (a *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if strings.HasPrefix(r.URL.Path, "/api/") {
+	if r.Method == "PUT" || r.Method == "POST" || r.Method == "PATCH" {
+		if e := verifySignature(a.a, r); e != nil {
+			writeError(w, e)
+			return
+		}
+	}
+
+	if r.Method != "GET" {
+		if e := checkIfMatch(a.a, r); e != nil {
+			writeError(w, e)
+			return
+		}
+	}
+
+	// Checked first, so namespaces named "namespace" aren't reachable via the bucket API.
+	if strings.HasPrefix(r.URL.Path, "/api/namespace/") {
+		params := strings.TrimPrefix(r.URL.Path, "/api/namespace/")
+		if parts := strings.Split(params, "/"); len(parts) == 2 && parts[1] == "dynamic" {
+			a.serveDynamicBuckets(w, r, parts[0])
+			return
+		} else if len(parts) == 3 && parts[1] == "dynamic" {
+			a.serveDynamicBucket(w, r, parts[0], parts[2])
+			return
+		} else if len(parts) == 2 && parts[1] == "export" {
+			a.exportNamespace(w, r, parts[0])
+			return
+		} else if len(parts) == 2 && parts[1] == "import" {
+			a.importNamespace(w, r, parts[0])
+			return
+		}
+
+		a.serveNamespace(w, r, params)
+	} else if strings.HasPrefix(r.URL.Path, "/api/") {
 		params := strings.TrimPrefix(r.URL.Path, "/api/")
-		namespace, name := extractNamespaceName(params)
-		logging.Printf("Request for %v", params)
-		switch r.Method {
-		case "DELETE":
-			a.a.DeleteBucket(namespace, name)
-		case "PUT":
-			c, e := getBucketConfig(r.Body)
-			if e != nil {
-				logging.Println("Caught error", e)
-				http.Error(w, "500 bad content", http.StatusInternalServerError)
-			} else {
-				a.a.AddBucket(namespace, c)
-			}
-		case "POST":
-			c, e := getBucketConfig(r.Body)
-			if e != nil {
-				logging.Println("Caught error", e)
-				http.Error(w, "500 bad content", http.StatusInternalServerError)
-			} else {
-				a.a.UpdateBucket(namespace, c)
-			}
-		case "GET":
-			e := a.writeConfigs(namespace, w)
-			if e != nil {
-				logging.Print("Caught error ", e)
-				http.Error(w, "500 bad content", http.StatusInternalServerError)
-			}
-		default:
-			logging.Printf("Not handling method %v", r.Method)
-			http.NotFound(w, r)
-		}
-	} else if strings.HasPrefix(r.URL.Path, "/api/namespace/") {
-		ns := strings.TrimPrefix(r.URL.Path, "/api/namespace/")
-		switch r.Method {
-		case "DELETE":
-			a.a.DeleteNamespace(ns)
-		case "PUT":
-			c, e := getNamespaceConfig(r.Body)
-			if e != nil {
-				logging.Println("Caught error", e)
-				http.Error(w, "500 bad content", http.StatusInternalServerError)
-			} else {
-				a.a.AddNamespace(c)
-			}
-		case "POST":
-			c, e := getNamespaceConfig(r.Body)
-			if e != nil {
-				logging.Println("Caught error", e)
-				http.Error(w, "500 bad content", http.StatusInternalServerError)
-			} else {
-				a.a.UpdateNamespace(c)
-			}
-		default:
-			logging.Printf("Not handling method %v", r.Method)
-			http.NotFound(w, r)
+		logging.Debugf("Request for %v", params)
+		if strings.Count(params, "/") == 2 && strings.HasSuffix(params, "/status") {
+			namespace, name := extractNamespaceName(strings.TrimSuffix(params, "/status"))
+			a.serveBucketStatus(w, r, namespace, name)
+			return
 		}
+
+		namespace, name := extractNamespaceName(params)
+		a.serveBucket(w, r, namespace, name)
 	} else {
-		logging.Printf("Not handling path %v", r.URL.Path)
-		http.NotFound(w, r)
+		writeErrorStatus(w, http.StatusNotFound, errors.New("Not handling path "+r.URL.Path))
+	}
+}
+
+func (a *apiHandler) serveBucket(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	switch r.Method {
+	case "DELETE":
+		writeError(w, a.a.DeleteBucket(namespace, name, identity(r)))
+	case "PUT":
+		c, e := getBucketConfig(r.Body)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		writeError(w, a.a.AddBucket(namespace, c, identity(r)))
+	case "POST":
+		c, e := getBucketConfig(r.Body)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		version, e := getVersion(r)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		writeError(w, a.a.UpdateBucket(namespace, c, version, identity(r)))
+	case "PATCH":
+		version, e := getVersion(r)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		c, e := a.patchBucketConfig(namespace, name, r.Body)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		writeError(w, a.a.UpdateBucket(namespace, c, version, identity(r)))
+	case "GET":
+		f, e := getConfigFilter(r)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		writeError(w, a.writeConfigs(namespace, f, w))
+	default:
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+	}
+}
+
+func (a *apiHandler) serveNamespace(w http.ResponseWriter, r *http.Request, ns string) {
+	switch r.Method {
+	case "DELETE":
+		writeError(w, a.a.DeleteNamespace(ns, identity(r)))
+	case "PUT":
+		c, e := getNamespaceConfig(r.Body)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		writeError(w, a.a.AddNamespace(c, identity(r)))
+	case "POST":
+		c, e := getNamespaceConfig(r.Body)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		version, e := getVersion(r)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		writeError(w, a.a.UpdateNamespace(c, version, identity(r)))
+	default:
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
 	}
 }
 
-func (a *apiHandler) writeConfigs(namespace string, w http.ResponseWriter) (e error) {
+// exportNamespace writes a single namespace as YAML, for importNamespace to read into another
+// cluster's config.
+func (a *apiHandler) exportNamespace(w http.ResponseWriter, r *http.Request, ns string) {
+	if r.Method != "GET" {
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+		return
+	}
+
+	cfgs := a.a.Configs()
+	n := cfgs.Namespaces[ns]
+	if n == nil {
+		writeError(w, config.NotFoundError{Message: "No such namespace " + ns})
+		return
+	}
+
+	y, e := n.ToYAML()
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	w.Header().Set("ETag", etag(cfgs.Version))
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(y)
+}
+
+// importNamespace adds the namespace defined by the YAML in the request, replacing the namespace
+// if it already exists. The rest of the config is left as it is.
+func (a *apiHandler) importNamespace(w http.ResponseWriter, r *http.Request, ns string) {
+	if r.Method != "PUT" {
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+		return
+	}
+
+	b, e := ioutil.ReadAll(r.Body)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	n, e := config.ParseNamespaceConfig(ns, b)
+	if e != nil {
+		if _, ok := e.(config.ValidationErrors); !ok {
+			e = badRequestError{e}
+		}
+		writeError(w, e)
+		return
+	}
+
+	if _, exists := a.a.Configs().Namespaces[ns]; !exists {
+		writeError(w, a.a.AddNamespace(n.ToProto(), identity(r)))
+		return
+	}
+
+	version, e := getVersion(r)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	writeError(w, a.a.UpdateNamespace(n.ToProto(), version, identity(r)))
+}
+
+// patchBucketConfig applies the JSON merge patch (RFC 7396) in r to a bucket's current config, so
+// that only the settings the patch specifies are changed.
+func (a *apiHandler) patchBucketConfig(namespace, name string, r io.Reader) (*pb.BucketConfig, error) {
+	var current *config.BucketConfig
+	cfgs := a.a.Configs()
+	if namespace == config.GlobalNamespace {
+		if name == config.DefaultBucketName {
+			current = cfgs.GlobalDefaultBucket
+		}
+	} else if ns := cfgs.Namespaces[namespace]; ns != nil {
+		current = ns.Buckets[name]
+	}
+
+	if current == nil {
+		return nil, config.NotFoundError{Message: "No such bucket " + config.FullyQualifiedName(namespace, name)}
+	}
+
+	patch, e := ioutil.ReadAll(r)
+	if e != nil {
+		return nil, e
+	}
+
+	patchDoc, e := decodeJSON(patch)
+	if e != nil {
+		return nil, badRequestError{e}
+	}
+
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, badRequestError{errors.New("Patch should be a JSON object")}
+	}
+
+	currentJSON, e := json.Marshal(current.ToProto())
+	if e != nil {
+		return nil, e
+	}
+
+	doc, e := decodeJSON(currentJSON)
+	if e != nil {
+		return nil, e
+	}
+
+	patched, e := json.Marshal(mergePatch(doc, patchDoc))
+	if e != nil {
+		return nil, e
+	}
+
+	c := &pb.BucketConfig{}
+	if e = config.ProtoFromJSON(patched, c); e != nil {
+		return nil, badRequestError{e}
+	}
+
+	// Buckets can't be renamed by patching them.
+	c.Name = current.Name
+	return c, nil
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as json.Numbers so large integers aren't
+// rounded.
+func decodeJSON(b []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var doc interface{}
+	e := d.Decode(&doc)
+	return doc, e
+}
+
+// mergePatch applies a JSON merge patch to a decoded JSON document. Objects are merged key by key,
+// nulls remove keys, and anything else replaces the document's value.
+func mergePatch(doc, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	docObj, ok := doc.(map[string]interface{})
+	if !ok {
+		docObj = make(map[string]interface{})
+	}
+
+	for k, v := range patchObj {
+		if v == nil {
+			delete(docObj, k)
+		} else {
+			docObj[k] = mergePatch(docObj[k], v)
+		}
+	}
+
+	return docObj
+}
+
+func (a *apiHandler) writeConfigs(namespace string, f *configFilter, w http.ResponseWriter) (e error) {
 	cfgs := a.a.Configs()
 	var b []byte
+	var next string
+	w.Header().Set("ETag", etag(cfgs.Version))
 
 	if namespace == "" || namespace == config.GlobalNamespace {
 		// All buckets and namespaces
-		b, e = json.Marshal(cfgs.ToProto())
+		p := cfgs.ToProto()
+		next = f.filterService(p)
+		b, e = json.Marshal(p)
 		if e != nil {
 			return
 		}
 	} else {
 		n := cfgs.Namespaces[namespace]
 		if n == nil {
-			e = errors.New("Unable to locate namespace " + namespace)
+			e = config.NotFoundError{Message: "Unable to locate namespace " + namespace}
 			return
 		}
-		b, e = json.Marshal(n.ToProto())
+		p := n.ToProto()
+		next = f.filterBuckets(p, true)
+		b, e = json.Marshal(p)
 		if e != nil {
 			return
 		}
 	}
 
+	if next != "" {
+		w.Header().Set(NextPageTokenHeader, next)
+	}
+
 	w.Write(b)
 	return
 }
 
+// getVersion reads the version of the config an update is based on, from the "version" query
+// parameter, or the If-Match header if there isn't one.
+func getVersion(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("version")
+	if v == "" {
+		if tags := ifMatch(r); len(tags) == 1 && tags[0] != "*" {
+			v = strings.Trim(tags[0], `"`)
+		}
+	}
+
+	if v == "" {
+		return 0, badRequestError{errors.New("Updates need to specify the config version they are based on")}
+	}
+
+	version, e := strconv.Atoi(v)
+	if e != nil {
+		return 0, badRequestError{errors.New("Invalid version " + v)}
+	}
+
+	return version, nil
+}
+
+// etag returns the entity tag of config resources at a version of the config. Resources are
+// tagged with the version of the whole config, as any change bumps it.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch returns the entity tags listed in the request's If-Match header, if any.
+func ifMatch(r *http.Request) []string {
+	h := r.Header.Get("If-Match")
+	if h == "" {
+		return nil
+	}
+
+	tags := strings.Split(h, ",")
+	for i, t := range tags {
+		tags[i] = strings.TrimSpace(t)
+	}
+
+	return tags
+}
+
+// preconditionFailedError is returned when a request's If-Match header doesn't match the config.
+type preconditionFailedError struct {
+	error
+}
+
+// checkIfMatch returns a preconditionFailedError if the request has an If-Match header that
+// doesn't list the current config's entity tag. Changes that take a version also check it when
+// they're applied, so aren't applied if the config changes in the meantime.
+func checkIfMatch(a Administrable, r *http.Request) error {
+	tags := ifMatch(r)
+	if tags == nil {
+		return nil
+	}
+
+	current := etag(a.Configs().Version)
+	for _, t := range tags {
+		// Weak tags never match, as If-Match uses strong comparison.
+		if t == "*" || t == current {
+			return nil
+		}
+	}
+
+	return preconditionFailedError{fmt.Errorf("Config is at version %v, which doesn't match If-Match %v",
+		current, r.Header.Get("If-Match"))}
+}
+
+// SignatureHeader is the request header holding the hex encoded HMAC-SHA256 of the request body,
+// required on requests that change configs if the server requires signed configs.
+const SignatureHeader = "X-Quotaservice-Signature"
+
+// verifySignature verifies the signature of the request's body, leaving the body to be read again.
+func verifySignature(a Administrable, r *http.Request) error {
+	body, e := ioutil.ReadAll(r.Body)
+	if e != nil {
+		return e
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return a.VerifySignature(body, r.Header.Get(SignatureHeader))
+}
+
+// apiError is the body of error responses from the REST API.
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	// Problems found validating a config, if that's why a change was rejected.
+	Problems config.ValidationErrors `json:"problems,omitempty"`
+}
+
+// badRequestError is returned when a request can't be read, such as when its body isn't valid
+// JSON.
+type badRequestError struct {
+	error
+}
+
+// writeError writes an error response with the status e maps to, if e isn't nil.
+func writeError(w http.ResponseWriter, e error) {
+	if e == nil {
+		return
+	}
+
+	writeErrorStatus(w, errorStatus(e), e)
+}
+
+func errorStatus(e error) int {
+	switch e.(type) {
+	case badRequestError:
+		return http.StatusBadRequest
+	case config.InvalidSignatureError:
+		return http.StatusForbidden
+	case NotImplementedError:
+		return http.StatusNotImplemented
+	case preconditionFailedError:
+		return http.StatusPreconditionFailed
+	case config.NotFoundError, config.UnknownVersionError:
+		return http.StatusNotFound
+	case config.VersionMismatchError, config.AlreadyExistsError:
+		return http.StatusConflict
+	case config.ValidationErrors:
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
+func writeErrorStatus(w http.ResponseWriter, status int, e error) {
+	logging.Println("Caught error", e)
+	body := apiError{Status: status, Message: e.Error()}
+	if problems, ok := e.(config.ValidationErrors); ok {
+		body.Problems = problems
+	}
+
+	b, e := json.Marshal(body)
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+type configHandler struct {
+	a Administrable
+}
+
+// configVersion summarizes a historical config, as listed by /api/config/history.
+type configVersion struct {
+	Version    int    `json:"version"`
+	Date       int64  `json:"date"`
+	User       string `json:"user,omitempty"`
+	Namespaces int    `json:"namespaces"`
+}
+
+// configValidation is the result of validating a config with /api/config/validate.
+type configValidation struct {
+	Valid  bool                    `json:"valid"`
+	Errors config.ValidationErrors `json:"errors"`
+}
+
+func (c *configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api/config" && r.Method == "PUT":
+		if e := checkIfMatch(c.a, r); e != nil {
+			writeError(w, e)
+			return
+		}
+
+		version, e := getVersion(r)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		if e = verifySignature(c.a, r); e != nil {
+			writeError(w, e)
+			return
+		}
+
+		cfg, e := getServiceConfig(r)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		writeError(w, c.a.ReplaceConfig(cfg, version, identity(r)))
+	case r.URL.Path == "/api/config/validate" && r.Method == "POST":
+		c.writeValidation(w, r)
+	case r.URL.Path == "/api/config/history" && r.Method == "GET":
+		writeError(w, c.writeHistory(w))
+	case r.URL.Path == "/api/config/export" && r.Method == "GET":
+		cfgs := c.a.Configs()
+		y, e := cfgs.ToYAML()
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		w.Header().Set("ETag", etag(cfgs.Version))
+		w.Header().Set("Content-Type", "application/x-yaml")
+		w.Write(y)
+	case strings.HasPrefix(r.URL.Path, "/api/config/rollback/") && r.Method == "POST":
+		v := strings.TrimPrefix(r.URL.Path, "/api/config/rollback/")
+		version, e := strconv.Atoi(v)
+		if e != nil {
+			writeErrorStatus(w, http.StatusBadRequest, errors.New("Invalid version "+v))
+			return
+		}
+
+		if e = checkIfMatch(c.a, r); e != nil {
+			writeError(w, e)
+			return
+		}
+
+		writeError(w, c.a.RollbackConfig(version, identity(r)))
+	default:
+		writeErrorStatus(w, http.StatusNotFound, fmt.Errorf("Not handling %v %v", r.Method, r.URL.Path))
+	}
+}
+
+// writeValidation validates the config in the request without applying it. Problems are listed
+// with a 422 status, so that invalid configs fail CI checks.
+func (c *configHandler) writeValidation(w http.ResponseWriter, r *http.Request) {
+	result := configValidation{Valid: true, Errors: config.ValidationErrors{}}
+	if _, e := getServiceConfig(r); e != nil {
+		result.Valid = false
+		if problems, ok := e.(config.ValidationErrors); ok {
+			result.Errors = problems
+		} else {
+			// Configs that can't be parsed have no settings to point to.
+			result.Errors = config.ValidationErrors{{Message: e.Error()}}
+		}
+	}
+
+	b, e := json.Marshal(result)
+	if e != nil {
+		logging.Print("Caught error ", e)
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Valid {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	w.Write(b)
+}
+
+func (c *configHandler) writeHistory(w http.ResponseWriter) error {
+	history, e := c.a.HistoricalConfigs()
+	if e != nil {
+		return e
+	}
+
+	versions := make([]configVersion, len(history))
+	for i, cfg := range history {
+		versions[i] = configVersion{cfg.Version, cfg.Date, cfg.User, len(cfg.Namespaces)}
+	}
+
+	b, e := json.Marshal(versions)
+	if e != nil {
+		return e
+	}
+
+	w.Write(b)
+	return nil
+}
+
 func extractNamespaceName(params string) (namespace, name string) {
 	// params should be in the format xyz/abc. We just split on '/'
 	parts := strings.Split(params, "/")
@@ -209,7 +867,38 @@ func getBucketConfig(r io.Reader) (*pb.BucketConfig, error) {
 		return nil, err
 	}
 	c := &pb.BucketConfig{}
-	json.Unmarshal(bytes, c)
+	if err = config.ProtoFromJSON(bytes, c); err != nil {
+		return nil, badRequestError{err}
+	}
+	return c, nil
+}
+
+// getServiceConfig reads a whole config, validating it and applying defaults. Configs are read as
+// JSON if the request's content type says so, and as YAML otherwise.
+func getServiceConfig(r *http.Request) (*config.ServiceConfig, error) {
+	bytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var c *config.ServiceConfig
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if c, err = config.FromJSON(bytes); err == nil {
+			if err = c.Validate(); err == nil {
+				c.ApplyDefaults()
+			}
+		}
+	} else {
+		c, err = config.ParseConfig(bytes)
+	}
+
+	if err != nil {
+		if _, ok := err.(config.ValidationErrors); !ok {
+			err = badRequestError{err}
+		}
+		return nil, err
+	}
+
 	return c, nil
 }
 
@@ -219,6 +908,8 @@ func getNamespaceConfig(r io.Reader) (*pb.NamespaceConfig, error) {
 		return nil, err
 	}
 	c := &pb.NamespaceConfig{}
-	json.Unmarshal(bytes, c)
+	if err = config.ProtoFromJSON(bytes, c); err != nil {
+		return nil, badRequestError{err}
+	}
 	return c, nil
 }
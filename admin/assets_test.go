@@ -0,0 +1,63 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedAssetsUpToDate(t *testing.T) {
+	files := 0
+	e := filepath.Walk("public", func(path string, info os.FileInfo, e error) error {
+		if e != nil || info.IsDir() {
+			return e
+		}
+
+		files++
+		contents, e := ioutil.ReadFile(path)
+		if e != nil {
+			return e
+		}
+
+		name, _ := filepath.Rel("public", path)
+		if assets[filepath.ToSlash(name)] != string(contents) {
+			t.Fatalf("Embedded %v is out of date. Run go generate.", name)
+		}
+		return nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if files != len(assets) {
+		t.Fatalf("Expecting %v embedded assets, but there are %v. Run go generate.", files, len(assets))
+	}
+}
+
+func TestServeEmbeddedAssets(t *testing.T) {
+	mux := http.NewServeMux()
+	ServeAdminConsole(nil, mux, "", Options{})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rsp, e := http.Get(srv.URL + "/js/app.js")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer rsp.Body.Close()
+
+	body, _ := ioutil.ReadAll(rsp.Body)
+	if rsp.StatusCode != http.StatusOK || string(body) != assets["js/app.js"] {
+		t.Fatalf("Expecting embedded app.js to be served. Status %v", rsp.Status)
+	}
+
+	if embeddedTemplates().Lookup("index.html") == nil {
+		t.Fatal("Expecting embedded index.html template")
+	}
+}
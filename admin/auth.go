@@ -0,0 +1,136 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// Authenticator authenticates requests made to the admin console and REST API.
+type Authenticator interface {
+	// Authenticate returns the identity of whoever made the request, or an error if the request
+	// doesn't carry valid credentials.
+	Authenticate(r *http.Request) (identity string, err error)
+	// Challenge is the WWW-Authenticate header sent with responses to unauthenticated requests.
+	Challenge() string
+}
+
+var errNoCredentials = errors.New("No credentials provided")
+
+type basicAuthenticator struct {
+	credentials map[string]string
+}
+
+// NewBasicAuthenticator creates an Authenticator accepting HTTP basic auth credentials, given as
+// usernames to passwords. Identities are usernames.
+func NewBasicAuthenticator(credentials map[string]string) Authenticator {
+	return &basicAuthenticator{credentials}
+}
+
+func (b *basicAuthenticator) Authenticate(r *http.Request) (string, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return "", errNoCredentials
+	}
+
+	expected, exists := b.credentials[user]
+	// Compare anyway, so unknown users take as long to reject as wrong passwords.
+	if subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 || !exists {
+		return "", errors.New("Invalid credentials for user " + user)
+	}
+
+	return user, nil
+}
+
+func (b *basicAuthenticator) Challenge() string {
+	return `Basic realm="quotaservice"`
+}
+
+// TokenVerifier verifies a bearer token, returning the identity it was issued to.
+type TokenVerifier func(token string) (identity string, err error)
+
+// StaticTokens is a TokenVerifier accepting a fixed set of tokens, given as tokens to identities.
+func StaticTokens(tokens map[string]string) TokenVerifier {
+	return func(token string) (string, error) {
+		for t, identity := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return identity, nil
+			}
+		}
+
+		return "", errors.New("Invalid token")
+	}
+}
+
+type tokenAuthenticator struct {
+	verify TokenVerifier
+}
+
+// NewTokenAuthenticator creates an Authenticator accepting bearer tokens, in the Authorization
+// header, that verify accepts.
+func NewTokenAuthenticator(verify TokenVerifier) Authenticator {
+	return &tokenAuthenticator{verify}
+}
+
+func (t *tokenAuthenticator) Authenticate(r *http.Request) (string, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return "", errNoCredentials
+	}
+
+	return t.verify(token)
+}
+
+func (t *tokenAuthenticator) Challenge() string {
+	return `Bearer realm="quotaservice"`
+}
+
+// bearerToken returns the bearer token in the request's Authorization header, if any.
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) < len("Bearer ") || !strings.EqualFold(h[:len("Bearer ")], "Bearer ") {
+		return ""
+	}
+
+	return strings.TrimSpace(h[len("Bearer "):])
+}
+
+type identityKey struct{}
+
+// identity returns the identity a request was authenticated as, falling back to the name on its
+// client certificate, or an empty string if it wasn't authenticated.
+func identity(r *http.Request) string {
+	if id, _ := r.Context().Value(identityKey{}).(string); id != "" {
+		return id
+	}
+
+	return clientCertName(r)
+}
+
+// authenticated only passes requests that auth authenticates on to h, with the identity they were
+// authenticated as. If auth is nil, all requests are passed on.
+func authenticated(auth Authenticator, h http.Handler) http.Handler {
+	if auth == nil {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, e := auth.Authenticate(r)
+		if e != nil {
+			logging.Printf("Rejecting unauthenticated request for %v. Error: %v", r.URL.Path, e)
+			w.Header().Set("WWW-Authenticate", auth.Challenge())
+			writeErrorStatus(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+
+		setCaller(r, id)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
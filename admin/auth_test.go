@@ -0,0 +1,47 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBasicAuthenticator(t *testing.T) {
+	auth := NewBasicAuthenticator(map[string]string{"alice": "secret"})
+	for _, creds := range [][2]string{{"alice", "wrong"}, {"bob", "secret"}, {"bob", ""}} {
+		r, _ := http.NewRequest("GET", "/api/", nil)
+		r.SetBasicAuth(creds[0], creds[1])
+		if _, e := auth.Authenticate(r); e == nil {
+			t.Fatalf("Expecting credentials %v to be rejected", creds)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/api/", nil)
+	if _, e := auth.Authenticate(r); e == nil {
+		t.Fatal("Expecting request without credentials to be rejected")
+	}
+
+	r.SetBasicAuth("alice", "secret")
+	if identity, e := auth.Authenticate(r); e != nil || identity != "alice" {
+		t.Fatalf("Expecting alice to be authenticated. Was %v, error %v", identity, e)
+	}
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	auth := NewTokenAuthenticator(StaticTokens(map[string]string{"t0k3n": "deployer"}))
+	for _, header := range []string{"", "Bearer", "Bearer wrong", "Basic t0k3n"} {
+		r, _ := http.NewRequest("GET", "/api/", nil)
+		r.Header.Set("Authorization", header)
+		if _, e := auth.Authenticate(r); e == nil {
+			t.Fatalf("Expecting Authorization header %q to be rejected", header)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/api/", nil)
+	r.Header.Set("Authorization", "bearer t0k3n")
+	if identity, e := auth.Authenticate(r); e != nil || identity != "deployer" {
+		t.Fatalf("Expecting token to be accepted. Was %v, error %v", identity, e)
+	}
+}
@@ -0,0 +1,105 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures Cross-Origin Resource Sharing for the REST API, so that admin frontends
+// hosted elsewhere can call it from browsers.
+type CORSConfig struct {
+	// Origins allowed to call the API, such as https://admin.example.com, or * for any origin.
+	AllowedOrigins []string
+	// Methods allowed in cross-origin requests. Defaults to all methods the API supports.
+	AllowedMethods []string
+	// Request headers allowed in cross-origin requests. Defaults to those the API uses.
+	AllowedHeaders []string
+	// Response headers browsers expose to frontends.
+	ExposedHeaders []string
+	// AllowCredentials allows browsers to send cookies and HTTP authentication, from origins listed
+	// by name. Origins only allowed by * are never sent credentials, since any site could then make
+	// requests as the admin using it.
+	AllowCredentials bool
+	// How long browsers may cache the results of preflight requests.
+	MaxAge time.Duration
+}
+
+var (
+	defaultCORSMethods = []string{"GET", "PUT", "POST", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "If-Match", SignatureHeader}
+)
+
+// allowsOrigin tells whether requests from origin are allowed, and whether origin is listed by
+// name, rather than only allowed by *.
+func (c *CORSConfig) allowsOrigin(origin string) (allowed, listed bool) {
+	for _, o := range c.AllowedOrigins {
+		if o == origin {
+			return true, true
+		}
+
+		if o == "*" {
+			allowed = true
+		}
+	}
+
+	return allowed, false
+}
+
+// withCORS adds CORS headers to responses to requests from allowed origins, and answers their
+// preflight requests itself. If c is nil, requests are passed on to h as they are.
+func withCORS(c *CORSConfig, h http.Handler) http.Handler {
+	if c == nil {
+		return h
+	}
+
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+
+	headers := c.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		// Responses depend on the origin, so mustn't be cached for other origins.
+		w.Header().Add("Vary", "Origin")
+		allowed, listed := c.allowsOrigin(origin)
+		if origin == "" || !allowed {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if listed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if c.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		} else {
+			// Origins only allowed by * aren't reflected, so browsers never share credentialed responses with them.
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			if c.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if len(c.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
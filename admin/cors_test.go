@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	mux := http.NewServeMux()
+	ServeAdminConsole(nil, mux, "", Options{
+		Authenticator: NewBasicAuthenticator(map[string]string{"admin": "secret"}),
+		CORS: &CORSConfig{
+			AllowedOrigins: []string{"https://ui.example.com"},
+			ExposedHeaders: []string{"ETag"},
+			MaxAge:         10 * time.Minute}})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, origin string, authenticate bool) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+OpenAPIPath, nil)
+		req.Header.Set("Origin", origin)
+		if method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "GET")
+		}
+		if authenticate {
+			req.SetBasicAuth("admin", "secret")
+		}
+
+		rsp, e := http.DefaultClient.Do(req)
+		if e != nil {
+			t.Fatal(e)
+		}
+		rsp.Body.Close()
+		return rsp
+	}
+
+	// Preflight requests don't carry credentials.
+	rsp := do("OPTIONS", "https://ui.example.com", false)
+	if rsp.StatusCode != http.StatusNoContent ||
+		rsp.Header.Get("Access-Control-Allow-Origin") != "https://ui.example.com" ||
+		rsp.Header.Get("Access-Control-Allow-Methods") != "GET, PUT, POST, PATCH, DELETE" ||
+		rsp.Header.Get("Access-Control-Allow-Headers") != "Authorization, Content-Type, If-Match, "+SignatureHeader ||
+		rsp.Header.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("Unexpected response to preflight request: %v %v", rsp.Status, rsp.Header)
+	}
+
+	rsp = do("GET", "https://ui.example.com", true)
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Access-Control-Allow-Origin") != "https://ui.example.com" ||
+		rsp.Header.Get("Access-Control-Expose-Headers") != "ETag" {
+		t.Fatalf("Unexpected response to cross-origin request: %v %v", rsp.Status, rsp.Header)
+	}
+
+	// Requests still need to be authenticated.
+	if rsp = do("GET", "https://ui.example.com", false); rsp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expecting unauthenticated cross-origin request to be rejected. Status %v", rsp.Status)
+	}
+
+	for _, method := range []string{"OPTIONS", "GET"} {
+		if rsp = do(method, "https://evil.example.com", true); rsp.Header.Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("Expecting %v from other origins not to be allowed. Headers %v", method, rsp.Header)
+		}
+	}
+}
+
+func TestCORSCredentialsOnlyForListedOrigins(t *testing.T) {
+	h := withCORS(&CORSConfig{
+		AllowedOrigins:   []string{"https://ui.example.com", "*"},
+		AllowCredentials: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, test := range []struct {
+		origin, allowed, credentials string
+	}{
+		{"https://ui.example.com", "https://ui.example.com", "true"},
+		{"https://evil.example.com", "*", ""}} {
+		req := httptest.NewRequest("GET", "/api/", nil)
+		req.Header.Set("Origin", test.origin)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Header().Get("Access-Control-Allow-Origin") != test.allowed || w.Header().Get("Access-Control-Allow-Credentials") != test.credentials {
+			t.Errorf("%v: expecting origin %q and credentials %q to be allowed. Headers %v", test.origin, test.allowed, test.credentials, w.Header())
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	pb "github.com/maniksurtani/quotaservice/protos/config"
+)
+
+// NextPageTokenHeader is the response header holding the page_token to read the next page of a
+// config with, if there are more namespaces or buckets than the limit the request specified.
+const NextPageTokenHeader = "X-Next-Page-Token"
+
+// configFilter selects the slice of a config that GET requests read, from their query parameters:
+//
+//	namespace_prefix: only namespaces whose names start with the prefix
+//	bucket_prefix:    only buckets whose names start with the prefix
+//	limit:            at most this many namespaces, or buckets when reading a namespace
+//	page_token:       only namespaces, or buckets, named after the token a previous page returned
+type configFilter struct {
+	namespacePrefix string
+	bucketPrefix    string
+	limit           int
+	pageToken       string
+}
+
+func getConfigFilter(r *http.Request) (*configFilter, error) {
+	q := r.URL.Query()
+	f := &configFilter{
+		namespacePrefix: q.Get("namespace_prefix"),
+		bucketPrefix:    q.Get("bucket_prefix"),
+		pageToken:       q.Get("page_token")}
+
+	if l := q.Get("limit"); l != "" {
+		limit, e := strconv.Atoi(l)
+		if e != nil || limit < 1 {
+			return nil, badRequestError{errors.New("Invalid limit " + l)}
+		}
+		f.limit = limit
+	}
+
+	return f, nil
+}
+
+// filterService filters the namespaces of a config, and their buckets, returning the page token of
+// the next page if there is one. The global default bucket is only included in the first page.
+func (f *configFilter) filterService(cfg *pb.ServiceConfig) string {
+	namespaces := make([]*pb.NamespaceConfig, 0, len(cfg.Namespaces))
+	next := ""
+	// Namespaces are sorted by name.
+	for _, ns := range cfg.Namespaces {
+		if !strings.HasPrefix(ns.Name, f.namespacePrefix) || ns.Name <= f.pageToken {
+			continue
+		}
+
+		if f.limit > 0 && len(namespaces) == f.limit {
+			next = namespaces[len(namespaces)-1].Name
+			break
+		}
+
+		f.filterBuckets(ns, false)
+		namespaces = append(namespaces, ns)
+	}
+
+	cfg.Namespaces = namespaces
+	if f.pageToken != "" {
+		cfg.GlobalDefaultBucket = nil
+	}
+
+	return next
+}
+
+// filterBuckets filters the buckets of a namespace. If paged, the buckets are paged through too,
+// returning the page token of the next page if there is one.
+func (f *configFilter) filterBuckets(cfg *pb.NamespaceConfig, paged bool) string {
+	buckets := make([]*pb.BucketConfig, 0, len(cfg.Buckets))
+	next := ""
+	// Buckets are sorted by name.
+	for _, b := range cfg.Buckets {
+		if !strings.HasPrefix(b.Name, f.bucketPrefix) || (paged && b.Name <= f.pageToken) {
+			continue
+		}
+
+		if paged && f.limit > 0 && len(buckets) == f.limit {
+			next = buckets[len(buckets)-1].Name
+			break
+		}
+
+		buckets = append(buckets, b)
+	}
+
+	cfg.Buckets = buckets
+	return next
+}
+
+// filterStatuses filters and pages through the statuses of live buckets, sorted by name, returning
+// the page token of the next page if there is one.
+func (f *configFilter) filterStatuses(statuses []*BucketStatus) ([]*BucketStatus, string) {
+	filtered := make([]*BucketStatus, 0, len(statuses))
+	for _, s := range statuses {
+		if !strings.HasPrefix(s.Name, f.bucketPrefix) || s.Name <= f.pageToken {
+			continue
+		}
+
+		if f.limit > 0 && len(filtered) == f.limit {
+			return filtered, filtered[len(filtered)-1].Name
+		}
+
+		filtered = append(filtered, s)
+	}
+
+	return filtered, ""
+}
@@ -0,0 +1,67 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+//go:build ignore
+// +build ignore
+
+// Generates assets.go, embedding the admin UI under public/ into the binary. Run via go generate
+// whenever the UI changes.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const assetsDirectory = "public"
+
+func main() {
+	var b bytes.Buffer
+	b.WriteString(`// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Code generated by gen_assets.go. DO NOT EDIT.
+
+package admin
+
+// assets are the admin UI's templates and other files, by path relative to the public directory.
+var assets = map[string]string{
+`)
+
+	e := filepath.Walk(assetsDirectory, func(path string, info os.FileInfo, e error) error {
+		if e != nil || info.IsDir() {
+			return e
+		}
+
+		contents, e := ioutil.ReadFile(path)
+		if e != nil {
+			return e
+		}
+
+		name, e := filepath.Rel(assetsDirectory, path)
+		if e != nil {
+			return e
+		}
+
+		fmt.Fprintf(&b, "%q: %v,\n", filepath.ToSlash(name), strconv.Quote(string(contents)))
+		return nil
+	})
+	check(e)
+
+	b.WriteString("}\n")
+	src, e := format.Source(b.Bytes())
+	check(e)
+	check(ioutil.WriteFile("assets.go", src, 0644))
+}
+
+func check(e error) {
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(1)
+	}
+}
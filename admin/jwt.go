@@ -0,0 +1,283 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// How long to wait for the JWKS endpoint to respond.
+	jwksTimeout = 30 * time.Second
+	// How long to wait before fetching keys again, when a token is signed with an unknown key.
+	jwksRefreshInterval = time.Minute
+	// Clock skew tolerated when checking when tokens expire or become valid.
+	jwtLeeway = time.Minute
+)
+
+// JWTConfig configures how JWTs issued by an SSO provider are verified.
+type JWTConfig struct {
+	// Issuer tokens must be issued by, matching their iss claim.
+	Issuer string
+	// Audience tokens must be issued for, one of their aud claims.
+	Audience string
+	// JWKSURL is where the provider publishes its signing keys, as a JSON Web Key Set.
+	JWKSURL string
+	// IdentityClaim is the claim identifying the user, such as email. Defaults to sub.
+	IdentityClaim string
+	// Client used to fetch keys. Defaults to a client with a 30 second timeout.
+	Client *http.Client
+}
+
+// NewJWTAuthenticator creates an Authenticator accepting JWTs signed using RS256 by the provider,
+// as bearer tokens. Keys are fetched when first needed, and fetched again when tokens are signed
+// with keys not seen before, so that providers can rotate keys.
+func NewJWTAuthenticator(cfg JWTConfig) Authenticator {
+	if cfg.IdentityClaim == "" {
+		cfg.IdentityClaim = "sub"
+	}
+
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: jwksTimeout}
+	}
+
+	v := &jwtVerifier{cfg: cfg, keys: make(map[string]*rsa.PublicKey), now: time.Now}
+	return NewTokenAuthenticator(v.verify)
+}
+
+type jwtVerifier struct {
+	cfg JWTConfig
+	now func() time.Time
+
+	sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	// Closed once the fetch of keys underway, if any, is done.
+	fetching chan struct{}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (v *jwtVerifier) verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("Malformed JWT")
+	}
+
+	header := &jwtHeader{}
+	if e := decodeJWTPart(parts[0], header); e != nil {
+		return "", e
+	}
+
+	// Only accepting RS256 rules out tokens that aren't signed, or are signed with the public key
+	// as an HMAC secret.
+	if header.Alg != "RS256" {
+		return "", fmt.Errorf("Unsupported JWT algorithm %v", header.Alg)
+	}
+
+	key, e := v.key(header.Kid)
+	if e != nil {
+		return "", e
+	}
+
+	signature, e := base64.RawURLEncoding.DecodeString(parts[2])
+	if e != nil {
+		return "", errors.New("Malformed JWT signature")
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+		return "", errors.New("Invalid JWT signature")
+	}
+
+	var claims map[string]interface{}
+	if e = decodeJWTPart(parts[1], &claims); e != nil {
+		return "", e
+	}
+
+	return v.checkClaims(claims)
+}
+
+// checkClaims checks that the token was issued by and for the expected parties, and is currently
+// valid, returning the identity it was issued to.
+func (v *jwtVerifier) checkClaims(claims map[string]interface{}) (string, error) {
+	if iss, _ := claims["iss"].(string); iss != v.cfg.Issuer {
+		return "", fmt.Errorf("JWT issued by %v rather than %v", iss, v.cfg.Issuer)
+	}
+
+	if !hasAudience(claims["aud"], v.cfg.Audience) {
+		return "", fmt.Errorf("JWT not issued for audience %v", v.cfg.Audience)
+	}
+
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return "", errors.New("JWT has no expiry")
+	}
+
+	if now.Add(-jwtLeeway).After(time.Unix(int64(exp), 0)) {
+		return "", errors.New("JWT has expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return "", errors.New("JWT is not valid yet")
+	}
+
+	identity, _ := claims[v.cfg.IdentityClaim].(string)
+	if identity == "" {
+		return "", fmt.Errorf("JWT has no %v claim", v.cfg.IdentityClaim)
+	}
+
+	return identity, nil
+}
+
+// hasAudience returns true if aud, either a string or a list of strings, contains audience.
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// key returns the provider's key with the given ID, fetching keys if it isn't known. Keys are
+// fetched without holding the lock, so tokens signed with known keys are verified meanwhile, while
+// those signed with unknown keys wait for the fetch underway rather than starting another.
+func (v *jwtVerifier) key(kid string) (*rsa.PublicKey, error) {
+	v.Lock()
+	if key := v.keys[kid]; key != nil {
+		v.Unlock()
+		return key, nil
+	}
+
+	if fetching := v.fetching; fetching != nil {
+		v.Unlock()
+		<-fetching
+		return v.knownKey(kid)
+	}
+
+	// Stops tokens signed with made up keys from hammering the provider.
+	if v.now().Sub(v.fetchedAt) < jwksRefreshInterval {
+		v.Unlock()
+		return nil, fmt.Errorf("Unknown JWT signing key %v", kid)
+	}
+
+	v.fetchedAt = v.now()
+	fetching := make(chan struct{})
+	v.fetching = fetching
+	v.Unlock()
+
+	keys, e := fetchJWKS(v.cfg.Client, v.cfg.JWKSURL)
+
+	v.Lock()
+	if e == nil {
+		v.keys = keys
+	}
+	v.fetching = nil
+	close(fetching)
+	v.Unlock()
+
+	if e != nil {
+		return nil, fmt.Errorf("Unable to fetch JWT signing keys from %v. Error: %v", v.cfg.JWKSURL, e)
+	}
+
+	return v.knownKey(kid)
+}
+
+// knownKey returns the key with the given ID, from those last fetched.
+func (v *jwtVerifier) knownKey(kid string) (*rsa.PublicKey, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	if key := v.keys[kid]; key != nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("Unknown JWT signing key %v", kid)
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// fetchJWKS fetches the RSA signing keys in a JSON Web Key Set, by key ID.
+func fetchJWKS(client *http.Client, url string) (map[string]*rsa.PublicKey, error) {
+	rsp, e := client.Get(url)
+	if e != nil {
+		return nil, e
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected response %v", rsp.Status)
+	}
+
+	set := &jwks{}
+	if e = json.NewDecoder(rsp.Body).Decode(set); e != nil {
+		return nil, e
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, e := base64.RawURLEncoding.DecodeString(k.N)
+		if e != nil {
+			return nil, fmt.Errorf("Invalid modulus for key %v", k.Kid)
+		}
+
+		exp, e := base64.RawURLEncoding.DecodeString(k.E)
+		if e != nil || len(exp) > 4 {
+			return nil, fmt.Errorf("Invalid exponent for key %v", k.Kid)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(exp).Int64())}
+	}
+
+	return keys, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	b, e := base64.RawURLEncoding.DecodeString(part)
+	if e != nil {
+		return errors.New("Malformed JWT")
+	}
+
+	if e = json.Unmarshal(b, v); e != nil {
+		return errors.New("Malformed JWT")
+	}
+
+	return nil
+}
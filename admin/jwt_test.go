@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWTAuthenticator(t *testing.T) {
+	key, e := rsa.GenerateKey(rand.Reader, 2048)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	other, e := rsa.GenerateKey(rand.Reader, 2048)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())}}})
+	}))
+	defer srv.Close()
+
+	auth := NewJWTAuthenticator(JWTConfig{
+		Issuer:        "https://sso.example.com",
+		Audience:      "quotaservice",
+		JWKSURL:       srv.URL,
+		IdentityClaim: "email"})
+
+	now := time.Now().Unix()
+	valid := map[string]interface{}{
+		"iss":   "https://sso.example.com",
+		"aud":   []string{"other", "quotaservice"},
+		"exp":   now + 600,
+		"email": "alice@example.com"}
+
+	authenticate := func(token string) (string, error) {
+		r, _ := http.NewRequest("GET", "/api/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		return auth.Authenticate(r)
+	}
+
+	identity, e := authenticate(signJWT(t, key, "RS256", "k1", valid))
+	if e != nil || identity != "alice@example.com" {
+		t.Fatalf("Expecting valid token to be accepted. Was %v, error %v", identity, e)
+	}
+
+	for name, token := range map[string]string{
+		"wrong issuer":     signJWT(t, key, "RS256", "k1", with(valid, "iss", "https://evil.example.com")),
+		"wrong audience":   signJWT(t, key, "RS256", "k1", with(valid, "aud", "other")),
+		"expired":          signJWT(t, key, "RS256", "k1", with(valid, "exp", now-600)),
+		"no expiry":        signJWT(t, key, "RS256", "k1", with(valid, "exp", nil)),
+		"not yet valid":    signJWT(t, key, "RS256", "k1", with(valid, "nbf", now+600)),
+		"no identity":      signJWT(t, key, "RS256", "k1", with(valid, "email", nil)),
+		"wrong key":        signJWT(t, other, "RS256", "k1", valid),
+		"unknown key":      signJWT(t, other, "RS256", "k2", valid),
+		"wrong algorithm":  signJWT(t, key, "HS256", "k1", valid),
+		"malformed":        "not.a.jwt",
+		"missing segments": "abc"} {
+		if _, e := authenticate(token); e == nil {
+			t.Fatalf("Expecting token with %v to be rejected", name)
+		}
+	}
+
+	// Keys are fetched once, and unknown keys don't trigger fetches straight away.
+	if fetches != 1 {
+		t.Fatalf("Expecting keys to be fetched once, but were fetched %v times", fetches)
+	}
+}
+
+func TestJWTKeysFetchedWithoutBlockingKnownKeys(t *testing.T) {
+	key, e := rsa.GenerateKey(rand.Reader, 2048)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(fetching)
+		<-release
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{}})
+	}))
+	defer srv.Close()
+
+	v := &jwtVerifier{
+		cfg:  JWTConfig{JWKSURL: srv.URL, Client: http.DefaultClient},
+		keys: map[string]*rsa.PublicKey{"k1": &key.PublicKey},
+		now:  time.Now}
+
+	fetched := make(chan error)
+	go func() {
+		_, e := v.key("k2")
+		fetched <- e
+	}()
+
+	<-fetching
+	if k, e := v.key("k1"); e != nil || k != &key.PublicKey {
+		t.Fatalf("Expecting known key while keys are fetched. Was %v, error %v", k, e)
+	}
+
+	close(release)
+	if e := <-fetched; e == nil {
+		t.Fatal("Expecting key missing from those fetched to be unknown")
+	}
+}
+
+func with(claims map[string]interface{}, claim string, value interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		c[k] = v
+	}
+
+	if value == nil {
+		delete(c, claim)
+	} else {
+		c[claim] = value
+	}
+
+	return c
+}
+
+// signJWT signs claims using RS256, whatever alg the header claims.
+func signJWT(t *testing.T, key *rsa.PrivateKey, alg, kid string, claims map[string]interface{}) string {
+	header, e := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	payload, e := json.Marshal(claims)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, e := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,56 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// LogLevelPath is where the server's log level is read and changed.
+const LogLevelPath = "/api/loglevel"
+
+// logLevel is the body of log level requests and responses.
+type logLevel struct {
+	// debug, info, warn or error.
+	Level string `json:"level"`
+}
+
+// logLevelHandler reads the least severe level of message the server logs with GET, and changes it
+// with PUT. Changes last until the server restarts, and aren't configs, so needn't be staged.
+type logLevelHandler struct{}
+
+func (l *logLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, &logLevel{logging.CurrentLevel().String()})
+	case "PUT":
+		body := &logLevel{}
+		if e := json.NewDecoder(r.Body).Decode(body); e != nil {
+			writeError(w, badRequestError{e})
+			return
+		}
+
+		level, e := logging.ParseLevel(body.Level)
+		if e != nil {
+			writeError(w, badRequestError{e})
+			return
+		}
+
+		// Logged under the more verbose of the two levels, so the change is logged if either logs info.
+		old := logging.CurrentLevel()
+		if level < old {
+			logging.SetLevel(level)
+		}
+		logging.Printf("Log level changed from %v to %v by %v", old, level, identity(r))
+		logging.SetLevel(level)
+
+		writeJSON(w, http.StatusOK, &logLevel{level.String()})
+	default:
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+	}
+}
@@ -0,0 +1,64 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+func TestLogLevel(t *testing.T) {
+	defer logging.SetLevel(logging.CurrentLevel())
+	logging.SetLevel(logging.LevelInfo)
+
+	mux := http.NewServeMux()
+	ServeAdminConsole(nil, mux, "", Options{Staging: &StagingConfig{Required: true}})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, body string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+LogLevelPath, strings.NewReader(body))
+		rsp, e := http.DefaultClient.Do(req)
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer rsp.Body.Close()
+
+		l := &logLevel{}
+		json.NewDecoder(rsp.Body).Decode(l)
+		return rsp.StatusCode, l.Level
+	}
+
+	if status, level := do("GET", ""); status != http.StatusOK || level != "info" {
+		t.Fatalf("Expecting level info, got %v %q", status, level)
+	}
+
+	// Changing the log level needn't be staged.
+	if status, level := do("PUT", `{"level": "debug"}`); status != http.StatusOK || level != "debug" {
+		t.Fatalf("Expecting level to be changed to debug, got %v %q", status, level)
+	}
+
+	if logging.CurrentLevel() != logging.LevelDebug {
+		t.Fatalf("Expecting the server to log at debug, got %v", logging.CurrentLevel())
+	}
+
+	for _, body := range []string{`{"level": "verbose"}`, `debug`} {
+		if status, _ := do("PUT", body); status != http.StatusBadRequest {
+			t.Errorf("%v: expecting a 400, got %v", body, status)
+		}
+	}
+
+	if status, _ := do("DELETE", ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("Expecting DELETE not to be allowed, got %v", status)
+	}
+
+	if logging.CurrentLevel() != logging.LevelDebug {
+		t.Errorf("Expecting invalid requests to leave the level as it was, got %v", logging.CurrentLevel())
+	}
+}
@@ -0,0 +1,308 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos/config"
+)
+
+// OpenAPIPath is where ServeAdminConsole serves an OpenAPI 3 document describing the REST API.
+const OpenAPIPath = "/api/openapi.json"
+
+type object map[string]interface{}
+
+// schemaTypes are the types described under components/schemas in the OpenAPI document, by name.
+// Schemas are generated from the types' JSON representations, so they can't drift.
+var schemaTypes = map[string]reflect.Type{
+	"ServiceConfig":    reflect.TypeOf(pb.ServiceConfig{}),
+	"NamespaceConfig":  reflect.TypeOf(pb.NamespaceConfig{}),
+	"BucketConfig":     reflect.TypeOf(pb.BucketConfig{}),
+	"ConfigVersion":    reflect.TypeOf(configVersion{}),
+	"ConfigValidation": reflect.TypeOf(configValidation{}),
+	"ValidationError":  reflect.TypeOf(config.ValidationError{}),
+	"BucketStatus":     reflect.TypeOf(BucketStatus{}),
+	"SearchResult":     reflect.TypeOf(searchResult{}),
+	"StagedChange":     reflect.TypeOf(stagedChange{}),
+	"LogLevel":         reflect.TypeOf(logLevel{}),
+	"BucketUsage":      reflect.TypeOf(BucketUsage{}),
+	"Error":            reflect.TypeOf(apiError{}),
+}
+
+type openAPIHandler struct {
+	spec []byte
+}
+
+func newOpenAPIHandler() *openAPIHandler {
+	spec, e := json.MarshalIndent(openAPISpec(), "", "  ")
+	check(e)
+	return &openAPIHandler{spec}
+}
+
+func (o *openAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(o.spec)
+}
+
+func openAPISpec() object {
+	schemas := object{}
+	for name, t := range schemaTypes {
+		schemas[name] = schemaFor(t)
+	}
+
+	version := object{"name": "version", "in": "query",
+		"description": "Version of the config the change is based on. Defaults to the version in If-Match.",
+		"schema":      object{"type": "integer"}}
+	filters := []object{
+		queryParam("namespace_prefix", "string", "Only reads namespaces whose names start with the prefix."),
+		queryParam("bucket_prefix", "string", "Only reads buckets whose names start with the prefix."),
+		queryParam("limit", "integer", "Reads at most this many namespaces, or buckets when reading a namespace."),
+		queryParam("page_token", "string", "Reads the page the "+NextPageTokenHeader+" response header of the previous page names.")}
+	namespace := pathParam("namespace", "Namespace, or "+config.GlobalNamespace+" for the global default bucket.")
+	bucket := pathParam("bucket", "Bucket name.")
+	bucketBody := jsonBody("BucketConfig")
+	namespaceBody := jsonBody("NamespaceConfig")
+
+	return object{
+		"openapi": "3.0.0",
+		"info": object{
+			"title":   "quotaservice admin API",
+			"version": "1"},
+		"components": object{
+			"schemas": schemas,
+			"securitySchemes": object{
+				"basic":  object{"type": "http", "scheme": "basic"},
+				"bearer": object{"type": "http", "scheme": "bearer"}}},
+		"security": []object{{"basic": []string{}}, {"bearer": []string{}}},
+		"paths": object{
+			"/api/": object{
+				"get": operation("Reads the whole config.", filters, nil, jsonResponse("ServiceConfig"))},
+			"/api/{namespace}": object{
+				"parameters": []object{namespace},
+				"get":        operation("Reads a namespace's config.", filters, nil, jsonResponse("NamespaceConfig"))},
+			"/api/{namespace}/{bucket}": object{
+				"parameters": []object{namespace, bucket},
+				"put":        operation("Adds a bucket.", nil, bucketBody, nil),
+				"post":       operation("Replaces a bucket's config.", []object{version}, bucketBody, nil),
+				"patch": operation("Changes some of a bucket's settings, as a JSON merge patch (RFC 7396).",
+					[]object{version}, object{"required": true, "content": object{
+						"application/merge-patch+json": object{"schema": ref("BucketConfig")}}}, nil),
+				"delete": operation("Deletes a bucket.", nil, nil, nil)},
+			"/api/{namespace}/{bucket}/status": object{
+				"parameters": []object{namespace, bucket},
+				"get": operation("Reads the runtime state of a live bucket, such as the tokens it holds.", nil, nil,
+					jsonResponse("BucketStatus"))},
+			"/api/namespace/{namespace}": object{
+				"parameters": []object{namespace},
+				"put":        operation("Adds a namespace.", nil, namespaceBody, nil),
+				"post":       operation("Replaces a namespace's config.", []object{version}, namespaceBody, nil),
+				"delete":     operation("Deletes a namespace.", nil, nil, nil)},
+			"/api/namespace/{namespace}/dynamic": object{
+				"parameters": []object{namespace},
+				"get": operation("Lists the status of a namespace's live dynamic buckets, sorted by name.", filters[1:], nil,
+					object{"description": "OK", "content": object{"application/json": object{
+						"schema": object{"type": "array", "items": ref("BucketStatus")}}}})},
+			"/api/namespace/{namespace}/dynamic/{bucket}": object{
+				"parameters": []object{namespace, bucket},
+				"delete":     operation("Evicts a live dynamic bucket and its state, without waiting for it to be idle.", nil, nil, nil)},
+			"/api/namespace/{namespace}/export": object{
+				"parameters": []object{namespace},
+				"get": operation("Exports a namespace in canonical YAML, for importing into another config.", nil, nil,
+					object{"description": "OK", "content": object{"application/x-yaml": object{
+						"schema": object{"type": "string"}}}})},
+			"/api/namespace/{namespace}/import": object{
+				"parameters": []object{namespace},
+				"put": operation("Adds a namespace from its YAML export, replacing the namespace if it exists.",
+					[]object{version}, object{"required": true, "content": object{
+						"application/x-yaml": object{"schema": object{"type": "string"}}}}, nil)},
+			TopPath: object{
+				"get": operation("Lists the live buckets with the most of a metric over a window, most first, leaving out those with none.",
+					[]object{
+						queryParam("metric", "string", "denied, the default, timeouts, tokens or grants."),
+						queryParam("window", "string", "1m, 5m, the default, or 1h."),
+						queryParam("limit", "integer", "Lists at most this many buckets, 20 by default.")}, nil,
+					object{"description": "OK", "content": object{"application/json": object{
+						"schema": object{"type": "array", "items": ref("BucketUsage")}}}})},
+			StatsPath + "{namespace}/{bucket}": object{
+				"parameters": []object{namespace, bucket},
+				"get": operation("Reads the requests a live bucket has granted and refused over the last minute, 5 minutes and hour.",
+					nil, nil, jsonResponse("BucketUsage"))},
+			"/api/search": object{
+				"get": operation("Finds the buckets whose names, namespaces' names or FQNs match a pattern, sorted by FQN.",
+					[]object{
+						queryParam("q", "string", "Pattern to match, such as *:payments_*."),
+						queryParam("syntax", "string", "glob, the default, or regex.")}, nil,
+					object{"description": "OK", "content": object{"application/json": object{
+						"schema": object{"type": "array", "items": ref("SearchResult")}}}})},
+			LogLevelPath: object{
+				"get": operation("Reads the least severe level of message the server logs.", nil, nil, jsonResponse("LogLevel")),
+				"put": operation("Changes the level of messages logged, until the server restarts: debug, info, warn or error.",
+					nil, jsonBody("LogLevel"), jsonResponse("LogLevel"))},
+			StagedPath: object{
+				"get": operation("Lists the changes staged to be committed, oldest first.", nil, nil,
+					object{"description": "OK", "content": object{"application/json": object{
+						"schema": object{"type": "array", "items": ref("StagedChange")}}}}),
+				"post": created(operation("Stages the request that would make a change, to be committed later.", nil,
+					jsonBody("StagedChange"), object{"description": "Created", "content": object{
+						"application/json": object{"schema": ref("StagedChange")}}}))},
+			StagedPath + "/{id}": object{
+				"parameters": []object{pathParam("id", "Staged change.")},
+				"get":        operation("Reads a staged change.", nil, nil, jsonResponse("StagedChange"))},
+			StagedPath + "/{id}/commit": object{
+				"parameters": []object{pathParam("id", "Staged change.")},
+				"post":       operation("Makes a staged change, as it was staged.", nil, nil, nil)},
+			StagedPath + "/{id}/abort": object{
+				"parameters": []object{pathParam("id", "Staged change.")},
+				"post":       operation("Drops a staged change without making it.", nil, nil, nil)},
+			"/api/config": object{
+				"put": operation("Replaces the whole config, in YAML or as JSON.", []object{version}, configBody(), nil)},
+			"/api/config/validate": object{
+				"post": operation("Validates a config without applying it. Invalid configs get a 422 response.",
+					nil, configBody(), jsonResponse("ConfigValidation"))},
+			"/api/config/history": object{
+				"get": operation("Lists the most recently persisted configs, most recent first.", nil, nil,
+					object{"description": "OK", "content": object{"application/json": object{
+						"schema": object{"type": "array", "items": ref("ConfigVersion")}}}})},
+			"/api/config/export": object{
+				"get": operation("Exports the config in canonical YAML.", nil, nil,
+					object{"description": "OK", "content": object{"application/x-yaml": object{
+						"schema": object{"type": "string"}}}})},
+			"/api/config/rollback/{version}": object{
+				"parameters": []object{pathParam("version", "Historical version to roll back to.")},
+				"post":       operation("Rolls back to a historical config, as the next version.", nil, nil, nil)},
+			OpenAPIPath: object{
+				"get": operation("This document.", nil, nil, object{"description": "OK"})}}}
+}
+
+func operation(summary string, params []object, body object, ok object) object {
+	if ok == nil {
+		ok = object{"description": "OK"}
+	}
+
+	op := object{
+		"summary": summary,
+		"responses": object{
+			"200":     ok,
+			"default": object{"description": "Error", "content": object{"application/json": object{"schema": ref("Error")}}}}}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if body != nil {
+		op["requestBody"] = body
+	}
+
+	return op
+}
+
+// created documents an operation's success response as 201 Created, rather than 200 OK.
+func created(op object) object {
+	responses := op["responses"].(object)
+	responses["201"] = responses["200"]
+	delete(responses, "200")
+	return op
+}
+
+func pathParam(name, description string) object {
+	return object{"name": name, "in": "path", "required": true, "description": description, "schema": object{"type": "string"}}
+}
+
+func queryParam(name, schemaType, description string) object {
+	return object{"name": name, "in": "query", "description": description, "schema": object{"type": schemaType}}
+}
+
+func ref(schema string) object {
+	return object{"$ref": "#/components/schemas/" + schema}
+}
+
+func jsonBody(schema string) object {
+	return object{"required": true, "content": object{"application/json": object{"schema": ref(schema)}}}
+}
+
+func jsonResponse(schema string) object {
+	return object{"description": "OK", "content": object{"application/json": object{"schema": ref(schema)}}}
+}
+
+func configBody() object {
+	return object{"required": true, "content": object{
+		"application/x-yaml": object{"schema": object{"type": "string"}},
+		"application/json":   object{"schema": ref("ServiceConfig")}}}
+}
+
+// schemaFor generates the JSON schema of a type, as encoding/json marshals it. Structs with a
+// schema of their own are referenced rather than inlined.
+func schemaFor(t reflect.Type) object {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int:
+		return object{"type": "integer"}
+	case reflect.Int32:
+		return object{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return object{"type": "integer", "format": "int64"}
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return object{"type": "string", "format": "byte"}
+		}
+		return object{"type": "array", "items": schemaOrRef(t.Elem())}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": schemaOrRef(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	return object{}
+}
+
+func structSchema(t reflect.Type) object {
+	properties := object{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.PkgPath != "" || name == "-" || strings.HasPrefix(f.Name, "XXX_") {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = schemaOrRef(f.Type)
+	}
+
+	return object{"type": "object", "properties": properties}
+}
+
+// schemaOrRef references the schema describing structs of type t, or pointers to them, if there is
+// one, and generates the type's schema otherwise.
+func schemaOrRef(t reflect.Type) object {
+	st := t
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+
+	for name, schemaType := range schemaTypes {
+		if schemaType == st {
+			return ref(name)
+		}
+	}
+
+	return schemaFor(t)
+}
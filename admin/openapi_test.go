@@ -0,0 +1,68 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	mux := http.NewServeMux()
+	ServeAdminConsole(nil, mux, "", Options{})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rsp, e := http.Get(srv.URL + OpenAPIPath)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Expecting OpenAPI document to be served. Status %v", rsp.Status)
+	}
+
+	var spec map[string]interface{}
+	if e = json.NewDecoder(rsp.Body).Decode(&spec); e != nil {
+		t.Fatal(e)
+	}
+
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	bucket := schemas["BucketConfig"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, field := range []string{"name", "size", "fill_rate", "wait_timeout_millis", "max_tokens_per_request", "labels"} {
+		if bucket[field] == nil {
+			t.Fatalf("Expecting BucketConfig schema to describe %v. Was %v", field, bucket)
+		}
+	}
+
+	namespaces := schemas["ServiceConfig"].(map[string]interface{})["properties"].(map[string]interface{})["namespaces"]
+	expected := map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/NamespaceConfig"}}
+	if !reflect.DeepEqual(namespaces, expected) {
+		t.Fatalf("Expecting namespaces to reference the NamespaceConfig schema. Was %v", namespaces)
+	}
+
+	// All references resolve.
+	var checkRefs func(v interface{})
+	checkRefs = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if r, ok := v["$ref"].(string); ok && schemas[strings.TrimPrefix(r, "#/components/schemas/")] == nil {
+				t.Fatalf("Unresolved reference %v", r)
+			}
+			for _, child := range v {
+				checkRefs(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				checkRefs(child)
+			}
+		}
+	}
+	checkRefs(spec)
+}
@@ -0,0 +1,49 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimiter claims a token for a change a client is about to make, returning false, along with
+// how long the client should wait before trying again, if the client has made too many changes.
+type RateLimiter func(client string) (retryAfter time.Duration, ok bool)
+
+// rateLimited rejects requests that may change configs with a 429 response if limiter doesn't
+// allow them. Clients are identified as they authenticated, or by their address otherwise. If
+// limiter is nil, requests are passed on to h as they are.
+func rateLimited(limiter RateLimiter, h http.Handler) http.Handler {
+	if limiter == nil {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		client := identity(r)
+		if client == "" {
+			client = r.RemoteAddr
+			if host, _, e := net.SplitHostPort(r.RemoteAddr); e == nil {
+				client = host
+			}
+		}
+
+		if retryAfter, ok := limiter(client); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeErrorStatus(w, http.StatusTooManyRequests, fmt.Errorf("Too many changes by %v. Retry in %v", client, retryAfter))
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -1,15 +1,24 @@
 package rest
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/maniksurtani/quotaservice"
 	"github.com/maniksurtani/quotaservice/admin"
 	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos/config"
 )
 
 func TestReadConfigs(t *testing.T) {
@@ -70,13 +79,13 @@ func TestAddGlobalDefault(t *testing.T) {
 
 	b := config.NewDefaultBucketConfig()
 	b.Name = config.DefaultBucketName
-	e := s.(admin.Administrable).AddBucket(config.GlobalNamespace, b.ToProto())
+	e := s.(admin.Administrable).AddBucket(config.GlobalNamespace, b.ToProto(), "")
 	assertNoError(t, e)
 
 	assertDefaultBucketExists(t, s)
 
 	// Now try and add a bucket config again - should error.
-	e = s.(admin.Administrable).AddBucket(config.GlobalNamespace, b.ToProto())
+	e = s.(admin.Administrable).AddBucket(config.GlobalNamespace, b.ToProto(), "")
 	assertError(t, e)
 }
 
@@ -86,13 +95,13 @@ func TestRemoveGlobalDefault(t *testing.T) {
 
 	assertDefaultBucketExists(t, s)
 
-	e := s.(admin.Administrable).DeleteBucket(config.GlobalNamespace, config.DefaultBucketName)
+	e := s.(admin.Administrable).DeleteBucket(config.GlobalNamespace, config.DefaultBucketName, "")
 	assertNoError(t, e)
 
 	assertDefaultBucketDoesNotExist(t, s)
 
 	// Should be idempotent
-	e = s.(admin.Administrable).DeleteBucket(config.GlobalNamespace, config.DefaultBucketName)
+	e = s.(admin.Administrable).DeleteBucket(config.GlobalNamespace, config.DefaultBucketName, "")
 	assertNoError(t, e)
 
 	assertDefaultBucketDoesNotExist(t, s)
@@ -107,7 +116,7 @@ func TestUpdateGlobalDefault(t *testing.T) {
 	b := config.NewDefaultBucketConfig()
 	b.MaxTokensPerRequest = 2
 	b.Name = config.DefaultBucketName
-	e := s.(admin.Administrable).UpdateBucket(config.GlobalNamespace, b.ToProto())
+	e := s.(admin.Administrable).UpdateBucket(config.GlobalNamespace, b.ToProto(), currentVersion(s), "")
 	assertNoError(t, e)
 
 	// Now check that we hit max tokens limits.
@@ -118,7 +127,7 @@ func TestUpdateGlobalDefault(t *testing.T) {
 	}
 
 	b.MaxTokensPerRequest = 10
-	e = s.(admin.Administrable).UpdateBucket(config.GlobalNamespace, b.ToProto())
+	e = s.(admin.Administrable).UpdateBucket(config.GlobalNamespace, b.ToProto(), currentVersion(s), "")
 	assertNoError(t, e)
 
 	// Now check again
@@ -134,14 +143,14 @@ func TestAddNamespace(t *testing.T) {
 
 	n := namespaceConfig("ns", true)
 
-	e := s.(admin.Administrable).AddNamespace(n.ToProto())
+	e := s.(admin.Administrable).AddNamespace(n.ToProto(), "")
 	assertNoError(t, e)
 
 	assertBucketExists(t, s, "ns", "b")
 	assertBucketExists(t, s, "ns", "bb")
 	assertBucketExists(t, s, "ns", "bbb")
 
-	e = s.(admin.Administrable).AddNamespace(n.ToProto())
+	e = s.(admin.Administrable).AddNamespace(n.ToProto(), "")
 	assertError(t, e)
 
 	assertBucketExists(t, s, "ns", "bbbb")
@@ -155,12 +164,12 @@ func TestRemoveNamespace(t *testing.T) {
 
 	assertBucketExists(t, s, "ns", "b")
 
-	e := s.(admin.Administrable).DeleteNamespace("ns")
+	e := s.(admin.Administrable).DeleteNamespace("ns", "")
 	assertNoError(t, e)
 
 	assertBucketDoesNotExist(t, s, "ns", "b")
 
-	e = s.(admin.Administrable).DeleteNamespace("ns")
+	e = s.(admin.Administrable).DeleteNamespace("ns", "")
 	assertError(t, e)
 
 	assertBucketDoesNotExist(t, s, "ns", "b")
@@ -178,7 +187,7 @@ func TestUpdateNamespace(t *testing.T) {
 
 	// change config to not allow dynamic buckets
 	n.DynamicBucketTemplate = nil
-	e := s.(admin.Administrable).UpdateNamespace(n.ToProto())
+	e := s.(admin.Administrable).UpdateNamespace(n.ToProto(), currentVersion(s), "")
 	assertNoError(t, e)
 
 	// Existing buckets should have been removed.
@@ -203,7 +212,7 @@ func TestAddBucket(t *testing.T) {
 
 	// Add bucket
 	b.Name = "b1"
-	e := s.(admin.Administrable).AddBucket("ns", b.ToProto())
+	e := s.(admin.Administrable).AddBucket("ns", b.ToProto(), "")
 	assertNoError(t, e)
 
 	// Existing buckets should still be there
@@ -212,7 +221,7 @@ func TestAddBucket(t *testing.T) {
 	assertBucketDoesNotExist(t, s, "ns", "b2")
 
 	// Already exists
-	e = s.(admin.Administrable).AddBucket("ns", b.ToProto())
+	e = s.(admin.Administrable).AddBucket("ns", b.ToProto(), "")
 	assertError(t, e)
 }
 
@@ -225,14 +234,14 @@ func TestRemoveBucket(t *testing.T) {
 	assertBucketDoesNotExist(t, s, "ns", "b1")
 
 	// Add bucket
-	e := s.(admin.Administrable).DeleteBucket("ns", "b")
+	e := s.(admin.Administrable).DeleteBucket("ns", "b", "")
 	assertNoError(t, e)
 
 	// Existing buckets should still be there
 	assertBucketDoesNotExist(t, s, "ns", "b")
 
 	// Idempotence
-	e = s.(admin.Administrable).DeleteBucket("ns", "b")
+	e = s.(admin.Administrable).DeleteBucket("ns", "b", "")
 	assertNoError(t, e)
 }
 
@@ -252,13 +261,1134 @@ func TestUpdateBucket(t *testing.T) {
 
 	// Update bucket
 	b.MaxTokensPerRequest = 10
-	e = s.(admin.Administrable).UpdateBucket("ns", b.ToProto())
+	e = s.(admin.Administrable).UpdateBucket("ns", b.ToProto(), currentVersion(s), "")
 	assertNoError(t, e)
 
 	_, e = s.(quotaservice.QuotaService).Allow("ns", "b", 5, 0)
 	assertNoError(t, e)
 }
 
+func TestUpdateBucketVersionMismatch(t *testing.T) {
+	b := bucketConfig("b")
+	s, _ := startService(false, namespaceConfig("ns", false, b))
+	defer s.Stop()
+
+	staleVersion := currentVersion(s)
+
+	b.MaxTokensPerRequest = 10
+	e := s.(admin.Administrable).UpdateBucket("ns", b.ToProto(), staleVersion, "")
+	assertNoError(t, e)
+
+	// Based on a version that has since moved on.
+	b.MaxTokensPerRequest = 20
+	e = s.(admin.Administrable).UpdateBucket("ns", b.ToProto(), staleVersion, "")
+	assertError(t, e)
+	if _, ok := e.(config.VersionMismatchError); !ok {
+		t.Fatal("Wrong error: ", e)
+	}
+
+	// The first update should still be in effect.
+	if max := s.(admin.Administrable).Configs().Namespaces["ns"].Buckets["b"].MaxTokensPerRequest; max != 10 {
+		t.Fatalf("Expecting max tokens per request of 10. Was %v", max)
+	}
+}
+
+func TestUpdateNamespaceVersionMismatch(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", true))
+	defer s.Stop()
+
+	staleVersion := currentVersion(s)
+	e := s.(admin.Administrable).AddBucket("ns", bucketConfig("b").ToProto(), "")
+	assertNoError(t, e)
+
+	// Change config to not allow dynamic buckets, based on a version that has since moved on.
+	e = s.(admin.Administrable).UpdateNamespace(namespaceConfig("ns", false).ToProto(), staleVersion, "")
+	assertError(t, e)
+	if _, ok := e.(config.VersionMismatchError); !ok {
+		t.Fatal("Wrong error: ", e)
+	}
+
+	// Still allows dynamic buckets.
+	assertBucketExists(t, s, "ns", "dyn")
+}
+
+func TestConfigChangesFromPersister(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	p, e := config.NewDiskConfigPersister("/tmp/qs_test_reload.dat")
+	assertNoError(t, e)
+	s.ServeAdminConsole(http.NewServeMux(), "", p)
+
+	// Another node persists a newer config.
+	c := config.NewDefaultServiceConfig()
+	c.GlobalDefaultBucket = nil
+	c.Version = currentVersion(s) + 1
+	c.AddNamespace("other", namespaceConfig("other", false, bucketConfig("ob")))
+	r, e := config.Marshal(c)
+	assertNoError(t, e)
+	assertNoError(t, p.PersistAndNotify(r))
+
+	deadline := time.Now().Add(time.Second)
+	for currentVersion(s) != c.Version && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assertBucketExists(t, s, "other", "ob")
+	assertBucketDoesNotExist(t, s, "ns", "b")
+}
+
+func TestConfigHistoryAndRollback(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	dir, e := ioutil.TempDir("", "qs_test_history")
+	assertNoError(t, e)
+	defer os.RemoveAll(dir)
+
+	p, e := config.NewDiskConfigPersister(filepath.Join(dir, "configs.dat"))
+	assertNoError(t, e)
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", p)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	assertNoError(t, s.(admin.Administrable).AddBucket("ns", bucketConfig("b2").ToProto(), ""))
+	good := currentVersion(s)
+	assertNoError(t, s.(admin.Administrable).DeleteBucket("ns", "b", ""))
+	assertBucketDoesNotExist(t, s, "ns", "b")
+
+	rsp, e := http.Get(srv.URL + "/api/config/history")
+	assertNoError(t, e)
+	defer rsp.Body.Close()
+	var history []struct {
+		Version int   `json:"version"`
+		Date    int64 `json:"date"`
+	}
+	assertNoError(t, json.NewDecoder(rsp.Body).Decode(&history))
+
+	if len(history) != 2 || history[0].Version != currentVersion(s) || history[1].Version != good {
+		t.Fatalf("Unexpected history %+v", history)
+	}
+
+	if history[1].Date == 0 {
+		t.Fatal("Expecting historical configs to be dated.")
+	}
+
+	rsp, e = http.Post(fmt.Sprintf("%v/api/config/rollback/%v", srv.URL, good), "", nil)
+	assertNoError(t, e)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting rollback to succeed. Status %v", rsp.Status)
+	}
+
+	assertBucketExists(t, s, "ns", "b")
+	assertBucketExists(t, s, "ns", "b2")
+	if currentVersion(s) != good+2 {
+		t.Fatalf("Expecting rollback to be applied as version %v. Was %v", good+2, currentVersion(s))
+	}
+
+	rsp, e = http.Post(srv.URL+"/api/config/rollback/12345", "", nil)
+	assertNoError(t, e)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expecting rolling back to an unknown version to fail. Status %v", rsp.Status)
+	}
+}
+
+func TestConfigExport(t *testing.T) {
+	s, _ := startService(true, namespaceConfig("ns", true, bucketConfig("b")))
+	defer s.Stop()
+
+	dir, e := ioutil.TempDir("", "qs_test_export")
+	assertNoError(t, e)
+	defer os.RemoveAll(dir)
+
+	p, e := config.NewDiskConfigPersister(filepath.Join(dir, "configs.dat"))
+	assertNoError(t, e)
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", p)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rsp, e := http.Get(srv.URL + "/api/config/export")
+	assertNoError(t, e)
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "application/x-yaml" {
+		t.Fatalf("Unexpected response %v, content type %v", rsp.Status, rsp.Header.Get("Content-Type"))
+	}
+
+	exported := config.ReadConfig(rsp.Body)
+	live := s.(admin.Administrable).Configs()
+	if exported.Version != live.Version || exported.Namespaces["ns"].Buckets["b"] == nil ||
+		!exported.Namespaces["ns"].Buckets["b"].Equals(live.Namespaces["ns"].Buckets["b"]) {
+		t.Fatalf("Expecting exported config to match the live config. Was %+v", exported)
+	}
+}
+
+func TestConfigImport(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	dir, e := ioutil.TempDir("", "qs_test_import")
+	assertNoError(t, e)
+	defer os.RemoveAll(dir)
+
+	p, e := config.NewDiskConfigPersister(filepath.Join(dir, "configs.dat"))
+	assertNoError(t, e)
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", p)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	put := func(contentType, body string, version int) *http.Response {
+		req, e := http.NewRequest("PUT", fmt.Sprintf("%v/api/config?version=%v", srv.URL, version), strings.NewReader(body))
+		assertNoError(t, e)
+		req.Header.Set("Content-Type", contentType)
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		return rsp
+	}
+
+	v := currentVersion(s)
+	rsp := put("application/x-yaml", "namespaces:\n  imported:\n    buckets:\n      x: {}\n", v)
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting YAML import to succeed. Status %v", rsp.Status)
+	}
+
+	assertBucketExists(t, s, "imported", "x")
+	assertBucketDoesNotExist(t, s, "ns", "b")
+	if currentVersion(s) != v+1 {
+		t.Fatalf("Expecting import to be applied as version %v. Was %v", v+1, currentVersion(s))
+	}
+
+	rsp = put("application/json", `{"namespaces":[{"name":"json","buckets":[{"name":"y","fill_rate":5}]}]}`, v+1)
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting JSON import to succeed. Status %v", rsp.Status)
+	}
+	assertBucketExists(t, s, "json", "y")
+
+	if rsp = put("application/json", "{}", v); rsp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expecting import based on a stale version to fail. Status %v", rsp.Status)
+	}
+
+	invalid := "namespaces:\n  bad:\n    default_bucket: {}\n    dynamic_bucket_template: {}\n"
+	if rsp = put("application/x-yaml", invalid, v+2); rsp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Expecting invalid config to be rejected. Status %v", rsp.Status)
+	}
+	assertBucketExists(t, s, "json", "y")
+}
+
+func TestConfigValidation(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	type validation struct {
+		Valid  bool                    `json:"valid"`
+		Errors config.ValidationErrors `json:"errors"`
+	}
+
+	validate := func(body string) (int, validation) {
+		rsp, e := http.Post(srv.URL+"/api/config/validate", "application/x-yaml", strings.NewReader(body))
+		assertNoError(t, e)
+		defer rsp.Body.Close()
+
+		var v validation
+		assertNoError(t, json.NewDecoder(rsp.Body).Decode(&v))
+		return rsp.StatusCode, v
+	}
+
+	v := currentVersion(s)
+	if status, result := validate("namespaces:\n  other:\n    buckets:\n      x: {}\n"); status != http.StatusOK || !result.Valid || len(result.Errors) != 0 {
+		t.Fatalf("Expecting config to be valid. Status %v, result %+v", status, result)
+	}
+
+	status, result := validate("namespaces:\n  bad:\n    default_bucket: {}\n    dynamic_bucket_template:\n      size: -1\n")
+	expected := config.ValidationErrors{
+		{Path: "namespaces.bad", Message: "A namespace cannot have a default bucket as well as allow dynamic buckets"},
+		{Path: "namespaces.bad.dynamic_bucket_template.size", Message: "Cannot be negative"}}
+	if status != http.StatusUnprocessableEntity || result.Valid || !reflect.DeepEqual(result.Errors, expected) {
+		t.Fatalf("Expecting validation errors %v. Status %v, result %+v", expected, status, result)
+	}
+
+	if status, result = validate("namespaces: ["); status != http.StatusUnprocessableEntity || len(result.Errors) != 1 || result.Errors[0].Path != "" {
+		t.Fatalf("Expecting a parse error. Status %v, result %+v", status, result)
+	}
+
+	// Nothing is applied.
+	assertBucketExists(t, s, "ns", "b")
+	if currentVersion(s) != v {
+		t.Fatalf("Expecting version to remain %v. Was %v", v, currentVersion(s))
+	}
+}
+
+func TestBucketLimits(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	config.SetLimits(config.BucketLimits{MaxSize: 1000})
+	defer config.SetLimits(config.BucketLimits{})
+
+	req, e := http.NewRequest("PUT", srv.URL+"/api/ns/big", strings.NewReader(`{"name":"big","size":10000000000}`))
+	assertNoError(t, e)
+	rsp, e := http.DefaultClient.Do(req)
+	assertNoError(t, e)
+	body, _ := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(string(body), "namespaces.ns.buckets.big.size: 10000000000 exceeds the limit of 1000") {
+		t.Fatalf("Expecting bucket exceeding limits to be rejected. Status %v, body %s", rsp.Status, body)
+	}
+	assertBucketDoesNotExist(t, s, "ns", "big")
+
+	v := currentVersion(s)
+	big := bucketConfig("b")
+	big.Size = 5000
+	e = s.(admin.Administrable).UpdateBucket("ns", big.ToProto(), v, "")
+	if _, ok := e.(config.ValidationErrors); !ok {
+		t.Fatalf("Expecting ValidationErrors updating bucket beyond limits. Was %v", e)
+	}
+	assertBucketExists(t, s, "ns", "b")
+}
+
+func TestSignedConfigs(t *testing.T) {
+	key := []byte("secret")
+	c := config.NewDefaultServiceConfig()
+	c.GlobalDefaultBucket = nil
+	c.AddNamespace("ns", namespaceConfig("ns", false, bucketConfig("b")))
+	s := quotaservice.New(c, &quotaservice.MockBucketFactory{}, &quotaservice.MockEndpoint{})
+	s.RequireSignedConfigs(key)
+	s.Start()
+	defer s.Stop()
+
+	dir, e := ioutil.TempDir("", "qs_test_signed")
+	assertNoError(t, e)
+	defer os.RemoveAll(dir)
+
+	p, e := config.NewDiskConfigPersister(filepath.Join(dir, "configs.dat"))
+	assertNoError(t, e)
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", p)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	put := func(body, signature string) *http.Response {
+		req, e := http.NewRequest("PUT", srv.URL+"/api/ns/b2", strings.NewReader(body))
+		assertNoError(t, e)
+		if signature != "" {
+			req.Header.Set(admin.SignatureHeader, signature)
+		}
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		return rsp
+	}
+
+	body := `{"name":"b2","size":10}`
+	signer := config.NewConfigSigner(key)
+	for _, signature := range []string{"", config.NewConfigSigner([]byte("other")).SignPayload([]byte(body))} {
+		if rsp := put(body, signature); rsp.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expecting request signed with %q to be rejected. Status %v", signature, rsp.Status)
+		}
+	}
+	assertBucketDoesNotExist(t, s, "ns", "b2")
+
+	if rsp := put(body, signer.SignPayload([]byte(body))); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting signed request to succeed. Status %v", rsp.Status)
+	}
+	assertBucketExists(t, s, "ns", "b2")
+
+	// Changes are persisted signed.
+	r, e := p.ReadPersistedConfig()
+	assertNoError(t, e)
+	persisted, e := config.Unmarshal(r)
+	assertNoError(t, e)
+	assertNoError(t, signer.Verify(persisted))
+}
+
+func TestAdminAuthentication(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	s.RequireAdminAuthentication(admin.NewBasicAuthenticator(map[string]string{"admin": "secret"}))
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, path, password string) *http.Response {
+		req, e := http.NewRequest(method, srv.URL+path, nil)
+		assertNoError(t, e)
+		if password != "" {
+			req.SetBasicAuth("admin", password)
+		}
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		return rsp
+	}
+
+	for _, path := range []string{"/api/", "/api/config/history", "/api/config/export"} {
+		for _, password := range []string{"", "wrong"} {
+			rsp := do("GET", path, password)
+			if rsp.StatusCode != http.StatusUnauthorized || rsp.Header.Get("WWW-Authenticate") == "" {
+				t.Fatalf("Expecting GET %v with password %q to be unauthorized. Status %v", path, password, rsp.Status)
+			}
+		}
+	}
+
+	do("DELETE", "/api/ns/b", "")
+	assertBucketExists(t, s, "ns", "b")
+
+	if rsp := do("GET", "/api/config/export", "secret"); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting authenticated request to succeed. Status %v", rsp.Status)
+	}
+
+	do("DELETE", "/api/ns/b", "secret")
+	assertBucketDoesNotExist(t, s, "ns", "b")
+
+	// Changes are attributed to whoever made them.
+	if user := s.(admin.Administrable).Configs().User; user != "admin" {
+		t.Fatalf("Expecting change to be attributed to admin, but was %q", user)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req, e := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		assertNoError(t, e)
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		defer rsp.Body.Close()
+
+		var apiError map[string]interface{}
+		if rsp.StatusCode != http.StatusOK {
+			if rsp.Header.Get("Content-Type") != "application/json" {
+				t.Fatalf("Expecting JSON error for %v %v, got %v", method, path, rsp.Header.Get("Content-Type"))
+			}
+			assertNoError(t, json.NewDecoder(rsp.Body).Decode(&apiError))
+		}
+		return rsp.StatusCode, apiError
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"DELETE", "/api/missing/b", "", http.StatusNotFound},
+		{"DELETE", "/api/namespace/missing", "", http.StatusNotFound},
+		{"GET", "/api/missing", "", http.StatusNotFound},
+		{"PUT", "/api/ns/b", `{"name": "b"}`, http.StatusConflict},
+		{"PUT", "/api/ns/c", `{"name": `, http.StatusBadRequest},
+		{"PUT", "/api/missing/c", `{"name": "c"}`, http.StatusNotFound},
+		{"POST", "/api/ns/b", `{"name": "b"}`, http.StatusBadRequest},
+		{"POST", "/api/ns/b?version=99", `{"name": "b"}`, http.StatusConflict},
+		{"PUT", "/api/ns/c", `{"name": "c", "size": -1}`, http.StatusUnprocessableEntity},
+		{"PUT", "/api/namespace/ns", `{"name": "ns"}`, http.StatusConflict},
+		{"PATCH", "/api/ns/b", `{"size": 5}`, http.StatusBadRequest},
+		{"PATCH", "/api/ns/missing?version=1", `{"size": 5}`, http.StatusNotFound},
+		{"PATCH", "/api/ns/b?version=1", `[]`, http.StatusBadRequest},
+		{"OPTIONS", "/api/ns/b", "", http.StatusMethodNotAllowed},
+		{"GET", "/api/config/unknown", "", http.StatusNotFound},
+	} {
+		status, apiError := do(tc.method, tc.path, tc.body)
+		if status != tc.status || apiError["status"] != float64(tc.status) || apiError["message"] == "" {
+			t.Fatalf("Expecting %v %v to fail with %v. Was %v, %v", tc.method, tc.path, tc.status, status, apiError)
+		}
+	}
+
+	// Namespaces are reachable under /api/namespace/.
+	if status, _ := do("PUT", "/api/namespace/other", `{"name": "other"}`); status != http.StatusOK {
+		t.Fatalf("Expecting namespace to be added. Status %v", status)
+	}
+	if s.(admin.Administrable).Configs().Namespaces["other"] == nil {
+		t.Fatal("Expecting namespace other to exist")
+	}
+}
+
+func TestPatchBucket(t *testing.T) {
+	b := bucketConfig("b")
+	b.Size = 1234
+	b.Labels = map[string]string{"team": "quota", "tier": "1"}
+	s, _ := startService(false, namespaceConfig("ns", false, b))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	patch := func(body string) {
+		req, e := http.NewRequest("PATCH", fmt.Sprintf("%v/api/ns/b?version=%v", srv.URL, currentVersion(s)), strings.NewReader(body))
+		assertNoError(t, e)
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("Expecting patch %v to succeed. Status %v", body, rsp.Status)
+		}
+	}
+
+	patch(`{"fill_rate": 7, "wait_timeout_millis": "2s", "labels": {"tier": null, "owner": "ops"}}`)
+	patched := s.(admin.Administrable).Configs().Namespaces["ns"].Buckets["b"]
+	if patched.Size != 1234 || patched.FillRate != 7 || patched.WaitTimeoutMillis != 2000 || patched.MaxTokensPerRequest != 2 {
+		t.Fatalf("Expecting only patched settings to change. Was %+v", patched)
+	}
+
+	if !reflect.DeepEqual(patched.Labels, map[string]string{"team": "quota", "owner": "ops"}) {
+		t.Fatalf("Expecting labels to be merged. Were %v", patched.Labels)
+	}
+
+	// Buckets can't be renamed.
+	patch(`{"name": "other"}`)
+	assertBucketExists(t, s, "ns", "b")
+	assertBucketDoesNotExist(t, s, "ns", "other")
+}
+
+func TestConditionalRequests(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rsp, e := http.Get(srv.URL + "/api/ns")
+	assertNoError(t, e)
+	rsp.Body.Close()
+	tag := rsp.Header.Get("ETag")
+	if tag != fmt.Sprintf(`"%v"`, currentVersion(s)) {
+		t.Fatalf("Expecting ETag of the current version. Was %q", tag)
+	}
+
+	update := func(ifMatch string) int {
+		b := bucketConfig("b")
+		b.Size = 4321
+		body, e := json.Marshal(b)
+		assertNoError(t, e)
+		req, e := http.NewRequest("POST", srv.URL+"/api/ns/b", bytes.NewReader(body))
+		assertNoError(t, e)
+		req.Header.Set("If-Match", ifMatch)
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+
+	for _, stale := range []string{`"99"`, "W/" + tag} {
+		if status := update(stale); status != http.StatusPreconditionFailed {
+			t.Fatalf("Expecting If-Match %v to fail. Status %v", stale, status)
+		}
+	}
+
+	// Updates take their version from If-Match.
+	if status := update(tag); status != http.StatusOK {
+		t.Fatalf("Expecting If-Match %v to succeed. Status %v", tag, status)
+	}
+
+	if b := s.(admin.Administrable).Configs().Namespaces["ns"].Buckets["b"]; b.Size != 4321 {
+		t.Fatalf("Expecting bucket to be updated. Was %+v", b)
+	}
+
+	if status := update(tag); status != http.StatusPreconditionFailed {
+		t.Fatalf("Expecting If-Match of the previous version to fail. Status %v", status)
+	}
+}
+
+func TestFilteredReads(t *testing.T) {
+	s, _ := startService(true,
+		namespaceConfig("team-a", false, bucketConfig("api-1"), bucketConfig("api-2"), bucketConfig("db")),
+		namespaceConfig("team-b", false, bucketConfig("api-1")),
+		namespaceConfig("team-c", false),
+		namespaceConfig("other", false))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(path string, into interface{}) string {
+		rsp, e := http.Get(srv.URL + path)
+		assertNoError(t, e)
+		defer rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("Expecting %v to succeed. Status %v", path, rsp.Status)
+		}
+		assertNoError(t, json.NewDecoder(rsp.Body).Decode(into))
+		return rsp.Header.Get(admin.NextPageTokenHeader)
+	}
+
+	names := func(cfg *pb.ServiceConfig) []string {
+		n := make([]string, 0, len(cfg.Namespaces))
+		for _, ns := range cfg.Namespaces {
+			n = append(n, ns.Name)
+			for _, b := range ns.Buckets {
+				n = append(n, ns.Name+":"+b.Name)
+			}
+		}
+		return n
+	}
+
+	cfg := &pb.ServiceConfig{}
+	next := get("/api/?namespace_prefix=team-&bucket_prefix=api-&limit=2", cfg)
+	if expected := []string{"team-a", "team-a:api-1", "team-a:api-2", "team-b", "team-b:api-1"}; !reflect.DeepEqual(names(cfg), expected) || next != "team-b" {
+		t.Fatalf("Expecting %v and a next page. Was %v, next page %q", expected, names(cfg), next)
+	}
+	if cfg.GlobalDefaultBucket == nil {
+		t.Fatal("Expecting first page to include the global default bucket")
+	}
+
+	cfg = &pb.ServiceConfig{}
+	next = get("/api/?namespace_prefix=team-&limit=2&page_token="+next, cfg)
+	if expected := []string{"team-c"}; !reflect.DeepEqual(names(cfg), expected) || next != "" || cfg.GlobalDefaultBucket != nil {
+		t.Fatalf("Expecting only %v on the last page. Was %v, next page %q", expected, names(cfg), next)
+	}
+
+	ns := &pb.NamespaceConfig{}
+	next = get("/api/team-a?limit=2", ns)
+	if len(ns.Buckets) != 2 || ns.Buckets[1].Name != "api-2" || next != "api-2" {
+		t.Fatalf("Expecting first 2 buckets and a next page. Was %v, next page %q", ns.Buckets, next)
+	}
+
+	ns = &pb.NamespaceConfig{}
+	next = get("/api/team-a?limit=2&page_token=api-2", ns)
+	if len(ns.Buckets) != 1 || ns.Buckets[0].Name != "db" || next != "" {
+		t.Fatalf("Expecting only the last bucket. Was %v, next page %q", ns.Buckets, next)
+	}
+
+	rsp, e := http.Get(srv.URL + "/api/?limit=none")
+	assertNoError(t, e)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expecting invalid limit to be rejected. Status %v", rsp.Status)
+	}
+}
+
+func TestBucketStatus(t *testing.T) {
+	b := bucketConfig("b")
+	b.Size = 1234
+	s, _ := startService(true, namespaceConfig("ns", true, b))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	status := func(path string) (int, *admin.BucketStatus) {
+		rsp, e := http.Get(srv.URL + path)
+		assertNoError(t, e)
+		defer rsp.Body.Close()
+		st := &admin.BucketStatus{}
+		if rsp.StatusCode == http.StatusOK {
+			assertNoError(t, json.NewDecoder(rsp.Body).Decode(st))
+		}
+		return rsp.StatusCode, st
+	}
+
+	if code, st := status("/api/ns/b/status"); code != http.StatusOK || st.Tokens != 1234 || st.Namespace != "ns" || st.Name != "b" || st.Dynamic {
+		t.Fatalf("Expecting status of static bucket. Status %v, %+v", code, st)
+	}
+
+	// Dynamic buckets only have a status once created.
+	if code, _ := status("/api/ns/dyn/status"); code != http.StatusNotFound {
+		t.Fatalf("Expecting no status for uncreated dynamic bucket. Status %v", code)
+	}
+
+	if _, e := s.(quotaservice.QuotaService).Allow("ns", "dyn", 1, 0); e != nil {
+		t.Fatal(e)
+	}
+
+	if code, st := status("/api/ns/dyn/status"); code != http.StatusOK || !st.Dynamic {
+		t.Fatalf("Expecting status of dynamic bucket. Status %v, %+v", code, st)
+	}
+
+	if code, st := status("/api/" + config.GlobalNamespace + "/" + config.DefaultBucketName + "/status"); code != http.StatusOK || st.Name != config.DefaultBucketName {
+		t.Fatalf("Expecting status of global default bucket. Status %v, %+v", code, st)
+	}
+
+	rsp, e := http.Post(srv.URL+"/api/ns/b/status", "application/json", nil)
+	assertNoError(t, e)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Expecting status to be read-only. Status %v", rsp.Status)
+	}
+}
+
+func TestBucketUsage(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, e := s.(quotaservice.QuotaService).Allow("ns", "b", 2, 0); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	rsp, e := http.Get(srv.URL + admin.StatsPath + "ns/b")
+	assertNoError(t, e)
+	defer rsp.Body.Close()
+	u := &admin.BucketUsage{}
+	if rsp.StatusCode == http.StatusOK {
+		assertNoError(t, json.NewDecoder(rsp.Body).Decode(u))
+	}
+
+	if rsp.StatusCode != http.StatusOK || u.Name != "b" || u.OneMinute.Grants != 3 || u.OneHour.TokensServed != 6 {
+		t.Fatalf("Expecting usage of the bucket. Status %v, %+v", rsp.Status, u)
+	}
+
+	for _, path := range []string{"ns/missing", "ns", "ns/b/extra"} {
+		rsp, e := http.Get(srv.URL + admin.StatsPath + path)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusNotFound {
+			t.Errorf("%v: expecting a 404. Status %v", path, rsp.Status)
+		}
+	}
+}
+
+func TestTopBuckets(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("a"), bucketConfig("b"), bucketConfig("c")),
+		namespaceConfig("other", false, bucketConfig("a")))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Requests for more than the buckets' max of 2 tokens are denied.
+	qs := s.(quotaservice.QuotaService)
+	for bucket, denials := range map[string]int{"ns:a": 1, "ns:b": 3, "other:a": 3} {
+		parts := strings.Split(bucket, ":")
+		for i := 0; i < denials; i++ {
+			qs.Allow(parts[0], parts[1], 5, 0)
+		}
+	}
+	qs.Allow("ns", "c", 2, 0)
+
+	top := func(query string) (int, []string) {
+		rsp, e := http.Get(srv.URL + admin.TopPath + query)
+		assertNoError(t, e)
+		defer rsp.Body.Close()
+		var usage []*admin.BucketUsage
+		if rsp.StatusCode == http.StatusOK {
+			assertNoError(t, json.NewDecoder(rsp.Body).Decode(&usage))
+		}
+
+		names := []string{}
+		for _, u := range usage {
+			names = append(names, u.Namespace+":"+u.Name)
+		}
+		return rsp.StatusCode, names
+	}
+
+	tests := map[string][]string{
+		"":                           {"ns:b", "other:a", "ns:a"},
+		"?metric=denied&limit=2":     {"ns:b", "other:a"},
+		"?metric=tokens&window=1h":   {"ns:c"},
+		"?metric=timeouts&window=1m": {},
+		"?metric=grants&window=5m":   {"ns:c"}}
+	for query, expected := range tests {
+		if code, names := top(query); code != http.StatusOK || !reflect.DeepEqual(names, expected) {
+			t.Errorf("%q: expecting %v. Status %v, %v", query, expected, code, names)
+		}
+	}
+
+	for _, query := range []string{"?metric=latency", "?window=1d", "?limit=0", "?limit=all"} {
+		if code, _ := top(query); code != http.StatusBadRequest {
+			t.Errorf("%q: expecting a 400. Status %v", query, code)
+		}
+	}
+}
+
+func TestDynamicBuckets(t *testing.T) {
+	s, _ := startService(false, namespaceConfig("ns", true, bucketConfig("static")))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, name := range []string{"c", "a", "b"} {
+		if _, e := s.(quotaservice.QuotaService).Allow("ns", name, 1, 0); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	list := func(query string) ([]string, string) {
+		rsp, e := http.Get(srv.URL + "/api/namespace/ns/dynamic" + query)
+		assertNoError(t, e)
+		defer rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("Expecting dynamic buckets to be listed. Status %v", rsp.Status)
+		}
+
+		var statuses []*admin.BucketStatus
+		assertNoError(t, json.NewDecoder(rsp.Body).Decode(&statuses))
+		names := make([]string, len(statuses))
+		for i, st := range statuses {
+			if !st.Dynamic || st.CreatedMillis == 0 || st.LastUsedMillis < st.CreatedMillis {
+				t.Fatalf("Expecting status of a used dynamic bucket. Was %+v", st)
+			}
+			names[i] = st.Name
+		}
+		return names, rsp.Header.Get(admin.NextPageTokenHeader)
+	}
+
+	if names, next := list(""); !reflect.DeepEqual(names, []string{"a", "b", "c"}) || next != "" {
+		t.Fatalf("Expecting all dynamic buckets, sorted. Were %v, next page %q", names, next)
+	}
+
+	if names, next := list("?limit=2&page_token=a"); !reflect.DeepEqual(names, []string{"b", "c"}) || next != "" {
+		t.Fatalf("Expecting last page of dynamic buckets. Were %v, next page %q", names, next)
+	}
+
+	req, e := http.NewRequest("DELETE", srv.URL+"/api/namespace/ns/dynamic/b", nil)
+	assertNoError(t, e)
+	rsp, e := http.DefaultClient.Do(req)
+	assertNoError(t, e)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting dynamic bucket to be evicted. Status %v", rsp.Status)
+	}
+
+	if names, _ := list(""); !reflect.DeepEqual(names, []string{"a", "c"}) {
+		t.Fatalf("Expecting evicted bucket to be gone. Were %v", names)
+	}
+
+	for _, name := range []string{"b", "static"} {
+		req, e = http.NewRequest("DELETE", srv.URL+"/api/namespace/ns/dynamic/"+name, nil)
+		assertNoError(t, e)
+		rsp, e = http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expecting only live dynamic buckets to be evicted. Status %v evicting %v", rsp.Status, name)
+		}
+	}
+
+	rsp, e = http.Get(srv.URL + "/api/namespace/missing/dynamic")
+	assertNoError(t, e)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expecting unknown namespace to be reported. Status %v", rsp.Status)
+	}
+}
+
+func TestNamespaceExportImport(t *testing.T) {
+	from, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b1"), bucketConfig("b2")))
+	defer from.Stop()
+	to, _ := startService(false, namespaceConfig("other", false, bucketConfig("b")))
+	defer to.Stop()
+
+	serve := func(s quotaservice.Server) *httptest.Server {
+		mux := http.NewServeMux()
+		s.ServeAdminConsole(mux, "", nil)
+		return httptest.NewServer(mux)
+	}
+	fromSrv, toSrv := serve(from), serve(to)
+	defer fromSrv.Close()
+	defer toSrv.Close()
+
+	rsp, e := http.Get(fromSrv.URL + "/api/namespace/ns/export")
+	assertNoError(t, e)
+	exported, e := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	assertNoError(t, e)
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "application/x-yaml" {
+		t.Fatalf("Expecting namespace to be exported as YAML. Status %v", rsp.Status)
+	}
+
+	importNamespace := func(body []byte, ifMatch string) *http.Response {
+		req, e := http.NewRequest("PUT", toSrv.URL+"/api/namespace/ns/import", bytes.NewReader(body))
+		assertNoError(t, e)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		return rsp
+	}
+
+	if rsp = importNamespace(exported, ""); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting namespace to be imported. Status %v", rsp.Status)
+	}
+
+	cfgs := to.(admin.Administrable).Configs()
+	if ns := cfgs.Namespaces["ns"]; ns == nil || len(ns.Buckets) != 2 || ns.Buckets["b1"].MaxTokensPerRequest != 2 {
+		t.Fatalf("Expecting imported namespace to match the exported one. Was %+v", ns)
+	}
+
+	if cfgs.Namespaces["other"] == nil {
+		t.Fatal("Expecting other namespaces to be left as they were")
+	}
+
+	// Replacing an existing namespace needs the version it's based on.
+	changed := bytes.Replace(exported, []byte("max_tokens_per_request: 2"), []byte("max_tokens_per_request: 3"), -1)
+	if rsp = importNamespace(changed, ""); rsp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expecting import without a version to be rejected. Status %v", rsp.Status)
+	}
+
+	if rsp = importNamespace(changed, fmt.Sprintf(`"%v"`, currentVersion(to))); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting namespace to be replaced. Status %v", rsp.Status)
+	}
+
+	if b := to.(admin.Administrable).Configs().Namespaces["ns"].Buckets["b1"]; b.MaxTokensPerRequest != 3 {
+		t.Fatalf("Expecting imported changes to be applied. Was %+v", b)
+	}
+
+	if rsp = importNamespace([]byte("buckets:\n  b1:\n    size: -1\n"), ""); rsp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Expecting invalid namespace to be rejected. Status %v", rsp.Status)
+	}
+
+	rsp, e = http.Get(fromSrv.URL + "/api/namespace/missing/export")
+	assertNoError(t, e)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expecting unknown namespace to be reported. Status %v", rsp.Status)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s, _ := startService(true,
+		namespaceConfig("payments", false, bucketConfig("charge"), bucketConfig("refund")),
+		namespaceConfig("search", true, bucketConfig("charge_back")))
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	search := func(query string) ([]string, int) {
+		rsp, e := http.Get(srv.URL + "/api/search?" + query)
+		assertNoError(t, e)
+		defer rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			return nil, rsp.StatusCode
+		}
+
+		var results []struct {
+			FQN    string
+			Config *pb.BucketConfig
+		}
+		assertNoError(t, json.NewDecoder(rsp.Body).Decode(&results))
+		fqns := make([]string, len(results))
+		for i, r := range results {
+			if r.Config == nil || r.Config.Size == 0 {
+				t.Fatalf("Expecting results to include bucket configs. Was %+v", r)
+			}
+			fqns[i] = r.FQN
+		}
+		return fqns, rsp.StatusCode
+	}
+
+	if fqns, _ := search("q=charge*"); !reflect.DeepEqual(fqns, []string{"payments:charge", "search:charge_back"}) {
+		t.Fatalf("Expecting buckets matching glob. Were %v", fqns)
+	}
+
+	// Namespace names match all of their buckets.
+	if fqns, _ := search("q=pay*"); !reflect.DeepEqual(fqns, []string{"payments:charge", "payments:refund"}) {
+		t.Fatalf("Expecting buckets of matching namespace. Were %v", fqns)
+	}
+
+	if fqns, _ := search("q=" + url.QueryEscape("^search:.*DYNAMIC") + "&syntax=regex"); !reflect.DeepEqual(fqns,
+		[]string{config.FullyQualifiedName("search", config.DynamicBucketTemplateName)}) {
+		t.Fatalf("Expecting buckets matching regex. Were %v", fqns)
+	}
+
+	if fqns, _ := search("q=nothing"); fqns == nil || len(fqns) != 0 {
+		t.Fatalf("Expecting no results. Were %v", fqns)
+	}
+
+	for _, query := range []string{"", "q=[", "q=(&syntax=regex", "q=a&syntax=sql"} {
+		if _, status := search(query); status != http.StatusBadRequest {
+			t.Fatalf("Expecting invalid search %q to be rejected. Status %v", query, status)
+		}
+	}
+}
+
+func TestStagedChanges(t *testing.T) {
+	serve := func(staging admin.StagingConfig) (quotaservice.Server, *httptest.Server) {
+		s, _ := startService(false, namespaceConfig("ns", false, bucketConfig("b")))
+		s.RequireAdminAuthentication(admin.NewBasicAuthenticator(map[string]string{"alice": "a", "bob": "b"}))
+		s.StageAdminChanges(staging)
+		mux := http.NewServeMux()
+		s.ServeAdminConsole(mux, "", nil)
+		return s, httptest.NewServer(mux)
+	}
+
+	s, srv := serve(admin.StagingConfig{Required: true, RequireReview: true})
+	defer s.Stop()
+	defer srv.Close()
+	base := srv.URL
+
+	do := func(user, method, path string, body interface{}) (*http.Response, map[string]interface{}) {
+		var b []byte
+		if body != nil {
+			var e error
+			b, e = json.Marshal(body)
+			assertNoError(t, e)
+		}
+
+		req, e := http.NewRequest(method, base+path, bytes.NewReader(b))
+		assertNoError(t, e)
+		req.SetBasicAuth(user, user[:1])
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		defer rsp.Body.Close()
+
+		var doc map[string]interface{}
+		json.NewDecoder(rsp.Body).Decode(&doc)
+		return rsp, doc
+	}
+
+	if rsp, _ := do("alice", "DELETE", "/api/namespace/ns", nil); rsp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expecting unstaged change to be rejected. Status %v", rsp.Status)
+	}
+
+	change := map[string]string{"method": "DELETE", "path": "/api/namespace/ns"}
+	rsp, staged := do("alice", "POST", admin.StagedPath, change)
+	if rsp.StatusCode != http.StatusCreated || staged["id"] == nil || staged["user"] != "alice" {
+		t.Fatalf("Expecting change to be staged. Status %v, staged %v", rsp.Status, staged)
+	}
+	id := staged["id"].(string)
+
+	if _, exists := s.(admin.Administrable).Configs().Namespaces["ns"]; !exists {
+		t.Fatal("Expecting staged change not to take effect until committed")
+	}
+
+	if rsp, _ = do("alice", "POST", admin.StagedPath+"/"+id+"/commit", nil); rsp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expecting commit by the stager to be rejected. Status %v", rsp.Status)
+	}
+
+	if rsp, _ = do("bob", "POST", admin.StagedPath+"/"+id+"/commit", nil); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting reviewed change to be committed. Status %v", rsp.Status)
+	}
+
+	cfgs := s.(admin.Administrable).Configs()
+	if _, exists := cfgs.Namespaces["ns"]; exists || cfgs.User != "alice, approved by bob" {
+		t.Fatalf("Expecting committed change to take effect, attributed to both. User %q", cfgs.User)
+	}
+
+	if rsp, _ = do("bob", "POST", admin.StagedPath+"/"+id+"/commit", nil); rsp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expecting committed change to be gone. Status %v", rsp.Status)
+	}
+
+	// Aborted changes can't be committed.
+	_, staged = do("alice", "POST", admin.StagedPath, map[string]string{"method": "DELETE", "path": "/api/other"})
+	id = staged["id"].(string)
+	if rsp, _ = do("bob", "POST", admin.StagedPath+"/"+id+"/abort", nil); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting change to be aborted. Status %v", rsp.Status)
+	}
+
+	if rsp, _ = do("bob", "POST", admin.StagedPath+"/"+id+"/commit", nil); rsp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expecting aborted change to be gone. Status %v", rsp.Status)
+	}
+
+	if rsp, _ = do("alice", "POST", admin.StagedPath, map[string]string{"method": "GET", "path": "/api/ns"}); rsp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expecting only changes to be staged. Status %v", rsp.Status)
+	}
+
+	// Reads don't need staging.
+	if rsp, _ = do("alice", "GET", "/api/", nil); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting reads to be allowed. Status %v", rsp.Status)
+	}
+
+	// Nor do evictions of dynamic buckets, which don't change configs.
+	if rsp, _ = do("alice", "DELETE", "/api/namespace/ns/dynamic/b", nil); rsp.StatusCode == http.StatusForbidden {
+		t.Fatalf("Expecting evictions not to need staging. Status %v", rsp.Status)
+	}
+
+	expiring, expiringSrv := serve(admin.StagingConfig{Expiry: 10 * time.Millisecond})
+	defer expiring.Stop()
+	defer expiringSrv.Close()
+	base = expiringSrv.URL
+
+	_, staged = do("alice", "POST", admin.StagedPath, change)
+	time.Sleep(20 * time.Millisecond)
+	if rsp, _ = do("alice", "POST", admin.StagedPath+"/"+staged["id"].(string)+"/commit", nil); rsp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expecting uncommitted change to expire. Status %v", rsp.Status)
+	}
+}
+
+func TestRateLimitedChanges(t *testing.T) {
+	c := config.NewDefaultServiceConfig()
+	c.AddNamespace("ns", namespaceConfig("ns", false, bucketConfig("b1"), bucketConfig("b2")))
+	bf := &quotaservice.MockBucketFactory{}
+	s := quotaservice.New(c, bf, &quotaservice.MockEndpoint{})
+	limit := config.NewDefaultBucketConfig()
+	limit.FillRate = 1
+	s.RateLimitAdminChanges(limit)
+	s.Start()
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	remove := func(name string) *http.Response {
+		req, e := http.NewRequest("DELETE", srv.URL+"/api/ns/"+name, nil)
+		assertNoError(t, e)
+		rsp, e := http.DefaultClient.Do(req)
+		assertNoError(t, e)
+		rsp.Body.Close()
+		return rsp
+	}
+
+	if rsp := remove("b1"); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting first change to be allowed. Status %v", rsp.Status)
+	}
+
+	// The client's bucket is now out of tokens.
+	bf.SetWaitTime(config.AdminNamespace, "127.0.0.1", time.Second)
+	rsp := remove("b2")
+	if rsp.StatusCode != http.StatusTooManyRequests || rsp.Header.Get("Retry-After") != "1" {
+		t.Fatalf("Expecting change to be rate limited. Status %v, Retry-After %q", rsp.Status, rsp.Header.Get("Retry-After"))
+	}
+
+	if _, ok := s.(admin.Administrable).Configs().Namespaces["ns"].Buckets["b2"]; !ok {
+		t.Fatal("Expecting rate limited change not to be applied")
+	}
+
+	rsp, e := http.Get(srv.URL + "/api/ns")
+	assertNoError(t, e)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting reads not to be rate limited. Status %v", rsp.Status)
+	}
+}
+
 func namespaceConfig(n string, dynamic bool, b ...*config.BucketConfig) *config.NamespaceConfig {
 	ns := config.NewDefaultNamespaceConfig()
 	ns.Name = n
@@ -293,6 +1423,10 @@ func startService(withDefault bool, ns ...*config.NamespaceConfig) (quotaservice
 	return s, c
 }
 
+func currentVersion(s quotaservice.Server) int {
+	return s.(admin.Administrable).Configs().Version
+}
+
 func assertDefaultBucketExists(t *testing.T, s quotaservice.Server) {
 	assertBucketExists(t, s, "doesn't exist", "doesn't exist")
 }
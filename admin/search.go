@@ -0,0 +1,113 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos/config"
+)
+
+// searchResult is a bucket found by /api/search, with its config.
+type searchResult struct {
+	FQN       string           `json:"fqn"`
+	Namespace string           `json:"namespace"`
+	Bucket    string           `json:"bucket"`
+	Config    *pb.BucketConfig `json:"config"`
+}
+
+// searchHandler finds the buckets whose names, or whose namespaces' names, match a pattern, across
+// the whole config. Patterns are globs, as path.Match reads them, unless syntax=regex is given.
+type searchHandler struct {
+	a Administrable
+}
+
+func (s *searchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+		return
+	}
+
+	match, e := getMatcher(r)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	cfgs := s.a.Configs()
+	results := []*searchResult{}
+	add := func(namespace string, b *config.BucketConfig) {
+		if b == nil {
+			return
+		}
+
+		fqn := config.FullyQualifiedName(namespace, b.Name)
+		if match(namespace) || match(b.Name) || match(fqn) {
+			results = append(results, &searchResult{fqn, namespace, b.Name, b.ToProto()})
+		}
+	}
+
+	add(config.GlobalNamespace, cfgs.GlobalDefaultBucket)
+	for name, ns := range cfgs.Namespaces {
+		add(name, ns.DefaultBucket)
+		add(name, ns.DynamicBucketTemplate)
+		add(name, ns.Ceiling)
+		for _, b := range ns.Buckets {
+			add(name, b)
+		}
+	}
+
+	sort.Sort(searchResults(results))
+	b, e := json.Marshal(results)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	w.Header().Set("ETag", etag(cfgs.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// getMatcher reads the pattern to search for from the "q" query parameter, with its syntax from the
+// "syntax" parameter.
+func getMatcher(r *http.Request) (func(string) bool, error) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		return nil, badRequestError{errors.New("Searches need a pattern to match, in the q parameter")}
+	}
+
+	switch syntax := r.URL.Query().Get("syntax"); syntax {
+	case "", "glob":
+		if _, e := path.Match(q, ""); e != nil {
+			return nil, badRequestError{errors.New("Invalid glob " + q)}
+		}
+
+		return func(name string) bool {
+			matched, _ := path.Match(q, name)
+			return matched
+		}, nil
+	case "regex":
+		re, e := regexp.Compile(q)
+		if e != nil {
+			return nil, badRequestError{e}
+		}
+
+		return re.MatchString, nil
+	default:
+		return nil, badRequestError{errors.New("Unknown pattern syntax " + syntax)}
+	}
+}
+
+type searchResults []*searchResult
+
+func (s searchResults) Len() int           { return len(s) }
+func (s searchResults) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s searchResults) Less(i, j int) bool { return s[i].FQN < s[j].FQN }
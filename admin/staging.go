@@ -0,0 +1,288 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// StagedPath is where changes are staged, to be committed or aborted later.
+const StagedPath = "/api/staged"
+
+const defaultStageExpiry = time.Hour
+
+// StagingConfig configures two-phase changes: rather than changing configs directly, callers stage
+// the request that would make a change with POST /api/staged, which someone then commits with
+// POST /api/staged/{id}/commit, or aborts with POST /api/staged/{id}/abort. Staged changes are held
+// in memory, so must be committed via the same server they were staged on.
+type StagingConfig struct {
+	// Required rejects changes made via the REST API that weren't staged first.
+	Required bool
+	// RequireReview rejects commits by whoever staged the change, so that a second operator
+	// reviews every change before it takes effect.
+	RequireReview bool
+	// How long staged changes wait to be committed before they're dropped. Defaults to an hour.
+	Expiry time.Duration
+}
+
+// stagedChange is a request held until it's committed, when it's made as it was staged.
+type stagedChange struct {
+	ID          string `json:"id"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body,omitempty"`
+	IfMatch     string `json:"if_match,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	// Who staged the change, if the request was authenticated.
+	User          string `json:"user,omitempty"`
+	CreatedMillis int64  `json:"created_millis"`
+	ExpiresMillis int64  `json:"expires_millis"`
+}
+
+// stagingHandler serves the staging endpoints, passing other requests on to h, the REST API that
+// staged changes are made on when they're committed.
+type stagingHandler struct {
+	cfg StagingConfig
+	h   http.Handler
+
+	sync.Mutex
+	staged map[string]*stagedChange
+}
+
+func newStagingHandler(cfg *StagingConfig, h http.Handler) *stagingHandler {
+	s := &stagingHandler{h: h, staged: make(map[string]*stagedChange)}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+
+	if s.cfg.Expiry <= 0 {
+		s.cfg.Expiry = defaultStageExpiry
+	}
+
+	return s
+}
+
+func (s *stagingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == StagedPath && r.Method == "GET":
+		s.writeStaged(w)
+	case r.URL.Path == StagedPath && r.Method == "POST":
+		s.stage(w, r)
+	case strings.HasPrefix(r.URL.Path, StagedPath+"/"):
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, StagedPath+"/"), "/")
+		switch {
+		case len(parts) == 1 && r.Method == "GET":
+			c, e := s.find(parts[0])
+			if e != nil {
+				writeError(w, e)
+				return
+			}
+			writeJSON(w, http.StatusOK, c)
+		case len(parts) == 2 && parts[1] == "commit" && r.Method == "POST":
+			s.commit(w, r, parts[0])
+		case len(parts) == 2 && parts[1] == "abort" && r.Method == "POST":
+			s.abort(w, r, parts[0])
+		default:
+			writeErrorStatus(w, http.StatusNotFound, errors.New("Not handling "+r.Method+" "+r.URL.Path))
+		}
+	case s.cfg.Required && changesConfig(r.Method, r.URL.Path):
+		writeErrorStatus(w, http.StatusForbidden, errors.New("Changes must be staged via "+StagedPath))
+	default:
+		s.h.ServeHTTP(w, r)
+	}
+}
+
+// changesConfig tells whether a request may change configs. Reads, validating configs without
+// applying them, changing the log level and evicting dynamic buckets, don't.
+func changesConfig(method, path string) bool {
+	if method == "GET" || method == "HEAD" || method == "OPTIONS" {
+		return false
+	}
+
+	if method == "DELETE" && strings.HasPrefix(path, "/api/namespace/") {
+		if parts := strings.Split(strings.TrimPrefix(path, "/api/namespace/"), "/"); len(parts) == 3 && parts[1] == "dynamic" {
+			return false
+		}
+	}
+
+	return path != "/api/config/validate" && path != LogLevelPath
+}
+
+func (s *stagingHandler) stage(w http.ResponseWriter, r *http.Request) {
+	c := &stagedChange{}
+	if e := json.NewDecoder(r.Body).Decode(c); e != nil {
+		writeError(w, badRequestError{e})
+		return
+	}
+
+	c.Method = strings.ToUpper(c.Method)
+	path := strings.SplitN(c.Path, "?", 2)[0]
+	if !strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, StagedPath) || !changesConfig(c.Method, path) {
+		writeError(w, badRequestError{errors.New("Only changes to configs made via the REST API can be staged")})
+		return
+	}
+
+	id := make([]byte, 8)
+	if _, e := rand.Read(id); e != nil {
+		writeError(w, e)
+		return
+	}
+
+	now := time.Now()
+	c.ID = hex.EncodeToString(id)
+	c.User = identity(r)
+	c.CreatedMillis = now.UnixNano() / int64(time.Millisecond)
+	c.ExpiresMillis = now.Add(s.cfg.Expiry).UnixNano() / int64(time.Millisecond)
+
+	s.Lock()
+	s.expire(now)
+	s.staged[c.ID] = c
+	s.Unlock()
+
+	logging.Printf("Staged change %v: %v %v by %v", c.ID, c.Method, c.Path, c.User)
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (s *stagingHandler) writeStaged(w http.ResponseWriter) {
+	s.Lock()
+	s.expire(time.Now())
+	changes := make([]*stagedChange, 0, len(s.staged))
+	for _, c := range s.staged {
+		changes = append(changes, c)
+	}
+	s.Unlock()
+
+	sort.Sort(stagedChanges(changes))
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// commit makes a staged change, attributing it to whoever staged it as well as the committer. The
+// change is kept if it fails, such as when the config has changed since, so it can be aborted.
+func (s *stagingHandler) commit(w http.ResponseWriter, r *http.Request, id string) {
+	c, e := s.find(id)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	committer := identity(r)
+	if s.cfg.RequireReview && (committer == "" || committer == c.User) {
+		writeErrorStatus(w, http.StatusForbidden, errors.New("Staged changes must be committed by someone other than who staged them"))
+		return
+	}
+
+	req, e := http.NewRequest(c.Method, c.Path, strings.NewReader(c.Body))
+	if e != nil {
+		writeError(w, badRequestError{e})
+		return
+	}
+
+	if c.ContentType != "" {
+		req.Header.Set("Content-Type", c.ContentType)
+	}
+
+	if c.IfMatch != "" {
+		req.Header.Set("If-Match", c.IfMatch)
+	}
+
+	if c.Signature != "" {
+		req.Header.Set(SignatureHeader, c.Signature)
+	}
+
+	user := c.User
+	if committer != "" && committer != user {
+		if user == "" {
+			user = committer
+		} else {
+			user += ", approved by " + committer
+		}
+	}
+
+	// Claimed before it's made, so that concurrent commits don't both make the change.
+	s.Lock()
+	claimed := s.staged[c.ID] == c
+	delete(s.staged, c.ID)
+	s.Unlock()
+	if !claimed {
+		writeError(w, config.NotFoundError{Message: "No such staged change " + id})
+		return
+	}
+
+	logging.Printf("Committing staged change %v: %v %v by %v", c.ID, c.Method, c.Path, user)
+	rec := &statusRecorder{ResponseWriter: w}
+	s.h.ServeHTTP(rec, req.WithContext(context.WithValue(r.Context(), identityKey{}, user)))
+	if rec.status >= 300 {
+		s.Lock()
+		s.staged[c.ID] = c
+		s.Unlock()
+	}
+}
+
+func (s *stagingHandler) abort(w http.ResponseWriter, r *http.Request, id string) {
+	if _, e := s.find(id); e != nil {
+		writeError(w, e)
+		return
+	}
+
+	s.Lock()
+	delete(s.staged, id)
+	s.Unlock()
+
+	logging.Printf("Aborted staged change %v by %v", id, identity(r))
+}
+
+func (s *stagingHandler) find(id string) (*stagedChange, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.expire(time.Now())
+	c := s.staged[id]
+	if c == nil {
+		return nil, config.NotFoundError{Message: "No such staged change " + id}
+	}
+
+	return c, nil
+}
+
+// expire drops changes that weren't committed in time. Must be called with the lock held.
+func (s *stagingHandler) expire(now time.Time) {
+	nowMillis := now.UnixNano() / int64(time.Millisecond)
+	for id, c := range s.staged {
+		if c.ExpiresMillis <= nowMillis {
+			logging.Printf("Staged change %v by %v expired", id, c.User)
+			delete(s.staged, id)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, e := json.Marshal(v)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+type stagedChanges []*stagedChange
+
+func (s stagedChanges) Len() int           { return len(s) }
+func (s stagedChanges) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s stagedChanges) Less(i, j int) bool { return s[i].CreatedMillis < s[j].CreatedMillis }
@@ -0,0 +1,159 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StatsPath prefixes the paths buckets' usage stats are read from, as StatsPath/{ns}/{bucket}.
+const StatsPath = "/api/stats/"
+
+// TopPath is where the buckets with the most denials, timeouts, grants or tokens served are listed.
+// A bucket in a namespace named "top" can still be read from StatsPath/top/{bucket}.
+const TopPath = StatsPath + "top"
+
+const (
+	defaultTopLimit = 20
+	maxTopLimit     = 1000
+)
+
+// topMetrics are the counts buckets can be ranked by, by the name of the metric query parameter.
+var topMetrics = map[string]func(c UsageCounts) int64{
+	"denied":   func(c UsageCounts) int64 { return c.Denials },
+	"timeouts": func(c UsageCounts) int64 { return c.Timeouts },
+	"tokens":   func(c UsageCounts) int64 { return c.TokensServed },
+	"grants":   func(c UsageCounts) int64 { return c.Grants }}
+
+// topWindows are the windows buckets' counts can be ranked over, by the name of the window query
+// parameter.
+var topWindows = map[string]func(u *BucketUsage) UsageCounts{
+	"1m": func(u *BucketUsage) UsageCounts { return u.OneMinute },
+	"5m": func(u *BucketUsage) UsageCounts { return u.FiveMinutes },
+	"1h": func(u *BucketUsage) UsageCounts { return u.OneHour }}
+
+// UsageCounts count the requests a bucket served over a window of time.
+type UsageCounts struct {
+	// Requests granted tokens, and the tokens granted them.
+	Grants       int64 `json:"grants"`
+	TokensServed int64 `json:"tokens_served"`
+	// Requests refused, for whatever reason, of which timeouts were refused for want of tokens
+	// within their max wait time.
+	Denials  int64 `json:"denials"`
+	Timeouts int64 `json:"timeouts"`
+}
+
+// BucketUsage counts the requests a live bucket has served over rolling windows of the last
+// minute, 5 minutes and hour, since it was created.
+type BucketUsage struct {
+	Namespace   string      `json:"namespace"`
+	Name        string      `json:"name"`
+	Dynamic     bool        `json:"dynamic"`
+	OneMinute   UsageCounts `json:"1m"`
+	FiveMinutes UsageCounts `json:"5m"`
+	OneHour     UsageCounts `json:"1h"`
+}
+
+// statsHandler serves the usage stats of buckets, for capacity planning.
+type statsHandler struct {
+	a Administrable
+}
+
+func (s *statsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+		return
+	}
+
+	if r.URL.Path == TopPath {
+		s.serveTop(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, StatsPath), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		writeErrorStatus(w, http.StatusNotFound, errors.New("Not handling path "+r.URL.Path))
+		return
+	}
+
+	u, e := s.a.BucketUsage(parts[0], parts[1])
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, u)
+}
+
+// serveTop lists the buckets with the highest of a metric over a window, highest first, so that
+// on-call can see who is being throttled hardest. Buckets whose metric is 0 are left out.
+func (s *statsHandler) serveTop(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	metricName, windowName := q.Get("metric"), q.Get("window")
+	if metricName == "" {
+		metricName = "denied"
+	}
+
+	if windowName == "" {
+		windowName = "5m"
+	}
+
+	metric, window := topMetrics[metricName], topWindows[windowName]
+	if metric == nil {
+		writeError(w, badRequestError{fmt.Errorf("Unknown metric %q; expecting denied, timeouts, tokens or grants", metricName)})
+		return
+	}
+
+	if window == nil {
+		writeError(w, badRequestError{fmt.Errorf("Unknown window %q; expecting 1m, 5m or 1h", windowName)})
+		return
+	}
+
+	limit := defaultTopLimit
+	if l := q.Get("limit"); l != "" {
+		var e error
+		if limit, e = strconv.Atoi(l); e != nil || limit < 1 || limit > maxTopLimit {
+			writeError(w, badRequestError{fmt.Errorf("Limit should be between 1 and %v", maxTopLimit)})
+			return
+		}
+	}
+
+	top := rankedUsage{usage: []*BucketUsage{}, count: func(u *BucketUsage) int64 { return metric(window(u)) }}
+	for _, u := range s.a.AllBucketUsage() {
+		if top.count(u) > 0 {
+			top.usage = append(top.usage, u)
+		}
+	}
+
+	sort.Sort(top)
+	if len(top.usage) > limit {
+		top.usage = top.usage[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, top.usage)
+}
+
+// rankedUsage sorts buckets' usage by count, highest first, then by namespace and name.
+type rankedUsage struct {
+	usage []*BucketUsage
+	count func(u *BucketUsage) int64
+}
+
+func (r rankedUsage) Len() int      { return len(r.usage) }
+func (r rankedUsage) Swap(i, j int) { r.usage[i], r.usage[j] = r.usage[j], r.usage[i] }
+func (r rankedUsage) Less(i, j int) bool {
+	if ci, cj := r.count(r.usage[i]), r.count(r.usage[j]); ci != cj {
+		return ci > cj
+	}
+
+	if r.usage[i].Namespace != r.usage[j].Namespace {
+		return r.usage[i].Namespace < r.usage[j].Namespace
+	}
+	return r.usage[i].Name < r.usage[j].Name
+}
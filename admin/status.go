@@ -0,0 +1,105 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// BucketStatus is the runtime state of a live bucket, as opposed to its config.
+type BucketStatus struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Dynamic   bool   `json:"dynamic"`
+	// Tokens that can be taken straight away.
+	Tokens int64 `json:"tokens"`
+	// When tokens were last added to the bucket, in Unix millis.
+	LastFillMillis int64 `json:"last_fill_millis"`
+	// Requests currently waiting on the bucket to claim tokens.
+	Waiting int `json:"waiting"`
+	// How far into the future tokens have already been claimed, in millis.
+	DebtMillis int64 `json:"debt_millis"`
+	// When the bucket was created, and last used to claim tokens, in Unix millis.
+	CreatedMillis  int64 `json:"created_millis"`
+	LastUsedMillis int64 `json:"last_used_millis"`
+	// Tokens currently added per second, for adaptive buckets.
+	FillRate int64 `json:"fill_rate,omitempty"`
+	// Mode the bucket is in, if it isn't normal.
+	Mode string `json:"mode,omitempty"`
+}
+
+// NotImplementedError is returned when the bucket implementation in use doesn't support an
+// operation, such as reporting bucket status.
+type NotImplementedError struct {
+	Message string
+}
+
+func (e NotImplementedError) Error() string {
+	return e.Message
+}
+
+func (a *apiHandler) serveBucketStatus(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	if r.Method != "GET" {
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+		return
+	}
+
+	s, e := a.a.BucketStatus(namespace, name)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	b, e := json.Marshal(s)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func (a *apiHandler) serveDynamicBuckets(w http.ResponseWriter, r *http.Request, namespace string) {
+	if r.Method != "GET" {
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+		return
+	}
+
+	f, e := getConfigFilter(r)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	statuses, e := a.a.DynamicBuckets(namespace)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	statuses, next := f.filterStatuses(statuses)
+	b, e := json.Marshal(statuses)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	if next != "" {
+		w.Header().Set(NextPageTokenHeader, next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func (a *apiHandler) serveDynamicBucket(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	if r.Method != "DELETE" {
+		writeErrorStatus(w, http.StatusMethodNotAllowed, errors.New("Not handling method "+r.Method))
+		return
+	}
+
+	writeError(w, a.a.EvictDynamicBucket(namespace, name, identity(r)))
+}
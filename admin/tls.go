@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSConfig configures TLS for the admin HTTP server.
+type TLSConfig struct {
+	// PEM encoded certificate and private key files the server identifies itself with.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, if set, is a PEM encoded file of the CAs that client certificates must be
+	// issued by. Clients without a certificate may still read configs, but not change them.
+	ClientCAFile string
+}
+
+// ServerTLSConfig loads the certificates, returning a tls.Config for the admin HTTP server.
+func (c TLSConfig) ServerTLSConfig() (*tls.Config, error) {
+	cert, e := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if e != nil {
+		return nil, e
+	}
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile != "" {
+		pem, e := ioutil.ReadFile(c.ClientCAFile)
+		if e != nil {
+			return nil, e
+		}
+
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No certificates found in " + c.ClientCAFile)
+		}
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
+// RequireClientCertsForChanges only passes requests that may change configs on to h if they were
+// made with a verified client certificate. Requests that only read, using GET or HEAD, are always
+// passed on.
+func RequireClientCertsForChanges(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified := r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+		if r.Method != "GET" && r.Method != "HEAD" && !verified {
+			writeErrorStatus(w, http.StatusForbidden, errors.New("Client certificate required to change configs"))
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// clientCertName returns the common name of the verified client certificate the request was made
+// with, or an empty string if there isn't one.
+func clientCertName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,84 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestClientCertsRequiredForChanges(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_tls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := helpers.NewCert(t, "ca", nil, nil)
+	server, serverKey := helpers.NewCert(t, "server", ca, caKey)
+	client, clientKey := helpers.NewCert(t, "deployer", ca, caKey)
+	rogue, rogueKey := helpers.NewCert(t, "rogue", nil, nil)
+
+	cfg := TLSConfig{
+		CertFile:     helpers.WritePEM(t, dir, "server.pem", "CERTIFICATE", server.Raw),
+		KeyFile:      helpers.WriteKey(t, dir, "server.key", serverKey),
+		ClientCAFile: helpers.WritePEM(t, dir, "ca.pem", "CERTIFICATE", ca.Raw)}
+
+	tlsCfg, e := cfg.ServerTLSConfig()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	srv := httptest.NewUnstartedServer(RequireClientCertsForChanges(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(identity(r)))
+		})))
+	srv.TLS = tlsCfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	do := func(method string, cert *x509.Certificate, key *ecdsa.PrivateKey) (int, string) {
+		roots := x509.NewCertPool()
+		roots.AddCert(ca)
+		clientCfg := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			clientCfg.Certificates = []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}
+		}
+
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: clientCfg}}
+		req, _ := http.NewRequest(method, srv.URL+"/api/", nil)
+		rsp, e := c.Do(req)
+		if e != nil {
+			// Servers reject certificates they can't verify during the handshake.
+			return 0, ""
+		}
+		defer rsp.Body.Close()
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return rsp.StatusCode, string(body)
+	}
+
+	if status, _ := do("GET", nil, nil); status != http.StatusOK {
+		t.Fatalf("Expecting reads without a client certificate to succeed. Status %v", status)
+	}
+
+	if status, _ := do("DELETE", nil, nil); status != http.StatusForbidden {
+		t.Fatalf("Expecting changes without a client certificate to be forbidden. Status %v", status)
+	}
+
+	if status, _ := do("DELETE", rogue, rogueKey); status == http.StatusOK {
+		t.Fatal("Expecting changes with an untrusted client certificate to fail")
+	}
+
+	if status, body := do("DELETE", client, clientKey); status != http.StatusOK || body != "deployer" {
+		t.Fatalf("Expecting changes with a client certificate to be made by deployer. Status %v, identity %q", status, body)
+	}
+}
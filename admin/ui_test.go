@@ -0,0 +1,59 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package admin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTemplateReloading(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_ui")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "index.html")
+	write := func(contents string, modified time.Time) {
+		if e := ioutil.WriteFile(filename, []byte(contents), 0644); e != nil {
+			t.Fatal(e)
+		}
+		if e := os.Chtimes(filename, modified, modified); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	render := func(h *uiHandler) string {
+		var b bytes.Buffer
+		if e := h.templates().ExecuteTemplate(&b, "index.html", nil); e != nil {
+			t.Fatal(e)
+		}
+		return b.String()
+	}
+
+	now := time.Now()
+	write("v1", now.Add(-time.Minute))
+	dev := newUIHandler(nil, dir, true)
+	prod := newUIHandler(nil, dir, false)
+	write("v2", now)
+
+	if r := render(dev); r != "v2" {
+		t.Fatalf("Expecting changed template to be reloaded in development. Was %q", r)
+	}
+
+	if r := render(prod); r != "v1" {
+		t.Fatalf("Expecting templates to be parsed once in production. Was %q", r)
+	}
+
+	// Templates that don't parse are ignored until fixed.
+	write("{{ broken", now.Add(time.Minute))
+	if r := render(dev); r != "v2" {
+		t.Fatalf("Expecting previous template to be used. Was %q", r)
+	}
+}
@@ -4,8 +4,12 @@
 package quotaservice
 
 import (
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
 	"github.com/maniksurtani/quotaservice/config"
 	"github.com/maniksurtani/quotaservice/logging"
 )
@@ -15,8 +19,84 @@ type Server interface {
 	Start() (bool, error)
 	Stop() (bool, error)
 	SetLogger(logger logging.Logger)
+	// ServeAdminConsole serves the admin console and REST API on mux. The UI is embedded in the
+	// binary; assetsDirectory, if set, overrides it with the UI assets in that directory.
 	ServeAdminConsole(mux *http.ServeMux, assetsDirectory string, p config.ConfigPersister)
+	// ServeAdminConsoleTLS serves the admin console over HTTPS on addr, blocking like
+	// http.ListenAndServeTLS. If tlsConfig has a ClientCAFile, only clients with certificates
+	// issued by those CAs can change configs, and changes are attributed to the certificates'
+	// common names unless the request is authenticated otherwise.
+	ServeAdminConsoleTLS(addr string, tlsConfig admin.TLSConfig, assetsDirectory string, p config.ConfigPersister) error
+	// ListenAdmin serves the admin console on its own listener bound to addr, apart from the RPC
+	// endpoints, so that config changes can be firewalled away from clients claiming tokens. It
+	// returns the address listened on once bound, serving in the background until the server is
+	// stopped. HTTPS is used if tlsConfig is set, as with ServeAdminConsoleTLS.
+	ListenAdmin(addr string, tlsConfig *admin.TLSConfig, assetsDirectory string, p config.ConfigPersister) (net.Addr, error)
+	// SetListener is AddListener, for callers that don't need to know how many events were dropped.
 	SetListener(listener Listener, eventQueueBufSize int)
+	// AddListener registers a listener notified of every event the server emits, such as metrics,
+	// audit or alerting consumers, each of which can be added independently. Each listener has a
+	// buffer of its own, holding up to eventQueueBufSize events, and is notified in a goroutine of
+	// its own; events emitted while its buffer is full are dropped, and counted by the returned
+	// producer's Dropped. Must be called before the server is started.
+	AddListener(listener Listener, eventQueueBufSize int) *EventProducer
+	// SetClock sets the clock buckets' waits, idle timeouts and schedules are measured by, in place
+	// of the system clock; chiefly so that tests can use a clock.Fake. Bucket factories take their
+	// own clocks. Must be called before the server is started.
+	SetClock(c clock.Clock)
+	// OnExhausted registers a callback called with an EVENT_BUCKET_EXHAUSTED event when a bucket
+	// first refuses a request for want of tokens, and OnRecovered one called with an
+	// EVENT_BUCKET_RECOVERED event once it grants a request having refused none for 10 seconds.
+	// Like the events sent to the Listener, they can be used to page operators or scale the
+	// downstream on sustained exhaustion. Callbacks are called in their own goroutines. Must be
+	// called before the server is started.
+	OnExhausted(callback Listener)
+	OnRecovered(callback Listener)
+	// RequireSignedConfigs makes the server sign the configs it persists using HMAC-SHA256 and key,
+	// which should be shared by all servers using the same ConfigPersister. Configs read from the
+	// persister without a valid signature are ignored, and admin API requests that change configs
+	// are rejected unless they carry the signature of their body in the admin.SignatureHeader
+	// header. Must be called before the server is started.
+	RequireSignedConfigs(key []byte)
+	// RequireAdminAuthentication makes the admin console and REST API served by ServeAdminConsole
+	// reject requests that auth doesn't authenticate. Must be called before ServeAdminConsole.
+	RequireAdminAuthentication(auth admin.Authenticator)
+	// AllowAdminCORS allows browsers to call the REST API served by ServeAdminConsole from other
+	// origins, as cors allows. Must be called before ServeAdminConsole.
+	AllowAdminCORS(cors admin.CORSConfig)
+	// RateLimitAdminChanges limits how often each client may change configs via the REST API
+	// served by ServeAdminConsole, using a bucket per client created from limit, in the internal
+	// config.AdminNamespace. Clients are identified as they authenticate, or by their address.
+	// Must be called before ServeAdminConsole.
+	RateLimitAdminChanges(limit *config.BucketConfig)
+	// StageAdminChanges configures two-phase changes via the REST API served by ServeAdminConsole,
+	// such as requiring that changes are staged and reviewed before they take effect. Must be
+	// called before ServeAdminConsole.
+	StageAdminChanges(staging admin.StagingConfig)
+	// SetAdminDevelopmentMode makes the admin console reload its templates whenever they change,
+	// when served from an assets directory, rather than parsing them once. Must be called before
+	// ServeAdminConsole.
+	SetAdminDevelopmentMode(development bool)
+	// WatchConfigFile makes the server watch a config file once started, and apply any changes
+	// made to it. Buckets whose configuration is unchanged retain their state. The file is polled
+	// every pollFreq instead if its directory can't be watched.
+	WatchConfigFile(filename string, pollFreq time.Duration)
+	// SnapshotBuckets makes the server save a Snapshot of its buckets' levels to store every
+	// interval once started, and when it's stopped, so that their levels needn't be lost when it
+	// restarts. Must be called before the server is started.
+	SnapshotBuckets(store SnapshotStore, interval time.Duration)
+	// RestoreBuckets makes the server restore its buckets' levels from the latest Snapshot in store
+	// when it starts, unless the snapshot was taken more than maxAge ago, in which case buckets
+	// start out as they're configured to. Must be called before the server is started.
+	RestoreBuckets(store SnapshotStore, maxAge time.Duration)
+	// JournalGrants makes the server append a GrantRecord of every request's grant or denial to j
+	// before responding to it, such that the journal can be replayed to reconcile usage. Must be
+	// called before the server is started.
+	JournalGrants(j Journal)
+	// AddWebhook makes the server POST an Alert to the webhook when a bucket it watches reaches its
+	// utilization threshold, or has been refusing requests for longer than it allows, debouncing
+	// and retrying alerts as it's configured to. Must be called before the server is started.
+	AddWebhook(w Webhook)
 }
 
 // New creates a new quotaservice server.
@@ -27,5 +107,6 @@ func New(config *config.ServiceConfig, bucketFactory BucketFactory, rpcEndpoints
 	return &server{
 		cfgs:          config,
 		bucketFactory: bucketFactory,
-		rpcEndpoints:  rpcEndpoints}
+		rpcEndpoints:  rpcEndpoints,
+		clock:         clock.System}
 }
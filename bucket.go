@@ -5,12 +5,16 @@ package quotaservice
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
 	"github.com/maniksurtani/quotaservice/config"
 	"github.com/maniksurtani/quotaservice/logging"
 )
@@ -20,8 +24,11 @@ type bucketContainer struct {
 	cfg           *config.ServiceConfig
 	bf            BucketFactory
 	n             notifier
+	clock         clock.Clock
 	namespaces    map[string]*namespace
+	aliases       map[string]string // Namespace aliases, to the names of their namespaces
 	defaultBucket *expirableBucket
+	rateCap       *expirableBucket // Enforces the config's GlobalRateCap, if any
 	sync.RWMutex // Embedded mutex
 }
 
@@ -31,6 +38,7 @@ type namespace struct {
 	cfg           *config.NamespaceConfig
 	buckets       map[string]*expirableBucket
 	defaultBucket *expirableBucket
+	ceiling       *expirableBucket
 	sync.RWMutex // Embedded mutex
 }
 
@@ -54,13 +62,407 @@ type Bucket interface {
 	Destroy()
 }
 
+// StatusReporter is implemented by buckets that can report their runtime state, as shown by the
+// admin console.
+type StatusReporter interface {
+	// Status returns the tokens the bucket holds, when it was last filled and its debt. The rest of
+	// the status is filled in by the bucket's container.
+	Status() *admin.BucketStatus
+}
+
+// Leaser is implemented by buckets that limit concurrency rather than rate, whose tokens are leased
+// and must be returned.
+type Leaser interface {
+	// Lease takes tokens as Take does, returning the ID of the lease they're held under.
+	Lease(numTokens int64, maxWaitTime time.Duration) (leaseID string, waitTime time.Duration, success bool)
+	// Release returns the tokens held by a lease, telling whether the lease was found.
+	Release(leaseID string) bool
+}
+
+// TokenReturner is implemented by buckets that can take back tokens taken from them, such as those
+// held by reservations that are cancelled or expire.
+type TokenReturner interface {
+	// ReturnTokens puts tokens back in the bucket, up to its size.
+	ReturnTokens(numTokens int64)
+}
+
+// RateAdjuster is implemented by buckets whose fill rate can be changed while they're live, such as
+// that of adaptive buckets while their downstream is unhealthy.
+type RateAdjuster interface {
+	// SetFillRate changes the tokens added to the bucket per fill period.
+	SetFillRate(fillRate int64)
+}
+
+// DebtLimiter is implemented by buckets that lend tokens from the future and can lend fewer for a
+// request than their MaxDebtMillis allows, for callers that would rather be refused than wait long.
+type DebtLimiter interface {
+	// TakeWithMaxDebt is Take, refusing the tokens if taking them would leave the bucket more than
+	// maxDebt in debt.
+	TakeWithMaxDebt(numTokens int64, maxWaitTime, maxDebt time.Duration) (waitTime time.Duration, success bool)
+}
+
+// StateEraser is implemented by buckets that keep their state outside the bucket, such as in Redis,
+// and can erase it when the bucket is evicted.
+type StateEraser interface {
+	EraseState()
+}
+
+// StateRestorer is implemented by buckets that can be set to the level a Snapshot recorded, such as
+// when a server restarts.
+type StateRestorer interface {
+	// RestoreState sets the bucket to the level it had when the snapshot was taken, at, filling it
+	// from then on.
+	RestoreState(s *BucketSnapshot, at time.Time)
+}
+
 type expirableBucket struct {
 	Bucket
 	activityMonitor chan struct{}
+	// Counts the requests the bucket serves, for BucketUsage.
+	usage usageStats
+	// Tells the time that waits and idleness are measured by.
+	clock clock.Clock
+	// Number of requests currently in Take. Accessed atomically.
+	waiting int32
+	created time.Time
+	// When activity was last reported, in Unix nanos, and how many times. Accessed atomically.
+	lastUsedNanos int64
+	uses          int64
+	// Guards admitted and waitUntil, which count the callers waiting on the bucket's tokens against
+	// its MaxWaiters: those admitted but not yet granted tokens, and when those told to wait for
+	// their tokens can use them, in Unix nanos.
+	waitersLock sync.Mutex
+	admitted    int64
+	waitUntil   []int64
+	// Callers queued for tokens, in arrival order, if the bucket is FIFO, and whether a goroutine
+	// is serving them. Guarded by queueLock.
+	queueLock sync.Mutex
+	queue     []*waiter
+	serving   bool
+	// Fill rate of an adaptive bucket, once it has adapted, otherwise 0. Guarded by rateLock.
+	rateLock sync.Mutex
+	fillRate int64
+	// Whether the bucket is exhausted, having refused requests for want of tokens without having
+	// recovered since, and when it last refused one. Guarded by exhaustionLock.
+	exhaustionLock sync.Mutex
+	exhausted      bool
+	lastRefused    time.Time
+	// Config the bucket takes its mode and enforcement percent from, which can change without it
+	// being replaced. Holds a *config.BucketConfig.
+	enforcement atomic.Value
+}
+
+// queueRetryInterval is the most often a FIFO bucket's queue is served.
+const queueRetryInterval = 5 * time.Millisecond
+
+// waiter is a caller queued for a FIFO bucket's tokens.
+type waiter struct {
+	// Claims the caller's tokens, waiting no longer than the max wait time it's passed.
+	claim    func(time.Duration) bool
+	deadline time.Time
+	// Told whether the caller's tokens were claimed, once it leaves the queue.
+	done chan bool
+}
+
+// admit counts a caller in as waiting on the bucket's tokens, unless MaxWaiters callers already
+// are. Callers admitted must leave once they're done claiming tokens.
+func (e *expirableBucket) admit() bool {
+	max := e.Config().MaxWaiters
+	if max <= 0 {
+		return true
+	}
+
+	e.waitersLock.Lock()
+	defer e.waitersLock.Unlock()
+
+	e.expireWaiters(e.clock.Now().UnixNano())
+	if e.admitted+int64(len(e.waitUntil)) >= max {
+		return false
+	}
+
+	e.admitted++
+	return true
+}
+
+// leave counts an admitted caller out, unless it was told to wait for its tokens, in which case it
+// still counts until it can use them.
+func (e *expirableBucket) leave(waitTime time.Duration) {
+	if e.Config().MaxWaiters <= 0 {
+		return
+	}
+
+	e.waitersLock.Lock()
+	defer e.waitersLock.Unlock()
+
+	e.admitted--
+	if waitTime > 0 {
+		e.waitUntil = append(e.waitUntil, e.clock.Now().Add(waitTime).UnixNano())
+	}
+}
+
+// expireWaiters stops counting callers whose wait is over. Must be called with waitersLock held.
+func (e *expirableBucket) expireWaiters(nowNanos int64) {
+	waiting := e.waitUntil[:0]
+	for _, t := range e.waitUntil {
+		if t > nowNanos {
+			waiting = append(waiting, t)
+		}
+	}
+
+	e.waitUntil = waiting
+}
+
+// Take takes tokens from the underlying bucket, tracking the number of requests waiting on it.
+func (e *expirableBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	return e.take(context.Background(), numTokens, maxWaitTime, 0)
+}
+
+// take is Take, lending no more than maxDebt if it's positive and the underlying bucket is a
+// DebtLimiter, and giving up once ctx is done.
+func (e *expirableBucket) take(ctx context.Context, numTokens int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
+	atomic.AddInt32(&e.waiting, 1)
+	defer atomic.AddInt32(&e.waiting, -1)
+
+	dl, limitDebt := e.Bucket.(DebtLimiter)
+	limitDebt = limitDebt && maxDebt > 0
+
+	var w time.Duration
+	success := e.inTurn(ctx, maxWaitTime, func(maxWaitTime time.Duration) (success bool) {
+		if limitDebt {
+			w, success = dl.TakeWithMaxDebt(numTokens, maxWaitTime, maxDebt)
+		} else {
+			w, success = e.Bucket.Take(numTokens, maxWaitTime)
+		}
+		return
+	})
+
+	return w, success
+}
+
+// lease takes tokens as take does, leasing them if the underlying bucket is a Leaser.
+func (e *expirableBucket) lease(ctx context.Context, numTokens int64, maxWaitTime, maxDebt time.Duration) (string, time.Duration, bool) {
+	l, ok := e.Bucket.(Leaser)
+	if !ok {
+		w, success := e.take(ctx, numTokens, maxWaitTime, maxDebt)
+		return "", w, success
+	}
+
+	atomic.AddInt32(&e.waiting, 1)
+	defer atomic.AddInt32(&e.waiting, -1)
+
+	var leaseID string
+	var w time.Duration
+	success := e.inTurn(ctx, maxWaitTime, func(maxWaitTime time.Duration) (success bool) {
+		leaseID, w, success = l.Lease(numTokens, maxWaitTime)
+		return
+	})
+
+	return leaseID, w, success
+}
+
+// heldBack tells whether claiming numTokens at the given priority would take tokens the bucket holds
+// back for high priority requests. Buckets that can't report their state can't hold tokens back.
+func (e *expirableBucket) heldBack(numTokens int64, priority Priority) bool {
+	reserve := e.Config().HighPriorityReserve
+	if reserve <= 0 || priority >= PRIORITY_HIGH {
+		return false
+	}
+
+	sr, ok := e.Bucket.(StatusReporter)
+	return ok && sr.Status().Tokens-numTokens < reserve
+}
+
+// inTurn calls claim, which claims tokens waiting no longer than the max wait time it's passed. If
+// the bucket is FIFO, callers claim tokens in arrival order: those that can't be granted tokens
+// straight away queue, to be served by serveQueue until their tokens are claimed or maxWaitTime
+// has passed. Callers queue no longer than ctx's deadline, since they won't be around to use their
+// tokens after it, and leave the queue as soon as ctx is done. Callers granted tokens with a wait
+// wait after their request returns, so their deadline doesn't limit the wait they're granted.
+func (e *expirableBucket) inTurn(ctx context.Context, maxWaitTime time.Duration, claim func(time.Duration) bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if !e.Config().FIFO {
+		return claim(maxWaitTime)
+	}
+
+	e.queueLock.Lock()
+	if len(e.queue) == 0 {
+		// Nobody is ahead of the caller, so it may claim its tokens straight away.
+		if claim(maxWaitTime) {
+			e.queueLock.Unlock()
+			return true
+		}
+	}
+
+	// The server waits for queued callers, so they queue no longer than their deadline.
+	queueTime := maxWaitTime
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := deadline.Sub(time.Now()); remaining < queueTime {
+			queueTime = remaining
+		}
+	}
+
+	if len(e.queue) == 0 && queueTime <= 0 {
+		e.queueLock.Unlock()
+		return false
+	}
+
+	w := &waiter{claim: claim, deadline: e.clock.Now().Add(queueTime), done: make(chan bool, 1)}
+	e.queue = append(e.queue, w)
+	if !e.serving {
+		e.serving = true
+		go e.serveQueue()
+	}
+	e.queueLock.Unlock()
+
+	select {
+	case claimed := <-w.done:
+		return claimed
+	case <-ctx.Done():
+		return e.dequeue(w)
+	}
+}
+
+// dequeue takes a waiter whose caller has gone away out of the queue. Returns whether its tokens
+// were claimed regardless, should it have been served before it could be taken out.
+func (e *expirableBucket) dequeue(w *waiter) bool {
+	e.queueLock.Lock()
+	defer e.queueLock.Unlock()
+
+	for i, queued := range e.queue {
+		if queued == w {
+			e.queue = append(e.queue[:i], e.queue[i+1:]...)
+			return false
+		}
+	}
+
+	// Waiters are told whether their tokens were claimed once they leave the queue.
+	return <-w.done
+}
+
+// serveQueue serves the queue once per queueTick, or sooner if a caller's max wait time runs out
+// before then, so that callers waiting on the same refill are woken together rather than each
+// retrying on a timer of its own. It returns once the queue is empty.
+func (e *expirableBucket) serveQueue() {
+	next := e.clock.Now().Add(e.queueTick())
+	for {
+		e.queueLock.Lock()
+		if len(e.queue) > 0 && e.queue[0].deadline.Before(next) {
+			next = e.queue[0].deadline
+		}
+		e.queueLock.Unlock()
+
+		if d := next.Sub(e.clock.Now()); d > 0 {
+			<-e.clock.NewTimer(d).C()
+		}
+
+		e.queueLock.Lock()
+		next = e.serveWaiters()
+		if len(e.queue) == 0 {
+			e.serving = false
+			e.queueLock.Unlock()
+			return
+		}
+		e.queueLock.Unlock()
+	}
+}
+
+// serveWaiters claims tokens for as many callers at the head of the queue as it can, in arrival
+// order, and turns away any callers that have waited as long as they may. Returns when the queue
+// should next be served. Must be called with queueLock held.
+func (e *expirableBucket) serveWaiters() time.Time {
+	now := e.clock.Now()
+	for len(e.queue) > 0 {
+		w := e.queue[0]
+		remaining := w.deadline.Sub(now)
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		claimed := w.claim(remaining)
+		if !claimed && remaining > 0 {
+			break
+		}
+
+		e.queue = e.queue[1:]
+		w.done <- claimed
+	}
+
+	next := now.Add(e.queueTick())
+	waiting := e.queue[:0]
+	for _, w := range e.queue {
+		if !w.deadline.After(now) {
+			w.done <- false
+			continue
+		}
+
+		if w.deadline.Before(next) {
+			next = w.deadline
+		}
+		waiting = append(waiting, w)
+	}
+
+	e.queue = waiting
+	return next
+}
+
+// queueTick is how often the queue is served: as often as the bucket refills with a token, but no
+// more often than queueRetryInterval. Buckets that don't refill at a rate are served every
+// queueRetryInterval.
+func (e *expirableBucket) queueTick() time.Duration {
+	cfg := e.Config()
+	var tick time.Duration
+	switch cfg.Algorithm {
+	case config.SlidingWindowAlgorithm, config.ConcurrencyAlgorithm:
+	case config.GCRAAlgorithm:
+		if cfg.Adaptive == nil && cfg.EmissionInterval > 0 {
+			tick = time.Duration(cfg.EmissionInterval)
+			break
+		}
+		fallthrough
+	default:
+		if rate := e.currentFillRate(); rate > 0 {
+			tick = time.Duration(cfg.NanosPerToken(rate))
+		}
+	}
+
+	if tick < queueRetryInterval {
+		tick = queueRetryInterval
+	}
+
+	return tick
+}
+
+// status returns the bucket's runtime state, or a NotImplementedError if the underlying bucket
+// can't report it.
+func (e *expirableBucket) status(namespace, name string) (*admin.BucketStatus, error) {
+	r, ok := e.Bucket.(StatusReporter)
+	if !ok {
+		return nil, admin.NotImplementedError{Message: fmt.Sprintf("Buckets of type %T don't report their status", e.Bucket)}
+	}
+
+	s := r.Status()
+	s.Namespace = namespace
+	s.Name = name
+	s.Dynamic = e.Dynamic()
+	s.Waiting = int(atomic.LoadInt32(&e.waiting))
+	s.CreatedMillis = e.created.UnixNano() / 1e6
+	s.LastUsedMillis = atomic.LoadInt64(&e.lastUsedNanos) / 1e6
+	if e.Config().Adaptive != nil {
+		s.FillRate = e.currentFillRate()
+	}
+	if m := e.currentMode(); m != config.BucketModeNormal {
+		s.Mode = m
+	}
+	return s, nil
 }
 
 // ReportActivity indicates that an ActivityChannel is active. This method doesn't block.
 func (e *expirableBucket) ReportActivity() {
+	atomic.StoreInt64(&e.lastUsedNanos, e.clock.Now().UnixNano())
+	atomic.AddInt64(&e.uses, 1)
 	select {
 	case e.activityMonitor <- struct{}{}:
 	// reported activity
@@ -87,25 +489,267 @@ func (ns *namespace) watch(bucketName string, bucket *expirableBucket, freq time
 		return
 	}
 
-	t := time.NewTicker(freq)
+	t := bucket.clock.NewTicker(freq)
+
+	defer t.Stop()
 
 	// Wait for a tick
-	for range t.C {
+	for range t.C() {
+		// Check that the bucket is still ours (it may have been carried over to a new namespace
+		// on a config change, or evicted and replaced by a new bucket of the same name)
+		if !ns.owns(bucketName, bucket) {
+			return
+		}
+
 		// Check for activity since last run
-		if !bucket.ActivityDetected() || !ns.exists(bucketName) {
+		if !bucket.ActivityDetected() {
 			break
 		}
 	}
 
-	t.Stop()
 	ns.removeBucket(bucketName)
 }
 
-func (ns *namespace) exists(name string) bool {
+// configuredAs tells whether the bucket is configured as cfg's schedule has it now, other than its
+// mode, and so can be carried over to a config with cfg.
+func (e *expirableBucket) configuredAs(cfg *config.BucketConfig) bool {
+	return e.Config().EqualsExceptEnforcement(cfg.ScheduledAt(e.clock.Now()))
+}
+
+// rescheduledAs tells whether the bucket was configured as cfg, and only a window in cfg's schedule
+// starting or ending since has changed how it should be configured.
+func (e *expirableBucket) rescheduledAs(cfg *config.BucketConfig) bool {
+	return e.enforcementConfig().EqualsExceptEnforcement(cfg) && !e.configuredAs(cfg)
+}
+
+// carryLevel sets to, replacing from because a window in their schedule started or ended, to the
+// level of from, capped to its own size, so that schedules change how much buckets hold and how
+// fast they fill rather than topping them up or emptying them.
+func carryLevel(from, to *expirableBucket) {
+	if from == nil || to == nil {
+		return
+	}
+
+	sr, ok := from.Bucket.(StatusReporter)
+	r, restorable := to.Bucket.(StateRestorer)
+	if !ok || !restorable {
+		return
+	}
+
+	status := sr.Status()
+	r.RestoreState(&BucketSnapshot{
+		Tokens:         status.Tokens,
+		LastFillMillis: status.LastFillMillis,
+		DebtMillis:     status.DebtMillis}, to.clock.Now())
+}
+
+// enforceAs has the bucket take its mode and enforcement percent from cfg.
+func (e *expirableBucket) enforceAs(cfg *config.BucketConfig) {
+	e.enforcement.Store(cfg)
+}
+
+func (e *expirableBucket) enforcementConfig() *config.BucketConfig {
+	if cfg, _ := e.enforcement.Load().(*config.BucketConfig); cfg != nil {
+		return cfg
+	}
+
+	return e.Config()
+}
+
+// currentMode returns the mode the bucket is in, defaulting to config.BucketModeNormal.
+func (e *expirableBucket) currentMode() string {
+	if cfg := e.enforcementConfig(); cfg != nil && cfg.EffectiveMode() != "" {
+		return cfg.EffectiveMode()
+	}
+
+	return config.BucketModeNormal
+}
+
+// enforces tells whether the bucket's refusals apply to requests by caller.
+func (e *expirableBucket) enforces(caller string) bool {
+	cfg := e.enforcementConfig()
+	return cfg == nil || cfg.Enforces(caller)
+}
+
+// maxWaitTime returns how long a caller may wait for the bucket's tokens: the override requested,
+// if it's lower than the bucket's wait timeout, or the timeout otherwise.
+func (e *expirableBucket) maxWaitTime(maxWaitMillisOverride int64) time.Duration {
+	if maxWaitMillisOverride > -1 && maxWaitMillisOverride < e.Config().WaitTimeoutMillis {
+		// Use the max wait time override from the request.
+		return time.Duration(maxWaitMillisOverride) * time.Millisecond
+	}
+
+	// Fall back to the max wait time configured on the bucket.
+	return time.Duration(e.Config().WaitTimeoutMillis) * time.Millisecond
+}
+
+// limiting returns those of buckets that limit requests, leaving out any set to allow them all.
+func limiting(buckets []*expirableBucket) []*expirableBucket {
+	l := make([]*expirableBucket, 0, len(buckets))
+	for _, b := range buckets {
+		if b.currentMode() != config.BucketModeAlwaysAllow {
+			l = append(l, b)
+		}
+	}
+
+	return l
+}
+
+func (ns *namespace) owns(name string, bucket *expirableBucket) bool {
+	ns.RLock()
+	defer ns.RUnlock()
+
+	return ns.buckets[name] == bucket
+}
+
+// takeDefaultBucket removes and returns this namespace's default bucket, provided its config is
+// the same as cfg. Returns nil otherwise.
+func (ns *namespace) takeDefaultBucket(cfg *config.BucketConfig) *expirableBucket {
+	if ns == nil || ns.defaultBucket == nil || !ns.defaultBucket.configuredAs(cfg) {
+		return nil
+	}
+
+	b := ns.defaultBucket
+	ns.defaultBucket = nil
+	b.enforceAs(cfg)
+	return b
+}
+
+// takeCeiling removes and returns this namespace's ceiling, provided its config is the same as cfg.
+// Returns nil otherwise.
+func (ns *namespace) takeCeiling(cfg *config.BucketConfig) *expirableBucket {
+	if ns == nil || ns.ceiling == nil || !ns.ceiling.configuredAs(cfg) {
+		return nil
+	}
+
+	b := ns.ceiling
+	ns.ceiling = nil
+	b.enforceAs(cfg)
+	return b
+}
+
+// takeBucket removes and returns a statically defined bucket from this namespace without
+// destroying it, provided its config is the same as cfg. Returns nil otherwise.
+func (ns *namespace) takeBucket(bucketName string, cfg *config.BucketConfig) *expirableBucket {
+	if ns == nil {
+		return nil
+	}
+
+	ns.Lock()
+	defer ns.Unlock()
+
+	b := ns.buckets[bucketName]
+	if b == nil || b.Dynamic() || !b.configuredAs(cfg) {
+		return nil
+	}
+
+	delete(ns.buckets, bucketName)
+	b.enforceAs(cfg)
+	return b
+}
+
+// takeDynamicBuckets removes and returns all dynamic buckets from this namespace without destroying
+// them, provided they were created from a template the same as tpl, and tpl's schedule hasn't
+// changed them since.
+func (ns *namespace) takeDynamicBuckets(tpl *config.BucketConfig) map[string]*expirableBucket {
+	if ns == nil || !ns.cfg.DynamicBucketTemplate.EqualsExceptEnforcement(tpl) {
+		return nil
+	}
+
+	ns.Lock()
+	defer ns.Unlock()
+
+	taken := make(map[string]*expirableBucket)
+	for name, b := range ns.buckets {
+		if b.Dynamic() && b.configuredAs(tpl) {
+			b.enforceAs(tpl)
+			taken[name] = b
+			delete(ns.buckets, name)
+		}
+	}
+
+	return taken
+}
+
+// rescheduled returns the bucket serving name in this namespace, its default bucket or its ceiling,
+// provided it's being replaced only because a window in cfg's schedule started or ended, so that its
+// level can be carried over to its replacement. Returns nil otherwise.
+func (ns *namespace) rescheduled(name string, cfg *config.BucketConfig) *expirableBucket {
+	if ns == nil {
+		return nil
+	}
+
+	ns.RLock()
+	defer ns.RUnlock()
+
+	var b *expirableBucket
+	switch name {
+	case config.DefaultBucketName:
+		b = ns.defaultBucket
+	case config.CeilingBucketName:
+		b = ns.ceiling
+	default:
+		b = ns.buckets[name]
+	}
+
+	if b == nil || !b.rescheduledAs(cfg) {
+		return nil
+	}
+
+	return b
+}
+
+// rescheduledDynamicBuckets returns the dynamic buckets in this namespace being replaced only
+// because a window in tpl's schedule started or ended, by name.
+func (ns *namespace) rescheduledDynamicBuckets(tpl *config.BucketConfig) map[string]*expirableBucket {
+	if ns == nil {
+		return nil
+	}
+
 	ns.RLock()
 	defer ns.RUnlock()
 
-	return ns.buckets[name] != nil
+	rescheduled := make(map[string]*expirableBucket)
+	for name, b := range ns.buckets {
+		if b.Dynamic() && b.rescheduledAs(tpl) {
+			rescheduled[name] = b
+		}
+	}
+
+	return rescheduled
+}
+
+// adoptBucket adds a bucket taken from another namespace to this one, and watches it for activity.
+func (ns *namespace) adoptBucket(bucketName string, bucket *expirableBucket) {
+	ns.Lock()
+	defer ns.Unlock()
+
+	ns.buckets[bucketName] = bucket
+	go ns.watch(bucketName, bucket, time.Duration(bucket.Config().MaxIdleMillis)*time.Millisecond)
+}
+
+// destroy removes all buckets in this namespace.
+func (ns *namespace) destroy() {
+	if ns.defaultBucket != nil {
+		ns.defaultBucket.Destroy()
+		ns.defaultBucket = nil
+	}
+
+	if ns.ceiling != nil {
+		ns.ceiling.Destroy()
+		ns.ceiling = nil
+	}
+
+	ns.RLock()
+	names := make([]string, 0, len(ns.buckets))
+	for name := range ns.buckets {
+		names = append(names, name)
+	}
+	ns.RUnlock()
+
+	for _, name := range names {
+		ns.removeBucket(name)
+	}
 }
 
 func (ns *namespace) removeBucket(bucketName string) {
@@ -115,11 +759,57 @@ func (ns *namespace) removeBucket(bucketName string) {
 	bucket := ns.buckets[bucketName]
 	if bucket != nil {
 		delete(ns.buckets, bucketName)
-		ns.n.Emit(newBucketRemovedEvent(ns.name, bucketName, bucket.Dynamic()))
+		ns.n.Emit(newBucketRemovedEvent(ns.name, bucketName, bucket.Dynamic(), bucket.Config()))
 		bucket.Destroy()
 	}
 }
 
+// makeRoom evicts a dynamic bucket, as the namespace's eviction policy says, so that another can be
+// created, telling whether it did. Buckets with requests waiting on them aren't evicted. Must be
+// called with the namespace's lock held.
+func (ns *namespace) makeRoom() bool {
+	var before func(a, b *expirableBucket) bool
+	switch ns.cfg.Eviction {
+	case config.EvictionLRU:
+		before = func(a, b *expirableBucket) bool {
+			return atomic.LoadInt64(&a.lastUsedNanos) < atomic.LoadInt64(&b.lastUsedNanos)
+		}
+	case config.EvictionLowestUse:
+		before = func(a, b *expirableBucket) bool {
+			aUses, bUses := atomic.LoadInt64(&a.uses), atomic.LoadInt64(&b.uses)
+			return aUses < bUses || aUses == bUses && atomic.LoadInt64(&a.lastUsedNanos) < atomic.LoadInt64(&b.lastUsedNanos)
+		}
+	default:
+		return false
+	}
+
+	var victimName string
+	var victim *expirableBucket
+	for name, b := range ns.buckets {
+		if b.Dynamic() && atomic.LoadInt32(&b.waiting) == 0 && (victim == nil || before(b, victim)) {
+			victimName, victim = name, b
+		}
+	}
+
+	if victim == nil {
+		return false
+	}
+
+	logging.Printf("Evicting bucket %v:%v to make room for another dynamic bucket", ns.name, victimName)
+	ns.evict(victimName, victim)
+	return true
+}
+
+// evict removes a dynamic bucket, erasing its state. Must be called with the namespace's lock held.
+func (ns *namespace) evict(name string, b *expirableBucket) {
+	delete(ns.buckets, name)
+	ns.n.Emit(newBucketRemovedEvent(ns.name, name, true, b.Config()))
+	if e, ok := b.Bucket.(StateEraser); ok {
+		e.EraseState()
+	}
+	b.Destroy()
+}
+
 // BucketFactory creates buckets.
 type BucketFactory interface {
 	// Init initializes the bucket factory.
@@ -130,56 +820,192 @@ type BucketFactory interface {
 }
 
 // NewBucketContainer creates a new bucket container.
-func NewBucketContainer(cfg *config.ServiceConfig, bf BucketFactory, n notifier) (bc *bucketContainer) {
-	bc = &bucketContainer{cfg: cfg, bf: bf, n: n, namespaces: make(map[string]*namespace)}
+func NewBucketContainer(cfg *config.ServiceConfig, bf BucketFactory, n notifier) *bucketContainer {
+	return newBucketContainer(cfg, bf, n, clock.System)
+}
+
+// newBucketContainer creates a new bucket container whose buckets wait and idle by the given clock.
+func newBucketContainer(cfg *config.ServiceConfig, bf BucketFactory, n notifier, c clock.Clock) (bc *bucketContainer) {
+	bc = &bucketContainer{cfg: cfg, bf: bf, n: n, clock: c, namespaces: make(map[string]*namespace), aliases: make(map[string]string)}
+	bc.Lock()
+	defer bc.Unlock()
+
+	bc.createBucketsUnderLock()
+	return
+}
+
+func (bc *bucketContainer) createBucketsUnderLock() {
+	if bc.cfg.GlobalDefaultBucket != nil {
+		bc.createGlobalDefaultBucket(bc.cfg.GlobalDefaultBucket)
+	}
+
+	if rc := bc.cfg.RateCapBucket(); rc != nil {
+		bc.rateCap = bc.newExpirableBucket(config.GlobalNamespace, config.RateCapBucketName, rc, false)
+	}
+
+	for name, nsCfg := range bc.cfg.Namespaces {
+		if nsCfg.Name == "" {
+			nsCfg.Name = name
+		}
+		bc.createNamespaceUnderLock(nsCfg, nil)
+	}
+}
+
+// replaceConfig replaces the configuration backing this container. Buckets whose configuration is
+// unchanged are carried over, retaining their state. All other buckets are removed, and new ones
+// created as per the new configuration.
+func (bc *bucketContainer) replaceConfig(cfg *config.ServiceConfig) {
 	bc.Lock()
 	defer bc.Unlock()
 
+	oldDefaultBucket := bc.defaultBucket
+	oldNamespaces := bc.namespaces
+
+	bc.cfg = cfg
+	bc.defaultBucket = nil
+	bc.namespaces = make(map[string]*namespace)
+	bc.aliases = make(map[string]string)
+
 	if cfg.GlobalDefaultBucket != nil {
-		bc.createGlobalDefaultBucket(cfg.GlobalDefaultBucket)
+		if oldDefaultBucket != nil && oldDefaultBucket.configuredAs(cfg.GlobalDefaultBucket) {
+			bc.defaultBucket, oldDefaultBucket = oldDefaultBucket, nil
+			bc.defaultBucket.enforceAs(cfg.GlobalDefaultBucket)
+		} else {
+			bc.createGlobalDefaultBucket(cfg.GlobalDefaultBucket)
+			if oldDefaultBucket != nil && oldDefaultBucket.rescheduledAs(cfg.GlobalDefaultBucket) {
+				carryLevel(oldDefaultBucket, bc.defaultBucket)
+			}
+		}
+	}
+
+	if oldDefaultBucket != nil {
+		oldDefaultBucket.Destroy()
+	}
+
+	oldRateCap := bc.rateCap
+	bc.rateCap = nil
+	if rc := cfg.RateCapBucket(); rc != nil {
+		if oldRateCap != nil && oldRateCap.configuredAs(rc) {
+			bc.rateCap, oldRateCap = oldRateCap, nil
+		} else {
+			bc.rateCap = bc.newExpirableBucket(config.GlobalNamespace, config.RateCapBucketName, rc, false)
+		}
+	}
+
+	if oldRateCap != nil {
+		oldRateCap.Destroy()
 	}
 
 	for name, nsCfg := range cfg.Namespaces {
 		if nsCfg.Name == "" {
 			nsCfg.Name = name
 		}
-		bc.createNamespaceUnderLock(nsCfg)
+		bc.createNamespaceUnderLock(nsCfg, oldNamespaces[nsCfg.Name])
+	}
+
+	// Anything left over hasn't been carried over.
+	for _, ns := range oldNamespaces {
+		ns.destroy()
 	}
-	return
 }
 
+// newExpirableBucket creates a bucket configured as cfg's schedule has it now.
 func (bc *bucketContainer) newExpirableBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) *expirableBucket {
-	actualBucket := bc.bf.NewBucket(namespace, bucketName, cfg, dyn)
+	now := bc.clock.Now()
+	actualBucket := bc.bf.NewBucket(namespace, bucketName, cfg.ScheduledAt(now), dyn)
 	if actualBucket == nil {
 		return nil
 	}
 
-	return &expirableBucket{actualBucket, make(chan struct{}, 1)}
+	b := &expirableBucket{Bucket: actualBucket, activityMonitor: make(chan struct{}, 1), clock: bc.clock, created: now}
+	b.enforceAs(cfg)
+	return b
 }
 
-func (bc *bucketContainer) createNamespaceUnderLock(nsCfg *config.NamespaceConfig) error {
+// createNamespaceUnderLock creates a namespace and its buckets. Buckets with unchanged
+// configuration are carried over from previous, if it isn't nil and has the same labels.
+func (bc *bucketContainer) createNamespaceUnderLock(nsCfg *config.NamespaceConfig, previous *namespace) error {
 	if _, exists := bc.namespaces[nsCfg.Name]; exists {
-		return errors.New("Namespace " + nsCfg.Name + " already exists.")
+		return config.AlreadyExistsError{Message: "Namespace " + nsCfg.Name + " already exists."}
+	}
+
+	if _, exists := bc.aliases[nsCfg.Name]; exists {
+		return config.AlreadyExistsError{Message: "Namespace " + nsCfg.Name + " is already an alias."}
+	}
+
+	for _, alias := range nsCfg.Aliases {
+		if _, exists := bc.namespaces[alias]; exists {
+			return config.AlreadyExistsError{Message: "Alias " + alias + " is already a namespace."}
+		}
+
+		if _, exists := bc.aliases[alias]; exists {
+			return config.AlreadyExistsError{Message: "Alias " + alias + " is already an alias."}
+		}
+	}
+
+	if previous != nil && !labelsEqual(previous.cfg.Labels, nsCfg.Labels) {
+		// Buckets report their namespace's labels, so none can be carried over.
+		previous = nil
+	}
+
+	if previous != nil && (previous.cfg.Backend != nsCfg.Backend || previous.cfg.Tolerance != nsCfg.Tolerance) {
+		// Buckets are kept elsewhere now, or granted from local slices or not, so they're created
+		// afresh.
+		previous = nil
 	}
 
 	nsp := &namespace{n: bc.n, name: nsCfg.Name, cfg: nsCfg, buckets: make(map[string]*expirableBucket)}
 	if nsCfg.DefaultBucket != nil {
-		nsp.defaultBucket = bc.newExpirableBucket(nsCfg.Name, config.DefaultBucketName, nsCfg.DefaultBucket, false)
+		nsp.defaultBucket = previous.takeDefaultBucket(nsCfg.DefaultBucket)
+		if nsp.defaultBucket == nil {
+			nsp.defaultBucket = bc.newExpirableBucket(nsCfg.Name, config.DefaultBucketName, nsCfg.DefaultBucket, false)
+			carryLevel(previous.rescheduled(config.DefaultBucketName, nsCfg.DefaultBucket), nsp.defaultBucket)
+		}
+	}
+
+	if nsCfg.Ceiling != nil {
+		nsp.ceiling = previous.takeCeiling(nsCfg.Ceiling)
+		if nsp.ceiling == nil {
+			nsp.ceiling = bc.newExpirableBucket(nsCfg.Name, config.CeilingBucketName, nsCfg.Ceiling, false)
+			carryLevel(previous.rescheduled(config.CeilingBucketName, nsCfg.Ceiling), nsp.ceiling)
+		}
 	}
 
 	for bucketName, bucketCfg := range nsCfg.Buckets {
-		bc.createNewNamedBucketFromCfg(nsCfg.Name, bucketName, nsp, bucketCfg, false)
+		if bucket := previous.takeBucket(bucketName, bucketCfg); bucket != nil {
+			nsp.adoptBucket(bucketName, bucket)
+		} else {
+			bucket := bc.createNewNamedBucketFromCfg(nsCfg.Name, bucketName, nsp, bucketCfg, false)
+			carryLevel(previous.rescheduled(bucketName, bucketCfg), bucket)
+		}
+	}
+
+	if tpl := nsCfg.DynamicBucketTemplate; tpl != nil {
+		for bucketName, bucket := range previous.takeDynamicBuckets(tpl) {
+			nsp.adoptBucket(bucketName, bucket)
+		}
+
+		// Unlike those whose template changed, dynamic buckets the schedule changes are recreated
+		// straight away, so that they keep their levels.
+		for bucketName, bucket := range previous.rescheduledDynamicBuckets(tpl) {
+			carryLevel(bucket, bc.createNewNamedBucketFromCfg(nsCfg.Name, bucketName, nsp, tpl, true))
+		}
 	}
 	bc.namespaces[nsCfg.Name] = nsp
+	bc.cfg.Namespaces[nsCfg.Name] = nsCfg
+	for _, alias := range nsCfg.Aliases {
+		bc.aliases[alias] = nsCfg.Name
+	}
 
 	return nil
 }
 
 func (bc *bucketContainer) createGlobalDefaultBucket(cfg *config.BucketConfig) error {
 	if bc.defaultBucket != nil {
-		return errors.New("Global default bucket already exists")
+		return config.AlreadyExistsError{Message: "Global default bucket already exists"}
 	}
 	bc.defaultBucket = bc.newExpirableBucket(config.GlobalNamespace, config.DefaultBucketName, cfg, false)
+	bc.cfg.GlobalDefaultBucket = cfg
 	return nil
 }
 
@@ -188,10 +1014,14 @@ func (bc *bucketContainer) createGlobalDefaultBucket(cfg *config.BucketConfig) e
 // named bucket doesn't exist, it will either use a namespace-scoped default bucket if available, or
 // a dynamic bucket is created if enabled (and space for more dynamic buckets is available). If all
 // fails, this function returns nil. This function is thread-safe, and may lazily create dynamic
-// buckets or re-create statically defined buckets that have been invalidated.
+// buckets or re-create statically defined buckets that have been invalidated. Namespaces may be
+// referred to by their aliases.
 func (bc *bucketContainer) FindBucket(namespace string, bucketName string) (*expirableBucket, error) {
 	bc.RLock()
 	ns := bc.namespaces[namespace]
+	if ns == nil {
+		ns = bc.namespaces[bc.aliases[namespace]]
+	}
 	bc.RUnlock()
 	var bucket *expirableBucket
 	var err error
@@ -214,7 +1044,7 @@ func (bc *bucketContainer) FindBucket(namespace string, bucketName string) (*exp
 				// need to check if an instance has been created concurrently.
 				bucket = ns.buckets[bucketName]
 				if bucket == nil {
-					bucket = bc.createNewNamedBucket(namespace, bucketName, ns)
+					bucket = bc.createNewNamedBucket(ns.name, bucketName, ns)
 					if bucket == nil {
 						err = errors.New("Cannot create dynamic bucket")
 					}
@@ -241,7 +1071,7 @@ func (bc *bucketContainer) createNewNamedBucket(namespace, bucketName string, ns
 	if bCfg == nil {
 		// Dynamic.
 		numDynamicBuckets := bc.countDynamicBuckets(namespace)
-		if numDynamicBuckets >= ns.cfg.MaxDynamicBuckets && ns.cfg.MaxDynamicBuckets > 0 {
+		if numDynamicBuckets >= ns.cfg.MaxDynamicBuckets && ns.cfg.MaxDynamicBuckets > 0 && !ns.makeRoom() {
 			logging.Printf("Bucket %v:%v numDynamicBuckets=%v maxDynamicBuckets=%v. Not creating more dynamic buckets.",
 				namespace, bucketName, numDynamicBuckets, ns.cfg.MaxDynamicBuckets)
 			return nil
@@ -265,7 +1095,7 @@ func (bc *bucketContainer) countDynamicBuckets(namespace string) int {
 }
 
 func (bc *bucketContainer) createNewNamedBucketFromCfg(namespace, bucketName string, ns *namespace, bCfg *config.BucketConfig, dyn bool) *expirableBucket {
-	bc.n.Emit(newBucketCreatedEvent(namespace, bucketName, dyn))
+	bc.n.Emit(newBucketCreatedEvent(namespace, bucketName, dyn, bCfg))
 	bucket := bc.newExpirableBucket(namespace, bucketName, bCfg, dyn)
 	ns.buckets[bucketName] = bucket
 	bucket.ReportActivity()
@@ -276,6 +1106,208 @@ func (bc *bucketContainer) createNewNamedBucketFromCfg(namespace, bucketName str
 	return bucket
 }
 
+// liveBucket returns the bucket currently serving a name in a namespace, or nil if there isn't one.
+// Unlike FindBucket, it neither falls back to default buckets nor creates dynamic buckets.
+func (bc *bucketContainer) liveBucket(namespace, name string) *expirableBucket {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	if namespace == config.GlobalNamespace {
+		switch name {
+		case config.DefaultBucketName:
+			return bc.defaultBucket
+		case config.RateCapBucketName:
+			return bc.rateCap
+		}
+		return nil
+	}
+
+	ns := bc.namespaces[namespace]
+	if ns == nil {
+		ns = bc.namespaces[bc.aliases[namespace]]
+	}
+
+	if ns == nil {
+		return nil
+	}
+
+	switch name {
+	case config.DefaultBucketName:
+		return ns.defaultBucket
+	case config.CeilingBucketName:
+		return ns.ceiling
+	}
+
+	ns.RLock()
+	defer ns.RUnlock()
+	return ns.buckets[name]
+}
+
+// peekBucket returns the bucket FindBucket would serve a name in a namespace with, without creating
+// dynamic buckets, making room for them or reporting activity, so that it can be looked at without
+// changing what's served. Dynamic buckets that don't exist yet are returned as stand-ins for the
+// full bucket their namespace's template would create. Returns nil if there's no such bucket.
+func (bc *bucketContainer) peekBucket(namespace, name string) *expirableBucket {
+	if b := bc.liveBucket(namespace, name); b != nil {
+		return b
+	}
+
+	bc.RLock()
+	ns := bc.namespaces[namespace]
+	if ns == nil {
+		ns = bc.namespaces[bc.aliases[namespace]]
+	}
+	defaultBucket := bc.defaultBucket
+	bc.RUnlock()
+
+	if ns == nil {
+		return defaultBucket
+	}
+
+	if tmpl := ns.cfg.DynamicBucketTemplate; tmpl != nil {
+		now := bc.clock.Now()
+		b := &expirableBucket{Bucket: &templateBucket{tmpl.ScheduledAt(now)}, clock: bc.clock, created: now}
+		b.enforceAs(tmpl)
+		return b
+	}
+
+	return ns.defaultBucket
+}
+
+// templateBucket stands in for a dynamic bucket that doesn't exist yet, holding as many tokens as
+// it would be created with. It's never served from.
+type templateBucket struct {
+	cfg *config.BucketConfig
+}
+
+func (b *templateBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	return 0, false
+}
+
+func (b *templateBucket) Status() *admin.BucketStatus {
+	return &admin.BucketStatus{Tokens: b.cfg.Size}
+}
+
+func (b *templateBucket) Config() *config.BucketConfig { return b.cfg }
+func (b *templateBucket) Dynamic() bool                { return true }
+func (b *templateBucket) Destroy()                     {}
+
+// parents returns the buckets that tokens taken from b, found in namespace, must also be taken
+// from, nearest first, ending with the global rate cap, if any. Parents that no longer exist, and
+// any above them, are skipped.
+func (bc *bucketContainer) parents(namespace string, b *expirableBucket) []*expirableBucket {
+	var parents []*expirableBucket
+	seen := make(map[string]bool)
+	for name := b.Config().Parent; name != "" && !seen[name]; {
+		seen[name] = true
+		p := bc.parent(namespace, name)
+		if p == nil {
+			logging.Printf("Parent %v of bucket %v:%v doesn't exist.", name, namespace, b.Config().Name)
+			break
+		}
+
+		parents = append(parents, p)
+		name = p.Config().Parent
+	}
+
+	if !seen[config.CeilingBucketName] && b.Config().Name != config.CeilingBucketName && bc.pooled(namespace) {
+		if c := bc.liveBucket(namespace, config.CeilingBucketName); c != nil {
+			parents = append(parents, c)
+		}
+	}
+
+	// Every bucket is bounded by the global rate cap, last of all.
+	if rc := bc.liveBucket(config.GlobalNamespace, config.RateCapBucketName); rc != nil && rc != b {
+		parents = append(parents, rc)
+	}
+
+	return parents
+}
+
+// pooled tells whether all of a namespace's buckets draw from its ceiling.
+func (bc *bucketContainer) pooled(namespace string) bool {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	ns := bc.namespaces[namespace]
+	if ns == nil {
+		ns = bc.namespaces[bc.aliases[namespace]]
+	}
+
+	return ns != nil && ns.cfg.Pooled
+}
+
+// parent returns the ceiling or statically defined bucket of a namespace, re-creating buckets that
+// have been invalidated.
+func (bc *bucketContainer) parent(namespace, name string) *expirableBucket {
+	if name == config.CeilingBucketName {
+		return bc.liveBucket(namespace, name)
+	}
+
+	bc.RLock()
+	ns := bc.namespaces[namespace]
+	if ns == nil {
+		ns = bc.namespaces[bc.aliases[namespace]]
+	}
+	bc.RUnlock()
+
+	if ns == nil || ns.cfg.Buckets[name] == nil {
+		return nil
+	}
+
+	b, _ := bc.FindBucket(ns.name, name)
+	return b
+}
+
+// dynamicBuckets returns the live dynamic buckets of the namespace, or the one it's an alias of,
+// by name.
+func (bc *bucketContainer) dynamicBuckets(namespace string) (map[string]*expirableBucket, error) {
+	bc.RLock()
+	ns := bc.namespaces[namespace]
+	if ns == nil {
+		ns = bc.namespaces[bc.aliases[namespace]]
+	}
+	bc.RUnlock()
+	if ns == nil {
+		return nil, config.NotFoundError{Message: "No such namespace " + namespace}
+	}
+
+	ns.RLock()
+	defer ns.RUnlock()
+	dynamic := make(map[string]*expirableBucket)
+	for name, b := range ns.buckets {
+		if b.Dynamic() {
+			dynamic[name] = b
+		}
+	}
+
+	return dynamic, nil
+}
+
+// evictDynamicBucket removes a live dynamic bucket, of a namespace or the one it's an alias of,
+// erasing its state, so a new bucket is created from the template if it is used again.
+func (bc *bucketContainer) evictDynamicBucket(namespace, name string) error {
+	bc.RLock()
+	ns := bc.namespaces[namespace]
+	if ns == nil {
+		ns = bc.namespaces[bc.aliases[namespace]]
+	}
+	bc.RUnlock()
+	if ns == nil {
+		return config.NotFoundError{Message: "No such namespace " + namespace}
+	}
+
+	ns.Lock()
+	defer ns.Unlock()
+	b := ns.buckets[name]
+	if b == nil || !b.Dynamic() {
+		return config.NotFoundError{Message: "No live dynamic bucket " + config.FullyQualifiedName(namespace, name)}
+	}
+
+	ns.evict(name, b)
+	return nil
+}
+
 func (bc *bucketContainer) NamespaceExists(namespace string) bool {
 	bc.RLock()
 	defer bc.RUnlock()
@@ -320,11 +1352,12 @@ func (bc *bucketContainer) deleteBucket(namespace, name string) error {
 					bc.defaultBucket.Destroy()
 					bc.defaultBucket = nil
 				}
+				bc.cfg.GlobalDefaultBucket = nil
 			} else {
-				return errors.New("No such bucket " + name + " on global namespace.")
+				return config.NotFoundError{Message: "No such bucket " + name + " on global namespace."}
 			}
 		} else {
-			return errors.New("No such namespace " + namespace + ".")
+			return config.NotFoundError{Message: "No such namespace " + namespace + "."}
 		}
 	}
 
@@ -337,11 +1370,19 @@ func (bc *bucketContainer) deleteNamespace(n string) error {
 
 	nsp := bc.namespaces[n]
 	if nsp == nil {
-		return errors.New("No such namespace " + n)
+		return config.NotFoundError{Message: "No such namespace " + n}
 	}
 
 	delete(bc.namespaces, n)
+	delete(bc.cfg.Namespaces, n)
+	for _, alias := range nsp.cfg.Aliases {
+		delete(bc.aliases, alias)
+	}
 	bc.deleteBucket(n, config.DefaultBucketName)
+	if nsp.ceiling != nil {
+		nsp.ceiling.Destroy()
+		nsp.ceiling = nil
+	}
 	for b, _ := range nsp.buckets {
 		bc.deleteBucket(n, b)
 	}
@@ -353,7 +1394,7 @@ func (bc *bucketContainer) createNamespace(nsCfg *config.NamespaceConfig) error
 	bc.Lock()
 	defer bc.Unlock()
 
-	return bc.createNamespaceUnderLock(nsCfg)
+	return bc.createNamespaceUnderLock(nsCfg, nil)
 }
 
 func (bc *bucketContainer) String() string {
@@ -378,6 +1419,10 @@ func (bc *bucketContainer) String() string {
 			buffer.WriteString("   + Default present\n")
 		}
 
+		if ns.ceiling != nil {
+			buffer.WriteString("   + Ceiling present\n")
+		}
+
 		// Sort buckets
 		sortedBuckets := make([]string, len(ns.buckets))
 		j := 0
@@ -396,3 +1441,17 @@ func (bc *bucketContainer) String() string {
 
 	return buffer.String()
 }
+
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+
+	return true
+}
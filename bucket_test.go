@@ -5,9 +5,14 @@
 package quotaservice
 
 import (
+	"context"
+
+	"github.com/maniksurtani/quotaservice/clock"
 	"github.com/maniksurtani/quotaservice/config"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 var cfg = func() *config.ServiceConfig {
@@ -161,3 +166,389 @@ func TestDelete(t *testing.T) {
 		t.Fatal("Namespace y should not exist")
 	}
 }
+
+func TestNamespaceAliases(t *testing.T) {
+	c := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+	ns.AddBucket("a", config.NewDefaultBucketConfig())
+	ns.Aliases = []string{"old", "legacy"}
+	c.AddNamespace("new", ns)
+	container, _, _ := NewBucketContainerWithMocks(c)
+
+	a, _ := container.FindBucket("new", "a")
+	for _, alias := range ns.Aliases {
+		if b, _ := container.FindBucket(alias, "a"); b == nil || b != a {
+			t.Fatalf("Expecting alias %v to resolve to namespace new.", alias)
+		}
+	}
+
+	// Dynamic buckets created via an alias belong to the namespace.
+	d, _ := container.FindBucket("old", "dyn")
+	if d == nil || !container.Exists("new", "dyn") {
+		t.Fatal("Expecting dynamic bucket to be created in namespace new.")
+	}
+
+	if dynamic, e := container.dynamicBuckets("legacy"); e != nil || dynamic["dyn"] != d {
+		t.Fatalf("Expecting dynamic buckets to be listed via an alias. Listed %v, error %v", dynamic, e)
+	}
+
+	if e := container.evictDynamicBucket("old", "dyn"); e != nil || container.Exists("new", "dyn") {
+		t.Fatalf("Expecting dynamic buckets to be evicted via an alias. Error %v", e)
+	}
+
+	other := config.NewDefaultNamespaceConfig()
+	other.Name = "other"
+	other.Aliases = []string{"legacy"}
+	if container.createNamespace(other) == nil {
+		t.Fatal("Expecting namespace reusing an alias to be rejected.")
+	}
+
+	other = config.NewDefaultNamespaceConfig()
+	other.Name = "old"
+	if container.createNamespace(other) == nil {
+		t.Fatal("Expecting namespace named after an alias to be rejected.")
+	}
+
+	container.deleteNamespace("new")
+	if b, _ := container.FindBucket("old", "a"); b != container.defaultBucket {
+		t.Fatal("Expecting aliases to be removed with their namespace.")
+	}
+}
+
+func TestEvictDynamicBucket(t *testing.T) {
+	c := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+	ns.DynamicBucketTemplate.MaxIdleMillis = 50
+	ns.AddBucket("static", config.NewDefaultBucketConfig())
+	c.AddNamespace("n", ns)
+	bc, _, _ := NewBucketContainerWithMocks(c)
+
+	evicted, _ := bc.FindBucket("n", "d")
+	if e := bc.evictDynamicBucket("n", "d"); e != nil {
+		t.Fatal(e)
+	}
+
+	if bc.Exists("n", "d") {
+		t.Fatal("n:d should have been evicted")
+	}
+
+	if _, ok := bc.evictDynamicBucket("n", "static").(config.NotFoundError); !ok {
+		t.Fatal("Static buckets shouldn't be evicted")
+	}
+
+	// The evicted bucket's idle timer mustn't remove the bucket replacing it.
+	replacement, _ := bc.FindBucket("n", "d")
+	if replacement == evicted {
+		t.Fatal("Expecting a new bucket to replace the evicted one")
+	}
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if b, _ := bc.FindBucket("n", "d"); b != replacement {
+			t.Fatal("Replacement bucket should still be in use")
+		}
+	}
+}
+
+func TestIdleDynamicBucket(t *testing.T) {
+	c := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+	ns.DynamicBucketTemplate.MaxIdleMillis = 1000
+	c.AddNamespace("n", ns)
+	now := clock.NewFake(time.Unix(100, 0))
+	bc := newBucketContainer(c, &MockBucketFactory{}, &MockEmitter{}, now)
+
+	b, _ := bc.FindBucket("n", "d")
+	b.ReportActivity()
+	if s, _ := b.status("n", "d"); s.LastUsedMillis != 100000 || s.CreatedMillis != 100000 {
+		t.Fatalf("Expecting activity to be timed by the clock. Status %+v", s)
+	}
+
+	// However long the bucket has existed, it idles out by the clock.
+	for i := 0; bc.Exists("n", "d") && i < 1000; i++ {
+		now.Advance(time.Second)
+		time.Sleep(time.Millisecond)
+	}
+
+	if bc.Exists("n", "d") {
+		t.Fatal("Expecting the idle bucket to be removed")
+	}
+}
+
+// slotBucket grants requests only if it holds enough tokens, never making callers wait.
+type slotBucket struct {
+	MockBucket
+	sync.Mutex
+	tokens int64
+}
+
+func (b *slotBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	if numTokens > b.tokens {
+		return 0, false
+	}
+
+	b.tokens -= numTokens
+	return 0, true
+}
+
+func (b *slotBucket) add(numTokens int64) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.tokens += numTokens
+}
+
+func (e *expirableBucket) queued() int {
+	e.queueLock.Lock()
+	defer e.queueLock.Unlock()
+
+	return len(e.queue)
+}
+
+func TestFIFO(t *testing.T) {
+	bCfg := config.NewDefaultBucketConfig()
+	bCfg.FIFO = true
+	b := &slotBucket{MockBucket: MockBucket{cfg: bCfg}}
+	e := &expirableBucket{Bucket: b, clock: clock.System}
+
+	granted := make(chan int64, 2)
+	take := func(n int64) {
+		if _, ok := e.Take(n, 5*time.Second); ok {
+			granted <- n
+		} else {
+			granted <- -n
+		}
+	}
+
+	awaitQueue := func(n int) {
+		for i := 0; e.queued() != n && i < 1000; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		if e.queued() != n {
+			t.Fatalf("Expecting %v callers queued, was %v", n, e.queued())
+		}
+	}
+
+	go take(5)
+	awaitQueue(1)
+	go take(1)
+	awaitQueue(2)
+
+	// Enough for the later, smaller request, which must not overtake the earlier one.
+	b.add(1)
+	time.Sleep(5 * queueRetryInterval)
+	if len(granted) > 0 {
+		t.Fatalf("Expecting the earlier request to be served first, was %v", <-granted)
+	}
+
+	b.add(4)
+	if n := <-granted; n != 5 {
+		t.Fatalf("Expecting the earlier request to be served first, was %v", n)
+	}
+
+	b.add(1)
+	if n := <-granted; n != 1 {
+		t.Fatalf("Expecting the later request to be served next, was %v", n)
+	}
+
+	if e.queued() != 0 {
+		t.Fatalf("Expecting an empty queue, was %v", e.queued())
+	}
+}
+
+func TestFIFOTimeout(t *testing.T) {
+	bCfg := config.NewDefaultBucketConfig()
+	bCfg.FIFO = true
+	b := &slotBucket{MockBucket: MockBucket{cfg: bCfg}}
+	e := &expirableBucket{Bucket: b, clock: clock.System}
+
+	done := make(chan bool)
+	go func() {
+		_, ok := e.Take(1, 5*time.Second)
+		done <- ok
+	}()
+
+	for i := 0; e.queued() != 1 && i < 1000; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Callers queued behind the head give up once their max wait time has passed.
+	if _, ok := e.Take(1, 10*time.Millisecond); ok {
+		t.Fatal("Expecting queued callers to time out")
+	}
+
+	if e.queued() != 1 {
+		t.Fatalf("Expecting callers that time out to leave the queue, was %v", e.queued())
+	}
+
+	b.add(1)
+	if !<-done {
+		t.Fatal("Expecting the head of the queue to be served")
+	}
+}
+
+func TestFIFOCancel(t *testing.T) {
+	bCfg := config.NewDefaultBucketConfig()
+	bCfg.FIFO = true
+	b := &slotBucket{MockBucket: MockBucket{cfg: bCfg}}
+	e := &expirableBucket{Bucket: b, clock: clock.System}
+
+	done := make(chan bool)
+	go func() {
+		_, ok := e.Take(1, 5*time.Second)
+		done <- ok
+	}()
+
+	for i := 0; e.queued() != 1 && i < 1000; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Callers whose request is cancelled leave the queue straight away.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan bool)
+	go func() {
+		_, ok := e.take(ctx, 1, 5*time.Second, 0)
+		cancelled <- ok
+	}()
+
+	for i := 0; e.queued() != 2 && i < 1000; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case ok := <-cancelled:
+		if ok {
+			t.Fatal("Expecting cancelled callers not to be granted tokens")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expecting cancelled callers to give up straight away")
+	}
+
+	if e.queued() != 1 {
+		t.Fatalf("Expecting cancelled callers to leave the queue, was %v", e.queued())
+	}
+
+	// Callers wait no longer than their deadline, even if they may wait longer.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, ok := e.take(ctx, 1, 5*time.Second, 0); ok || time.Since(start) > time.Second {
+		t.Fatalf("Expecting callers to give up at their deadline. Granted %v after %v", ok, time.Since(start))
+	}
+
+	if _, ok := e.take(ctx, 1, 5*time.Second, 0); ok {
+		t.Fatal("Expecting callers past their deadline not to be granted tokens")
+	}
+
+	b.add(1)
+	if !<-done {
+		t.Fatal("Expecting the head of the queue to be served")
+	}
+}
+
+func TestDeadlineDoesntLimitLentTokens(t *testing.T) {
+	for _, fifo := range []bool{false, true} {
+		bCfg := config.NewDefaultBucketConfig()
+		bCfg.FIFO = fifo
+		e := &expirableBucket{Bucket: &MockBucket{cfg: bCfg, WaitTime: time.Second}, clock: clock.System}
+
+		// Callers wait for lent tokens after their request returns, so their deadline doesn't matter.
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		w, ok := e.take(ctx, 1, 5*time.Second, 0)
+		cancel()
+		if !ok || w != time.Second {
+			t.Fatalf("Expecting tokens to be lent past the caller's deadline. FIFO %v, granted %v with a wait of %v", fifo, ok, w)
+		}
+	}
+}
+
+func TestFIFOServedPerTick(t *testing.T) {
+	bCfg := config.NewDefaultBucketConfig()
+	bCfg.FIFO = true
+	b := &slotBucket{MockBucket: MockBucket{cfg: bCfg}}
+	now := clock.NewFake(time.Unix(100, 0))
+	e := &expirableBucket{Bucket: b, clock: now}
+
+	granted := make(chan bool, 100)
+	for i := 0; i < 100; i++ {
+		go func() {
+			_, ok := e.Take(1, time.Minute)
+			granted <- ok
+		}()
+	}
+
+	for i := 0; e.queued() != 100 && i < 1000; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if e.queued() != 100 {
+		t.Fatalf("Expecting 100 callers queued, was %v", e.queued())
+	}
+
+	// Callers waiting on the same refill are all served on the next tick.
+	b.add(100)
+	now.Advance(e.queueTick())
+	for i := 0; i < 100; i++ {
+		select {
+		case ok := <-granted:
+			if !ok {
+				t.Fatal("Expecting queued callers to be granted tokens")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expecting all callers to be served in one tick, %v were", i)
+		}
+	}
+
+	e.queueLock.Lock()
+	defer e.queueLock.Unlock()
+	if len(e.queue) != 0 {
+		t.Fatalf("Expecting an empty queue, was %v", len(e.queue))
+	}
+}
+
+func TestEviction(t *testing.T) {
+	c := config.NewDefaultServiceConfig()
+	for _, policy := range []string{config.EvictionLRU, config.EvictionLowestUse} {
+		ns := config.NewDefaultNamespaceConfig()
+		ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+		ns.MaxDynamicBuckets = 2
+		ns.Eviction = policy
+		c.AddNamespace(policy, ns)
+	}
+
+	bc, _, _ := NewBucketContainerWithMocks(c)
+	find := func(namespace, name string) {
+		if b, e := bc.FindBucket(namespace, name); b == nil || e != nil {
+			t.Fatalf("Expecting bucket %v:%v to be found. Error %v", namespace, name, e)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a is used more recently than b, but less often.
+	for _, name := range []string{"b", "b", "b", "a"} {
+		find(config.EvictionLRU, name)
+		find(config.EvictionLowestUse, name)
+	}
+
+	find(config.EvictionLRU, "c")
+	if bc.Exists(config.EvictionLRU, "b") || !bc.Exists(config.EvictionLRU, "a") {
+		t.Fatal("Expecting the least recently used bucket to be evicted")
+	}
+
+	find(config.EvictionLowestUse, "c")
+	if bc.Exists(config.EvictionLowestUse, "a") || !bc.Exists(config.EvictionLowestUse, "b") {
+		t.Fatal("Expecting the least used bucket to be evicted")
+	}
+
+	if n := bc.countDynamicBuckets(config.EvictionLRU); n != 2 {
+		t.Fatalf("Expecting 2 dynamic buckets. Was %v", n)
+	}
+}
@@ -0,0 +1,161 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package backends implements a BucketFactory that keeps each namespace's buckets in the backend
+// its config names, such as in memory or in Redis, so that namespaces shared by many instances can
+// enforce a single limit while others stay local. Namespaces with a tolerance have their shared
+// buckets' tokens granted from local slices, reconciled with the backend asynchronously. Backends are registered by name, so that other
+// implementations can be plugged in alongside the built-in ones.
+package backends
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/cassandra"
+	"github.com/maniksurtani/quotaservice/buckets/dynamodb"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/buckets/redis"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/logging"
+	redisClient "gopkg.in/redis.v3"
+)
+
+// Constructor creates the bucket factory of a backend, configured from the service config, or
+// returns nil if the config doesn't have the backend's settings.
+type Constructor func(cfg *config.ServiceConfig) quotaservice.BucketFactory
+
+var (
+	constructorsLock sync.RWMutex
+	constructors     = map[string]Constructor{
+		config.BackendMemory: func(*config.ServiceConfig) quotaservice.BucketFactory {
+			return memory.NewBucketFactory()
+		},
+		config.BackendRedis:     newRedisBucketFactory,
+		config.BackendDynamo:    newDynamoBucketFactory,
+		config.BackendCassandra: newCassandraBucketFactory}
+)
+
+// Register registers the Constructor of the backend namespaces name with their backend setting.
+// Panics if one is already registered.
+func Register(name string, c Constructor) {
+	constructorsLock.Lock()
+	defer constructorsLock.Unlock()
+
+	if _, exists := constructors[name]; exists {
+		panic(fmt.Sprintf("Backend %v already registered", name))
+	}
+	constructors[name] = c
+	config.RegisterBackend(name)
+}
+
+func constructor(name string) Constructor {
+	constructorsLock.RLock()
+	defer constructorsLock.RUnlock()
+
+	return constructors[name]
+}
+
+func newRedisBucketFactory(cfg *config.ServiceConfig) quotaservice.BucketFactory {
+	if cfg.Redis == nil {
+		return nil
+	}
+
+	return redis.NewBucketFactory(&redisClient.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.ExpandedPassword(),
+		DB:       cfg.Redis.DB,
+		PoolSize: cfg.Redis.PoolSize}, cfg.Redis.ConnectionRetries)
+}
+
+func newDynamoBucketFactory(cfg *config.ServiceConfig) quotaservice.BucketFactory {
+	if cfg.Dynamo == nil {
+		return nil
+	}
+
+	return dynamodb.NewBucketFactory(cfg.Dynamo)
+}
+
+func newCassandraBucketFactory(cfg *config.ServiceConfig) quotaservice.BucketFactory {
+	if cfg.Cassandra == nil {
+		return nil
+	}
+
+	return cassandra.NewBucketFactory(cfg.Cassandra)
+}
+
+type bucketFactory struct {
+	sync.Mutex
+	cfg       *config.ServiceConfig
+	factories map[string]quotaservice.BucketFactory
+	// Times hybrid buckets' reconciliations.
+	clock clock.Clock
+}
+
+// NewBucketFactory creates a factory creating buckets with the factory of their namespace's
+// backend, constructed from the service config the first time a namespace needs it.
+func NewBucketFactory() quotaservice.BucketFactory {
+	return NewBucketFactoryWith(make(map[string]quotaservice.BucketFactory))
+}
+
+// NewBucketFactoryWith is NewBucketFactory, using the given factories for their backends rather
+// than constructing them.
+func NewBucketFactoryWith(factories map[string]quotaservice.BucketFactory) quotaservice.BucketFactory {
+	return &bucketFactory{factories: factories, clock: clock.System}
+}
+
+func (bf *bucketFactory) Init(cfg *config.ServiceConfig) {
+	bf.Lock()
+	defer bf.Unlock()
+
+	bf.cfg = cfg
+	for _, f := range bf.factories {
+		f.Init(cfg)
+	}
+}
+
+func (bf *bucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) quotaservice.Bucket {
+	backend := cfg.Backend()
+	f := bf.factory(backend)
+	if f == nil {
+		logging.Printf("No %v backend configured for bucket %v, keeping it in memory.",
+			backend, config.FullyQualifiedName(namespace, bucketName))
+		return bf.factory(config.BackendMemory).NewBucket(namespace, bucketName, cfg, dyn)
+	}
+
+	b := f.NewBucket(namespace, bucketName, cfg, dyn)
+	if tolerance := cfg.Tolerance(); tolerance > 0 {
+		return newHybridBucket(b, tolerance, bf.clock)
+	}
+
+	return b
+}
+
+// factory returns the factory of the backend, constructing and initializing it if this is the
+// first time it's needed, or nil if the backend isn't registered or configured. Backends are
+// configured from the service config the factory was initialized with, so changes to their
+// settings only apply once the server is restarted.
+func (bf *bucketFactory) factory(backend string) quotaservice.BucketFactory {
+	bf.Lock()
+	defer bf.Unlock()
+
+	if f, exists := bf.factories[backend]; exists {
+		return f
+	}
+
+	c := constructor(backend)
+	if c == nil {
+		return nil
+	}
+
+	f := c(bf.cfg)
+	if f == nil {
+		return nil
+	}
+
+	f.Init(bf.cfg)
+	bf.factories[backend] = f
+	return f
+}
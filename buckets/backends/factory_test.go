@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package backends
+
+import (
+	"testing"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func TestBackends(t *testing.T) {
+	cfg, e := config.ParseConfig([]byte(`redis: {addr: "localhost:6379"}
+namespaces:
+  shared:
+    backend: redis
+    buckets:
+      b: {}
+  local:
+    buckets:
+      b: {}
+`))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	bf := NewBucketFactoryWith(map[string]quotaservice.BucketFactory{
+		config.BackendMemory: memory.NewBucketFactory(),
+		config.BackendRedis:  &quotaservice.MockBucketFactory{}})
+	bf.Init(cfg)
+
+	shared := bf.NewBucket("shared", "b", cfg.Namespaces["shared"].Buckets["b"], false)
+	defer shared.Destroy()
+	if _, ok := shared.(*quotaservice.MockBucket); !ok {
+		t.Fatalf("Expecting buckets in redis namespaces to come from the redis factory. Was %T", shared)
+	}
+
+	local := bf.NewBucket("local", "b", cfg.Namespaces["local"].Buckets["b"], false)
+	defer local.Destroy()
+	if _, ok := local.(*quotaservice.MockBucket); ok {
+		t.Fatal("Expecting buckets in other namespaces to be kept in memory")
+	}
+}
+
+func TestMissingBackend(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Backend = config.BackendRedis
+	ns.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("shared", ns)
+
+	// Without redis settings on start, there's no redis factory to create buckets with.
+	bf := NewBucketFactory()
+	bf.Init(config.NewDefaultServiceConfig())
+
+	b := bf.NewBucket("shared", "b", ns.Buckets["b"], false)
+	defer b.Destroy()
+	if _, ok := b.Take(1, 0); !ok {
+		t.Fatal("Expecting buckets to fall back to memory")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	mock := &quotaservice.MockBucketFactory{}
+	Register("mock", func(*config.ServiceConfig) quotaservice.BucketFactory { return mock })
+
+	cfg, e := config.ParseConfig([]byte("namespaces:\n  ns:\n    backend: mock\n    buckets:\n      b: {}\n"))
+	if e != nil {
+		t.Fatalf("Expecting registered backends to be valid. Error: %v", e)
+	}
+
+	bf := NewBucketFactory()
+	bf.Init(cfg)
+	if _, ok := bf.NewBucket("ns", "b", cfg.Namespaces["ns"].Buckets["b"], false).(*quotaservice.MockBucket); !ok {
+		t.Fatal("Expecting buckets to come from the registered backend's factory")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expecting backends to only be registered once")
+		}
+	}()
+	Register("mock", func(*config.ServiceConfig) quotaservice.BucketFactory { return mock })
+}
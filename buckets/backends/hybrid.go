@@ -0,0 +1,191 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package backends
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// reconcileInterval is how often hybrid buckets reconcile the tokens they've granted locally with
+// their shared backend.
+const reconcileInterval = 100 * time.Millisecond
+
+// hybridBucket grants tokens from a local slice of a bucket kept in a shared backend, so that most
+// requests needn't wait on the backend. The tokens granted locally are taken from the backend every
+// reconcileInterval, when the slice is topped up again with as many of the backend's tokens as
+// remain, up to its size. Requests the slice can't grant are passed on to the backend.
+type hybridBucket struct {
+	// The bucket kept in the shared backend.
+	quotaservice.Bucket
+	// Most tokens granted locally between reconciliations.
+	slice int64
+
+	sync.Mutex
+	// Tokens that can still be granted locally, and those granted since the last reconciliation.
+	available, pending int64
+	// Set once the backend's state is erased, after which there's nothing to reconcile.
+	erased bool
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newHybridBucket creates a bucket granting tokens from a slice of up to tolerance of remote's size,
+// reconciling with remote whenever c ticks past reconcileInterval.
+func newHybridBucket(remote quotaservice.Bucket, tolerance float64, c clock.Clock) *hybridBucket {
+	b := &hybridBucket{
+		Bucket: remote,
+		slice:  int64(math.Ceil(tolerance * float64(remote.Config().Size))),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{})}
+
+	b.reconcile()
+	go b.reconcileLoop(c.NewTicker(reconcileInterval))
+	return b
+}
+
+func (b *hybridBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	if b.takeLocally(numTokens) {
+		return 0, true
+	}
+
+	// The slice has run out, so the backend decides.
+	return b.Bucket.Take(numTokens, maxWaitTime)
+}
+
+// TakeWithMaxDebt implements quotaservice.DebtLimiter, limiting the backend's debt if it can be.
+// Tokens granted locally are granted without waiting, so they're never refused for the debt.
+func (b *hybridBucket) TakeWithMaxDebt(numTokens int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
+	if b.takeLocally(numTokens) {
+		return 0, true
+	}
+
+	if dl, ok := b.Bucket.(quotaservice.DebtLimiter); ok {
+		return dl.TakeWithMaxDebt(numTokens, maxWaitTime, maxDebt)
+	}
+
+	return b.Bucket.Take(numTokens, maxWaitTime)
+}
+
+// takeLocally grants the tokens from the local slice, if it holds enough.
+func (b *hybridBucket) takeLocally(numTokens int64) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if numTokens > b.available {
+		return false
+	}
+
+	b.available -= numTokens
+	b.pending += numTokens
+	return true
+}
+
+// reconcileLoop reconciles with the backend whenever t ticks, until the bucket is destroyed.
+func (b *hybridBucket) reconcileLoop(t clock.Ticker) {
+	defer close(b.done)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			b.reconcile()
+			return
+		case <-t.C():
+			b.reconcile()
+		}
+	}
+}
+
+// reconcile takes the tokens granted locally from the backend, and tops up the slice with the
+// tokens the backend has left. Backends that can't report their state top it up fully, unless
+// they refused the tokens granted locally.
+func (b *hybridBucket) reconcile() {
+	b.Lock()
+	if b.erased {
+		b.Unlock()
+		return
+	}
+	pending := b.pending
+	b.pending = 0
+	b.Unlock()
+
+	ok := true
+	if pending > 0 {
+		// Tokens granted locally have already been served, so they're taken however long the
+		// backend would make callers wait, as far as its max debt allows.
+		if _, ok = b.Bucket.Take(pending, time.Duration(math.MaxInt64)); !ok {
+			logging.Printf("Backend refused %v tokens granted locally from bucket %v", pending, b.Config().FQN())
+		}
+	}
+
+	available := b.slice
+	if sr, isReporter := b.Bucket.(quotaservice.StatusReporter); isReporter {
+		if tokens := sr.Status().Tokens; tokens < available {
+			available = tokens
+		}
+	} else if !ok {
+		available = 0
+	}
+
+	b.Lock()
+	// Tokens granted while reconciling come out of the new slice.
+	b.available = available - b.pending
+	b.Unlock()
+}
+
+// Status reports the backend's state, less the tokens granted locally since the last
+// reconciliation.
+func (b *hybridBucket) Status() *admin.BucketStatus {
+	sr, ok := b.Bucket.(quotaservice.StatusReporter)
+	if !ok {
+		return &admin.BucketStatus{}
+	}
+
+	s := sr.Status()
+	b.Lock()
+	s.Tokens -= b.pending
+	b.Unlock()
+	if s.Tokens < 0 {
+		s.Tokens = 0
+	}
+
+	return s
+}
+
+// EraseState implements quotaservice.StateEraser, erasing the backend's state if it keeps any.
+// Tokens granted locally are forgotten rather than taken from the backend, which would otherwise
+// recreate the state once it's erased.
+func (b *hybridBucket) EraseState() {
+	b.Lock()
+	b.erased = true
+	b.available, b.pending = 0, 0
+	b.Unlock()
+
+	b.stopReconciling()
+	if e, ok := b.Bucket.(quotaservice.StateEraser); ok {
+		e.EraseState()
+	}
+}
+
+// Destroy takes the tokens granted locally from the backend one last time before destroying the
+// bucket kept there.
+func (b *hybridBucket) Destroy() {
+	b.stopReconciling()
+	b.Bucket.Destroy()
+}
+
+// stopReconciling stops reconcileLoop, waiting for its last reconciliation.
+func (b *hybridBucket) stopReconciling() {
+	b.stopOnce.Do(func() { close(b.stop) })
+	<-b.done
+}
@@ -0,0 +1,205 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package backends
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+// countingBucket stands in for a bucket in a shared backend, granting up to its size in tokens.
+type countingBucket struct {
+	sync.Mutex
+	cfg       *config.BucketConfig
+	taken     int64
+	destroyed bool
+}
+
+func (b *countingBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.taken+numTokens > b.cfg.Size {
+		return 0, false
+	}
+
+	b.taken += numTokens
+	return 0, true
+}
+
+func (b *countingBucket) Status() *admin.BucketStatus {
+	b.Lock()
+	defer b.Unlock()
+
+	return &admin.BucketStatus{Tokens: b.cfg.Size - b.taken}
+}
+
+func (b *countingBucket) Config() *config.BucketConfig { return b.cfg }
+func (b *countingBucket) Dynamic() bool                { return false }
+func (b *countingBucket) Destroy()                     { b.destroyed = true }
+
+// waitForTaken waits for n tokens to have been taken from the bucket.
+func (b *countingBucket) waitForTaken(t *testing.T, n int64) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if b.Status().Tokens == b.cfg.Size-n {
+			return
+		}
+	}
+
+	t.Fatalf("Expecting %v tokens to be taken. Status %+v", n, b.Status())
+}
+
+func TestHybridBucket(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	remote := &countingBucket{cfg: cfg}
+	now := clock.NewFake(time.Unix(100, 0))
+	b := newHybridBucket(remote, 0.2, now)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := b.Take(1, 0); !ok {
+			t.Fatal("Expecting tokens to be granted from the local slice")
+		}
+	}
+
+	if s := remote.Status(); s.Tokens != 10 {
+		t.Fatalf("Expecting tokens granted locally not to be taken from the backend yet. Status %+v", s)
+	}
+
+	if s := b.Status(); s.Tokens != 8 {
+		t.Fatalf("Expecting status to count tokens granted locally. Status %+v", s)
+	}
+
+	// Requests the slice can't grant go to the backend.
+	if _, ok := b.Take(1, 0); !ok {
+		t.Fatal("Expecting the backend to grant tokens once the slice runs out")
+	}
+	remote.waitForTaken(t, 1)
+
+	now.Advance(reconcileInterval)
+	remote.waitForTaken(t, 3)
+
+	// The slice is topped up no further than the backend's tokens.
+	remote.Take(6, 0)
+	now.Advance(reconcileInterval)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		b.Lock()
+		available := b.available
+		b.Unlock()
+		if available == 1 {
+			break
+		}
+	}
+
+	if _, ok := b.Take(2, 0); ok {
+		t.Fatal("Expecting the slice to hold no more than the backend's tokens")
+	}
+
+	if _, ok := b.Take(1, 0); !ok {
+		t.Fatal("Expecting the backend's last token to be granted")
+	}
+
+	b.Destroy()
+	if s := remote.Status(); s.Tokens != 0 || !remote.destroyed {
+		t.Fatalf("Expecting tokens granted locally to be reconciled when destroyed. Status %+v", s)
+	}
+}
+
+// limitingBucket is a countingBucket that limits its debt and erases its state, as Redis buckets do.
+type limitingBucket struct {
+	countingBucket
+	maxDebt time.Duration
+	erased  bool
+}
+
+func (b *limitingBucket) TakeWithMaxDebt(numTokens int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
+	b.Lock()
+	b.maxDebt = maxDebt
+	b.Unlock()
+	return b.Take(numTokens, maxWaitTime)
+}
+
+func (b *limitingBucket) EraseState() {
+	b.Lock()
+	defer b.Unlock()
+	b.erased = true
+	b.taken = 0
+}
+
+func TestHybridBucketLimitsDebt(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	remote := &limitingBucket{countingBucket: countingBucket{cfg: cfg}}
+	b := newHybridBucket(remote, 0.1, clock.NewFake(time.Unix(100, 0)))
+	defer b.Destroy()
+
+	var dl quotaservice.DebtLimiter = b
+	if _, ok := dl.TakeWithMaxDebt(1, 0, time.Second); !ok || remote.maxDebt != 0 {
+		t.Fatal("Expecting tokens to be granted from the local slice")
+	}
+
+	// Requests the slice can't grant are limited by the backend.
+	if _, ok := dl.TakeWithMaxDebt(1, 0, time.Second); !ok || remote.maxDebt != time.Second {
+		t.Fatalf("Expecting the backend to limit its debt. Max debt %v", remote.maxDebt)
+	}
+}
+
+func TestHybridBucketErasesState(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	remote := &limitingBucket{countingBucket: countingBucket{cfg: cfg}}
+	now := clock.NewFake(time.Unix(100, 0))
+	b := newHybridBucket(remote, 0.5, now)
+
+	b.Take(3, 0)
+	var eraser quotaservice.StateEraser = b
+	eraser.EraseState()
+	b.Destroy()
+
+	// Tokens granted locally aren't taken from the backend once its state is erased.
+	if s := remote.Status(); !remote.erased || s.Tokens != 10 || !remote.destroyed {
+		t.Fatalf("Expecting the backend's state to be erased. Status %+v", s)
+	}
+}
+
+func TestHybridBackends(t *testing.T) {
+	cfg, e := config.ParseConfig([]byte(`redis: {addr: "localhost:6379"}
+namespaces:
+  hybrid:
+    backend: redis
+    tolerance: 0.1
+    buckets:
+      b: {}
+`))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	bf := NewBucketFactoryWith(map[string]quotaservice.BucketFactory{
+		config.BackendMemory: memory.NewBucketFactory(),
+		config.BackendRedis:  &quotaservice.MockBucketFactory{}})
+	bf.Init(cfg)
+
+	hybrid := bf.NewBucket("hybrid", "b", cfg.Namespaces["hybrid"].Buckets["b"], false)
+	defer hybrid.Destroy()
+	if b, ok := hybrid.(*hybridBucket); !ok || b.slice != 10 {
+		t.Fatalf("Expecting buckets in namespaces with a tolerance to be granted from local slices. Was %T", hybrid)
+	}
+
+	// Buckets falling back to memory are granted by it alone.
+	bf = NewBucketFactory()
+	bf.Init(config.NewDefaultServiceConfig())
+	local := bf.NewBucket("hybrid", "b", cfg.Namespaces["hybrid"].Buckets["b"], false)
+	defer local.Destroy()
+	if _, ok := local.(*hybridBucket); ok {
+		t.Fatal("Expecting buckets kept in memory not to be granted from local slices")
+	}
+}
@@ -49,6 +49,43 @@ func TestTokenAcquisition(t *testing.T, bucket quotaservice.Bucket) {
 	}
 }
 
+// TestStatus tests the status reported by a bucket created with NewStatusTestBucketConfig.
+func TestStatus(t *testing.T, bucket quotaservice.Bucket) {
+	r, ok := bucket.(quotaservice.StatusReporter)
+	if !ok {
+		t.Fatalf("Expecting %T to report its status", bucket)
+	}
+
+	if s := r.Status(); s.Tokens != 10 || s.DebtMillis != 0 {
+		t.Fatalf("Expecting full bucket without debt. Was %+v", s)
+	}
+
+	bucket.Take(4, 0)
+	s := r.Status()
+	if s.Tokens != 6 || s.DebtMillis != 0 {
+		t.Fatalf("Expecting 6 tokens. Was %+v", s)
+	}
+
+	if s.LastFillMillis <= 0 || s.LastFillMillis > time.Now().UnixNano()/1e6 {
+		t.Fatalf("Expecting a past last fill time. Was %+v", s)
+	}
+
+	// Claims tokens 10 seconds into the future.
+	bucket.Take(16, time.Minute)
+	if s = r.Status(); s.Tokens != 0 || s.DebtMillis < 9000 || s.DebtMillis > 10000 {
+		t.Fatalf("Expecting 10 seconds of debt. Was %+v", s)
+	}
+}
+
+// NewStatusTestBucketConfig returns the config of buckets used with TestStatus.
+func NewStatusTestBucketConfig() *config.BucketConfig {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	cfg.FillRate = 1
+	cfg.MaxDebtMillis = 60000
+	return cfg
+}
+
 func TestGC(t *testing.T, factory quotaservice.BucketFactory, impl string) {
 	cfg := config.NewDefaultServiceConfig()
 	cfg.Namespaces["n"] = config.NewDefaultNamespaceConfig()
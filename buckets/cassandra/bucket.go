@@ -0,0 +1,242 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package cassandra implements buckets counting tokens in a Cassandra or Scylla table, for quotas
+// over long periods, such as a day or a month, that must survive restarts and be shared across
+// regions. Rather than filling over time, buckets grant up to their size in tokens per period of
+// window_millis, a day unless configured otherwise. Periods are aligned to the Unix epoch, so daily
+// quotas reset at midnight UTC. Tokens are never lent, so requests that don't fit are refused
+// straight away.
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+const (
+	// defaultMaxAttempts is how many attempts are made at claiming tokens with lightweight
+	// transactions if the config doesn't say.
+	defaultMaxAttempts = 5
+	// defaultPeriod is how long buckets count tokens over if they don't have a window.
+	defaultPeriod = 24 * time.Hour
+)
+
+type bucketFactory struct {
+	client *client
+	// Keyspace qualified name of the table.
+	table       string
+	consistency uint16
+	// Consistency of the Paxos phase of lightweight transactions, and of reads made before them.
+	serialConsistency uint16
+	lwt               bool
+	maxAttempts       int
+	clock             clock.Clock
+}
+
+// NewBucketFactory creates a factory whose buckets count tokens in the table the config names.
+func NewBucketFactory(cfg *config.CassandraConfig) quotaservice.BucketFactory {
+	return newBucketFactory(cfg, newClient(cfg.Hosts, cfg.Username, cfg.ExpandedPassword()), clock.System)
+}
+
+func newBucketFactory(cfg *config.CassandraConfig, c *client, clk clock.Clock) *bucketFactory {
+	bf := &bucketFactory{
+		client:            c,
+		table:             cfg.Keyspace + "." + cfg.Table,
+		consistency:       consistencies["quorum"],
+		serialConsistency: serial,
+		lwt:               cfg.Mode == config.CassandraLWT,
+		maxAttempts:       cfg.MaxAttempts,
+		clock:             clk}
+
+	if level, ok := consistencies[cfg.Consistency]; ok {
+		bf.consistency = level
+	}
+
+	// Quotas read and written within a region are serialized within it too.
+	if strings.HasPrefix(cfg.Consistency, "local_") {
+		bf.serialConsistency = localSerial
+	}
+
+	if bf.maxAttempts < 1 {
+		bf.maxAttempts = defaultMaxAttempts
+	}
+
+	return bf
+}
+
+func (bf *bucketFactory) Init(cfg *config.ServiceConfig) {}
+
+func (bf *bucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) quotaservice.Bucket {
+	if cfg.Algorithm != "" {
+		logging.Printf("Cassandra buckets count tokens per period rather than by the %v algorithm. Bucket %v counts tokens per period.",
+			cfg.Algorithm, config.FullyQualifiedName(namespace, bucketName))
+	}
+
+	period := cfg.WindowMillis * int64(time.Millisecond)
+	if period <= 0 {
+		period = int64(defaultPeriod)
+	}
+
+	return &cassandraBucket{
+		dynamic: dyn,
+		cfg:     cfg,
+		factory: bf,
+		id:      config.FullyQualifiedName(namespace, bucketName),
+		period:  period}
+}
+
+// cassandraBucket is threadsafe since it delegates concurrency to Cassandra.
+type cassandraBucket struct {
+	dynamic bool
+	cfg     *config.BucketConfig
+	factory *bucketFactory
+	id      string
+	// Length of the periods tokens are counted over, in nanos.
+	period int64
+}
+
+func (b *cassandraBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	period := b.factory.clock.Now().UnixNano() / b.period
+
+	var ok bool
+	var e error
+	if b.factory.lwt {
+		ok, e = b.claim(numTokens, period)
+	} else {
+		ok, e = b.count(numTokens, period)
+	}
+
+	if e != nil {
+		logging.Warnf("Unable to claim tokens from bucket %v in Cassandra: %v", b.id, e)
+		return 0, false
+	}
+
+	return 0, ok
+}
+
+// count adds the tokens to the period's counter, taking them away again if that exceeds the
+// bucket's size, as counters can't be updated conditionally.
+func (b *cassandraBucket) count(numTokens, period int64) (bool, error) {
+	if e := b.add(numTokens, period); e != nil {
+		return false, e
+	}
+
+	used, _, e := b.used(period, b.factory.consistency)
+	if e != nil {
+		return false, e
+	}
+
+	if used <= b.cfg.Size {
+		return true, nil
+	}
+
+	return false, b.add(-numTokens, period)
+}
+
+func (b *cassandraBucket) add(numTokens, period int64) error {
+	_, e := b.factory.client.query(
+		fmt.Sprintf("UPDATE %v SET used = used + ? WHERE id = ? AND period = ?", b.factory.table),
+		b.factory.consistency, 0, bigint(numTokens), []byte(b.id), bigint(period))
+	return e
+}
+
+// claim claims the tokens with a lightweight transaction, on condition that no other claim has
+// changed the period's count since it was read, retrying if one has.
+func (b *cassandraBucket) claim(numTokens, period int64) (bool, error) {
+	// Rows of finished periods are kept for another period, such as to reconcile usage, then expire.
+	ttl := intValue(int32(2 * b.period / int64(time.Second)))
+
+	for attempt := 0; attempt < b.factory.maxAttempts; attempt++ {
+		used, exists, e := b.used(period, b.factory.serialConsistency)
+		if e != nil {
+			return false, e
+		}
+
+		if used+numTokens > b.cfg.Size {
+			return false, nil
+		}
+
+		var rows []row
+		if exists {
+			rows, e = b.factory.client.query(
+				fmt.Sprintf("UPDATE %v USING TTL ? SET used = ? WHERE id = ? AND period = ? IF used = ?", b.factory.table),
+				b.factory.consistency, b.factory.serialConsistency,
+				ttl, bigint(used+numTokens), []byte(b.id), bigint(period), bigint(used))
+		} else {
+			rows, e = b.factory.client.query(
+				fmt.Sprintf("INSERT INTO %v (id, period, used) VALUES (?, ?, ?) IF NOT EXISTS USING TTL ?", b.factory.table),
+				b.factory.consistency, b.factory.serialConsistency,
+				[]byte(b.id), bigint(period), bigint(numTokens), ttl)
+		}
+
+		if e != nil {
+			return false, e
+		}
+
+		if len(rows) > 0 && toBool(rows[0]["[applied]"]) {
+			return true, nil
+		}
+		// Another claim changed the count since it was read, so try again with its count.
+	}
+
+	return false, fmt.Errorf("Gave up after %v conflicting attempts", b.factory.maxAttempts)
+}
+
+// used reads the tokens counted in the period, and whether they've been counted at all.
+func (b *cassandraBucket) used(period int64, consistency uint16) (int64, bool, error) {
+	rows, e := b.factory.client.query(
+		fmt.Sprintf("SELECT used FROM %v WHERE id = ? AND period = ?", b.factory.table),
+		consistency, 0, []byte(b.id), bigint(period))
+	if e != nil || len(rows) == 0 {
+		return 0, false, e
+	}
+
+	return toInt64(rows[0]["used"]), true, nil
+}
+
+func (b *cassandraBucket) Config() *config.BucketConfig {
+	return b.cfg
+}
+
+// Status reads the tokens left in the current period, which is reported as the last fill.
+func (b *cassandraBucket) Status() *admin.BucketStatus {
+	period := b.factory.clock.Now().UnixNano() / b.period
+	used, _, e := b.used(period, b.factory.consistency)
+	if e != nil {
+		logging.Warnf("Unable to read status of bucket %v from Cassandra: %v", b.id, e)
+		return &admin.BucketStatus{}
+	}
+
+	s := &admin.BucketStatus{Tokens: b.cfg.Size - used, LastFillMillis: period * b.period / 1e6}
+	if s.Tokens < 0 {
+		s.Tokens = 0
+	}
+
+	return s
+}
+
+func (b *cassandraBucket) Dynamic() bool {
+	return b.dynamic
+}
+
+func (b *cassandraBucket) Destroy() {
+	// No-op
+}
+
+// EraseState deletes the bucket's counts for every period, so a bucket of the same name starts
+// afresh.
+func (b *cassandraBucket) EraseState() {
+	_, e := b.factory.client.query(fmt.Sprintf("DELETE FROM %v WHERE id = ?", b.factory.table),
+		b.factory.consistency, 0, []byte(b.id))
+	if e != nil {
+		logging.Warnf("Unable to erase state of bucket %v from Cassandra: %v", b.id, e)
+	}
+}
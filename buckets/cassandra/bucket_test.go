@@ -0,0 +1,341 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package cassandra
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+type key struct {
+	id     string
+	period int64
+}
+
+// fakeCassandra serves the queries buckets make over the native protocol, keeping counts in memory.
+type fakeCassandra struct {
+	sync.Mutex
+	listener net.Listener
+	used     map[key]int64
+	// Credentials clients must authenticate with, if set.
+	username, password string
+	// Called before lightweight transactions are applied, such as to simulate other instances'.
+	beforeTransaction func(k key)
+	// Consistency levels of the queries served.
+	consistencies []uint16
+}
+
+func newFakeCassandra(t *testing.T) *fakeCassandra {
+	l, e := net.Listen("tcp", "localhost:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	f := &fakeCassandra{listener: l, used: make(map[key]int64)}
+	go func() {
+		for {
+			conn, e := l.Accept()
+			if e != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	return f
+}
+
+func (f *fakeCassandra) Close() {
+	f.listener.Close()
+}
+
+func (f *fakeCassandra) serve(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, headerLength)
+	for {
+		if _, e := io.ReadFull(conn, header); e != nil {
+			return
+		}
+
+		body := make([]byte, binary.BigEndian.Uint32(header[5:]))
+		if _, e := io.ReadFull(conn, body); e != nil {
+			return
+		}
+
+		op, rsp := f.handle(header[4], body)
+		header := []byte{protocolVersion | responseFlag, 0, 0, 0, op, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[5:], uint32(len(rsp)))
+		conn.Write(append(header, rsp...))
+	}
+}
+
+func (f *fakeCassandra) handle(op byte, body []byte) (byte, []byte) {
+	switch op {
+	case opStartup:
+		if f.username != "" {
+			b := &buffer{}
+			b.string("org.apache.cassandra.auth.PasswordAuthenticator")
+			return opAuthenticate, b.b
+		}
+		return opReady, nil
+	case opAuthResponse:
+		r := &reader{b: body}
+		if string(r.bytes()) != "\x00"+f.username+"\x00"+f.password {
+			return failure("Bad credentials")
+		}
+		return opAuthSuccess, nil
+	case opQuery:
+		return f.query(body)
+	}
+
+	return failure("Unexpected operation")
+}
+
+func (f *fakeCassandra) query(body []byte) (byte, []byte) {
+	r := &reader{b: body}
+	cql := string(r.take(int(r.int())))
+	consistency := r.short()
+	flags := r.take(1)[0]
+	var values [][]byte
+	if flags&flagValues != 0 {
+		values = make([][]byte, r.short())
+		for i := range values {
+			values[i] = r.bytes()
+		}
+	}
+
+	f.Lock()
+	f.consistencies = append(f.consistencies, consistency)
+	hook := f.beforeTransaction
+	f.Unlock()
+
+	if strings.Contains(cql, " IF ") && hook != nil {
+		if strings.HasPrefix(cql, "INSERT") {
+			hook(key{string(values[0]), toInt64(values[1])})
+		} else {
+			hook(key{string(values[2]), toInt64(values[3])})
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	switch {
+	case strings.HasPrefix(cql, "SELECT used FROM ks.buckets"):
+		k := key{string(values[0]), toInt64(values[1])}
+		if used, exists := f.used[k]; exists {
+			return rows(row{"used": bigint(used)})
+		}
+		return rows()
+	case strings.HasPrefix(cql, "UPDATE ks.buckets SET used = used + ?"):
+		f.used[key{string(values[1]), toInt64(values[2])}] += toInt64(values[0])
+		return void()
+	case strings.HasPrefix(cql, "INSERT INTO ks.buckets") && strings.Contains(cql, "IF NOT EXISTS"):
+		k := key{string(values[0]), toInt64(values[1])}
+		if used, exists := f.used[k]; exists {
+			return rows(row{"[applied]": []byte{0}, "used": bigint(used)})
+		}
+		f.used[k] = toInt64(values[2])
+		return rows(row{"[applied]": []byte{1}})
+	case strings.HasPrefix(cql, "UPDATE ks.buckets USING TTL ? SET used = ?") && strings.HasSuffix(cql, "IF used = ?"):
+		k := key{string(values[2]), toInt64(values[3])}
+		if f.used[k] != toInt64(values[4]) {
+			return rows(row{"[applied]": []byte{0}, "used": bigint(f.used[k])})
+		}
+		f.used[k] = toInt64(values[1])
+		return rows(row{"[applied]": []byte{1}})
+	case strings.HasPrefix(cql, "DELETE FROM ks.buckets WHERE id = ?"):
+		for k := range f.used {
+			if k.id == string(values[0]) {
+				delete(f.used, k)
+			}
+		}
+		return void()
+	}
+
+	return failure("Unexpected query " + cql)
+}
+
+func void() (byte, []byte) {
+	b := &buffer{}
+	b.int(0x0001)
+	return opResult, b.b
+}
+
+// rows encodes a result with the rows, whose columns are [applied] and used, if they have them.
+func rows(rs ...row) (byte, []byte) {
+	var columns []string
+	if len(rs) > 0 {
+		if _, ok := rs[0]["[applied]"]; ok {
+			columns = append(columns, "[applied]")
+		}
+	}
+	columns = append(columns, "used")
+
+	b := &buffer{}
+	b.int(resultRows)
+	b.int(rowsGlobalTableSpec)
+	b.int(int32(len(columns)))
+	b.string("ks")
+	b.string("buckets")
+	for _, c := range columns {
+		b.string(c)
+		if c == "used" {
+			b.short(0x0002) // bigint
+		} else {
+			b.short(0x0004) // boolean
+		}
+	}
+
+	b.int(int32(len(rs)))
+	for _, r := range rs {
+		for _, c := range columns {
+			b.bytes(r[c])
+		}
+	}
+
+	return opResult, b.b
+}
+
+func failure(msg string) (byte, []byte) {
+	b := &buffer{}
+	b.int(0x2200) // Invalid
+	b.string(msg)
+	return opError, b.b
+}
+
+func newTestFactory(f *fakeCassandra, mode string, now clock.Clock) *bucketFactory {
+	cfg := &config.CassandraConfig{
+		Hosts:       []string{f.listener.Addr().String()},
+		Keyspace:    "ks",
+		Table:       "buckets",
+		Username:    f.username,
+		Password:    f.password,
+		Consistency: "local_quorum",
+		Mode:        mode}
+	return newBucketFactory(cfg, newClient(cfg.Hosts, cfg.Username, cfg.Password), now)
+}
+
+func newTestBucketConfig() *config.BucketConfig {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	return cfg
+}
+
+func TestTake(t *testing.T) {
+	for _, mode := range []string{config.CassandraCounter, config.CassandraLWT} {
+		f := newFakeCassandra(t)
+		f.username, f.password = "user", "secret"
+		defer f.Close()
+
+		// A day before midnight UTC.
+		now := clock.NewFake(time.Unix(86400*100-1, 0))
+		b1 := newTestFactory(f, mode, now).NewBucket("ns", "b", newTestBucketConfig(), false).(*cassandraBucket)
+		b2 := newTestFactory(f, mode, now).NewBucket("ns", "b", newTestBucketConfig(), false).(*cassandraBucket)
+
+		if _, ok := b1.Take(6, 0); !ok {
+			t.Fatalf("Expecting %v buckets to grant tokens", mode)
+		}
+
+		// Instances sharing the table share the quota.
+		if _, ok := b2.Take(5, time.Hour); ok {
+			t.Fatalf("Expecting %v buckets to refuse tokens beyond their size", mode)
+		}
+
+		if _, ok := b2.Take(4, 0); !ok {
+			t.Fatalf("Expecting %v buckets to grant the rest of their tokens", mode)
+		}
+
+		if s := b1.Status(); s.Tokens != 0 || s.LastFillMillis != 86400*99*1000 {
+			t.Fatalf("Unexpected status of %v bucket %+v", mode, s)
+		}
+
+		// Quotas are counted afresh each period.
+		now.Advance(time.Second)
+		if s := b1.Status(); s.Tokens != 10 || s.LastFillMillis != 86400*100*1000 {
+			t.Fatalf("Expecting %v buckets to reset at the end of the period. Status %+v", mode, s)
+		}
+
+		b1.Take(1, 0)
+		b1.EraseState()
+		if len(f.used) != 0 {
+			t.Fatalf("Expecting %v buckets' counts to be erased. Counts %v", mode, f.used)
+		}
+
+		if f.consistencies[len(f.consistencies)-1] != consistencies["local_quorum"] {
+			t.Fatalf("Expecting queries at the configured consistency. Consistencies %v", f.consistencies)
+		}
+	}
+}
+
+func TestConflictingTransactions(t *testing.T) {
+	f := newFakeCassandra(t)
+	defer f.Close()
+
+	now := clock.NewFake(time.Unix(100, 0))
+	b := newTestFactory(f, config.CassandraLWT, now).NewBucket("ns", "b", newTestBucketConfig(), false).(*cassandraBucket)
+	other := newTestFactory(f, config.CassandraLWT, now).NewBucket("ns", "b", newTestBucketConfig(), false).(*cassandraBucket)
+
+	// Another instance claims tokens between the bucket's read and transaction.
+	conflicts := 0
+	f.beforeTransaction = func(key) {
+		if conflicts == 0 {
+			conflicts++
+			other.Take(3, 0)
+		}
+	}
+
+	if _, ok := b.Take(7, 0); !ok {
+		t.Fatal("Expecting conflicting claims to be retried")
+	}
+
+	f.beforeTransaction = nil
+	if s := b.Status(); s.Tokens != 0 {
+		t.Fatalf("Expecting both claims to count. Status %+v", s)
+	}
+
+	// Claims give up once they've conflicted max attempts times.
+	f.beforeTransaction = func(k key) {
+		f.Lock()
+		defer f.Unlock()
+		f.used[k]--
+	}
+
+	if _, ok := b.Take(1, 0); ok {
+		t.Fatal("Expecting claims to give up after too many conflicts")
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	f := newFakeCassandra(t)
+	defer f.Close()
+
+	// Hosts are tried in turn, skipping those that can't be reached.
+	l, _ := net.Listen("tcp", "localhost:0")
+	down := l.Addr().String()
+	l.Close()
+
+	cfg := &config.CassandraConfig{Hosts: []string{down, f.listener.Addr().String()}, Keyspace: "ks", Table: "buckets"}
+	bf := newBucketFactory(cfg, newClient(cfg.Hosts, "", ""), clock.NewFake(time.Unix(100, 0)))
+	if _, ok := bf.NewBucket("ns", "b", newTestBucketConfig(), false).Take(1, 0); !ok {
+		t.Fatal("Expecting reachable hosts to be used")
+	}
+
+	f.Close()
+	bf.client.Lock()
+	bf.client.close()
+	bf.client.Unlock()
+	if _, ok := bf.NewBucket("ns", "b", newTestBucketConfig(), false).Take(1, 0); ok {
+		t.Fatal("Expecting requests to be refused once no host can be reached")
+	}
+}
@@ -0,0 +1,414 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package cassandra
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// The client speaks just as much of version 4 of Cassandra's native protocol as buckets need:
+// unprepared queries with bound values, over a single connection at a time.
+const (
+	protocolVersion = 0x04
+	responseFlag    = 0x80
+	headerLength    = 9
+
+	opError        = 0x00
+	opStartup      = 0x01
+	opReady        = 0x02
+	opAuthenticate = 0x03
+	opQuery        = 0x07
+	opResult       = 0x08
+	opAuthResponse = 0x0F
+	opAuthSuccess  = 0x10
+
+	resultRows = 0x0002
+
+	flagValues            = 0x01
+	flagSerialConsistency = 0x10
+
+	rowsGlobalTableSpec = 0x0001
+	rowsHasMorePages    = 0x0002
+	rowsNoMetadata      = 0x0004
+
+	dialTimeout = 5 * time.Second
+	ioTimeout   = 10 * time.Second
+)
+
+// Consistency levels, as the protocol encodes them.
+var consistencies = map[string]uint16{
+	"one":          0x0001,
+	"two":          0x0002,
+	"three":        0x0003,
+	"quorum":       0x0004,
+	"all":          0x0005,
+	"local_quorum": 0x0006,
+	"each_quorum":  0x0007,
+	"local_one":    0x000A}
+
+const (
+	serial      uint16 = 0x0008
+	localSerial uint16 = 0x0009
+)
+
+// serverError is an ERROR response.
+type serverError struct {
+	Code    int32
+	Message string
+}
+
+func (e *serverError) Error() string {
+	return fmt.Sprintf("Cassandra error 0x%04x: %v", e.Code, e.Message)
+}
+
+// row is a row of a result, by column name. Values are as encoded by the protocol, or nil if null.
+type row map[string][]byte
+
+// client sends queries to the first of its hosts it can connect to, reconnecting to the next once
+// a connection fails.
+type client struct {
+	hosts              []string
+	username, password string
+
+	sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	next int // Index of the host to connect to next
+}
+
+func newClient(hosts []string, username, password string) *client {
+	return &client{hosts: hosts, username: username, password: password}
+}
+
+// query runs the CQL statement with the values bound to its markers, at the consistency level,
+// and serialConsistency, if set, for lightweight transactions. Returns the rows of its result.
+func (c *client) query(cql string, consistency, serialConsistency uint16, values ...[]byte) ([]row, error) {
+	body := &buffer{}
+	body.longString(cql)
+	body.short(consistency)
+
+	var flags byte
+	if len(values) > 0 {
+		flags |= flagValues
+	}
+
+	if serialConsistency != 0 {
+		flags |= flagSerialConsistency
+	}
+	body.byte(flags)
+
+	if len(values) > 0 {
+		body.short(uint16(len(values)))
+		for _, v := range values {
+			body.bytes(v)
+		}
+	}
+
+	if serialConsistency != 0 {
+		body.short(serialConsistency)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.conn == nil {
+		if e := c.connect(); e != nil {
+			return nil, e
+		}
+	}
+
+	op, rsp, e := c.roundTrip(opQuery, body.b)
+	if e != nil {
+		// The connection may be broken, so the next query makes a new one.
+		c.close()
+		return nil, e
+	}
+
+	if op != opResult {
+		return nil, fmt.Errorf("Unexpected response 0x%02x to query", op)
+	}
+
+	return parseResult(rsp)
+}
+
+// connect connects to the next host that accepts connections, starting up and authenticating.
+// Must be called with the lock held.
+func (c *client) connect() (e error) {
+	for attempt := 0; attempt < len(c.hosts); attempt++ {
+		host := c.hosts[c.next]
+		c.next = (c.next + 1) % len(c.hosts)
+
+		if e = c.startup(host); e == nil {
+			return nil
+		}
+		c.close()
+	}
+
+	return fmt.Errorf("Unable to connect to Cassandra: %v", e)
+}
+
+func (c *client) startup(host string) error {
+	conn, e := net.DialTimeout("tcp", host, dialTimeout)
+	if e != nil {
+		return e
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+
+	body := &buffer{}
+	body.short(1)
+	body.string("CQL_VERSION")
+	body.string("3.0.0")
+	op, rsp, e := c.roundTrip(opStartup, body.b)
+	if e != nil {
+		return e
+	}
+
+	if op == opAuthenticate {
+		// PasswordAuthenticator's SASL PLAIN token.
+		token := &buffer{}
+		token.bytes([]byte("\x00" + c.username + "\x00" + c.password))
+		if op, rsp, e = c.roundTrip(opAuthResponse, token.b); e != nil {
+			return e
+		}
+
+		if op != opAuthSuccess {
+			return fmt.Errorf("Unexpected response 0x%02x to authentication", op)
+		}
+		return nil
+	}
+
+	if op != opReady {
+		return fmt.Errorf("Unexpected response 0x%02x to startup: %q", op, rsp)
+	}
+	return nil
+}
+
+// roundTrip sends a request and reads its response. ERROR responses are returned as errors. Must
+// be called with the lock held.
+func (c *client) roundTrip(op byte, body []byte) (byte, []byte, error) {
+	c.conn.SetDeadline(time.Now().Add(ioTimeout))
+
+	header := make([]byte, headerLength, headerLength+len(body))
+	header[0] = protocolVersion
+	header[4] = op
+	binary.BigEndian.PutUint32(header[5:], uint32(len(body)))
+	if _, e := c.conn.Write(append(header, body...)); e != nil {
+		return 0, nil, e
+	}
+
+	if _, e := io.ReadFull(c.r, header); e != nil {
+		return 0, nil, e
+	}
+
+	if header[0] != protocolVersion|responseFlag {
+		return 0, nil, fmt.Errorf("Unexpected protocol version 0x%02x", header[0])
+	}
+
+	rsp := make([]byte, binary.BigEndian.Uint32(header[5:]))
+	if _, e := io.ReadFull(c.r, rsp); e != nil {
+		return 0, nil, e
+	}
+
+	if header[4] == opError {
+		r := &reader{b: rsp}
+		e := &serverError{Code: r.int()}
+		e.Message = r.string()
+		if r.e != nil {
+			return 0, nil, r.e
+		}
+		return 0, nil, e
+	}
+
+	return header[4], rsp, nil
+}
+
+// close closes the connection, if any. Must be called with the lock held.
+func (c *client) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+		c.r = nil
+	}
+}
+
+// parseResult returns the rows of a RESULT response, or none if it has none.
+func parseResult(b []byte) ([]row, error) {
+	r := &reader{b: b}
+	if r.int() != resultRows {
+		return nil, r.e
+	}
+
+	flags := r.int()
+	columns := make([]string, r.int())
+	if flags&rowsHasMorePages != 0 {
+		r.bytes()
+	}
+
+	if flags&rowsNoMetadata != 0 {
+		return nil, errors.New("Rows without metadata")
+	}
+
+	if flags&rowsGlobalTableSpec != 0 {
+		r.string()
+		r.string()
+	}
+
+	for i := range columns {
+		if flags&rowsGlobalTableSpec == 0 {
+			r.string()
+			r.string()
+		}
+		columns[i] = r.string()
+		r.skipType()
+	}
+
+	rows := make([]row, r.int())
+	for i := range rows {
+		rows[i] = make(row, len(columns))
+		for _, name := range columns {
+			rows[i][name] = r.bytes()
+		}
+	}
+
+	return rows, r.e
+}
+
+// buffer encodes the protocol's notations.
+type buffer struct {
+	b []byte
+}
+
+func (b *buffer) byte(v byte) {
+	b.b = append(b.b, v)
+}
+
+func (b *buffer) short(v uint16) {
+	b.b = append(b.b, byte(v>>8), byte(v))
+}
+
+func (b *buffer) int(v int32) {
+	b.b = append(b.b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (b *buffer) string(s string) {
+	b.short(uint16(len(s)))
+	b.b = append(b.b, s...)
+}
+
+func (b *buffer) longString(s string) {
+	b.int(int32(len(s)))
+	b.b = append(b.b, s...)
+}
+
+// bytes appends v, or null if v is nil.
+func (b *buffer) bytes(v []byte) {
+	if v == nil {
+		b.int(-1)
+		return
+	}
+
+	b.int(int32(len(v)))
+	b.b = append(b.b, v...)
+}
+
+// reader decodes the protocol's notations, remembering the first error it runs into, after which
+// it returns zero values.
+type reader struct {
+	b []byte
+	e error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.e != nil {
+		return nil
+	}
+
+	if n < 0 || n > len(r.b) {
+		r.e = io.ErrUnexpectedEOF
+		return nil
+	}
+
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) short() uint16 {
+	if v := r.take(2); v != nil {
+		return binary.BigEndian.Uint16(v)
+	}
+	return 0
+}
+
+func (r *reader) int() int32 {
+	if v := r.take(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (r *reader) string() string {
+	return string(r.take(int(r.short())))
+}
+
+// bytes returns the bytes, or nil if they're null.
+func (r *reader) bytes() []byte {
+	n := r.int()
+	if n < 0 {
+		return nil
+	}
+
+	return append([]byte{}, r.take(int(n))...)
+}
+
+// skipType reads past a column type.
+func (r *reader) skipType() {
+	switch id := r.short(); id {
+	case 0x0000: // Custom
+		r.string()
+	case 0x0020, 0x0022: // List, set
+		r.skipType()
+	case 0x0021: // Map
+		r.skipType()
+		r.skipType()
+	default:
+		if id > 0x0022 && r.e == nil {
+			r.e = fmt.Errorf("Unsupported column type 0x%04x", id)
+		}
+	}
+}
+
+func bigint(v int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+func intValue(v int32) []byte {
+	b := &buffer{}
+	b.int(v)
+	return b.b
+}
+
+// toInt64 decodes a bigint or counter, or returns 0 if it's null.
+func toInt64(v []byte) int64 {
+	if len(v) != 8 {
+		return 0
+	}
+
+	return int64(binary.BigEndian.Uint64(v))
+}
+
+// toBool decodes a boolean, such as the [applied] column of lightweight transactions' results.
+func toBool(v []byte) bool {
+	return len(v) == 1 && v[0] != 0
+}
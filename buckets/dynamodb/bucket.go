@@ -0,0 +1,284 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package dynamodb implements token buckets whose state is kept in a DynamoDB table, so that all
+// instances sharing the table enforce a single limit, and limits survive restarts. Tokens are
+// claimed optimistically: each claim reads the bucket's item, and writes it back on condition that
+// no other claim has written it since, retrying if one has.
+package dynamodb
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// defaultMaxAttempts is how many attempts are made at claiming tokens if the config doesn't say.
+const defaultMaxAttempts = 5
+
+// Attributes of bucket items. The table's partition key is the string attribute idAttr.
+const (
+	idAttr = "id"
+	// tokensNextAvailableNanos and accumulatedTokens, as in the Redis implementation.
+	tnaAttr    = "tna"
+	tokensAttr = "tokens"
+	// Incremented on every write, so that writes can be made conditional on no others having been.
+	versionAttr = "version"
+	// Unix time, in seconds, the item may be deleted at, if buckets expire when idle. Enable
+	// DynamoDB's time to live on it to have idle buckets deleted.
+	expiresAttr = "expires"
+)
+
+type bucketFactory struct {
+	client      *client
+	table       string
+	maxAttempts int
+	clock       clock.Clock
+}
+
+// NewBucketFactory creates a factory whose buckets are kept in the table the config names.
+func NewBucketFactory(cfg *config.DynamoConfig) quotaservice.BucketFactory {
+	return newBucketFactory(cfg, newClient(cfg.Region, cfg.Endpoint, defaultCredentials(cfg.Region)), clock.System)
+}
+
+func newBucketFactory(cfg *config.DynamoConfig, c *client, clk clock.Clock) *bucketFactory {
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	return &bucketFactory{
+		client:      c,
+		table:       cfg.Table,
+		maxAttempts: maxAttempts,
+		clock:       clk}
+}
+
+func (bf *bucketFactory) Init(cfg *config.ServiceConfig) {}
+
+func (bf *bucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) quotaservice.Bucket {
+	if cfg.Algorithm != "" && cfg.Algorithm != config.TokenBucketAlgorithm {
+		logging.Printf("DynamoDB buckets don't support the %v algorithm. Bucket %v will be a token bucket.",
+			cfg.Algorithm, config.FullyQualifiedName(namespace, bucketName))
+	}
+
+	return &dynamoBucket{
+		dynamic:            dyn,
+		cfg:                cfg,
+		factory:            bf,
+		key:                item{idAttr: {S: config.FullyQualifiedName(namespace, bucketName)}},
+		nanosBetweenTokens: cfg.NanosPerToken(cfg.FillRate)}
+}
+
+// dynamoBucket is threadsafe since it delegates concurrency to DynamoDB's conditional writes.
+type dynamoBucket struct {
+	dynamic            bool
+	cfg                *config.BucketConfig
+	factory            *bucketFactory
+	key                item
+	nanosBetweenTokens int64
+}
+
+// state is a bucket's item, as read from the table.
+type state struct {
+	tokensNextAvailableNanos, accumulatedTokens, version int64
+}
+
+func (b *dynamoBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	return b.take(numTokens, maxWaitTime, b.cfg.MaxDebtMillis*1e6)
+}
+
+// TakeWithMaxDebt is Take, lending no more than maxDebt, or the bucket's max debt if that's lower.
+func (b *dynamoBucket) TakeWithMaxDebt(numTokens int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
+	maxDebtNanos := b.cfg.MaxDebtMillis * 1e6
+	if maxDebt.Nanoseconds() < maxDebtNanos {
+		maxDebtNanos = maxDebt.Nanoseconds()
+	}
+
+	return b.take(numTokens, maxWaitTime, maxDebtNanos)
+}
+
+func (b *dynamoBucket) take(numTokens int64, maxWaitTime time.Duration, maxDebtNanos int64) (time.Duration, bool) {
+	for attempt := 0; attempt < b.factory.maxAttempts; attempt++ {
+		nowNanos := b.factory.clock.Now().UnixNano()
+		s, e := b.read(nowNanos)
+		if e != nil {
+			logging.Warnf("Unable to read bucket %v from DynamoDB: %v", b.key[idAttr].S, e)
+			return 0, false
+		}
+
+		previousVersion := s.version
+		waitNanos, ok := b.claim(s, nowNanos, numTokens, maxWaitTime.Nanoseconds(), maxDebtNanos)
+		if !ok {
+			return 0, false
+		}
+
+		e = b.write(s, previousVersion, nowNanos)
+		if e == nil {
+			return time.Duration(waitNanos), true
+		}
+
+		if !conditionFailed(e) {
+			logging.Warnf("Unable to write bucket %v to DynamoDB: %v", b.key[idAttr].S, e)
+			return 0, false
+		}
+		// Another claim wrote the bucket since it was read, so try again with its state.
+	}
+
+	logging.Printf("Gave up claiming tokens from bucket %v after %v conflicting attempts",
+		b.key[idAttr].S, b.factory.maxAttempts)
+	return 0, false
+}
+
+// claim claims tokens from the state, as the Redis implementation's script does, returning how
+// long the caller has to wait for them, or false if it can't.
+func (b *dynamoBucket) claim(s *state, nowNanos, numTokens, maxWaitNanos, maxDebtNanos int64) (int64, bool) {
+	if nowNanos > s.tokensNextAvailableNanos {
+		fresh := (nowNanos - s.tokensNextAvailableNanos) / b.nanosBetweenTokens
+		s.accumulatedTokens += fresh
+		if s.accumulatedTokens > b.cfg.Size {
+			s.accumulatedTokens = b.cfg.Size
+		}
+		s.tokensNextAvailableNanos = nowNanos
+	}
+
+	waitNanos := s.tokensNextAvailableNanos - nowNanos
+	used := numTokens
+	if used > s.accumulatedTokens {
+		used = s.accumulatedTokens
+	}
+
+	s.tokensNextAvailableNanos += (numTokens - used) * b.nanosBetweenTokens
+	s.accumulatedTokens -= used
+
+	if s.tokensNextAvailableNanos-nowNanos > maxDebtNanos || waitNanos > maxWaitNanos {
+		return 0, false
+	}
+
+	return waitNanos, true
+}
+
+// read returns the bucket's state, or the state it starts out in if it has no item, or its item
+// has expired but not yet been deleted.
+func (b *dynamoBucket) read(nowNanos int64) (*state, error) {
+	resp := struct{ Item item }{}
+	e := b.factory.client.call("GetItem", map[string]interface{}{
+		"TableName":      b.factory.table,
+		"Key":            b.key,
+		"ConsistentRead": true}, &resp)
+	if e != nil {
+		return nil, e
+	}
+
+	s := &state{
+		tokensNextAvailableNanos: nowNanos,
+		accumulatedTokens:        b.cfg.InitialTokens(b.cfg.Size),
+		version:                  toInt64(resp.Item[versionAttr])}
+
+	if resp.Item == nil {
+		return s, nil
+	}
+
+	if expires := toInt64(resp.Item[expiresAttr]); expires > 0 && expires*int64(time.Second) < nowNanos {
+		return s, nil
+	}
+
+	s.tokensNextAvailableNanos = toInt64(resp.Item[tnaAttr])
+	s.accumulatedTokens = toInt64(resp.Item[tokensAttr])
+	return s, nil
+}
+
+// write writes the state to the bucket's item, on condition that it's still at previousVersion.
+func (b *dynamoBucket) write(s *state, previousVersion, nowNanos int64) error {
+	update := "SET #tna = :tna, #tokens = :tokens, #version = :version"
+	names := map[string]string{
+		"#id":      idAttr,
+		"#tna":     tnaAttr,
+		"#tokens":  tokensAttr,
+		"#version": versionAttr}
+	values := item{
+		":tna":      number(s.tokensNextAvailableNanos),
+		":tokens":   number(s.accumulatedTokens),
+		":version":  number(previousVersion + 1),
+		":previous": number(previousVersion)}
+
+	if b.cfg.MaxIdleMillis > 0 {
+		update += ", #expires = :expires"
+		names["#expires"] = expiresAttr
+		values[":expires"] = number((nowNanos + b.cfg.MaxIdleMillis*1e6) / int64(time.Second))
+	}
+
+	return b.factory.client.call("UpdateItem", map[string]interface{}{
+		"TableName":                 b.factory.table,
+		"Key":                       b.key,
+		"UpdateExpression":          update,
+		"ConditionExpression":       "attribute_not_exists(#id) OR #version = :previous",
+		"ExpressionAttributeNames":  names,
+		"ExpressionAttributeValues": values}, nil)
+}
+
+func number(v int64) attributeValue {
+	return attributeValue{N: strconv.FormatInt(v, 10)}
+}
+
+func toInt64(v attributeValue) int64 {
+	if v.N == "" {
+		return 0
+	}
+
+	n, e := strconv.ParseInt(v.N, 10, 64)
+	if e != nil {
+		logging.Warnf("Cannot convert '%v' to int64", v.N)
+	}
+	return n
+}
+
+func (b *dynamoBucket) Config() *config.BucketConfig {
+	return b.cfg
+}
+
+// Status reads the bucket's state from DynamoDB, as a claim would see it if tokens were claimed now.
+func (b *dynamoBucket) Status() *admin.BucketStatus {
+	nowNanos := b.factory.clock.Now().UnixNano()
+	s, e := b.read(nowNanos)
+	if e != nil {
+		logging.Warnf("Unable to read status of bucket %v from DynamoDB: %v", b.key[idAttr].S, e)
+		return &admin.BucketStatus{}
+	}
+
+	status := &admin.BucketStatus{Tokens: s.accumulatedTokens}
+	if nowNanos > s.tokensNextAvailableNanos {
+		status.LastFillMillis = s.tokensNextAvailableNanos / 1e6
+		status.Tokens += (nowNanos - s.tokensNextAvailableNanos) / b.nanosBetweenTokens
+		if status.Tokens > b.cfg.Size {
+			status.Tokens = b.cfg.Size
+		}
+	} else {
+		status.DebtMillis = (s.tokensNextAvailableNanos - nowNanos) / 1e6
+	}
+
+	return status
+}
+
+func (b *dynamoBucket) Dynamic() bool {
+	return b.dynamic
+}
+
+func (b *dynamoBucket) Destroy() {
+	// No-op
+}
+
+// EraseState deletes the bucket's item, so a bucket of the same name starts afresh.
+func (b *dynamoBucket) EraseState() {
+	e := b.factory.client.call("DeleteItem", map[string]interface{}{
+		"TableName": b.factory.table,
+		"Key":       b.key}, nil)
+	if e != nil {
+		logging.Warnf("Unable to erase state of bucket %v from DynamoDB: %v", b.key[idAttr].S, e)
+	}
+}
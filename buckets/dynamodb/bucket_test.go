@@ -0,0 +1,225 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package dynamodb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+// fakeDynamo serves the operations buckets use, keeping items in memory.
+type fakeDynamo struct {
+	sync.Mutex
+	items map[string]item
+	// Called before updates are applied, such as to simulate other instances' writes.
+	beforeUpdate func(id string)
+	// Headers of the last request.
+	headers http.Header
+}
+
+func newFakeDynamo() (*fakeDynamo, *httptest.Server) {
+	f := &fakeDynamo{items: make(map[string]item)}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeDynamo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Key                       item
+		ExpressionAttributeValues item
+	}{}
+	if e := json.NewDecoder(r.Body).Decode(&req); e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := req.Key[idAttr].S
+	op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), apiVersion+".")
+	if op == "UpdateItem" && f.beforeUpdate != nil {
+		f.beforeUpdate(id)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	f.headers = r.Header
+	switch op {
+	case "GetItem":
+		json.NewEncoder(w).Encode(map[string]item{"Item": f.items[id]})
+	case "UpdateItem":
+		values := req.ExpressionAttributeValues
+		if existing, exists := f.items[id]; exists && existing[versionAttr] != values[":previous"] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(apiError{Type: "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException"})
+			return
+		}
+
+		f.items[id] = item{
+			idAttr:      req.Key[idAttr],
+			tnaAttr:     values[":tna"],
+			tokensAttr:  values[":tokens"],
+			versionAttr: values[":version"],
+			expiresAttr: values[":expires"]}
+		w.Write([]byte("{}"))
+	case "DeleteItem":
+		delete(f.items, id)
+		w.Write([]byte("{}"))
+	default:
+		http.Error(w, "Unknown operation "+op, http.StatusBadRequest)
+	}
+}
+
+func newTestFactory(url string, now clock.Clock) *bucketFactory {
+	cfg := &config.DynamoConfig{Region: "us-east-1", Table: "buckets"}
+	c := newClient(cfg.Region, url, credentials{accessKey: "key", secretKey: "secret"})
+	return newBucketFactory(cfg, c, now)
+}
+
+func newTestBucketConfig() *config.BucketConfig {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	cfg.FillRate = 10
+	cfg.MaxDebtMillis = 1000
+	return cfg
+}
+
+func TestTake(t *testing.T) {
+	f, server := newFakeDynamo()
+	defer server.Close()
+
+	now := clock.NewFake(time.Unix(100, 0))
+	cfg := newTestBucketConfig()
+
+	// Two instances sharing the table share the limit.
+	b1 := newTestFactory(server.URL, now).NewBucket("ns", "b", cfg, false).(*dynamoBucket)
+	b2 := newTestFactory(server.URL, now).NewBucket("ns", "b", cfg, false).(*dynamoBucket)
+
+	if w, ok := b1.Take(6, 0); !ok || w != 0 {
+		t.Fatalf("Expecting tokens straight away. Got %v, %v", w, ok)
+	}
+
+	// Tokens are lent, as they are by other implementations, making the next claim wait.
+	if w, ok := b2.Take(5, 0); !ok || w != 0 {
+		t.Fatalf("Expecting the missing token to be lent. Got %v, %v", w, ok)
+	}
+
+	if _, ok := b1.Take(1, 0); ok {
+		t.Fatal("Expecting tokens claimed by other instances to be gone")
+	}
+
+	if w, ok := b1.Take(1, time.Second); !ok || w != 100*time.Millisecond {
+		t.Fatalf("Expecting to wait for the lent token. Got %v, %v", w, ok)
+	}
+
+	if s := b2.Status(); s.Tokens != 0 || s.DebtMillis != 200 {
+		t.Fatalf("Unexpected status %+v", s)
+	}
+
+	if _, ok := b1.Take(20, time.Hour); ok {
+		t.Fatal("Expecting claims beyond the max debt to be refused")
+	}
+
+	now.Advance(time.Second)
+	if s := b2.Status(); s.Tokens != 8 {
+		t.Fatalf("Expecting the bucket to fill over time. Status %+v", s)
+	}
+
+	if _, exists := f.items["ns:b"]; !exists {
+		t.Fatal("Expecting the bucket's state in the table")
+	}
+
+	b1.EraseState()
+	if _, exists := f.items["ns:b"]; exists {
+		t.Fatal("Expecting the bucket's state to be erased")
+	}
+}
+
+func TestConflictingTakes(t *testing.T) {
+	f, server := newFakeDynamo()
+	defer server.Close()
+
+	now := clock.NewFake(time.Unix(100, 0))
+	b := newTestFactory(server.URL, now).NewBucket("ns", "b", newTestBucketConfig(), false).(*dynamoBucket)
+	other := newTestFactory(server.URL, now).NewBucket("ns", "b", newTestBucketConfig(), false).(*dynamoBucket)
+
+	// Another instance claims tokens between the bucket's read and write.
+	conflicts := 0
+	f.beforeUpdate = func(string) {
+		if conflicts == 0 {
+			conflicts++
+			other.Take(5, 0)
+		}
+	}
+
+	if _, ok := b.Take(5, 0); !ok {
+		t.Fatal("Expecting conflicting claims to be retried")
+	}
+
+	f.beforeUpdate = nil
+	if s := b.Status(); s.Tokens != 0 {
+		t.Fatalf("Expecting both claims to count. Status %+v", s)
+	}
+
+	// Claims give up once they've conflicted max attempts times.
+	f.beforeUpdate = func(id string) {
+		f.Lock()
+		defer f.Unlock()
+		f.items[id][versionAttr] = number(toInt64(f.items[id][versionAttr]) + 1)
+	}
+
+	now.Advance(time.Second)
+	if _, ok := b.Take(1, 0); ok {
+		t.Fatal("Expecting claims to give up after too many conflicts")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	f, server := newFakeDynamo()
+	defer server.Close()
+
+	now := clock.NewFake(time.Unix(100, 0))
+	cfg := newTestBucketConfig()
+	cfg.MaxIdleMillis = 10000
+	b := newTestFactory(server.URL, now).NewBucket("ns", "b", cfg, false).(*dynamoBucket)
+
+	b.Take(10, 0)
+	if e := toInt64(f.items["ns:b"][expiresAttr]); e != 110 {
+		t.Fatalf("Expecting items to expire once idle. Expires %v", e)
+	}
+
+	// Expired items read as new buckets, even if they haven't been deleted yet.
+	now.Advance(11 * time.Second)
+	if _, ok := b.Take(10, 0); !ok {
+		t.Fatal("Expecting expired buckets to start afresh")
+	}
+}
+
+func TestSigning(t *testing.T) {
+	f, server := newFakeDynamo()
+	defer server.Close()
+
+	bf := newTestFactory(server.URL, clock.NewFake(time.Unix(100, 0)))
+	bf.client.now = func() time.Time { return time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC) }
+	bf.NewBucket("ns", "b", newTestBucketConfig(), false).(*dynamoBucket).Status()
+
+	if d := f.headers.Get("X-Amz-Date"); d != "20160501T120000Z" {
+		t.Fatalf("Unexpected date %v", d)
+	}
+
+	if target := f.headers.Get("X-Amz-Target"); target != "DynamoDB_20120810.GetItem" {
+		t.Fatalf("Unexpected target %v", target)
+	}
+
+	prefix := "AWS4-HMAC-SHA256 Credential=key/20160501/us-east-1/dynamodb/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-target, Signature="
+	if auth := f.headers.Get("Authorization"); !strings.HasPrefix(auth, prefix) || len(auth) != len(prefix)+64 {
+		t.Fatalf("Unexpected authorization %v", auth)
+	}
+}
@@ -0,0 +1,179 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package dynamodb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// apiVersion prefixes the operations named in the X-Amz-Target header.
+	apiVersion = "DynamoDB_20120810"
+	// signingService is the service requests are signed for.
+	signingService = "dynamodb"
+	// amzDateLayout is the layout of the timestamps requests are signed with.
+	amzDateLayout = "20060102T150405Z"
+)
+
+// attributeValue is an attribute of an item, as the DynamoDB JSON API represents it. Only string
+// and number attributes are used.
+type attributeValue struct {
+	S string `json:"S,omitempty"`
+	N string `json:"N,omitempty"`
+}
+
+type item map[string]attributeValue
+
+// apiError is an error returned by the DynamoDB API.
+type apiError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("DynamoDB error %v: %v", e.Type, e.Message)
+}
+
+// conditionFailed tells whether the error is a write's condition not holding, such as when another
+// instance wrote to the item first.
+func conditionFailed(e error) bool {
+	a, ok := e.(*apiError)
+	return ok && strings.HasSuffix(a.Type, "#ConditionalCheckFailedException")
+}
+
+// client calls the DynamoDB JSON API, signing requests with AWS Signature Version 4.
+type client struct {
+	endpoint, region string
+	creds            credentialsProvider
+	http             *http.Client
+	// Tells the time requests are signed at.
+	now func() time.Time
+}
+
+func newClient(region, endpoint string, creds credentialsProvider) *client {
+	if endpoint == "" {
+		endpoint = "https://dynamodb." + region + ".amazonaws.com"
+	}
+
+	return &client{
+		endpoint: endpoint,
+		region:   region,
+		creds:    creds,
+		http:     &http.Client{Timeout: 5 * time.Second},
+		now:      time.Now}
+}
+
+// call invokes the operation with the request, decoding the response into out.
+func (c *client) call(operation string, in, out interface{}) error {
+	body, e := json.Marshal(in)
+	if e != nil {
+		return e
+	}
+
+	req, e := http.NewRequest("POST", c.endpoint, bytes.NewReader(body))
+	if e != nil {
+		return e
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", apiVersion+"."+operation)
+	creds, e := c.creds.retrieve()
+	if e != nil {
+		return e
+	}
+	c.sign(req, body, creds)
+
+	resp, e := c.http.Do(req)
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+
+	respBody, e := ioutil.ReadAll(resp.Body)
+	if e != nil {
+		return e
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		a := &apiError{}
+		if json.Unmarshal(respBody, a) != nil || a.Type == "" {
+			return fmt.Errorf("DynamoDB returned %v: %s", resp.Status, respBody)
+		}
+		return a
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// sign adds the headers authenticating the request with creds to it.
+func (c *client) sign(req *http.Request, body []byte, creds credentials) {
+	t := c.now().UTC()
+	amzDate := t.Format(amzDateLayout)
+	date := amzDate[:8]
+
+	u, _ := url.Parse(c.endpoint)
+	req.Header.Set("Host", u.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	// Every header set is signed, in lower case, sorted by name.
+	names := []string{"content-type", "host", "x-amz-date"}
+	if creds.sessionToken != "" {
+		names = append(names, "x-amz-security-token")
+	}
+	names = append(names, "x-amz-target")
+
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		"POST", path, "", canonicalHeaders.String(), signedHeaders, hexSHA256(body)}, "\n")
+	scope := strings.Join([]string{date, c.region, signingService, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), date)
+	for _, s := range []string{c.region, signingService, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+func hexSHA256(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
@@ -0,0 +1,278 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package dynamodb
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// imdsEndpoint is the EC2 instance metadata service.
+	imdsEndpoint = "http://169.254.169.254"
+	// containerEndpoint serves credentials to ECS tasks, at the path they're given.
+	containerEndpoint = "http://169.254.170.2"
+	// credentialsExpiryWindow is how long before they expire credentials are refreshed.
+	credentialsExpiryWindow = 5 * time.Minute
+)
+
+// credentials sign requests. Those that don't expire have a zero expiry.
+type credentials struct {
+	accessKey, secretKey, sessionToken string
+	expires                            time.Time
+}
+
+// retrieve implements credentialsProvider, for credentials that never change.
+func (c credentials) retrieve() (credentials, error) {
+	return c, nil
+}
+
+// credentialsProvider provides the credentials requests are signed with.
+type credentialsProvider interface {
+	retrieve() (credentials, error)
+}
+
+// defaultCredentials finds credentials where the AWS SDKs do, in order: the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables; a web identity token, as EKS
+// provides to service accounts with IAM roles (IRSA), exchanged for the AWS_ROLE_ARN role's; ECS'
+// or EKS Pod Identity's container credentials; and otherwise the EC2 instance profile's.
+func defaultCredentials(region string) credentialsProvider {
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		return credentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN")}
+	}
+
+	h := &http.Client{Timeout: 5 * time.Second}
+	if tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); tokenFile != "" {
+		sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+		if sessionName == "" {
+			sessionName = fmt.Sprintf("quotaservice-%v", time.Now().UnixNano())
+		}
+
+		return newRefreshingCredentials(func() (credentials, error) {
+			return webIdentityCredentials(h, stsEndpoint(region), tokenFile, os.Getenv("AWS_ROLE_ARN"), sessionName)
+		})
+	}
+
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return newRefreshingCredentials(func() (credentials, error) {
+			return containerCredentials(h, containerEndpoint+uri)
+		})
+	}
+
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		return newRefreshingCredentials(func() (credentials, error) {
+			return containerCredentials(h, uri)
+		})
+	}
+
+	return newRefreshingCredentials(func() (credentials, error) {
+		return instanceCredentials(h, imdsEndpoint)
+	})
+}
+
+// refreshingCredentials caches credentials fetched from elsewhere, fetching them again shortly
+// before they expire.
+type refreshingCredentials struct {
+	sync.Mutex
+	fetch  func() (credentials, error)
+	cached credentials
+	// Tells the time credentials are checked for expiry at.
+	now func() time.Time
+}
+
+func newRefreshingCredentials(fetch func() (credentials, error)) *refreshingCredentials {
+	return &refreshingCredentials{fetch: fetch, now: time.Now}
+}
+
+func (r *refreshingCredentials) retrieve() (credentials, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.cached.accessKey != "" && (r.cached.expires.IsZero() || r.now().Add(credentialsExpiryWindow).Before(r.cached.expires)) {
+		return r.cached, nil
+	}
+
+	c, e := r.fetch()
+	if e != nil {
+		return credentials{}, fmt.Errorf("Unable to fetch AWS credentials: %v", e)
+	}
+
+	r.cached = c
+	return c, nil
+}
+
+// metadataCredentials are credentials as the instance metadata service and container credentials
+// endpoints represent them.
+type metadataCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+func (m *metadataCredentials) credentials() credentials {
+	return credentials{
+		accessKey:    m.AccessKeyID,
+		secretKey:    m.SecretAccessKey,
+		sessionToken: m.Token,
+		expires:      m.Expiration}
+}
+
+// instanceCredentials fetches the credentials of the EC2 instance's profile from the instance
+// metadata service at endpoint, using IMDSv2's session tokens.
+func instanceCredentials(h *http.Client, endpoint string) (credentials, error) {
+	req, _ := http.NewRequest("PUT", endpoint+"/latest/api/token", nil)
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, e := fetchBody(h, req)
+	if e != nil {
+		return credentials{}, e
+	}
+
+	path := endpoint + "/latest/meta-data/iam/security-credentials/"
+	req, _ = http.NewRequest("GET", path, nil)
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	roles, e := fetchBody(h, req)
+	if e != nil {
+		return credentials{}, e
+	}
+
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return credentials{}, fmt.Errorf("Instance has no IAM role")
+	}
+
+	req, _ = http.NewRequest("GET", path+role, nil)
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	return decodeMetadataCredentials(h, req)
+}
+
+// containerCredentials fetches the credentials of an ECS task, or of an EKS pod with Pod Identity,
+// from uri, authorizing the request with the token the container is given, if any.
+func containerCredentials(h *http.Client, uri string) (credentials, error) {
+	req, e := http.NewRequest("GET", uri, nil)
+	if e != nil {
+		return credentials{}, e
+	}
+
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		b, e := ioutil.ReadFile(tokenFile)
+		if e != nil {
+			return credentials{}, e
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	return decodeMetadataCredentials(h, req)
+}
+
+func decodeMetadataCredentials(h *http.Client, req *http.Request) (credentials, error) {
+	b, e := fetchBody(h, req)
+	if e != nil {
+		return credentials{}, e
+	}
+
+	m := &metadataCredentials{}
+	if e := json.Unmarshal(b, m); e != nil {
+		return credentials{}, e
+	}
+
+	if m.AccessKeyID == "" {
+		return credentials{}, fmt.Errorf("No credentials in response from %v", req.URL)
+	}
+
+	return m.credentials(), nil
+}
+
+// fetchBody makes the request, returning the body of its response.
+func fetchBody(h *http.Client, req *http.Request) ([]byte, error) {
+	resp, e := h.Do(req)
+	if e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+
+	b, e := ioutil.ReadAll(resp.Body)
+	if e != nil {
+		return nil, e
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v %v returned %v: %s", req.Method, req.URL, resp.Status, b)
+	}
+
+	return b, nil
+}
+
+func stsEndpoint(region string) string {
+	return "https://sts." + region + ".amazonaws.com"
+}
+
+// assumeRoleWithWebIdentityResponse is the part of STS' response to AssumeRoleWithWebIdentity
+// holding the role's credentials.
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// webIdentityCredentials exchanges the web identity token in tokenFile for the credentials of
+// role, with STS at endpoint. The token is read afresh each time, since it's rotated.
+func webIdentityCredentials(h *http.Client, endpoint, tokenFile, role, sessionName string) (credentials, error) {
+	token, e := ioutil.ReadFile(tokenFile)
+	if e != nil {
+		return credentials{}, e
+	}
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {role},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))}}
+	req, e := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if e != nil {
+		return credentials{}, e
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	b, e := fetchBody(h, req)
+	if e != nil {
+		return credentials{}, e
+	}
+
+	r := &assumeRoleWithWebIdentityResponse{}
+	if e := xml.Unmarshal(b, r); e != nil {
+		return credentials{}, e
+	}
+
+	c := r.Credentials
+	if c.AccessKeyID == "" {
+		return credentials{}, fmt.Errorf("No credentials in response from %v", endpoint)
+	}
+
+	return credentials{
+		accessKey:    c.AccessKeyID,
+		secretKey:    c.SecretAccessKey,
+		sessionToken: c.SessionToken,
+		expires:      c.Expiration}, nil
+}
@@ -0,0 +1,148 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package dynamodb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const metadataCredentialsJSON = `{"AccessKeyId": "key", "SecretAccessKey": "secret", "Token": "token", "Expiration": "2016-05-01T12:00:00Z"}`
+
+var expectedCredentials = credentials{
+	accessKey:    "key",
+	secretKey:    "secret",
+	sessionToken: "token",
+	expires:      time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)}
+
+func TestInstanceCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			if r.Method != "PUT" || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("session"))
+			return
+		}
+
+		if r.Header.Get("X-aws-ec2-metadata-token") != "session" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("role\n"))
+		case "/latest/meta-data/iam/security-credentials/role":
+			w.Write([]byte(metadataCredentialsJSON))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, e := instanceCredentials(http.DefaultClient, server.URL)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if c != expectedCredentials {
+		t.Fatalf("Unexpected credentials %+v", c)
+	}
+}
+
+func TestContainerCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/creds" || r.Header.Get("Authorization") != "secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(metadataCredentialsJSON))
+	}))
+	defer server.Close()
+
+	defer os.Unsetenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if _, e := containerCredentials(http.DefaultClient, server.URL+"/creds"); e == nil {
+		t.Fatal("Expecting unauthorized requests for credentials to fail")
+	}
+
+	os.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "secret-token")
+	c, e := containerCredentials(http.DefaultClient, server.URL+"/creds")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if c != expectedCredentials {
+		t.Fatalf("Unexpected credentials %+v", c)
+	}
+}
+
+func TestWebIdentityCredentials(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_web_identity")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte("jwt\n"), 0600)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRoleWithWebIdentity" || r.Form.Get("RoleArn") != "arn:aws:iam::123:role/qs" ||
+			r.Form.Get("RoleSessionName") != "session" || r.Form.Get("WebIdentityToken") != "jwt" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>key</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2016-05-01T12:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer server.Close()
+
+	c, e := webIdentityCredentials(http.DefaultClient, server.URL, tokenFile, "arn:aws:iam::123:role/qs", "session")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if c != expectedCredentials {
+		t.Fatalf("Unexpected credentials %+v", c)
+	}
+}
+
+func TestRefreshingCredentials(t *testing.T) {
+	fetches := 0
+	r := newRefreshingCredentials(func() (credentials, error) {
+		fetches++
+		return expectedCredentials, nil
+	})
+
+	now := expectedCredentials.expires.Add(-time.Hour)
+	r.now = func() time.Time { return now }
+	r.retrieve()
+	r.retrieve()
+	if fetches != 1 {
+		t.Fatalf("Expecting credentials to be cached until they're about to expire. Fetched %v times", fetches)
+	}
+
+	now = expectedCredentials.expires.Add(-time.Minute)
+	r.retrieve()
+	if fetches != 2 {
+		t.Fatalf("Expecting credentials to be refreshed before they expire. Fetched %v times", fetches)
+	}
+}
@@ -9,12 +9,15 @@ import (
 	"time"
 
 	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
 	"github.com/maniksurtani/quotaservice/config"
 	"github.com/maniksurtani/quotaservice/logging"
 )
 
 type bucketFactory struct {
-	cfg *config.ServiceConfig
+	cfg   *config.ServiceConfig
+	clock clock.Clock
 }
 
 func (bf *bucketFactory) Init(cfg *config.ServiceConfig) {
@@ -22,15 +25,32 @@ func (bf *bucketFactory) Init(cfg *config.ServiceConfig) {
 }
 
 func (bf *bucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) quotaservice.Bucket {
-	// fill rate is tokens-per-second.
+	switch cfg.Algorithm {
+	case config.SlidingWindowAlgorithm:
+		return newSlidingWindow(cfg, dyn, bf.clock)
+	case config.GCRAAlgorithm:
+		return newGCRA(cfg, dyn, bf.clock)
+	case config.ConcurrencyAlgorithm:
+		return newConcurrency(cfg, dyn, bf.clock)
+	}
+
+	// fill rate is tokens per fill period, a second unless configured otherwise.
+	nowNanos := bf.clock.Now().UnixNano()
 	bucket := &tokenBucket{
-		dynamic:            dyn,
-		cfg:                cfg,
-		nanosBetweenTokens: 1e9 / cfg.FillRate,
-		accumulatedTokens:  cfg.Size, // Start full
-		fullName:           config.FullyQualifiedName(namespace, bucketName),
-		waitTimer:          make(chan *waitTimeReq),
-		closer:             make(chan struct{})}
+		dynamic:                  dyn,
+		cfg:                      cfg,
+		clock:                    bf.clock,
+		nanosBetweenTokens:       cfg.NanosPerToken(cfg.FillRate),
+		tokensNextAvailableNanos: nowNanos,
+		accumulatedTokens:        cfg.InitialTokens(cfg.Size), // Start full, unless configured otherwise
+		lastFillNanos:            nowNanos,
+		fullName:                 config.FullyQualifiedName(namespace, bucketName),
+		waitTimer:                make(chan *waitTimeReq),
+		statusReq:                make(chan chan *admin.BucketStatus),
+		returns:                  make(chan int64),
+		fillRates:                make(chan int64),
+		restores:                 make(chan *restoreReq),
+		closer:                   make(chan struct{})}
 
 	go bucket.waitTimeLoop()
 
@@ -38,7 +58,13 @@ func (bf *bucketFactory) NewBucket(namespace, bucketName string, cfg *config.Buc
 }
 
 func NewBucketFactory() quotaservice.BucketFactory {
-	return &bucketFactory{}
+	return NewBucketFactoryWithClock(clock.System)
+}
+
+// NewBucketFactoryWithClock creates a factory whose buckets fill, and expire leases, by the given
+// clock, such as a clock.Fake in tests.
+func NewBucketFactoryWithClock(c clock.Clock) quotaservice.BucketFactory {
+	return &bucketFactory{clock: c}
 }
 
 // tokenBucket is a single-threaded implementation. A single goroutine updates the values of
@@ -49,24 +75,42 @@ func NewBucketFactory() quotaservice.BucketFactory {
 type tokenBucket struct {
 	dynamic bool
 	cfg     *config.BucketConfig
+	clock   clock.Clock
 	nanosBetweenTokens,
 	tokensNextAvailableNanos,
 	accumulatedTokens int64
-	fullName  string
-	waitTimer chan *waitTimeReq
-	closer    chan struct{}
+	// When tokens were last added to accumulatedTokens.
+	lastFillNanos int64
+	fullName      string
+	waitTimer     chan *waitTimeReq
+	statusReq     chan chan *admin.BucketStatus
+	returns       chan int64
+	fillRates     chan int64
+	restores      chan *restoreReq
+	closer        chan struct{}
+}
+
+// restoreReq is a request to restore the bucket to the level a snapshot taken at atNanos recorded.
+type restoreReq struct {
+	snapshot *quotaservice.BucketSnapshot
+	atNanos  int64
 }
 
 // waitTimeReq is a request that you put on the channel for the waitTimer goroutine to pick up and
 // process.
 type waitTimeReq struct {
-	requested, maxWaitTimeNanos int64
-	response                    chan int64
+	requested, maxWaitTimeNanos, maxDebtNanos int64
+	response                                  chan int64
 }
 
 func (b *tokenBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	return b.TakeWithMaxDebt(numTokens, maxWaitTime, time.Duration(b.cfg.MaxDebtMillis)*time.Millisecond)
+}
+
+// TakeWithMaxDebt is Take, lending no more than maxDebt, or the bucket's max debt if that's lower.
+func (b *tokenBucket) TakeWithMaxDebt(numTokens int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
 	rsp := make(chan int64, 1)
-	b.waitTimer <- &waitTimeReq{numTokens, maxWaitTime.Nanoseconds(), rsp}
+	b.waitTimer <- &waitTimeReq{numTokens, maxWaitTime.Nanoseconds(), maxDebt.Nanoseconds(), rsp}
 	waitTimeNanos := <-rsp
 
 	if waitTimeNanos < 0 {
@@ -78,8 +122,8 @@ func (b *tokenBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Dur
 }
 
 // calcWaitTime is designed to run in a single event loop and is not thread-safe.
-func (b *tokenBucket) calcWaitTime(requested, maxWaitTimeNanos int64) (waitTimeNanos int64) {
-	currentTimeNanos := time.Now().UnixNano()
+func (b *tokenBucket) calcWaitTime(requested, maxWaitTimeNanos, maxDebtNanos int64) (waitTimeNanos int64) {
+	currentTimeNanos := b.clock.Now().UnixNano()
 	tna := b.tokensNextAvailableNanos
 	ac := b.accumulatedTokens
 
@@ -91,6 +135,10 @@ func (b *tokenBucket) calcWaitTime(requested, maxWaitTimeNanos int64) (waitTimeN
 		tna = currentTimeNanos
 	}
 
+	if freshTokens > 0 {
+		b.lastFillNanos = currentTimeNanos
+	}
+
 	waitTimeNanos = tna - currentTimeNanos
 	accumulatedTokensUsed := min(ac, requested)
 	tokensToWaitFor := requested - accumulatedTokensUsed
@@ -99,7 +147,11 @@ func (b *tokenBucket) calcWaitTime(requested, maxWaitTimeNanos int64) (waitTimeN
 	tna += futureWaitNanos
 	ac -= accumulatedTokensUsed
 
-	if (tna-currentTimeNanos > b.cfg.MaxDebtMillis*1e6) || (waitTimeNanos > 0 && waitTimeNanos > maxWaitTimeNanos) {
+	if maxDebtNanos > b.cfg.MaxDebtMillis*1e6 {
+		maxDebtNanos = b.cfg.MaxDebtMillis * 1e6
+	}
+
+	if (tna-currentTimeNanos > maxDebtNanos) || (waitTimeNanos > 0 && waitTimeNanos > maxWaitTimeNanos) {
 		waitTimeNanos = -1
 	} else {
 		b.tokensNextAvailableNanos = tna
@@ -109,6 +161,66 @@ func (b *tokenBucket) calcWaitTime(requested, maxWaitTimeNanos int64) (waitTimeN
 	return waitTimeNanos
 }
 
+// calcStatus is designed to run in the same event loop as calcWaitTime, and is not thread-safe.
+func (b *tokenBucket) calcStatus() *admin.BucketStatus {
+	currentTimeNanos := b.clock.Now().UnixNano()
+	s := &admin.BucketStatus{Tokens: b.accumulatedTokens, LastFillMillis: b.lastFillNanos / 1e6}
+
+	if currentTimeNanos > b.tokensNextAvailableNanos {
+		// Tokens that would be added if any were claimed now.
+		freshTokens := (currentTimeNanos - b.tokensNextAvailableNanos) / b.nanosBetweenTokens
+		s.Tokens = min(b.cfg.Size, s.Tokens+freshTokens)
+	} else {
+		s.DebtMillis = (b.tokensNextAvailableNanos - currentTimeNanos) / 1e6
+	}
+
+	return s
+}
+
+// returnTokens is designed to run in the same event loop as calcWaitTime, and is not thread-safe.
+// Returned tokens pay back any debt first.
+func (b *tokenBucket) returnTokens(numTokens int64) {
+	currentTimeNanos := b.clock.Now().UnixNano()
+	if b.tokensNextAvailableNanos > currentTimeNanos {
+		debt := (b.tokensNextAvailableNanos - currentTimeNanos + b.nanosBetweenTokens - 1) / b.nanosBetweenTokens
+		repaid := min(numTokens, debt)
+		b.tokensNextAvailableNanos -= repaid * b.nanosBetweenTokens
+		if b.tokensNextAvailableNanos < currentTimeNanos {
+			b.tokensNextAvailableNanos = currentTimeNanos
+		}
+		numTokens -= repaid
+	}
+
+	b.accumulatedTokens = min(b.cfg.Size, b.accumulatedTokens+numTokens)
+}
+
+// setFillRate is designed to run in the same event loop as calcWaitTime, and is not thread-safe.
+// Tokens accumulated at the old rate are added to the bucket first.
+func (b *tokenBucket) setFillRate(fillRate int64) {
+	currentTimeNanos := b.clock.Now().UnixNano()
+	if currentTimeNanos > b.tokensNextAvailableNanos {
+		freshTokens := (currentTimeNanos - b.tokensNextAvailableNanos) / b.nanosBetweenTokens
+		b.accumulatedTokens = min(b.cfg.Size, b.accumulatedTokens+freshTokens)
+		b.tokensNextAvailableNanos = currentTimeNanos
+	}
+
+	b.nanosBetweenTokens = b.cfg.NanosPerToken(fillRate)
+}
+
+// restoreState is designed to run in the same event loop as calcWaitTime, and is not thread-safe.
+// Buckets in debt were empty, with tokens claimed until the debt was due.
+func (b *tokenBucket) restoreState(s *quotaservice.BucketSnapshot, atNanos int64) {
+	if s.DebtMillis > 0 {
+		b.accumulatedTokens = 0
+		b.tokensNextAvailableNanos = atNanos + s.DebtMillis*1e6
+	} else {
+		b.accumulatedTokens = min(b.cfg.Size, s.Tokens)
+		b.tokensNextAvailableNanos = atNanos
+	}
+
+	b.lastFillNanos = s.LastFillMillis * 1e6
+}
+
 func min(x, y int64) int64 {
 	if x < y {
 		return x
@@ -121,7 +233,15 @@ func (b *tokenBucket) waitTimeLoop() {
 	for {
 		select {
 		case req := <-b.waitTimer:
-			req.response <- b.calcWaitTime(req.requested, req.maxWaitTimeNanos)
+			req.response <- b.calcWaitTime(req.requested, req.maxWaitTimeNanos, req.maxDebtNanos)
+		case rsp := <-b.statusReq:
+			rsp <- b.calcStatus()
+		case n := <-b.returns:
+			b.returnTokens(n)
+		case r := <-b.fillRates:
+			b.setFillRate(r)
+		case r := <-b.restores:
+			b.restoreState(r.snapshot, r.atNanos)
 		case <-b.closer:
 			logging.Printf("Garbage collecting bucket %v", b.fullName)
 			// TODO(manik) properly notify goroutines who are currently trying to write to waitTimer
@@ -134,6 +254,41 @@ func (b *tokenBucket) Config() *config.BucketConfig {
 	return b.cfg
 }
 
+// Status returns the bucket's state, or an empty status if the bucket has been destroyed.
+func (b *tokenBucket) Status() *admin.BucketStatus {
+	rsp := make(chan *admin.BucketStatus, 1)
+	select {
+	case b.statusReq <- rsp:
+		return <-rsp
+	case <-b.closer:
+		return &admin.BucketStatus{}
+	}
+}
+
+// ReturnTokens puts tokens back in the bucket, unless it has been destroyed.
+func (b *tokenBucket) ReturnTokens(numTokens int64) {
+	select {
+	case b.returns <- numTokens:
+	case <-b.closer:
+	}
+}
+
+// SetFillRate changes the bucket's fill rate, unless it has been destroyed.
+func (b *tokenBucket) SetFillRate(fillRate int64) {
+	select {
+	case b.fillRates <- fillRate:
+	case <-b.closer:
+	}
+}
+
+// RestoreState sets the bucket to the level the snapshot recorded, unless it has been destroyed.
+func (b *tokenBucket) RestoreState(s *quotaservice.BucketSnapshot, at time.Time) {
+	select {
+	case b.restores <- &restoreReq{s, at.UnixNano()}:
+	case <-b.closer:
+	}
+}
+
 func (b *tokenBucket) Dynamic() bool {
 	return b.dynamic
 }
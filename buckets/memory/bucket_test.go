@@ -6,8 +6,11 @@ package memory
 import (
 	"os"
 	"testing"
+	"time"
 
+	"github.com/maniksurtani/quotaservice"
 	"github.com/maniksurtani/quotaservice/buckets"
+	"github.com/maniksurtani/quotaservice/clock"
 	"github.com/maniksurtani/quotaservice/config"
 )
 
@@ -28,6 +31,201 @@ func TestTokenAcquisition(t *testing.T) {
 	buckets.TestTokenAcquisition(t, bucket)
 }
 
+func TestStatus(t *testing.T) {
+	bucket := factory.NewBucket("memory", "status", buckets.NewStatusTestBucketConfig(), false)
+	buckets.TestStatus(t, bucket)
+}
+
 func TestGC(t *testing.T) {
 	buckets.TestGC(t, factory, "memory")
 }
+
+func TestReturnTokens(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	cfg.FillRate = 1
+	b := factory.NewBucket("memory", "returns", cfg, false).(*tokenBucket)
+	defer b.Destroy()
+
+	if _, ok := b.Take(10, 0); !ok {
+		t.Fatal("Expecting a full bucket")
+	}
+
+	b.ReturnTokens(4)
+	if s := b.Status(); s.Tokens != 4 {
+		t.Fatalf("Expecting returned tokens to be back in the bucket. Status %+v", s)
+	}
+
+	// Borrows 2 tokens from the future.
+	if _, ok := b.Take(6, 0); !ok {
+		t.Fatal("Expecting tokens to be lent")
+	}
+
+	b.ReturnTokens(2)
+	if s := b.Status(); s.Tokens != 0 || s.DebtMillis != 0 {
+		t.Fatalf("Expecting returned tokens to pay back debt first. Status %+v", s)
+	}
+
+	b.ReturnTokens(20)
+	if s := b.Status(); s.Tokens != 10 {
+		t.Fatalf("Expecting returned tokens to fill the bucket no further than its size. Status %+v", s)
+	}
+}
+
+func TestInitialFill(t *testing.T) {
+	for _, algorithm := range []string{config.TokenBucketAlgorithm, config.GCRAAlgorithm, config.SlidingWindowAlgorithm} {
+		cfg := config.NewDefaultBucketConfig()
+		cfg.Algorithm = algorithm
+		cfg.Size = 10
+		cfg.FillRate = 1
+		percent := int64(40)
+		cfg.InitialFillPercent = &percent
+		b := factory.NewBucket("memory", "initial_"+algorithm, cfg, false)
+		if s := b.(quotaservice.StatusReporter).Status(); s.Tokens != 4 {
+			t.Fatalf("Expecting %v buckets to start 40%% full. Status %+v", algorithm, s)
+		}
+
+		percent = 0
+		empty := factory.NewBucket("memory", "empty_"+algorithm, cfg, false)
+		if s := empty.(quotaservice.StatusReporter).Status(); s.Tokens != 0 {
+			t.Fatalf("Expecting %v buckets to start empty. Status %+v", algorithm, s)
+		}
+
+		b.Destroy()
+		empty.Destroy()
+	}
+}
+
+func TestFillByClock(t *testing.T) {
+	c := clock.NewFake(time.Unix(100, 0))
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	cfg.FillRate = 2
+	b := NewBucketFactoryWithClock(c).NewBucket("memory", "clock", cfg, false).(*tokenBucket)
+	defer b.Destroy()
+
+	b.Take(11, 0)
+	if w, ok := b.Take(1, time.Second); !ok || w != 500*time.Millisecond {
+		t.Fatalf("Expecting to wait for the token lent by the clock. Wait %v, success %v", w, ok)
+	}
+
+	// Pays back the two tokens lent, then fills two more.
+	c.Advance(2 * time.Second)
+	if s := b.Status(); s.Tokens != 2 || s.LastFillMillis != 100000 {
+		t.Fatalf("Expecting tokens to fill as the clock moves on. Status %+v", s)
+	}
+
+	if w, ok := b.Take(2, 0); !ok || w != 0 {
+		t.Fatalf("Expecting filled tokens to be claimable. Wait %v, success %v", w, ok)
+	}
+
+	if s := b.Status(); s.LastFillMillis != 102000 {
+		t.Fatalf("Expecting the last fill to be by the clock. Status %+v", s)
+	}
+}
+
+func TestFillPeriod(t *testing.T) {
+	c := clock.NewFake(time.Unix(100, 0))
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	cfg.FillRate = 10
+	cfg.FillPeriodMillis = 60000
+	b := NewBucketFactoryWithClock(c).NewBucket("memory", "per_minute", cfg, false).(*tokenBucket)
+	defer b.Destroy()
+
+	b.Take(10, 0)
+	c.Advance(5 * time.Second)
+	if s := b.Status(); s.Tokens != 0 {
+		t.Fatalf("Expecting no tokens before a tenth of a minute has passed. Status %+v", s)
+	}
+
+	c.Advance(55 * time.Second)
+	if s := b.Status(); s.Tokens != 10 {
+		t.Fatalf("Expecting 10 tokens a minute. Status %+v", s)
+	}
+}
+
+func TestMaxDebtOverride(t *testing.T) {
+	c := clock.NewFake(time.Unix(100, 0))
+	for _, algorithm := range []string{config.TokenBucketAlgorithm, config.GCRAAlgorithm} {
+		cfg := config.NewDefaultBucketConfig()
+		cfg.Algorithm = algorithm
+		cfg.Size = 10
+		cfg.FillRate = 10
+		cfg.MaxDebtMillis = 1000
+		cfg.ApplyDefaults()
+		b := NewBucketFactoryWithClock(c).NewBucket("memory", "debt_"+algorithm, cfg, false).(quotaservice.DebtLimiter)
+
+		if _, ok := b.TakeWithMaxDebt(10, 0, 0); !ok {
+			t.Fatalf("Expecting %v buckets to grant tokens held without lending", algorithm)
+		}
+
+		if _, ok := b.TakeWithMaxDebt(5, time.Second, 200*time.Millisecond); ok {
+			t.Fatalf("Expecting %v buckets not to lend beyond the max debt given", algorithm)
+		}
+
+		if _, ok := b.TakeWithMaxDebt(2, time.Second, 200*time.Millisecond); !ok {
+			t.Fatalf("Expecting %v buckets to lend within the max debt given", algorithm)
+		}
+
+		if _, ok := b.TakeWithMaxDebt(9, time.Second, time.Minute); ok {
+			t.Fatalf("Expecting %v buckets not to lend beyond their own max debt", algorithm)
+		}
+
+		b.(quotaservice.Bucket).Destroy()
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	// A second after a snapshot of 4 tokens, buckets have filled by a token, except sliding windows,
+	// which free none until the window moves on.
+	expected := map[string]int64{
+		config.TokenBucketAlgorithm:   5,
+		config.GCRAAlgorithm:          5,
+		config.SlidingWindowAlgorithm: 4}
+
+	for algorithm, tokens := range expected {
+		c := clock.NewFake(time.Unix(100, 0))
+		cfg := config.NewDefaultBucketConfig()
+		cfg.Algorithm = algorithm
+		cfg.Size = 10
+		cfg.FillRate = 1
+		cfg.WindowMillis = 10000
+		bf := NewBucketFactoryWithClock(c)
+		b := bf.NewBucket("memory", "restored_"+algorithm, cfg, false)
+		b.Take(6, 0)
+
+		s := b.(quotaservice.StatusReporter).Status()
+		snapshot := &quotaservice.BucketSnapshot{Tokens: s.Tokens, LastFillMillis: s.LastFillMillis, DebtMillis: s.DebtMillis}
+		b.Destroy()
+
+		// Restored buckets fill from when the snapshot was taken.
+		at := c.Now()
+		c.Advance(time.Second)
+		restored := bf.NewBucket("memory", "restored_"+algorithm, cfg, false)
+		restored.(quotaservice.StateRestorer).RestoreState(snapshot, at)
+		if s := restored.(quotaservice.StatusReporter).Status(); s.Tokens != tokens {
+			t.Fatalf("Expecting %v buckets to be restored with %v tokens. Status %+v", algorithm, tokens, s)
+		}
+		restored.Destroy()
+	}
+}
+
+func TestRestoreDebt(t *testing.T) {
+	c := clock.NewFake(time.Unix(100, 0))
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Size = 10
+	cfg.FillRate = 10
+	cfg.MaxDebtMillis = 1000
+	b := NewBucketFactoryWithClock(c).NewBucket("memory", "restored_debt", cfg, false).(*tokenBucket)
+	defer b.Destroy()
+
+	b.RestoreState(&quotaservice.BucketSnapshot{DebtMillis: 500}, c.Now())
+	if w, ok := b.Take(1, time.Second); !ok || w != 500*time.Millisecond {
+		t.Fatalf("Expecting to wait for the restored debt. Wait %v, success %v", w, ok)
+	}
+
+	if s := b.Status(); s.Tokens != 0 || s.DebtMillis != 600 {
+		t.Fatalf("Unexpected status %+v", s)
+	}
+}
@@ -0,0 +1,127 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package memory
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+// concurrency allows up to cfg.Size tokens to be in use at once. Tokens are leased, returning to the
+// bucket when the lease is released, or once it expires, if its holder never releases it. There's
+// no telling when tokens will be released, so requests that don't fit are rejected straight away.
+type concurrency struct {
+	dynamic bool
+	cfg     *config.BucketConfig
+	// How long leases last, in nanos.
+	ttl int64
+	// Tells the current time.
+	clock clock.Clock
+
+	sync.Mutex
+	leases map[string]*lease
+	inUse  int64
+}
+
+type lease struct {
+	tokens int64
+	// When the lease expires, in Unix nanos.
+	expires int64
+}
+
+func newConcurrency(cfg *config.BucketConfig, dyn bool, c clock.Clock) *concurrency {
+	ttl := cfg.LeaseTTLMillis * int64(time.Millisecond)
+	if ttl <= 0 {
+		ttl = int64(time.Minute)
+	}
+
+	return &concurrency{
+		dynamic: dyn,
+		cfg:     cfg,
+		ttl:     ttl,
+		clock:   c,
+		leases:  make(map[string]*lease)}
+}
+
+// Take leases tokens without telling the caller the lease, so the tokens are only returned when it
+// expires.
+func (b *concurrency) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	_, w, success := b.Lease(numTokens, maxWaitTime)
+	return w, success
+}
+
+func (b *concurrency) Lease(numTokens int64, maxWaitTime time.Duration) (string, time.Duration, bool) {
+	id := make([]byte, 8)
+	if _, e := rand.Read(id); e != nil {
+		return "", 0, false
+	}
+
+	nowNanos := b.clock.Now().UnixNano()
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.expire(nowNanos)
+	if b.inUse+numTokens > b.cfg.Size {
+		return "", 0, false
+	}
+
+	leaseID := hex.EncodeToString(id)
+	b.leases[leaseID] = &lease{tokens: numTokens, expires: nowNanos + b.ttl}
+	b.inUse += numTokens
+	return leaseID, 0, true
+}
+
+func (b *concurrency) Release(leaseID string) bool {
+	nowNanos := b.clock.Now().UnixNano()
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.expire(nowNanos)
+	l := b.leases[leaseID]
+	if l == nil {
+		return false
+	}
+
+	delete(b.leases, leaseID)
+	b.inUse -= l.tokens
+	return true
+}
+
+// expire returns the tokens of leases that have expired. Must be called with the lock held.
+func (b *concurrency) expire(nowNanos int64) {
+	for id, l := range b.leases {
+		if l.expires <= nowNanos {
+			delete(b.leases, id)
+			b.inUse -= l.tokens
+		}
+	}
+}
+
+func (b *concurrency) Status() *admin.BucketStatus {
+	nowNanos := b.clock.Now().UnixNano()
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.expire(nowNanos)
+	return &admin.BucketStatus{Tokens: b.cfg.Size - b.inUse}
+}
+
+func (b *concurrency) Config() *config.BucketConfig {
+	return b.cfg
+}
+
+func (b *concurrency) Dynamic() bool {
+	return b.dynamic
+}
+
+func (b *concurrency) Destroy() {}
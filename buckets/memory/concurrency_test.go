@@ -0,0 +1,78 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func TestConcurrency(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Algorithm = config.ConcurrencyAlgorithm
+	cfg.Size = 3
+	cfg.LeaseTTLMillis = 1000
+	b, ok := factory.NewBucket("memory", "concurrency", cfg, false).(*concurrency)
+	if !ok {
+		t.Fatal("Expecting a concurrency bucket")
+	}
+
+	now := clock.NewFake(time.Unix(100, 0))
+	b.clock = now
+
+	first, _, ok := b.Lease(2, time.Second)
+	if !ok || first == "" {
+		t.Fatal("Expecting a lease")
+	}
+
+	if _, _, ok := b.Lease(2, time.Second); ok {
+		t.Fatal("Expecting leases beyond the bucket's size to be rejected")
+	}
+
+	second, _, ok := b.Lease(1, time.Second)
+	if !ok || second == first {
+		t.Fatal("Expecting a second, distinct lease")
+	}
+
+	if s := b.Status(); s.Tokens != 0 {
+		t.Fatalf("Expecting all tokens to be in use. Status %+v", s)
+	}
+
+	if !b.Release(first) {
+		t.Fatal("Expecting the lease to be released")
+	}
+
+	if b.Release(first) {
+		t.Fatal("Expecting leases to only be released once")
+	}
+
+	if s := b.Status(); s.Tokens != 2 {
+		t.Fatalf("Expecting released tokens to return to the bucket. Status %+v", s)
+	}
+
+	// Leases that aren't released return their tokens once they expire.
+	now.Advance(time.Second)
+	if s := b.Status(); s.Tokens != 3 {
+		t.Fatalf("Expecting expired leases to return their tokens. Status %+v", s)
+	}
+
+	if b.Release(second) {
+		t.Fatal("Expecting expired leases to be gone")
+	}
+
+	if _, ok := b.Take(3, 0); !ok {
+		t.Fatal("Expecting Take to lease tokens")
+	}
+}
+
+func TestConcurrencyDefaults(t *testing.T) {
+	cfg := &config.BucketConfig{Algorithm: config.ConcurrencyAlgorithm}
+	cfg.ApplyDefaults()
+	if cfg.LeaseTTLMillis != 60000 {
+		t.Fatalf("Expecting leases to default to a minute. Was %v", cfg.LeaseTTLMillis)
+	}
+}
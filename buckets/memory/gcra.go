@@ -0,0 +1,150 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+// gcra implements the generic cell rate algorithm. Rather than counting tokens, it tracks the
+// theoretical arrival time (TAT): when the bucket would be empty again if tokens were claimed no
+// faster than one per emission interval. Claims may run ahead of the TAT by up to burst tokens;
+// beyond that, they wait for the TAT to catch up.
+type gcra struct {
+	dynamic bool
+	cfg     *config.BucketConfig
+	// Nanos between tokens, and the tokens that can be claimed at once.
+	interval, burst int64
+	// Tells the current time.
+	clock clock.Clock
+
+	sync.Mutex
+	tat int64
+}
+
+func newGCRA(cfg *config.BucketConfig, dyn bool, c clock.Clock) *gcra {
+	b := &gcra{
+		dynamic:  dyn,
+		cfg:      cfg,
+		interval: cfg.EmissionInterval,
+		burst:    cfg.Burst,
+		clock:    c}
+
+	if b.interval <= 0 {
+		b.interval = cfg.NanosPerToken(cfg.FillRate)
+	}
+
+	if b.burst <= 0 {
+		b.burst = cfg.Size
+	}
+
+	// Buckets that don't start full start with their missing tokens already claimed.
+	if missing := b.burst - cfg.InitialTokens(b.burst); missing > 0 {
+		b.tat = b.clock.Now().UnixNano() + missing*b.interval
+	}
+
+	return b
+}
+
+func (b *gcra) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	return b.TakeWithMaxDebt(numTokens, maxWaitTime, time.Duration(b.cfg.MaxDebtMillis)*time.Millisecond)
+}
+
+// TakeWithMaxDebt is Take, lending no more than maxDebt, or the bucket's max debt if that's lower.
+func (b *gcra) TakeWithMaxDebt(numTokens int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
+	if limit := time.Duration(b.cfg.MaxDebtMillis) * time.Millisecond; maxDebt > limit {
+		maxDebt = limit
+	}
+
+	nowNanos := b.clock.Now().UnixNano()
+
+	b.Lock()
+	defer b.Unlock()
+
+	tat := b.tat
+	if tat < nowNanos {
+		tat = nowNanos
+	}
+
+	tat += numTokens * b.interval
+	waitNanos := tat - b.burst*b.interval - nowNanos
+	if waitNanos < 0 {
+		waitNanos = 0
+	}
+
+	if waitNanos > maxWaitTime.Nanoseconds() || waitNanos > maxDebt.Nanoseconds() {
+		return 0, false
+	}
+
+	b.tat = tat
+	return time.Duration(waitNanos), true
+}
+
+func (b *gcra) Status() *admin.BucketStatus {
+	nowNanos := b.clock.Now().UnixNano()
+
+	b.Lock()
+	defer b.Unlock()
+
+	s := &admin.BucketStatus{Tokens: b.burst}
+	if ahead := b.tat - nowNanos; ahead > 0 {
+		// Tokens claimed ahead of the TAT, rounded up as a token is only freed once it's due.
+		s.Tokens -= (ahead + b.interval - 1) / b.interval
+		if s.Tokens < 0 {
+			s.Tokens = 0
+		}
+
+		if debt := ahead - b.burst*b.interval; debt > 0 {
+			s.DebtMillis = debt / int64(time.Millisecond)
+		}
+	}
+
+	return s
+}
+
+// ReturnTokens moves the TAT back by the tokens' emission intervals.
+func (b *gcra) ReturnTokens(numTokens int64) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.tat -= numTokens * b.interval
+}
+
+// SetFillRate changes the emission interval to the fill period over the fill rate.
+func (b *gcra) SetFillRate(fillRate int64) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.interval = b.cfg.NanosPerToken(fillRate)
+}
+
+// RestoreState sets the TAT to where it was when the snapshot was taken: the missing tokens' emission
+// intervals, plus any debt, past at.
+func (b *gcra) RestoreState(s *quotaservice.BucketSnapshot, at time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	tokens := s.Tokens
+	if tokens > b.burst {
+		tokens = b.burst
+	}
+
+	b.tat = at.UnixNano() + (b.burst-tokens)*b.interval + s.DebtMillis*int64(time.Millisecond)
+}
+
+func (b *gcra) Config() *config.BucketConfig {
+	return b.cfg
+}
+
+func (b *gcra) Dynamic() bool {
+	return b.dynamic
+}
+
+func (b *gcra) Destroy() {}
@@ -0,0 +1,96 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func TestGCRA(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Algorithm = config.GCRAAlgorithm
+	cfg.Burst = 3
+	cfg.EmissionInterval = int64(100 * time.Millisecond)
+	cfg.MaxDebtMillis = 250
+	b, ok := factory.NewBucket("memory", "gcra", cfg, false).(*gcra)
+	if !ok {
+		t.Fatal("Expecting a GCRA bucket")
+	}
+
+	now := clock.NewFake(time.Unix(100, 0))
+	b.clock = now
+
+	// A burst is allowed straight away.
+	if w, ok := b.Take(3, 0); !ok || w != 0 {
+		t.Fatalf("Expecting burst to be allowed. Wait %v, success %v", w, ok)
+	}
+
+	// Further tokens are paced an emission interval apart.
+	if _, ok := b.Take(1, 0); ok {
+		t.Fatal("Expecting request beyond the burst not to be allowed without waiting")
+	}
+
+	if w, ok := b.Take(1, time.Second); !ok || w != 100*time.Millisecond {
+		t.Fatalf("Expecting to wait an emission interval. Wait %v, success %v", w, ok)
+	}
+
+	if w, ok := b.Take(1, time.Second); !ok || w != 200*time.Millisecond {
+		t.Fatalf("Expecting to wait two emission intervals. Wait %v, success %v", w, ok)
+	}
+
+	if s := b.Status(); s.Tokens != 0 || s.DebtMillis != 200 {
+		t.Fatalf("Unexpected status %+v", s)
+	}
+
+	// Waits are bounded by the max debt.
+	if _, ok := b.Take(1, time.Second); ok {
+		t.Fatal("Expecting max debt to be enforced")
+	}
+
+	// Once the TAT has passed, the whole burst is available again.
+	now.Advance(time.Second)
+	if s := b.Status(); s.Tokens != 3 || s.DebtMillis != 0 {
+		t.Fatalf("Expecting the burst to be available again. Status %+v", s)
+	}
+
+	now.Advance(50 * time.Millisecond)
+	b.Take(3, 0)
+	now.Advance(150 * time.Millisecond)
+	if s := b.Status(); s.Tokens != 1 {
+		t.Fatalf("Expecting a token to be freed per emission interval. Status %+v", s)
+	}
+}
+
+func TestGCRADefaults(t *testing.T) {
+	cfg := &config.BucketConfig{Algorithm: config.GCRAAlgorithm, Size: 20, FillRate: 4}
+	cfg.ApplyDefaults()
+	if cfg.Burst != 20 || cfg.EmissionInterval != int64(250*time.Millisecond) {
+		t.Fatalf("Expecting GCRA settings to default to size and fill rate. Was %+v", cfg)
+	}
+}
+
+func TestGCRAReturnTokens(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Algorithm = config.GCRAAlgorithm
+	cfg.Burst = 3
+	cfg.EmissionInterval = int64(100 * time.Millisecond)
+	b := factory.NewBucket("memory", "gcra_returns", cfg, false).(*gcra)
+
+	now := clock.NewFake(time.Unix(100, 0))
+	b.clock = now
+
+	b.Take(3, 0)
+	b.ReturnTokens(2)
+	if s := b.Status(); s.Tokens != 2 {
+		t.Fatalf("Expecting returned tokens to be claimable again. Status %+v", s)
+	}
+
+	if w, ok := b.Take(2, 0); !ok || w != 0 {
+		t.Fatalf("Expecting returned tokens to be claimable without waiting. Wait %v, success %v", w, ok)
+	}
+}
@@ -0,0 +1,153 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package memory
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+// slidingWindow allows cfg.Size tokens per rolling window. Rather than keeping the time of every
+// claim, it counts the tokens claimed in fixed windows, estimating those claimed in the rolling
+// window as the current window's count plus the previous window's, weighted by how much of the
+// previous window the rolling window overlaps. Tokens are never lent from the future, so requests
+// that don't fit are rejected straight away.
+type slidingWindow struct {
+	dynamic bool
+	cfg     *config.BucketConfig
+	// Length of the windows, in nanos.
+	window int64
+	// Tells the current time.
+	clock clock.Clock
+
+	sync.Mutex
+	// Index of the current window since the Unix epoch, and the tokens claimed in it and the
+	// window before it.
+	current, currentCount, previousCount int64
+}
+
+func newSlidingWindow(cfg *config.BucketConfig, dyn bool, c clock.Clock) *slidingWindow {
+	window := cfg.WindowMillis * int64(time.Millisecond)
+	if window <= 0 {
+		window = int64(time.Second)
+	}
+
+	b := &slidingWindow{
+		dynamic: dyn,
+		cfg:     cfg,
+		window:  window,
+		clock:   c}
+
+	// Buckets that don't start full start with their missing tokens claimed in the current window.
+	if missing := cfg.Size - cfg.InitialTokens(cfg.Size); missing > 0 {
+		b.current = b.clock.Now().UnixNano() / window
+		b.currentCount = missing
+	}
+
+	return b
+}
+
+func (b *slidingWindow) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	nowNanos := b.clock.Now().UnixNano()
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.advance(nowNanos)
+	if b.claimed(nowNanos)+float64(numTokens) > float64(b.cfg.Size) {
+		return 0, false
+	}
+
+	b.currentCount += numTokens
+	return 0, true
+}
+
+// advance moves the counts on to the window nowNanos falls in. Must be called with the lock held.
+func (b *slidingWindow) advance(nowNanos int64) {
+	idx := nowNanos / b.window
+	switch {
+	case idx == b.current:
+		return
+	case idx == b.current+1:
+		b.previousCount = b.currentCount
+	default:
+		b.previousCount = 0
+	}
+
+	b.current = idx
+	b.currentCount = 0
+}
+
+// claimed estimates the tokens claimed in the rolling window ending at nowNanos. Must be called
+// with the lock held, after advance.
+func (b *slidingWindow) claimed(nowNanos int64) float64 {
+	overlap := float64(b.window-nowNanos%b.window) / float64(b.window)
+	return float64(b.previousCount)*overlap + float64(b.currentCount)
+}
+
+func (b *slidingWindow) Status() *admin.BucketStatus {
+	nowNanos := b.clock.Now().UnixNano()
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.advance(nowNanos)
+	tokens := b.cfg.Size - int64(math.Ceil(b.claimed(nowNanos)))
+	if tokens < 0 {
+		tokens = 0
+	}
+
+	// Tokens are freed continuously as the window slides, so report the current window's start.
+	return &admin.BucketStatus{Tokens: tokens, LastFillMillis: b.current * b.window / 1e6}
+}
+
+// ReturnTokens takes tokens off the current window's count, then the previous window's.
+func (b *slidingWindow) ReturnTokens(numTokens int64) {
+	nowNanos := b.clock.Now().UnixNano()
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.advance(nowNanos)
+	if numTokens <= b.currentCount {
+		b.currentCount -= numTokens
+		return
+	}
+
+	b.previousCount -= numTokens - b.currentCount
+	b.currentCount = 0
+	if b.previousCount < 0 {
+		b.previousCount = 0
+	}
+}
+
+// RestoreState counts the tokens missing when the snapshot was taken as claimed in the window at
+// falls in.
+func (b *slidingWindow) RestoreState(s *quotaservice.BucketSnapshot, at time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.current = at.UnixNano() / b.window
+	b.previousCount = 0
+	b.currentCount = b.cfg.Size - s.Tokens
+	if b.currentCount < 0 {
+		b.currentCount = 0
+	}
+}
+
+func (b *slidingWindow) Config() *config.BucketConfig {
+	return b.cfg
+}
+
+func (b *slidingWindow) Dynamic() bool {
+	return b.dynamic
+}
+
+func (b *slidingWindow) Destroy() {}
@@ -0,0 +1,103 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Algorithm = config.SlidingWindowAlgorithm
+	cfg.Size = 10
+	cfg.WindowMillis = 1000
+	b, ok := factory.NewBucket("memory", "sliding", cfg, false).(*slidingWindow)
+	if !ok {
+		t.Fatal("Expecting a sliding window bucket")
+	}
+
+	now := clock.NewFake(time.Unix(100, 0))
+	b.clock = now
+
+	take := func(n int64) bool {
+		_, ok := b.Take(n, time.Second)
+		return ok
+	}
+
+	if !take(10) {
+		t.Fatal("Expecting the whole window to be claimable")
+	}
+
+	if take(1) {
+		t.Fatal("Expecting a full window to reject requests rather than make them wait")
+	}
+
+	// Halfway into the next window, half of the previous window still overlaps the rolling one.
+	now.Advance(1500 * time.Millisecond)
+	if !take(5) {
+		t.Fatal("Expecting tokens to be freed as the window slides")
+	}
+
+	if take(1) {
+		t.Fatal("Expecting the previous window to still count")
+	}
+
+	if s := b.Status(); s.Tokens != 0 {
+		t.Fatalf("Expecting no tokens left. Status %+v", s)
+	}
+
+	// Windows more than one window ago don't count at all.
+	now.Advance(2 * time.Second)
+	if s := b.Status(); s.Tokens != 10 {
+		t.Fatalf("Expecting all tokens to be free. Status %+v", s)
+	}
+
+	if take(11) {
+		t.Fatal("Expecting requests for more tokens than the bucket holds to be rejected")
+	}
+}
+
+func TestSlidingWindowDefaults(t *testing.T) {
+	cfg := &config.BucketConfig{Algorithm: config.SlidingWindowAlgorithm}
+	cfg.ApplyDefaults()
+	if cfg.WindowMillis != 1000 {
+		t.Fatalf("Expecting sliding windows to default to a second. Was %v", cfg.WindowMillis)
+	}
+
+	if b := factory.NewBucket("memory", "sliding", config.NewDefaultBucketConfig(), false); b.Config().Algorithm != "" {
+		t.Fatal("Expecting token buckets by default")
+	} else if _, ok := b.(*tokenBucket); !ok {
+		t.Fatal("Expecting a token bucket")
+	}
+}
+
+func TestSlidingWindowReturnTokens(t *testing.T) {
+	cfg := config.NewDefaultBucketConfig()
+	cfg.Algorithm = config.SlidingWindowAlgorithm
+	cfg.Size = 10
+	cfg.WindowMillis = 1000
+	b := factory.NewBucket("memory", "sliding_returns", cfg, false).(*slidingWindow)
+
+	now := clock.NewFake(time.Unix(100, 0))
+	b.clock = now
+
+	b.Take(10, 0)
+	now.Advance(1500 * time.Millisecond)
+	b.Take(2, 0)
+
+	// Tokens come off the current window first, then the previous one.
+	b.ReturnTokens(6)
+	if b.currentCount != 0 || b.previousCount != 6 {
+		t.Fatalf("Unexpected counts %v and %v", b.currentCount, b.previousCount)
+	}
+
+	b.ReturnTokens(20)
+	if s := b.Status(); s.Tokens != 10 {
+		t.Fatalf("Expecting all tokens to be free. Status %+v", s)
+	}
+}
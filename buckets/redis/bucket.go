@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
 	"github.com/maniksurtani/quotaservice/config"
 	"github.com/maniksurtani/quotaservice/logging"
 	"gopkg.in/redis.v3"
@@ -32,6 +33,7 @@ type redisBucket struct {
 	maxTokensToAccumulate string
 	maxIdleTimeMillis     string
 	maxDebtNanos          string
+	initialTokens         string
 	redisKeys             []string // {tokensNextAvailableRedisKey, accumulatedTokensRedisKey}
 }
 
@@ -75,7 +77,7 @@ func (bf *bucketFactory) connectToRedis() {
 	bf.client = redis.NewClient(bf.redisOpts)
 	redisResults := bf.client.Time().Val()
 	if len(redisResults) == 0 {
-		logging.Printf("Cannot connect to Redis. TIME returned %v", redisResults)
+		logging.Warnf("Cannot connect to Redis. TIME returned %v", redisResults)
 	} else {
 		t := time.Unix(toInt64(redisResults[0], 0), 0)
 		logging.Printf("Connection established. Time on Redis server: %v", t)
@@ -84,6 +86,11 @@ func (bf *bucketFactory) connectToRedis() {
 }
 
 func (bf *bucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) quotaservice.Bucket {
+	if cfg.Algorithm != "" && cfg.Algorithm != config.TokenBucketAlgorithm {
+		logging.Printf("Redis buckets don't support the %v algorithm. Bucket %v will be a token bucket.",
+			cfg.Algorithm, config.FullyQualifiedName(namespace, bucketName))
+	}
+
 	idle := "0"
 	if cfg.MaxIdleMillis > 0 {
 		idle = strconv.FormatInt(int64(cfg.MaxIdleMillis), 10)
@@ -93,10 +100,11 @@ func (bf *bucketFactory) NewBucket(namespace, bucketName string, cfg *config.Buc
 		dyn,
 		cfg,
 		bf,
-		strconv.FormatInt(1e9/cfg.FillRate, 10),
+		strconv.FormatInt(cfg.NanosPerToken(cfg.FillRate), 10),
 		strconv.FormatInt(cfg.Size, 10),
 		idle,
 		strconv.FormatInt(cfg.MaxDebtMillis*1e6, 10), // Convert millis to nanos
+		strconv.FormatInt(cfg.InitialTokens(cfg.Size), 10),
 		[]string{toRedisKey(namespace, bucketName, tokensNextAvblNanosSuffix),
 			toRedisKey(namespace, bucketName, accumulatedTokensSuffix)}}
 
@@ -108,10 +116,23 @@ func toRedisKey(namespace, bucketName, suffix string) string {
 }
 
 func (b *redisBucket) Take(requested int64, maxWaitTime time.Duration) (time.Duration, bool) {
+	return b.take(requested, maxWaitTime, b.maxDebtNanos)
+}
+
+// TakeWithMaxDebt is Take, lending no more than maxDebt, or the bucket's max debt if that's lower.
+func (b *redisBucket) TakeWithMaxDebt(requested int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
+	if maxDebt.Nanoseconds() >= b.cfg.MaxDebtMillis*1e6 {
+		return b.take(requested, maxWaitTime, b.maxDebtNanos)
+	}
+
+	return b.take(requested, maxWaitTime, strconv.FormatInt(maxDebt.Nanoseconds(), 10))
+}
+
+func (b *redisBucket) take(requested int64, maxWaitTime time.Duration, maxDebtNanos string) (time.Duration, bool) {
 	currentTimeNanos := strconv.FormatInt(time.Now().UnixNano(), 10)
 	args := []string{currentTimeNanos, b.nanosBetweenTokens, b.maxTokensToAccumulate,
 		strconv.FormatInt(requested, 10), strconv.FormatInt(maxWaitTime.Nanoseconds(), 10),
-		b.maxIdleTimeMillis, b.maxDebtNanos}
+		b.maxIdleTimeMillis, maxDebtNanos, b.initialTokens}
 
 	keepTrying := true
 	var waitTime time.Duration
@@ -152,7 +173,7 @@ func toInt64(s interface{}, defaultValue int64) (v int64) {
 		var err error
 		v, err = strconv.ParseInt(s.(string), 10, 64)
 		if err != nil {
-			logging.Printf("Cannot convert '%v' to int64", s)
+			logging.Warnf("Cannot convert '%v' to int64", s)
 		}
 	}
 	return
@@ -162,6 +183,39 @@ func (b *redisBucket) Config() *config.BucketConfig {
 	return b.cfg
 }
 
+// Status reads the bucket's state from Redis, as the script would see it if tokens were claimed
+// now. Buckets whose state has expired are reported as they'd start out.
+func (b *redisBucket) Status() *admin.BucketStatus {
+	currentTimeNanos := time.Now().UnixNano()
+	vals, e := b.factory.client.MGet(b.redisKeys...).Result()
+	if e != nil {
+		logging.Warnf("Unable to read status of bucket %v from Redis: %v", b.redisKeys, e)
+		return &admin.BucketStatus{}
+	}
+
+	tna := toInt64(vals[0], 0)
+	s := &admin.BucketStatus{Tokens: b.cfg.InitialTokens(b.cfg.Size)}
+	if vals[1] == nil {
+		// State is only ever written with both keys together.
+		return s
+	}
+
+	s.Tokens = toInt64(vals[1], 0)
+
+	// The script moves tokensNextAvailableNanos up to the current time whenever it adds tokens.
+	if currentTimeNanos > tna {
+		s.LastFillMillis = tna / 1e6
+		s.Tokens += (currentTimeNanos - tna) / b.cfg.NanosPerToken(b.cfg.FillRate)
+		if s.Tokens > b.cfg.Size {
+			s.Tokens = b.cfg.Size
+		}
+	} else {
+		s.DebtMillis = (tna - currentTimeNanos) / 1e6
+	}
+
+	return s
+}
+
 func (b *redisBucket) Dynamic() bool {
 	return b.dynamic
 }
@@ -170,6 +224,13 @@ func (b *redisBucket) Destroy() {
 	// No-op
 }
 
+// EraseState deletes the bucket's state from Redis, so a bucket of the same name starts afresh.
+func (b *redisBucket) EraseState() {
+	if e := b.factory.client.Del(b.redisKeys...).Err(); e != nil {
+		logging.Warnf("Unable to erase state of bucket %v from Redis: %v", b.redisKeys, e)
+	}
+}
+
 func checkScriptExists(c *redis.Client, sha string) bool {
 	r := c.ScriptExists(sha)
 	return r.Val()[0]
@@ -188,7 +249,9 @@ func loadScript(c *redis.Client) (sha string) {
 
 	local accumulatedTokens = redis.call("GET", KEYS[2])
 	if not accumulatedTokens then
-		accumulatedTokens = maxTokensToAccumulate
+		-- New buckets start with their initial tokens, filling up from now on.
+		accumulatedTokens = tonumber(ARGV[8])
+		tokensNextAvailableNanos = tonumber(ARGV[1])
 	end
 
 	local currentTimeNanos = tonumber(ARGV[1])
@@ -64,6 +64,13 @@ func TestTokenAcquisition(t *testing.T) {
 	buckets.TestTokenAcquisition(t, bucket)
 }
 
+func TestStatus(t *testing.T) {
+	b := factory.NewBucket("redis", "status", buckets.NewStatusTestBucketConfig(), false).(*redisBucket)
+	// Clear state left by previous runs.
+	b.factory.client.Del(b.redisKeys...)
+	buckets.TestStatus(t, b)
+}
+
 func TestGC(t *testing.T) {
 	buckets.TestGC(t, factory, "redis")
 }
@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package clock abstracts the passing of time, so that buckets and the server can be run against a
+// Fake clock in tests rather than waiting on the system clock.
+package clock
+
+import "time"
+
+// Clock tells the time, and waits for it to pass.
+type Clock interface {
+	Now() time.Time
+	// NewTimer creates a Timer that fires once d has passed.
+	NewTimer(d time.Duration) Timer
+	// NewTicker creates a Ticker that ticks every d.
+	NewTicker(d time.Duration) Ticker
+	// Sleep blocks until d has passed.
+	Sleep(d time.Duration)
+}
+
+// Timer is a time.Timer, created by a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	// Stop stops the timer, returning false if it had already fired or been stopped.
+	Stop() bool
+}
+
+// Ticker is a time.Ticker, created by a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// System is the system clock.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return &systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return &systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t *systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
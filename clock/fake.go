@@ -0,0 +1,137 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock whose time only passes when Advance is called, firing the timers and tickers due
+// by then, so that timing-based behavior can be tested deterministically and without waiting.
+type Fake struct {
+	sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFake creates a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.now
+}
+
+// Advance moves the clock on by d, firing timers and ticking tickers that are due. As with
+// time.Ticker, tickers that are due more than once only tick once, dropping ticks that aren't
+// received.
+func (f *Fake) Advance(d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.now = f.now.Add(d)
+	waiting := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.stopped {
+			continue
+		}
+
+		if !w.due.After(f.now) {
+			select {
+			case w.c <- f.now:
+			default:
+			}
+
+			if w.period <= 0 {
+				w.stopped = true
+				continue
+			}
+
+			for !w.due.After(f.now) {
+				w.due = w.due.Add(w.period)
+			}
+		}
+
+		waiting = append(waiting, w)
+	}
+
+	f.waiters = waiting
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.newWaiter(d, 0)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("Non-positive interval for NewTicker")
+	}
+
+	return fakeTicker{f.newWaiter(d, d)}
+}
+
+// Sleep blocks until the clock has been advanced by d.
+func (f *Fake) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	<-f.NewTimer(d).C()
+}
+
+func (f *Fake) newWaiter(d, period time.Duration) *fakeWaiter {
+	f.Lock()
+	defer f.Unlock()
+
+	w := &fakeWaiter{f: f, c: make(chan time.Time, 1), due: f.now.Add(d), period: period}
+	if d <= 0 {
+		// Due straight away.
+		w.c <- f.now
+		w.stopped = true
+		return w
+	}
+
+	f.waiters = append(f.waiters, w)
+	return w
+}
+
+// fakeWaiter is the Timer or Ticker of a Fake clock, firing at due and, for tickers, every period
+// after that.
+type fakeWaiter struct {
+	f       *Fake
+	c       chan time.Time
+	due     time.Time
+	period  time.Duration
+	stopped bool // Guarded by f
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.f.Lock()
+	defer w.f.Unlock()
+
+	active := !w.stopped
+	w.stopped = true
+	return active
+}
+
+type fakeTicker struct {
+	w *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t fakeTicker) Stop() {
+	t.w.Stop()
+}
@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Unix(100, 0)
+	f := NewFake(start)
+	timer := f.NewTimer(time.Second)
+	ticker := f.NewTicker(300 * time.Millisecond)
+	stopped := f.NewTimer(time.Second)
+	if !stopped.Stop() {
+		t.Fatal("Expecting timers to be active until they fire")
+	}
+
+	f.Advance(500 * time.Millisecond)
+	if now := f.Now(); !now.Equal(start.Add(500 * time.Millisecond)) {
+		t.Fatalf("Expecting time to move on as advanced. Now %v", now)
+	}
+
+	select {
+	case <-timer.C():
+		t.Fatal("Expecting timers not to fire early")
+	case <-ticker.C():
+	default:
+		t.Fatal("Expecting tickers to tick once due")
+	}
+
+	f.Advance(time.Second)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("Expecting timers to fire once due")
+	}
+
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Fatal("Expecting ticks that aren't received to be dropped")
+	case <-stopped.C():
+		t.Fatal("Expecting stopped timers not to fire")
+	default:
+	}
+
+	ticker.Stop()
+	if timer.Stop() {
+		t.Fatal("Expecting timers that have fired to be inactive")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f.Sleep(time.Second)
+		close(done)
+	}()
+
+	for slept := false; !slept; {
+		f.Advance(time.Second)
+		select {
+		case <-done:
+			slept = true
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
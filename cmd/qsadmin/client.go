@@ -0,0 +1,129 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+// client makes requests to the admin REST API.
+type client struct {
+	url string
+	// Bearer token, or user and password for HTTP basic authentication, if the API requires them.
+	token    string
+	user     string
+	password string
+	// signer signs requests that change configs, if the server requires signed configs.
+	signer *config.ConfigSigner
+	http   *http.Client
+}
+
+// apiError is an error response from the API.
+type apiError struct {
+	Status   int                     `json:"status"`
+	Message  string                  `json:"message"`
+	Problems config.ValidationErrors `json:"problems"`
+}
+
+func (e *apiError) Error() string {
+	if len(e.Problems) == 0 {
+		return e.Message
+	}
+
+	lines := []string{e.Message}
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.Path+": "+p.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// do sends a request to path, returning the response body and its ETag. ifMatch, if set, makes the
+// request conditional on the config being at that version. Error responses are returned as errors.
+func (c *client) do(method, path, contentType string, body []byte, ifMatch string) ([]byte, string, error) {
+	var b io.Reader
+	if body != nil {
+		b = bytes.NewReader(body)
+	}
+
+	req, e := http.NewRequest(method, strings.TrimSuffix(c.url, "/")+path, b)
+	if e != nil {
+		return nil, "", e
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	if c.signer != nil && body != nil {
+		req.Header.Set(admin.SignatureHeader, c.signer.SignPayload(body))
+	}
+
+	rsp, e := c.http.Do(req)
+	if e != nil {
+		return nil, "", e
+	}
+	defer rsp.Body.Close()
+
+	contents, e := ioutil.ReadAll(rsp.Body)
+	if e != nil {
+		return nil, "", e
+	}
+
+	if rsp.StatusCode >= 300 {
+		apiErr := &apiError{}
+		if json.Unmarshal(contents, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(contents))
+		}
+		apiErr.Status = rsp.StatusCode
+		apiErr.Message = fmt.Sprintf("%v: %v", rsp.Status, apiErr.Message)
+		return nil, "", apiErr
+	}
+
+	return contents, rsp.Header.Get("ETag"), nil
+}
+
+func (c *client) get(path string, into interface{}) (string, error) {
+	b, tag, e := c.do("GET", path, "", nil, "")
+	if e != nil {
+		return "", e
+	}
+
+	return tag, json.Unmarshal(b, into)
+}
+
+// send sends v as JSON, conditional on the config being at version ifMatch if set.
+func (c *client) send(method, path string, v interface{}, ifMatch string) error {
+	b, e := json.Marshal(v)
+	if e != nil {
+		return e
+	}
+
+	_, _, e = c.do(method, path, "application/json", b, ifMatch)
+	return e
+}
+
+// version returns the ETag of the current config, to base changes on.
+func (c *client) version() (string, error) {
+	_, tag, e := c.do("GET", "/api/config/export", "", nil, "")
+	return tag, e
+}
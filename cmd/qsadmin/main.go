@@ -0,0 +1,465 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// qsadmin administers a quotaservice via its admin REST API.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos/config"
+)
+
+const usage = `Usage: qsadmin [flags] COMMAND [ARGS]
+
+Commands:
+  list [namespace]
+	Lists namespaces and their buckets, or the buckets of a namespace.
+  get namespace [bucket]
+	Prints the config of a namespace or bucket as JSON.
+  add-bucket namespace bucket [setting=value ...]
+	Adds a bucket, such as: add-bucket ns b size=100 fill_rate=50 wait_timeout_millis=1s
+  update-bucket namespace bucket setting=value ...
+	Changes some of a bucket's settings, leaving the others as they are.
+  delete-bucket namespace bucket
+  add-namespace namespace [setting=value ...]
+	Adds a namespace, such as: add-namespace ns max_dynamic_buckets=100
+  update-namespace namespace setting=value ...
+	Changes some of a namespace's settings, leaving the others as they are.
+  delete-namespace namespace
+  diff file
+	Shows how the config in file differs from the live config.
+  apply file
+	Replaces the live config with the config in file, printing the changes made.
+  history
+	Lists recent config versions.
+  rollback version
+	Rolls back to a historical config version, as the next version.
+
+Setting values are JSON, such as {"owner": "ops"} for labels, or strings. Use ` + config.GlobalNamespace + `
+as the namespace and ` + config.DefaultBucketName + ` as the bucket for the global default bucket.
+
+Flags:
+`
+
+// out is where commands print their output.
+var out io.Writer = os.Stdout
+
+type command func(c *client, args []string) error
+
+var commands = map[string]command{
+	"list":             list,
+	"get":              get,
+	"add-bucket":       addBucket,
+	"update-bucket":    updateBucket,
+	"delete-bucket":    deleteBucket,
+	"add-namespace":    addNamespace,
+	"update-namespace": updateNamespace,
+	"delete-namespace": deleteNamespace,
+	"diff":             diff,
+	"apply":            apply,
+	"history":          history,
+	"rollback":         rollback}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+
+	c := &client{http: &http.Client{Timeout: 30 * time.Second}}
+	flag.StringVar(&c.url, "url", "http://localhost:8080", "URL of the admin console.")
+	flag.StringVar(&c.token, "token", os.Getenv("QSADMIN_TOKEN"), "Bearer token to authenticate with. Defaults to $QSADMIN_TOKEN.")
+	flag.StringVar(&c.user, "user", "", "User to authenticate as with HTTP basic authentication.")
+	flag.StringVar(&c.password, "password", os.Getenv("QSADMIN_PASSWORD"), "Password for -user. Defaults to $QSADMIN_PASSWORD.")
+	keyFile := flag.String("signing-key-file", "", "File holding the key to sign changes with, if the server requires signed configs.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cmd := commands[flag.Arg(0)]
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %v\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *keyFile != "" {
+		key, e := ioutil.ReadFile(*keyFile)
+		if e != nil {
+			fmt.Fprintln(os.Stderr, "Error:", e)
+			os.Exit(1)
+		}
+		c.signer = config.NewConfigSigner(key)
+	}
+
+	if e := cmd(c, flag.Args()[1:]); e != nil {
+		fmt.Fprintln(os.Stderr, "Error:", e)
+		os.Exit(1)
+	}
+}
+
+func list(c *client, args []string) error {
+	if len(args) > 1 {
+		return errors.New("Usage: list [namespace]")
+	}
+
+	if len(args) == 1 {
+		ns := &pb.NamespaceConfig{}
+		if _, e := c.get(namespacePath(args[0]), ns); e != nil {
+			return e
+		}
+
+		printBuckets(ns, "")
+		return nil
+	}
+
+	cfg := &pb.ServiceConfig{}
+	if _, e := c.get("/api/", cfg); e != nil {
+		return e
+	}
+
+	fmt.Fprintf(out, "Version %v\n", cfg.Version)
+	if cfg.GlobalDefaultBucket != nil {
+		fmt.Fprintln(out, config.GlobalNamespace)
+		fmt.Fprintln(out, "  "+config.DefaultBucketName)
+	}
+
+	for _, ns := range cfg.Namespaces {
+		fmt.Fprintln(out, ns.Name)
+		printBuckets(ns, "  ")
+	}
+
+	return nil
+}
+
+func printBuckets(ns *pb.NamespaceConfig, indent string) {
+	if ns.DefaultBucket != nil {
+		fmt.Fprintln(out, indent+config.DefaultBucketName)
+	}
+
+	if ns.DynamicBucketTemplate != nil {
+		fmt.Fprintln(out, indent+config.DynamicBucketTemplateName)
+	}
+
+	for _, b := range ns.Buckets {
+		fmt.Fprintln(out, indent+b.Name)
+	}
+}
+
+func get(c *client, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("Usage: get namespace [bucket]")
+	}
+
+	var v interface{}
+	if len(args) == 1 {
+		ns := &pb.NamespaceConfig{}
+		if _, e := c.get(namespacePath(args[0]), ns); e != nil {
+			return e
+		}
+		v = ns
+	} else {
+		b, e := getBucket(c, args[0], args[1])
+		if e != nil {
+			return e
+		}
+		v = b
+	}
+
+	j, e := json.MarshalIndent(v, "", "  ")
+	if e != nil {
+		return e
+	}
+
+	fmt.Fprintln(out, string(j))
+	return nil
+}
+
+func getBucket(c *client, namespace, name string) (*pb.BucketConfig, error) {
+	var b *pb.BucketConfig
+	if namespace == config.GlobalNamespace {
+		cfg := &pb.ServiceConfig{}
+		if _, e := c.get("/api/", cfg); e != nil {
+			return nil, e
+		}
+		if name == config.DefaultBucketName {
+			b = cfg.GlobalDefaultBucket
+		}
+	} else {
+		ns := &pb.NamespaceConfig{}
+		if _, e := c.get(namespacePath(namespace)+"?bucket_prefix="+url.QueryEscape(name), ns); e != nil {
+			return nil, e
+		}
+
+		switch name {
+		case config.DefaultBucketName:
+			b = ns.DefaultBucket
+		case config.DynamicBucketTemplateName:
+			b = ns.DynamicBucketTemplate
+		default:
+			for _, nb := range ns.Buckets {
+				if nb.Name == name {
+					b = nb
+				}
+			}
+		}
+	}
+
+	if b == nil {
+		return nil, fmt.Errorf("No bucket %v in namespace %v", name, namespace)
+	}
+
+	return b, nil
+}
+
+func addBucket(c *client, args []string) error {
+	if len(args) < 2 {
+		return errors.New("Usage: add-bucket namespace bucket [setting=value ...]")
+	}
+
+	s, e := settings(args[2:])
+	if e != nil {
+		return e
+	}
+
+	s["name"] = args[1]
+	return c.send("PUT", bucketPath(args[0], args[1]), s, "")
+}
+
+func updateBucket(c *client, args []string) error {
+	if len(args) < 3 {
+		return errors.New("Usage: update-bucket namespace bucket setting=value ...")
+	}
+
+	s, e := settings(args[2:])
+	if e != nil {
+		return e
+	}
+
+	version, e := c.version()
+	if e != nil {
+		return e
+	}
+
+	return c.send("PATCH", bucketPath(args[0], args[1]), s, version)
+}
+
+func deleteBucket(c *client, args []string) error {
+	if len(args) != 2 {
+		return errors.New("Usage: delete-bucket namespace bucket")
+	}
+
+	_, _, e := c.do("DELETE", bucketPath(args[0], args[1]), "", nil, "")
+	return e
+}
+
+func addNamespace(c *client, args []string) error {
+	if len(args) < 1 {
+		return errors.New("Usage: add-namespace namespace [setting=value ...]")
+	}
+
+	s, e := settings(args[1:])
+	if e != nil {
+		return e
+	}
+
+	s["name"] = args[0]
+	return c.send("PUT", "/api/namespace/"+url.PathEscape(args[0]), s, "")
+}
+
+func updateNamespace(c *client, args []string) error {
+	if len(args) < 2 {
+		return errors.New("Usage: update-namespace namespace setting=value ...")
+	}
+
+	s, e := settings(args[1:])
+	if e != nil {
+		return e
+	}
+
+	// Namespaces are replaced as a whole, so the change is based on the current config, and
+	// rejected if it changes in the meantime.
+	ns := make(map[string]interface{})
+	version, e := c.get(namespacePath(args[0]), &ns)
+	if e != nil {
+		return e
+	}
+
+	for k, v := range s {
+		ns[k] = v
+	}
+
+	return c.send("POST", "/api/namespace/"+url.PathEscape(args[0]), ns, version)
+}
+
+func deleteNamespace(c *client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: delete-namespace namespace")
+	}
+
+	_, _, e := c.do("DELETE", "/api/namespace/"+url.PathEscape(args[0]), "", nil, "")
+	return e
+}
+
+func diff(c *client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: diff file")
+	}
+
+	_, _, e := diffFile(c, args[0])
+	return e
+}
+
+func apply(c *client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: apply file")
+	}
+
+	y, version, e := diffFile(c, args[0])
+	if e != nil || y == nil {
+		return e
+	}
+
+	_, _, e = c.do("PUT", "/api/config", "application/x-yaml", y, version)
+	return e
+}
+
+// diffFile prints how the config in filename differs from the live config, returning the file's
+// config in canonical YAML and the version of the live config, or nil if they don't differ.
+func diffFile(c *client, filename string) ([]byte, string, error) {
+	live, version, e := c.do("GET", "/api/config/export", "", nil, "")
+	if e != nil {
+		return nil, "", e
+	}
+
+	cfg, e := readConfigFile(filename)
+	if e != nil {
+		return nil, "", e
+	}
+
+	// Versions are assigned by the server, so shouldn't show up as a difference.
+	cfg.Version, e = strconv.Atoi(strings.Trim(version, `"`))
+	if e != nil {
+		return nil, "", fmt.Errorf("Unexpected config version %v", version)
+	}
+
+	y, e := cfg.ToYAML()
+	if e != nil {
+		return nil, "", e
+	}
+
+	d := diffLines(string(live), string(y))
+	if d == nil {
+		fmt.Fprintln(out, "No changes.")
+		return nil, version, nil
+	}
+
+	fmt.Fprintln(out, "--- live")
+	fmt.Fprintln(out, "+++ "+filename)
+	for _, l := range d {
+		fmt.Fprintln(out, l)
+	}
+
+	return y, version, nil
+}
+
+// readConfigFile reads a config file as the server would, following includes and expanding
+// environment variables locally, as the server can't.
+func readConfigFile(filename string) (cfg *config.ServiceConfig, e error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e = fmt.Errorf("%v", r)
+		}
+	}()
+
+	return config.ReadConfigFromFile(filename), nil
+}
+
+func history(c *client, args []string) error {
+	if len(args) != 0 {
+		return errors.New("Usage: history")
+	}
+
+	var versions []struct {
+		Version    int    `json:"version"`
+		Date       int64  `json:"date"`
+		User       string `json:"user"`
+		Namespaces int    `json:"namespaces"`
+	}
+	if _, e := c.get("/api/config/history", &versions); e != nil {
+		return e
+	}
+
+	for _, v := range versions {
+		date := "-"
+		if v.Date > 0 {
+			date = time.Unix(0, v.Date*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+		}
+
+		user := v.User
+		if user == "" {
+			user = "-"
+		}
+
+		fmt.Fprintf(out, "%v\t%v\t%v\t%v namespaces\n", v.Version, date, user, v.Namespaces)
+	}
+
+	return nil
+}
+
+func rollback(c *client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: rollback version")
+	}
+
+	if _, e := strconv.Atoi(args[0]); e != nil {
+		return errors.New("Invalid version " + args[0])
+	}
+
+	_, _, e := c.do("POST", "/api/config/rollback/"+args[0], "", nil, "")
+	return e
+}
+
+// settings parses setting=value arguments. Values are read as JSON, or as strings if they aren't
+// valid JSON.
+func settings(args []string) (map[string]interface{}, error) {
+	s := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("Expecting setting=value, but was %v", arg)
+		}
+
+		var v interface{}
+		if json.Unmarshal([]byte(arg[i+1:]), &v) != nil {
+			v = arg[i+1:]
+		}
+		s[arg[:i]] = v
+	}
+
+	return s, nil
+}
+
+func namespacePath(namespace string) string {
+	return "/api/" + url.PathEscape(namespace)
+}
+
+func bucketPath(namespace, bucket string) string {
+	return namespacePath(namespace) + "/" + url.PathEscape(bucket)
+}
@@ -0,0 +1,130 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func TestCommands(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	cfg.GlobalDefaultBucket = nil
+	s := quotaservice.New(cfg, &quotaservice.MockBucketFactory{}, &quotaservice.MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, "", nil)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := &client{url: srv.URL, http: http.DefaultClient}
+	run := func(args ...string) string {
+		var b bytes.Buffer
+		out = &b
+		defer func() { out = os.Stdout }()
+		if e := commands[args[0]](c, args[1:]); e != nil {
+			t.Fatalf("Expecting %v to succeed. Error %v", args, e)
+		}
+		return b.String()
+	}
+
+	run("add-namespace", "ns", `labels={"team": "quota"}`)
+	run("add-bucket", "ns", "b", "size=100", "fill_rate=50", "wait_timeout_millis=1s")
+	run("update-bucket", "ns", "b", "fill_rate=60", `labels={"owner": "ops"}`)
+	run("update-namespace", "ns", `labels={"team": "quotas"}`)
+
+	ns := s.(admin.Administrable).Configs().Namespaces["ns"]
+	b := ns.Buckets["b"]
+	if ns.Labels["team"] != "quotas" || b.Size != 100 || b.FillRate != 60 || b.WaitTimeoutMillis != 1000 ||
+		!reflect.DeepEqual(b.Labels, map[string]string{"owner": "ops"}) {
+		t.Fatalf("Expecting changes to be applied. Namespace %+v, bucket %+v", ns, b)
+	}
+
+	if l := run("list"); !strings.Contains(l, "ns\n  b\n") {
+		t.Fatalf("Expecting bucket to be listed. Was %q", l)
+	}
+
+	if g := run("get", "ns", "b"); !strings.Contains(g, `"fill_rate": 60`) {
+		t.Fatalf("Expecting bucket config. Was %q", g)
+	}
+
+	dir, e := ioutil.TempDir("", "qs_test_qsadmin")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.yaml")
+	e = ioutil.WriteFile(filename, []byte(`namespaces:
+  ns:
+    buckets:
+      b:
+        size: 100
+        fill_rate: 70
+`), 0644)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	d := run("diff", filename)
+	if !strings.Contains(d, "-        fill_rate: 60\n") || !strings.Contains(d, "+        fill_rate: 70\n") {
+		t.Fatalf("Expecting fill rate to differ. Diff was %q", d)
+	}
+
+	run("apply", filename)
+	if b := s.(admin.Administrable).Configs().Namespaces["ns"].Buckets["b"]; b.FillRate != 70 {
+		t.Fatalf("Expecting file to be applied. Bucket %+v", b)
+	}
+
+	if d := run("diff", filename); d != "No changes.\n" {
+		t.Fatalf("Expecting no differences once applied. Diff was %q", d)
+	}
+
+	run("delete-bucket", "ns", "b")
+	if _, e := getBucket(c, "ns", "b"); e == nil {
+		t.Fatal("Expecting bucket to be deleted")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"status": 422, "message": "Invalid config", "problems": [{"path": "namespaces.ns", "message": "Bad"}]}`))
+	}))
+	defer srv.Close()
+
+	c := &client{url: srv.URL, http: http.DefaultClient}
+	e := addBucket(c, []string{"ns", "b"})
+	if e == nil || !strings.Contains(e.Error(), "Invalid config") || !strings.Contains(e.Error(), "namespaces.ns") {
+		t.Fatalf("Expecting error with problems. Was %v", e)
+	}
+
+	if _, e := settings([]string{"noequals"}); e == nil {
+		t.Fatal("Expecting settings without values to be rejected")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	d := diffLines("a\nb\nc\n", "a\nc\nd\n")
+	if expected := []string{" a", "-b", " c", "+d"}; !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Expecting %v. Was %v", expected, d)
+	}
+
+	if d := diffLines("a\n", "a\n"); d != nil {
+		t.Fatalf("Expecting no diff. Was %v", d)
+	}
+}
@@ -0,0 +1,172 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package compress compresses HTTP responses with gzip or deflate, as clients' Accept-Encoding
+// headers negotiate, for the admin console and the HTTP endpoint.
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encodings, most preferred first.
+const (
+	gzipEncoding    = "gzip"
+	deflateEncoding = "deflate"
+)
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}}
+	// The deflate content coding is zlib-wrapped, not raw, DEFLATE.
+	zlibWriters = sync.Pool{New: func() interface{} {
+		return zlib.NewWriter(nil)
+	}}
+)
+
+// Handler compresses the responses h writes with the encoding the request accepts, if any.
+// Responses that are already encoded, have no body, or answer range requests are left as they are.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == "HEAD" || r.Header.Get("Range") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressedWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate returns the encoding to compress responses with, given the request's Accept-Encoding
+// header, or "" if it accepts neither. Encodings are ranked by their quality values, ties going to
+// gzip.
+func negotiate(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, e := strconv.ParseFloat(param[2:], 64); e == nil {
+					q = v
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{gzipEncoding, deflateEncoding} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+// compressedWriter compresses the body written to it, deciding whether to once the status and
+// headers are written.
+type compressedWriter struct {
+	http.ResponseWriter
+	encoding    string
+	wroteHeader bool
+	// Compresses the body, if it's being compressed.
+	w io.WriteCloser
+}
+
+func (c *compressedWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	h := c.Header()
+	if h.Get("Content-Encoding") == "" && status != http.StatusNoContent &&
+		status != http.StatusNotModified && status != http.StatusPartialContent && status >= http.StatusOK {
+		h.Set("Content-Encoding", c.encoding)
+		// The length of the compressed body isn't known until it's written.
+		h.Del("Content-Length")
+		c.w = c.newWriter()
+	}
+
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *compressedWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		if c.Header().Get("Content-Type") == "" {
+			// Sniffed from the uncompressed body, as it would be otherwise.
+			c.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if c.w == nil {
+		return c.ResponseWriter.Write(b)
+	}
+
+	return c.w.Write(b)
+}
+
+// Flush writes what has been compressed so far, for responses streamed to the client.
+func (c *compressedWriter) Flush() {
+	if f, ok := c.w.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressedWriter) newWriter() io.WriteCloser {
+	if c.encoding == gzipEncoding {
+		w := gzipWriters.Get().(*gzip.Writer)
+		w.Reset(c.ResponseWriter)
+		return w
+	}
+
+	w := zlibWriters.Get().(*zlib.Writer)
+	w.Reset(c.ResponseWriter)
+	return w
+}
+
+// close finishes compressing the body, if it was compressed.
+func (c *compressedWriter) close() {
+	if c.w == nil {
+		return
+	}
+
+	c.w.Close()
+	switch w := c.w.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(w)
+	case *zlib.Writer:
+		zlibWriters.Put(w)
+	}
+}
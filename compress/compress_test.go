@@ -0,0 +1,107 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                           "",
+		"identity":                   "",
+		"gzip":                       gzipEncoding,
+		"deflate":                    deflateEncoding,
+		"deflate, gzip":              gzipEncoding,
+		"GZIP;q=0.5, deflate":        deflateEncoding,
+		"gzip;q=0, deflate;q=0":      "",
+		"*":                          gzipEncoding,
+		"*;q=0.2, gzip;q=0":          deflateEncoding,
+		"br, deflate;q=0.8, *;q=0.1": deflateEncoding}
+
+	for header, expected := range tests {
+		if encoding := negotiate(header); encoding != expected {
+			t.Errorf("%q: expecting %q, got %q", header, expected, encoding)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	body := strings.Repeat(`{"name":"bucket","size":100}`, 100)
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/encoded":
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, body)
+		default:
+			w.Header().Set("Content-Length", "2800")
+			io.WriteString(w, body)
+		}
+	}))
+
+	tests := []struct {
+		path, acceptEncoding, expected string
+	}{
+		{"/", "gzip, deflate", gzipEncoding},
+		{"/", "deflate", deflateEncoding},
+		{"/", "", ""},
+		{"/empty", "gzip", ""},
+		{"/encoded", "gzip", "br"}}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.path, nil)
+		r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if encoding := w.Header().Get("Content-Encoding"); encoding != test.expected {
+			t.Errorf("%v %q: expecting encoding %q, got %q", test.path, test.acceptEncoding, test.expected, encoding)
+		}
+
+		if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%v %q: expecting to vary by Accept-Encoding, got %q", test.path, test.acceptEncoding, vary)
+		}
+
+		var reader io.Reader = w.Body
+		switch test.expected {
+		case gzipEncoding:
+			gz, e := gzip.NewReader(w.Body)
+			if e != nil {
+				t.Fatal(e)
+			}
+			reader = gz
+		case deflateEncoding:
+			z, e := zlib.NewReader(w.Body)
+			if e != nil {
+				t.Fatal(e)
+			}
+			reader = z
+		}
+
+		if test.expected != "" && test.expected != "br" {
+			if w.Header().Get("Content-Length") != "" || w.Body.Len() >= len(body) {
+				t.Errorf("%v %q: expecting a compressed body of unknown length, got %v bytes, Content-Length %q",
+					test.path, test.acceptEncoding, w.Body.Len(), w.Header().Get("Content-Length"))
+			}
+		}
+
+		b, e := ioutil.ReadAll(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if test.path != "/empty" && string(b) != body {
+			t.Errorf("%v %q: expecting the body, got %q", test.path, test.acceptEncoding, b)
+		}
+	}
+}
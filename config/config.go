@@ -6,16 +6,15 @@ package config
 
 import (
 	"fmt"
+	"hash/fnv"
 	"io"
 	"io/ioutil"
-
-	"gopkg.in/yaml.v2"
-
-	"encoding/json"
+	"math/rand"
+	"sort"
+	"time"
 
 	"bytes"
 	"github.com/golang/protobuf/proto"
-	"github.com/maniksurtani/quotaservice/logging"
 	pb "github.com/maniksurtani/quotaservice/protos/config"
 )
 
@@ -23,12 +22,274 @@ const (
 	GlobalNamespace           = "___GLOBAL___"
 	DefaultBucketName         = "___DEFAULT_BUCKET___"
 	DynamicBucketTemplateName = "___DYNAMIC_BUCKET_TPL___"
+	// CeilingBucketName is the name of namespaces' ceilings, which buckets name as their parent to
+	// be capped by their namespace as a whole.
+	CeilingBucketName = "___CEILING___"
+	// RateCapBucketName is the name of the bucket enforcing the GlobalRateCap, which every bucket
+	// draws from after its own parents.
+	RateCapBucketName = "___RATE_CAP___"
+	// AdminNamespace holds the buckets limiting how often each client may change configs via the
+	// admin API. It is internal to the server, so can't be used in configs.
+	AdminNamespace = "___ADMIN___"
+)
+
+// Algorithms buckets can limit requests with.
+const (
+	// TokenBucketAlgorithm fills buckets with fill_rate tokens per fill period, up to size, lending
+	// clients tokens from the future up to max_debt_millis. It's the default.
+	TokenBucketAlgorithm = "token_bucket"
+	// SlidingWindowAlgorithm allows size tokens per rolling window of window_millis, weighting the
+	// previous window's count by how much of it overlaps the rolling window. Requests that don't
+	// fit are rejected rather than made to wait.
+	SlidingWindowAlgorithm = "sliding_window"
+	// GCRAAlgorithm is the generic cell rate algorithm, which paces tokens emission_interval apart,
+	// allowing bursts of up to burst tokens, by tracking when the next token is theoretically due.
+	GCRAAlgorithm = "gcra"
+	// ConcurrencyAlgorithm limits the tokens in use at once to size, rather than the rate they're
+	// claimed at. Tokens are leased, and return to the bucket when the lease is released, or expires
+	// after lease_ttl_millis.
+	ConcurrencyAlgorithm = "concurrency"
+)
+
+// Modes buckets can be put in, such as during incidents or migrations, without losing their state.
+const (
+	// BucketModeNormal limits requests as the bucket is configured to. It's the default.
+	BucketModeNormal = "normal"
+	// BucketModeAlwaysAllow grants requests without claiming the bucket's tokens, bypassing it.
+	BucketModeAlwaysAllow = "always_allow"
+	// BucketModeAlwaysDeny refuses all requests for the bucket's tokens.
+	BucketModeAlwaysDeny = "always_deny"
+	// BucketModeShadow limits requests as BucketModeNormal does, claiming tokens and emitting events,
+	// but grants those it would refuse, so that configs can be tried against traffic before they're
+	// enforced.
+	BucketModeShadow = "shadow"
+)
+
+// Eviction policies, deciding what happens when a namespace already has max_dynamic_buckets dynamic
+// buckets and another is needed.
+const (
+	// EvictionReject refuses to create the bucket. It's the default.
+	EvictionReject = "reject"
+	// EvictionLRU evicts the dynamic bucket used least recently to make room.
+	EvictionLRU = "lru"
+	// EvictionLowestUse evicts the dynamic bucket that has been asked for tokens the fewest times.
+	EvictionLowestUse = "lowest_use"
+)
+
+// Backends namespaces' buckets can be kept in.
+const (
+	// BackendMemory keeps buckets in the memory of each instance, so that every instance enforces
+	// its own limits. It's the default.
+	BackendMemory = "memory"
+	// BackendRedis keeps buckets in Redis, so that all instances sharing it enforce a single limit.
+	BackendRedis = "redis"
+	// BackendDynamo keeps buckets in a DynamoDB table, so that all instances sharing it enforce a
+	// single limit, with state that survives restarts.
+	BackendDynamo = "dynamo"
+	// BackendCassandra counts buckets' tokens in a Cassandra or Scylla table, for long-period quotas,
+	// such as per day or month, that must survive restarts and be shared across regions.
+	BackendCassandra = "cassandra"
+)
+
+// Modes Cassandra buckets count tokens in.
+const (
+	// CassandraCounter counts tokens in counter columns. Counters can't be updated conditionally,
+	// so tokens are counted before checking the limit, and given back if it's exceeded: claims
+	// racing for a quota's last tokens may all be refused. It's the default.
+	CassandraCounter = "counter"
+	// CassandraLWT claims tokens with lightweight transactions, enforcing quotas strictly at the
+	// cost of the round trips of Paxos.
+	CassandraLWT = "lwt"
 )
 
 type ServiceConfig struct {
 	GlobalDefaultBucket *BucketConfig               `yaml:"global_default_bucket,flow"`
 	Namespaces          map[string]*NamespaceConfig `yaml:",flow"`
 	Version             int
+	// When the change resulting in this version was made (Unix time, in millis).
+	Date int64 `yaml:"-"`
+	// Defaults for any settings buckets and their namespaces don't specify, taking precedence over
+	// the BucketDefaults set with SetDefaults.
+	Defaults *BucketConfig `yaml:"defaults,flow"`
+	// Set by a ConfigSigner, if configs are signed.
+	Signature []byte `yaml:"-"`
+	// Who made the change resulting in this version, if known.
+	User string `yaml:"-"`
+	// GlobalRateCap bounds the tokens granted per second across all namespaces, after buckets'
+	// own limits, protecting infrastructure shared by them all from their combined load. 0 doesn't
+	// cap them.
+	GlobalRateCap int64 `yaml:"global_rate_cap"`
+	// Redis is how to connect to Redis, for namespaces whose buckets are backed by it. It's read
+	// when the server starts, so changes only apply once it's restarted.
+	Redis *RedisConfig `yaml:"redis,flow"`
+	// Dynamo is where in DynamoDB to keep the buckets of namespaces backed by it. Like Redis, it's
+	// read when the server starts.
+	Dynamo *DynamoConfig `yaml:"dynamo,flow"`
+	// Cassandra is where in Cassandra to count the tokens of namespaces backed by it. Like Redis,
+	// it's read when the server starts.
+	Cassandra *CassandraConfig `yaml:"cassandra,flow"`
+}
+
+// RedisConfig has the settings of the connection to Redis. Passwords can be given as environment
+// variables, such as ${REDIS_PASSWORD}, to keep them out of config files. They're only expanded when
+// connecting, with ExpandedPassword, so configs served, exported and persisted keep the reference.
+type RedisConfig struct {
+	// Addr is the host:port of the server.
+	Addr     string
+	Password string
+	DB       int64 `yaml:"db"`
+	// PoolSize is the number of connections kept open to the server, or 0 for the client's default.
+	PoolSize int `yaml:"pool_size"`
+	// ConnectionRetries is the number of attempts made to reconnect before giving up on a request.
+	ConnectionRetries int `yaml:"connection_retries"`
+}
+
+// ExpandedPassword returns the password, with the environment variables it references expanded.
+func (r *RedisConfig) ExpandedPassword() string {
+	return expandSecret(r.Password)
+}
+
+// DynamoConfig has the settings of the DynamoDB table bucket state is kept in. Credentials are found
+// where the AWS SDKs look for them: the standard environment variables, a web identity token, ECS'
+// container credentials, or the EC2 instance profile.
+type DynamoConfig struct {
+	Region string
+	// Table is the name of the table, whose partition key is a string attribute named id.
+	Table string
+	// Endpoint is the URL of the DynamoDB API, if not the region's, such as for DynamoDB Local.
+	Endpoint string
+	// MaxAttempts is the number of attempts made at claiming tokens when other instances claim them
+	// at the same time. Defaults to 5.
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// CassandraConfig has the settings of the Cassandra table tokens are counted in. Passwords can be
+// given as environment variables, as with RedisConfig, and are likewise only expanded when
+// connecting, with ExpandedPassword.
+type CassandraConfig struct {
+	// Hosts are the host:port of the nodes to connect to, tried in turn.
+	Hosts []string `yaml:",flow"`
+	// Keyspace and Table name the table, whose partition key is a text column named id, clustered by
+	// a bigint column named period, and tokens counted in a column named used: a counter, or a
+	// bigint in CassandraLWT mode.
+	Keyspace string
+	Table    string
+	Username string
+	Password string
+	// Consistency is the consistency level of reads and writes, such as local_quorum, or
+	// each_quorum to share quotas across regions strictly. Defaults to quorum.
+	Consistency string
+	// Mode is how tokens are counted: CassandraCounter, the default, or CassandraLWT.
+	Mode string
+	// MaxAttempts is the number of attempts made at claiming tokens in CassandraLWT mode when other
+	// instances claim them at the same time. Defaults to 5.
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// ExpandedPassword returns the password, with the environment variables it references expanded.
+func (c *CassandraConfig) ExpandedPassword() string {
+	return expandSecret(c.Password)
+}
+
+// BucketDefaults are the settings buckets get when neither they nor the configs they belong to
+// specify them. Buckets that don't specify max tokens per request default to their fill rate.
+type BucketDefaults struct {
+	Size              int64
+	FillRate          int64
+	WaitTimeoutMillis int64
+	MaxIdleMillis     int64
+	MaxDebtMillis     int64
+	// Only applied to SlidingWindowAlgorithm buckets.
+	WindowMillis int64
+	// Only applied to ConcurrencyAlgorithm buckets.
+	LeaseTTLMillis int64
+}
+
+// builtinDefaults are the BucketDefaults unless SetDefaults is called, and fill in the settings
+// SetDefaults is passed no default for that buckets can't do without.
+var builtinDefaults = BucketDefaults{
+	Size:              100,
+	FillRate:          50,
+	WaitTimeoutMillis: 1000,
+	MaxIdleMillis:     -1,
+	MaxDebtMillis:     10000,
+	WindowMillis:      1000,
+	LeaseTTLMillis:    60000}
+
+var defaults = builtinDefaults
+
+// SetDefaults changes the BucketDefaults applied to configs read from here on. Should be called
+// before any configs are read. Size, FillRate, WindowMillis and LeaseTTLMillis left at 0 keep their
+// built-in defaults, as buckets can't do without them; negative ones panic.
+func SetDefaults(d BucketDefaults) {
+	for _, setting := range []struct {
+		name    string
+		value   *int64
+		builtin int64
+	}{
+		{"Size", &d.Size, builtinDefaults.Size},
+		{"FillRate", &d.FillRate, builtinDefaults.FillRate},
+		{"WindowMillis", &d.WindowMillis, builtinDefaults.WindowMillis},
+		{"LeaseTTLMillis", &d.LeaseTTLMillis, builtinDefaults.LeaseTTLMillis}} {
+		if *setting.value < 0 {
+			panic(fmt.Sprintf("Default %v cannot be negative, but was %v", setting.name, *setting.value))
+		}
+
+		if *setting.value == 0 {
+			*setting.value = setting.builtin
+		}
+	}
+
+	defaults = d
+}
+
+// VersionMismatchError is returned when a change is based on a version of the ServiceConfig other
+// than the current, live one.
+type VersionMismatchError struct {
+	Current, Requested int
+}
+
+func (e VersionMismatchError) Error() string {
+	return fmt.Sprintf("Config has moved on. Change based on version %v, but current version is %v.",
+		e.Requested, e.Current)
+}
+
+// UnknownVersionError is returned when a version of the ServiceConfig is requested that isn't
+// available in the config history.
+type UnknownVersionError struct {
+	Version int
+}
+
+func (e UnknownVersionError) Error() string {
+	return fmt.Sprintf("Config version %v is not available in the config history.", e.Version)
+}
+
+// NotFoundError is returned when a change refers to a namespace or bucket that doesn't exist.
+type NotFoundError struct {
+	Message string
+}
+
+func (e NotFoundError) Error() string {
+	return e.Message
+}
+
+// AlreadyExistsError is returned when adding a namespace, alias or bucket whose name is taken.
+type AlreadyExistsError struct {
+	Message string
+}
+
+func (e AlreadyExistsError) Error() string {
+	return e.Message
+}
+
+// CheckVersion returns a VersionMismatchError if version isn't the same as the current version of
+// this ServiceConfig.
+func (s *ServiceConfig) CheckVersion(version int) error {
+	if s.Version != version {
+		return VersionMismatchError{Current: s.Version, Requested: version}
+	}
+
+	return nil
 }
 
 func (s *ServiceConfig) String() string {
@@ -50,44 +311,115 @@ func (s *ServiceConfig) Equals(other *ServiceConfig) bool {
 	return proto.Equal(p1, p2)
 }
 
+// Clone returns a deep copy of the config, which can be modified without affecting the original.
+func (s *ServiceConfig) Clone() *ServiceConfig {
+	if s == nil {
+		return nil
+	}
+
+	c := *s
+	c.GlobalDefaultBucket = s.GlobalDefaultBucket.Clone()
+	c.Defaults = s.Defaults.Clone()
+	c.Signature = append([]byte(nil), s.Signature...)
+	if s.Redis != nil {
+		r := *s.Redis
+		c.Redis = &r
+	}
+	if s.Dynamo != nil {
+		d := *s.Dynamo
+		c.Dynamo = &d
+	}
+	if s.Cassandra != nil {
+		cs := *s.Cassandra
+		cs.Hosts = append([]string(nil), s.Cassandra.Hosts...)
+		c.Cassandra = &cs
+	}
+	if s.Namespaces != nil {
+		c.Namespaces = make(map[string]*NamespaceConfig, len(s.Namespaces))
+		for name, ns := range s.Namespaces {
+			c.Namespaces[name] = ns.Clone()
+		}
+	}
+
+	return &c
+}
+
 func (s *ServiceConfig) ToProto() *pb.ServiceConfig {
 	return &pb.ServiceConfig{
 		Version:             int32(s.Version),
+		Date:                s.Date,
 		GlobalDefaultBucket: bucketToProto(DefaultBucketName, s.GlobalDefaultBucket),
-		Namespaces:          namespaceMapToProto(s.Namespaces)}
+		Namespaces:          namespaceMapToProto(s.Namespaces),
+		Defaults:            bucketToProto("", s.Defaults),
+		Signature:           s.Signature,
+		SchemaVersion:       CurrentSchemaVersion,
+		User:                s.User,
+		GlobalRateCap:       s.GlobalRateCap,
+		Redis:               redisToProto(s.Redis),
+		Dynamo:              dynamoToProto(s.Dynamo),
+		Cassandra:           cassandraToProto(s.Cassandra)}
+}
+
+// RateCapBucket returns the config of the bucket enforcing the GlobalRateCap, which holds a
+// second's worth of tokens, or nil if there's no cap.
+func (s *ServiceConfig) RateCapBucket() *BucketConfig {
+	if s.GlobalRateCap <= 0 {
+		return nil
+	}
+
+	b := &BucketConfig{Name: RateCapBucketName, Size: s.GlobalRateCap, FillRate: s.GlobalRateCap}
+	return b.ApplyDefaults()
 }
 
 func (s *ServiceConfig) ApplyDefaults() *ServiceConfig {
 	if s.GlobalDefaultBucket != nil {
-		s.GlobalDefaultBucket.ApplyDefaults()
+		s.GlobalDefaultBucket.ApplyDefaultsFrom(s.Defaults).ApplyDefaults()
 		s.GlobalDefaultBucket.Name = DefaultBucketName
 	}
 
+	aliases := make(map[string]string)
 	for name, ns := range s.Namespaces {
 		ns.Name = name
 		if ns.DefaultBucket != nil && ns.DynamicBucketTemplate != nil {
 			panic(fmt.Sprintf("Namespace %v is not allowed to have a default bucket as well as allow dynamic buckets.", name))
 		}
 
+		for _, alias := range ns.Aliases {
+			if _, exists := s.Namespaces[alias]; exists {
+				panic(fmt.Sprintf("Alias %v of namespace %v is already a namespace.", alias, name))
+			}
+
+			if other, exists := aliases[alias]; exists {
+				panic(fmt.Sprintf("Alias %v is used by namespaces %v and %v.", alias, other, name))
+			}
+			aliases[alias] = name
+		}
+
 		// Ensure the namespace's bucket map exists.
 		if ns.Buckets == nil {
 			ns.Buckets = make(map[string]*BucketConfig)
 		}
 
 		if ns.DefaultBucket != nil {
-			ns.DefaultBucket.ApplyDefaults()
+			ns.DefaultBucket.ApplyDefaultsFrom(ns.Defaults).ApplyDefaultsFrom(s.Defaults).ApplyDefaults()
 			ns.DefaultBucket.Name = DefaultBucketName
 			ns.DefaultBucket.namespace = ns
 		}
 
+		if ns.Ceiling != nil {
+			ns.Ceiling.ApplyDefaultsFrom(ns.Defaults).ApplyDefaultsFrom(s.Defaults).ApplyDefaults()
+			ns.Ceiling.Name = CeilingBucketName
+			ns.Ceiling.namespace = ns
+		}
+
 		if ns.DynamicBucketTemplate != nil {
-			ns.DynamicBucketTemplate.ApplyDefaults()
+			ns.DynamicBucketTemplate.ApplyDefaultsFrom(ns.Defaults).ApplyDefaultsFrom(s.Defaults).ApplyDefaults()
 			ns.DynamicBucketTemplate.Name = DynamicBucketTemplateName
 			ns.DynamicBucketTemplate.namespace = ns
 		}
 
 		for n, b := range ns.Buckets {
-			b.ApplyDefaults()
+			b.ApplyDefaultsFrom(ns.Defaults).ApplyDefaultsFrom(s.Defaults).ApplyDefaults()
 			b.Name = n
 			b.namespace = ns
 		}
@@ -115,6 +447,33 @@ type NamespaceConfig struct {
 	MaxDynamicBuckets     int                      `yaml:"max_dynamic_buckets"`
 	Buckets               map[string]*BucketConfig `yaml:",flow"`
 	Name                  string
+	// Defaults for any settings the namespace's buckets don't specify, taking precedence over the
+	// global defaults.
+	Defaults *BucketConfig `yaml:"defaults,flow"`
+	// Labels tag the namespace with details such as its owner, and apply to all of its buckets.
+	Labels map[string]string `yaml:",flow"`
+	// Aliases are other names the namespace can be looked up by, so that clients can be moved to a
+	// new name gradually.
+	Aliases []string `yaml:",flow"`
+	// Ceiling caps the namespace's buckets whose parent is CeilingBucketName, such as per-customer
+	// buckets that shouldn't exceed a limit for the namespace as a whole.
+	Ceiling *BucketConfig `yaml:"ceiling,flow"`
+	// Eviction is what happens when the namespace already has MaxDynamicBuckets dynamic buckets and
+	// another is needed. Defaults to EvictionReject.
+	Eviction string
+	// Pooled has all of the namespace's buckets draw from its ceiling as well as their own limits,
+	// whether or not they name it as their parent, so that the namespace as a whole is capped.
+	Pooled bool
+	// Shadow puts those of the namespace's buckets in BucketModeNormal in BucketModeShadow.
+	Shadow bool
+	// Backend is where the namespace's buckets are kept, such as BackendRedis. Defaults to
+	// BackendMemory.
+	Backend string
+	// Tolerance, if positive, has each instance grant requests for the namespace's buckets from a
+	// local slice of that fraction of their sizes, reconciling the tokens granted with the shared
+	// backend asynchronously, rather than consulting it on every request. Instances may together
+	// overshoot a limit by a slice each between reconciliations.
+	Tolerance float64
 }
 
 func (n *NamespaceConfig) AddBucket(name string, b *BucketConfig) *NamespaceConfig {
@@ -131,13 +490,47 @@ func (n *NamespaceConfig) SetDynamicBucketTemplate(b *BucketConfig) *NamespaceCo
 	return n
 }
 
+// Clone returns a deep copy of the namespace, whose buckets belong to the copy.
+func (n *NamespaceConfig) Clone() *NamespaceConfig {
+	if n == nil {
+		return nil
+	}
+
+	c := *n
+	c.Labels = cloneLabels(n.Labels)
+	if n.Aliases != nil {
+		c.Aliases = append([]string{}, n.Aliases...)
+	}
+	c.Defaults = n.Defaults.cloneInto(&c)
+	c.DefaultBucket = n.DefaultBucket.cloneInto(&c)
+	c.DynamicBucketTemplate = n.DynamicBucketTemplate.cloneInto(&c)
+	c.Ceiling = n.Ceiling.cloneInto(&c)
+	if n.Buckets != nil {
+		c.Buckets = make(map[string]*BucketConfig, len(n.Buckets))
+		for name, b := range n.Buckets {
+			c.Buckets[name] = b.cloneInto(&c)
+		}
+	}
+
+	return &c
+}
+
 func (n *NamespaceConfig) ToProto() *pb.NamespaceConfig {
 	return &pb.NamespaceConfig{
 		DefaultBucket:         bucketToProto(DefaultBucketName, n.DefaultBucket),
 		DynamicBucketTemplate: bucketToProto(DynamicBucketTemplateName, n.DynamicBucketTemplate),
 		MaxDynamicBuckets:     int32(n.MaxDynamicBuckets),
 		Buckets:               bucketMapToProto(n.Buckets),
-		Name:                  n.Name}
+		Name:                  n.Name,
+		Defaults:              bucketToProto("", n.Defaults),
+		Ceiling:               bucketToProto(CeilingBucketName, n.Ceiling),
+		Labels:                n.Labels,
+		Aliases:               n.Aliases,
+		Eviction:              n.Eviction,
+		Pooled:                n.Pooled,
+		Shadow:                n.Shadow,
+		Backend:               n.Backend,
+		Tolerance:             n.Tolerance}
 }
 
 type BucketConfig struct {
@@ -149,12 +542,218 @@ type BucketConfig struct {
 	MaxTokensPerRequest int64 `yaml:"max_tokens_per_request"`
 	namespace           *NamespaceConfig
 	Name                string
+	// Labels tag the bucket with details such as its owner, team or cost center.
+	Labels map[string]string `yaml:",flow"`
+	// Algorithm the bucket limits requests with. Defaults to TokenBucketAlgorithm.
+	Algorithm string
+	// WindowMillis is the rolling window SlidingWindowAlgorithm buckets allow Size tokens in.
+	WindowMillis int64 `yaml:"window_millis"`
+	// Burst is how many tokens GCRAAlgorithm buckets allow to be claimed at once, defaulting to
+	// Size, and EmissionInterval the nanos between their tokens, defaulting to the fill period over
+	// FillRate.
+	Burst            int64
+	EmissionInterval int64 `yaml:"emission_interval"`
+	// LeaseTTLMillis is how long ConcurrencyAlgorithm buckets hold tokens leased by clients that
+	// don't release them, such as clients that crashed.
+	LeaseTTLMillis int64 `yaml:"lease_ttl_millis"`
+	// Parent is a bucket in the same namespace, or CeilingBucketName, that tokens must also be
+	// available in for them to be granted. Parents may have parents of their own. Unlike other
+	// settings, parents aren't inherited from defaults.
+	Parent string
+	// MaxWaiters is how many callers may be waiting for the bucket's tokens, either within Take or
+	// having been told to wait before using them, before further requests are rejected outright.
+	// Defaults to 0, which doesn't limit them.
+	MaxWaiters int64 `yaml:"max_waiters"`
+	// FIFO queues callers that can't be granted tokens straight away, retrying them in arrival
+	// order for up to their max wait time, so that later, smaller requests can't starve them.
+	FIFO bool `yaml:"fifo"`
+	// HighPriorityReserve is how many of the bucket's tokens are held back for high priority
+	// requests, so that normal priority requests, such as those of background jobs, can't use them
+	// all up.
+	HighPriorityReserve int64 `yaml:"high_priority_reserve"`
+	// Costs are the tokens each operation costs, multiplying the tokens requested by callers naming
+	// their operation. Operations that aren't listed cost a token.
+	Costs map[string]int64 `yaml:",flow"`
+	// Adaptive has the bucket's fill rate adapt to the health of the downstream it protects.
+	Adaptive *AdaptiveConfig `yaml:"adaptive,flow"`
+	// InitialFillPercent is the share of its tokens, from 0 to 100, that the bucket starts with, so
+	// that many dynamic buckets created at once can't all be drained in a burst. Buckets start full
+	// if it isn't set.
+	InitialFillPercent *int64 `yaml:"initial_fill_percent"`
+	// Schedule changes the bucket's size and fill rate during windows of the day, such as lowering
+	// them overnight. The first entry whose window the current time falls in applies.
+	Schedule []*ScheduleEntry
+	// FillPeriodMillis is the period FillRate tokens are added over, defaulting to a second, so that
+	// low rates such as 10 tokens a minute can be expressed exactly.
+	FillPeriodMillis int64 `yaml:"fill_period_millis"`
+	// Mode the bucket is in, such as BucketModeAlwaysDeny. Defaults to BucketModeNormal. Unlike other
+	// settings, modes aren't inherited from defaults, and buckets keep their state when only their
+	// mode changes.
+	Mode string
+	// EnforcementPercent is the share of requests, from 0 to 100, that the bucket's refusals apply
+	// to, so that a new limit can be rolled out gradually. Other requests it would refuse are granted
+	// as in BucketModeShadow. Callers are picked by hashing their identity, so the same callers
+	// are enforced as the share grows. Refusals apply to all requests if it isn't set.
+	EnforcementPercent *int64 `yaml:"enforcement_percent"`
+}
+
+// FillPeriod returns the period FillRate tokens are added over: FillPeriodMillis, or a second if it
+// isn't set.
+func (b *BucketConfig) FillPeriod() time.Duration {
+	if b.FillPeriodMillis <= 0 {
+		return time.Second
+	}
+
+	return time.Duration(b.FillPeriodMillis) * time.Millisecond
+}
+
+// NanosPerToken returns the nanos between tokens added at fillRate tokens per fill period.
+// Panics if fillRate isn't positive, as no number of nanos adds tokens at that rate; validated
+// configs always have a positive fill rate.
+func (b *BucketConfig) NanosPerToken(fillRate int64) int64 {
+	if fillRate <= 0 {
+		panic(fmt.Sprintf("Bucket %v cannot add tokens at a fill rate of %v", b.FQN(), fillRate))
+	}
+
+	return int64(b.FillPeriod()) / fillRate
+}
+
+// InitialTokens returns how many of a bucket's capacity tokens it starts with.
+func (b *BucketConfig) InitialTokens(capacity int64) int64 {
+	if b.InitialFillPercent == nil {
+		return capacity
+	}
+
+	return capacity * *b.InitialFillPercent / 100
+}
+
+// Enforces tells whether the bucket's refusals apply to requests by caller, as per its
+// EnforcementPercent. Requests by callers that don't identify themselves are picked at random.
+func (b *BucketConfig) Enforces(caller string) bool {
+	if b.EnforcementPercent == nil {
+		return true
+	}
+
+	if caller == "" {
+		return rand.Int63n(100) < *b.EnforcementPercent
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(caller))
+	return int64(h.Sum32()%100) < *b.EnforcementPercent
+}
+
+// AdaptiveConfig has a bucket's fill rate follow the health of the downstream it protects, as
+// reported by clients: backing off multiplicatively while the downstream is unhealthy, and
+// recovering additively while it's healthy, up to the bucket's FillRate.
+type AdaptiveConfig struct {
+	// MaxErrorRate, from 0 to 1, and MaxLatencyMillis are the error rate and latency beyond which
+	// the downstream is unhealthy. Thresholds that aren't set don't apply.
+	MaxErrorRate     float64 `yaml:"max_error_rate"`
+	MaxLatencyMillis int64   `yaml:"max_latency_millis"`
+	// BackoffPercent is the share of the fill rate kept each time the downstream is reported
+	// unhealthy. Defaults to 50.
+	BackoffPercent int64 `yaml:"backoff_percent"`
+	// Increase is the tokens per second added back each time the downstream is reported healthy.
+	// Defaults to a tenth of the bucket's FillRate.
+	Increase int64
+	// MinFillRate is the lowest the fill rate backs off to. Defaults to 1.
+	MinFillRate int64 `yaml:"min_fill_rate"`
+}
+
+// Healthy tells whether a downstream with the error rate and latency reported is healthy.
+func (a *AdaptiveConfig) Healthy(errorRate float64, latency time.Duration) bool {
+	if a.MaxErrorRate > 0 && errorRate > a.MaxErrorRate {
+		return false
+	}
+
+	return a.MaxLatencyMillis <= 0 || latency <= time.Duration(a.MaxLatencyMillis)*time.Millisecond
+}
+
+func (a *AdaptiveConfig) toProto() *pb.AdaptiveConfig {
+	if a == nil {
+		return nil
+	}
+
+	return &pb.AdaptiveConfig{
+		MaxErrorRate:     a.MaxErrorRate,
+		MaxLatencyMillis: a.MaxLatencyMillis,
+		BackoffPercent:   a.BackoffPercent,
+		Increase:         a.Increase,
+		MinFillRate:      a.MinFillRate}
+}
+
+func adaptiveFromProto(cfg *pb.AdaptiveConfig) *AdaptiveConfig {
+	if cfg == nil {
+		return nil
+	}
+
+	return &AdaptiveConfig{
+		MaxErrorRate:     cfg.MaxErrorRate,
+		MaxLatencyMillis: cfg.MaxLatencyMillis,
+		BackoffPercent:   cfg.BackoffPercent,
+		Increase:         cfg.Increase,
+		MinFillRate:      cfg.MinFillRate}
 }
 
 func (b *BucketConfig) String() string {
 	return fmt.Sprint(*b)
 }
 
+// Clone returns a copy of the bucket, belonging to the same namespace.
+func (b *BucketConfig) Clone() *BucketConfig {
+	if b == nil {
+		return nil
+	}
+
+	return b.cloneInto(b.namespace)
+}
+
+func (b *BucketConfig) cloneInto(ns *NamespaceConfig) *BucketConfig {
+	if b == nil {
+		return nil
+	}
+
+	c := *b
+	c.Labels = cloneLabels(b.Labels)
+	if b.Costs != nil {
+		c.Costs = make(map[string]int64, len(b.Costs))
+		for op, cost := range b.Costs {
+			c.Costs[op] = cost
+		}
+	}
+
+	if b.Adaptive != nil {
+		a := *b.Adaptive
+		c.Adaptive = &a
+	}
+
+	if b.InitialFillPercent != nil {
+		p := *b.InitialFillPercent
+		c.InitialFillPercent = &p
+	}
+
+	if b.EnforcementPercent != nil {
+		p := *b.EnforcementPercent
+		c.EnforcementPercent = &p
+	}
+
+	if b.Schedule != nil {
+		c.Schedule = make([]*ScheduleEntry, len(b.Schedule))
+		for i, s := range b.Schedule {
+			entry := *s
+			entry.Days = append([]string(nil), s.Days...)
+			c.Schedule[i] = &entry
+		}
+	}
+
+	if b.namespace != nil {
+		c.namespace = ns
+	}
+
+	return &c
+}
+
 func (b *BucketConfig) ToProto() *pb.BucketConfig {
 	return &pb.BucketConfig{
 		Size:                b.Size,
@@ -163,37 +762,268 @@ func (b *BucketConfig) ToProto() *pb.BucketConfig {
 		MaxIdleMillis:       b.MaxIdleMillis,
 		MaxDebtMillis:       b.MaxDebtMillis,
 		MaxTokensPerRequest: b.MaxTokensPerRequest,
-		Name:                b.Name}
+		Name:                b.Name,
+		Labels:              b.Labels,
+		Algorithm:           b.Algorithm,
+		WindowMillis:        b.WindowMillis,
+		Burst:               b.Burst,
+		EmissionInterval:    b.EmissionInterval,
+		LeaseTtlMillis:      b.LeaseTTLMillis,
+		Parent:              b.Parent,
+		MaxWaiters:          b.MaxWaiters,
+		Fifo:                b.FIFO,
+		HighPriorityReserve: b.HighPriorityReserve,
+		Costs:               b.Costs,
+		Adaptive:            b.Adaptive.toProto(),
+		InitialFillPercent:  int64ValueToProto(b.InitialFillPercent),
+		Schedule:            scheduleToProto(b.Schedule),
+		FillPeriodMillis:    b.FillPeriodMillis,
+		Mode:                b.Mode,
+		EnforcementPercent:  int64ValueToProto(b.EnforcementPercent)}
+}
+
+// Equals tells you whether two bucket configs have the same settings.
+func (b *BucketConfig) Equals(other *BucketConfig) bool {
+	if b == nil || other == nil {
+		return b == other
+	}
+
+	return proto.Equal(b.ToProto(), other.ToProto())
+}
+
+// EffectiveMode returns the mode the bucket is in: its Mode, unless that's BucketModeNormal and its
+// namespace is in shadow, in which case it's BucketModeShadow.
+func (b *BucketConfig) EffectiveMode() string {
+	if (b.Mode == "" || b.Mode == BucketModeNormal) && b.namespace != nil && b.namespace.Shadow {
+		return BucketModeShadow
+	}
+
+	return b.Mode
+}
+
+// Backend returns where the bucket is kept: its namespace's backend, or BackendMemory if the
+// namespace doesn't say or the bucket isn't in one.
+func (b *BucketConfig) Backend() string {
+	if b.namespace == nil || b.namespace.Backend == "" {
+		return BackendMemory
+	}
+
+	return b.namespace.Backend
+}
+
+// Tolerance returns the fraction of the bucket's size instances may grant locally before
+// reconciling with the shared backend, as per its namespace's Tolerance, or 0 if tokens are granted
+// by the backend.
+func (b *BucketConfig) Tolerance() float64 {
+	if b.namespace == nil || b.Backend() == BackendMemory {
+		return 0
+	}
+
+	return b.namespace.Tolerance
+}
+
+// EqualsExceptEnforcement tells you whether two bucket configs have the same settings, other than
+// how they're enforced: their modes and enforcement percents.
+func (b *BucketConfig) EqualsExceptEnforcement(other *BucketConfig) bool {
+	if b == nil || other == nil {
+		return b == other
+	}
+
+	p, o := b.ToProto(), other.ToProto()
+	p.Mode, o.Mode = "", ""
+	p.EnforcementPercent, o.EnforcementPercent = nil, nil
+	return proto.Equal(p, o)
 }
 
+// ApplyDefaultsFrom copies any settings not specified in this BucketConfig from defaults, which
+// may be nil.
+func (b *BucketConfig) ApplyDefaultsFrom(defaults *BucketConfig) *BucketConfig {
+	if defaults == nil {
+		return b
+	}
+
+	if b.Size == 0 {
+		b.Size = defaults.Size
+	}
+
+	if b.FillRate == 0 {
+		b.FillRate = defaults.FillRate
+		// A fill rate is per its period, so the period is only inherited along with the rate.
+		if b.FillPeriodMillis == 0 {
+			b.FillPeriodMillis = defaults.FillPeriodMillis
+		}
+	}
+
+	if b.WaitTimeoutMillis == 0 {
+		b.WaitTimeoutMillis = defaults.WaitTimeoutMillis
+	}
+
+	if b.MaxIdleMillis == 0 {
+		b.MaxIdleMillis = defaults.MaxIdleMillis
+	}
+
+	if b.MaxDebtMillis == 0 {
+		b.MaxDebtMillis = defaults.MaxDebtMillis
+	}
+
+	if b.MaxTokensPerRequest == 0 {
+		b.MaxTokensPerRequest = defaults.MaxTokensPerRequest
+	}
+
+	if b.Algorithm == "" {
+		b.Algorithm = defaults.Algorithm
+	}
+
+	if b.WindowMillis == 0 {
+		b.WindowMillis = defaults.WindowMillis
+	}
+
+	if b.Burst == 0 {
+		b.Burst = defaults.Burst
+	}
+
+	if b.EmissionInterval == 0 {
+		b.EmissionInterval = defaults.EmissionInterval
+	}
+
+	if b.LeaseTTLMillis == 0 {
+		b.LeaseTTLMillis = defaults.LeaseTTLMillis
+	}
+
+	if b.MaxWaiters == 0 {
+		b.MaxWaiters = defaults.MaxWaiters
+	}
+
+	if !b.FIFO {
+		b.FIFO = defaults.FIFO
+	}
+
+	if b.HighPriorityReserve == 0 {
+		b.HighPriorityReserve = defaults.HighPriorityReserve
+	}
+
+	if b.Costs == nil {
+		b.Costs = defaults.Costs
+	}
+
+	if b.Adaptive == nil && defaults.Adaptive != nil {
+		a := *defaults.Adaptive
+		b.Adaptive = &a
+	}
+
+	if b.InitialFillPercent == nil && defaults.InitialFillPercent != nil {
+		p := *defaults.InitialFillPercent
+		b.InitialFillPercent = &p
+	}
+
+	if b.EnforcementPercent == nil && defaults.EnforcementPercent != nil {
+		p := *defaults.EnforcementPercent
+		b.EnforcementPercent = &p
+	}
+
+	if b.Schedule == nil {
+		b.Schedule = defaults.Schedule
+	}
+
+	return b
+}
+
+// ApplyDefaults applies the BucketDefaults to any settings not specified in this BucketConfig.
 func (b *BucketConfig) ApplyDefaults() *BucketConfig {
 	if b.Size == 0 {
-		b.Size = 100
+		b.Size = defaults.Size
 	}
 
 	if b.FillRate == 0 {
-		b.FillRate = 50
+		b.FillRate = defaults.FillRate
 	}
 
 	if b.WaitTimeoutMillis == 0 {
-		b.WaitTimeoutMillis = 1000
+		b.WaitTimeoutMillis = defaults.WaitTimeoutMillis
 	}
 
 	if b.MaxIdleMillis == 0 {
-		b.MaxIdleMillis = -1
+		b.MaxIdleMillis = defaults.MaxIdleMillis
 	}
 
 	if b.MaxDebtMillis == 0 {
-		b.MaxDebtMillis = 10000
+		b.MaxDebtMillis = defaults.MaxDebtMillis
 	}
 
 	if b.MaxTokensPerRequest == 0 {
 		b.MaxTokensPerRequest = b.FillRate
 	}
 
+	if b.Algorithm == SlidingWindowAlgorithm && b.WindowMillis == 0 {
+		b.WindowMillis = defaults.WindowMillis
+	}
+
+	if b.Algorithm == GCRAAlgorithm {
+		if b.Burst == 0 {
+			b.Burst = b.Size
+		}
+
+		if b.EmissionInterval == 0 && b.FillRate > 0 {
+			b.EmissionInterval = b.NanosPerToken(b.FillRate)
+		}
+	}
+
+	if b.Algorithm == ConcurrencyAlgorithm && b.LeaseTTLMillis == 0 {
+		b.LeaseTTLMillis = defaults.LeaseTTLMillis
+	}
+
+	if a := b.Adaptive; a != nil {
+		if a.BackoffPercent == 0 {
+			a.BackoffPercent = 50
+		}
+
+		if a.Increase == 0 {
+			a.Increase = b.FillRate / 10
+			if a.Increase < 1 {
+				a.Increase = 1
+			}
+		}
+
+		if a.MinFillRate == 0 {
+			a.MinFillRate = 1
+		}
+	}
+
 	return b
 }
 
+// Cost returns the tokens an operation costs, or 1 if the bucket doesn't price the operation.
+func (b *BucketConfig) Cost(operation string) int64 {
+	if cost, ok := b.Costs[operation]; ok {
+		return cost
+	}
+
+	return 1
+}
+
+// AllLabels returns the bucket's labels along with those of its namespace. The bucket's own labels
+// take precedence.
+func (b *BucketConfig) AllLabels() map[string]string {
+	if b.namespace == nil || len(b.namespace.Labels) == 0 {
+		return b.Labels
+	}
+
+	if len(b.Labels) == 0 {
+		return b.namespace.Labels
+	}
+
+	labels := make(map[string]string, len(b.namespace.Labels)+len(b.Labels))
+	for k, v := range b.namespace.Labels {
+		labels[k] = v
+	}
+
+	for k, v := range b.Labels {
+		labels[k] = v
+	}
+
+	return labels
+}
+
 func (b *BucketConfig) FQN() string {
 	if b.namespace == nil {
 		// This is a global default.
@@ -203,38 +1033,69 @@ func (b *BucketConfig) FQN() string {
 	return FullyQualifiedName(b.namespace.Name, b.Name)
 }
 
+// ReadConfigFromFile reads a config file in YAML, or TOML or JSON if the file has a .toml or
+// .json extension. References to environment variables, as ${VAR} or ${VAR:-default}, are
+// expanded. The file may list other files defining namespaces under includes, as paths or globs
+// relative to the file, in any of these formats. Buckets may reference named presets, defined
+// under presets in the main file, as preset: name; any settings they specify override the preset.
 func ReadConfigFromFile(filename string) *ServiceConfig {
+	return readConfigFromFile(filename, false)
+}
+
+// ReadConfigFromFileStrict is like ReadConfigFromFile, but also panics if the file isn't valid
+// YAML, or contains keys that don't map to any config field, such as a misspelled fill_rate.
+func ReadConfigFromFileStrict(filename string) *ServiceConfig {
+	return readConfigFromFile(filename, true)
+}
+
+// ReadConfigFromFileWithOverlays is like ReadConfigFromFile, but merges overlay files, such as
+// per-environment settings, into the file before validating it and applying defaults. Overlays
+// only need to specify the settings they change: mappings are merged key by key, and any other
+// values, including lists, replace the file's. Later overlays take precedence over earlier ones.
+// Overlays may use presets and environment variables, but not include other files.
+func ReadConfigFromFileWithOverlays(filename string, overlays ...string) *ServiceConfig {
+	return readConfigFromFile(filename, false, overlays...)
+}
+
+func readConfigFromFile(filename string, strict bool, overlays ...string) *ServiceConfig {
 	bytes, err := ioutil.ReadFile(filename)
 	if err != nil {
 		panic(fmt.Sprintf("Unable to open file %v. Error: %v", filename, err))
 	}
 
-	return readConfigFromBytes(bytes)
+	return parseConfigFile(filename, bytes, strict, overlays...)
 }
 
+// ReadConfig reads a YAML config, expanding references to environment variables and including
+// other files as ReadConfigFromFile does. Includes are relative to the working directory.
 func ReadConfig(yamlStream io.Reader) *ServiceConfig {
+	return readConfig(yamlStream, false)
+}
+
+// ReadConfigStrict is like ReadConfig, but also panics if the stream isn't valid YAML, or contains
+// keys that don't map to any config field.
+func ReadConfigStrict(yamlStream io.Reader) *ServiceConfig {
+	return readConfig(yamlStream, true)
+}
+
+func readConfig(yamlStream io.Reader, strict bool) *ServiceConfig {
 	bytes, err := ioutil.ReadAll(yamlStream)
 	if err != nil {
 		panic(fmt.Sprintf("Unable to open reader. Error: %v", err))
 	}
 
-	return readConfigFromBytes(bytes)
+	// Read as a YAML file in the working directory.
+	return parseConfigFile("", bytes, strict)
 }
 
 func readConfigFromBytes(bytes []byte) *ServiceConfig {
-	logging.Print(string(bytes))
-	cfg := NewDefaultServiceConfig()
-	cfg.GlobalDefaultBucket = nil
-	yaml.Unmarshal(bytes, cfg)
-
-	return cfg.ApplyDefaults()
+	return parseConfigFile("", bytes, false)
 }
 
 func NewDefaultServiceConfig() *ServiceConfig {
 	return &ServiceConfig{
-		NewDefaultBucketConfig(),
-		make(map[string]*NamespaceConfig),
-		0}
+		GlobalDefaultBucket: NewDefaultBucketConfig(),
+		Namespaces:          make(map[string]*NamespaceConfig)}
 }
 
 func NewDefaultNamespaceConfig() *NamespaceConfig {
@@ -242,10 +1103,28 @@ func NewDefaultNamespaceConfig() *NamespaceConfig {
 }
 
 func NewDefaultBucketConfig() *BucketConfig {
-	return &BucketConfig{Size: 100, FillRate: 50, WaitTimeoutMillis: 1000, MaxIdleMillis: -1, MaxDebtMillis: 10000}
+	return &BucketConfig{
+		Size:              defaults.Size,
+		FillRate:          defaults.FillRate,
+		WaitTimeoutMillis: defaults.WaitTimeoutMillis,
+		MaxIdleMillis:     defaults.MaxIdleMillis,
+		MaxDebtMillis:     defaults.MaxDebtMillis}
 }
 
 // Helpers to read to and write from proto representations
+func cloneLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+
+	return c
+}
+
 func bucketToProto(name string, b *BucketConfig) *pb.BucketConfig {
 	if b == nil {
 		return nil
@@ -254,35 +1133,59 @@ func bucketToProto(name string, b *BucketConfig) *pb.BucketConfig {
 	return b.ToProto()
 }
 
+// bucketMapToProto converts buckets to protos, sorted by name so representations are stable.
 func bucketMapToProto(buckets map[string]*BucketConfig) []*pb.BucketConfig {
 	c := make([]*pb.BucketConfig, 0, len(buckets))
 	for n, b := range buckets {
 		c = append(c, bucketToProto(n, b))
 	}
 
+	sort.Sort(bucketsByName(c))
 	return c
 }
 
+// namespaceMapToProto converts namespaces to protos, sorted by name so representations are stable.
 func namespaceMapToProto(namespaces map[string]*NamespaceConfig) []*pb.NamespaceConfig {
 	c := make([]*pb.NamespaceConfig, 0, len(namespaces))
 	for _, nsp := range namespaces {
 		c = append(c, nsp.ToProto())
 	}
 
+	sort.Sort(namespacesByName(c))
 	return c
 }
 
+type namespacesByName []*pb.NamespaceConfig
+
+func (n namespacesByName) Len() int           { return len(n) }
+func (n namespacesByName) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n namespacesByName) Less(i, j int) bool { return n[i].Name < n[j].Name }
+
+type bucketsByName []*pb.BucketConfig
+
+func (b bucketsByName) Len() int           { return len(b) }
+func (b bucketsByName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b bucketsByName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+
 func FromProto(cfg *pb.ServiceConfig) *ServiceConfig {
 	globalBucket := BucketFromProto(cfg.GlobalDefaultBucket, nil)
 	return &ServiceConfig{
 		GlobalDefaultBucket: globalBucket,
 		Version:             int(cfg.Version),
-		Namespaces:          namespacesFromProto(cfg.Namespaces)}
+		Date:                cfg.Date,
+		Namespaces:          namespacesFromProto(cfg.Namespaces),
+		Defaults:            BucketFromProto(cfg.Defaults, nil),
+		Signature:           cfg.Signature,
+		User:                cfg.User,
+		GlobalRateCap:       cfg.GlobalRateCap,
+		Redis:               redisFromProto(cfg.Redis),
+		Dynamo:              dynamoFromProto(cfg.Dynamo),
+		Cassandra:           cassandraFromProto(cfg.Cassandra)}
 }
 
 func FromJSON(j []byte) (c *ServiceConfig, e error) {
 	p := &pb.ServiceConfig{}
-	e = json.Unmarshal(j, p)
+	e = ProtoFromJSON(j, p)
 	if e == nil {
 		c = FromProto(p)
 	}
@@ -314,10 +1217,127 @@ func BucketFromProto(cfg *pb.BucketConfig, nsc *NamespaceConfig) (b *BucketConfi
 		MaxIdleMillis:       cfg.MaxIdleMillis,
 		MaxDebtMillis:       cfg.MaxDebtMillis,
 		MaxTokensPerRequest: cfg.MaxTokensPerRequest,
-		namespace:           nsc, Name: cfg.Name}
+		namespace:           nsc,
+		Name:                cfg.Name,
+		Labels:              cfg.Labels,
+		Algorithm:           cfg.Algorithm,
+		WindowMillis:        cfg.WindowMillis,
+		Burst:               cfg.Burst,
+		EmissionInterval:    cfg.EmissionInterval,
+		LeaseTTLMillis:      cfg.LeaseTtlMillis,
+		Parent:              cfg.Parent,
+		MaxWaiters:          cfg.MaxWaiters,
+		FIFO:                cfg.Fifo,
+		HighPriorityReserve: cfg.HighPriorityReserve,
+		Costs:               cfg.Costs,
+		Adaptive:            adaptiveFromProto(cfg.Adaptive),
+		InitialFillPercent:  int64ValueFromProto(cfg.InitialFillPercent),
+		Schedule:            scheduleFromProto(cfg.Schedule),
+		FillPeriodMillis:    cfg.FillPeriodMillis,
+		Mode:                cfg.Mode,
+		EnforcementPercent:  int64ValueFromProto(cfg.EnforcementPercent)}
 	return
 }
 
+func int64ValueToProto(v *int64) *pb.Int64Value {
+	if v == nil {
+		return nil
+	}
+
+	return &pb.Int64Value{Value: *v}
+}
+
+func int64ValueFromProto(v *pb.Int64Value) *int64 {
+	if v == nil {
+		return nil
+	}
+
+	value := v.Value
+	return &value
+}
+
+func redisToProto(r *RedisConfig) *pb.RedisConfig {
+	if r == nil {
+		return nil
+	}
+
+	return &pb.RedisConfig{
+		Addr:              r.Addr,
+		Password:          r.Password,
+		Db:                r.DB,
+		PoolSize:          int32(r.PoolSize),
+		ConnectionRetries: int32(r.ConnectionRetries)}
+}
+
+func redisFromProto(r *pb.RedisConfig) *RedisConfig {
+	if r == nil {
+		return nil
+	}
+
+	return &RedisConfig{
+		Addr:              r.Addr,
+		Password:          r.Password,
+		DB:                r.Db,
+		PoolSize:          int(r.PoolSize),
+		ConnectionRetries: int(r.ConnectionRetries)}
+}
+
+func dynamoToProto(d *DynamoConfig) *pb.DynamoConfig {
+	if d == nil {
+		return nil
+	}
+
+	return &pb.DynamoConfig{
+		Region:      d.Region,
+		Table:       d.Table,
+		Endpoint:    d.Endpoint,
+		MaxAttempts: int32(d.MaxAttempts)}
+}
+
+func dynamoFromProto(d *pb.DynamoConfig) *DynamoConfig {
+	if d == nil {
+		return nil
+	}
+
+	return &DynamoConfig{
+		Region:      d.Region,
+		Table:       d.Table,
+		Endpoint:    d.Endpoint,
+		MaxAttempts: int(d.MaxAttempts)}
+}
+
+func cassandraToProto(c *CassandraConfig) *pb.CassandraConfig {
+	if c == nil {
+		return nil
+	}
+
+	return &pb.CassandraConfig{
+		Hosts:       c.Hosts,
+		Keyspace:    c.Keyspace,
+		Table:       c.Table,
+		Username:    c.Username,
+		Password:    c.Password,
+		Consistency: c.Consistency,
+		Mode:        c.Mode,
+		MaxAttempts: int32(c.MaxAttempts)}
+}
+
+func cassandraFromProto(c *pb.CassandraConfig) *CassandraConfig {
+	if c == nil {
+		return nil
+	}
+
+	return &CassandraConfig{
+		Hosts:       c.Hosts,
+		Keyspace:    c.Keyspace,
+		Table:       c.Table,
+		Username:    c.Username,
+		Password:    c.Password,
+		Consistency: c.Consistency,
+		Mode:        c.Mode,
+		MaxAttempts: int(c.MaxAttempts)}
+}
+
 func namespacesFromProto(cfgs []*pb.NamespaceConfig) map[string]*NamespaceConfig {
 	namespaces := make(map[string]*NamespaceConfig, len(cfgs))
 
@@ -338,18 +1358,27 @@ func NamespaceFromProto(cfg *pb.NamespaceConfig) (n *NamespaceConfig) {
 
 	n = &NamespaceConfig{
 		MaxDynamicBuckets: int(cfg.MaxDynamicBuckets),
-		Name:              cfg.Name}
+		Name:              cfg.Name,
+		Labels:            cfg.Labels,
+		Aliases:           cfg.Aliases,
+		Eviction:          cfg.Eviction,
+		Pooled:            cfg.Pooled,
+		Shadow:            cfg.Shadow,
+		Backend:           cfg.Backend,
+		Tolerance:         cfg.Tolerance}
 
 	n.DefaultBucket = BucketFromProto(cfg.DefaultBucket, n)
 	n.DynamicBucketTemplate = BucketFromProto(cfg.DynamicBucketTemplate, n)
 	n.Buckets = bucketsFromProto(cfg.Buckets, n)
+	n.Defaults = BucketFromProto(cfg.Defaults, n)
+	n.Ceiling = BucketFromProto(cfg.Ceiling, n)
 
 	return
 }
 
 func NamespaceFromJSON(j []byte) (n *NamespaceConfig, e error) {
 	p := &pb.NamespaceConfig{}
-	e = json.Unmarshal(j, p)
+	e = ProtoFromJSON(j, p)
 	if e == nil {
 		n = NamespaceFromProto(p)
 	}
@@ -371,12 +1400,18 @@ func Marshal(s *ServiceConfig) (io.Reader, error) {
 	return bytes.NewReader(b), nil
 }
 
+// Unmarshal reads a config written by Marshal, migrating it from older schema versions if needed.
 func Unmarshal(r io.Reader) (*ServiceConfig, error) {
 	b, e := ioutil.ReadAll(r)
 	if e != nil {
 		return nil, e
 	}
 
+	b, e = migrateIfNeeded(b)
+	if e != nil {
+		return nil, e
+	}
+
 	p := &pb.ServiceConfig{}
 	e = proto.Unmarshal(b, p)
 	if e != nil {
@@ -4,10 +4,14 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/maniksurtani/quotaservice/test/helpers"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 const cfgYaml = `namespaces:
@@ -112,6 +116,330 @@ func assertBucket(t *testing.T, b *BucketConfig, size, fillRate, waitTimeoutMill
 	}
 }
 
+const cfgToml = `[namespaces.no_default_no_dynamic.buckets.one]
+fill_rate = 321
+wait_timeout_millis = 9999
+max_idle_millis = 20000
+max_debt_millis = 30000
+
+[namespaces.no_default_no_dynamic.buckets.with_defaults]
+size = 100
+
+[namespaces.only_dynamic]
+max_dynamic_buckets = 50
+dynamic_bucket_template = { fill_rate = 999, wait_timeout_millis = 8888, max_idle_millis = 30000, max_tokens_per_request = 5 }
+
+[namespaces.only_default.default_bucket]
+fill_rate = 800
+wait_timeout_millis = 7777
+max_idle_millis = 40000
+`
+
+const cfgJson = `{
+	"namespaces": {
+		"no_default_no_dynamic": {
+			"buckets": {
+				"one": {"fill_rate": 321, "wait_timeout_millis": 9999, "max_idle_millis": 20000, "max_debt_millis": 30000},
+				"with_defaults": {"size": 100}
+			}
+		},
+		"only_dynamic": {
+			"max_dynamic_buckets": 50,
+			"dynamic_bucket_template": {"fill_rate": 999, "wait_timeout_millis": 8888, "max_idle_millis": 30000, "max_tokens_per_request": 5}
+		},
+		"only_default": {
+			"default_bucket": {"fill_rate": 800, "wait_timeout_millis": 7777, "max_idle_millis": 40000}
+		}
+	}
+}`
+
+func TestConfigFileFormats(t *testing.T) {
+	expected := readConfigFromBytes([]byte(cfgYaml))
+
+	for ext, contents := range map[string]string{".toml": cfgToml, ".json": cfgJson, ".yaml": cfgYaml} {
+		f, e := ioutil.TempFile("", "qs_test_formats")
+		checkError(t, e)
+		f.WriteString(contents)
+		f.Close()
+		filename := f.Name() + ext
+		checkError(t, os.Rename(f.Name(), filename))
+		defer os.Remove(filename)
+
+		if cfg := ReadConfigFromFileStrict(filename); !cfg.Equals(expected) {
+			t.Fatalf("Config read from %v file differs. Expected %+v, was %+v", ext, expected, cfg)
+		}
+	}
+}
+
+func TestTOMLConfig(t *testing.T) {
+	contents, e := toYAML("qs.toml", []byte(`[namespaces.ns]
+aliases = [
+  "alias_a",
+  "alias_b",
+]
+
+[namespaces.ns.buckets.batch]
+size = 100
+
+[[namespaces.ns.buckets.batch.schedule]]
+days = ["mon", "tue"]
+start = "09:00"
+end = "17:00"
+size = 200
+
+[[namespaces.ns.buckets.batch.schedule]]
+start = "22:00"
+end = "06:00"
+fill_rate = 10
+`))
+	checkError(t, e)
+
+	cfg := readConfigFromBytes(contents)
+	ns := cfg.Namespaces["ns"]
+	if !reflect.DeepEqual(ns.Aliases, []string{"alias_a", "alias_b"}) {
+		t.Fatalf("Expecting aliases from a multi-line array. Were %v", ns.Aliases)
+	}
+
+	if s := ns.Buckets["batch"].Schedule; len(s) != 2 || s[0].Size != 200 || s[1].FillRate != 10 {
+		t.Fatalf("Expecting a schedule from an array of tables. Was %+v", s)
+	}
+}
+
+func TestInvalidConfigFileFormats(t *testing.T) {
+	for ext, contents := range map[string]string{".toml": "namespaces = [", ".json": "{"} {
+		f, e := ioutil.TempFile("", "qs_test_formats")
+		checkError(t, e)
+		f.WriteString(contents)
+		f.Close()
+		filename := f.Name() + ext
+		checkError(t, os.Rename(f.Name(), filename))
+		defer os.Remove(filename)
+
+		helpers.ExpectingPanic(t, func() {
+			_ = ReadConfigFromFile(filename)
+		})
+	}
+}
+
+func TestNamespaceDefaults(t *testing.T) {
+	cfg := readConfigFromBytes([]byte(`namespaces:
+  ns:
+    defaults:
+      wait_timeout_millis: 5s
+      fill_rate: 20
+    buckets:
+      inherits: {}
+      overrides:
+        fill_rate: 30
+    dynamic_bucket_template:
+      size: 10
+  other:
+    buckets:
+      unaffected: {}
+`))
+
+	ns := cfg.Namespaces["ns"]
+	inherits, overrides := ns.Buckets["inherits"], ns.Buckets["overrides"]
+	if inherits.WaitTimeoutMillis != 5000 || inherits.FillRate != 20 || inherits.MaxTokensPerRequest != 20 {
+		t.Fatalf("Expecting bucket to inherit namespace defaults. Was %+v", inherits)
+	}
+
+	if overrides.WaitTimeoutMillis != 5000 || overrides.FillRate != 30 {
+		t.Fatalf("Expecting bucket to override namespace defaults. Was %+v", overrides)
+	}
+
+	// Global defaults still apply to settings the namespace doesn't specify.
+	if inherits.Size != 100 {
+		t.Fatalf("Expecting global default for size. Was %+v", inherits)
+	}
+
+	if ns.DynamicBucketTemplate.WaitTimeoutMillis != 5000 || ns.DynamicBucketTemplate.Size != 10 {
+		t.Fatalf("Expecting dynamic bucket template to inherit namespace defaults. Was %+v", ns.DynamicBucketTemplate)
+	}
+
+	if b := cfg.Namespaces["other"].Buckets["unaffected"]; b.WaitTimeoutMillis != 1000 || b.FillRate != 50 {
+		t.Fatalf("Expecting global defaults only. Was %+v", b)
+	}
+
+	if !cfg.Equals(FromProto(cfg.ToProto())) {
+		t.Fatal("Expecting namespace defaults to survive conversion to protos.")
+	}
+}
+
+func TestConfigurableDefaults(t *testing.T) {
+	defer SetDefaults(defaults)
+	SetDefaults(BucketDefaults{Size: 1, FillRate: 2, WaitTimeoutMillis: 3, MaxIdleMillis: 4, MaxDebtMillis: 5})
+
+	cfg := readConfigFromBytes([]byte(`defaults:
+  size: 10
+  wait_timeout_millis: 30
+global_default_bucket:
+  max_debt_millis: 50
+namespaces:
+  ns:
+    defaults:
+      size: 100
+    buckets:
+      b: {}
+`))
+
+	g := cfg.GlobalDefaultBucket
+	if g.Size != 10 || g.FillRate != 2 || g.WaitTimeoutMillis != 30 || g.MaxIdleMillis != 4 || g.MaxDebtMillis != 50 {
+		t.Fatalf("Unexpected global default bucket %+v", g)
+	}
+
+	b := cfg.Namespaces["ns"].Buckets["b"]
+	if b.Size != 100 || b.FillRate != 2 || b.WaitTimeoutMillis != 30 || b.MaxIdleMillis != 4 || b.MaxDebtMillis != 5 || b.MaxTokensPerRequest != 2 {
+		t.Fatalf("Unexpected bucket %+v", b)
+	}
+
+	if n := NewDefaultBucketConfig(); n.Size != 1 || n.MaxDebtMillis != 5 {
+		t.Fatalf("Expecting new bucket configs to use the configured defaults. Was %+v", n)
+	}
+
+	if !cfg.Equals(FromProto(cfg.ToProto())) {
+		t.Fatal("Expecting defaults to survive conversion to protos.")
+	}
+}
+
+func TestPartialDefaults(t *testing.T) {
+	defer SetDefaults(defaults)
+	SetDefaults(BucketDefaults{Size: 10})
+
+	b := NewDefaultBucketConfig()
+	if b.Size != 10 || b.FillRate != 50 || b.NanosPerToken(b.FillRate) != 20000000 {
+		t.Fatalf("Expecting the fill rate to keep its built-in default. Was %+v", b)
+	}
+
+	if defaults.WindowMillis != 1000 || defaults.LeaseTTLMillis != 60000 {
+		t.Fatalf("Expecting window and lease TTL to keep their built-in defaults. Were %+v", defaults)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expecting a zero fill rate to be refused.")
+		}
+	}()
+	b.NanosPerToken(0)
+}
+
+func TestFillPeriod(t *testing.T) {
+	cfg := readConfigFromBytes([]byte(`namespaces:
+  ns:
+    defaults:
+      fill_rate: 10
+      fill_period_millis: 1m
+    buckets:
+      a: {}
+      b:
+        fill_rate: 5
+      c:
+        fill_period_millis: 10s
+      d:
+        algorithm: gcra
+`))
+
+	buckets := cfg.Namespaces["ns"].Buckets
+	for name, expected := range map[string]time.Duration{
+		"a": 6 * time.Second,
+		"b": 200 * time.Millisecond,
+		"c": time.Second,
+		"d": 6 * time.Second} {
+		if n := buckets[name].NanosPerToken(buckets[name].FillRate); n != int64(expected) {
+			t.Fatalf("Expecting bucket %v to fill a token every %v. Was %v", name, expected, time.Duration(n))
+		}
+	}
+
+	if buckets["d"].EmissionInterval != int64(6*time.Second) {
+		t.Fatalf("Expecting the emission interval to default to the fill period over the fill rate. Was %v", buckets["d"].EmissionInterval)
+	}
+
+	if NewDefaultBucketConfig().FillPeriod() != time.Second {
+		t.Fatal("Expecting fill rates to be per second by default")
+	}
+
+	if !cfg.Equals(FromProto(cfg.ToProto())) {
+		t.Fatal("Expecting fill periods to survive conversion to protos.")
+	}
+}
+
+func TestLabels(t *testing.T) {
+	cfg := readConfigFromBytes([]byte(`namespaces:
+  ns:
+    labels:
+      team: quota
+      cost_center: "1234"
+    buckets:
+      b:
+        labels:
+          team: storage
+          owner: alice
+      unlabelled: {}
+`))
+
+	ns := cfg.Namespaces["ns"]
+	if all := ns.Buckets["b"].AllLabels(); len(all) != 3 || all["team"] != "storage" || all["owner"] != "alice" || all["cost_center"] != "1234" {
+		t.Fatalf("Expecting bucket labels to take precedence over namespace labels. Was %v", all)
+	}
+
+	if all := ns.Buckets["unlabelled"].AllLabels(); len(all) != 2 || all["team"] != "quota" {
+		t.Fatalf("Expecting namespace labels. Was %v", all)
+	}
+
+	recreated := FromProto(cfg.ToProto())
+	if !cfg.Equals(recreated) || recreated.Namespaces["ns"].Buckets["b"].Labels["owner"] != "alice" {
+		t.Fatal("Expecting labels to survive conversion to protos.")
+	}
+
+	j, e := json.Marshal(ns.ToProto())
+	checkError(t, e)
+	fromJSON, e := NamespaceFromJSON(j)
+	checkError(t, e)
+	if fromJSON.Labels["cost_center"] != "1234" || fromJSON.Buckets["b"].AllLabels()["team"] != "storage" {
+		t.Fatalf("Expecting labels to survive conversion to JSON. Was %s", j)
+	}
+}
+
+func TestClone(t *testing.T) {
+	cfg := readConfigFromBytes([]byte(cfgYaml))
+	cfg.GlobalDefaultBucket = NewDefaultBucketConfig()
+	cfg.Namespaces["only_dynamic"].Labels = map[string]string{"team": "quota"}
+	c := cfg.Clone()
+
+	if !cfg.Equals(c) {
+		t.Fatal("Expecting clone to be equal to the original.")
+	}
+
+	ns := c.Namespaces["no_default_no_dynamic"]
+	b := ns.Buckets["one"]
+	if b.FQN() != "no_default_no_dynamic:one" {
+		t.Fatalf("Unexpected FQN %v", b.FQN())
+	}
+
+	if b.namespace != ns || c.Namespaces["only_dynamic"].DynamicBucketTemplate.namespace != c.Namespaces["only_dynamic"] {
+		t.Fatal("Expecting cloned buckets to belong to the cloned namespace.")
+	}
+
+	b.FillRate++
+	ns.Buckets["new"] = NewDefaultBucketConfig()
+	c.Namespaces["only_dynamic"].Labels["team"] = "other"
+	c.GlobalDefaultBucket.Size++
+	delete(c.Namespaces, "only_default")
+
+	if cfg.Namespaces["no_default_no_dynamic"].Buckets["one"].FillRate != 321 ||
+		cfg.Namespaces["no_default_no_dynamic"].Buckets["new"] != nil ||
+		cfg.Namespaces["only_dynamic"].Labels["team"] != "quota" ||
+		cfg.Namespaces["only_default"] == nil ||
+		cfg.GlobalDefaultBucket.Size == c.GlobalDefaultBucket.Size {
+		t.Fatal("Expecting changes to the clone not to affect the original.")
+	}
+
+	var nilCfg *ServiceConfig
+	if nilCfg.Clone() != nil {
+		t.Fatal("Expecting clone of nil to be nil.")
+	}
+}
+
 func TestNonexistentFile(t *testing.T) {
 	helpers.ExpectingPanic(t, func() {
 		_ = ReadConfigFromFile("/does/not/exist")
@@ -0,0 +1,379 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+const (
+	// How long a single blocking query waits on Consul for changes.
+	consulMaxWait = 5 * time.Minute
+	// How long to wait before retrying a blocking query that failed.
+	consulRetryDelay = 5 * time.Second
+)
+
+// ConsulConfigPersister is a ConfigPersister that stores configs in Consul's KV store. It uses
+// Consul's blocking queries to detect changes made to the key by other nodes. Historical configs
+// are kept under the key's "history/" prefix.
+type ConsulConfigPersister struct {
+	kvURL      string
+	key        string
+	historyKey string
+	client     *http.Client
+	watcher    chan struct{}
+
+	sync.Mutex
+	// ModifyIndex of the config last read or written. Configs are only written over that one, so
+	// that nodes don't overwrite configs they haven't seen; 0, if there was none, only creates the
+	// key.
+	index uint64
+}
+
+// consulKV is a key as Consul's KV API reads it.
+type consulKV struct {
+	ModifyIndex uint64
+	Value       []byte
+}
+
+// NewConsulConfigPersister creates a new ConsulConfigPersister, storing configs under key on the
+// Consul agent at address, in the form "http://host:port".
+func NewConsulConfigPersister(address, key string) (ConfigPersister, error) {
+	u, e := url.Parse(address)
+	if e != nil {
+		return nil, e
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("Consul address should be in the format 'http://host:port', but is %v", address)
+	}
+
+	key = strings.Trim(key, "/")
+	c := &ConsulConfigPersister{
+		kvURL:      strings.TrimSuffix(address, "/") + "/v1/kv/",
+		key:        key,
+		historyKey: key + "/history/",
+		client:     &http.Client{Timeout: consulMaxWait + 30*time.Second},
+		watcher:    make(chan struct{}, 1)}
+
+	go c.watch()
+
+	return c, nil
+}
+
+// PersistAndNotify persists a marshalled configuration passed in, provided the persisted config is
+// still the one last read or written. Otherwise another node has changed it since, and a
+// VersionMismatchError is returned.
+func (c *ConsulConfigPersister) PersistAndNotify(marshalledConfig io.Reader) error {
+	b, e := ioutil.ReadAll(marshalledConfig)
+	if e != nil {
+		return e
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	written, e := c.casPut(c.key, b, c.index)
+	if e != nil {
+		return e
+	}
+
+	if !written {
+		return c.versionMismatch(b)
+	}
+
+	// Consul doesn't say what the key's new ModifyIndex is, so it's read back. Should another node
+	// have written the key since, its config hasn't been seen and the old index is kept.
+	if kv, e := c.getKV(c.key); e == nil && kv != nil && bytes.Equal(kv.Value, b) {
+		c.index = kv.ModifyIndex
+	}
+
+	if e = c.addToHistory(b); e != nil {
+		return e
+	}
+
+	c.notify()
+	return nil
+}
+
+// ReadPersistedConfig provides a reader to a marshalled config previously persisted.
+func (c *ConsulConfigPersister) ReadPersistedConfig() (marshalledConfig io.Reader, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	kv, e := c.getKV(c.key)
+	if e != nil {
+		return nil, e
+	}
+
+	if kv == nil {
+		return nil, fmt.Errorf("No config persisted under %v in Consul", c.key)
+	}
+
+	c.index = kv.ModifyIndex
+	return bytes.NewReader(kv.Value), nil
+}
+
+// versionMismatch returns a VersionMismatchError for a config that couldn't be written over the
+// one persisted since by another node.
+func (c *ConsulConfigPersister) versionMismatch(b []byte) error {
+	e := VersionMismatchError{Current: -1, Requested: -1}
+	if cfg, err := Unmarshal(bytes.NewReader(b)); err == nil {
+		// Written configs have had their version bumped.
+		e.Requested = cfg.Version - 1
+	}
+
+	if kv, err := c.getKV(c.key); err == nil && kv != nil {
+		if cfg, err := Unmarshal(bytes.NewReader(kv.Value)); err == nil {
+			e.Current = cfg.Version
+		}
+	}
+
+	return e
+}
+
+// ReadHistoricalConfigs provides readers to the last MaxConfigHistory marshalled configs
+// persisted, most recent first.
+func (c *ConsulConfigPersister) ReadHistoricalConfigs() (marshalledConfigs []io.Reader, err error) {
+	keys, e := c.historyKeys()
+	if e != nil {
+		return nil, e
+	}
+
+	for _, k := range keys {
+		b, e := c.get(k)
+		if e != nil {
+			return nil, e
+		}
+		marshalledConfigs = append(marshalledConfigs, bytes.NewReader(b))
+	}
+
+	return marshalledConfigs, nil
+}
+
+// addToHistory stores a marshalled config under the history prefix, removing the oldest entries
+// beyond MaxConfigHistory.
+func (c *ConsulConfigPersister) addToHistory(b []byte) error {
+	// Named by timestamp, so lexical order is chronological order.
+	if e := c.put(fmt.Sprintf("%v%020d", c.historyKey, time.Now().UnixNano()), b); e != nil {
+		return e
+	}
+
+	keys, e := c.historyKeys()
+	if e != nil {
+		return e
+	}
+
+	for len(keys) > MaxConfigHistory {
+		if e = c.delete(keys[len(keys)-1]); e != nil {
+			return e
+		}
+		keys = keys[:len(keys)-1]
+	}
+
+	return nil
+}
+
+// historyKeys lists all keys under the history prefix, most recent first.
+func (c *ConsulConfigPersister) historyKeys() ([]string, error) {
+	rsp, e := c.client.Get(c.kvURL + c.historyKey + "?keys")
+	if e != nil {
+		return nil, e
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode == http.StatusNotFound {
+		// No history yet.
+		return nil, nil
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to list config history in Consul. Status %v", rsp.Status)
+	}
+
+	var keys []string
+	if e = json.NewDecoder(rsp.Body).Decode(&keys); e != nil {
+		return nil, e
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	return keys, nil
+}
+
+func (c *ConsulConfigPersister) get(key string) ([]byte, error) {
+	rsp, e := c.client.Get(c.kvURL + key + "?raw")
+	if e != nil {
+		return nil, e
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to read %v from Consul. Status %v", key, rsp.Status)
+	}
+
+	return ioutil.ReadAll(rsp.Body)
+}
+
+// getKV reads a key along with its ModifyIndex, or nil if it doesn't exist.
+func (c *ConsulConfigPersister) getKV(key string) (*consulKV, error) {
+	rsp, e := c.client.Get(c.kvURL + key)
+	if e != nil {
+		return nil, e
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to read %v from Consul. Status %v", key, rsp.Status)
+	}
+
+	var kvs []*consulKV
+	if e = json.NewDecoder(rsp.Body).Decode(&kvs); e != nil {
+		return nil, e
+	}
+
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+
+	return kvs[0], nil
+}
+
+func (c *ConsulConfigPersister) put(key string, b []byte) error {
+	return c.do("PUT", key, bytes.NewReader(b))
+}
+
+// casPut writes the key only if its ModifyIndex is still index, or if it doesn't exist should
+// index be 0, telling whether it was written.
+func (c *ConsulConfigPersister) casPut(key string, b []byte, index uint64) (bool, error) {
+	req, e := http.NewRequest("PUT", fmt.Sprintf("%v%v?cas=%v", c.kvURL, key, index), bytes.NewReader(b))
+	if e != nil {
+		return false, e
+	}
+
+	rsp, e := c.client.Do(req)
+	if e != nil {
+		return false, e
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Unable to PUT %v in Consul. Status %v", key, rsp.Status)
+	}
+
+	var written bool
+	if e = json.NewDecoder(rsp.Body).Decode(&written); e != nil {
+		return false, e
+	}
+
+	return written, nil
+}
+
+func (c *ConsulConfigPersister) delete(key string) error {
+	return c.do("DELETE", key, nil)
+}
+
+func (c *ConsulConfigPersister) do(method, key string, body io.Reader) error {
+	req, e := http.NewRequest(method, c.kvURL+key, body)
+	if e != nil {
+		return e
+	}
+
+	rsp, e := c.client.Do(req)
+	if e != nil {
+		return e
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to %v %v in Consul. Status %v", method, key, rsp.Status)
+	}
+
+	return nil
+}
+
+// ConfigChangedWatcher returns a channel that is notified whenever configuration changes are
+// detected. Changes are coalesced so that a single notification may be emitted for multiple
+// changes.
+func (c *ConsulConfigPersister) ConfigChangedWatcher() chan struct{} {
+	return c.watcher
+}
+
+func (c *ConsulConfigPersister) notify() {
+	select {
+	case c.watcher <- struct{}{}:
+		// Notified
+	default:
+		// Doesn't matter; another notification is pending.
+	}
+}
+
+// watch issues blocking queries against the config key for as long as the process runs,
+// notifying the watcher channel each time Consul reports that the key has been modified.
+func (c *ConsulConfigPersister) watch() {
+	var index uint64
+
+	for {
+		newIndex, exists, e := c.waitForChange(index)
+		if e != nil {
+			logging.Printf("Error watching Consul key %v. Retrying in %v. Error: %v", c.key, consulRetryDelay, e)
+			time.Sleep(consulRetryDelay)
+			continue
+		}
+
+		switch {
+		case newIndex < index:
+			// Consul's index went backwards, e.g., the key was deleted and recreated. Start over.
+			index = 0
+		case index == 0:
+			// First query; establishes the index to block on. Configs persisted before this node
+			// started are read, so that it can write over them.
+			index = newIndex
+			if exists {
+				c.notify()
+			}
+		case newIndex > index:
+			index = newIndex
+			if exists {
+				c.notify()
+			}
+		}
+	}
+}
+
+// waitForChange blocks until the config key's modify index moves past index, or until Consul's
+// wait time elapses, returning the current index and whether the key exists.
+func (c *ConsulConfigPersister) waitForChange(index uint64) (uint64, bool, error) {
+	u := fmt.Sprintf("%v%v?index=%v&wait=%vs", c.kvURL, c.key, index, int(consulMaxWait.Seconds()))
+	rsp, e := c.client.Get(u)
+	if e != nil {
+		return 0, false, e
+	}
+	defer rsp.Body.Close()
+	io.Copy(ioutil.Discard, rsp.Body)
+
+	// Consul responds with a 404 if the key doesn't exist yet, but still supports blocking on it.
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNotFound {
+		return 0, false, fmt.Errorf("Unexpected status %v", rsp.Status)
+	}
+
+	newIndex, e := strconv.ParseUint(rsp.Header.Get("X-Consul-Index"), 10, 64)
+	return newIndex, rsp.StatusCode == http.StatusOK, e
+}
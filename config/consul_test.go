@@ -0,0 +1,249 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul mimics the subset of Consul's KV HTTP API used by ConsulConfigPersister.
+type fakeConsul struct {
+	sync.Mutex
+	values   map[string][]byte
+	modified map[string]uint64
+	index    uint64
+	changed  chan struct{}
+}
+
+func newFakeConsul() *fakeConsul {
+	return &fakeConsul{
+		values:   make(map[string][]byte),
+		modified: make(map[string]uint64),
+		index:    1,
+		changed:  make(chan struct{})}
+}
+
+func (f *fakeConsul) set(key string, v []byte) {
+	f.Lock()
+	defer f.Unlock()
+	f.setLocked(key, v)
+}
+
+func (f *fakeConsul) setLocked(key string, v []byte) {
+	f.index++
+	if v == nil {
+		delete(f.values, key)
+		delete(f.modified, key)
+	} else {
+		f.values[key] = v
+		f.modified[key] = f.index
+	}
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	switch r.Method {
+	case "PUT":
+		b, _ := ioutil.ReadAll(r.Body)
+		f.Lock()
+		defer f.Unlock()
+		if cas := r.URL.Query().Get("cas"); cas != "" {
+			if index, _ := strconv.ParseUint(cas, 10, 64); index != f.modified[key] {
+				w.Write([]byte("false"))
+				return
+			}
+		}
+		f.setLocked(key, b)
+		w.Write([]byte("true"))
+	case "DELETE":
+		f.set(key, nil)
+		w.Write([]byte("true"))
+	case "GET":
+		f.Lock()
+		idx, changed := f.index, f.changed
+		f.Unlock()
+
+		if requested, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); requested > 0 && requested == idx {
+			select {
+			case <-changed:
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+
+		f.Lock()
+		defer f.Unlock()
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+
+		if _, ok := r.URL.Query()["keys"]; ok {
+			var keys []string
+			for k := range f.values {
+				if strings.HasPrefix(k, key) {
+					keys = append(keys, k)
+				}
+			}
+			if keys == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(keys)
+			return
+		}
+
+		v := f.values[key]
+		if v == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, ok := r.URL.Query()["raw"]; ok {
+			w.Write(v)
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"Key": key, "ModifyIndex": f.modified[key], "Value": v}})
+	}
+}
+
+func TestConsulPersistence(t *testing.T) {
+	fake := newFakeConsul()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	persister, e := NewConsulConfigPersister(srv.URL, "quotaservice/config")
+	checkError(t, e)
+
+	_, e = persister.ReadPersistedConfig()
+	if e == nil {
+		t.Fatal("Expecting error reading config that hasn't been persisted.")
+	}
+
+	s := NewDefaultServiceConfig()
+	s.Version = 12
+	s.AddNamespace("xyz", NewDefaultNamespaceConfig())
+
+	r, e := Marshal(s)
+	checkError(t, e)
+	checkError(t, persister.PersistAndNotify(r))
+	waitForNotification(t, persister)
+
+	r, e = persister.ReadPersistedConfig()
+	checkError(t, e)
+	unmarshalled, e := Unmarshal(r)
+	checkError(t, e)
+
+	if !s.Equals(unmarshalled) {
+		t.Fatalf("Configs should be equal! %+v != %+v", s, unmarshalled)
+	}
+}
+
+func TestConsulWatchesForChanges(t *testing.T) {
+	fake := newFakeConsul()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	persister, e := NewConsulConfigPersister(srv.URL, "quotaservice/config")
+	checkError(t, e)
+
+	// Give the watcher a chance to establish its index.
+	time.Sleep(50 * time.Millisecond)
+
+	// Simulate another node updating the config.
+	s := NewDefaultServiceConfig()
+	s.Version = 13
+	b, e := Marshal(s)
+	checkError(t, e)
+	raw, _ := ioutil.ReadAll(b)
+	fake.set("quotaservice/config", raw)
+
+	waitForNotification(t, persister)
+
+	r, e := persister.ReadPersistedConfig()
+	checkError(t, e)
+	persisted, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(persisted, raw) {
+		t.Fatal("Expecting to read the config written by another node.")
+	}
+}
+
+func TestConsulHistory(t *testing.T) {
+	fake := newFakeConsul()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	persister, e := NewConsulConfigPersister(srv.URL, "quotaservice/config")
+	checkError(t, e)
+
+	assertHistory(t, persister, MaxConfigHistory+2)
+}
+
+func TestInvalidConsulAddress(t *testing.T) {
+	_, e := NewConsulConfigPersister("localhost", "quotaservice/config")
+	if e == nil {
+		t.Fatal("Expecting error with an address without a scheme.")
+	}
+}
+
+func waitForNotification(t *testing.T, p ConfigPersister) {
+	select {
+	case <-p.ConfigChangedWatcher():
+	// This is good.
+	case <-time.After(time.Second):
+		t.Fatal("Expecting a config change notification.")
+	}
+}
+
+func TestConsulRefusesStaleWrites(t *testing.T) {
+	fake := newFakeConsul()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	p1, e := NewConsulConfigPersister(srv.URL, "quotaservice/config")
+	checkError(t, e)
+	p2, e := NewConsulConfigPersister(srv.URL, "quotaservice/config")
+	checkError(t, e)
+
+	s := NewDefaultServiceConfig()
+	s.Version = 1
+	r, _ := Marshal(s)
+	checkError(t, p1.PersistAndNotify(r))
+
+	// The second node hasn't read the first's config, so can't write over it.
+	s.Version = 2
+	r, _ = Marshal(s)
+	e = p2.PersistAndNotify(r)
+	if mismatch, ok := e.(VersionMismatchError); !ok || mismatch.Current != 1 || mismatch.Requested != 1 {
+		t.Fatalf("Expecting a version mismatch. Was %v", e)
+	}
+
+	// Once read, it can.
+	_, e = p2.ReadPersistedConfig()
+	checkError(t, e)
+	r, _ = Marshal(s)
+	checkError(t, p2.PersistAndNotify(r))
+
+	// Leaving the first node behind.
+	s.Version = 3
+	r, _ = Marshal(s)
+	if _, ok := p1.PersistAndNotify(r).(VersionMismatchError); !ok {
+		t.Fatal("Expecting a version mismatch writing over a config that hasn't been read.")
+	}
+
+	// Its own writes don't leave it behind.
+	_, e = p1.ReadPersistedConfig()
+	checkError(t, e)
+	r, _ = Marshal(s)
+	checkError(t, p1.PersistAndNotify(r))
+	s.Version = 4
+	r, _ = Marshal(s)
+	checkError(t, p1.PersistAndNotify(r))
+}
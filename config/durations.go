@@ -0,0 +1,114 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"gopkg.in/yaml.v2"
+)
+
+// durationFields are the bucket config fields that may also be set using Go-style duration strings
+// such as "500ms" or "2m", along with the units the fields are in.
+var durationFields = map[string]time.Duration{
+	"wait_timeout_millis": time.Millisecond,
+	"max_idle_millis":     time.Millisecond,
+	"max_debt_millis":     time.Millisecond,
+	"window_millis":       time.Millisecond,
+	"lease_ttl_millis":    time.Millisecond,
+	"max_latency_millis":  time.Millisecond,
+	"fill_period_millis":  time.Millisecond,
+	"emission_interval":   time.Nanosecond}
+
+// ProtoFromJSON unmarshals JSON into a config proto, such as a pb.BucketConfig, accepting duration
+// strings for fields in millis.
+func ProtoFromJSON(j []byte, p proto.Message) error {
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+
+	var doc interface{}
+	if e := d.Decode(&doc); e != nil {
+		return e
+	}
+
+	if e := convertDurations(doc); e != nil {
+		return e
+	}
+
+	converted, e := json.Marshal(doc)
+	if e != nil {
+		return e
+	}
+
+	return json.Unmarshal(converted, p)
+}
+
+// convertDurationsInYAML converts duration strings in a YAML document to millis. Documents that
+// aren't valid YAML are returned as they are, for the caller to report.
+func convertDurationsInYAML(contents []byte) ([]byte, error) {
+	var doc interface{}
+	if yaml.Unmarshal(contents, &doc) != nil {
+		return contents, nil
+	}
+
+	if e := convertDurations(doc); e != nil {
+		return nil, e
+	}
+
+	return yaml.Marshal(doc)
+}
+
+// convertDurations replaces duration strings held under durationFields keys of a generic YAML or
+// JSON document with the equivalent number of each field's units.
+func convertDurations(doc interface{}) (err error) {
+	switch doc := doc.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range doc {
+			if doc[k], err = convertField(fmt.Sprint(k), v); err != nil {
+				return
+			}
+		}
+	case map[string]interface{}:
+		for k, v := range doc {
+			if doc[k], err = convertField(k, v); err != nil {
+				return
+			}
+		}
+	case []interface{}:
+		for _, v := range doc {
+			if err = convertDurations(v); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func convertField(key string, v interface{}) (interface{}, error) {
+	s, isString := v.(string)
+	unit, isDuration := durationFields[key]
+	if !isString || !isDuration {
+		return v, convertDurations(v)
+	}
+
+	d, e := time.ParseDuration(s)
+	if e != nil {
+		return nil, fmt.Errorf("Invalid duration %q for %v. Use a number of %v, or a duration such as \"500ms\" or \"2m\"",
+			s, key, unitName(unit))
+	}
+
+	return int64(d / unit), nil
+}
+
+func unitName(unit time.Duration) string {
+	if unit == time.Nanosecond {
+		return "nanos"
+	}
+	return "millis"
+}
@@ -0,0 +1,57 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/maniksurtani/quotaservice/protos/config"
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestDurationsInYAML(t *testing.T) {
+	cfg := ReadConfigStrict(strings.NewReader(`global_default_bucket:
+  wait_timeout_millis: 500ms
+  max_idle_millis: 2m
+  max_debt_millis: 1500
+namespaces:
+  wait_timeout_millis:
+    default_bucket:
+      max_debt_millis: 1h
+`))
+
+	b := cfg.GlobalDefaultBucket
+	if b.WaitTimeoutMillis != 500 || b.MaxIdleMillis != 120000 || b.MaxDebtMillis != 1500 {
+		t.Fatalf("Durations not converted to millis: %+v", b)
+	}
+
+	// Namespace names aren't confused for fields.
+	if cfg.Namespaces["wait_timeout_millis"].DefaultBucket.MaxDebtMillis != 3600000 {
+		t.Fatalf("Durations not converted to millis: %+v", cfg.Namespaces["wait_timeout_millis"].DefaultBucket)
+	}
+
+	helpers.ExpectingPanic(t, func() {
+		ReadConfig(strings.NewReader("global_default_bucket:\n  wait_timeout_millis: soon\n"))
+	})
+}
+
+func TestDurationsInJSON(t *testing.T) {
+	p := &pb.BucketConfig{}
+	checkError(t, ProtoFromJSON([]byte(`{"name": "b", "size": 10, "wait_timeout_millis": "1.5s", "max_idle_millis": -1}`), p))
+
+	if p.Name != "b" || p.Size != 10 || p.WaitTimeoutMillis != 1500 || p.MaxIdleMillis != -1 {
+		t.Fatalf("Unexpected bucket %+v", p)
+	}
+
+	if ProtoFromJSON([]byte(`{"max_debt_millis": "lots"}`), p) == nil {
+		t.Fatal("Expecting error with an invalid duration.")
+	}
+
+	n, e := NamespaceFromJSON([]byte(`{"name": "ns", "buckets": [{"name": "b", "max_debt_millis": "10s"}]}`))
+	checkError(t, e)
+	if len(n.Buckets) != 1 || n.Buckets["b"].MaxDebtMillis != 10000 {
+		t.Fatalf("Unexpected namespace %+v", n)
+	}
+}
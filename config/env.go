@@ -0,0 +1,235 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// expandEnvInYAML expands environment variables, as expandEnv does, in the keys and scalar values
+// of a YAML document, leaving comments alone and never changing the document's structure, however
+// values are written. Values expanded to numbers or booleans are read as such, so that
+// fill_rate: ${RATE} is a number, and values expanded to nothing are null. Documents that aren't
+// valid YAML are left for the loader to report.
+func expandEnvInYAML(contents []byte, strict bool) ([]byte, error) {
+	if bytes.IndexByte(contents, '$') < 0 {
+		return contents, nil
+	}
+
+	var doc interface{}
+	if yaml.Unmarshal(contents, &doc) != nil {
+		return contents, nil
+	}
+
+	kept, e := keepSecrets(doc, strict)
+	if e != nil {
+		return nil, e
+	}
+
+	doc, e = expandEnvIn(doc, strict)
+	if e != nil {
+		return nil, e
+	}
+
+	for section, secret := range kept {
+		doc.(map[interface{}]interface{})[section].(map[interface{}]interface{})[secrets[section]] = secret
+	}
+
+	return yaml.Marshal(doc)
+}
+
+// secrets are the settings, by the top-level section they're in, holding secrets. Environment
+// variables they reference are only expanded when they're used, with expandSecret, so that configs
+// served by the admin API, exported or persisted hold the reference rather than the secret.
+var secrets = map[string]string{"redis": "password", "cassandra": "password"}
+
+// keepSecrets takes the secrets out of a generic YAML document, returning them by section, so that
+// they're left unexpanded. Secrets referencing unset variables are an error if strict.
+func keepSecrets(doc interface{}, strict bool) (map[string]string, error) {
+	top, ok := doc.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	kept := make(map[string]string)
+	for section, key := range secrets {
+		settings, ok := top[section].(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+
+		secret, ok := settings[key].(string)
+		if !ok {
+			continue
+		}
+
+		if _, e := expandEnv([]byte(secret), strict); e != nil {
+			return nil, e
+		}
+
+		delete(settings, key)
+		kept[section] = secret
+	}
+
+	return kept, nil
+}
+
+// expandSecret expands the environment variables a secret references, as expandEnv does. Secrets
+// that can't be expanded are used as they are.
+func expandSecret(secret string) string {
+	b, e := expandEnv([]byte(secret), false)
+	if e != nil {
+		return secret
+	}
+
+	return string(b)
+}
+
+// expandEnvIn expands environment variables in the keys and scalar values of a generic YAML
+// document.
+func expandEnvIn(doc interface{}, strict bool) (interface{}, error) {
+	switch doc := doc.(type) {
+	case map[interface{}]interface{}:
+		expanded := make(map[interface{}]interface{}, len(doc))
+		for k, v := range doc {
+			if name, ok := k.(string); ok {
+				b, e := expandEnv([]byte(name), strict)
+				if e != nil {
+					return nil, e
+				}
+				k = string(b)
+			}
+
+			v, e := expandEnvIn(v, strict)
+			if e != nil {
+				return nil, e
+			}
+			expanded[k] = v
+		}
+
+		return expanded, nil
+	case []interface{}:
+		for i, v := range doc {
+			v, e := expandEnvIn(v, strict)
+			if e != nil {
+				return nil, e
+			}
+			doc[i] = v
+		}
+	case string:
+		b, e := expandEnv([]byte(doc), strict)
+		if e != nil || string(b) == doc {
+			return doc, e
+		}
+
+		return scalarValue(string(b)), nil
+	}
+
+	return doc, nil
+}
+
+// scalarValue reads an expanded value as a number or boolean if that's what it is, as it would be
+// if written in the document, or as nothing if it's empty. Anything else is a string, however it
+// would read as YAML.
+func scalarValue(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+
+	var v interface{}
+	if yaml.Unmarshal([]byte(s), &v) == nil {
+		switch v.(type) {
+		case int, int64, uint64, float64:
+			return v
+		}
+	}
+
+	return s
+}
+
+// expandEnv replaces ${VAR} in a string with the value of the environment variable VAR, and
+// ${VAR:-default} with default if VAR is unset or empty. $${ is a literal ${, and any other $ is
+// left as is. Unset variables without a default expand to an empty string, or are an error if
+// strict.
+func expandEnv(contents []byte, strict bool) ([]byte, error) {
+	var b bytes.Buffer
+	for i := 0; i < len(contents); i++ {
+		if contents[i] != '$' || i+1 == len(contents) {
+			b.WriteByte(contents[i])
+			continue
+		}
+
+		switch {
+		case bytes.HasPrefix(contents[i+1:], []byte("${")):
+			b.WriteByte('$')
+			i++
+		case contents[i+1] == '{':
+			end := bytes.IndexByte(contents[i+2:], '}')
+			if end < 0 {
+				return nil, errors.New("Unterminated ${ in config")
+			}
+
+			v, e := lookupEnv(string(contents[i+2:i+2+end]), strict)
+			if e != nil {
+				return nil, e
+			}
+			b.WriteString(v)
+			i += end + 2
+		default:
+			b.WriteByte('$')
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// lookupEnv resolves the contents of a ${...} expression.
+func lookupEnv(expr string, strict bool) (string, error) {
+	name, def := expr, ""
+	hasDefault := false
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		name, def, hasDefault = expr[:i], expr[i+2:], true
+	}
+
+	if !isEnvName(name) {
+		return "", fmt.Errorf("Invalid environment variable name %q in config", name)
+	}
+
+	if v := os.Getenv(name); v != "" {
+		return v, nil
+	}
+
+	if !hasDefault && strict {
+		if _, set := os.LookupEnv(name); !set {
+			return "", fmt.Errorf("Environment variable %v referenced in config is not set", name)
+		}
+	}
+
+	return def, nil
+}
+
+func isEnvName(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+
+	return true
+}
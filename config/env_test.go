@@ -0,0 +1,132 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("QS_TEST_RATE", "123")
+	os.Setenv("QS_TEST_EMPTY", "")
+	defer os.Unsetenv("QS_TEST_RATE")
+	defer os.Unsetenv("QS_TEST_EMPTY")
+	os.Unsetenv("QS_TEST_UNSET")
+
+	for in, expected := range map[string]string{
+		"rate: ${QS_TEST_RATE}":            "rate: 123",
+		"rate: ${QS_TEST_RATE:-5}":         "rate: 123",
+		"rate: ${QS_TEST_UNSET:-5}":        "rate: 5",
+		"rate: ${QS_TEST_EMPTY:-5}":        "rate: 5",
+		"rate: ${QS_TEST_UNSET:-}":         "rate: ",
+		"rate: ${QS_TEST_UNSET}":           "rate: ",
+		"price: $$5, $QS_TEST_RATE, cost$": "price: $$5, $QS_TEST_RATE, cost$",
+		"literal: $${QS_TEST_RATE}":        "literal: ${QS_TEST_RATE}",
+	} {
+		out, e := expandEnv([]byte(in), false)
+		checkError(t, e)
+		if string(out) != expected {
+			t.Fatalf("Expected %q to expand to %q. Was %q", in, expected, out)
+		}
+	}
+
+	for _, invalid := range []string{"${QS_TEST_RATE", "${}", "${1X}", "${A B}"} {
+		if _, e := expandEnv([]byte(invalid), false); e == nil {
+			t.Fatalf("Expecting error expanding %q", invalid)
+		}
+	}
+
+	if _, e := expandEnv([]byte("${QS_TEST_UNSET}"), true); e == nil {
+		t.Fatal("Expecting error expanding an unset variable when strict.")
+	}
+
+	if _, e := expandEnv([]byte("${QS_TEST_EMPTY}"), true); e != nil {
+		t.Fatal("Not expecting error expanding a set, empty variable when strict. ", e)
+	}
+}
+
+func TestReadConfigWithEnv(t *testing.T) {
+	os.Setenv("QS_TEST_NAMESPACE", "from_env")
+	defer os.Unsetenv("QS_TEST_NAMESPACE")
+
+	cfg := ReadConfigStrict(strings.NewReader(`namespaces:
+  ${QS_TEST_NAMESPACE}:
+    default_bucket:
+      fill_rate: ${QS_TEST_FILL_RATE:-42}
+`))
+
+	ns := cfg.Namespaces["from_env"]
+	if ns == nil || ns.DefaultBucket.FillRate != 42 {
+		t.Fatalf("Expecting namespace from_env with a fill rate of 42. Config was %+v", cfg)
+	}
+
+	// Comments aren't expanded, and values can't change the document's structure.
+	os.Setenv("QS_TEST_OWNER", `"team": x, size: 5`)
+	defer os.Unsetenv("QS_TEST_OWNER")
+	cfg = ReadConfigStrict(strings.NewReader(`# costs ${ are fun
+namespaces:
+  ns:
+    labels:
+      owner: ${QS_TEST_OWNER}
+      price: $$5
+    default_bucket:
+      size: ${QS_TEST_UNSET_SIZE:-}
+`))
+
+	ns = cfg.Namespaces["ns"]
+	if ns.Labels["owner"] != `"team": x, size: 5` || ns.Labels["price"] != "$$5" || ns.DefaultBucket.Size != 100 {
+		t.Fatalf("Unexpected namespace %+v with default bucket %+v", ns, ns.DefaultBucket)
+	}
+
+	helpers.ExpectingPanic(t, func() {
+		ReadConfigStrict(strings.NewReader("namespaces:\n  ${QS_TEST_UNSET_NAMESPACE}:\n"))
+	})
+}
+
+func TestSecretsExpandedOnUse(t *testing.T) {
+	os.Setenv("QS_TEST_REDIS_PASSWORD", "s3cret")
+	os.Setenv("QS_TEST_CASSANDRA_PASSWORD", "s3cret")
+	defer os.Unsetenv("QS_TEST_REDIS_PASSWORD")
+	defer os.Unsetenv("QS_TEST_CASSANDRA_PASSWORD")
+
+	cfg := ReadConfigStrict(strings.NewReader(`redis:
+  addr: localhost:6379
+  password: ${QS_TEST_REDIS_PASSWORD}
+cassandra:
+  hosts: ["localhost:9042"]
+  keyspace: quotas
+  table: tokens
+  password: ${QS_TEST_CASSANDRA_PASSWORD}
+`))
+
+	if cfg.Redis.Password != "${QS_TEST_REDIS_PASSWORD}" || cfg.Redis.ExpandedPassword() != "s3cret" {
+		t.Fatalf("Expecting the password to be expanded only when used. Config %+v", cfg.Redis)
+	}
+
+	if cfg.Cassandra.Password != "${QS_TEST_CASSANDRA_PASSWORD}" || cfg.Cassandra.ExpandedPassword() != "s3cret" {
+		t.Fatalf("Expecting the password to be expanded only when used. Config %+v", cfg.Cassandra)
+	}
+
+	// Exported and persisted configs never hold the secret.
+	exported, e := cfg.ToYAML()
+	checkError(t, e)
+	r, e := Marshal(cfg)
+	checkError(t, e)
+	persisted, e := ioutil.ReadAll(r)
+	checkError(t, e)
+	for _, b := range [][]byte{exported, persisted} {
+		if strings.Contains(string(b), "s3cret") {
+			t.Fatalf("Expecting exported configs to keep the reference to the password. Exported %s", b)
+		}
+	}
+
+	helpers.ExpectingPanic(t, func() {
+		ReadConfigStrict(strings.NewReader("redis:\n  password: ${QS_TEST_UNSET_PASSWORD}\n"))
+	})
+}
@@ -0,0 +1,267 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ToYAML renders the config as YAML that ReadConfig reads back into an equal config. The output is
+// canonical: keys are always written in the same order, namespaces and buckets sorted by name, so
+// that snapshots of the same config are identical and changes diff cleanly.
+func (s *ServiceConfig) ToYAML() ([]byte, error) {
+	doc := yaml.MapSlice{{Key: "version", Value: s.Version}}
+	if s.Defaults != nil {
+		doc = append(doc, yaml.MapItem{Key: "defaults", Value: bucketToYAML(s.Defaults)})
+	}
+
+	if s.GlobalDefaultBucket != nil {
+		doc = append(doc, yaml.MapItem{Key: "global_default_bucket", Value: bucketToYAML(s.GlobalDefaultBucket)})
+	}
+
+	if s.GlobalRateCap != 0 {
+		doc = append(doc, yaml.MapItem{Key: "global_rate_cap", Value: s.GlobalRateCap})
+	}
+
+	if s.Redis != nil {
+		doc = append(doc, yaml.MapItem{Key: "redis", Value: redisToYAML(s.Redis)})
+	}
+
+	if d := s.Dynamo; d != nil {
+		dynamo := yaml.MapSlice{{Key: "region", Value: d.Region}, {Key: "table", Value: d.Table}}
+		if d.Endpoint != "" {
+			dynamo = append(dynamo, yaml.MapItem{Key: "endpoint", Value: d.Endpoint})
+		}
+
+		if d.MaxAttempts != 0 {
+			dynamo = append(dynamo, yaml.MapItem{Key: "max_attempts", Value: d.MaxAttempts})
+		}
+		doc = append(doc, yaml.MapItem{Key: "dynamo", Value: dynamo})
+	}
+
+	if c := s.Cassandra; c != nil {
+		doc = append(doc, yaml.MapItem{Key: "cassandra", Value: cassandraToYAML(c)})
+	}
+
+	if len(s.Namespaces) > 0 {
+		names := s.NamespaceNames()
+		sort.Strings(names)
+		namespaces := make(yaml.MapSlice, len(names))
+		for i, name := range names {
+			namespaces[i] = yaml.MapItem{Key: name, Value: namespaceToYAML(s.Namespaces[name])}
+		}
+		doc = append(doc, yaml.MapItem{Key: "namespaces", Value: namespaces})
+	}
+
+	return yaml.Marshal(doc)
+}
+
+// ToYAML renders the namespace as YAML that ParseNamespaceConfig reads back, in the same canonical
+// form as ServiceConfig.ToYAML, so that a namespace can be moved between configs on its own.
+func (n *NamespaceConfig) ToYAML() ([]byte, error) {
+	return yaml.Marshal(namespaceToYAML(n))
+}
+
+func namespaceToYAML(n *NamespaceConfig) yaml.MapSlice {
+	doc := yaml.MapSlice{}
+	if len(n.Labels) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "labels", Value: n.Labels})
+	}
+
+	if len(n.Aliases) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "aliases", Value: n.Aliases})
+	}
+
+	if n.Defaults != nil {
+		doc = append(doc, yaml.MapItem{Key: "defaults", Value: bucketToYAML(n.Defaults)})
+	}
+
+	if n.Ceiling != nil {
+		doc = append(doc, yaml.MapItem{Key: "ceiling", Value: bucketToYAML(n.Ceiling)})
+	}
+
+	if n.DefaultBucket != nil {
+		doc = append(doc, yaml.MapItem{Key: "default_bucket", Value: bucketToYAML(n.DefaultBucket)})
+	}
+
+	if n.DynamicBucketTemplate != nil {
+		doc = append(doc,
+			yaml.MapItem{Key: "dynamic_bucket_template", Value: bucketToYAML(n.DynamicBucketTemplate)},
+			yaml.MapItem{Key: "max_dynamic_buckets", Value: n.MaxDynamicBuckets})
+	}
+
+	if n.Eviction != "" {
+		doc = append(doc, yaml.MapItem{Key: "eviction", Value: n.Eviction})
+	}
+
+	if n.Pooled {
+		doc = append(doc, yaml.MapItem{Key: "pooled", Value: true})
+	}
+
+	if n.Shadow {
+		doc = append(doc, yaml.MapItem{Key: "shadow", Value: true})
+	}
+
+	if n.Backend != "" {
+		doc = append(doc, yaml.MapItem{Key: "backend", Value: n.Backend})
+	}
+
+	if n.Tolerance != 0 {
+		doc = append(doc, yaml.MapItem{Key: "tolerance", Value: n.Tolerance})
+	}
+
+	if len(n.Buckets) > 0 {
+		names := make([]string, 0, len(n.Buckets))
+		for name := range n.Buckets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		buckets := make(yaml.MapSlice, len(names))
+		for i, name := range names {
+			buckets[i] = yaml.MapItem{Key: name, Value: bucketToYAML(n.Buckets[name])}
+		}
+		doc = append(doc, yaml.MapItem{Key: "buckets", Value: buckets})
+	}
+
+	return doc
+}
+
+// bucketToYAML omits settings that are zero, as zero means unspecified when configs are read.
+func bucketToYAML(b *BucketConfig) yaml.MapSlice {
+	doc := yaml.MapSlice{}
+	if b.Algorithm != "" {
+		doc = append(doc, yaml.MapItem{Key: "algorithm", Value: b.Algorithm})
+	}
+
+	if b.Parent != "" {
+		doc = append(doc, yaml.MapItem{Key: "parent", Value: b.Parent})
+	}
+
+	if b.Mode != "" {
+		doc = append(doc, yaml.MapItem{Key: "mode", Value: b.Mode})
+	}
+
+	for _, setting := range []yaml.MapItem{
+		{Key: "size", Value: b.Size},
+		{Key: "fill_rate", Value: b.FillRate},
+		{Key: "fill_period_millis", Value: b.FillPeriodMillis},
+		{Key: "wait_timeout_millis", Value: b.WaitTimeoutMillis},
+		{Key: "max_idle_millis", Value: b.MaxIdleMillis},
+		{Key: "max_debt_millis", Value: b.MaxDebtMillis},
+		{Key: "max_tokens_per_request", Value: b.MaxTokensPerRequest},
+		{Key: "window_millis", Value: b.WindowMillis},
+		{Key: "burst", Value: b.Burst},
+		{Key: "emission_interval", Value: b.EmissionInterval},
+		{Key: "lease_ttl_millis", Value: b.LeaseTTLMillis},
+		{Key: "max_waiters", Value: b.MaxWaiters},
+		{Key: "high_priority_reserve", Value: b.HighPriorityReserve}} {
+		if setting.Value.(int64) != 0 {
+			doc = append(doc, setting)
+		}
+	}
+
+	if b.FIFO {
+		doc = append(doc, yaml.MapItem{Key: "fifo", Value: true})
+	}
+
+	if b.InitialFillPercent != nil {
+		doc = append(doc, yaml.MapItem{Key: "initial_fill_percent", Value: *b.InitialFillPercent})
+	}
+
+	if b.EnforcementPercent != nil {
+		doc = append(doc, yaml.MapItem{Key: "enforcement_percent", Value: *b.EnforcementPercent})
+	}
+
+	if a := b.Adaptive; a != nil {
+		adaptive := yaml.MapSlice{}
+		if a.MaxErrorRate != 0 {
+			adaptive = append(adaptive, yaml.MapItem{Key: "max_error_rate", Value: a.MaxErrorRate})
+		}
+
+		for _, setting := range []yaml.MapItem{
+			{Key: "max_latency_millis", Value: a.MaxLatencyMillis},
+			{Key: "backoff_percent", Value: a.BackoffPercent},
+			{Key: "increase", Value: a.Increase},
+			{Key: "min_fill_rate", Value: a.MinFillRate}} {
+			if setting.Value.(int64) != 0 {
+				adaptive = append(adaptive, setting)
+			}
+		}
+
+		doc = append(doc, yaml.MapItem{Key: "adaptive", Value: adaptive})
+	}
+
+	if len(b.Schedule) > 0 {
+		schedule := make([]yaml.MapSlice, len(b.Schedule))
+		for i, s := range b.Schedule {
+			entry := yaml.MapSlice{}
+			if len(s.Days) > 0 {
+				entry = append(entry, yaml.MapItem{Key: "days", Value: s.Days})
+			}
+
+			entry = append(entry, yaml.MapItem{Key: "start", Value: s.Start}, yaml.MapItem{Key: "end", Value: s.End})
+			if s.Size != 0 {
+				entry = append(entry, yaml.MapItem{Key: "size", Value: s.Size})
+			}
+
+			if s.FillRate != 0 {
+				entry = append(entry, yaml.MapItem{Key: "fill_rate", Value: s.FillRate})
+			}
+
+			schedule[i] = entry
+		}
+
+		doc = append(doc, yaml.MapItem{Key: "schedule", Value: schedule})
+	}
+
+	if len(b.Costs) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "costs", Value: b.Costs})
+	}
+
+	if len(b.Labels) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "labels", Value: b.Labels})
+	}
+
+	return doc
+}
+
+func cassandraToYAML(c *CassandraConfig) yaml.MapSlice {
+	doc := yaml.MapSlice{{Key: "hosts", Value: c.Hosts}, {Key: "keyspace", Value: c.Keyspace}, {Key: "table", Value: c.Table}}
+	for _, setting := range []yaml.MapItem{
+		{Key: "username", Value: c.Username},
+		{Key: "password", Value: c.Password},
+		{Key: "consistency", Value: c.Consistency},
+		{Key: "mode", Value: c.Mode}} {
+		if setting.Value.(string) != "" {
+			doc = append(doc, setting)
+		}
+	}
+
+	if c.MaxAttempts != 0 {
+		doc = append(doc, yaml.MapItem{Key: "max_attempts", Value: c.MaxAttempts})
+	}
+
+	return doc
+}
+
+func redisToYAML(r *RedisConfig) yaml.MapSlice {
+	doc := yaml.MapSlice{{Key: "addr", Value: r.Addr}}
+	if r.Password != "" {
+		doc = append(doc, yaml.MapItem{Key: "password", Value: r.Password})
+	}
+
+	for _, setting := range []yaml.MapItem{
+		{Key: "db", Value: r.DB},
+		{Key: "pool_size", Value: int64(r.PoolSize)},
+		{Key: "connection_retries", Value: int64(r.ConnectionRetries)}} {
+		if setting.Value.(int64) != 0 {
+			doc = append(doc, setting)
+		}
+	}
+
+	return doc
+}
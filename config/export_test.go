@@ -0,0 +1,99 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestToYAML(t *testing.T) {
+	cfg := readConfigFromBytes([]byte(cfgYaml))
+	cfg.Version = 3
+	cfg.Namespaces["labelled"] = &NamespaceConfig{
+		Labels:   map[string]string{"team": "quota"},
+		Defaults: &BucketConfig{Size: 7},
+		Buckets:  map[string]*BucketConfig{"b": {Labels: map[string]string{"owner": "alice"}}}}
+	cfg.ApplyDefaults()
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+
+	recreated := readConfigFromBytes(y)
+	if !cfg.Equals(recreated) {
+		t.Fatalf("Expecting exported config to read back the same. Exported:\n%s", y)
+	}
+
+	// Canonical output doesn't depend on how the config was built.
+	again, e := recreated.ToYAML()
+	checkError(t, e)
+	if string(y) != string(again) {
+		t.Fatalf("Expecting the same YAML. Was:\n%s\nand:\n%s", y, again)
+	}
+}
+
+func TestToYAMLOrdering(t *testing.T) {
+	cfg := &ServiceConfig{Version: 1, Namespaces: map[string]*NamespaceConfig{
+		"z": {Buckets: map[string]*BucketConfig{"b": {Size: 1}, "a": {FillRate: 2}}},
+		"a": {DynamicBucketTemplate: &BucketConfig{Size: 3}, MaxDynamicBuckets: 4}}}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+
+	expected := `version: 1
+namespaces:
+  a:
+    dynamic_bucket_template:
+      size: 3
+    max_dynamic_buckets: 4
+  z:
+    buckets:
+      a:
+        fill_rate: 2
+      b:
+        size: 1
+`
+	if string(y) != expected {
+		t.Fatalf("Expecting:\n%s\nWas:\n%s", expected, y)
+	}
+}
+
+func TestNamespaceToYAML(t *testing.T) {
+	ns := &NamespaceConfig{
+		Name:                  "ns",
+		Labels:                map[string]string{"team": "quota"},
+		DynamicBucketTemplate: &BucketConfig{Size: 3},
+		MaxDynamicBuckets:     4,
+		Buckets:               map[string]*BucketConfig{}}
+	ns.AddBucket("b", &BucketConfig{Size: 1, WaitTimeoutMillis: 1000})
+
+	y, e := ns.ToYAML()
+	checkError(t, e)
+
+	recreated, e := ParseNamespaceConfig("ns", y)
+	checkError(t, e)
+	if !proto.Equal(ns.ToProto(), recreated.ToProto()) {
+		t.Fatalf("Expecting exported namespace to read back the same. Exported:\n%s", y)
+	}
+
+	// Durations are read as they are in whole configs.
+	recreated, e = ParseNamespaceConfig("other", []byte("buckets:\n  b:\n    wait_timeout_millis: 1s\n"))
+	checkError(t, e)
+	if recreated.Name != "other" || recreated.Buckets["b"].WaitTimeoutMillis != 1000 || recreated.Buckets["b"].Size != 0 {
+		t.Fatalf("Unexpected namespace %+v", recreated)
+	}
+
+	if _, e = ParseNamespaceConfig("ns", []byte("buckets:\n  b:\n    size: -1\n")); e == nil {
+		t.Fatal("Expecting invalid namespace to be rejected")
+	}
+
+	if _, e = ParseNamespaceConfig("ns", []byte("bukets: {}\n")); e == nil {
+		t.Fatal("Expecting unknown fields to be rejected")
+	}
+
+	if _, e = ParseNamespaceConfig("ns", []byte("- b\n")); e == nil {
+		t.Fatal("Expecting namespace that isn't a map to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// toYAML converts the contents of a config file to YAML, from the format indicated by its
+// extension; .toml and .json files are supported, and anything else is treated as YAML already.
+// This way all formats share the same structure, defaults and strictness.
+func toYAML(filename string, contents []byte) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".toml":
+		return tomlToYAML(contents)
+	case ".json":
+		return jsonToYAML(contents)
+	}
+
+	return contents, nil
+}
+
+func tomlToYAML(contents []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if e := toml.Unmarshal(contents, &doc); e != nil {
+		return nil, e
+	}
+
+	return yaml.Marshal(doc)
+}
+
+func jsonToYAML(contents []byte) ([]byte, error) {
+	// Decode numbers as json.Number, so integers don't lose precision as float64s.
+	d := json.NewDecoder(bytes.NewReader(contents))
+	d.UseNumber()
+
+	var doc interface{}
+	if e := d.Decode(&doc); e != nil {
+		return nil, e
+	}
+
+	return yaml.Marshal(fromJSONNumbers(doc))
+}
+
+// fromJSONNumbers replaces json.Numbers in a decoded JSON document with int64s or float64s, which
+// marshal to YAML as numbers rather than strings.
+func fromJSONNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, e := v.Int64(); e == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, mv := range v {
+			v[k] = fromJSONNumbers(mv)
+		}
+	case []interface{}:
+		for i, sv := range v {
+			v[i] = fromJSONNumbers(sv)
+		}
+	}
+
+	return v
+}
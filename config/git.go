@@ -0,0 +1,201 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/maniksurtani/quotaservice/protos/config"
+)
+
+// GitConfigFile is the file, relative to the root of the repository, that GitConfigPersister
+// stores configs in.
+const GitConfigFile = "quotaservice.json"
+
+// GitConfigPersister is a ConfigPersister that stores each config change as a commit in a git
+// repository. Configs are stored as indented JSON so that changes can be reviewed, blamed and
+// rolled back using standard git tooling.
+type GitConfigPersister struct {
+	repoDir  string
+	onBranch bool
+	watcher  chan struct{}
+}
+
+// NewGitConfigPersister creates a new GitConfigPersister backed by the git repository at repoDir.
+// If ref is not empty, the branch or tag it names is checked out first, so the server boots from
+// the config at that ref. Changes can only be persisted if a branch is checked out; booting from a
+// tag pins the config.
+func NewGitConfigPersister(repoDir, ref string) (ConfigPersister, error) {
+	g := &GitConfigPersister{repoDir: repoDir, watcher: make(chan struct{}, 1)}
+
+	if _, e := g.git("rev-parse", "--git-dir"); e != nil {
+		return nil, e
+	}
+
+	if ref != "" {
+		if _, e := g.git("checkout", "-q", ref); e != nil {
+			return nil, e
+		}
+	}
+
+	// Fails if HEAD is detached, e.g., when a tag is checked out.
+	_, e := g.git("symbolic-ref", "-q", "HEAD")
+	g.onBranch = e == nil
+
+	return g, nil
+}
+
+// PersistAndNotify persists a marshalled configuration passed in, as a new commit.
+func (g *GitConfigPersister) PersistAndNotify(marshalledConfig io.Reader) error {
+	if !g.onBranch {
+		return errors.New("Cannot persist configs; no branch checked out in " + g.repoDir)
+	}
+
+	b, e := ioutil.ReadAll(marshalledConfig)
+	if e != nil {
+		return e
+	}
+
+	p := &pb.ServiceConfig{}
+	if e = proto.Unmarshal(b, p); e != nil {
+		return e
+	}
+
+	j, e := json.MarshalIndent(p, "", "  ")
+	if e != nil {
+		return e
+	}
+
+	if e = ioutil.WriteFile(filepath.Join(g.repoDir, GitConfigFile), append(j, '\n'), 0644); e != nil {
+		return e
+	}
+
+	if _, e = g.git("add", GitConfigFile); e != nil {
+		return e
+	}
+
+	// Only commit if something actually changed.
+	if _, e = g.git("diff", "--cached", "--quiet"); e != nil {
+		username := currentUsername()
+		msg := fmt.Sprintf("Config version %v, updated by %v at %v",
+			p.Version, username, time.Now().Format(time.RFC3339))
+		if _, e = g.gitAs(username, "commit", "-q", "-m", msg); e != nil {
+			return e
+		}
+	}
+
+	// ... and notify
+	select {
+	case g.watcher <- struct{}{}:
+		// Notified
+	default:
+		// Doesn't matter; another notification is pending.
+	}
+	return nil
+}
+
+// ReadPersistedConfig provides a reader to a marshalled config previously persisted, as
+// committed at HEAD.
+func (g *GitConfigPersister) ReadPersistedConfig() (marshalledConfig io.Reader, err error) {
+	return g.readConfigAt("HEAD")
+}
+
+// ReadHistoricalConfigs provides readers to the configs in the last MaxConfigHistory commits that
+// changed GitConfigFile, most recent first.
+func (g *GitConfigPersister) ReadHistoricalConfigs() (marshalledConfigs []io.Reader, err error) {
+	if _, e := g.git("rev-parse", "-q", "--verify", "HEAD"); e != nil {
+		// Nothing committed yet.
+		return nil, nil
+	}
+
+	log, e := g.git("log", "-n", strconv.Itoa(MaxConfigHistory), "--format=%H", "--", GitConfigFile)
+	if e != nil {
+		return nil, e
+	}
+
+	for _, commit := range strings.Fields(string(log)) {
+		r, e := g.readConfigAt(commit)
+		if e != nil {
+			return nil, e
+		}
+		marshalledConfigs = append(marshalledConfigs, r)
+	}
+
+	return marshalledConfigs, nil
+}
+
+// readConfigAt reads the config committed at rev, converting it back into its marshalled form.
+func (g *GitConfigPersister) readConfigAt(rev string) (io.Reader, error) {
+	j, e := g.git("show", rev+":"+GitConfigFile)
+	if e != nil {
+		return nil, e
+	}
+
+	p := &pb.ServiceConfig{}
+	if e = json.Unmarshal(j, p); e != nil {
+		return nil, e
+	}
+
+	b, e := proto.Marshal(p)
+	if e != nil {
+		return nil, e
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// ConfigChangedWatcher returns a channel that is notified whenever configuration changes are
+// detected. Changes are coalesced so that a single notification may be emitted for multiple
+// changes.
+func (g *GitConfigPersister) ConfigChangedWatcher() chan struct{} {
+	return g.watcher
+}
+
+func (g *GitConfigPersister) git(args ...string) ([]byte, error) {
+	return g.gitAs("", args...)
+}
+
+// gitAs runs a git command in the repository, attributing any commits made to username if it
+// isn't empty.
+func (g *GitConfigPersister) gitAs(username string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.repoDir
+	if username != "" {
+		hostname, _ := os.Hostname()
+		email := username + "@" + hostname
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME="+username, "GIT_AUTHOR_EMAIL="+email,
+			"GIT_COMMITTER_NAME="+username, "GIT_COMMITTER_EMAIL="+email)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, e := cmd.Output()
+	if e != nil {
+		return nil, fmt.Errorf("git %v failed: %v %v", strings.Join(args, " "), e, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+func currentUsername() string {
+	if u, e := user.Current(); e == nil && u.Username != "" {
+		return u.Username
+	}
+
+	return "quotaservice"
+}
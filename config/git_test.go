@@ -0,0 +1,135 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGitPersistence(t *testing.T) {
+	dir := newGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	persister, e := NewGitConfigPersister(dir, "")
+	checkError(t, e)
+
+	s := NewDefaultServiceConfig()
+	s.AddNamespace("xyz", NewDefaultNamespaceConfig())
+	s.AddNamespace("abc", NewDefaultNamespaceConfig().AddBucket("b", NewDefaultBucketConfig()))
+
+	for v := 1; v <= 2; v++ {
+		s.Version = v
+		r, e := Marshal(s)
+		checkError(t, e)
+		checkError(t, persister.PersistAndNotify(r))
+
+		select {
+		case <-persister.ConfigChangedWatcher():
+		// This is good.
+		default:
+			t.Fatal("Config channel should not be empty!")
+		}
+	}
+
+	r, e := persister.ReadPersistedConfig()
+	checkError(t, e)
+	unmarshalled, e := Unmarshal(r)
+	checkError(t, e)
+
+	if !s.Equals(unmarshalled) {
+		t.Fatalf("Configs should be equal! %+v != %+v", s, unmarshalled)
+	}
+
+	log := runGit(t, dir, "log", "--format=%s")
+	commits := strings.Split(strings.TrimSpace(log), "\n")
+	if len(commits) != 2 {
+		t.Fatalf("Expecting 2 commits. Log was %v", log)
+	}
+
+	if !strings.HasPrefix(commits[0], "Config version 2, updated by ") {
+		t.Fatalf("Unexpected commit message %v", commits[0])
+	}
+}
+
+func TestGitHistory(t *testing.T) {
+	dir := newGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	persister, e := NewGitConfigPersister(dir, "")
+	checkError(t, e)
+
+	assertHistory(t, persister, MaxConfigHistory+2)
+}
+
+func TestGitBootFromTag(t *testing.T) {
+	dir := newGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	persister, e := NewGitConfigPersister(dir, "")
+	checkError(t, e)
+
+	s := NewDefaultServiceConfig()
+	s.Version = 1
+	r, _ := Marshal(s)
+	checkError(t, persister.PersistAndNotify(r))
+	runGit(t, dir, "tag", "v1")
+
+	s.Version = 2
+	r, _ = Marshal(s)
+	checkError(t, persister.PersistAndNotify(r))
+
+	persister, e = NewGitConfigPersister(dir, "v1")
+	checkError(t, e)
+
+	r, e = persister.ReadPersistedConfig()
+	checkError(t, e)
+	unmarshalled, e := Unmarshal(r)
+	checkError(t, e)
+
+	if unmarshalled.Version != 1 {
+		t.Fatalf("Expecting version 1 from tag v1. Was %v", unmarshalled.Version)
+	}
+
+	// Tags are read-only.
+	r, _ = Marshal(s)
+	if persister.PersistAndNotify(r) == nil {
+		t.Fatal("Should not be able to persist configs on a tag.")
+	}
+}
+
+func TestNotAGitRepo(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_not_git")
+	checkError(t, e)
+	defer os.RemoveAll(dir)
+
+	if _, e = NewGitConfigPersister(dir, ""); e == nil {
+		t.Fatal("Expecting error creating a persister outside a git repository.")
+	}
+}
+
+func newGitRepo(t *testing.T) string {
+	if _, e := exec.LookPath("git"); e != nil {
+		t.Skip("git not available")
+	}
+
+	dir, e := ioutil.TempDir("", "qs_git_persistence")
+	checkError(t, e)
+	runGit(t, dir, "init", "-q")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, e := cmd.CombinedOutput()
+	if e != nil {
+		t.Fatalf("git %v failed: %v %s", args, e, out)
+	}
+
+	return string(out)
+}
@@ -0,0 +1,202 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// configFile is the structure of a config file: a ServiceConfig, plus other files to include and
+// bucket presets, which are expanded as files are loaded.
+type configFile struct {
+	ServiceConfig `yaml:",inline"`
+	Includes      []string
+	Presets       map[string]*BucketConfig
+}
+
+// parseConfigFile parses the contents of a config file, merging in any overlay files and the
+// namespaces of any files it includes before applying defaults. Files that can't be read or
+// converted, or that conflict with each other, panic regardless of strictness.
+func parseConfigFile(filename string, contents []byte, strict bool, overlays ...string) *ServiceConfig {
+	cfg := NewDefaultServiceConfig()
+	cfg.GlobalDefaultBucket = nil
+
+	l := &configLoader{strict: strict, loaded: make(map[string]bool)}
+	if filename != "" {
+		l.markLoaded(filename)
+	}
+
+	contents, e := l.preprocess(filename, contents)
+	for _, overlay := range overlays {
+		if e != nil {
+			break
+		}
+		contents, e = l.applyOverlay(contents, overlay)
+	}
+
+	if e == nil {
+		e = l.loadYAML(cfg, filename, contents, false)
+	}
+
+	if e != nil {
+		panic(fmt.Sprintf("Unable to parse config. Error: %v", e))
+	}
+
+	return cfg.ApplyDefaults()
+}
+
+// configLoader loads config files into a ServiceConfig, following includes.
+type configLoader struct {
+	strict bool
+	// Absolute paths of files loaded so far, to detect cycles.
+	loaded map[string]bool
+	// Bucket presets defined by the main file.
+	presets map[interface{}]interface{}
+}
+
+// load parses a config file into cfg. Included files, or fragments, may only define namespaces.
+func (l *configLoader) load(cfg *ServiceConfig, filename string, contents []byte, fragment bool) error {
+	contents, e := l.preprocess(filename, contents)
+	if e != nil {
+		return e
+	}
+
+	return l.loadYAML(cfg, filename, contents, fragment)
+}
+
+// preprocess converts a config file to YAML and expands environment variables in it.
+func (l *configLoader) preprocess(filename string, contents []byte) ([]byte, error) {
+	contents, e := toYAML(filename, contents)
+	if e != nil {
+		return nil, e
+	}
+
+	return expandEnvInYAML(contents, l.strict)
+}
+
+// applyOverlay merges the overlay file into the YAML contents of the file it overlays.
+func (l *configLoader) applyOverlay(contents []byte, overlay string) ([]byte, error) {
+	o, e := ioutil.ReadFile(overlay)
+	if e == nil {
+		o, e = l.preprocess(overlay, o)
+	}
+
+	if e == nil {
+		contents, e = mergeOverlayInYAML(contents, o)
+	}
+
+	if e != nil {
+		return nil, fmt.Errorf("%v (in %v)", e, overlay)
+	}
+
+	return contents, nil
+}
+
+// loadYAML is load, for contents already converted to YAML.
+func (l *configLoader) loadYAML(cfg *ServiceConfig, filename string, contents []byte, fragment bool) error {
+	contents, e := convertDurationsInYAML(contents)
+	if e != nil {
+		return e
+	}
+
+	contents, presets, e := expandPresetsInYAML(contents, l.presets)
+	if e != nil {
+		return e
+	}
+
+	logging.Debug(string(contents))
+	f := &configFile{ServiceConfig: ServiceConfig{Namespaces: make(map[string]*NamespaceConfig)}}
+	e = yaml.Unmarshal(contents, f)
+	if e == nil && l.strict {
+		e = checkForUnknownFields(contents)
+	}
+
+	if e != nil {
+		if l.strict {
+			return e
+		}
+		logging.Warnf("Ignoring errors parsing config. Error: %v", e)
+	}
+
+	if fragment {
+		if f.GlobalDefaultBucket != nil || f.Defaults != nil || f.Version != 0 || f.GlobalRateCap != 0 || f.Redis != nil || f.Dynamo != nil || f.Cassandra != nil || len(f.Presets) > 0 {
+			return fmt.Errorf("Included file %v may only define namespaces and includes", filename)
+		}
+	} else {
+		cfg.GlobalDefaultBucket = f.GlobalDefaultBucket
+		cfg.Defaults = f.Defaults
+		cfg.Version = f.Version
+		cfg.GlobalRateCap = f.GlobalRateCap
+		cfg.Redis = f.Redis
+		cfg.Dynamo = f.Dynamo
+		cfg.Cassandra = f.Cassandra
+		l.presets = presets
+	}
+
+	for name, ns := range f.Namespaces {
+		if _, exists := cfg.Namespaces[name]; exists {
+			return fmt.Errorf("Namespace %v defined more than once", name)
+		}
+		cfg.Namespaces[name] = ns
+	}
+
+	return l.loadIncludes(cfg, filepath.Dir(filename), f.Includes)
+}
+
+func (l *configLoader) loadIncludes(cfg *ServiceConfig, dir string, includes []string) error {
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		matches, e := filepath.Glob(pattern)
+		if e != nil {
+			return e
+		}
+
+		// Globs may legitimately match nothing, but plain paths must exist.
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return fmt.Errorf("Included file %v does not exist", pattern)
+		}
+
+		for _, m := range matches {
+			if !l.markLoaded(m) {
+				return fmt.Errorf("File %v included more than once", m)
+			}
+
+			contents, e := ioutil.ReadFile(m)
+			if e != nil {
+				return e
+			}
+
+			if e = l.load(cfg, m, contents, true); e != nil {
+				return fmt.Errorf("%v (in %v)", e, m)
+			}
+		}
+	}
+
+	return nil
+}
+
+// markLoaded records that a file has been loaded, returning false if it already had been.
+func (l *configLoader) markLoaded(filename string) bool {
+	abs, e := filepath.Abs(filename)
+	if e != nil {
+		abs = filename
+	}
+
+	if l.loaded[abs] {
+		return false
+	}
+
+	l.loaded[abs] = true
+	return true
+}
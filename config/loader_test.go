@@ -0,0 +1,107 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.yaml": `global_default_bucket:
+  size: 10
+includes:
+  - namespaces/*.yaml
+  - other.toml
+  - empty/*.yaml
+namespaces:
+  main:
+    max_dynamic_buckets: 1
+`,
+		"namespaces/a.yaml": `namespaces:
+  a:
+    default_bucket:
+      fill_rate: 11
+includes:
+  - ../nested.json
+`,
+		"namespaces/b.yaml": `namespaces:
+  b:
+    max_dynamic_buckets: 12
+`,
+		"other.toml": `[namespaces.other.buckets.x]
+size = 13
+`,
+		"nested.json": `{"namespaces": {"nested": {"max_dynamic_buckets": 14}}}`,
+	})
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "empty"), 0755)
+
+	cfg := ReadConfigFromFileStrict(filepath.Join(dir, "main.yaml"))
+
+	if cfg.GlobalDefaultBucket.Size != 10 {
+		t.Fatalf("Expecting global default from the including file. Was %+v", cfg.GlobalDefaultBucket)
+	}
+
+	if len(cfg.Namespaces) != 5 {
+		t.Fatalf("Expecting 5 namespaces. Was %v", cfg.NamespaceNames())
+	}
+
+	if cfg.Namespaces["a"].DefaultBucket.FillRate != 11 ||
+		cfg.Namespaces["b"].MaxDynamicBuckets != 12 ||
+		cfg.Namespaces["other"].Buckets["x"].Size != 13 ||
+		cfg.Namespaces["nested"].MaxDynamicBuckets != 14 {
+		t.Fatalf("Included namespaces not read correctly: %+v", cfg)
+	}
+
+	// Defaults are applied to included namespaces.
+	if cfg.Namespaces["other"].Buckets["x"].FillRate != 50 {
+		t.Fatalf("Expecting defaults to be applied to included buckets. Was %+v", cfg.Namespaces["other"].Buckets["x"])
+	}
+}
+
+func TestInvalidIncludes(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"duplicate namespace": {
+			"main.yaml": "includes: [a.yaml]\nnamespaces:\n  a:\n",
+			"a.yaml":    "namespaces:\n  a:\n"},
+		"cycle": {
+			"main.yaml": "includes: [a.yaml]",
+			"a.yaml":    "includes: [main.yaml]"},
+		"included twice": {
+			"main.yaml": "includes: [a.yaml, '*.yaml']",
+			"a.yaml":    "namespaces:\n  a:\n"},
+		"missing file": {
+			"main.yaml": "includes: [missing.yaml]"},
+		"fragment with global default": {
+			"main.yaml": "includes: [a.yaml]",
+			"a.yaml":    "global_default_bucket:\n  size: 10\n"},
+	} {
+		dir := writeConfigFiles(t, files)
+		defer os.RemoveAll(dir)
+
+		t.Log("Testing ", name)
+		helpers.ExpectingPanic(t, func() {
+			ReadConfigFromFile(filepath.Join(dir, "main.yaml"))
+		})
+	}
+}
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, e := ioutil.TempDir("", "qs_test_includes")
+	checkError(t, e)
+
+	for name, contents := range files {
+		filename := filepath.Join(dir, name)
+		checkError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		checkError(t, ioutil.WriteFile(filename, []byte(contents), 0644))
+	}
+
+	return dir
+}
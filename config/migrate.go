@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// CurrentSchemaVersion is the schema version of configs marshalled by this version of the
+// quotaservice. It is bumped whenever stored configs need migrating, such as when bucket fields
+// are renamed or split.
+const CurrentSchemaVersion = 1
+
+// Migration upgrades a marshalled config from one schema version to the next. Migrations needn't
+// update the schema version stored in the config; Migrate does so.
+type Migration func(raw []byte) ([]byte, error)
+
+var (
+	migrationsLock sync.RWMutex
+	migrations     = map[int]Migration{
+		// Configs stored before schema versions were introduced need no changes.
+		0: func(raw []byte) ([]byte, error) { return raw, nil }}
+)
+
+// RegisterMigration registers the Migration upgrading configs from schema version from to the
+// next. Panics if one is already registered.
+func RegisterMigration(from int, m Migration) {
+	migrationsLock.Lock()
+	defer migrationsLock.Unlock()
+
+	if _, exists := migrations[from]; exists {
+		panic(fmt.Sprintf("Migration from schema version %v already registered", from))
+	}
+	migrations[from] = m
+}
+
+// Migrate upgrades a marshalled config from oldVersion to CurrentSchemaVersion, applying registered
+// migrations in order.
+func Migrate(oldVersion int, raw []byte) ([]byte, error) {
+	return migrate(oldVersion, CurrentSchemaVersion, raw)
+}
+
+func migrate(from, to int, raw []byte) ([]byte, error) {
+	if from > to {
+		return nil, fmt.Errorf("Config schema version %v is newer than supported version %v", from, to)
+	}
+
+	migrationsLock.RLock()
+	defer migrationsLock.RUnlock()
+
+	for v := from; v < to; v++ {
+		m := migrations[v]
+		if m == nil {
+			return nil, fmt.Errorf("No migration registered from config schema version %v", v)
+		}
+
+		migrated, e := m(raw)
+		if e != nil {
+			return nil, fmt.Errorf("Unable to migrate config from schema version %v. Error: %v", v, e)
+		}
+
+		// Fields appearing later take precedence, so this overrides any existing schema version.
+		b := proto.NewBuffer(append([]byte(nil), migrated...))
+		if e = b.EncodeVarint(schemaVersionTag); e == nil {
+			e = b.EncodeVarint(uint64(v + 1))
+		}
+		if e != nil {
+			return nil, e
+		}
+		raw = b.Bytes()
+	}
+
+	return raw, nil
+}
+
+// schemaVersionTag is the wire format key of ServiceConfig's schema_version field.
+const schemaVersionTag = 7<<3 | proto.WireVarint
+
+// schemaVersioned reads just the schema version of a marshalled config, whatever its schema.
+type schemaVersioned struct {
+	SchemaVersion int32 `protobuf:"varint,7,opt,name=schema_version"`
+}
+
+func (m *schemaVersioned) Reset()         { *m = schemaVersioned{} }
+func (m *schemaVersioned) String() string { return proto.CompactTextString(m) }
+func (*schemaVersioned) ProtoMessage()    {}
+
+// migrateIfNeeded migrates a marshalled config to CurrentSchemaVersion, if it isn't already.
+func migrateIfNeeded(raw []byte) ([]byte, error) {
+	v := &schemaVersioned{}
+	if e := proto.Unmarshal(raw, v); e != nil {
+		return nil, e
+	}
+
+	if v.SchemaVersion == CurrentSchemaVersion {
+		return raw, nil
+	}
+
+	return Migrate(int(v.SchemaVersion), raw)
+}
@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/maniksurtani/quotaservice/protos/config"
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestUnmarshalUnversionedConfig(t *testing.T) {
+	cfg := readConfigFromBytes([]byte(cfgYaml))
+	p := cfg.ToProto()
+	p.SchemaVersion = 0
+	b, e := proto.Marshal(p)
+	checkError(t, e)
+
+	unmarshalled, e := Unmarshal(bytes.NewReader(b))
+	checkError(t, e)
+	if !cfg.Equals(unmarshalled) {
+		t.Fatalf("Configs should be equal! %+v != %+v", cfg, unmarshalled)
+	}
+}
+
+func TestUnmarshalNewerConfig(t *testing.T) {
+	b, e := proto.Marshal(&pb.ServiceConfig{SchemaVersion: CurrentSchemaVersion + 1})
+	checkError(t, e)
+
+	if _, e = Unmarshal(bytes.NewReader(b)); e == nil {
+		t.Fatal("Expecting configs with a newer schema version to be rejected.")
+	}
+}
+
+func TestMigrationPipeline(t *testing.T) {
+	// Schema version 100 stored fill rates in size; 101 split them out.
+	RegisterMigration(100, func(raw []byte) ([]byte, error) {
+		p := &pb.ServiceConfig{}
+		if e := proto.Unmarshal(raw, p); e != nil {
+			return nil, e
+		}
+		p.GlobalDefaultBucket.FillRate = p.GlobalDefaultBucket.Size
+		return proto.Marshal(p)
+	})
+	RegisterMigration(101, func(raw []byte) ([]byte, error) {
+		p := &pb.ServiceConfig{}
+		if e := proto.Unmarshal(raw, p); e != nil {
+			return nil, e
+		}
+		p.GlobalDefaultBucket.Size *= 2
+		return proto.Marshal(p)
+	})
+	defer func() {
+		delete(migrations, 100)
+		delete(migrations, 101)
+	}()
+
+	b, e := proto.Marshal(&pb.ServiceConfig{SchemaVersion: 100, GlobalDefaultBucket: &pb.BucketConfig{Size: 10}})
+	checkError(t, e)
+
+	migrated, e := migrate(100, 102, b)
+	checkError(t, e)
+
+	p := &pb.ServiceConfig{}
+	checkError(t, proto.Unmarshal(migrated, p))
+	if p.SchemaVersion != 102 || p.GlobalDefaultBucket.Size != 20 || p.GlobalDefaultBucket.FillRate != 10 {
+		t.Fatalf("Unexpected migrated config %+v", p)
+	}
+
+	if _, e = migrate(100, 103, b); e == nil {
+		t.Fatal("Expecting migration without a registered step to fail.")
+	}
+
+	helpers.ExpectingPanic(t, func() {
+		RegisterMigration(100, func(raw []byte) ([]byte, error) { return raw, nil })
+	})
+}
@@ -0,0 +1,58 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"errors"
+
+	"gopkg.in/yaml.v2"
+)
+
+// mergeOverlayInYAML merges an overlay YAML document into a base one. Mappings are merged key by
+// key, recursively; any other value in the overlay, including lists, replaces the base's value.
+// Null values in the overlay leave the base's value alone, so an overlay can name a namespace or
+// bucket without changing it.
+func mergeOverlayInYAML(base, overlay []byte) ([]byte, error) {
+	var baseDoc, overlayDoc map[interface{}]interface{}
+	if e := yaml.Unmarshal(base, &baseDoc); e != nil {
+		return nil, e
+	}
+
+	if e := yaml.Unmarshal(overlay, &overlayDoc); e != nil {
+		return nil, e
+	}
+
+	if _, exists := overlayDoc["includes"]; exists {
+		return nil, errors.New("Overlays may not include other files")
+	}
+
+	return yaml.Marshal(mergeOverlay(baseDoc, overlayDoc))
+}
+
+func mergeOverlay(base, overlay interface{}) interface{} {
+	if overlay == nil {
+		return base
+	}
+
+	baseMap, ok := base.(map[interface{}]interface{})
+	if !ok {
+		return overlay
+	}
+
+	overlayMap, ok := overlay.(map[interface{}]interface{})
+	if !ok {
+		return overlay
+	}
+
+	merged := make(map[interface{}]interface{}, len(baseMap)+len(overlayMap))
+	for k, v := range baseMap {
+		merged[k] = v
+	}
+
+	for k, v := range overlayMap {
+		merged[k] = mergeOverlay(baseMap[k], v)
+	}
+
+	return merged
+}
@@ -0,0 +1,77 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestOverlays(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"base.yaml": `presets:
+  small:
+    size: 10
+    fill_rate: 5
+defaults:
+  wait_timeout_millis: 1s
+namespaces:
+  a:
+    aliases: [old, older]
+    buckets:
+      x:
+        preset: small
+      z:
+        size: 100
+        fill_rate: 100
+`,
+		"prod.json": `{"namespaces": {"a": {"aliases": ["old"], "buckets": {"x": {"size": 20}}}, "b": {}}}`,
+		"big.yaml": `presets:
+  small:
+    fill_rate: 50
+namespaces:
+  a:
+    buckets:
+      z:
+      x:
+        wait_timeout_millis: 2s
+`})
+	defer os.RemoveAll(dir)
+
+	cfg := ReadConfigFromFileWithOverlays(filepath.Join(dir, "base.yaml"),
+		filepath.Join(dir, "prod.json"), filepath.Join(dir, "big.yaml"))
+
+	a := cfg.Namespaces["a"]
+	if a == nil || cfg.Namespaces["b"] == nil {
+		t.Fatalf("Namespaces not merged correctly: %+v", cfg)
+	}
+
+	// Lists are replaced rather than merged.
+	if !reflect.DeepEqual(a.Aliases, []string{"old"}) {
+		t.Fatalf("Expecting overlay to replace aliases, but was %v", a.Aliases)
+	}
+
+	assertBucket(t, a.Buckets["x"], 20, 50, 2000, -1, 10000, 50)
+	assertBucket(t, a.Buckets["z"], 100, 100, 1000, -1, 10000, 100)
+}
+
+func TestInvalidOverlays(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"base.yaml":    "namespaces:\n  a:\n",
+		"include.yaml": "includes: [other.yaml]",
+		"invalid.yaml": "namespaces: [",
+		"other.yaml":   "namespaces:\n  b:\n"})
+	defer os.RemoveAll(dir)
+
+	for _, overlay := range []string{"include.yaml", "invalid.yaml", "missing.yaml"} {
+		t.Log("Testing ", overlay)
+		helpers.ExpectingPanic(t, func() {
+			ReadConfigFromFileWithOverlays(filepath.Join(dir, "base.yaml"), filepath.Join(dir, overlay))
+		})
+	}
+}
@@ -5,11 +5,18 @@ package config
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 )
 
+// MaxConfigHistory is the number of most recently persisted configs a ConfigPersister retains.
+const MaxConfigHistory = 10
+
 // ConfigPersister is an interface that persists configs and notifies a channel of changes.
 type ConfigPersister interface {
 	// PersistAndNotify persists a marshalled configuration passed in.
@@ -20,12 +27,17 @@ type ConfigPersister interface {
 	ConfigChangedWatcher() chan struct{}
 	// ReadPersistedConfig provides a reader to a marshalled config previously persisted.
 	ReadPersistedConfig() (marshalledConfig io.Reader, err error)
+	// ReadHistoricalConfigs provides readers to the last MaxConfigHistory marshalled configs
+	// persisted, most recent first.
+	ReadHistoricalConfigs() (marshalledConfigs []io.Reader, err error)
 }
 
-// DiskConfigPersister is a ConfigPersister that saves configs to the local filesystem.
+// DiskConfigPersister is a ConfigPersister that saves configs to the local filesystem. Historical
+// configs are kept in a directory alongside, named after the config file with a .history suffix.
 type DiskConfigPersister struct {
-	location string
-	watcher  chan struct{}
+	location   string
+	historyDir string
+	watcher    chan struct{}
 }
 
 // NewDiskConfigPersister creates a new DiskConfigPersister
@@ -37,7 +49,7 @@ func NewDiskConfigPersister(location string) (ConfigPersister, error) {
 		return nil, e
 	}
 
-	return &DiskConfigPersister{location, make(chan struct{}, 1)}, nil
+	return &DiskConfigPersister{location, location + ".history", make(chan struct{}, 1)}, nil
 }
 
 // PersistAndNotify persists a marshalled configuration passed in.
@@ -57,6 +69,10 @@ func (d *DiskConfigPersister) PersistAndNotify(marshalledConfig io.Reader) error
 		return e
 	}
 
+	if e = d.addToHistory(b); e != nil {
+		return e
+	}
+
 	// ... and notify
 	select {
 	case d.watcher <- struct{}{}:
@@ -77,6 +93,74 @@ func (d *DiskConfigPersister) ReadPersistedConfig() (marshalledConfig io.Reader,
 	return bytes.NewReader(b), nil
 }
 
+// ReadHistoricalConfigs provides readers to the last MaxConfigHistory marshalled configs
+// persisted, most recent first.
+func (d *DiskConfigPersister) ReadHistoricalConfigs() (marshalledConfigs []io.Reader, err error) {
+	files, e := d.historyFiles()
+	if e != nil {
+		return nil, e
+	}
+
+	for _, f := range files {
+		b, e := ioutil.ReadFile(f)
+		if e != nil {
+			return nil, e
+		}
+		marshalledConfigs = append(marshalledConfigs, bytes.NewReader(b))
+	}
+
+	return marshalledConfigs, nil
+}
+
+// addToHistory copies a marshalled config into the history directory, removing the oldest
+// entries beyond MaxConfigHistory.
+func (d *DiskConfigPersister) addToHistory(b []byte) error {
+	if e := os.MkdirAll(d.historyDir, 0755); e != nil {
+		return e
+	}
+
+	// Named by timestamp, so lexical order is chronological order.
+	f := filepath.Join(d.historyDir, fmt.Sprintf("%020d", time.Now().UnixNano()))
+	if e := ioutil.WriteFile(f, b, 0644); e != nil {
+		return e
+	}
+
+	files, e := d.historyFiles()
+	if e != nil {
+		return e
+	}
+
+	for len(files) > MaxConfigHistory {
+		if e = os.Remove(files[len(files)-1]); e != nil {
+			return e
+		}
+		files = files[:len(files)-1]
+	}
+
+	return nil
+}
+
+// historyFiles lists all files in the history directory, most recent first.
+func (d *DiskConfigPersister) historyFiles() ([]string, error) {
+	infos, e := ioutil.ReadDir(d.historyDir)
+	if e != nil {
+		if os.IsNotExist(e) {
+			return nil, nil
+		}
+		return nil, e
+	}
+
+	files := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		if !fi.IsDir() {
+			files = append(files, filepath.Join(d.historyDir, fi.Name()))
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
 // ConfigChangedWatcher returns a channel that is notified whenever configuration changes are
 // detected. Changes are coalesced so that a single notification may be emitted for multiple
 // changes.
@@ -4,6 +4,9 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -53,6 +56,49 @@ func TestPersistence(t *testing.T) {
 	}
 }
 
+func TestDiskHistory(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_disk_history")
+	checkError(t, e)
+	defer os.RemoveAll(dir)
+
+	persister, e := NewDiskConfigPersister(filepath.Join(dir, "configs.dat"))
+	checkError(t, e)
+
+	assertHistory(t, persister, MaxConfigHistory+2)
+}
+
+// assertHistory persists versions 1 to n of a config, and checks that the persister retains the
+// last MaxConfigHistory of them, most recent first.
+func assertHistory(t *testing.T, p ConfigPersister, n int) {
+	history, e := p.ReadHistoricalConfigs()
+	checkError(t, e)
+	if len(history) != 0 {
+		t.Fatalf("Expecting no history. Was %v", len(history))
+	}
+
+	s := NewDefaultServiceConfig()
+	for v := 1; v <= n; v++ {
+		s.Version = v
+		r, e := Marshal(s)
+		checkError(t, e)
+		checkError(t, p.PersistAndNotify(r))
+	}
+
+	history, e = p.ReadHistoricalConfigs()
+	checkError(t, e)
+	if len(history) != MaxConfigHistory {
+		t.Fatalf("Expecting %v configs in history. Was %v", MaxConfigHistory, len(history))
+	}
+
+	for i, r := range history {
+		cfg, e := Unmarshal(r)
+		checkError(t, e)
+		if cfg.Version != n-i {
+			t.Fatalf("Expecting version %v at position %v in history. Was %v", n-i, i, cfg.Version)
+		}
+	}
+}
+
 func checkError(t *testing.T, e error) {
 	if e != nil {
 		t.Fatal("Not expecting error ", e)
@@ -0,0 +1,88 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// expandPresetsInYAML replaces references to named bucket presets in a YAML document, as
+// preset: name, with the preset's settings. Settings the bucket specifies itself take precedence.
+// Presets are those defined under presets in the document, or inherited if it defines none, and
+// are returned so that included files can use them too. Documents that aren't valid YAML are
+// returned as they are, for the caller to report.
+func expandPresetsInYAML(contents []byte, inherited map[interface{}]interface{}) ([]byte, map[interface{}]interface{}, error) {
+	var doc map[interface{}]interface{}
+	if yaml.Unmarshal(contents, &doc) != nil {
+		return contents, inherited, nil
+	}
+
+	presets := inherited
+	if p, ok := doc["presets"].(map[interface{}]interface{}); ok {
+		presets = p
+	}
+
+	x := &presetExpander{presets: presets}
+	x.expand(doc, "global_default_bucket", "defaults")
+	if namespaces, ok := doc["namespaces"].(map[interface{}]interface{}); ok {
+		for _, ns := range namespaces {
+			ns, _ := ns.(map[interface{}]interface{})
+			x.expand(ns, "default_bucket", "dynamic_bucket_template", "defaults")
+			if buckets, ok := ns["buckets"].(map[interface{}]interface{}); ok {
+				for name := range buckets {
+					x.expand(buckets, name)
+				}
+			}
+		}
+	}
+
+	if x.err != nil {
+		return nil, nil, x.err
+	}
+
+	if !x.expanded {
+		return contents, presets, nil
+	}
+
+	expanded, e := yaml.Marshal(doc)
+	return expanded, presets, e
+}
+
+type presetExpander struct {
+	presets  map[interface{}]interface{}
+	expanded bool
+	err      error
+}
+
+// expand expands presets referenced by the buckets under keys of parent, failing on the first
+// unknown preset.
+func (x *presetExpander) expand(parent map[interface{}]interface{}, keys ...interface{}) {
+	for _, k := range keys {
+		b, ok := parent[k].(map[interface{}]interface{})
+		if !ok || x.err != nil {
+			continue
+		}
+
+		name, ok := b["preset"]
+		if !ok {
+			continue
+		}
+
+		preset, ok := x.presets[name].(map[interface{}]interface{})
+		if !ok {
+			x.err = fmt.Errorf("Unknown preset %v for bucket %v", name, k)
+			return
+		}
+
+		delete(b, "preset")
+		for setting, v := range preset {
+			if _, set := b[setting]; !set {
+				b[setting] = v
+			}
+		}
+		x.expanded = true
+	}
+}
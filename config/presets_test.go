@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestPresets(t *testing.T) {
+	cfg := ReadConfigStrict(strings.NewReader(`presets:
+  small:
+    size: 10
+    fill_rate: 5
+    wait_timeout_millis: 1s
+  large:
+    size: 10000
+    fill_rate: 1000
+global_default_bucket:
+  preset: small
+namespaces:
+  ns:
+    dynamic_bucket_template:
+      preset: large
+    buckets:
+      a:
+        preset: small
+      b:
+        preset: small
+        fill_rate: 7
+      c:
+        size: 3
+`))
+
+	ns := cfg.Namespaces["ns"]
+	assertBucket(t, cfg.GlobalDefaultBucket, 10, 5, 1000, -1, 10000, 5)
+	assertBucket(t, ns.Buckets["a"], 10, 5, 1000, -1, 10000, 5)
+	assertBucket(t, ns.Buckets["b"], 10, 7, 1000, -1, 10000, 7)
+	assertBucket(t, ns.Buckets["c"], 3, 50, 1000, -1, 10000, 50)
+	assertBucket(t, ns.DynamicBucketTemplate, 10000, 1000, 1000, -1, 10000, 1000)
+}
+
+func TestPresetsInIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.yaml": `presets:
+  small: {size: 10}
+includes: [ns.yaml]
+`,
+		"ns.yaml": `namespaces:
+  ns:
+    buckets:
+      b: {preset: small}
+`,
+		"bad.yaml": `presets:
+  small: {size: 10}
+`,
+		"includes_bad.yaml": `includes: [bad.yaml]
+`})
+	defer os.RemoveAll(dir)
+
+	cfg := ReadConfigFromFileStrict(filepath.Join(dir, "main.yaml"))
+	if b := cfg.Namespaces["ns"].Buckets["b"]; b.Size != 10 {
+		t.Fatalf("Expecting preset from main file to apply to included buckets. Was %+v", b)
+	}
+
+	// Presets may only be defined by the main file.
+	helpers.ExpectingPanic(t, func() {
+		ReadConfigFromFile(filepath.Join(dir, "includes_bad.yaml"))
+	})
+}
+
+func TestUnknownPreset(t *testing.T) {
+	helpers.ExpectingPanic(t, func() {
+		readConfigFromBytes([]byte(`namespaces:
+  ns:
+    buckets:
+      b: {preset: medium}
+`))
+	})
+
+	if _, e := ParseConfig([]byte("namespaces:\n  ns:\n    buckets:\n      b: {preset: medium}\n")); e == nil {
+		t.Fatal("Expecting unknown preset to fail to parse.")
+	}
+}
@@ -0,0 +1,178 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"strings"
+	"time"
+
+	pb "github.com/maniksurtani/quotaservice/protos/config"
+)
+
+// scheduleTimeLayout is the layout of the start and end times of schedule entries.
+const scheduleTimeLayout = "15:04"
+
+// weekdays are the names schedule entries give days by.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday}
+
+// ScheduleEntry changes a bucket's size and fill rate during a window of the day, such as lowering
+// them overnight. Times are in UTC.
+type ScheduleEntry struct {
+	// Days the window starts on, such as mon or sat. Every day if empty.
+	Days []string `yaml:",flow"`
+	// Start and End of the window, as HH:MM. Windows ending before they start run past midnight.
+	Start, End string
+	// Size and FillRate the bucket has during the window. Settings that aren't set are unchanged.
+	Size     int64
+	FillRate int64 `yaml:"fill_rate"`
+}
+
+// activeAt tells whether t falls in the entry's window.
+func (s *ScheduleEntry) activeAt(t time.Time) bool {
+	start, e1 := time.Parse(scheduleTimeLayout, s.Start)
+	end, e2 := time.Parse(scheduleTimeLayout, s.End)
+	if e1 != nil || e2 != nil {
+		return false
+	}
+
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	day := t.Weekday()
+	switch {
+	case from < to && minute >= from && minute < to:
+	case from > to && minute >= from:
+	case from > to && minute < to:
+		// Past midnight, so the window started the day before.
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+
+	if len(s.Days) == 0 {
+		return true
+	}
+
+	for _, d := range s.Days {
+		if wd, ok := weekdays[strings.ToLower(d)]; ok && wd == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// activeEntry returns the index of the first of the bucket's schedule entries whose window t falls
+// in, or -1 if there's none.
+func (b *BucketConfig) activeEntry(t time.Time) int {
+	if b == nil {
+		return -1
+	}
+
+	for i, s := range b.Schedule {
+		if s.activeAt(t) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ScheduledAt returns the bucket's config as its schedule has it at t: a copy with the size and
+// fill rate of the entry whose window t falls in, or the bucket itself if there's none.
+func (b *BucketConfig) ScheduledAt(t time.Time) *BucketConfig {
+	i := b.activeEntry(t)
+	if i < 0 {
+		return b
+	}
+
+	s := b.Schedule[i]
+	c := b.Clone()
+	if s.Size > 0 {
+		if c.Burst == c.Size {
+			// Burst defaults to the size.
+			c.Burst = s.Size
+		}
+		c.Size = s.Size
+	}
+
+	if s.FillRate > 0 {
+		if b.FillRate > 0 && c.EmissionInterval == b.NanosPerToken(b.FillRate) {
+			// The emission interval defaults to the fill period over the fill rate.
+			c.EmissionInterval = b.NanosPerToken(s.FillRate)
+		}
+		c.FillRate = s.FillRate
+	}
+
+	return c
+}
+
+// ScheduleChanged tells whether any bucket's schedule has it configured differently at to than at
+// from.
+func (s *ServiceConfig) ScheduleChanged(from, to time.Time) bool {
+	changed := func(b *BucketConfig) bool {
+		return b.activeEntry(from) != b.activeEntry(to)
+	}
+
+	if changed(s.GlobalDefaultBucket) {
+		return true
+	}
+
+	for _, ns := range s.Namespaces {
+		if changed(ns.DefaultBucket) || changed(ns.DynamicBucketTemplate) || changed(ns.Ceiling) {
+			return true
+		}
+
+		for _, b := range ns.Buckets {
+			if changed(b) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func scheduleToProto(schedule []*ScheduleEntry) []*pb.ScheduleEntry {
+	if schedule == nil {
+		return nil
+	}
+
+	entries := make([]*pb.ScheduleEntry, len(schedule))
+	for i, s := range schedule {
+		entries[i] = &pb.ScheduleEntry{
+			Days:     s.Days,
+			Start:    s.Start,
+			End:      s.End,
+			Size:     s.Size,
+			FillRate: s.FillRate}
+	}
+
+	return entries
+}
+
+func scheduleFromProto(entries []*pb.ScheduleEntry) []*ScheduleEntry {
+	if entries == nil {
+		return nil
+	}
+
+	schedule := make([]*ScheduleEntry, len(entries))
+	for i, s := range entries {
+		schedule[i] = &ScheduleEntry{
+			Days:     s.Days,
+			Start:    s.Start,
+			End:      s.End,
+			Size:     s.Size,
+			FillRate: s.FillRate}
+	}
+
+	return schedule
+}
@@ -0,0 +1,81 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`namespaces:
+  ns:
+    buckets:
+      batch:
+        size: 100
+        fill_rate: 50
+        schedule:
+          - {days: [mon, tue, wed, thu, fri], start: "09:00", end: "17:00", size: 200, fill_rate: 100}
+          - {start: "22:00", end: "06:00", fill_rate: 10}
+`))
+	checkError(t, e)
+
+	b := cfg.Namespaces["ns"].Buckets["batch"]
+	for _, c := range []struct {
+		at             string
+		size, fillRate int64
+	}{
+		{"2016-06-06T10:00:00Z", 200, 100}, // Monday, business hours
+		{"2016-06-06T17:00:00Z", 100, 50},  // End of business hours
+		{"2016-06-04T10:00:00Z", 100, 50},  // Saturday
+		{"2016-06-06T23:30:00Z", 100, 10},  // Overnight
+		{"2016-06-07T05:59:00Z", 100, 10},  // Overnight, past midnight
+		{"2016-06-07T06:00:00Z", 100, 50}} {
+		at, _ := time.Parse(time.RFC3339, c.at)
+		if s := b.ScheduledAt(at); s.Size != c.size || s.FillRate != c.fillRate {
+			t.Fatalf("Expecting size %v and fill rate %v at %v. Was %v and %v", c.size, c.fillRate, c.at, s.Size, s.FillRate)
+		}
+	}
+
+	if b.Size != 100 || b.FillRate != 50 {
+		t.Fatalf("Expecting schedules not to change the configured bucket. Was %+v", b)
+	}
+
+	morning, _ := time.Parse(time.RFC3339, "2016-06-06T08:59:00Z")
+	if !cfg.ScheduleChanged(morning, morning.Add(time.Minute)) || cfg.ScheduleChanged(morning, morning.Add(-time.Minute)) {
+		t.Fatal("Expecting schedules to only change as windows start or end")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "schedule:") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting schedules to be exported. Exported:\n%s", y)
+	}
+
+	if !FromProto(cfg.ToProto()).Namespaces["ns"].Buckets["batch"].Equals(b) {
+		t.Fatal("Expecting schedules to survive conversion to protos.")
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	_, e := ParseConfig([]byte(`namespaces:
+  ns:
+    buckets:
+      b:
+        schedule:
+          - {days: [someday], start: "9am", end: "17:00", size: 10}
+          - {start: "10:00", end: "10:00", fill_rate: 10}
+          - {start: "10:00", end: "11:00"}
+`))
+	expected := ValidationErrors{
+		{"namespaces.ns.buckets.b.schedule[0].days", "Unknown day \"someday\""},
+		{"namespaces.ns.buckets.b.schedule[0].start", "Must be a time of day such as 09:00"},
+		{"namespaces.ns.buckets.b.schedule[1]", "Window cannot start and end at the same time"},
+		{"namespaces.ns.buckets.b.schedule[2]", "Must change size or fill_rate"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// InvalidSignatureError is returned when a config, or a payload containing one, isn't signed with
+// the expected key.
+type InvalidSignatureError struct {
+	Reason string
+}
+
+func (e InvalidSignatureError) Error() string {
+	return "Config signature rejected: " + e.Reason
+}
+
+// ConfigSigner signs configs and verifies their signatures using HMAC-SHA256 and a key shared by
+// all servers, so that changes made to configs outside the quotaservice, such as directly in a
+// compromised config store, are rejected.
+type ConfigSigner struct {
+	key []byte
+}
+
+// NewConfigSigner creates a ConfigSigner using key, which should be at least 32 random bytes.
+func NewConfigSigner(key []byte) *ConfigSigner {
+	return &ConfigSigner{key: key}
+}
+
+// Sign sets the config's Signature. Configs are signed in their canonical YAML form, which is
+// unaffected by how persisters store them, along with the Date and User of the change, which the
+// YAML leaves out, so that the history of who changed what can't be forged either.
+func (c *ConfigSigner) Sign(cfg *ServiceConfig) error {
+	mac, e := c.mac(cfg)
+	if e != nil {
+		return e
+	}
+
+	cfg.Signature = mac
+	return nil
+}
+
+// Verify returns an InvalidSignatureError unless the config's Signature is valid.
+func (c *ConfigSigner) Verify(cfg *ServiceConfig) error {
+	if len(cfg.Signature) == 0 {
+		return InvalidSignatureError{"Config is not signed"}
+	}
+
+	mac, e := c.mac(cfg)
+	if e != nil {
+		return e
+	}
+
+	if !hmac.Equal(mac, cfg.Signature) {
+		return InvalidSignatureError{"Signature does not match config"}
+	}
+
+	return nil
+}
+
+// VerifyPayload returns an InvalidSignatureError unless signature is the hex encoded
+// HMAC-SHA256 of payload. Used for configs submitted to the server, whose signature is detached.
+func (c *ConfigSigner) VerifyPayload(payload []byte, signature string) error {
+	if signature == "" {
+		return InvalidSignatureError{"Payload is not signed"}
+	}
+
+	expected, e := hex.DecodeString(signature)
+	if e != nil {
+		return InvalidSignatureError{"Signature is not hex encoded"}
+	}
+
+	if !hmac.Equal(c.sum(payload), expected) {
+		return InvalidSignatureError{"Signature does not match payload"}
+	}
+
+	return nil
+}
+
+// SignPayload returns the hex encoded HMAC-SHA256 of payload, as expected by VerifyPayload.
+func (c *ConfigSigner) SignPayload(payload []byte) string {
+	return hex.EncodeToString(c.sum(payload))
+}
+
+func (c *ConfigSigner) mac(cfg *ServiceConfig) ([]byte, error) {
+	y, e := cfg.ToYAML()
+	if e != nil {
+		return nil, e
+	}
+
+	// Quoted, so that users' names can't run into what's signed after them.
+	return c.sum(append(y, fmt.Sprintf("date: %v\nuser: %q\n", cfg.Date, cfg.User)...)), nil
+}
+
+func (c *ConfigSigner) sum(b []byte) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write(b)
+	return h.Sum(nil)
+}
@@ -0,0 +1,59 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer := NewConfigSigner([]byte("secret"))
+	cfg := readConfigFromBytes([]byte(cfgYaml))
+
+	if _, ok := signer.Verify(cfg).(InvalidSignatureError); !ok {
+		t.Fatal("Expecting unsigned config to be rejected.")
+	}
+
+	checkError(t, signer.Sign(cfg))
+	checkError(t, signer.Verify(cfg))
+
+	// Signatures survive persistence.
+	r, e := Marshal(cfg)
+	checkError(t, e)
+	unmarshalled, e := Unmarshal(r)
+	checkError(t, e)
+	checkError(t, signer.Verify(unmarshalled))
+
+	for _, tamper := range []func(*ServiceConfig){
+		func(c *ServiceConfig) { c.User = "someone else" },
+		func(c *ServiceConfig) { c.Date++ }} {
+		forged := cfg.Clone()
+		tamper(forged)
+		if _, ok := signer.Verify(forged).(InvalidSignatureError); !ok {
+			t.Fatal("Expecting config with a tampered date or user to be rejected.")
+		}
+	}
+
+	unmarshalled.Namespaces["no_default_no_dynamic"].Buckets["one"].FillRate = 1000000
+	if _, ok := signer.Verify(unmarshalled).(InvalidSignatureError); !ok {
+		t.Fatal("Expecting tampered config to be rejected.")
+	}
+
+	if _, ok := NewConfigSigner([]byte("other")).Verify(cfg).(InvalidSignatureError); !ok {
+		t.Fatal("Expecting config signed with another key to be rejected.")
+	}
+}
+
+func TestSignAndVerifyPayload(t *testing.T) {
+	signer := NewConfigSigner([]byte("secret"))
+	payload := []byte(`{"name":"b","size":10}`)
+
+	checkError(t, signer.VerifyPayload(payload, signer.SignPayload(payload)))
+
+	for _, signature := range []string{"", "not hex", signer.SignPayload([]byte("other"))} {
+		if _, ok := signer.VerifyPayload(payload, signature).(InvalidSignatureError); !ok {
+			t.Fatalf("Expecting signature %q to be rejected.", signature)
+		}
+	}
+}
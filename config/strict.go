@@ -0,0 +1,113 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// checkForUnknownFields returns an error listing any keys in a YAML config file that don't map to
+// a field of ServiceConfig or the configs nested within it.
+func checkForUnknownFields(bytes []byte) error {
+	var doc interface{}
+	if e := yaml.Unmarshal(bytes, &doc); e != nil {
+		return e
+	}
+
+	unknown := unknownFields("", doc, reflect.TypeOf(configFile{}))
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("Unknown fields in config: %v", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// unknownFields walks a generic YAML value alongside the type it is unmarshalled into, collecting
+// the paths of keys that have no corresponding struct field. Type mismatches are left for
+// yaml.Unmarshal to report.
+func unknownFields(path string, v interface{}, t reflect.Type) (unknown []string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, _ := v.(map[interface{}]interface{})
+		fields := yamlFields(t)
+		for k, fv := range m {
+			key := fmt.Sprint(k)
+			ft, ok := fields[key]
+			if !ok {
+				unknown = append(unknown, joinPath(path, key))
+				continue
+			}
+			unknown = append(unknown, unknownFields(joinPath(path, key), fv, ft)...)
+		}
+	case reflect.Map:
+		m, _ := v.(map[interface{}]interface{})
+		for k, mv := range m {
+			unknown = append(unknown, unknownFields(joinPath(path, fmt.Sprint(k)), mv, t.Elem())...)
+		}
+	case reflect.Slice:
+		s, _ := v.([]interface{})
+		for i, sv := range s {
+			unknown = append(unknown, unknownFields(fmt.Sprintf("%v[%v]", path, i), sv, t.Elem())...)
+		}
+	}
+
+	return
+}
+
+// yamlFields maps the YAML keys of a struct's fields to their types, following yaml.v2's naming
+// rules: the name in the yaml tag if present, and the lowercased field name otherwise. Fields of
+// inlined structs are included.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported
+			continue
+		}
+
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+
+		inline := false
+		for _, flag := range tag[1:] {
+			inline = inline || flag == "inline"
+		}
+
+		if inline {
+			for n, t := range yamlFields(f.Type) {
+				fields[n] = t
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
@@ -0,0 +1,60 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+const misspeltCfgYaml = `global_default_bucket:
+  fillrate: 10
+namespaces:
+  ns:
+    buckets:
+      b:
+        size: 10
+        wait_timeout: 100
+`
+
+func TestStrictParsing(t *testing.T) {
+	cfg := ReadConfigStrict(strings.NewReader(cfgYaml))
+	if len(cfg.Namespaces) != 3 {
+		t.Fatalf("Expecting 3 namespaces. Was %v", len(cfg.Namespaces))
+	}
+}
+
+func TestStrictParsingUnknownFields(t *testing.T) {
+	e := checkForUnknownFields([]byte(misspeltCfgYaml))
+	if e == nil {
+		t.Fatal("Expecting error on unknown fields.")
+	}
+
+	expected := "global_default_bucket.fillrate, namespaces.ns.buckets.b.wait_timeout"
+	if !strings.Contains(e.Error(), expected) {
+		t.Fatalf("Expecting error to name %v. Was %v", expected, e)
+	}
+
+	helpers.ExpectingPanic(t, func() {
+		ReadConfigStrict(strings.NewReader(misspeltCfgYaml))
+	})
+
+	// Unknown fields are ignored when not strict.
+	cfg := ReadConfig(strings.NewReader(misspeltCfgYaml))
+	if cfg.GlobalDefaultBucket.FillRate != 50 {
+		t.Fatalf("Expecting default fill rate. Was %v", cfg.GlobalDefaultBucket.FillRate)
+	}
+}
+
+func TestStrictParsingInvalidYaml(t *testing.T) {
+	invalid := "namespaces:\n  ns:\n    max_dynamic_buckets: lots\n"
+
+	helpers.ExpectingPanic(t, func() {
+		ReadConfigStrict(strings.NewReader(invalid))
+	})
+
+	ReadConfig(strings.NewReader(invalid))
+}
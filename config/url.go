@@ -0,0 +1,169 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// DefaultURLTimeout is how long ReadConfigFromURL waits for a config, unless told otherwise.
+const DefaultURLTimeout = 30 * time.Second
+
+// URLOptions configure how ReadConfigFromURL fetches configs.
+type URLOptions struct {
+	// Headers added to the request, such as Authorization.
+	Headers map[string]string
+	// CacheFile, if set, is updated with every valid config fetched, and read instead if the
+	// config can't be fetched or is invalid, so servers can still start while the config service
+	// is down.
+	CacheFile string
+	// Timeout for the whole request. Defaults to DefaultURLTimeout.
+	Timeout time.Duration
+	// Client used to fetch configs, such as one with custom TLS settings. Timeout is ignored if
+	// set.
+	Client *http.Client
+}
+
+// ReadConfigFromURL fetches a config from an http or https URL, in YAML, or TOML or JSON if the
+// URL's path has a .toml or .json extension, and reads it as ReadConfig does. Panics if the
+// config can neither be fetched and validated nor read from the cache file.
+func ReadConfigFromURL(configURL string, opts URLOptions) *ServiceConfig {
+	u, e := url.Parse(configURL)
+	if e != nil {
+		panic(fmt.Sprintf("Invalid config URL %v. Error: %v", configURL, e))
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		panic(fmt.Sprintf("Config URL should be http or https, but is %v", configURL))
+	}
+
+	contents, e := fetchConfig(configURL, &opts)
+	if e == nil {
+		var cfg *ServiceConfig
+		if cfg, e = readURLConfig(u.Path, contents); e == nil {
+			// Only configs that read and validate replace the last good one in the cache.
+			if opts.CacheFile != "" {
+				if e = writeCacheFile(opts.CacheFile, contents); e != nil {
+					logging.Warnf("Unable to update config cache file %v. Error: %v", opts.CacheFile, e)
+				}
+			}
+
+			return cfg
+		}
+	}
+
+	if opts.CacheFile == "" {
+		panic(fmt.Sprintf("Unable to fetch config from %v. Error: %v", configURL, e))
+	}
+
+	logging.Warnf("Unable to fetch config from %v, reading %v instead. Error: %v", configURL, opts.CacheFile, e)
+	if contents, e = ioutil.ReadFile(opts.CacheFile); e != nil {
+		panic(fmt.Sprintf("Unable to open file %v. Error: %v", opts.CacheFile, e))
+	}
+
+	cfg, e := readURLConfig(u.Path, contents)
+	if e != nil {
+		panic(fmt.Sprintf("Unable to read config cache file %v. Error: %v", opts.CacheFile, e))
+	}
+
+	return cfg
+}
+
+// readURLConfig reads and validates a config fetched from a URL with the path, returning an error
+// rather than panicking if it's invalid.
+func readURLConfig(path string, contents []byte) (cfg *ServiceConfig, e error) {
+	if contents, e = toYAML(path, contents); e != nil {
+		return nil, e
+	}
+
+	// ReadConfig ignores YAML errors, so they're looked for up front.
+	var doc interface{}
+	if e = yaml.Unmarshal(contents, &doc); e != nil {
+		return nil, e
+	}
+
+	if doc == nil {
+		return nil, errors.New("Config is empty")
+	}
+
+	defer func() {
+		// Invalid configs panic when defaults are applied.
+		if r := recover(); r != nil {
+			cfg, e = nil, fmt.Errorf("%v", r)
+		}
+	}()
+
+	cfg = readConfigFromBytes(contents)
+	if e = cfg.Validate(); e != nil {
+		return nil, e
+	}
+
+	return cfg, nil
+}
+
+func fetchConfig(configURL string, opts *URLOptions) ([]byte, error) {
+	client := opts.Client
+	if client == nil {
+		timeout := opts.Timeout
+		if timeout == 0 {
+			timeout = DefaultURLTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+
+	req, e := http.NewRequest("GET", configURL, nil)
+	if e != nil {
+		return nil, e
+	}
+
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	rsp, e := client.Do(req)
+	if e != nil {
+		return nil, e
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected response %v", rsp.Status)
+	}
+
+	return ioutil.ReadAll(rsp.Body)
+}
+
+// writeCacheFile replaces the cache file atomically, so a crash never leaves a partial config.
+func writeCacheFile(filename string, contents []byte) error {
+	f, e := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename))
+	if e != nil {
+		return e
+	}
+
+	_, e = f.Write(contents)
+	if closeErr := f.Close(); e == nil {
+		e = closeErr
+	}
+
+	if e == nil {
+		e = os.Rename(f.Name(), filename)
+	}
+
+	if e != nil {
+		os.Remove(f.Name())
+	}
+
+	return e
+}
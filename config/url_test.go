@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestReadConfigFromURL(t *testing.T) {
+	online := true
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/qs.yaml":
+			w.Write([]byte("namespaces:\n  ns:\n    buckets:\n      b:\n        size: 10\n"))
+		case "/broken.yaml":
+			w.Write([]byte("namespaces: [\n"))
+		case "/invalid.yaml":
+			w.Write([]byte("namespaces:\n  ns:\n    buckets:\n      b:\n        size: -1\n"))
+		case "/qs.json":
+			w.Write([]byte(`{"namespaces": {"ns": {"buckets": {"b": {"size": 20}}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir, e := ioutil.TempDir("", "qs_test_url")
+	checkError(t, e)
+	defer os.RemoveAll(dir)
+
+	opts := URLOptions{
+		Headers:   map[string]string{"Authorization": "Bearer secret"},
+		CacheFile: filepath.Join(dir, "cache.yaml"),
+		Client:    srv.Client()}
+
+	cfg := ReadConfigFromURL(srv.URL+"/qs.yaml", opts)
+	if cfg.Namespaces["ns"].Buckets["b"].Size != 10 {
+		t.Fatalf("Config not read correctly: %+v", cfg)
+	}
+
+	// The config service is down, so the cached config is used.
+	online = false
+	cfg = ReadConfigFromURL(srv.URL+"/qs.yaml", opts)
+	if cfg.Namespaces["ns"].Buckets["b"].Size != 10 {
+		t.Fatalf("Cached config not read correctly: %+v", cfg)
+	}
+
+	// Broken or invalid configs leave the last good config in the cache, and it's read instead.
+	online = true
+	for _, path := range []string{"/broken.yaml", "/invalid.yaml"} {
+		cfg = ReadConfigFromURL(srv.URL+path, opts)
+		if cfg.Namespaces["ns"].Buckets["b"].Size != 10 {
+			t.Fatalf("Expecting the cached config instead of %v. Was %+v", path, cfg)
+		}
+	}
+
+	online = false
+	cfg = ReadConfigFromURL(srv.URL+"/qs.yaml", opts)
+	if cfg.Namespaces["ns"].Buckets["b"].Size != 10 {
+		t.Fatalf("Cached config not read correctly: %+v", cfg)
+	}
+
+	online = true
+	opts.CacheFile = ""
+	helpers.ExpectingPanic(t, func() {
+		ReadConfigFromURL(srv.URL+"/invalid.yaml", opts)
+	})
+
+	cfg = ReadConfigFromURL(srv.URL+"/qs.json", opts)
+	if cfg.Namespaces["ns"].Buckets["b"].Size != 20 {
+		t.Fatalf("JSON config not read correctly: %+v", cfg)
+	}
+
+	opts.Headers = nil
+	helpers.ExpectingPanic(t, func() {
+		ReadConfigFromURL(srv.URL+"/qs.yaml", opts)
+	})
+}
+
+func TestInvalidConfigURL(t *testing.T) {
+	for _, u := range []string{"file:///etc/qs.yaml", "qs.yaml", "http://%zz"} {
+		helpers.ExpectingPanic(t, func() {
+			ReadConfigFromURL(u, URLOptions{})
+		})
+	}
+}
@@ -0,0 +1,607 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ParseConfig parses a YAML config, validates it and applies defaults. Unlike ReadConfigStrict, it
+// returns errors rather than panicking, and neither expands environment variables nor follows
+// includes, so it is safe to use on configs submitted to the server.
+func ParseConfig(contents []byte) (*ServiceConfig, error) {
+	cfg, e := parseConfigDocument(contents)
+	if e != nil {
+		return nil, e
+	}
+
+	if e = cfg.Validate(); e != nil {
+		return nil, e
+	}
+
+	return cfg.ApplyDefaults(), nil
+}
+
+// ParseNamespaceConfig reads the YAML definition of a single namespace, as written by
+// NamespaceConfig.ToYAML, and validates it. Unlike ParseConfig, defaults aren't applied, so that
+// those of the config the namespace is added to apply instead.
+func ParseNamespaceConfig(name string, contents []byte) (*NamespaceConfig, error) {
+	var doc interface{}
+	if e := yaml.Unmarshal(contents, &doc); e != nil {
+		return nil, e
+	}
+
+	if doc == nil {
+		doc = map[interface{}]interface{}{}
+	} else if _, ok := doc.(map[interface{}]interface{}); !ok {
+		return nil, errors.New("Namespace config should be a map of settings")
+	}
+
+	wrapped, e := yaml.Marshal(map[string]interface{}{"namespaces": map[string]interface{}{name: doc}})
+	if e != nil {
+		return nil, e
+	}
+
+	cfg, e := parseConfigDocument(wrapped)
+	if e != nil {
+		return nil, e
+	}
+
+	ns := cfg.Namespaces[name]
+	ns.Name = name
+	if e = ns.Validate(); e != nil {
+		return nil, e
+	}
+
+	if ns.Buckets == nil {
+		ns.Buckets = make(map[string]*BucketConfig)
+	}
+
+	for n, b := range ns.Buckets {
+		b.Name = n
+		b.namespace = ns
+	}
+
+	return ns, nil
+}
+
+// parseConfigDocument reads a config without validating it or applying defaults.
+func parseConfigDocument(contents []byte) (*ServiceConfig, error) {
+	contents, e := convertDurationsInYAML(contents)
+	if e != nil {
+		return nil, e
+	}
+
+	contents, _, e = expandPresetsInYAML(contents, nil)
+	if e != nil {
+		return nil, e
+	}
+
+	f := &configFile{ServiceConfig: ServiceConfig{Namespaces: make(map[string]*NamespaceConfig)}}
+	if e = yaml.Unmarshal(contents, f); e != nil {
+		return nil, e
+	}
+
+	if e = checkForUnknownFields(contents); e != nil {
+		return nil, e
+	}
+
+	if len(f.Includes) > 0 {
+		return nil, errors.New("Includes are not supported here")
+	}
+
+	return &f.ServiceConfig, nil
+}
+
+// ValidationError describes a problem found validating a config.
+type ValidationError struct {
+	// Path to the offending setting, such as namespaces.ns.buckets.b.fill_rate.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every problem found validating a config, sorted by path.
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+	problems := make([]string, len(v))
+	for i, e := range v {
+		problems[i] = e.Path + ": " + e.Message
+	}
+
+	return "Invalid config: " + strings.Join(problems, "; ")
+}
+
+func (v ValidationErrors) Len() int           { return len(v) }
+func (v ValidationErrors) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v ValidationErrors) Less(i, j int) bool { return v[i].Path < v[j].Path }
+
+// asError returns the problems sorted by path, or nil if there aren't any.
+func (v ValidationErrors) asError() error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	sort.Stable(v)
+	return v
+}
+
+// BucketLimits are guard rails on bucket settings, so that typos such as an extra few zeros are
+// rejected when configs are validated. Zero means unlimited.
+type BucketLimits struct {
+	MaxSize              int64
+	MaxFillRate          int64
+	MaxWaitTimeoutMillis int64
+}
+
+var limits BucketLimits
+
+// SetLimits changes the BucketLimits enforced when configs are validated from here on.
+func SetLimits(l BucketLimits) {
+	limits = l
+}
+
+// Validate checks that the config can be applied, returning ValidationErrors describing every
+// problem found, or nil. It may be called before or after defaults are applied.
+func (s *ServiceConfig) Validate() error {
+	var problems ValidationErrors
+	problems = append(problems, validateBucket("global_default_bucket", s.GlobalDefaultBucket)...)
+	problems = append(problems, validateBucket("defaults", s.Defaults)...)
+	problems = append(problems, noParent("global_default_bucket", s.GlobalDefaultBucket, "Only buckets in namespaces can have parents")...)
+	problems = append(problems, noParent("defaults", s.Defaults, "Parents aren't inherited from defaults")...)
+	if s.GlobalRateCap < 0 {
+		problems = append(problems, ValidationError{"global_rate_cap", "Cannot be negative"})
+	}
+
+	if r := s.Redis; r != nil {
+		if r.Addr == "" {
+			problems = append(problems, ValidationError{"redis.addr", "Missing address"})
+		}
+
+		if r.PoolSize < 0 {
+			problems = append(problems, ValidationError{"redis.pool_size", "Cannot be negative"})
+		}
+
+		if r.ConnectionRetries < 0 {
+			problems = append(problems, ValidationError{"redis.connection_retries", "Cannot be negative"})
+		}
+	}
+
+	if d := s.Dynamo; d != nil {
+		if d.Region == "" {
+			problems = append(problems, ValidationError{"dynamo.region", "Missing region"})
+		}
+
+		if d.Table == "" {
+			problems = append(problems, ValidationError{"dynamo.table", "Missing table"})
+		}
+
+		if d.MaxAttempts < 0 {
+			problems = append(problems, ValidationError{"dynamo.max_attempts", "Cannot be negative"})
+		}
+	}
+
+	if c := s.Cassandra; c != nil {
+		if len(c.Hosts) == 0 {
+			problems = append(problems, ValidationError{"cassandra.hosts", "Missing hosts"})
+		}
+
+		if c.Keyspace == "" {
+			problems = append(problems, ValidationError{"cassandra.keyspace", "Missing keyspace"})
+		}
+
+		if c.Table == "" {
+			problems = append(problems, ValidationError{"cassandra.table", "Missing table"})
+		}
+
+		if c.Consistency != "" && !cassandraConsistencies[c.Consistency] {
+			problems = append(problems, ValidationError{"cassandra.consistency", "Unknown consistency " + strconv.Quote(c.Consistency)})
+		}
+
+		if c.Mode != "" && c.Mode != CassandraCounter && c.Mode != CassandraLWT {
+			problems = append(problems, ValidationError{"cassandra.mode", "Unknown mode " + strconv.Quote(c.Mode)})
+		}
+
+		if c.MaxAttempts < 0 {
+			problems = append(problems, ValidationError{"cassandra.max_attempts", "Cannot be negative"})
+		}
+	}
+
+	aliases := make(map[string]string)
+	for name, ns := range s.Namespaces {
+		problems = append(problems, validateNamespace("namespaces."+name, name, ns)...)
+		if ns == nil {
+			continue
+		}
+
+		if ns.Backend == BackendRedis && s.Redis == nil {
+			problems = append(problems, ValidationError{"namespaces." + name + ".backend", "Requires the redis settings to be configured"})
+		} else if ns.Backend == BackendDynamo && s.Dynamo == nil {
+			problems = append(problems, ValidationError{"namespaces." + name + ".backend", "Requires the dynamo settings to be configured"})
+		} else if ns.Backend == BackendCassandra && s.Cassandra == nil {
+			problems = append(problems, ValidationError{"namespaces." + name + ".backend", "Requires the cassandra settings to be configured"})
+		}
+
+		for _, alias := range ns.Aliases {
+			path := "namespaces." + name + ".aliases"
+			if alias == name {
+				// Reported by validateNamespace.
+				continue
+			} else if _, exists := s.Namespaces[alias]; exists {
+				problems = append(problems, ValidationError{path, "Alias " + alias + " is already a namespace"})
+			} else if other, exists := aliases[alias]; exists {
+				problems = append(problems, ValidationError{path, "Alias " + alias + " is also an alias of namespace " + other})
+			}
+			aliases[alias] = name
+		}
+	}
+
+	return problems.asError()
+}
+
+// Validate checks that the namespace can be applied, returning ValidationErrors describing every
+// problem found, or nil.
+func (n *NamespaceConfig) Validate() error {
+	return validateNamespace("namespaces."+n.Name, n.Name, n).asError()
+}
+
+// ValidateBucketDeletion checks that a bucket can be removed from the namespace, which it can't be
+// while other buckets name it as their parent.
+func (n *NamespaceConfig) ValidateBucketDeletion(name string) error {
+	var problems ValidationErrors
+	check := func(b *BucketConfig) {
+		if b != nil && b.Parent == name {
+			problems = append(problems, ValidationError{b.path() + ".parent", "Parent " + name + " cannot be removed"})
+		}
+	}
+
+	check(n.DefaultBucket)
+	check(n.DynamicBucketTemplate)
+	for _, b := range n.Buckets {
+		check(b)
+	}
+
+	return problems.asError()
+}
+
+// Validate checks the bucket's settings, returning ValidationErrors describing every problem
+// found, or nil.
+func (b *BucketConfig) Validate() error {
+	problems := validateBucket(b.path(), b)
+	if b.namespace != nil {
+		problems = append(problems, validateParent(b.path(), b.Name, b.namespace, b)...)
+	} else {
+		problems = append(problems, noParent(b.path(), b, "Only buckets in namespaces can have parents")...)
+	}
+
+	return problems.asError()
+}
+
+// path returns the path to the bucket within its ServiceConfig.
+func (b *BucketConfig) path() string {
+	if b.namespace == nil {
+		if b.Name == "" || b.Name == DefaultBucketName {
+			return "global_default_bucket"
+		}
+		return b.Name
+	}
+
+	path := "namespaces." + b.namespace.Name
+	switch b.Name {
+	case DefaultBucketName:
+		return path + ".default_bucket"
+	case DynamicBucketTemplateName:
+		return path + ".dynamic_bucket_template"
+	case CeilingBucketName:
+		return path + ".ceiling"
+	}
+
+	return path + ".buckets." + b.Name
+}
+
+func validateNamespace(path, name string, ns *NamespaceConfig) (problems ValidationErrors) {
+	if name == GlobalNamespace || name == AdminNamespace {
+		problems = append(problems, ValidationError{path, "Namespace name is reserved"})
+	}
+
+	if ns == nil {
+		return append(problems, ValidationError{path, "Missing namespace config"})
+	}
+
+	for _, alias := range ns.Aliases {
+		if alias == "" || alias == GlobalNamespace || alias == AdminNamespace || alias == name {
+			problems = append(problems, ValidationError{path + ".aliases", "Invalid alias " + strconv.Quote(alias)})
+		}
+	}
+
+	if ns.DefaultBucket != nil && ns.DynamicBucketTemplate != nil {
+		problems = append(problems, ValidationError{path, "A namespace cannot have a default bucket as well as allow dynamic buckets"})
+	}
+
+	if ns.MaxDynamicBuckets < 0 {
+		problems = append(problems, ValidationError{path + ".max_dynamic_buckets", "Cannot be negative"})
+	} else if ns.MaxDynamicBuckets > 0 && ns.DynamicBucketTemplate == nil {
+		problems = append(problems, ValidationError{path + ".max_dynamic_buckets", "Only applies to namespaces with a dynamic_bucket_template"})
+	}
+
+	if ns.Eviction != "" && !evictionPolicies[ns.Eviction] {
+		problems = append(problems, ValidationError{path + ".eviction", "Unknown eviction policy " + strconv.Quote(ns.Eviction)})
+	} else if ns.Eviction != "" && ns.MaxDynamicBuckets == 0 {
+		problems = append(problems, ValidationError{path + ".eviction", "Only applies to namespaces with max_dynamic_buckets"})
+	}
+
+	if ns.Backend != "" && !knownBackend(ns.Backend) {
+		problems = append(problems, ValidationError{path + ".backend", "Unknown backend " + strconv.Quote(ns.Backend)})
+	}
+
+	if ns.Tolerance < 0 || ns.Tolerance > 1 {
+		problems = append(problems, ValidationError{path + ".tolerance", "Must be between 0 and 1"})
+	} else if ns.Tolerance > 0 && (ns.Backend == "" || ns.Backend == BackendMemory) {
+		problems = append(problems, ValidationError{path + ".tolerance", "Only applies to namespaces with a shared backend"})
+	}
+
+	if ns.Pooled && ns.Ceiling == nil {
+		problems = append(problems, ValidationError{path + ".pooled", "Only applies to namespaces with a ceiling"})
+	}
+
+	problems = append(problems, validateBucket(path+".defaults", ns.Defaults)...)
+	problems = append(problems, validateBucket(path+".default_bucket", ns.DefaultBucket)...)
+	problems = append(problems, validateBucket(path+".dynamic_bucket_template", ns.DynamicBucketTemplate)...)
+	problems = append(problems, validateBucket(path+".ceiling", ns.Ceiling)...)
+	problems = append(problems, noParent(path+".defaults", ns.Defaults, "Parents aren't inherited from defaults")...)
+	problems = append(problems, noParent(path+".ceiling", ns.Ceiling, "A namespace's ceiling cannot have a parent")...)
+	problems = append(problems, validateParent(path+".default_bucket", DefaultBucketName, ns, ns.DefaultBucket)...)
+	problems = append(problems, validateParent(path+".dynamic_bucket_template", DynamicBucketTemplateName, ns, ns.DynamicBucketTemplate)...)
+	for bucketName, b := range ns.Buckets {
+		bucketPath := path + ".buckets." + bucketName
+		if bucketName == DefaultBucketName || bucketName == DynamicBucketTemplateName || bucketName == CeilingBucketName {
+			problems = append(problems, ValidationError{bucketPath, "Bucket name is reserved"})
+		}
+
+		if b == nil {
+			problems = append(problems, ValidationError{bucketPath, "Missing bucket config"})
+			continue
+		}
+		problems = append(problems, validateBucket(bucketPath, b)...)
+		problems = append(problems, validateParent(bucketPath, bucketName, ns, b)...)
+	}
+
+	return
+}
+
+func noParent(path string, b *BucketConfig, message string) ValidationErrors {
+	if b == nil || b.Parent == "" {
+		return nil
+	}
+
+	return ValidationErrors{{path + ".parent", message}}
+}
+
+// validateParent checks that the chain of parents above a bucket in ns ends, without looping back,
+// at buckets that exist and can cap it.
+func validateParent(path, name string, ns *NamespaceConfig, b *BucketConfig) ValidationErrors {
+	if b == nil {
+		return nil
+	}
+
+	seen := map[string]bool{name: true}
+	for parent := b.Parent; parent != ""; {
+		var p *BucketConfig
+		if parent == CeilingBucketName {
+			if p = ns.Ceiling; p == nil {
+				return ValidationErrors{{path + ".parent", "The namespace has no ceiling"}}
+			}
+		} else if p = ns.Buckets[parent]; p == nil {
+			return ValidationErrors{{path + ".parent", "Unknown parent " + strconv.Quote(parent)}}
+		}
+
+		if seen[parent] {
+			return ValidationErrors{{path + ".parent", "Parents cannot form a cycle"}}
+		}
+
+		if p.Algorithm == ConcurrencyAlgorithm {
+			return ValidationErrors{{path + ".parent", "Concurrency buckets cannot be parents"}}
+		}
+
+		seen[parent] = true
+		parent = p.Parent
+	}
+
+	return nil
+}
+
+// algorithms are those buckets can limit requests with.
+var evictionPolicies = map[string]bool{
+	EvictionReject:    true,
+	EvictionLRU:       true,
+	EvictionLowestUse: true}
+
+// cassandraConsistencies are the consistency levels Cassandra buckets can read and write at.
+var cassandraConsistencies = map[string]bool{
+	"one":          true,
+	"two":          true,
+	"three":        true,
+	"quorum":       true,
+	"all":          true,
+	"local_quorum": true,
+	"each_quorum":  true,
+	"local_one":    true}
+
+var algorithms = map[string]bool{
+	TokenBucketAlgorithm:   true,
+	SlidingWindowAlgorithm: true,
+	GCRAAlgorithm:          true,
+	ConcurrencyAlgorithm:   true}
+
+var (
+	backendsLock sync.RWMutex
+	backends     = map[string]bool{
+		BackendMemory:    true,
+		BackendRedis:     true,
+		BackendDynamo:    true,
+		BackendCassandra: true}
+)
+
+// RegisterBackend makes name a backend namespaces can keep their buckets in. Backends are
+// registered along with their bucket factories, through backends.Register.
+func RegisterBackend(name string) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	backends[name] = true
+}
+
+func knownBackend(name string) bool {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+
+	return backends[name]
+}
+
+var bucketModes = map[string]bool{
+	BucketModeNormal:      true,
+	BucketModeAlwaysAllow: true,
+	BucketModeAlwaysDeny:  true,
+	BucketModeShadow:      true}
+
+func validateBucket(path string, b *BucketConfig) (problems ValidationErrors) {
+	if b == nil {
+		return
+	}
+
+	for _, setting := range []struct {
+		name         string
+		value, limit int64
+	}{
+		{"size", b.Size, limits.MaxSize},
+		{"fill_rate", b.FillRate, limits.MaxFillRate},
+		{"wait_timeout_millis", b.WaitTimeoutMillis, limits.MaxWaitTimeoutMillis},
+		{"max_debt_millis", b.MaxDebtMillis, 0},
+		{"max_tokens_per_request", b.MaxTokensPerRequest, 0},
+		{"window_millis", b.WindowMillis, 0},
+		{"burst", b.Burst, 0},
+		{"emission_interval", b.EmissionInterval, 0},
+		{"lease_ttl_millis", b.LeaseTTLMillis, 0},
+		{"max_waiters", b.MaxWaiters, 0},
+		{"high_priority_reserve", b.HighPriorityReserve, 0},
+		{"fill_period_millis", b.FillPeriodMillis, 0}} {
+		if setting.value < 0 {
+			problems = append(problems, ValidationError{path + "." + setting.name, "Cannot be negative"})
+		} else if setting.limit > 0 && setting.value > setting.limit {
+			problems = append(problems, ValidationError{path + "." + setting.name,
+				fmt.Sprintf("%v exceeds the limit of %v", setting.value, setting.limit)})
+		}
+	}
+
+	for op, cost := range b.Costs {
+		if cost <= 0 {
+			problems = append(problems, ValidationError{path + ".costs." + op, "Must be positive"})
+		}
+	}
+
+	problems = append(problems, validateAdaptive(path+".adaptive", b)...)
+	problems = append(problems, validateSchedule(path+".schedule", b)...)
+
+	if p := b.InitialFillPercent; p != nil && (*p < 0 || *p > 100) {
+		problems = append(problems, ValidationError{path + ".initial_fill_percent", "Must be between 0 and 100"})
+	} else if p != nil && b.Algorithm == ConcurrencyAlgorithm {
+		problems = append(problems, ValidationError{path + ".initial_fill_percent", "Doesn't apply to concurrency buckets"})
+	}
+
+	if p := b.EnforcementPercent; p != nil && (*p < 0 || *p > 100) {
+		problems = append(problems, ValidationError{path + ".enforcement_percent", "Must be between 0 and 100"})
+	}
+
+	if b.Size > 0 && b.HighPriorityReserve > b.Size {
+		problems = append(problems, ValidationError{path + ".high_priority_reserve", "Cannot exceed size"})
+	}
+
+	if b.Algorithm != "" && !algorithms[b.Algorithm] {
+		problems = append(problems, ValidationError{path + ".algorithm", "Unknown algorithm " + strconv.Quote(b.Algorithm)})
+	}
+
+	if b.Mode != "" && !bucketModes[b.Mode] {
+		problems = append(problems, ValidationError{path + ".mode", "Unknown mode " + strconv.Quote(b.Mode)})
+	}
+
+	return
+}
+
+func validateSchedule(path string, b *BucketConfig) (problems ValidationErrors) {
+	for i, s := range b.Schedule {
+		entryPath := fmt.Sprintf("%v[%v]", path, i)
+		for _, d := range s.Days {
+			if _, ok := weekdays[strings.ToLower(d)]; !ok {
+				problems = append(problems, ValidationError{entryPath + ".days", "Unknown day " + strconv.Quote(d)})
+			}
+		}
+
+		start, e1 := time.Parse(scheduleTimeLayout, s.Start)
+		if e1 != nil {
+			problems = append(problems, ValidationError{entryPath + ".start", "Must be a time of day such as 09:00"})
+		}
+
+		end, e2 := time.Parse(scheduleTimeLayout, s.End)
+		if e2 != nil {
+			problems = append(problems, ValidationError{entryPath + ".end", "Must be a time of day such as 17:30"})
+		}
+
+		if e1 == nil && e2 == nil && start.Equal(end) {
+			problems = append(problems, ValidationError{entryPath, "Window cannot start and end at the same time"})
+		}
+
+		if s.Size < 0 || s.FillRate < 0 {
+			problems = append(problems, ValidationError{entryPath, "Size and fill_rate cannot be negative"})
+		} else if s.Size == 0 && s.FillRate == 0 {
+			problems = append(problems, ValidationError{entryPath, "Must change size or fill_rate"})
+		}
+	}
+
+	return
+}
+
+func validateAdaptive(path string, b *BucketConfig) (problems ValidationErrors) {
+	a := b.Adaptive
+	if a == nil {
+		return
+	}
+
+	if b.Algorithm != "" && b.Algorithm != TokenBucketAlgorithm && b.Algorithm != GCRAAlgorithm {
+		problems = append(problems, ValidationError{path, "Only applies to token_bucket and gcra buckets"})
+	}
+
+	if a.MaxErrorRate < 0 || a.MaxErrorRate > 1 {
+		problems = append(problems, ValidationError{path + ".max_error_rate", "Must be between 0 and 1"})
+	}
+
+	if a.BackoffPercent < 0 || a.BackoffPercent >= 100 {
+		problems = append(problems, ValidationError{path + ".backoff_percent", "Must be between 0 and 99"})
+	}
+
+	for _, setting := range []struct {
+		name  string
+		value int64
+	}{
+		{"max_latency_millis", a.MaxLatencyMillis},
+		{"increase", a.Increase},
+		{"min_fill_rate", a.MinFillRate}} {
+		if setting.value < 0 {
+			problems = append(problems, ValidationError{path + "." + setting.name, "Cannot be negative"})
+		}
+	}
+
+	if b.FillRate > 0 && a.MinFillRate > b.FillRate {
+		problems = append(problems, ValidationError{path + ".min_fill_rate", "Cannot exceed fill_rate"})
+	}
+
+	return
+}
@@ -0,0 +1,742 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`version: 4
+namespaces:
+  ns:
+    buckets:
+      b:
+        fill_rate: 10
+        wait_timeout_millis: 2s
+`))
+	checkError(t, e)
+
+	b := cfg.Namespaces["ns"].Buckets["b"]
+	if cfg.Version != 4 || b.FillRate != 10 || b.WaitTimeoutMillis != 2000 || b.Size != 100 {
+		t.Fatalf("Unexpected config %+v", cfg)
+	}
+}
+
+func TestParseInvalidConfigs(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"malformed":      "namespaces: [",
+		"unknown field":  "namespaces:\n  ns:\n    buckets:\n      b:\n        fil_rate: 10\n",
+		"includes":       "includes: [other.yaml]\n",
+		"bad duration":   "defaults:\n  wait_timeout_millis: soon\n",
+		"conflict":       "namespaces:\n  ns:\n    default_bucket: {}\n    dynamic_bucket_template: {}\n",
+		"negative":       "namespaces:\n  ns:\n    max_dynamic_buckets: -1\n",
+		"missing bucket": "namespaces:\n  ns:\n    buckets:\n      b:\n",
+	} {
+		if _, e := ParseConfig([]byte(yaml)); e == nil {
+			t.Fatalf("Expecting %v config to fail to parse.", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := NewDefaultServiceConfig()
+	checkError(t, cfg.Validate())
+
+	cfg.GlobalDefaultBucket.Size = -1
+	ns := NewDefaultNamespaceConfig()
+	ns.DefaultBucket = NewDefaultBucketConfig()
+	ns.DynamicBucketTemplate = NewDefaultBucketConfig()
+	ns.AddBucket("b", &BucketConfig{FillRate: -5})
+	cfg.AddNamespace("ns", ns)
+
+	ns = NewDefaultNamespaceConfig()
+	ns.MaxDynamicBuckets = 3
+	ns.AddBucket(DefaultBucketName, NewDefaultBucketConfig())
+	cfg.AddNamespace(GlobalNamespace, ns)
+	cfg.AddNamespace(AdminNamespace, NewDefaultNamespaceConfig())
+
+	e := cfg.Validate()
+	problems, ok := e.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expecting ValidationErrors. Was %v", e)
+	}
+
+	expected := ValidationErrors{
+		{"global_default_bucket.size", "Cannot be negative"},
+		{"namespaces.___ADMIN___", "Namespace name is reserved"},
+		{"namespaces.___GLOBAL___", "Namespace name is reserved"},
+		{"namespaces.___GLOBAL___.buckets.___DEFAULT_BUCKET___", "Bucket name is reserved"},
+		{"namespaces.___GLOBAL___.max_dynamic_buckets", "Only applies to namespaces with a dynamic_bucket_template"},
+		{"namespaces.ns", "A namespace cannot have a default bucket as well as allow dynamic buckets"},
+		{"namespaces.ns.buckets.b.fill_rate", "Cannot be negative"}}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Fatalf("Expecting %v. Was %v", expected, problems)
+	}
+
+	if !strings.HasPrefix(e.Error(), "Invalid config: global_default_bucket.size: Cannot be negative; ") {
+		t.Fatalf("Unexpected error message %v", e)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	defer SetLimits(limits)
+	SetLimits(BucketLimits{MaxSize: 1000, MaxFillRate: 100, MaxWaitTimeoutMillis: 5000})
+
+	_, e := ParseConfig([]byte(`namespaces:
+  ns:
+    buckets:
+      typo:
+        size: 10000000000
+        fill_rate: 100
+        wait_timeout_millis: 1m
+`))
+
+	expected := ValidationErrors{
+		{"namespaces.ns.buckets.typo.size", "10000000000 exceeds the limit of 1000"},
+		{"namespaces.ns.buckets.typo.wait_timeout_millis", "60000 exceeds the limit of 5000"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting %v. Was %v", expected, e)
+	}
+
+	ns := NewDefaultNamespaceConfig()
+	ns.Name = "ns"
+	ns.SetDynamicBucketTemplate(&BucketConfig{FillRate: 101})
+	if e = ns.Validate(); !reflect.DeepEqual(e, ValidationErrors{{"namespaces.ns.dynamic_bucket_template.fill_rate", "101 exceeds the limit of 100"}}) {
+		t.Fatalf("Unexpected errors validating namespace: %v", e)
+	}
+
+	if e = ns.DynamicBucketTemplate.Validate(); !reflect.DeepEqual(e, ValidationErrors{{"namespaces.ns.dynamic_bucket_template.fill_rate", "101 exceeds the limit of 100"}}) {
+		t.Fatalf("Unexpected errors validating bucket: %v", e)
+	}
+
+	checkError(t, NewDefaultBucketConfig().Validate())
+}
+
+func TestValidateAliases(t *testing.T) {
+	_, e := ParseConfig([]byte(`namespaces:
+  a:
+    aliases: [b, x]
+  b: {}
+  c:
+    aliases: [c]
+`))
+
+	expected := ValidationErrors{
+		{"namespaces.a.aliases", "Alias b is already a namespace"},
+		{"namespaces.c.aliases", "Invalid alias \"c\""}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+
+	_, e = ParseConfig([]byte(`namespaces:
+  a:
+    aliases: [x]
+  b:
+    aliases: [x]
+`))
+	if problems, ok := e.(ValidationErrors); !ok || len(problems) != 1 || !strings.HasPrefix(problems[0].Message, "Alias x is also an alias of namespace") {
+		t.Fatalf("Expecting alias reused by two namespaces to be rejected, got %v", e)
+	}
+
+	cfg, e := ParseConfig([]byte("namespaces:\n  new:\n    aliases: [old]\n"))
+	checkError(t, e)
+	if !reflect.DeepEqual(FromProto(cfg.ToProto()).Namespaces["new"].Aliases, []string{"old"}) {
+		t.Fatal("Expecting aliases to survive conversion to protos.")
+	}
+}
+
+func TestValidateAlgorithms(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`defaults:
+  window_millis: 1m
+namespaces:
+  ns:
+    buckets:
+      sliding:
+        algorithm: sliding_window
+        size: 10
+      gcra:
+        algorithm: gcra
+        emission_interval: 20ms
+      concurrent:
+        algorithm: concurrency
+        lease_ttl_millis: 30s
+      token: {}
+`))
+	checkError(t, e)
+
+	sliding := cfg.Namespaces["ns"].Buckets["sliding"]
+	if sliding.Algorithm != SlidingWindowAlgorithm || sliding.WindowMillis != 60000 {
+		t.Fatalf("Unexpected bucket %+v", sliding)
+	}
+
+	gcra := cfg.Namespaces["ns"].Buckets["gcra"]
+	if gcra.EmissionInterval != int64(20*time.Millisecond) || gcra.Burst != gcra.Size {
+		t.Fatalf("Unexpected bucket %+v", gcra)
+	}
+
+	if concurrent := cfg.Namespaces["ns"].Buckets["concurrent"]; concurrent.LeaseTTLMillis != 30000 {
+		t.Fatalf("Unexpected bucket %+v", concurrent)
+	}
+
+	if !FromProto(cfg.ToProto()).Namespaces["ns"].Buckets["sliding"].Equals(sliding) {
+		t.Fatal("Expecting algorithms to survive conversion to protos.")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "algorithm: sliding_window") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting algorithms to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  ns:\n    buckets:\n      b:\n        algorithm: leaky\n"))
+	expected := ValidationErrors{{"namespaces.ns.buckets.b.algorithm", "Unknown algorithm \"leaky\""}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateParents(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`namespaces:
+  customers:
+    ceiling: {size: 1000}
+    dynamic_bucket_template: {size: 10, parent: tier}
+    buckets:
+      tier: {size: 100, parent: ___CEILING___}
+`))
+	checkError(t, e)
+
+	ns := cfg.Namespaces["customers"]
+	if ns.Ceiling.Name != CeilingBucketName || ns.Ceiling.FillRate != 50 || ns.DynamicBucketTemplate.Parent != "tier" {
+		t.Fatalf("Unexpected namespace %+v", ns)
+	}
+
+	if !FromProto(cfg.ToProto()).Namespaces["customers"].Ceiling.Equals(ns.Ceiling) {
+		t.Fatal("Expecting ceilings to survive conversion to protos.")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "parent: ___CEILING___") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting parents and ceilings to be exported. Exported:\n%s", y)
+	}
+
+	expected := ValidationErrors{{"namespaces.customers.dynamic_bucket_template.parent", "Parent tier cannot be removed"}}
+	if e := ns.ValidateBucketDeletion("tier"); !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+
+	_, e = ParseConfig([]byte(`global_default_bucket: {parent: a}
+defaults: {parent: a}
+namespaces:
+  ns:
+    ceiling: {parent: a}
+    default_bucket: {parent: missing}
+    buckets:
+      a: {parent: b}
+      b: {parent: a}
+      c: {parent: ___CEILING___}
+      d: {parent: e}
+      e: {algorithm: concurrency}
+  no_ceiling:
+    buckets:
+      a: {parent: ___CEILING___}
+`))
+	expected = ValidationErrors{
+		{"defaults.parent", "Parents aren't inherited from defaults"},
+		{"global_default_bucket.parent", "Only buckets in namespaces can have parents"},
+		{"namespaces.no_ceiling.buckets.a.parent", "The namespace has no ceiling"},
+		{"namespaces.ns.buckets.a.parent", "Parents cannot form a cycle"},
+		{"namespaces.ns.buckets.b.parent", "Parents cannot form a cycle"},
+		{"namespaces.ns.buckets.c.parent", "Parents cannot form a cycle"},
+		{"namespaces.ns.buckets.d.parent", "Concurrency buckets cannot be parents"},
+		{"namespaces.ns.ceiling.parent", "A namespace's ceiling cannot have a parent"},
+		{"namespaces.ns.default_bucket.parent", "Unknown parent \"missing\""}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateHighPriorityReserve(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`defaults: {high_priority_reserve: 10}
+namespaces:
+  ns:
+    buckets:
+      shared: {size: 100, fifo: true, max_waiters: 5}
+`))
+	checkError(t, e)
+
+	shared := cfg.Namespaces["ns"].Buckets["shared"]
+	if shared.HighPriorityReserve != 10 || !shared.FIFO || shared.MaxWaiters != 5 {
+		t.Fatalf("Unexpected bucket %+v", shared)
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "high_priority_reserve: 10") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting reserves to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  ns:\n    buckets:\n      b: {size: 10, high_priority_reserve: 20}\n"))
+	expected := ValidationErrors{{"namespaces.ns.buckets.b.high_priority_reserve", "Cannot exceed size"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateEviction(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`namespaces:
+  ns:
+    dynamic_bucket_template: {}
+    max_dynamic_buckets: 10
+    eviction: lru
+`))
+	checkError(t, e)
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "eviction: lru") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting eviction policies to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte(`namespaces:
+  a:
+    dynamic_bucket_template: {}
+    max_dynamic_buckets: 10
+    eviction: random
+  b:
+    dynamic_bucket_template: {}
+    eviction: lru
+`))
+	expected := ValidationErrors{
+		{"namespaces.a.eviction", "Unknown eviction policy \"random\""},
+		{"namespaces.b.eviction", "Only applies to namespaces with max_dynamic_buckets"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateCosts(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`defaults: {costs: {heavy_op: 5}}
+namespaces:
+  ns:
+    buckets:
+      inherited: {}
+      priced: {costs: {heavy_op: 10, light_op: 1}}
+`))
+	checkError(t, e)
+
+	buckets := cfg.Namespaces["ns"].Buckets
+	if c := buckets["inherited"].Cost("heavy_op"); c != 5 {
+		t.Fatalf("Expecting costs to be inherited from defaults. Cost %v", c)
+	}
+
+	if c := buckets["priced"].Cost("heavy_op"); c != 10 {
+		t.Fatalf("Expecting buckets' own costs to take precedence. Cost %v", c)
+	}
+
+	if c := buckets["priced"].Cost("other_op"); c != 1 {
+		t.Fatalf("Expecting operations that aren't priced to cost a token. Cost %v", c)
+	}
+
+	if !FromProto(cfg.ToProto()).Namespaces["ns"].Buckets["priced"].Equals(buckets["priced"]) {
+		t.Fatal("Expecting costs to survive conversion to protos.")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "heavy_op: 10") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting costs to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  ns:\n    buckets:\n      b: {costs: {free_op: 0}}\n"))
+	expected := ValidationErrors{{"namespaces.ns.buckets.b.costs.free_op", "Must be positive"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateAdaptive(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`namespaces:
+  ns:
+    buckets:
+      b: {fill_rate: 100, adaptive: {max_error_rate: 0.05, max_latency_millis: 200}}
+`))
+	checkError(t, e)
+
+	a := cfg.Namespaces["ns"].Buckets["b"].Adaptive
+	if a.BackoffPercent != 50 || a.Increase != 10 || a.MinFillRate != 1 {
+		t.Fatalf("Expecting adaptive defaults to apply. Was %+v", a)
+	}
+
+	if a.Healthy(0.1, 0) || a.Healthy(0, 300*time.Millisecond) || !a.Healthy(0.01, 100*time.Millisecond) {
+		t.Fatal("Expecting the downstream to be unhealthy past either threshold")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "max_latency_millis: 200") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting adaptive settings to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte(`namespaces:
+  ns:
+    buckets:
+      b: {algorithm: concurrency, fill_rate: 10, adaptive: {max_error_rate: 2, backoff_percent: 100, min_fill_rate: 20}}
+`))
+	expected := ValidationErrors{
+		{"namespaces.ns.buckets.b.adaptive", "Only applies to token_bucket and gcra buckets"},
+		{"namespaces.ns.buckets.b.adaptive.backoff_percent", "Must be between 0 and 99"},
+		{"namespaces.ns.buckets.b.adaptive.max_error_rate", "Must be between 0 and 1"},
+		{"namespaces.ns.buckets.b.adaptive.min_fill_rate", "Cannot exceed fill_rate"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidatePooled(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`namespaces:
+  ns:
+    ceiling: {size: 500, fill_rate: 500}
+    pooled: true
+    buckets:
+      client: {size: 100, fill_rate: 100}
+`))
+	checkError(t, e)
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "pooled: true") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting pooled namespaces to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  ns:\n    pooled: true\n"))
+	expected := ValidationErrors{{"namespaces.ns.pooled", "Only applies to namespaces with a ceiling"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateGlobalRateCap(t *testing.T) {
+	cfg, e := ParseConfig([]byte("global_rate_cap: 1000\nnamespaces:\n  ns: {}\n"))
+	checkError(t, e)
+
+	if b := cfg.RateCapBucket(); b.Size != 1000 || b.FillRate != 1000 || b.Name != RateCapBucketName {
+		t.Fatalf("Expecting the cap to allow 1000 tokens a second. Was %+v", b)
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "global_rate_cap: 1000") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting the global rate cap to be exported. Exported:\n%s", y)
+	}
+
+	if !cfg.Equals(FromProto(cfg.ToProto())) {
+		t.Fatal("Expecting the global rate cap to survive conversion to protos.")
+	}
+
+	if NewDefaultServiceConfig().RateCapBucket() != nil {
+		t.Fatal("Expecting no cap by default")
+	}
+
+	_, e = ParseConfig([]byte("global_rate_cap: -1\n"))
+	expected := ValidationErrors{{"global_rate_cap", "Cannot be negative"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateInitialFillPercent(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`defaults: {initial_fill_percent: 0}
+namespaces:
+  ns:
+    buckets:
+      inherited: {}
+      half: {initial_fill_percent: 50}
+`))
+	checkError(t, e)
+
+	buckets := cfg.Namespaces["ns"].Buckets
+	if n := buckets["inherited"].InitialTokens(10); n != 0 {
+		t.Fatalf("Expecting buckets to inherit starting empty. Tokens %v", n)
+	}
+
+	if n := buckets["half"].InitialTokens(10); n != 5 {
+		t.Fatalf("Expecting buckets to start half full. Tokens %v", n)
+	}
+
+	if n := NewDefaultBucketConfig().InitialTokens(10); n != 10 {
+		t.Fatalf("Expecting buckets to start full by default. Tokens %v", n)
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "initial_fill_percent: 0") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting initial fill levels to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte(`namespaces:
+  ns:
+    buckets:
+      a: {initial_fill_percent: 150}
+      b: {algorithm: concurrency, initial_fill_percent: 50}
+`))
+	expected := ValidationErrors{
+		{"namespaces.ns.buckets.a.initial_fill_percent", "Must be between 0 and 100"},
+		{"namespaces.ns.buckets.b.initial_fill_percent", "Doesn't apply to concurrency buckets"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateModes(t *testing.T) {
+	cfg, e := ParseConfig([]byte("namespaces:\n  ns:\n    buckets:\n      b:\n        mode: always_deny\n"))
+	checkError(t, e)
+
+	b := cfg.Namespaces["ns"].Buckets["b"]
+	if b.Mode != BucketModeAlwaysDeny {
+		t.Fatalf("Unexpected bucket %+v", b)
+	}
+
+	if !FromProto(cfg.ToProto()).Namespaces["ns"].Buckets["b"].Equals(b) {
+		t.Fatal("Expecting modes to survive conversion to protos.")
+	}
+
+	c := b.Clone()
+	c.Mode = BucketModeNormal
+	if !c.EqualsExceptEnforcement(b) || c.Equals(b) {
+		t.Fatal("Expecting configs differing only in their modes to be equal except for them.")
+	}
+
+	cfg, e = ParseConfig([]byte("namespaces:\n  ns:\n    shadow: true\n    buckets:\n      b: {}\n      c:\n        mode: always_allow\n"))
+	checkError(t, e)
+	if ns := cfg.Namespaces["ns"]; ns.Buckets["b"].EffectiveMode() != BucketModeShadow || ns.Buckets["c"].EffectiveMode() != BucketModeAlwaysAllow {
+		t.Fatal("Expecting namespaces in shadow mode to put only their normal buckets in shadow mode.")
+	}
+
+	if !FromProto(cfg.ToProto()).Namespaces["ns"].Shadow {
+		t.Fatal("Expecting shadow mode to survive conversion to protos.")
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  ns:\n    buckets:\n      b:\n        mode: frozen\n"))
+	expected := ValidationErrors{{"namespaces.ns.buckets.b.mode", "Unknown mode \"frozen\""}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateEnforcementPercent(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`defaults: {enforcement_percent: 0}
+namespaces:
+  ns:
+    buckets:
+      none: {}
+      half: {enforcement_percent: 50}
+      all: {enforcement_percent: 100}
+`))
+	checkError(t, e)
+
+	buckets := cfg.Namespaces["ns"].Buckets
+	for i := 0; i < 100; i++ {
+		caller := fmt.Sprintf("caller-%v", i)
+		if buckets["none"].Enforces(caller) || !buckets["all"].Enforces(caller) {
+			t.Fatalf("Unexpected enforcement on %v", caller)
+		}
+
+		if buckets["half"].Enforces(caller) != buckets["half"].Enforces(caller) {
+			t.Fatalf("Expecting enforcement on %v to be decided consistently", caller)
+		}
+	}
+
+	enforced := 0
+	for i := 0; i < 1000; i++ {
+		if buckets["half"].Enforces(fmt.Sprintf("caller-%v", i)) {
+			enforced++
+		}
+	}
+
+	if enforced < 400 || enforced > 600 {
+		t.Fatalf("Expecting about half of callers to be enforced. Enforced %v", enforced)
+	}
+
+	if !NewDefaultBucketConfig().Enforces("caller") {
+		t.Fatal("Expecting buckets to enforce all requests by default")
+	}
+
+	if !FromProto(cfg.ToProto()).Namespaces["ns"].Buckets["half"].Equals(buckets["half"]) {
+		t.Fatal("Expecting enforcement percents to survive conversion to protos.")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "enforcement_percent: 50") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting enforcement percents to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  ns:\n    buckets:\n      b: {enforcement_percent: 101}\n"))
+	expected := ValidationErrors{{"namespaces.ns.buckets.b.enforcement_percent", "Must be between 0 and 100"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateBackends(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`redis: {addr: "localhost:6379", pool_size: 10}
+namespaces:
+  shared:
+    backend: redis
+    buckets:
+      b: {}
+  local:
+    buckets:
+      b: {}
+`))
+	checkError(t, e)
+
+	if cfg.Redis == nil || cfg.Redis.Addr != "localhost:6379" || cfg.Redis.PoolSize != 10 {
+		t.Fatalf("Unexpected redis settings %+v", cfg.Redis)
+	}
+
+	if b := cfg.Namespaces["shared"].Buckets["b"].Backend(); b != BackendRedis {
+		t.Fatalf("Expecting buckets to be kept in their namespace's backend. Was %v", b)
+	}
+
+	if b := cfg.Namespaces["local"].Buckets["b"].Backend(); b != BackendMemory {
+		t.Fatalf("Expecting buckets to be kept in memory by default. Was %v", b)
+	}
+
+	if c := FromProto(cfg.ToProto()); !c.Equals(cfg) || c.Namespaces["shared"].Backend != BackendRedis || c.Redis.PoolSize != 10 {
+		t.Fatal("Expecting backends to survive conversion to protos.")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "backend: redis") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting backends to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("redis: {pool_size: -1}\nnamespaces:\n  ns:\n    backend: disk\n  shared:\n    backend: redis\n"))
+	expected := ValidationErrors{
+		{"namespaces.ns.backend", "Unknown backend \"disk\""},
+		{"redis.addr", "Missing address"},
+		{"redis.pool_size", "Cannot be negative"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  shared:\n    backend: redis\n"))
+	expected = ValidationErrors{{"namespaces.shared.backend", "Requires the redis settings to be configured"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateTolerance(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`redis: {addr: "localhost:6379"}
+namespaces:
+  shared:
+    backend: redis
+    tolerance: 0.25
+    buckets:
+      b: {}
+`))
+	checkError(t, e)
+
+	if tolerance := cfg.Namespaces["shared"].Buckets["b"].Tolerance(); tolerance != 0.25 {
+		t.Fatalf("Expecting buckets to have their namespace's tolerance. Was %v", tolerance)
+	}
+
+	if c := FromProto(cfg.ToProto()); !c.Equals(cfg) || c.Namespaces["shared"].Tolerance != 0.25 {
+		t.Fatal("Expecting tolerances to survive conversion to protos.")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !strings.Contains(string(y), "tolerance: 0.25") || !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting tolerances to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("redis: {addr: \"localhost:6379\"}\nnamespaces:\n  local:\n    tolerance: 0.1\n  shared:\n    backend: redis\n    tolerance: 2\n"))
+	expected := ValidationErrors{
+		{"namespaces.local.tolerance", "Only applies to namespaces with a shared backend"},
+		{"namespaces.shared.tolerance", "Must be between 0 and 1"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateDynamo(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`dynamo: {region: us-east-1, table: buckets}
+namespaces:
+  shared:
+    backend: dynamo
+`))
+	checkError(t, e)
+
+	if c := FromProto(cfg.ToProto()); c.Dynamo == nil || *c.Dynamo != *cfg.Dynamo {
+		t.Fatal("Expecting dynamo settings to survive conversion to protos.")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting dynamo settings to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("dynamo: {max_attempts: -1}\nnamespaces:\n  shared:\n    backend: dynamo\n"))
+	expected := ValidationErrors{
+		{"dynamo.max_attempts", "Cannot be negative"},
+		{"dynamo.region", "Missing region"},
+		{"dynamo.table", "Missing table"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  shared:\n    backend: dynamo\n"))
+	expected = ValidationErrors{{"namespaces.shared.backend", "Requires the dynamo settings to be configured"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
+
+func TestValidateCassandra(t *testing.T) {
+	cfg, e := ParseConfig([]byte(`cassandra: {hosts: ["c1:9042", "c2:9042"], keyspace: quotas, table: buckets, consistency: local_quorum, mode: lwt}
+namespaces:
+  shared:
+    backend: cassandra
+`))
+	checkError(t, e)
+
+	if c := FromProto(cfg.ToProto()); c.Cassandra == nil || !reflect.DeepEqual(c.Cassandra, cfg.Cassandra) {
+		t.Fatal("Expecting cassandra settings to survive conversion to protos.")
+	}
+
+	y, e := cfg.ToYAML()
+	checkError(t, e)
+	if !cfg.Equals(readConfigFromBytes(y)) {
+		t.Fatalf("Expecting cassandra settings to be exported. Exported:\n%s", y)
+	}
+
+	_, e = ParseConfig([]byte("cassandra: {consistency: any, mode: batch, max_attempts: -1}\nnamespaces:\n  shared:\n    backend: cassandra\n"))
+	expected := ValidationErrors{
+		{"cassandra.consistency", `Unknown consistency "any"`},
+		{"cassandra.hosts", "Missing hosts"},
+		{"cassandra.keyspace", "Missing keyspace"},
+		{"cassandra.max_attempts", "Cannot be negative"},
+		{"cassandra.mode", `Unknown mode "batch"`},
+		{"cassandra.table", "Missing table"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+
+	_, e = ParseConfig([]byte("namespaces:\n  shared:\n    backend: cassandra\n"))
+	expected = ValidationErrors{{"namespaces.shared.backend", "Requires the cassandra settings to be configured"}}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("Expecting errors %v, got %v", expected, e)
+	}
+}
@@ -0,0 +1,171 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// ConfigFileWatcher watches a config file, parsing and publishing its contents whenever they
+// change. The file's directory is watched rather than the file itself, so that files replaced by
+// renaming another over them, as editors and Kubernetes' ConfigMap volumes do, are still watched
+// afterwards. Only the file itself is checked; changes to files it includes are picked up the next
+// time it changes.
+type ConfigFileWatcher struct {
+	filename     string
+	lastModTime  time.Time
+	lastContents []byte
+	configs      chan *ServiceConfig
+	closer       chan struct{}
+}
+
+// NewConfigFileWatcher creates a ConfigFileWatcher that checks filename for changes whenever
+// anything in its directory changes, or every freq if the directory can't be watched. The file's
+// current contents are treated as already applied.
+func NewConfigFileWatcher(filename string, freq time.Duration) *ConfigFileWatcher {
+	w := &ConfigFileWatcher{
+		filename: filename,
+		configs:  make(chan *ServiceConfig, 1),
+		closer:   make(chan struct{})}
+
+	if fi, e := os.Stat(filename); e == nil {
+		w.lastModTime = fi.ModTime()
+	}
+	w.lastContents, _ = ioutil.ReadFile(filename)
+
+	if fw, e := watchDir(filepath.Dir(filename)); e == nil {
+		go w.watch(fw)
+	} else {
+		logging.Warnf("Unable to watch config file %v; polling it every %v instead. Error: %v", filename, freq, e)
+		go w.poll(freq)
+	}
+
+	return w
+}
+
+func watchDir(dir string) (*fsnotify.Watcher, error) {
+	fw, e := fsnotify.NewWatcher()
+	if e != nil {
+		return nil, e
+	}
+
+	if e := fw.Add(dir); e != nil {
+		fw.Close()
+		return nil, e
+	}
+
+	return fw, nil
+}
+
+// Configs returns a channel on which new configs are published whenever the file changes. Files
+// that cannot be read or parsed are logged and skipped.
+func (w *ConfigFileWatcher) Configs() <-chan *ServiceConfig {
+	return w.configs
+}
+
+// Stop stops watching the file, closing the channel returned by Configs().
+func (w *ConfigFileWatcher) Stop() {
+	close(w.closer)
+}
+
+func (w *ConfigFileWatcher) watch(fw *fsnotify.Watcher) {
+	defer fw.Close()
+	defer close(w.configs)
+
+	for {
+		select {
+		case _, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+
+			if !w.publishChanges() {
+				return
+			}
+		case e, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+
+			logging.Warnf("Error watching config file %v: %v", w.filename, e)
+		case <-w.closer:
+			return
+		}
+	}
+}
+
+func (w *ConfigFileWatcher) poll(freq time.Duration) {
+	t := time.NewTicker(freq)
+	defer t.Stop()
+	defer close(w.configs)
+
+	for {
+		select {
+		case <-t.C:
+			if !w.publishChanges() {
+				return
+			}
+		case <-w.closer:
+			return
+		}
+	}
+}
+
+// publishChanges publishes the file's config if it has changed, returning false if the watcher is
+// stopped first.
+func (w *ConfigFileWatcher) publishChanges() bool {
+	cfg := w.checkForChanges()
+	if cfg == nil {
+		return true
+	}
+
+	select {
+	case w.configs <- cfg:
+		return true
+	case <-w.closer:
+		return false
+	}
+}
+
+func (w *ConfigFileWatcher) checkForChanges() (cfg *ServiceConfig) {
+	fi, e := os.Stat(w.filename)
+	if e != nil {
+		logging.Warnf("Unable to stat config file %v. Error: %v", w.filename, e)
+		return nil
+	}
+
+	if fi.ModTime().Equal(w.lastModTime) && int64(len(w.lastContents)) == fi.Size() {
+		return nil
+	}
+	w.lastModTime = fi.ModTime()
+
+	contents, e := ioutil.ReadFile(w.filename)
+	if e != nil {
+		logging.Warnf("Unable to read config file %v. Error: %v", w.filename, e)
+		return nil
+	}
+
+	if bytes.Equal(contents, w.lastContents) {
+		return nil
+	}
+	w.lastContents = contents
+
+	defer func() {
+		// Invalid configs panic when defaults are applied.
+		if r := recover(); r != nil {
+			logging.Warnf("Ignoring invalid config file %v. Error: %v", w.filename, r)
+			cfg = nil
+		}
+	}()
+
+	logging.Printf("Config file %v changed.", w.filename)
+	return parseConfigFile(w.filename, contents, false)
+}
@@ -0,0 +1,100 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigFileWatcher(t *testing.T) {
+	f, e := ioutil.TempFile("", "qs_test_watcher")
+	checkError(t, e)
+	defer os.Remove(f.Name())
+	f.WriteString(cfgYaml)
+	f.Close()
+
+	w := NewConfigFileWatcher(f.Name(), 10*time.Millisecond)
+	defer w.Stop()
+
+	// Unchanged files aren't published.
+	select {
+	case cfg := <-w.Configs():
+		t.Fatalf("Not expecting a config; got %+v", cfg)
+	case <-time.After(50 * time.Millisecond):
+		// This is good.
+	}
+
+	// Invalid configs are skipped.
+	replaceFile(t, f.Name(), `namespaces:
+  invalid:
+    default_bucket:
+      size: 10
+    dynamic_bucket_template:
+      size: 10
+`)
+
+	select {
+	case cfg := <-w.Configs():
+		t.Fatalf("Not expecting a config; got %+v", cfg)
+	case <-time.After(50 * time.Millisecond):
+		// This is good.
+	}
+
+	replaceFile(t, f.Name(), `namespaces:
+  changed:
+    default_bucket:
+      fill_rate: 123
+`)
+
+	select {
+	case cfg := <-w.Configs():
+		if cfg.Namespaces["changed"] == nil || cfg.Namespaces["changed"].DefaultBucket.FillRate != 123 {
+			t.Fatalf("Unexpected config %+v", cfg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expecting a config after the file changed.")
+	}
+}
+
+func TestConfigFileWatcherWatchesDirectory(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_watcher")
+	checkError(t, e)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "quotaservice.yaml")
+	checkError(t, ioutil.WriteFile(filename, []byte(cfgYaml), 0644))
+
+	// Changes are noticed long before the file would next be polled.
+	w := NewConfigFileWatcher(filename, time.Hour)
+	defer w.Stop()
+
+	for i := 0; i < 2; i++ {
+		replaceFile(t, filename, fmt.Sprintf(`namespaces:
+  changed:
+    default_bucket:
+      fill_rate: %v
+`, 100+i))
+
+		select {
+		case cfg := <-w.Configs():
+			if cfg.Namespaces["changed"].DefaultBucket.FillRate != int64(100+i) {
+				t.Fatalf("Unexpected config %+v", cfg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expecting a config after the file was replaced.")
+		}
+	}
+}
+
+// replaceFile atomically replaces the contents of filename, so the watcher never sees a partially
+// written file.
+func replaceFile(t *testing.T, filename, contents string) {
+	tmp := filename + ".tmp"
+	checkError(t, ioutil.WriteFile(tmp, []byte(contents), 0644))
+	checkError(t, os.Rename(tmp, filename))
+}
@@ -9,7 +9,7 @@ import (
 	"errors"
 )
 
-// ErrorReason provides details on why calls to Allow may fail.
+// ErrorReason provides details on why calls to the QuotaService may fail.
 type ErrorReason int
 
 const (
@@ -24,6 +24,24 @@ const (
 
 	// Too many tokens requested
 	ER_TOO_MANY_TOKENS_REQUESTED
+
+	// No such lease, or it has expired
+	ER_NO_LEASE
+
+	// No such reservation, or it has expired
+	ER_NO_RESERVATION
+
+	// Bucket can't take tokens back, so can't hold reservations
+	ER_NOT_RESERVABLE
+
+	// Too many callers already waiting on the bucket
+	ER_TOO_MANY_WAITERS
+
+	// Bucket doesn't adapt its fill rate
+	ER_NOT_ADAPTIVE
+
+	// Bucket is set to deny all requests
+	ER_DENIED
 )
 
 type QuotaServiceError struct {
@@ -4,9 +4,11 @@
 package quotaservice
 
 import (
+	"sync/atomic"
 	"time"
 
 	"fmt"
+	"github.com/maniksurtani/quotaservice/config"
 	"github.com/maniksurtani/quotaservice/logging"
 )
 
@@ -19,6 +21,10 @@ const (
 	EVENT_BUCKET_MISS
 	EVENT_BUCKET_CREATED
 	EVENT_BUCKET_REMOVED
+	EVENT_BUCKET_EXHAUSTED
+	EVENT_BUCKET_RECOVERED
+	EVENT_SHADOW_REFUSED
+	EVENT_CONFIG_CHANGED
 )
 
 var eventNames = []string{
@@ -27,7 +33,11 @@ var eventNames = []string{
 	EVENT_TOO_MANY_TOKENS_REQUESTED: "EVENT_TOO_MANY_TOKENS_REQUESTED",
 	EVENT_BUCKET_MISS:               "EVENT_BUCKET_MISS",
 	EVENT_BUCKET_CREATED:            "EVENT_BUCKET_CREATED",
-	EVENT_BUCKET_REMOVED:            "EVENT_BUCKET_REMOVED"}
+	EVENT_BUCKET_REMOVED:            "EVENT_BUCKET_REMOVED",
+	EVENT_BUCKET_EXHAUSTED:          "EVENT_BUCKET_EXHAUSTED",
+	EVENT_BUCKET_RECOVERED:          "EVENT_BUCKET_RECOVERED",
+	EVENT_SHADOW_REFUSED:            "EVENT_SHADOW_REFUSED",
+	EVENT_CONFIG_CHANGED:            "EVENT_CONFIG_CHANGED"}
 
 func (et EventType) String() string {
 	name := eventNames[et]
@@ -45,12 +55,29 @@ type Event interface {
 	Dynamic() bool
 	NumTokens() int64
 	WaitTime() time.Duration
+	// Labels returns the labels of the bucket and its namespace, or nil if the bucket doesn't exist.
+	Labels() map[string]string
+	// Caller returns who requested the tokens the event is about, or the zero Caller if the event
+	// isn't about a request.
+	Caller() Caller
 }
 
-// EventProducer is a hook into the notification system, to inform listeners that certain events
-// take place.
+// ConfigChangedEvent is the Event emitted as EVENT_CONFIG_CHANGED, once a new version of the
+// config is applied. Its namespace and bucket name are empty, and its Caller is who made the
+// change, if known.
+type ConfigChangedEvent interface {
+	Event
+	ConfigVersion() int
+}
+
+// EventProducer is a hook into the notification system, to inform a listener that certain events
+// take place. Events are buffered for the listener, which is notified in a goroutine of its own, so
+// that slow listeners neither hold up requests nor other listeners; events emitted while the
+// buffer is full are dropped, and counted.
 type EventProducer struct {
-	c chan Event
+	c        chan Event
+	listener Listener
+	dropped  int64
 }
 
 func (e *EventProducer) Emit(event Event) {
@@ -58,34 +85,38 @@ func (e *EventProducer) Emit(event Event) {
 	case e.c <- event:
 	// OK
 	default:
-		logging.Println("Event buffer full; dropping event.")
+		if atomic.AddInt64(&e.dropped, 1) == 1 {
+			logging.Warn("Event buffer full; dropping events.")
+		}
 	}
 }
 
-func (ep *EventProducer) notifyListeners(l Listener) {
+// Dropped returns how many events have been dropped because the listener's buffer was full.
+func (e *EventProducer) Dropped() int64 {
+	return atomic.LoadInt64(&e.dropped)
+}
+
+func (ep *EventProducer) notifyListeners() {
 	for event := range ep.c {
-		l(event)
+		ep.listener(event)
 	}
 }
 
 type Listener func(details Event)
 
-func registerListener(listener Listener, bufsize int) *EventProducer {
+func newEventProducer(listener Listener, bufsize int) *EventProducer {
 	if listener == nil {
 		panic("Cannot register a nil listener")
 	}
 
-	ep := &EventProducer{make(chan Event, bufsize)}
-
-	go ep.notifyListeners(listener)
-
-	return ep
+	return &EventProducer{c: make(chan Event, bufsize), listener: listener}
 }
 
 type namedEvent struct {
 	eventType             EventType
 	namespace, bucketName string
 	dynamic               bool
+	cfg                   *config.BucketConfig
 }
 
 func (n *namedEvent) String() string {
@@ -117,9 +148,22 @@ func (n *namedEvent) WaitTime() time.Duration {
 	return 0
 }
 
+func (n *namedEvent) Caller() Caller {
+	return Caller{}
+}
+
+func (n *namedEvent) Labels() map[string]string {
+	if n.cfg == nil {
+		return nil
+	}
+
+	return n.cfg.AllLabels()
+}
+
 type tokenEvent struct {
 	*namedEvent
 	numTokens int64
+	caller    Caller
 }
 
 func (t *tokenEvent) String() string {
@@ -131,6 +175,10 @@ func (t *tokenEvent) NumTokens() int64 {
 	return t.numTokens
 }
 
+func (t *tokenEvent) Caller() Caller {
+	return t.caller
+}
+
 type tokenWaitEvent struct {
 	*tokenEvent
 	waitTime time.Duration
@@ -145,42 +193,88 @@ func (t *tokenWaitEvent) WaitTime() time.Duration {
 	return t.waitTime
 }
 
-func newTokensServedEvent(namespace, bucketName string, dynamic bool, numTokens int64, waitTime time.Duration) Event {
+func newTokensServedEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig, numTokens int64, caller Caller, waitTime time.Duration) Event {
 	return &tokenWaitEvent{
 		tokenEvent: &tokenEvent{
-			namedEvent: newNamedEvent(namespace, bucketName, dynamic, EVENT_TOKENS_SERVED),
-			numTokens:  numTokens},
+			namedEvent: newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_TOKENS_SERVED),
+			numTokens:  numTokens,
+			caller:     caller},
 		waitTime: waitTime}
 }
 
-func newTimedOutEvent(namespace, bucketName string, dynamic bool, numTokens int64) Event {
+func newTimedOutEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig, numTokens int64, caller Caller) Event {
+	return &tokenEvent{
+		namedEvent: newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_TIMEOUT_SERVING_TOKENS),
+		numTokens:  numTokens,
+		caller:     caller}
+}
+
+// newShadowRefusedEvent reports a request that a bucket in shadow mode would have refused, but
+// granted.
+func newShadowRefusedEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig, numTokens int64, caller Caller) Event {
 	return &tokenEvent{
-		namedEvent: newNamedEvent(namespace, bucketName, dynamic, EVENT_TIMEOUT_SERVING_TOKENS),
-		numTokens:  numTokens}
+		namedEvent: newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_SHADOW_REFUSED),
+		numTokens:  numTokens,
+		caller:     caller}
 }
 
-func newTooManyTokensRequestedEvent(namespace, bucketName string, dynamic bool, numTokens int64) Event {
+func newTooManyTokensRequestedEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig, numTokens int64, caller Caller) Event {
 	return &tokenEvent{
-		namedEvent: newNamedEvent(namespace, bucketName, dynamic, EVENT_TOO_MANY_TOKENS_REQUESTED),
-		numTokens:  numTokens}
+		namedEvent: newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_TOO_MANY_TOKENS_REQUESTED),
+		numTokens:  numTokens,
+		caller:     caller}
+}
+
+func newBucketMissedEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig) Event {
+	return newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_BUCKET_MISS)
+}
+
+func newBucketCreatedEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig) Event {
+	return newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_BUCKET_CREATED)
+}
+
+func newBucketRemovedEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig) Event {
+	return newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_BUCKET_REMOVED)
+}
+
+func newBucketExhaustedEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig) Event {
+	return newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_BUCKET_EXHAUSTED)
+}
+
+func newBucketRecoveredEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig) Event {
+	return newNamedEvent(namespace, bucketName, dynamic, cfg, EVENT_BUCKET_RECOVERED)
+}
+
+type configChangedEvent struct {
+	*namedEvent
+	version int
+	caller  Caller
+}
+
+func (c *configChangedEvent) String() string {
+	return fmt.Sprintf("configChangedEvent{type: %v, version: %v, user: %v}", c.eventType, c.version, c.caller.ID)
 }
 
-func newBucketMissedEvent(namespace, bucketName string, dynamic bool) Event {
-	return newNamedEvent(namespace, bucketName, dynamic, EVENT_BUCKET_MISS)
+func (c *configChangedEvent) ConfigVersion() int {
+	return c.version
 }
 
-func newBucketCreatedEvent(namespace, bucketName string, dynamic bool) Event {
-	return newNamedEvent(namespace, bucketName, dynamic, EVENT_BUCKET_CREATED)
+func (c *configChangedEvent) Caller() Caller {
+	return c.caller
 }
 
-func newBucketRemovedEvent(namespace, bucketName string, dynamic bool) Event {
-	return newNamedEvent(namespace, bucketName, dynamic, EVENT_BUCKET_REMOVED)
+func newConfigChangedEvent(version int, user string) Event {
+	return &configChangedEvent{
+		namedEvent: newNamedEvent("", "", false, nil, EVENT_CONFIG_CHANGED),
+		version:    version,
+		caller:     Caller{ID: user}}
 }
 
-func newNamedEvent(namespace, bucketName string, dynamic bool, eventType EventType) *namedEvent {
+func newNamedEvent(namespace, bucketName string, dynamic bool, cfg *config.BucketConfig, eventType EventType) *namedEvent {
 	return &namedEvent{
 		eventType:  eventType,
 		namespace:  namespace,
 		bucketName: bucketName,
-		dynamic:    dynamic}
+		dynamic:    dynamic,
+		cfg:        cfg}
 }
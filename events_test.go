@@ -4,7 +4,9 @@
 package quotaservice
 
 import (
+	"context"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -46,7 +48,9 @@ func setUp() {
 	ns = config.NewDefaultNamespaceConfig()
 	b := config.NewDefaultBucketConfig()
 	b.MaxTokensPerRequest = 10
+	b.Labels = map[string]string{"owner": "bucket-owner"}
 	ns.AddBucket("b", b)
+	ns.Labels = map[string]string{"owner": "namespace-owner", "team": "quota"}
 	cfg.AddNamespace("nodyn", ns)
 
 	mbf = &MockBucketFactory{}
@@ -67,25 +71,62 @@ func TestTokens(t *testing.T) {
 	checkEvent("nodyn", "b", false, EVENT_TOKENS_SERVED, 1, 0, <-events, t)
 }
 
+func TestEventCaller(t *testing.T) {
+	caller := Caller{ID: "u1", Labels: map[string]string{"service": "billing"}}
+	qs.Lease(context.Background(), "nodyn", "b", 1, 0, 0, PRIORITY_NORMAL, caller)
+	e := <-events
+	checkEvent("nodyn", "b", false, EVENT_TOKENS_SERVED, 1, 0, e, t)
+	if !reflect.DeepEqual(e.Caller(), caller) {
+		t.Fatalf("Expecting the event to carry caller %+v, got %+v", caller, e.Caller())
+	}
+
+	qs.Lease(context.Background(), "nodyn", "b", 100, 0, 0, PRIORITY_NORMAL, caller)
+	if e = <-events; !reflect.DeepEqual(e.Caller(), caller) {
+		t.Fatalf("Expecting refusals to carry caller %+v, got %+v", caller, e.Caller())
+	}
+
+	qs.Allow("nodyn", "b", 1, 0)
+	if e = <-events; !reflect.DeepEqual(e.Caller(), Caller{}) {
+		t.Fatalf("Expecting requests without a caller to carry none, got %+v", e.Caller())
+	}
+}
+
 func TestTooManyTokens(t *testing.T) {
 	qs.Allow("nodyn", "b", 100, 0)
 	checkEvent("nodyn", "b", false, EVENT_TOO_MANY_TOKENS_REQUESTED, 100, 0, <-events, t)
 }
 
 func TestTimeout(t *testing.T) {
-	mbf.SetWaitTime("nodyn", "b", 2 * time.Minute)
+	mbf.SetWaitTime("nodyn", "b", 2*time.Minute)
 	qs.Allow("nodyn", "b", 1, 1)
 	checkEvent("nodyn", "b", false, EVENT_TIMEOUT_SERVING_TOKENS, 1, 0, <-events, t)
+	checkEvent("nodyn", "b", false, EVENT_BUCKET_EXHAUSTED, 0, 0, <-events, t)
 	mbf.SetWaitTime("nodyn", "b", 0)
 }
 
 func TestWithWait(t *testing.T) {
-	mbf.SetWaitTime("nodyn", "b", 2 * time.Nanosecond)
+	mbf.SetWaitTime("nodyn", "b", 2*time.Nanosecond)
 	qs.Allow("nodyn", "b", 1, 10)
-	checkEvent("nodyn", "b", false, EVENT_TOKENS_SERVED, 1, 2 * time.Nanosecond, <-events, t)
+	checkEvent("nodyn", "b", false, EVENT_TOKENS_SERVED, 1, 2*time.Nanosecond, <-events, t)
 	mbf.SetWaitTime("nodyn", "b", 0)
 }
 
+func TestLabels(t *testing.T) {
+	qs.Allow("nodyn", "b", 1, 0)
+	e := <-events
+	checkEvent("nodyn", "b", false, EVENT_TOKENS_SERVED, 1, 0, e, t)
+
+	labels := e.Labels()
+	if len(labels) != 2 || labels["owner"] != "bucket-owner" || labels["team"] != "quota" {
+		t.Fatalf("Expecting bucket and namespace labels. Was %v", labels)
+	}
+
+	qs.Allow("nodyn", "x", 1, 0)
+	if e = <-events; e.Labels() != nil {
+		t.Fatalf("Expecting no labels for a missing bucket. Was %v", e.Labels())
+	}
+}
+
 func TestNoSuchBucket(t *testing.T) {
 	qs.Allow("nodyn", "x", 1, 0)
 	checkEvent("nodyn", "x", false, EVENT_BUCKET_MISS, 0, 0, <-events, t)
@@ -122,6 +163,73 @@ func TestBucketRemoval(t *testing.T) {
 	}
 }
 
+func TestListeners(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("n", ns)
+
+	me := &MockEndpoint{}
+	s := New(cfg, &MockBucketFactory{}, me)
+	metrics := make(chan Event, 100)
+	s.AddListener(func(e Event) { metrics <- e }, 100)
+	unblock := make(chan struct{})
+	slow := s.AddListener(func(e Event) { <-unblock }, 1)
+	s.Start()
+	defer s.Stop()
+	defer close(unblock)
+
+	for i := 0; i < 5; i++ {
+		me.QuotaService.Allow("n", "b", 1, 0)
+	}
+
+	// The bucket's creation, then the tokens served.
+	checkEvent("n", "b", false, EVENT_BUCKET_CREATED, 0, 0, <-metrics, t)
+	for i := 0; i < 5; i++ {
+		select {
+		case e := <-metrics:
+			checkEvent("n", "b", false, EVENT_TOKENS_SERVED, 1, 0, e, t)
+		case <-time.After(time.Second):
+			t.Fatalf("Expecting each listener to be notified despite others being slow")
+		}
+	}
+
+	// The slow listener is notified of one of the 6 events, and buffers another.
+	if slow.Dropped() < 4 {
+		t.Fatalf("Expecting events the slow listener had no room for to be dropped. Dropped %v", slow.Dropped())
+	}
+}
+
+func TestConfigChanged(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	cfg.AddNamespace("n", config.NewDefaultNamespaceConfig())
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	events := make(chan Event, 100)
+	s.AddListener(func(e Event) { events <- e }, 100)
+	s.Start()
+	defer s.Stop()
+
+	if e := s.(*server).AddBucket("n", config.NewDefaultBucketConfig().ToProto(), "alice"); e != nil {
+		t.Fatal(e)
+	}
+
+	for {
+		select {
+		case e := <-events:
+			if e.EventType() != EVENT_CONFIG_CHANGED {
+				continue
+			}
+
+			if c, ok := e.(ConfigChangedEvent); !ok || c.ConfigVersion() != 1 || c.Caller().ID != "alice" {
+				t.Fatalf("Expecting version 1, changed by alice. Event %+v", e)
+			}
+			return
+		case <-time.After(time.Second):
+			t.Fatalf("Expecting %v", EVENT_CONFIG_CHANGED)
+		}
+	}
+}
+
 func checkEvent(namespace, name string, dyn bool, eventType EventType, tokens int64, waitTime time.Duration, actual Event, t *testing.T) {
 	if actual == nil {
 		t.Fatalf("Expecting event; was nil.")
@@ -0,0 +1,81 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"time"
+
+	"github.com/maniksurtani/quotaservice/lifecycle"
+)
+
+// exhaustionDebounce is how long an exhausted bucket must go without refusing requests before it's
+// considered recovered, so that buckets hovering at their limit don't flap between the two.
+const exhaustionDebounce = 10 * time.Second
+
+func (s *server) OnExhausted(callback Listener) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot add exhaustion callback after server has started!")
+	}
+	s.onExhausted = append(s.onExhausted, callback)
+}
+
+func (s *server) OnRecovered(callback Listener) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot add recovery callback after server has started!")
+	}
+	s.onRecovered = append(s.onRecovered, callback)
+}
+
+// refused records that a bucket, known to callers as name, refused a request for want of tokens,
+// notifying listeners and callbacks if that has exhausted it.
+func (s *server) refused(namespace, name string, b *expirableBucket) {
+	if b.refused() {
+		s.notifyExhaustion(newBucketExhaustedEvent(namespace, name, b.Dynamic(), b.Config()), s.onExhausted)
+	}
+}
+
+// served records that a bucket, known to callers as name, granted a request, notifying listeners
+// and callbacks if that has recovered it from exhaustion.
+func (s *server) served(namespace, name string, b *expirableBucket) {
+	if b.served() {
+		s.notifyExhaustion(newBucketRecoveredEvent(namespace, name, b.Dynamic(), b.Config()), s.onRecovered)
+	}
+}
+
+// notifyExhaustion emits an exhaustion or recovery event, and calls callbacks with it, each in its
+// own goroutine so that they can't hold up requests.
+func (s *server) notifyExhaustion(event Event, callbacks []Listener) {
+	s.Emit(event)
+	for _, callback := range callbacks {
+		go callback(event)
+	}
+}
+
+// refused records that the bucket refused a request, telling whether it has just become exhausted.
+func (e *expirableBucket) refused() bool {
+	e.exhaustionLock.Lock()
+	defer e.exhaustionLock.Unlock()
+
+	e.lastRefused = e.clock.Now()
+	if e.exhausted {
+		return false
+	}
+
+	e.exhausted = true
+	return true
+}
+
+// served records that the bucket granted a request, telling whether it has just recovered: it was
+// exhausted, but hasn't refused a request for exhaustionDebounce.
+func (e *expirableBucket) served() bool {
+	e.exhaustionLock.Lock()
+	defer e.exhaustionLock.Unlock()
+
+	if !e.exhausted || e.clock.Now().Sub(e.lastRefused) < exhaustionDebounce {
+		return false
+	}
+
+	e.exhausted = false
+	return true
+}
@@ -0,0 +1,346 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/lifecycle"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// GrantRecord is an entry in a Journal: tokens a caller was granted, or denied.
+type GrantRecord struct {
+	// When the request was served, in Unix millis.
+	TimeMillis int64 `json:"time_millis"`
+	// Fully qualified name of the bucket tokens were requested from.
+	Bucket string `json:"bucket"`
+	Tokens int64  `json:"tokens"`
+	Caller string `json:"caller,omitempty"`
+	// Labels of the caller, such as the name of its service.
+	CallerLabels map[string]string `json:"caller_labels,omitempty"`
+	Granted      bool              `json:"granted"`
+	WaitMillis   int64             `json:"wait_millis,omitempty"`
+	// Why tokens were denied.
+	Error string `json:"error,omitempty"`
+}
+
+// Journal records the tokens granted and denied by a server, in the order requests are served, such
+// as for billing reconciliation or post-incident analysis.
+type Journal interface {
+	// Append records r after every record appended before it, returning once it's recorded, or with
+	// the error recording it.
+	Append(r *GrantRecord) error
+}
+
+// FileJournal is a Journal appending records to a file, a line of JSON each. Append returns once
+// its record has been written and synced to the file, so a grant is on disk before the server
+// responds to it. Records appended at the same time are written together by a goroutine of the
+// journal's own, syncing the file once for all of them. Once the file reaches its max size it's
+// rotated: renamed with the suffix .1, with files rotated before it moving on to .2, .3 and so on.
+type FileJournal struct {
+	lock     sync.RWMutex // Guards closed, against records being appended as the journal is closed
+	closed   bool
+	records  chan *journalWrite
+	done     chan struct{} // Closed once every record appended has been written
+	location string
+	maxBytes int64
+	maxFiles int
+	f        *os.File
+	w        *bufio.Writer
+	size     int64
+}
+
+// journalWrite is a record waiting to be written, and how writing it went.
+type journalWrite struct {
+	b       []byte
+	written chan error
+}
+
+// journalBacklog is how many records may wait to be written, and so be written together, before
+// appending more blocks.
+const journalBacklog = 8192
+
+// NewFileJournal creates a FileJournal appending to the file at location, rotating it once it holds
+// maxBytes, and keeping up to maxFiles rotated files. maxBytes <= 0 never rotates the file, and
+// maxFiles <= 0 keeps every rotated file.
+func NewFileJournal(location string, maxBytes int64, maxFiles int) (*FileJournal, error) {
+	j := &FileJournal{
+		records:  make(chan *journalWrite, journalBacklog),
+		done:     make(chan struct{}),
+		location: location,
+		maxBytes: maxBytes,
+		maxFiles: maxFiles}
+	if e := j.open(); e != nil {
+		return nil, e
+	}
+
+	go j.writeRecords()
+	return j, nil
+}
+
+func (j *FileJournal) open() error {
+	f, e := os.OpenFile(j.location, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if e != nil {
+		return e
+	}
+
+	info, e := f.Stat()
+	if e != nil {
+		f.Close()
+		return e
+	}
+
+	j.f = f
+	j.w = bufio.NewWriter(f)
+	j.size = info.Size()
+	return nil
+}
+
+// Append writes the record to the file, returning once it's synced, or with the error writing it.
+func (j *FileJournal) Append(r *GrantRecord) error {
+	b, e := json.Marshal(r)
+	if e != nil {
+		return e
+	}
+	b = append(b, '\n')
+
+	w := &journalWrite{b: b, written: make(chan error, 1)}
+	j.lock.RLock()
+	if j.closed {
+		j.lock.RUnlock()
+		return fmt.Errorf("Journal %v is closed", j.location)
+	}
+	j.records <- w
+	j.lock.RUnlock()
+
+	return <-w.written
+}
+
+// writeRecords writes records as they're appended until the journal is closed. Records appended
+// while a batch is being written make up the next batch.
+func (j *FileJournal) writeRecords() {
+	defer close(j.done)
+
+	for {
+		w, ok := <-j.records
+		if !ok {
+			return
+		}
+
+		batch := []*journalWrite{w}
+	batching:
+		for len(batch) < journalBacklog {
+			select {
+			case w, ok := <-j.records:
+				if !ok {
+					break batching
+				}
+				batch = append(batch, w)
+			default:
+				break batching
+			}
+		}
+
+		j.writeBatch(batch)
+	}
+}
+
+// writeBatch writes and syncs the records, telling each how it went.
+func (j *FileJournal) writeBatch(batch []*journalWrite) {
+	errs := make([]error, len(batch))
+	for i, w := range batch {
+		errs[i] = j.write(w.b)
+	}
+
+	e := j.sync()
+	for i, w := range batch {
+		if errs[i] == nil {
+			errs[i] = e
+		}
+		w.written <- errs[i]
+	}
+}
+
+// sync flushes records buffered for the file, and syncs it.
+func (j *FileJournal) sync() error {
+	if j.f == nil {
+		return fmt.Errorf("Journal %v has no file open", j.location)
+	}
+
+	if e := j.w.Flush(); e != nil {
+		return e
+	}
+
+	return j.f.Sync()
+}
+
+// write writes the record, rotating the file first if the record would take it past its max size.
+func (j *FileJournal) write(b []byte) error {
+	if j.f == nil {
+		// The file couldn't be reopened when it was last rotated.
+		if e := j.open(); e != nil {
+			return e
+		}
+	}
+
+	if j.maxBytes > 0 && j.size > 0 && j.size+int64(len(b)) > j.maxBytes {
+		if e := j.rotate(); e != nil {
+			return e
+		}
+	}
+
+	n, e := j.w.Write(b)
+	j.size += int64(n)
+	return e
+}
+
+// rotate moves the file and those rotated before it on a suffix, dropping those beyond the max
+// files, and opens a new file. Only called by the journal's writer.
+func (j *FileJournal) rotate() error {
+	if e := j.closeFile(); e != nil {
+		return e
+	}
+
+	n := rotatedFiles(j.location)
+	for ; j.maxFiles > 0 && n >= j.maxFiles; n-- {
+		if e := os.Remove(rotatedFile(j.location, n)); e != nil {
+			return e
+		}
+	}
+
+	for ; n > 0; n-- {
+		if e := os.Rename(rotatedFile(j.location, n), rotatedFile(j.location, n+1)); e != nil {
+			return e
+		}
+	}
+
+	if e := os.Rename(j.location, rotatedFile(j.location, 1)); e != nil {
+		return e
+	}
+
+	return j.open()
+}
+
+// closeFile syncs records buffered for the file, and closes it.
+func (j *FileJournal) closeFile() error {
+	if j.f == nil {
+		return nil
+	}
+
+	e := j.sync()
+	if ce := j.f.Close(); e == nil {
+		e = ce
+	}
+
+	j.f, j.w = nil, nil
+	return e
+}
+
+// Close writes every record appended so far to the file, and closes it. Records can't be appended
+// once the journal is closed.
+func (j *FileJournal) Close() error {
+	j.lock.Lock()
+	if j.closed {
+		j.lock.Unlock()
+		return nil
+	}
+	j.closed = true
+	close(j.records)
+	j.lock.Unlock()
+
+	<-j.done
+	return j.closeFile()
+}
+
+func rotatedFile(location string, n int) string {
+	return fmt.Sprintf("%v.%v", location, n)
+}
+
+// rotatedFiles returns how many rotated files there are of the journal at location.
+func rotatedFiles(location string) (n int) {
+	for {
+		if _, e := os.Stat(rotatedFile(location, n+1)); e != nil {
+			return
+		}
+		n++
+	}
+}
+
+// ReplayJournal calls fn with every record in the FileJournal at location, including its rotated
+// files, oldest first. Stops at the first error fn returns, returning it.
+func ReplayJournal(location string, fn func(r *GrantRecord) error) error {
+	for n := rotatedFiles(location); n >= 0; n-- {
+		file := location
+		if n > 0 {
+			file = rotatedFile(location, n)
+		}
+
+		if e := replayFile(file, fn); e != nil && !os.IsNotExist(e) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func replayFile(file string, fn func(r *GrantRecord) error) error {
+	f, e := os.Open(file)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r := &GrantRecord{}
+		if e := json.Unmarshal(scanner.Bytes(), r); e != nil {
+			return fmt.Errorf("Unable to read record in %v: %v", file, e)
+		}
+
+		if e := fn(r); e != nil {
+			return e
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *server) JournalGrants(j Journal) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot journal grants after server has started!")
+	}
+
+	s.journal = j
+}
+
+// journalGrant records the outcome of a request by caller for tokens from a bucket, if the server
+// keeps a journal.
+func (s *server) journalGrant(namespace, name string, tokens int64, caller Caller, w time.Duration, e error) {
+	if s.journal == nil {
+		return
+	}
+
+	r := &GrantRecord{
+		TimeMillis:   s.clock.Now().UnixNano() / 1e6,
+		Bucket:       config.FullyQualifiedName(namespace, name),
+		Tokens:       tokens,
+		Caller:       caller.ID,
+		CallerLabels: caller.Labels,
+		Granted:      e == nil,
+		WaitMillis:   int64(w / time.Millisecond)}
+	if e != nil {
+		r.Error = e.Error()
+	}
+
+	if e := s.journal.Append(r); e != nil {
+		logging.Warnf("Unable to journal grant of %v tokens from %v: %v", tokens, r.Bucket, e)
+	}
+}
@@ -0,0 +1,158 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+type memoryJournal struct {
+	sync.Mutex
+	records []*GrantRecord
+}
+
+func (m *memoryJournal) Append(r *GrantRecord) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.records = append(m.records, r)
+	return nil
+}
+
+func TestJournalGrants(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("ns", ns)
+
+	bf := &MockBucketFactory{}
+	j := &memoryJournal{}
+	s := New(cfg, bf, &MockEndpoint{}).(*server)
+	s.SetClock(clock.NewFake(time.Unix(100, 0)))
+	s.JournalGrants(j)
+	s.Start()
+	defer s.Stop()
+
+	s.Lease(context.Background(), "ns", "b", 2, -1, 0, PRIORITY_NORMAL, Caller{ID: "caller", Labels: map[string]string{"service": "billing"}})
+	bf.SetWaitTime("ns", "b", time.Hour)
+	s.Allow("ns", "b", 3, 0)
+	s.Allow("ns", "missing", 1, 0)
+
+	expected := []*GrantRecord{
+		{TimeMillis: 100000, Bucket: "ns:b", Tokens: 2, Caller: "caller", CallerLabels: map[string]string{"service": "billing"}, Granted: true},
+		{TimeMillis: 100000, Bucket: "ns:b", Tokens: 3, Error: "Timed out waiting on ns:b"},
+		{TimeMillis: 100000, Bucket: "ns:missing", Tokens: 1, Error: "No such bucket ns:missing"}}
+	if !reflect.DeepEqual(j.records, expected) {
+		t.Fatalf("Expecting grants and denials to be journaled. Journal %+v", j.records)
+	}
+
+	helpers.ExpectingPanic(t, func() {
+		s.JournalGrants(j)
+	})
+}
+
+func TestFileJournal(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_journal")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	location := filepath.Join(dir, "grants.log")
+	record := func(n int64) *GrantRecord {
+		return &GrantRecord{TimeMillis: n, Bucket: "ns:b", Tokens: 1, Granted: true}
+	}
+
+	// Fits two records per file, keeping two rotated files.
+	j, e := NewFileJournal(location, 150, 2)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for n := int64(1); n <= 4; n++ {
+		if e := j.Append(record(n)); e != nil {
+			t.Fatal(e)
+		}
+	}
+	j.Close()
+
+	// Journals are appended to rather than replaced when reopened.
+	if j, e = NewFileJournal(location, 150, 2); e != nil {
+		t.Fatal(e)
+	}
+
+	for n := int64(5); n <= 7; n++ {
+		j.Append(record(n))
+	}
+	j.Close()
+
+	if e := j.Append(record(8)); e == nil {
+		t.Fatal("Expecting closed journals to refuse records")
+	}
+
+	var replayed []int64
+	ReplayJournal(location, func(r *GrantRecord) error {
+		replayed = append(replayed, r.TimeMillis)
+		return nil
+	})
+
+	if !reflect.DeepEqual(replayed, []int64{3, 4, 5, 6, 7}) {
+		t.Fatalf("Expecting records in rotated files that are kept to be replayed in order. Replayed %v", replayed)
+	}
+}
+
+func TestFileJournalWritesBeforeReturning(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_journal")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	location := filepath.Join(dir, "grants.log")
+	j, e := NewFileJournal(location, 0, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer j.Close()
+
+	var wg sync.WaitGroup
+	for n := int64(1); n <= 50; n++ {
+		wg.Add(1)
+		go func(n int64) {
+			defer wg.Done()
+			if e := j.Append(&GrantRecord{TimeMillis: n, Bucket: "ns:b", Tokens: 1, Granted: true}); e != nil {
+				t.Error(e)
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	// Records are in the file once appended, without the journal being closed.
+	replayed := 0
+	ReplayJournal(location, func(r *GrantRecord) error {
+		replayed++
+		return nil
+	})
+
+	if replayed != 50 {
+		t.Fatalf("Expecting records to be written before being appended returns. Replayed %v", replayed)
+	}
+
+	// Records that can't be written fail to be appended.
+	j.f.Close()
+	if e := j.Append(&GrantRecord{TimeMillis: 51, Bucket: "ns:b", Tokens: 1, Granted: true}); e == nil {
+		t.Fatal("Expecting records that can't be written to fail to be appended")
+	}
+}
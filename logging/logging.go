@@ -4,13 +4,50 @@
 package logging
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync/atomic"
 )
 
+// Level is the severity of a message. Messages less severe than the current level aren't logged.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("Level(%d)", int32(l))
+	}
+
+	return levelNames[l]
+}
+
+// ParseLevel returns the level named, case insensitively, by s: debug, info, warn or error.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+
+	return LevelInfo, fmt.Errorf("Unknown log level %q", s)
+}
+
 // Use golang's standard logger by default.
 var logger Logger = log.New(os.Stderr, "", log.LstdFlags)
 
+// Messages are logged at info and above by default.
+var level = int32(LevelInfo)
+
 // Logger mimics golang's standard Logger as an interface.
 type Logger interface {
 	Fatal(args ...interface{})
@@ -31,6 +68,23 @@ func CurrentLogger() Logger {
 	return logger
 }
 
+// SetLevel sets the least severe level of message logged. Safe to call while messages are being
+// logged, so the level can be changed at runtime.
+func SetLevel(l Level) {
+	atomic.StoreInt32(&level, int32(l))
+}
+
+// CurrentLevel gets the least severe level of message logged.
+func CurrentLevel() Level {
+	return Level(atomic.LoadInt32(&level))
+}
+
+// Enabled tells whether messages of level l are logged, so callers can skip building messages
+// that wouldn't be.
+func Enabled(l Level) bool {
+	return l >= CurrentLevel()
+}
+
 // Fatal is equivalent to Print() followed by a call to os.Exit() with a non-zero exit code.
 func Fatal(args ...interface{}) {
 	logger.Fatal(args...)
@@ -46,17 +100,84 @@ func Fatalln(args ...interface{}) {
 	logger.Fatalln(args...)
 }
 
-// Print prints to the logger. Arguments are handled in the manner of fmt.Print.
+// Print prints to the logger at info level. Arguments are handled in the manner of fmt.Print.
 func Print(args ...interface{}) {
-	logger.Print(args...)
+	if Enabled(LevelInfo) {
+		logger.Print(args...)
+	}
 }
 
-// Printf prints to the logger. Arguments are handled in the manner of fmt.Printf.
+// Printf prints to the logger at info level. Arguments are handled in the manner of fmt.Printf.
 func Printf(format string, args ...interface{}) {
-	logger.Printf(format, args...)
+	if Enabled(LevelInfo) {
+		logger.Printf(format, args...)
+	}
 }
 
-// Println prints to the logger. Arguments are handled in the manner of fmt.Println.
+// Println prints to the logger at info level. Arguments are handled in the manner of fmt.Println.
 func Println(args ...interface{}) {
-	logger.Println(args...)
+	if Enabled(LevelInfo) {
+		logger.Println(args...)
+	}
+}
+
+// Debug prints to the logger at debug level, for detail that's only needed when diagnosing
+// problems, such as whole configs. Arguments are handled in the manner of fmt.Print.
+func Debug(args ...interface{}) {
+	if Enabled(LevelDebug) {
+		logAt(LevelDebug, fmt.Sprint(args...))
+	}
+}
+
+// Debugf prints to the logger at debug level. Arguments are handled in the manner of fmt.Printf.
+func Debugf(format string, args ...interface{}) {
+	if Enabled(LevelDebug) {
+		logAt(LevelDebug, fmt.Sprintf(format, args...))
+	}
+}
+
+// Info is equivalent to Print().
+func Info(args ...interface{}) {
+	Print(args...)
+}
+
+// Infof is equivalent to Printf().
+func Infof(format string, args ...interface{}) {
+	Printf(format, args...)
+}
+
+// Warn prints to the logger at warn level, for problems the service carries on despite.
+// Arguments are handled in the manner of fmt.Print.
+func Warn(args ...interface{}) {
+	if Enabled(LevelWarn) {
+		logAt(LevelWarn, fmt.Sprint(args...))
+	}
+}
+
+// Warnf prints to the logger at warn level. Arguments are handled in the manner of fmt.Printf.
+func Warnf(format string, args ...interface{}) {
+	if Enabled(LevelWarn) {
+		logAt(LevelWarn, fmt.Sprintf(format, args...))
+	}
+}
+
+// Error prints to the logger at error level, for failures. Arguments are handled in the manner of
+// fmt.Print.
+func Error(args ...interface{}) {
+	if Enabled(LevelError) {
+		logAt(LevelError, fmt.Sprint(args...))
+	}
+}
+
+// Errorf prints to the logger at error level. Arguments are handled in the manner of fmt.Printf.
+func Errorf(format string, args ...interface{}) {
+	if Enabled(LevelError) {
+		logAt(LevelError, fmt.Sprintf(format, args...))
+	}
+}
+
+// logAt logs msg prefixed by the name of its level, so messages of different levels can be told
+// apart. Info messages aren't prefixed, as Print has always logged them that way.
+func logAt(l Level, msg string) {
+	logger.Print(strings.ToUpper(l.String()) + " " + msg)
 }
@@ -0,0 +1,55 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package logging
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		if parsed, e := ParseLevel(l.String()); e != nil || parsed != l {
+			t.Errorf("Expecting %v to parse, got %v, %v", l, parsed, e)
+		}
+	}
+
+	if l, e := ParseLevel("WARN"); e != nil || l != LevelWarn {
+		t.Errorf("Expecting levels to parse case insensitively, got %v, %v", l, e)
+	}
+
+	if _, e := ParseLevel("verbose"); e == nil {
+		t.Error("Expecting an unknown level not to parse")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	defer SetLogger(CurrentLogger())
+	defer SetLevel(CurrentLevel())
+	SetLogger(log.New(&buf, "", 0))
+
+	logAll := func() {
+		Debugf("debug %v", 1)
+		Printf("info %v", 2)
+		Warn("warn ", 3)
+		Errorf("error %v", 4)
+	}
+
+	tests := map[Level]string{
+		LevelDebug: "DEBUG debug 1\ninfo 2\nWARN warn 3\nERROR error 4\n",
+		LevelInfo:  "info 2\nWARN warn 3\nERROR error 4\n",
+		LevelWarn:  "WARN warn 3\nERROR error 4\n",
+		LevelError: "ERROR error 4\n"}
+
+	for l, expected := range tests {
+		buf.Reset()
+		SetLevel(l)
+		logAll()
+		if buf.String() != expected {
+			t.Errorf("%v: expecting %q, got %q", l, expected, buf.String())
+		}
+	}
+}
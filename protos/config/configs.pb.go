@@ -12,6 +12,9 @@ It has these top-level messages:
 	ServiceConfig
 	NamespaceConfig
 	BucketConfig
+	AdaptiveConfig
+	Int64Value
+	ScheduleEntry
 */
 package quotaservice_configs
 
@@ -29,6 +32,24 @@ type ServiceConfig struct {
 	GlobalDefaultBucket *BucketConfig      `protobuf:"bytes,1,opt,name=global_default_bucket" json:"global_default_bucket,omitempty"`
 	Namespaces          []*NamespaceConfig `protobuf:"bytes,2,rep,name=namespaces" json:"namespaces,omitempty"`
 	Version             int32              `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+	// When the change resulting in this version was made (Unix time, in millis).
+	Date int64 `protobuf:"varint,4,opt,name=date" json:"date,omitempty"`
+	// Defaults for any settings buckets and their namespaces don't specify.
+	Defaults *BucketConfig `protobuf:"bytes,5,opt,name=defaults" json:"defaults,omitempty"`
+	// HMAC-SHA256 of the config's canonical YAML, if configs are signed.
+	Signature []byte `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"`
+	// Version of the schema the config was written with, used to migrate stored configs.
+	SchemaVersion int32 `protobuf:"varint,7,opt,name=schema_version" json:"schema_version,omitempty"`
+	// Who made the change resulting in this version, if known.
+	User string `protobuf:"bytes,8,opt,name=user" json:"user,omitempty"`
+	// Tokens granted per second across all namespaces, or 0 if uncapped.
+	GlobalRateCap int64 `protobuf:"varint,9,opt,name=global_rate_cap" json:"global_rate_cap,omitempty"`
+	// Connection settings for namespaces whose buckets are backed by Redis.
+	Redis *RedisConfig `protobuf:"bytes,10,opt,name=redis" json:"redis,omitempty"`
+	// Connection settings for namespaces whose buckets are backed by DynamoDB.
+	Dynamo *DynamoConfig `protobuf:"bytes,11,opt,name=dynamo" json:"dynamo,omitempty"`
+	// Connection settings for namespaces whose buckets are backed by Cassandra.
+	Cassandra *CassandraConfig `protobuf:"bytes,12,opt,name=cassandra" json:"cassandra,omitempty"`
 }
 
 func (m *ServiceConfig) Reset()                    { *m = ServiceConfig{} }
@@ -50,12 +71,59 @@ func (m *ServiceConfig) GetNamespaces() []*NamespaceConfig {
 	return nil
 }
 
+func (m *ServiceConfig) GetDefaults() *BucketConfig {
+	if m != nil {
+		return m.Defaults
+	}
+	return nil
+}
+
+func (m *ServiceConfig) GetRedis() *RedisConfig {
+	if m != nil {
+		return m.Redis
+	}
+	return nil
+}
+
+func (m *ServiceConfig) GetDynamo() *DynamoConfig {
+	if m != nil {
+		return m.Dynamo
+	}
+	return nil
+}
+
+func (m *ServiceConfig) GetCassandra() *CassandraConfig {
+	if m != nil {
+		return m.Cassandra
+	}
+	return nil
+}
+
 type NamespaceConfig struct {
 	Name                  string          `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	DefaultBucket         *BucketConfig   `protobuf:"bytes,2,opt,name=default_bucket" json:"default_bucket,omitempty"`
 	DynamicBucketTemplate *BucketConfig   `protobuf:"bytes,3,opt,name=dynamic_bucket_template" json:"dynamic_bucket_template,omitempty"`
 	MaxDynamicBuckets     int32           `protobuf:"varint,4,opt,name=max_dynamic_buckets" json:"max_dynamic_buckets,omitempty"`
 	Buckets               []*BucketConfig `protobuf:"bytes,5,rep,name=buckets" json:"buckets,omitempty"`
+	// Defaults for any settings the namespace's buckets don't specify.
+	Defaults *BucketConfig `protobuf:"bytes,6,opt,name=defaults" json:"defaults,omitempty"`
+	// Tags such as ownership, team or cost center, inherited by the namespace's buckets.
+	Labels map[string]string `protobuf:"bytes,7,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Other names the namespace can be looked up by, such as names it was previously known by.
+	Aliases []string `protobuf:"bytes,8,rep,name=aliases" json:"aliases,omitempty"`
+	// Caps the namespace's buckets whose parent is ___CEILING___.
+	Ceiling *BucketConfig `protobuf:"bytes,9,opt,name=ceiling" json:"ceiling,omitempty"`
+	// What happens once max_dynamic_buckets is reached: reject, the default, lru or lowest_use.
+	Eviction string `protobuf:"bytes,10,opt,name=eviction" json:"eviction,omitempty"`
+	// Has all of the namespace's buckets draw from its ceiling, whether or not it's their parent.
+	Pooled bool `protobuf:"varint,11,opt,name=pooled" json:"pooled,omitempty"`
+	// Puts those of the namespace's buckets in normal mode in shadow mode.
+	Shadow bool `protobuf:"varint,12,opt,name=shadow" json:"shadow,omitempty"`
+	// Where the namespace's buckets are kept: memory, the default, or redis.
+	Backend string `protobuf:"bytes,13,opt,name=backend" json:"backend,omitempty"`
+	// Fraction of their sizes instances grant buckets' tokens locally from, reconciling with the
+	// backend asynchronously. 0, the default, has the backend grant every request.
+	Tolerance float64 `protobuf:"fixed64,14,opt,name=tolerance" json:"tolerance,omitempty"`
 }
 
 func (m *NamespaceConfig) Reset()                    { *m = NamespaceConfig{} }
@@ -84,6 +152,27 @@ func (m *NamespaceConfig) GetBuckets() []*BucketConfig {
 	return nil
 }
 
+func (m *NamespaceConfig) GetDefaults() *BucketConfig {
+	if m != nil {
+		return m.Defaults
+	}
+	return nil
+}
+
+func (m *NamespaceConfig) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *NamespaceConfig) GetCeiling() *BucketConfig {
+	if m != nil {
+		return m.Ceiling
+	}
+	return nil
+}
+
 type BucketConfig struct {
 	Name                string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	Size                int64  `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
@@ -92,6 +181,41 @@ type BucketConfig struct {
 	MaxIdleMillis       int64  `protobuf:"varint,5,opt,name=max_idle_millis" json:"max_idle_millis,omitempty"`
 	MaxDebtMillis       int64  `protobuf:"varint,6,opt,name=max_debt_millis" json:"max_debt_millis,omitempty"`
 	MaxTokensPerRequest int64  `protobuf:"varint,7,opt,name=max_tokens_per_request" json:"max_tokens_per_request,omitempty"`
+	// Tags such as ownership, team or cost center.
+	Labels map[string]string `protobuf:"bytes,8,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// How requests are limited: token_bucket, the default, sliding_window, gcra or concurrency.
+	Algorithm string `protobuf:"bytes,9,opt,name=algorithm" json:"algorithm,omitempty"`
+	// Length of the rolling window that sliding_window buckets allow size requests in.
+	WindowMillis int64 `protobuf:"varint,10,opt,name=window_millis" json:"window_millis,omitempty"`
+	// Tokens gcra buckets allow to be claimed at once. Defaults to size.
+	Burst int64 `protobuf:"varint,11,opt,name=burst" json:"burst,omitempty"`
+	// Nanos between the tokens of gcra buckets. Defaults to a second over fill_rate.
+	EmissionInterval int64 `protobuf:"varint,12,opt,name=emission_interval" json:"emission_interval,omitempty"`
+	// How long concurrency buckets hold tokens leased by clients that don't release them.
+	LeaseTtlMillis int64 `protobuf:"varint,13,opt,name=lease_ttl_millis" json:"lease_ttl_millis,omitempty"`
+	// Bucket in the same namespace, or ___CEILING___, that tokens must also be available in.
+	Parent string `protobuf:"bytes,14,opt,name=parent" json:"parent,omitempty"`
+	// Callers that may be waiting on the bucket's tokens before further requests are rejected.
+	// Defaults to 0, which doesn't limit them.
+	MaxWaiters int64 `protobuf:"varint,15,opt,name=max_waiters" json:"max_waiters,omitempty"`
+	// Whether callers waiting on the bucket's tokens are queued and granted them in arrival order.
+	Fifo bool `protobuf:"varint,16,opt,name=fifo" json:"fifo,omitempty"`
+	// Tokens held back for high priority requests.
+	HighPriorityReserve int64 `protobuf:"varint,17,opt,name=high_priority_reserve" json:"high_priority_reserve,omitempty"`
+	// Tokens each operation costs, for requests naming their operation.
+	Costs map[string]int64 `protobuf:"bytes,18,rep,name=costs" json:"costs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Adapts the fill rate to the health of the downstream the bucket protects.
+	Adaptive *AdaptiveConfig `protobuf:"bytes,19,opt,name=adaptive" json:"adaptive,omitempty"`
+	// Share of the bucket's tokens it starts with, from 0 to 100. Buckets start full if unset.
+	InitialFillPercent *Int64Value `protobuf:"bytes,20,opt,name=initial_fill_percent" json:"initial_fill_percent,omitempty"`
+	// Windows of the day during which the bucket has a different size or fill rate.
+	Schedule []*ScheduleEntry `protobuf:"bytes,21,rep,name=schedule" json:"schedule,omitempty"`
+	// Period fill_rate tokens are added over, in millis. Defaults to a second.
+	FillPeriodMillis int64 `protobuf:"varint,22,opt,name=fill_period_millis" json:"fill_period_millis,omitempty"`
+	// Administrative mode: normal, always_allow, always_deny or shadow. Defaults to normal.
+	Mode string `protobuf:"bytes,23,opt,name=mode" json:"mode,omitempty"`
+	// Share of requests, from 0 to 100, that the bucket's refusals apply to. All requests if unset.
+	EnforcementPercent *Int64Value `protobuf:"bytes,24,opt,name=enforcement_percent" json:"enforcement_percent,omitempty"`
 }
 
 func (m *BucketConfig) Reset()                    { *m = BucketConfig{} }
@@ -99,33 +223,224 @@ func (m *BucketConfig) String() string            { return proto.CompactTextStri
 func (*BucketConfig) ProtoMessage()               {}
 func (*BucketConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
+func (m *BucketConfig) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *BucketConfig) GetCosts() map[string]int64 {
+	if m != nil {
+		return m.Costs
+	}
+	return nil
+}
+
+func (m *BucketConfig) GetAdaptive() *AdaptiveConfig {
+	if m != nil {
+		return m.Adaptive
+	}
+	return nil
+}
+
+func (m *BucketConfig) GetInitialFillPercent() *Int64Value {
+	if m != nil {
+		return m.InitialFillPercent
+	}
+	return nil
+}
+
+func (m *BucketConfig) GetSchedule() []*ScheduleEntry {
+	if m != nil {
+		return m.Schedule
+	}
+	return nil
+}
+
+func (m *BucketConfig) GetEnforcementPercent() *Int64Value {
+	if m != nil {
+		return m.EnforcementPercent
+	}
+	return nil
+}
+
+type AdaptiveConfig struct {
+	// Error rate, from 0 to 1, and latency beyond which the downstream is unhealthy.
+	MaxErrorRate     float64 `protobuf:"fixed64,1,opt,name=max_error_rate" json:"max_error_rate,omitempty"`
+	MaxLatencyMillis int64   `protobuf:"varint,2,opt,name=max_latency_millis" json:"max_latency_millis,omitempty"`
+	// Share of the fill rate kept each time the downstream is reported unhealthy. Defaults to 50.
+	BackoffPercent int64 `protobuf:"varint,3,opt,name=backoff_percent" json:"backoff_percent,omitempty"`
+	// Tokens per second added back each time the downstream is reported healthy.
+	Increase int64 `protobuf:"varint,4,opt,name=increase" json:"increase,omitempty"`
+	// Lowest the fill rate backs off to. Defaults to 1.
+	MinFillRate int64 `protobuf:"varint,5,opt,name=min_fill_rate" json:"min_fill_rate,omitempty"`
+}
+
+func (m *AdaptiveConfig) Reset()                    { *m = AdaptiveConfig{} }
+func (m *AdaptiveConfig) String() string            { return proto.CompactTextString(m) }
+func (*AdaptiveConfig) ProtoMessage()               {}
+func (*AdaptiveConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+// Wraps an int64, so that setting it to 0 can be told apart from leaving it unset.
+type Int64Value struct {
+	Value int64 `protobuf:"varint,1,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *Int64Value) Reset()                    { *m = Int64Value{} }
+func (m *Int64Value) String() string            { return proto.CompactTextString(m) }
+func (*Int64Value) ProtoMessage()               {}
+func (*Int64Value) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+type ScheduleEntry struct {
+	// Days the window starts on, such as mon or sat. Every day if empty.
+	Days []string `protobuf:"bytes,1,rep,name=days" json:"days,omitempty"`
+	// Start and end of the window, as HH:MM in UTC.
+	Start string `protobuf:"bytes,2,opt,name=start" json:"start,omitempty"`
+	End   string `protobuf:"bytes,3,opt,name=end" json:"end,omitempty"`
+	// Size and fill rate during the window, if set.
+	Size     int64 `protobuf:"varint,4,opt,name=size" json:"size,omitempty"`
+	FillRate int64 `protobuf:"varint,5,opt,name=fill_rate" json:"fill_rate,omitempty"`
+}
+
+func (m *ScheduleEntry) Reset()                    { *m = ScheduleEntry{} }
+func (m *ScheduleEntry) String() string            { return proto.CompactTextString(m) }
+func (*ScheduleEntry) ProtoMessage()               {}
+func (*ScheduleEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type RedisConfig struct {
+	// host:port of the Redis server.
+	Addr     string `protobuf:"bytes,1,opt,name=addr" json:"addr,omitempty"`
+	Password string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
+	Db       int64  `protobuf:"varint,3,opt,name=db" json:"db,omitempty"`
+	// Connections kept open to the server, or 0 for the client's default.
+	PoolSize int32 `protobuf:"varint,4,opt,name=pool_size" json:"pool_size,omitempty"`
+	// Attempts made to reconnect before giving up on a request.
+	ConnectionRetries int32 `protobuf:"varint,5,opt,name=connection_retries" json:"connection_retries,omitempty"`
+}
+
+func (m *RedisConfig) Reset()                    { *m = RedisConfig{} }
+func (m *RedisConfig) String() string            { return proto.CompactTextString(m) }
+func (*RedisConfig) ProtoMessage()               {}
+func (*RedisConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+type DynamoConfig struct {
+	// AWS region and name of the table bucket state is kept in.
+	Region string `protobuf:"bytes,1,opt,name=region" json:"region,omitempty"`
+	Table  string `protobuf:"bytes,2,opt,name=table" json:"table,omitempty"`
+	// URL of the DynamoDB API, if not the region's, such as for DynamoDB Local.
+	Endpoint string `protobuf:"bytes,3,opt,name=endpoint" json:"endpoint,omitempty"`
+	// Attempts made at claiming tokens when other instances claim them at the same time.
+	MaxAttempts int32 `protobuf:"varint,4,opt,name=max_attempts" json:"max_attempts,omitempty"`
+}
+
+func (m *DynamoConfig) Reset()                    { *m = DynamoConfig{} }
+func (m *DynamoConfig) String() string            { return proto.CompactTextString(m) }
+func (*DynamoConfig) ProtoMessage()               {}
+func (*DynamoConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+type CassandraConfig struct {
+	// host:port of the nodes to connect to, tried in turn.
+	Hosts []string `protobuf:"bytes,1,rep,name=hosts" json:"hosts,omitempty"`
+	// Keyspace and name of the table tokens are counted in.
+	Keyspace string `protobuf:"bytes,2,opt,name=keyspace" json:"keyspace,omitempty"`
+	Table    string `protobuf:"bytes,3,opt,name=table" json:"table,omitempty"`
+	Username string `protobuf:"bytes,4,opt,name=username" json:"username,omitempty"`
+	Password string `protobuf:"bytes,5,opt,name=password" json:"password,omitempty"`
+	// Consistency level of reads and writes, such as local_quorum. Defaults to quorum.
+	Consistency string `protobuf:"bytes,6,opt,name=consistency" json:"consistency,omitempty"`
+	// How tokens are counted: counter, the default, or lwt.
+	Mode string `protobuf:"bytes,7,opt,name=mode" json:"mode,omitempty"`
+	// Attempts made at claiming tokens with lightweight transactions when other instances claim them
+	// at the same time.
+	MaxAttempts int32 `protobuf:"varint,8,opt,name=max_attempts" json:"max_attempts,omitempty"`
+}
+
+func (m *CassandraConfig) Reset()                    { *m = CassandraConfig{} }
+func (m *CassandraConfig) String() string            { return proto.CompactTextString(m) }
+func (*CassandraConfig) ProtoMessage()               {}
+func (*CassandraConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
 func init() {
 	proto.RegisterType((*ServiceConfig)(nil), "quotaservice.configs.ServiceConfig")
 	proto.RegisterType((*NamespaceConfig)(nil), "quotaservice.configs.NamespaceConfig")
 	proto.RegisterType((*BucketConfig)(nil), "quotaservice.configs.BucketConfig")
+	proto.RegisterType((*AdaptiveConfig)(nil), "quotaservice.configs.AdaptiveConfig")
+	proto.RegisterType((*Int64Value)(nil), "quotaservice.configs.Int64Value")
+	proto.RegisterType((*ScheduleEntry)(nil), "quotaservice.configs.ScheduleEntry")
+	proto.RegisterType((*RedisConfig)(nil), "quotaservice.configs.RedisConfig")
+	proto.RegisterType((*DynamoConfig)(nil), "quotaservice.configs.DynamoConfig")
+	proto.RegisterType((*CassandraConfig)(nil), "quotaservice.configs.CassandraConfig")
 }
 
 var fileDescriptor0 = []byte{
-	// 329 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x8c, 0x92, 0x4b, 0x6a, 0xf3, 0x30,
-	0x14, 0x85, 0x71, 0x9c, 0x07, 0xb9, 0x7f, 0xfe, 0x86, 0xba, 0x8f, 0x18, 0x02, 0x25, 0x18, 0x0a,
-	0x1d, 0xb9, 0xd0, 0x8c, 0xda, 0x59, 0x9b, 0x79, 0x27, 0x5d, 0x80, 0x90, 0xed, 0x9b, 0x20, 0x22,
-	0x5b, 0x8e, 0x24, 0xa7, 0x8f, 0xc5, 0x74, 0x05, 0x5d, 0x5d, 0x57, 0x50, 0x49, 0xb1, 0x4b, 0x13,
-	0x32, 0xf0, 0xc8, 0xf8, 0x9c, 0x7b, 0xce, 0xfd, 0xae, 0x31, 0x4c, 0x4b, 0x29, 0xb4, 0x50, 0xb7,
-	0xa9, 0x28, 0x96, 0x6c, 0x55, 0x3f, 0x54, 0xec, 0xd4, 0xe0, 0x7c, 0x53, 0x09, 0x4d, 0x15, 0xca,
-	0x2d, 0x4b, 0x31, 0xae, 0xbd, 0xe8, 0xd3, 0x83, 0xff, 0x2f, 0x3b, 0x6d, 0xe1, 0xa4, 0xe0, 0x11,
-	0x2e, 0x56, 0x5c, 0x24, 0x94, 0x93, 0x0c, 0x97, 0xb4, 0xe2, 0x9a, 0x24, 0x55, 0xba, 0x46, 0x1d,
-	0x7a, 0x33, 0xef, 0xe6, 0xdf, 0x5d, 0x14, 0x1f, 0xeb, 0x89, 0x9f, 0xdc, 0x4c, 0x5d, 0x71, 0x0f,
-	0x50, 0xd0, 0x1c, 0x55, 0x49, 0x53, 0x54, 0x61, 0x67, 0xe6, 0x9b, 0xdc, 0xf5, 0xf1, 0xdc, 0x73,
-	0x33, 0x57, 0x47, 0xc7, 0x30, 0xd8, 0xa2, 0x54, 0x4c, 0x14, 0xa1, 0x6f, 0xf6, 0xf5, 0xa2, 0x6f,
-	0x0f, 0xc6, 0x87, 0x43, 0x23, 0xe8, 0xda, 0x7e, 0x47, 0x34, 0x0c, 0x1e, 0xe0, 0xe4, 0x80, 0xb4,
-	0xd3, 0x9a, 0x74, 0x01, 0x93, 0xec, 0xdd, 0x74, 0xb1, 0xb4, 0xce, 0x12, 0x8d, 0x79, 0xc9, 0xa9,
-	0x46, 0xb7, 0xbe, 0x5d, 0xc9, 0x14, 0xce, 0x72, 0xfa, 0x46, 0xf6, 0x8b, 0x54, 0xd8, 0xb5, 0xfc,
-	0xc1, 0x1c, 0x06, 0x8d, 0xd0, 0x73, 0x1f, 0xa2, 0x45, 0x63, 0xf4, 0xe5, 0xc1, 0x68, 0x6f, 0xc5,
-	0xfe, 0xc5, 0xe6, 0x4d, 0xb1, 0x0f, 0x74, 0x77, 0xfa, 0xc1, 0x29, 0x0c, 0x97, 0x8c, 0x73, 0x22,
-	0x1b, 0x6a, 0xdf, 0x12, 0xbd, 0x52, 0x66, 0x8e, 0x61, 0x39, 0x8a, 0x4a, 0x93, 0xdc, 0xf8, 0x6c,
-	0x47, 0xe4, 0x07, 0x13, 0x18, 0x5b, 0x5c, 0x96, 0x71, 0x6c, 0x8c, 0xde, 0x5f, 0x23, 0xc3, 0xe4,
-	0x37, 0xd1, 0x77, 0xc6, 0x15, 0x5c, 0x5a, 0x43, 0x8b, 0x35, 0x16, 0x8a, 0x94, 0x28, 0x89, 0xc4,
-	0x4d, 0x85, 0x4a, 0x87, 0x03, 0xeb, 0x27, 0x7d, 0xf7, 0x87, 0xcd, 0x7f, 0x02, 0x00, 0x00, 0xff,
-	0xff, 0xd5, 0x63, 0xb2, 0x4e, 0x80, 0x02, 0x00, 0x00,
+	// 1080 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x56, 0xdd, 0x8e, 0xdb, 0x44,
+	0x14, 0x96, 0xd7, 0x71, 0xd6, 0x3e, 0xf9, 0xf7, 0xfe, 0x0d, 0xad, 0x40, 0x21, 0x80, 0x94, 0x0b,
+	0x1a, 0x41, 0x5b, 0xaa, 0x52, 0x41, 0xa5, 0x65, 0xe1, 0x02, 0x09, 0x71, 0x41, 0x05, 0x17, 0xdc,
+	0x58, 0x13, 0xfb, 0x24, 0x19, 0xad, 0x3d, 0x93, 0xce, 0x4c, 0xb2, 0x84, 0x07, 0xe0, 0x31, 0x78,
+	0x0c, 0x9e, 0x84, 0x7b, 0x5e, 0x05, 0xcd, 0xb1, 0x1d, 0x92, 0x6d, 0x0a, 0xe1, 0x32, 0x9e, 0xf3,
+	0xf3, 0x9d, 0xef, 0x3b, 0x3f, 0x81, 0x4e, 0xaa, 0xe4, 0x4c, 0xcc, 0xcd, 0x64, 0xa9, 0x95, 0x55,
+	0xf1, 0xf9, 0xeb, 0x95, 0xb2, 0xdc, 0xa0, 0x5e, 0x8b, 0x14, 0x27, 0xd5, 0xdb, 0xe8, 0x4f, 0x1f,
+	0x3a, 0xaf, 0xca, 0x6f, 0x37, 0xf4, 0x29, 0xbe, 0x86, 0x8b, 0x79, 0xae, 0xa6, 0x3c, 0x4f, 0x32,
+	0x9c, 0xf1, 0x55, 0x6e, 0x93, 0xe9, 0x2a, 0xbd, 0x45, 0xcb, 0xbc, 0xa1, 0x37, 0x6e, 0x3d, 0x1e,
+	0x4d, 0x0e, 0xc5, 0x99, 0x7c, 0x45, 0x36, 0x55, 0x88, 0xcf, 0x01, 0x24, 0x2f, 0xd0, 0x2c, 0x79,
+	0x8a, 0x86, 0x9d, 0x0c, 0xfd, 0x71, 0xeb, 0xf1, 0x47, 0x87, 0xfd, 0xbe, 0xaf, 0xed, 0x2a, 0xd7,
+	0x1e, 0x9c, 0xae, 0x51, 0x1b, 0xa1, 0x24, 0xf3, 0x87, 0xde, 0x38, 0x88, 0xdb, 0xd0, 0xc8, 0xb8,
+	0x45, 0xd6, 0x18, 0x7a, 0x63, 0x3f, 0x7e, 0x0a, 0x61, 0x85, 0xca, 0xb0, 0xe0, 0x68, 0x3c, 0x03,
+	0x88, 0x8c, 0x98, 0x4b, 0x6e, 0x57, 0x1a, 0x59, 0x73, 0xe8, 0x8d, 0xdb, 0xf1, 0x25, 0x74, 0x4d,
+	0xba, 0xc0, 0x82, 0x27, 0x75, 0xba, 0xd3, 0x3a, 0xdd, 0xca, 0xa0, 0x66, 0xe1, 0xd0, 0x1b, 0x47,
+	0xf1, 0x15, 0xf4, 0x2a, 0x2e, 0x34, 0xb7, 0x98, 0xa4, 0x7c, 0xc9, 0x22, 0xc2, 0xf1, 0x09, 0x04,
+	0x1a, 0x33, 0x61, 0x18, 0x10, 0x88, 0xf7, 0x0f, 0x83, 0xf8, 0xc1, 0x99, 0x54, 0x18, 0x1e, 0x43,
+	0x33, 0xdb, 0x48, 0x5e, 0x28, 0xd6, 0xfa, 0x37, 0xdc, 0x5f, 0x93, 0x4d, 0xe5, 0xf3, 0x1c, 0xa2,
+	0x94, 0x1b, 0xc3, 0x65, 0xa6, 0x39, 0x6b, 0x0f, 0xbd, 0xb7, 0xd3, 0x78, 0x53, 0x9b, 0x95, 0x9e,
+	0xa3, 0x3f, 0x1a, 0xd0, 0xbb, 0x4f, 0x6d, 0x1b, 0x1a, 0x4e, 0x15, 0xd2, 0x31, 0x8a, 0x5f, 0x40,
+	0xf7, 0x9e, 0xbe, 0x27, 0x47, 0xf3, 0x79, 0x03, 0x57, 0x54, 0x8b, 0x48, 0x2b, 0xdf, 0xc4, 0x62,
+	0xb1, 0xcc, 0x9d, 0x4c, 0xfe, 0xd1, 0x41, 0x1e, 0xc2, 0x59, 0xc1, 0x7f, 0x49, 0xf6, 0x03, 0x19,
+	0xd2, 0x39, 0x88, 0x9f, 0xc0, 0x69, 0xfd, 0x21, 0x18, 0xfa, 0x47, 0x46, 0xdc, 0x6d, 0x8e, 0xe6,
+	0xd1, 0x38, 0xae, 0xa1, 0x99, 0xf3, 0x29, 0xe6, 0x86, 0x9d, 0x52, 0xa6, 0x4f, 0x8f, 0x6a, 0xd4,
+	0xc9, 0x77, 0xe4, 0xf3, 0x8d, 0xb4, 0x7a, 0xe3, 0x9a, 0x96, 0xe7, 0x82, 0x1b, 0x34, 0x2c, 0x1c,
+	0xfa, 0xe3, 0xc8, 0xc1, 0x4f, 0x51, 0xe4, 0x42, 0xce, 0x59, 0x74, 0x34, 0x90, 0x3e, 0x84, 0xb8,
+	0x16, 0xa9, 0x75, 0xcd, 0x08, 0xa4, 0x51, 0x17, 0x9a, 0x4b, 0xa5, 0x72, 0xcc, 0xa8, 0x67, 0x42,
+	0xf7, 0xdb, 0x2c, 0x78, 0xa6, 0xee, 0xa8, 0x19, 0x42, 0x97, 0x77, 0xca, 0xd3, 0x5b, 0x94, 0x19,
+	0xeb, 0x90, 0xc3, 0x00, 0x22, 0xab, 0x72, 0xd4, 0x5c, 0xa6, 0xc8, 0xba, 0x43, 0x6f, 0xec, 0x3d,
+	0x78, 0x04, 0xad, 0x5d, 0xa8, 0x2d, 0xf0, 0x6f, 0x71, 0x53, 0xf5, 0x40, 0x07, 0x82, 0x35, 0xcf,
+	0x57, 0x48, 0xd2, 0x47, 0x2f, 0x4e, 0x9e, 0x7b, 0xa3, 0xbf, 0x9a, 0xd0, 0xde, 0x43, 0xb5, 0xdf,
+	0x35, 0x6d, 0x68, 0x18, 0xf1, 0x6b, 0xe9, 0xe0, 0xbb, 0x74, 0x33, 0x91, 0x97, 0xc3, 0x41, 0xca,
+	0xfb, 0x4e, 0xd5, 0x3b, 0x2e, 0x6c, 0x62, 0x45, 0x81, 0x6a, 0x65, 0x93, 0x42, 0xe4, 0xb9, 0x30,
+	0xd5, 0xf4, 0x5e, 0x41, 0xcf, 0x49, 0x2e, 0xb2, 0x1c, 0xeb, 0x87, 0x60, 0xf7, 0x21, 0xc3, 0xe9,
+	0xd6, 0xa3, 0x49, 0x0f, 0xef, 0xc1, 0xa5, 0x7b, 0xb0, 0xea, 0x16, 0xa5, 0x49, 0x96, 0xa8, 0x13,
+	0x8d, 0xaf, 0x57, 0x68, 0x2c, 0x8d, 0xab, 0x1f, 0xbf, 0xdc, 0x8a, 0x17, 0x92, 0x78, 0x93, 0xff,
+	0xe6, 0x79, 0x4f, 0xb9, 0x01, 0x44, 0x3c, 0x9f, 0x2b, 0x2d, 0xec, 0xa2, 0x20, 0xa9, 0xa2, 0xf8,
+	0x02, 0x3a, 0x77, 0x42, 0x66, 0xea, 0xae, 0x46, 0x02, 0x94, 0xa9, 0x03, 0xc1, 0x74, 0xa5, 0x8d,
+	0x25, 0x29, 0xfc, 0xf8, 0x1d, 0x18, 0x60, 0x21, 0x8c, 0xdb, 0x1c, 0x89, 0x90, 0x16, 0xf5, 0x9a,
+	0xe7, 0xa4, 0x8a, 0x1f, 0x33, 0xe8, 0xe7, 0xc8, 0x0d, 0x26, 0xd6, 0xe6, 0x75, 0x8c, 0x0e, 0xbd,
+	0x38, 0x3d, 0xb9, 0x46, 0x69, 0x49, 0x9b, 0x28, 0x3e, 0x83, 0x96, 0xab, 0xce, 0x11, 0x86, 0xda,
+	0xb0, 0x1e, 0x19, 0xb5, 0xa1, 0x31, 0x13, 0x33, 0xc5, 0xfa, 0x24, 0xf1, 0xbb, 0x70, 0xb1, 0x10,
+	0xf3, 0x45, 0xb2, 0xd4, 0xc2, 0xa1, 0xdc, 0x24, 0x1a, 0x5d, 0x71, 0xc8, 0x06, 0x64, 0xfc, 0x05,
+	0x04, 0xa9, 0x32, 0xd6, 0xb0, 0x98, 0xca, 0x7f, 0x74, 0x44, 0xf9, 0x37, 0xce, 0xbe, 0xac, 0xfe,
+	0x19, 0x84, 0x3c, 0xe3, 0x4b, 0x2b, 0xd6, 0xc8, 0xce, 0xa8, 0x4f, 0x3f, 0x3c, 0x1c, 0xe0, 0xba,
+	0xb2, 0xaa, 0x7a, 0xe2, 0x25, 0x9c, 0x0b, 0x29, 0xac, 0xe0, 0x79, 0x42, 0xfa, 0x2f, 0x51, 0xa7,
+	0xae, 0xaa, 0x73, 0x8a, 0x31, 0x3c, 0x1c, 0xe3, 0x5b, 0x69, 0x9f, 0x3d, 0xfd, 0xc9, 0xb5, 0x5b,
+	0xfc, 0x19, 0x84, 0x6e, 0xf9, 0x66, 0xab, 0x1c, 0xd9, 0x05, 0x01, 0xff, 0xe0, 0xb0, 0xcf, 0xab,
+	0xca, 0xaa, 0x84, 0xfb, 0x00, 0xe2, 0x3a, 0x9d, 0x50, 0x59, 0x4d, 0xed, 0x65, 0xcd, 0x5a, 0xa1,
+	0x32, 0x64, 0x57, 0x44, 0xec, 0x97, 0x70, 0x86, 0x72, 0xa6, 0x74, 0x8a, 0x05, 0x4a, 0xbb, 0xc5,
+	0xc7, 0x8e, 0xc3, 0xf7, 0x3f, 0x67, 0xe6, 0xc1, 0xc7, 0x00, 0x3b, 0xa4, 0xbe, 0xdd, 0xda, 0xa7,
+	0x09, 0xfb, 0xcd, 0x83, 0xee, 0x3d, 0x3e, 0x2f, 0xa1, 0xeb, 0xfa, 0x00, 0xb5, 0x56, 0xba, 0x1c,
+	0x26, 0xe7, 0xed, 0xb9, 0x82, 0xdd, 0x77, 0xb7, 0x58, 0x65, 0xba, 0xa9, 0x0b, 0x3e, 0xa9, 0x47,
+	0xc6, 0xcd, 0xbe, 0x9a, 0xcd, 0xb6, 0xe5, 0x95, 0x13, 0xd8, 0x87, 0x50, 0xc8, 0x54, 0xbb, 0x0e,
+	0xac, 0xc6, 0xee, 0x02, 0x3a, 0x85, 0x90, 0xc9, 0x3f, 0xa3, 0x4a, 0x43, 0x37, 0x7a, 0x08, 0xb0,
+	0xa3, 0xc9, 0x16, 0xa9, 0x47, 0x8f, 0x3f, 0x43, 0x67, 0x9f, 0x7c, 0xba, 0xc3, 0x1b, 0xc3, 0x3c,
+	0x5a, 0x70, 0x1d, 0x08, 0x8c, 0xe5, 0xba, 0x3c, 0x1a, 0x91, 0xab, 0xd9, 0x2d, 0x21, 0x7f, 0x6f,
+	0x47, 0x34, 0xde, 0xdc, 0x11, 0x65, 0xe2, 0x05, 0xb4, 0x76, 0x2f, 0x63, 0x1b, 0x1a, 0x3c, 0xcb,
+	0x74, 0xc5, 0x58, 0x1f, 0xc2, 0x25, 0x37, 0xe6, 0x4e, 0xe9, 0xac, 0x0a, 0x0e, 0x70, 0x92, 0x4d,
+	0xab, 0xe2, 0x06, 0x10, 0xb9, 0x8d, 0x98, 0x6c, 0x13, 0x04, 0x8e, 0xa4, 0x54, 0x49, 0x89, 0xb4,
+	0x38, 0x13, 0x8d, 0x56, 0x0b, 0x2c, 0xf7, 0x4a, 0x30, 0xfa, 0x11, 0xda, 0x7b, 0x07, 0xb5, 0x0b,
+	0x4d, 0x8d, 0x73, 0xb7, 0x60, 0xb7, 0xf2, 0x58, 0x3e, 0xcd, 0x2b, 0x31, 0x5d, 0x6e, 0x94, 0xd9,
+	0x52, 0x89, 0x8a, 0xcc, 0x28, 0x3e, 0x87, 0xb6, 0x53, 0x80, 0x5b, 0x77, 0xe0, 0xea, 0xeb, 0x34,
+	0xfa, 0xdd, 0x83, 0xde, 0xbd, 0x8b, 0xeb, 0x42, 0x2d, 0x68, 0x12, 0x4b, 0x82, 0xfa, 0x10, 0xde,
+	0xe2, 0x86, 0x0e, 0x06, 0x3b, 0xd9, 0xcf, 0xe5, 0xd7, 0xb9, 0xdc, 0x1f, 0x0d, 0xda, 0xad, 0x8d,
+	0x37, 0x2a, 0x0f, 0xea, 0xfd, 0x90, 0x2a, 0x69, 0x84, 0x21, 0xfd, 0x59, 0xb3, 0xa6, 0x97, 0x3a,
+	0xfd, 0xf4, 0x20, 0x40, 0xf7, 0xbf, 0x25, 0x98, 0x36, 0xe9, 0x2f, 0xdf, 0x93, 0xbf, 0x07, 0x00,
+	0xa5, 0x41, 0x1d, 0x9c, 0x03, 0x0a, 0x00, 0x00,
 }
@@ -0,0 +1,229 @@
+// Code generated by protoc-gen-go.
+// source: protos/envoy/rls.proto
+// DO NOT EDIT!
+
+/*
+Package envoy_service_ratelimit_v3 is a generated protocol buffer package.
+
+It is generated from these files:
+	protos/envoy/rls.proto
+
+It has these top-level messages:
+	RateLimitDescriptor
+	RateLimitRequest
+	RateLimitResponse
+*/
+package envoy_service_ratelimit_v3
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type RateLimitResponse_Code int32
+
+const (
+	RateLimitResponse_UNKNOWN    RateLimitResponse_Code = 0
+	RateLimitResponse_OK         RateLimitResponse_Code = 1
+	RateLimitResponse_OVER_LIMIT RateLimitResponse_Code = 2
+)
+
+var RateLimitResponse_Code_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "OK",
+	2: "OVER_LIMIT",
+}
+var RateLimitResponse_Code_value = map[string]int32{
+	"UNKNOWN":    0,
+	"OK":         1,
+	"OVER_LIMIT": 2,
+}
+
+func (x RateLimitResponse_Code) String() string {
+	return proto.EnumName(RateLimitResponse_Code_name, int32(x))
+}
+func (RateLimitResponse_Code) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2, 0} }
+
+type RateLimitDescriptor struct {
+	Entries []*RateLimitDescriptor_Entry `protobuf:"bytes,1,rep,name=entries" json:"entries,omitempty"`
+}
+
+func (m *RateLimitDescriptor) Reset()                    { *m = RateLimitDescriptor{} }
+func (m *RateLimitDescriptor) String() string            { return proto.CompactTextString(m) }
+func (*RateLimitDescriptor) ProtoMessage()               {}
+func (*RateLimitDescriptor) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *RateLimitDescriptor) GetEntries() []*RateLimitDescriptor_Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+type RateLimitDescriptor_Entry struct {
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *RateLimitDescriptor_Entry) Reset()                    { *m = RateLimitDescriptor_Entry{} }
+func (m *RateLimitDescriptor_Entry) String() string            { return proto.CompactTextString(m) }
+func (*RateLimitDescriptor_Entry) ProtoMessage()               {}
+func (*RateLimitDescriptor_Entry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
+
+type RateLimitRequest struct {
+	Domain      string                 `protobuf:"bytes,1,opt,name=domain" json:"domain,omitempty"`
+	Descriptors []*RateLimitDescriptor `protobuf:"bytes,2,rep,name=descriptors" json:"descriptors,omitempty"`
+	// *
+	// Tokens each descriptor's bucket is asked for. Defaults to 1.
+	HitsAddend uint32 `protobuf:"varint,3,opt,name=hits_addend" json:"hits_addend,omitempty"`
+}
+
+func (m *RateLimitRequest) Reset()                    { *m = RateLimitRequest{} }
+func (m *RateLimitRequest) String() string            { return proto.CompactTextString(m) }
+func (*RateLimitRequest) ProtoMessage()               {}
+func (*RateLimitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *RateLimitRequest) GetDescriptors() []*RateLimitDescriptor {
+	if m != nil {
+		return m.Descriptors
+	}
+	return nil
+}
+
+type RateLimitResponse struct {
+	// *
+	// OVER_LIMIT if any descriptor is.
+	OverallCode RateLimitResponse_Code `protobuf:"varint,1,opt,name=overall_code,enum=envoy.service.ratelimit.v3.RateLimitResponse_Code" json:"overall_code,omitempty"`
+	// *
+	// Statuses of the request's descriptors, in the same order.
+	Statuses []*RateLimitResponse_DescriptorStatus `protobuf:"bytes,2,rep,name=statuses" json:"statuses,omitempty"`
+}
+
+func (m *RateLimitResponse) Reset()                    { *m = RateLimitResponse{} }
+func (m *RateLimitResponse) String() string            { return proto.CompactTextString(m) }
+func (*RateLimitResponse) ProtoMessage()               {}
+func (*RateLimitResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *RateLimitResponse) GetStatuses() []*RateLimitResponse_DescriptorStatus {
+	if m != nil {
+		return m.Statuses
+	}
+	return nil
+}
+
+type RateLimitResponse_DescriptorStatus struct {
+	Code RateLimitResponse_Code `protobuf:"varint,1,opt,name=code,enum=envoy.service.ratelimit.v3.RateLimitResponse_Code" json:"code,omitempty"`
+}
+
+func (m *RateLimitResponse_DescriptorStatus) Reset()         { *m = RateLimitResponse_DescriptorStatus{} }
+func (m *RateLimitResponse_DescriptorStatus) String() string { return proto.CompactTextString(m) }
+func (*RateLimitResponse_DescriptorStatus) ProtoMessage()    {}
+func (*RateLimitResponse_DescriptorStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{2, 0}
+}
+
+func init() {
+	proto.RegisterType((*RateLimitDescriptor)(nil), "envoy.service.ratelimit.v3.RateLimitDescriptor")
+	proto.RegisterType((*RateLimitDescriptor_Entry)(nil), "envoy.service.ratelimit.v3.RateLimitDescriptor.Entry")
+	proto.RegisterType((*RateLimitRequest)(nil), "envoy.service.ratelimit.v3.RateLimitRequest")
+	proto.RegisterType((*RateLimitResponse)(nil), "envoy.service.ratelimit.v3.RateLimitResponse")
+	proto.RegisterType((*RateLimitResponse_DescriptorStatus)(nil), "envoy.service.ratelimit.v3.RateLimitResponse.DescriptorStatus")
+	proto.RegisterEnum("envoy.service.ratelimit.v3.RateLimitResponse_Code", RateLimitResponse_Code_name, RateLimitResponse_Code_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Client API for RateLimitService service
+
+type RateLimitServiceClient interface {
+	ShouldRateLimit(ctx context.Context, in *RateLimitRequest, opts ...grpc.CallOption) (*RateLimitResponse, error)
+}
+
+type rateLimitServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRateLimitServiceClient(cc *grpc.ClientConn) RateLimitServiceClient {
+	return &rateLimitServiceClient{cc}
+}
+
+func (c *rateLimitServiceClient) ShouldRateLimit(ctx context.Context, in *RateLimitRequest, opts ...grpc.CallOption) (*RateLimitResponse, error) {
+	out := new(RateLimitResponse)
+	err := grpc.Invoke(ctx, "/envoy.service.ratelimit.v3.RateLimitService/ShouldRateLimit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for RateLimitService service
+
+type RateLimitServiceServer interface {
+	ShouldRateLimit(context.Context, *RateLimitRequest) (*RateLimitResponse, error)
+}
+
+func RegisterRateLimitServiceServer(s *grpc.Server, srv RateLimitServiceServer) {
+	s.RegisterService(&_RateLimitService_serviceDesc, srv)
+}
+
+func _RateLimitService_ShouldRateLimit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(RateLimitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RateLimitServiceServer).ShouldRateLimit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _RateLimitService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "envoy.service.ratelimit.v3.RateLimitService",
+	HandlerType: (*RateLimitServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ShouldRateLimit",
+			Handler:    _RateLimitService_ShouldRateLimit_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+var fileDescriptor0 = []byte{
+	// 357 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa4, 0x92, 0x4f, 0x6b, 0xe2, 0x40,
+	0x18, 0xc6, 0x4d, 0xfc, 0xb7, 0xfb, 0x66, 0x75, 0xb3, 0x23, 0x2c, 0x21, 0x27, 0xc9, 0x5e, 0x84,
+	0x6d, 0x47, 0x88, 0xf4, 0x5a, 0x0a, 0xd5, 0x52, 0xd1, 0x6a, 0x51, 0xdb, 0x1e, 0x25, 0x75, 0x5e,
+	0x30, 0x34, 0x66, 0xd2, 0x99, 0x49, 0xc0, 0x43, 0x2f, 0xa5, 0x9f, 0xa8, 0x9f, 0xb0, 0x38, 0xad,
+	0x56, 0x4a, 0x0b, 0x4a, 0x6f, 0x33, 0xcf, 0x30, 0xbf, 0xe7, 0x37, 0xbc, 0x03, 0x7f, 0x13, 0xc1,
+	0x15, 0x97, 0x4d, 0x8c, 0x33, 0xbe, 0x6c, 0x8a, 0x48, 0x52, 0x1d, 0x10, 0x57, 0x07, 0x54, 0xa2,
+	0xc8, 0xc2, 0x19, 0x52, 0x11, 0x28, 0x8c, 0xc2, 0x45, 0xa8, 0x68, 0xd6, 0xf2, 0x1e, 0x0d, 0xa8,
+	0x8d, 0x02, 0x85, 0xfd, 0x55, 0xd0, 0x46, 0x39, 0x13, 0x61, 0xa2, 0xb8, 0x20, 0x67, 0x50, 0xc6,
+	0x58, 0x89, 0x10, 0xa5, 0x63, 0xd4, 0xf3, 0x0d, 0xcb, 0x3f, 0xa2, 0x5f, 0x53, 0xe8, 0x27, 0x04,
+	0xda, 0x89, 0x95, 0x58, 0xba, 0xff, 0xa0, 0xa8, 0x17, 0xc4, 0x82, 0xfc, 0x1d, 0x2e, 0x1d, 0xa3,
+	0x6e, 0x34, 0x7e, 0x92, 0x0a, 0x14, 0xb3, 0x20, 0x4a, 0xd1, 0x31, 0x57, 0x5b, 0xef, 0x01, 0xec,
+	0x0d, 0x61, 0x84, 0xf7, 0x29, 0x4a, 0x45, 0xaa, 0x50, 0x62, 0x7c, 0x11, 0x84, 0xf1, 0xdb, 0x95,
+	0x36, 0x58, 0x6c, 0x03, 0x97, 0x8e, 0xa9, 0xa5, 0x9a, 0x7b, 0x4a, 0x91, 0x1a, 0x58, 0xf3, 0x50,
+	0xc9, 0x69, 0xc0, 0x18, 0xc6, 0xcc, 0xc9, 0xd7, 0x8d, 0x46, 0xc5, 0x7b, 0x36, 0xe1, 0xcf, 0x56,
+	0xbf, 0x4c, 0x78, 0x2c, 0x91, 0x9c, 0xc3, 0x2f, 0x9e, 0xa1, 0x08, 0xa2, 0x68, 0x3a, 0xe3, 0x0c,
+	0xb5, 0x46, 0xd5, 0xf7, 0x77, 0x6a, 0x5c, 0x43, 0xe8, 0x29, 0x67, 0x48, 0x2e, 0xe1, 0x87, 0x54,
+	0x81, 0x4a, 0x25, 0xae, 0xbd, 0x8f, 0xf7, 0xa3, 0xbc, 0x3f, 0x60, 0xac, 0x39, 0xee, 0x04, 0xec,
+	0x8f, 0x19, 0x39, 0x81, 0xc2, 0xf7, 0x3c, 0xbd, 0xff, 0x50, 0xd0, 0xbe, 0x16, 0x94, 0xaf, 0x06,
+	0xbd, 0xc1, 0xf0, 0x66, 0x60, 0xe7, 0x48, 0x09, 0xcc, 0x61, 0xcf, 0x36, 0x48, 0x15, 0x60, 0x78,
+	0xdd, 0x19, 0x4d, 0xfb, 0xdd, 0x8b, 0xee, 0xc4, 0x36, 0xfd, 0x27, 0x63, 0x6b, 0x68, 0xe3, 0xd7,
+	0x16, 0x92, 0xc0, 0xef, 0xf1, 0x9c, 0xa7, 0x11, 0xdb, 0x9c, 0x90, 0x83, 0x1d, 0x45, 0xf4, 0xd4,
+	0xdd, 0xc3, 0xbd, 0xb4, 0xbd, 0xdc, 0x6d, 0x49, 0x7f, 0xf1, 0xd6, 0xcb, 0x00, 0x07, 0x32, 0x57,
+	0xc3, 0xfc, 0x02, 0x00, 0x00,
+}
@@ -11,6 +11,16 @@ It is generated from these files:
 It has these top-level messages:
 	AllowRequest
 	AllowResponse
+	ReleaseRequest
+	ReleaseResponse
+	ReserveRequest
+	ReserveResponse
+	ReservationRequest
+	ReservationResponse
+	FeedbackRequest
+	FeedbackResponse
+	QueryRequest
+	QueryResponse
 */
 package quotaservice
 
@@ -38,6 +48,9 @@ const (
 	AllowResponse_REJECTED_TOO_MANY_TOKENS_REQUESTED AllowResponse_Status = 4
 	AllowResponse_REJECTED_INVALID_REQUEST           AllowResponse_Status = 5
 	AllowResponse_REJECTED_SERVER_ERROR              AllowResponse_Status = 6
+	AllowResponse_REJECTED_NOT_RESERVABLE            AllowResponse_Status = 7
+	AllowResponse_REJECTED_TOO_MANY_WAITERS          AllowResponse_Status = 8
+	AllowResponse_REJECTED_DENIED                    AllowResponse_Status = 9
 )
 
 var AllowResponse_Status_name = map[int32]string{
@@ -48,6 +61,9 @@ var AllowResponse_Status_name = map[int32]string{
 	4: "REJECTED_TOO_MANY_TOKENS_REQUESTED",
 	5: "REJECTED_INVALID_REQUEST",
 	6: "REJECTED_SERVER_ERROR",
+	7: "REJECTED_NOT_RESERVABLE",
+	8: "REJECTED_TOO_MANY_WAITERS",
+	9: "REJECTED_DENIED",
 }
 var AllowResponse_Status_value = map[string]int32{
 	"OK":                                 0,
@@ -57,6 +73,9 @@ var AllowResponse_Status_value = map[string]int32{
 	"REJECTED_TOO_MANY_TOKENS_REQUESTED": 4,
 	"REJECTED_INVALID_REQUEST":           5,
 	"REJECTED_SERVER_ERROR":              6,
+	"REJECTED_NOT_RESERVABLE":            7,
+	"REJECTED_TOO_MANY_WAITERS":          8,
+	"REJECTED_DENIED":                    9,
 }
 
 func (x AllowResponse_Status) String() string {
@@ -64,6 +83,123 @@ func (x AllowResponse_Status) String() string {
 }
 func (AllowResponse_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1, 0} }
 
+type ReleaseResponse_Status int32
+
+const (
+	ReleaseResponse_OK                       ReleaseResponse_Status = 0
+	ReleaseResponse_REJECTED_NO_BUCKET       ReleaseResponse_Status = 1
+	ReleaseResponse_REJECTED_NO_LEASE        ReleaseResponse_Status = 2
+	ReleaseResponse_REJECTED_INVALID_REQUEST ReleaseResponse_Status = 3
+	ReleaseResponse_REJECTED_SERVER_ERROR    ReleaseResponse_Status = 4
+)
+
+var ReleaseResponse_Status_name = map[int32]string{
+	0: "OK",
+	1: "REJECTED_NO_BUCKET",
+	2: "REJECTED_NO_LEASE",
+	3: "REJECTED_INVALID_REQUEST",
+	4: "REJECTED_SERVER_ERROR",
+}
+var ReleaseResponse_Status_value = map[string]int32{
+	"OK":                       0,
+	"REJECTED_NO_BUCKET":       1,
+	"REJECTED_NO_LEASE":        2,
+	"REJECTED_INVALID_REQUEST": 3,
+	"REJECTED_SERVER_ERROR":    4,
+}
+
+func (x ReleaseResponse_Status) String() string {
+	return proto.EnumName(ReleaseResponse_Status_name, int32(x))
+}
+func (ReleaseResponse_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3, 0} }
+
+type ReservationResponse_Status int32
+
+const (
+	ReservationResponse_OK                       ReservationResponse_Status = 0
+	ReservationResponse_REJECTED_NO_RESERVATION  ReservationResponse_Status = 1
+	ReservationResponse_REJECTED_INVALID_REQUEST ReservationResponse_Status = 2
+	ReservationResponse_REJECTED_SERVER_ERROR    ReservationResponse_Status = 3
+)
+
+var ReservationResponse_Status_name = map[int32]string{
+	0: "OK",
+	1: "REJECTED_NO_RESERVATION",
+	2: "REJECTED_INVALID_REQUEST",
+	3: "REJECTED_SERVER_ERROR",
+}
+var ReservationResponse_Status_value = map[string]int32{
+	"OK":                       0,
+	"REJECTED_NO_RESERVATION":  1,
+	"REJECTED_INVALID_REQUEST": 2,
+	"REJECTED_SERVER_ERROR":    3,
+}
+
+func (x ReservationResponse_Status) String() string {
+	return proto.EnumName(ReservationResponse_Status_name, int32(x))
+}
+func (ReservationResponse_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{7, 0} }
+
+type FeedbackResponse_Status int32
+
+const (
+	FeedbackResponse_OK                       FeedbackResponse_Status = 0
+	FeedbackResponse_REJECTED_NO_BUCKET       FeedbackResponse_Status = 1
+	FeedbackResponse_REJECTED_NOT_ADAPTIVE    FeedbackResponse_Status = 2
+	FeedbackResponse_REJECTED_INVALID_REQUEST FeedbackResponse_Status = 3
+	FeedbackResponse_REJECTED_SERVER_ERROR    FeedbackResponse_Status = 4
+)
+
+var FeedbackResponse_Status_name = map[int32]string{
+	0: "OK",
+	1: "REJECTED_NO_BUCKET",
+	2: "REJECTED_NOT_ADAPTIVE",
+	3: "REJECTED_INVALID_REQUEST",
+	4: "REJECTED_SERVER_ERROR",
+}
+var FeedbackResponse_Status_value = map[string]int32{
+	"OK":                       0,
+	"REJECTED_NO_BUCKET":       1,
+	"REJECTED_NOT_ADAPTIVE":    2,
+	"REJECTED_INVALID_REQUEST": 3,
+	"REJECTED_SERVER_ERROR":    4,
+}
+
+func (x FeedbackResponse_Status) String() string {
+	return proto.EnumName(FeedbackResponse_Status_name, int32(x))
+}
+func (FeedbackResponse_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{9, 0} }
+
+type QueryResponse_Status int32
+
+const (
+	QueryResponse_OK                        QueryResponse_Status = 0
+	QueryResponse_REJECTED_NO_BUCKET        QueryResponse_Status = 1
+	QueryResponse_REJECTED_TOO_MANY_BUCKETS QueryResponse_Status = 2
+	QueryResponse_REJECTED_INVALID_REQUEST  QueryResponse_Status = 3
+	QueryResponse_REJECTED_SERVER_ERROR     QueryResponse_Status = 4
+)
+
+var QueryResponse_Status_name = map[int32]string{
+	0: "OK",
+	1: "REJECTED_NO_BUCKET",
+	2: "REJECTED_TOO_MANY_BUCKETS",
+	3: "REJECTED_INVALID_REQUEST",
+	4: "REJECTED_SERVER_ERROR",
+}
+var QueryResponse_Status_value = map[string]int32{
+	"OK":                        0,
+	"REJECTED_NO_BUCKET":        1,
+	"REJECTED_TOO_MANY_BUCKETS": 2,
+	"REJECTED_INVALID_REQUEST":  3,
+	"REJECTED_SERVER_ERROR":     4,
+}
+
+func (x QueryResponse_Status) String() string {
+	return proto.EnumName(QueryResponse_Status_name, int32(x))
+}
+func (QueryResponse_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{11, 0} }
+
 type AllowRequest struct {
 	Namespace  string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
 	BucketName string `protobuf:"bytes,2,opt,name=bucket_name" json:"bucket_name,omitempty"`
@@ -73,6 +209,33 @@ type AllowRequest struct {
 	// *
 	// Max wait time, in millis. Defaults to 0, which assumes no waiting.
 	MaxWaitMillisOverride int64 `protobuf:"varint,4,opt,name=max_wait_millis_override" json:"max_wait_millis_override,omitempty"`
+	// *
+	// Fewest and most tokens to grant, if any amount in between will do. As many tokens are granted
+	// as are available, but no fewer than min_tokens. Used instead of tokens_requested when
+	// max_tokens is set, with min_tokens defaulting to 1.
+	MinTokens int64 `protobuf:"varint,5,opt,name=min_tokens" json:"min_tokens,omitempty"`
+	MaxTokens int64 `protobuf:"varint,6,opt,name=max_tokens" json:"max_tokens,omitempty"`
+	// *
+	// Operation the tokens are for. Tokens requested are multiplied by what the operation costs in
+	// the bucket's costs table, so tokens_granted counts priced tokens.
+	Operation string `protobuf:"bytes,7,opt,name=operation" json:"operation,omitempty"`
+	// *
+	// Most debt, in millis, buckets may lend the request, if lower than their max_debt_millis.
+	// Defaults to 0, which lends as much as the buckets allow.
+	MaxDebtMillisOverride int64 `protobuf:"varint,8,opt,name=max_debt_millis_override" json:"max_debt_millis_override,omitempty"`
+	// *
+	// Identifies the caller, such as by user or client ID, so that buckets with an
+	// enforcement_percent consistently enforce their limits on the same callers.
+	CallerId string `protobuf:"bytes,9,opt,name=caller_id" json:"caller_id,omitempty"`
+	// *
+	// Labels describing the caller, such as its service name or user ID, that events and grant
+	// records of the request carry, so that callers of a shared bucket can be told apart.
+	CallerLabels map[string]string `protobuf:"bytes,10,rep,name=caller_labels" json:"caller_labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// *
+	// Evaluate whether tokens would be granted, and how long the caller would wait for them, without
+	// claiming any. The response is the one the request would get, projected from the state of the
+	// bucket and its parents, though without a lease.
+	DryRun bool `protobuf:"varint,11,opt,name=dry_run" json:"dry_run,omitempty"`
 }
 
 func (m *AllowRequest) Reset()                    { *m = AllowRequest{} }
@@ -80,6 +243,13 @@ func (m *AllowRequest) String() string            { return proto.CompactTextStri
 func (*AllowRequest) ProtoMessage()               {}
 func (*AllowRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+func (m *AllowRequest) GetCallerLabels() map[string]string {
+	if m != nil {
+		return m.CallerLabels
+	}
+	return nil
+}
+
 type AllowResponse struct {
 	Status AllowResponse_Status `protobuf:"varint,1,opt,name=status,enum=quotaservice.AllowResponse_Status" json:"status,omitempty"`
 	// *
@@ -88,6 +258,20 @@ type AllowResponse struct {
 	// *
 	// Wait for this many millis before proceeding, if status == OK. 0 if no waiting is required.
 	WaitMillis int64 `protobuf:"varint,3,opt,name=wait_millis" json:"wait_millis,omitempty"`
+	// *
+	// Lease on the tokens granted by concurrency buckets, if status == OK. Pass it to Release once
+	// the tokens are no longer in use. Empty for other buckets.
+	LeaseId string `protobuf:"bytes,4,opt,name=lease_id" json:"lease_id,omitempty"`
+	// *
+	// Estimate of the tokens left in the bucket and its parents once the request was served, if
+	// status is OK, REJECTED_TIMEOUT or REJECTED_TOO_MANY_WAITERS. 0 if none of the buckets can
+	// report their tokens.
+	TokensRemaining int64 `protobuf:"varint,5,opt,name=tokens_remaining" json:"tokens_remaining,omitempty"`
+	// *
+	// Estimate of how many millis to wait before retrying, if status is REJECTED_TIMEOUT or
+	// REJECTED_TOO_MANY_WAITERS, projected from the rate the buckets free tokens at. 0 if no wait
+	// can be projected, as for concurrency buckets.
+	RetryAfterMillis int64 `protobuf:"varint,6,opt,name=retry_after_millis" json:"retry_after_millis,omitempty"`
 }
 
 func (m *AllowResponse) Reset()                    { *m = AllowResponse{} }
@@ -95,10 +279,158 @@ func (m *AllowResponse) String() string            { return proto.CompactTextStr
 func (*AllowResponse) ProtoMessage()               {}
 func (*AllowResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
 
+type ReleaseRequest struct {
+	Namespace  string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+	BucketName string `protobuf:"bytes,2,opt,name=bucket_name" json:"bucket_name,omitempty"`
+	LeaseId    string `protobuf:"bytes,3,opt,name=lease_id" json:"lease_id,omitempty"`
+}
+
+func (m *ReleaseRequest) Reset()                    { *m = ReleaseRequest{} }
+func (m *ReleaseRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReleaseRequest) ProtoMessage()               {}
+func (*ReleaseRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type ReleaseResponse struct {
+	Status ReleaseResponse_Status `protobuf:"varint,1,opt,name=status,enum=quotaservice.ReleaseResponse_Status" json:"status,omitempty"`
+}
+
+func (m *ReleaseResponse) Reset()                    { *m = ReleaseResponse{} }
+func (m *ReleaseResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReleaseResponse) ProtoMessage()               {}
+func (*ReleaseResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+type ReserveRequest struct {
+	Namespace  string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+	BucketName string `protobuf:"bytes,2,opt,name=bucket_name" json:"bucket_name,omitempty"`
+	// *
+	// Number of tokens to reserve. Defaults to 1, cannot be 0.
+	TokensRequested int64 `protobuf:"varint,3,opt,name=tokens_requested" json:"tokens_requested,omitempty"`
+	// *
+	// Max wait time, in millis. Defaults to 0, which assumes no waiting.
+	MaxWaitMillisOverride int64 `protobuf:"varint,4,opt,name=max_wait_millis_override" json:"max_wait_millis_override,omitempty"`
+	// *
+	// How long the reservation is held before its tokens are returned, in millis. Defaults to a minute.
+	TtlMillis int64 `protobuf:"varint,5,opt,name=ttl_millis" json:"ttl_millis,omitempty"`
+	// *
+	// Operation the tokens are for, priced as in AllowRequest.
+	Operation string `protobuf:"bytes,6,opt,name=operation" json:"operation,omitempty"`
+}
+
+func (m *ReserveRequest) Reset()                    { *m = ReserveRequest{} }
+func (m *ReserveRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReserveRequest) ProtoMessage()               {}
+func (*ReserveRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+type ReserveResponse struct {
+	Status AllowResponse_Status `protobuf:"varint,1,opt,name=status,enum=quotaservice.AllowResponse_Status" json:"status,omitempty"`
+	// *
+	// Number of tokens reserved, if status == OK
+	TokensReserved int64 `protobuf:"varint,2,opt,name=tokens_reserved" json:"tokens_reserved,omitempty"`
+	// *
+	// Wait for this many millis before using the tokens, if status == OK.
+	WaitMillis int64 `protobuf:"varint,3,opt,name=wait_millis" json:"wait_millis,omitempty"`
+	// *
+	// Pass to CommitReservation to consume the tokens, or CancelReservation to return them.
+	ReservationId string `protobuf:"bytes,4,opt,name=reservation_id" json:"reservation_id,omitempty"`
+}
+
+func (m *ReserveResponse) Reset()                    { *m = ReserveResponse{} }
+func (m *ReserveResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReserveResponse) ProtoMessage()               {}
+func (*ReserveResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type ReservationRequest struct {
+	ReservationId string `protobuf:"bytes,1,opt,name=reservation_id" json:"reservation_id,omitempty"`
+}
+
+func (m *ReservationRequest) Reset()                    { *m = ReservationRequest{} }
+func (m *ReservationRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReservationRequest) ProtoMessage()               {}
+func (*ReservationRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+type ReservationResponse struct {
+	Status ReservationResponse_Status `protobuf:"varint,1,opt,name=status,enum=quotaservice.ReservationResponse_Status" json:"status,omitempty"`
+}
+
+func (m *ReservationResponse) Reset()                    { *m = ReservationResponse{} }
+func (m *ReservationResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReservationResponse) ProtoMessage()               {}
+func (*ReservationResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+type FeedbackRequest struct {
+	Namespace  string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+	BucketName string `protobuf:"bytes,2,opt,name=bucket_name" json:"bucket_name,omitempty"`
+	// *
+	// Share of calls to the downstream the bucket protects that failed, from 0 to 1.
+	ErrorRate float64 `protobuf:"fixed64,3,opt,name=error_rate" json:"error_rate,omitempty"`
+	// *
+	// Latency of calls to the downstream, in millis.
+	LatencyMillis int64 `protobuf:"varint,4,opt,name=latency_millis" json:"latency_millis,omitempty"`
+}
+
+func (m *FeedbackRequest) Reset()                    { *m = FeedbackRequest{} }
+func (m *FeedbackRequest) String() string            { return proto.CompactTextString(m) }
+func (*FeedbackRequest) ProtoMessage()               {}
+func (*FeedbackRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+type FeedbackResponse struct {
+	Status FeedbackResponse_Status `protobuf:"varint,1,opt,name=status,enum=quotaservice.FeedbackResponse_Status" json:"status,omitempty"`
+	// *
+	// Tokens the bucket now adds per second, if status == OK.
+	FillRate int64 `protobuf:"varint,2,opt,name=fill_rate" json:"fill_rate,omitempty"`
+}
+
+func (m *FeedbackResponse) Reset()                    { *m = FeedbackResponse{} }
+func (m *FeedbackResponse) String() string            { return proto.CompactTextString(m) }
+func (*FeedbackResponse) ProtoMessage()               {}
+func (*FeedbackResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+type QueryRequest struct {
+	Namespace  string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+	BucketName string `protobuf:"bytes,2,opt,name=bucket_name" json:"bucket_name,omitempty"`
+	// *
+	// Tokens to project the wait for. Defaults to 1.
+	TokensRequested int64 `protobuf:"varint,3,opt,name=tokens_requested" json:"tokens_requested,omitempty"`
+}
+
+func (m *QueryRequest) Reset()                    { *m = QueryRequest{} }
+func (m *QueryRequest) String() string            { return proto.CompactTextString(m) }
+func (*QueryRequest) ProtoMessage()               {}
+func (*QueryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+type QueryResponse struct {
+	Status QueryResponse_Status `protobuf:"varint,1,opt,name=status,enum=quotaservice.QueryResponse_Status" json:"status,omitempty"`
+	// *
+	// Tokens the bucket and its parents have available, if status == OK. Nothing is claimed.
+	TokensAvailable int64 `protobuf:"varint,2,opt,name=tokens_available" json:"tokens_available,omitempty"`
+	// *
+	// How long a caller would wait for tokens_requested tokens, if status == OK.
+	WaitMillis int64 `protobuf:"varint,3,opt,name=wait_millis" json:"wait_millis,omitempty"`
+}
+
+func (m *QueryResponse) Reset()                    { *m = QueryResponse{} }
+func (m *QueryResponse) String() string            { return proto.CompactTextString(m) }
+func (*QueryResponse) ProtoMessage()               {}
+func (*QueryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
 func init() {
 	proto.RegisterType((*AllowRequest)(nil), "quotaservice.AllowRequest")
 	proto.RegisterType((*AllowResponse)(nil), "quotaservice.AllowResponse")
+	proto.RegisterType((*ReleaseRequest)(nil), "quotaservice.ReleaseRequest")
+	proto.RegisterType((*ReleaseResponse)(nil), "quotaservice.ReleaseResponse")
+	proto.RegisterType((*ReserveRequest)(nil), "quotaservice.ReserveRequest")
+	proto.RegisterType((*ReserveResponse)(nil), "quotaservice.ReserveResponse")
+	proto.RegisterType((*ReservationRequest)(nil), "quotaservice.ReservationRequest")
+	proto.RegisterType((*ReservationResponse)(nil), "quotaservice.ReservationResponse")
+	proto.RegisterType((*FeedbackRequest)(nil), "quotaservice.FeedbackRequest")
+	proto.RegisterType((*FeedbackResponse)(nil), "quotaservice.FeedbackResponse")
+	proto.RegisterType((*QueryRequest)(nil), "quotaservice.QueryRequest")
+	proto.RegisterType((*QueryResponse)(nil), "quotaservice.QueryResponse")
 	proto.RegisterEnum("quotaservice.AllowResponse_Status", AllowResponse_Status_name, AllowResponse_Status_value)
+	proto.RegisterEnum("quotaservice.ReleaseResponse_Status", ReleaseResponse_Status_name, ReleaseResponse_Status_value)
+	proto.RegisterEnum("quotaservice.ReservationResponse_Status", ReservationResponse_Status_name, ReservationResponse_Status_value)
+	proto.RegisterEnum("quotaservice.FeedbackResponse_Status", FeedbackResponse_Status_name, FeedbackResponse_Status_value)
+	proto.RegisterEnum("quotaservice.QueryResponse_Status", QueryResponse_Status_name, QueryResponse_Status_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -109,6 +441,13 @@ var _ grpc.ClientConn
 
 type QuotaServiceClient interface {
 	Allow(ctx context.Context, in *AllowRequest, opts ...grpc.CallOption) (*AllowResponse, error)
+	AllowStream(ctx context.Context, opts ...grpc.CallOption) (QuotaService_AllowStreamClient, error)
+	Release(ctx context.Context, in *ReleaseRequest, opts ...grpc.CallOption) (*ReleaseResponse, error)
+	Reserve(ctx context.Context, in *ReserveRequest, opts ...grpc.CallOption) (*ReserveResponse, error)
+	CommitReservation(ctx context.Context, in *ReservationRequest, opts ...grpc.CallOption) (*ReservationResponse, error)
+	CancelReservation(ctx context.Context, in *ReservationRequest, opts ...grpc.CallOption) (*ReservationResponse, error)
+	Feedback(ctx context.Context, in *FeedbackRequest, opts ...grpc.CallOption) (*FeedbackResponse, error)
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
 }
 
 type quotaServiceClient struct {
@@ -128,10 +467,102 @@ func (c *quotaServiceClient) Allow(ctx context.Context, in *AllowRequest, opts .
 	return out, nil
 }
 
+func (c *quotaServiceClient) AllowStream(ctx context.Context, opts ...grpc.CallOption) (QuotaService_AllowStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_QuotaService_serviceDesc.Streams[0], c.cc, "/quotaservice.QuotaService/AllowStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &quotaServiceAllowStreamClient{stream}
+	return x, nil
+}
+
+type QuotaService_AllowStreamClient interface {
+	Send(*AllowRequest) error
+	Recv() (*AllowResponse, error)
+	grpc.ClientStream
+}
+
+type quotaServiceAllowStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *quotaServiceAllowStreamClient) Send(m *AllowRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *quotaServiceAllowStreamClient) Recv() (*AllowResponse, error) {
+	m := new(AllowResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *quotaServiceClient) Release(ctx context.Context, in *ReleaseRequest, opts ...grpc.CallOption) (*ReleaseResponse, error) {
+	out := new(ReleaseResponse)
+	err := grpc.Invoke(ctx, "/quotaservice.QuotaService/Release", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quotaServiceClient) Reserve(ctx context.Context, in *ReserveRequest, opts ...grpc.CallOption) (*ReserveResponse, error) {
+	out := new(ReserveResponse)
+	err := grpc.Invoke(ctx, "/quotaservice.QuotaService/Reserve", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quotaServiceClient) CommitReservation(ctx context.Context, in *ReservationRequest, opts ...grpc.CallOption) (*ReservationResponse, error) {
+	out := new(ReservationResponse)
+	err := grpc.Invoke(ctx, "/quotaservice.QuotaService/CommitReservation", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quotaServiceClient) CancelReservation(ctx context.Context, in *ReservationRequest, opts ...grpc.CallOption) (*ReservationResponse, error) {
+	out := new(ReservationResponse)
+	err := grpc.Invoke(ctx, "/quotaservice.QuotaService/CancelReservation", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quotaServiceClient) Feedback(ctx context.Context, in *FeedbackRequest, opts ...grpc.CallOption) (*FeedbackResponse, error) {
+	out := new(FeedbackResponse)
+	err := grpc.Invoke(ctx, "/quotaservice.QuotaService/Feedback", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quotaServiceClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := grpc.Invoke(ctx, "/quotaservice.QuotaService/Query", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for QuotaService service
 
 type QuotaServiceServer interface {
 	Allow(context.Context, *AllowRequest) (*AllowResponse, error)
+	AllowStream(QuotaService_AllowStreamServer) error
+	Release(context.Context, *ReleaseRequest) (*ReleaseResponse, error)
+	Reserve(context.Context, *ReserveRequest) (*ReserveResponse, error)
+	CommitReservation(context.Context, *ReservationRequest) (*ReservationResponse, error)
+	CancelReservation(context.Context, *ReservationRequest) (*ReservationResponse, error)
+	Feedback(context.Context, *FeedbackRequest) (*FeedbackResponse, error)
+	Query(context.Context, *QueryRequest) (*QueryResponse, error)
 }
 
 func RegisterQuotaServiceServer(s *grpc.Server, srv QuotaServiceServer) {
@@ -150,6 +581,104 @@ func _QuotaService_Allow_Handler(srv interface{}, ctx context.Context, dec func(
 	return out, nil
 }
 
+func _QuotaService_AllowStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(QuotaServiceServer).AllowStream(&quotaServiceAllowStreamServer{stream})
+}
+
+type QuotaService_AllowStreamServer interface {
+	Send(*AllowResponse) error
+	Recv() (*AllowRequest, error)
+	grpc.ServerStream
+}
+
+type quotaServiceAllowStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *quotaServiceAllowStreamServer) Send(m *AllowResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *quotaServiceAllowStreamServer) Recv() (*AllowRequest, error) {
+	m := new(AllowRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _QuotaService_Release_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ReleaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QuotaServiceServer).Release(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _QuotaService_Reserve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ReserveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QuotaServiceServer).Reserve(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _QuotaService_CommitReservation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ReservationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QuotaServiceServer).CommitReservation(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _QuotaService_CancelReservation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ReservationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QuotaServiceServer).CancelReservation(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _QuotaService_Feedback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FeedbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QuotaServiceServer).Feedback(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _QuotaService_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QuotaServiceServer).Query(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _QuotaService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "quotaservice.QuotaService",
 	HandlerType: (*QuotaServiceServer)(nil),
@@ -158,33 +687,102 @@ var _QuotaService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Allow",
 			Handler:    _QuotaService_Allow_Handler,
 		},
+		{
+			MethodName: "Release",
+			Handler:    _QuotaService_Release_Handler,
+		},
+		{
+			MethodName: "Reserve",
+			Handler:    _QuotaService_Reserve_Handler,
+		},
+		{
+			MethodName: "CommitReservation",
+			Handler:    _QuotaService_CommitReservation_Handler,
+		},
+		{
+			MethodName: "CancelReservation",
+			Handler:    _QuotaService_CancelReservation_Handler,
+		},
+		{
+			MethodName: "Feedback",
+			Handler:    _QuotaService_Feedback_Handler,
+		},
+		{
+			MethodName: "Query",
+			Handler:    _QuotaService_Query_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AllowStream",
+			Handler:       _QuotaService_AllowStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams: []grpc.StreamDesc{},
 }
 
 var fileDescriptor0 = []byte{
-	// 365 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x7c, 0x92, 0xdd, 0x6e, 0xa2, 0x40,
-	0x1c, 0xc5, 0x05, 0x95, 0xc4, 0xff, 0xba, 0x66, 0x76, 0xf6, 0x23, 0xe8, 0xee, 0x26, 0x86, 0x8b,
-	0xcd, 0x5e, 0xd1, 0xc4, 0x3e, 0x81, 0x1f, 0x73, 0x61, 0xad, 0x10, 0x07, 0x34, 0xe9, 0xd5, 0x04,
-	0x75, 0xd2, 0x10, 0x11, 0x94, 0x19, 0xb4, 0xaf, 0xd5, 0x17, 0xe8, 0xb3, 0x15, 0x46, 0x6a, 0x6d,
-	0xda, 0xf4, 0xf6, 0xfc, 0xce, 0x9f, 0x73, 0x72, 0x18, 0xe8, 0xec, 0xd2, 0x44, 0x26, 0xe2, 0x6a,
-	0x9f, 0x25, 0x32, 0x60, 0x82, 0xa7, 0x87, 0x70, 0xc5, 0x6d, 0x25, 0xe2, 0xa6, 0x12, 0x4b, 0xcd,
-	0x4a, 0xa1, 0xd9, 0x8f, 0xa2, 0xe4, 0x48, 0xf9, 0x3e, 0xe3, 0x42, 0xe2, 0x6f, 0xd0, 0x88, 0x83,
-	0x2d, 0x17, 0xbb, 0x60, 0xc5, 0x4d, 0xad, 0xab, 0xfd, 0x6f, 0xe0, 0xef, 0xf0, 0x65, 0x99, 0xad,
-	0x36, 0x5c, 0xb2, 0x82, 0x98, 0xba, 0x12, 0x4d, 0x40, 0x32, 0xd9, 0xf0, 0x58, 0xb0, 0xf4, 0x74,
-	0xc9, 0xd7, 0x66, 0x35, 0x27, 0x55, 0xdc, 0x05, 0x73, 0x1b, 0x3c, 0xb0, 0x63, 0x10, 0x4a, 0xb6,
-	0x0d, 0xa3, 0x28, 0x14, 0x2c, 0x39, 0xf0, 0x34, 0x0d, 0xd7, 0xdc, 0xac, 0x15, 0x0e, 0xeb, 0x51,
-	0x87, 0xaf, 0x65, 0xa8, 0xd8, 0x25, 0xb1, 0xe0, 0xb8, 0x07, 0x86, 0x90, 0x81, 0xcc, 0x84, 0x8a,
-	0x6c, 0xf5, 0x2c, 0xfb, 0xb2, 0xa4, 0xfd, 0xc6, 0x6c, 0x7b, 0xca, 0x89, 0x7f, 0x41, 0xab, 0x6c,
-	0x70, 0x9f, 0x06, 0x71, 0x91, 0xaf, 0xab, 0xfc, 0xbc, 0xee, 0x45, 0xf6, 0xa9, 0x94, 0xf5, 0xa4,
-	0x81, 0x51, 0xde, 0x19, 0xa0, 0xbb, 0x13, 0x54, 0xc1, 0x3f, 0x00, 0x51, 0x72, 0x43, 0x86, 0x3e,
-	0x19, 0x31, 0x7f, 0x3c, 0x25, 0xee, 0xdc, 0x47, 0x5a, 0xfe, 0x55, 0x7c, 0x56, 0x1d, 0x97, 0x0d,
-	0xe6, 0xc3, 0x09, 0xf1, 0x91, 0x8e, 0xff, 0x42, 0xfb, 0xd5, 0xed, 0xba, 0x6c, 0xda, 0x77, 0xee,
-	0x4a, 0xea, 0xa1, 0x2a, 0xfe, 0x07, 0xd6, 0x7b, 0xec, 0xbb, 0x13, 0xe2, 0x78, 0x8c, 0x92, 0xd9,
-	0x9c, 0x78, 0x39, 0x41, 0x35, 0xfc, 0x07, 0xcc, 0xb3, 0x6f, 0xec, 0x2c, 0xfa, 0xb7, 0xe3, 0xd1,
-	0x0b, 0x47, 0x75, 0xdc, 0x86, 0x9f, 0x67, 0xea, 0x11, 0xba, 0x20, 0x94, 0x11, 0x4a, 0x5d, 0x8a,
-	0x8c, 0x1e, 0x85, 0xe6, 0xac, 0x98, 0xc4, 0x3b, 0x4d, 0x82, 0x07, 0x50, 0x57, 0xab, 0xe0, 0xce,
-	0x87, 0x53, 0xa9, 0x5f, 0xd2, 0xf9, 0xfd, 0xc9, 0x8c, 0x56, 0x65, 0x69, 0xa8, 0x07, 0x71, 0xfd,
-	0x1c, 0x00, 0x00, 0xff, 0xff, 0x73, 0x20, 0xfc, 0xb1, 0x2e, 0x02, 0x00, 0x00,
+	// 970 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xbc, 0x56, 0xcd, 0x6e, 0x23, 0x45,
+	0x10, 0xce, 0xd8, 0xb1, 0x63, 0x97, 0x13, 0x7b, 0xdc, 0x61, 0xb3, 0xb3, 0xce, 0x06, 0x99, 0x16,
+	0x20, 0x1f, 0x56, 0x06, 0x65, 0x41, 0x5a, 0x71, 0x73, 0xec, 0x46, 0x78, 0xe3, 0xb5, 0x77, 0xc7,
+	0x93, 0x20, 0xb8, 0x8c, 0xda, 0x76, 0x67, 0x35, 0xca, 0x78, 0x26, 0xdb, 0xd3, 0xce, 0xc6, 0x12,
+	0x07, 0xee, 0x1c, 0x78, 0x00, 0x1e, 0x84, 0x33, 0x57, 0x1e, 0x81, 0x77, 0xe0, 0xc8, 0x11, 0x09,
+	0x4d, 0x4f, 0x8f, 0x33, 0xfe, 0x25, 0x4b, 0x80, 0x6b, 0xd5, 0x57, 0x5f, 0x97, 0xbf, 0xaa, 0xaf,
+	0x3c, 0x50, 0xb9, 0xe2, 0xbe, 0xf0, 0x83, 0x4f, 0xde, 0x4c, 0x7c, 0x41, 0xed, 0x80, 0xf1, 0x6b,
+	0x67, 0xc8, 0xea, 0x32, 0x88, 0x76, 0x65, 0x50, 0xc5, 0xf0, 0xef, 0x29, 0xd8, 0x6d, 0xb8, 0xae,
+	0xff, 0xd6, 0x64, 0x6f, 0x26, 0x2c, 0x10, 0xa8, 0x0c, 0x79, 0x8f, 0x8e, 0x59, 0x70, 0x45, 0x87,
+	0xcc, 0xd0, 0xaa, 0x5a, 0x2d, 0x8f, 0xf6, 0xa1, 0x30, 0x98, 0x0c, 0x2f, 0x99, 0xb0, 0xc3, 0x8c,
+	0x91, 0x92, 0x41, 0x03, 0x74, 0xe1, 0x5f, 0x32, 0x2f, 0xb0, 0x79, 0x54, 0xc9, 0x46, 0x46, 0xba,
+	0xaa, 0xd5, 0xd2, 0xa8, 0x0a, 0xc6, 0x98, 0xde, 0xd8, 0x6f, 0xa9, 0x23, 0xec, 0xb1, 0xe3, 0xba,
+	0x4e, 0x60, 0xfb, 0xd7, 0x8c, 0x73, 0x67, 0xc4, 0x8c, 0x6d, 0x89, 0x40, 0x00, 0x63, 0xc7, 0xb3,
+	0xa3, 0x7a, 0x23, 0x33, 0x8b, 0xd1, 0x9b, 0x38, 0x96, 0x95, 0xb1, 0x32, 0xe4, 0xfd, 0x2b, 0xc6,
+	0xa9, 0x70, 0x7c, 0xcf, 0xd8, 0x91, 0xcf, 0x2a, 0xf2, 0x11, 0x1b, 0x2c, 0x93, 0xe7, 0xe2, 0xa2,
+	0x21, 0x75, 0x5d, 0xc6, 0x6d, 0x67, 0x64, 0xe4, 0x65, 0x51, 0x13, 0xf6, 0x54, 0xc8, 0xa5, 0x03,
+	0xe6, 0x06, 0x06, 0x54, 0xd3, 0xb5, 0xc2, 0xf1, 0x93, 0x7a, 0x52, 0x8a, 0x7a, 0x52, 0x86, 0x7a,
+	0x53, 0xe2, 0x3b, 0x12, 0x4e, 0x3c, 0xc1, 0xa7, 0xa8, 0x04, 0x3b, 0x23, 0x3e, 0xb5, 0xf9, 0xc4,
+	0x33, 0x0a, 0x55, 0xad, 0x96, 0xab, 0x3c, 0x85, 0xf2, 0x32, 0xaa, 0x00, 0xe9, 0x4b, 0x36, 0x55,
+	0xc2, 0xed, 0x41, 0xe6, 0x9a, 0xba, 0x13, 0x25, 0xd9, 0x17, 0xa9, 0x67, 0x1a, 0xfe, 0x35, 0x0d,
+	0x7b, 0xea, 0xa1, 0xe0, 0xca, 0xf7, 0x02, 0x86, 0x8e, 0x21, 0x1b, 0x08, 0x2a, 0x26, 0x81, 0x2c,
+	0x2a, 0x1e, 0xe3, 0x95, 0x5d, 0x45, 0xe0, 0x7a, 0x5f, 0x22, 0xd1, 0x01, 0x14, 0x95, 0xf8, 0xaf,
+	0x39, 0xf5, 0x42, 0xe9, 0x53, 0xf2, 0xb7, 0xef, 0x43, 0x21, 0x21, 0xbb, 0x9a, 0x87, 0x0e, 0x39,
+	0x97, 0xd1, 0x80, 0x85, 0x7a, 0x6c, 0x2f, 0xcd, 0x6e, 0x4c, 0x1d, 0xcf, 0xf1, 0x5e, 0xab, 0x29,
+	0x54, 0x00, 0x71, 0x26, 0xf8, 0xd4, 0xa6, 0x17, 0x82, 0xf1, 0x98, 0x47, 0x4e, 0x03, 0xff, 0x98,
+	0x82, 0xac, 0x7a, 0x3f, 0x0b, 0xa9, 0xde, 0xa9, 0xbe, 0x85, 0xde, 0x03, 0xdd, 0x24, 0xcf, 0x49,
+	0xd3, 0x22, 0x2d, 0xdb, 0x6a, 0xbf, 0x20, 0xbd, 0x33, 0x4b, 0xd7, 0xd0, 0x01, 0xa0, 0x59, 0xb4,
+	0xdb, 0xb3, 0x4f, 0xce, 0x9a, 0xa7, 0xc4, 0xd2, 0x53, 0xe8, 0x08, 0x1e, 0xdd, 0xa2, 0x7b, 0x3d,
+	0xfb, 0x45, 0xa3, 0xfb, 0x8d, 0xca, 0xf6, 0xf5, 0x34, 0xfa, 0x18, 0xf0, 0x72, 0xda, 0xea, 0x9d,
+	0x92, 0x6e, 0xdf, 0x36, 0xc9, 0xab, 0x33, 0xd2, 0xb7, 0x48, 0x4b, 0xdf, 0x46, 0x8f, 0xc1, 0x98,
+	0xe1, 0xda, 0xdd, 0xf3, 0x46, 0xa7, 0xdd, 0x8a, 0xf3, 0x7a, 0x06, 0x3d, 0x82, 0x07, 0xb3, 0x6c,
+	0x9f, 0x98, 0xe7, 0xc4, 0xb4, 0x89, 0x69, 0xf6, 0x4c, 0x3d, 0x8b, 0x0e, 0xe1, 0x61, 0xa2, 0x2f,
+	0xcb, 0x36, 0x49, 0x08, 0x68, 0x9c, 0x74, 0x88, 0xbe, 0xb3, 0xba, 0xb9, 0xaf, 0x1b, 0x6d, 0x8b,
+	0x98, 0x7d, 0x3d, 0x87, 0xf6, 0xa1, 0x34, 0x4b, 0xb7, 0x48, 0xb7, 0x4d, 0x5a, 0x7a, 0x1e, 0x3f,
+	0x87, 0xa2, 0xc9, 0xa4, 0xb6, 0xef, 0xea, 0x9e, 0xe4, 0x4c, 0xc2, 0x29, 0xe5, 0xf1, 0x2f, 0x1a,
+	0x94, 0x66, 0x64, 0x6a, 0x35, 0x3e, 0x5b, 0x58, 0x8d, 0x0f, 0xe7, 0x57, 0x63, 0x01, 0xae, 0x96,
+	0x03, 0xdf, 0x2c, 0x8d, 0x69, 0xf5, 0x40, 0x34, 0xf4, 0x00, 0xca, 0xc9, 0x78, 0x87, 0x34, 0xfa,
+	0x44, 0x4f, 0x6d, 0x14, 0x38, 0xbd, 0x5e, 0xe0, 0x6d, 0xfc, 0x93, 0x16, 0x0a, 0x12, 0xb6, 0xc7,
+	0xfe, 0xe7, 0x73, 0x22, 0x84, 0x1b, 0x2f, 0x6b, 0x66, 0xf9, 0x74, 0x64, 0xa5, 0xc2, 0x3f, 0x48,
+	0x85, 0x55, 0x77, 0xf7, 0x30, 0xdf, 0x43, 0x28, 0xcd, 0x5a, 0x95, 0x6c, 0x1b, 0xdd, 0x77, 0x00,
+	0xc5, 0x08, 0x26, 0x5b, 0x99, 0x79, 0x10, 0x3f, 0x01, 0x64, 0xde, 0xc6, 0x63, 0xb9, 0x96, 0xd1,
+	0x52, 0x33, 0xfc, 0xb3, 0x06, 0xfb, 0x73, 0x70, 0xd5, 0xff, 0xb3, 0x85, 0xfe, 0x6b, 0x8b, 0x1b,
+	0xb2, 0x54, 0x12, 0x6f, 0xc9, 0xc5, 0xd2, 0x96, 0xcc, 0xdb, 0x23, 0x76, 0x87, 0xd5, 0xee, 0x75,
+	0x75, 0x6d, 0xe3, 0x4e, 0xa4, 0xd6, 0xef, 0x44, 0x1a, 0x33, 0x28, 0x7d, 0xc9, 0xd8, 0x68, 0x40,
+	0x87, 0x97, 0xef, 0xba, 0x13, 0x08, 0x80, 0x71, 0xee, 0x73, 0x9b, 0x53, 0xc1, 0xa4, 0x9c, 0xe1,
+	0x6d, 0x29, 0xba, 0x54, 0x30, 0x6f, 0x38, 0x8d, 0x65, 0x96, 0x3b, 0x80, 0x7f, 0xd3, 0x40, 0xbf,
+	0x7d, 0x47, 0xa9, 0xf3, 0xf9, 0x82, 0x3a, 0x1f, 0xcd, 0xab, 0xb3, 0x88, 0x8f, 0x07, 0x5c, 0x86,
+	0xfc, 0x85, 0xe3, 0xba, 0xd1, 0xb3, 0x72, 0xb4, 0xf8, 0xbb, 0x3b, 0x7b, 0x2a, 0x29, 0x45, 0x78,
+	0x64, 0x1a, 0xad, 0xc6, 0x4b, 0xab, 0x7d, 0x7e, 0x2f, 0x5f, 0xbd, 0x84, 0xdd, 0x57, 0x13, 0xc6,
+	0xa7, 0xff, 0x9a, 0xa9, 0xf0, 0x1f, 0x1a, 0xec, 0x29, 0xca, 0xbb, 0x39, 0x61, 0x0e, 0x1c, 0x0b,
+	0x75, 0xcb, 0x4f, 0xaf, 0xa9, 0xe3, 0xd2, 0x81, 0xcb, 0x36, 0x58, 0x01, 0x7f, 0xaf, 0xdd, 0x59,
+	0xc5, 0x8d, 0x7f, 0x15, 0xff, 0x5c, 0xc9, 0xe3, 0x3f, 0xb7, 0x43, 0x29, 0x7d, 0x41, 0xfb, 0xd1,
+	0xef, 0x42, 0x27, 0x90, 0x91, 0x26, 0x47, 0x95, 0xf5, 0x1f, 0x03, 0x95, 0xc3, 0x0d, 0x57, 0x01,
+	0x6f, 0xa1, 0x0e, 0x14, 0x64, 0xa8, 0x2f, 0x38, 0xa3, 0xe3, 0x7b, 0x30, 0xd5, 0xb4, 0x4f, 0x35,
+	0xf4, 0x15, 0xec, 0xa8, 0xc3, 0x8e, 0x1e, 0xaf, 0xb9, 0xf7, 0x11, 0xd7, 0xd1, 0xc6, 0x7f, 0x03,
+	0xbc, 0x15, 0x31, 0x85, 0xe9, 0x15, 0x4c, 0xc9, 0x23, 0x5d, 0x39, 0x5a, 0x93, 0x9d, 0x31, 0x7d,
+	0x0b, 0xe5, 0xa6, 0x3f, 0x1e, 0x3b, 0x22, 0x71, 0x50, 0x50, 0x75, 0xc3, 0xad, 0x89, 0x78, 0x3f,
+	0xf8, 0xdb, 0x6b, 0xa4, 0xb8, 0xa9, 0x37, 0x64, 0xee, 0x7f, 0xc0, 0x7d, 0x0a, 0xb9, 0xd8, 0xe4,
+	0xe8, 0x68, 0x9d, 0xf9, 0x23, 0xbe, 0xf7, 0x37, 0xdf, 0x06, 0xbc, 0x15, 0xae, 0x8a, 0x74, 0xc1,
+	0xe2, 0x80, 0x93, 0xd6, 0xac, 0x1c, 0xae, 0xcc, 0xc5, 0x1c, 0x83, 0xac, 0xfc, 0x06, 0x7f, 0xfa,
+	0xd7, 0x00, 0x06, 0xc4, 0x40, 0xc7, 0xa1, 0x0b, 0x00, 0x00,
 }
@@ -0,0 +1,321 @@
+// Code generated by protoc-gen-go.
+// source: protos/reflection/reflection.proto
+// DO NOT EDIT!
+
+/*
+Package grpc_reflection_v1alpha is a generated protocol buffer package.
+
+It is generated from these files:
+
+	protos/reflection/reflection.proto
+
+It has these top-level messages:
+
+	ServerReflectionRequest
+	ExtensionRequest
+	ServerReflectionResponse
+	FileDescriptorResponse
+	ExtensionNumberResponse
+	ListServiceResponse
+	ServiceResponse
+	ErrorResponse
+*/
+package grpc_reflection_v1alpha
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type ServerReflectionRequest struct {
+	Host string `protobuf:"bytes,1,opt,name=host" json:"host,omitempty"`
+	// oneof message_request
+	FileByFilename            string            `protobuf:"bytes,3,opt,name=file_by_filename" json:"file_by_filename,omitempty"`
+	FileContainingSymbol      string            `protobuf:"bytes,4,opt,name=file_containing_symbol" json:"file_containing_symbol,omitempty"`
+	FileContainingExtension   *ExtensionRequest `protobuf:"bytes,5,opt,name=file_containing_extension" json:"file_containing_extension,omitempty"`
+	AllExtensionNumbersOfType string            `protobuf:"bytes,6,opt,name=all_extension_numbers_of_type" json:"all_extension_numbers_of_type,omitempty"`
+	ListServices              string            `protobuf:"bytes,7,opt,name=list_services" json:"list_services,omitempty"`
+}
+
+func (m *ServerReflectionRequest) Reset()                    { *m = ServerReflectionRequest{} }
+func (m *ServerReflectionRequest) String() string            { return proto.CompactTextString(m) }
+func (*ServerReflectionRequest) ProtoMessage()               {}
+func (*ServerReflectionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *ServerReflectionRequest) GetFileContainingExtension() *ExtensionRequest {
+	if m != nil {
+		return m.FileContainingExtension
+	}
+	return nil
+}
+
+type ExtensionRequest struct {
+	ContainingType  string `protobuf:"bytes,1,opt,name=containing_type" json:"containing_type,omitempty"`
+	ExtensionNumber int32  `protobuf:"varint,2,opt,name=extension_number" json:"extension_number,omitempty"`
+}
+
+func (m *ExtensionRequest) Reset()                    { *m = ExtensionRequest{} }
+func (m *ExtensionRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExtensionRequest) ProtoMessage()               {}
+func (*ExtensionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+type ServerReflectionResponse struct {
+	ValidHost       string                   `protobuf:"bytes,1,opt,name=valid_host" json:"valid_host,omitempty"`
+	OriginalRequest *ServerReflectionRequest `protobuf:"bytes,2,opt,name=original_request" json:"original_request,omitempty"`
+	// oneof message_response
+	FileDescriptorResponse      *FileDescriptorResponse  `protobuf:"bytes,4,opt,name=file_descriptor_response" json:"file_descriptor_response,omitempty"`
+	AllExtensionNumbersResponse *ExtensionNumberResponse `protobuf:"bytes,5,opt,name=all_extension_numbers_response" json:"all_extension_numbers_response,omitempty"`
+	ListServicesResponse        *ListServiceResponse     `protobuf:"bytes,6,opt,name=list_services_response" json:"list_services_response,omitempty"`
+	ErrorResponse               *ErrorResponse           `protobuf:"bytes,7,opt,name=error_response" json:"error_response,omitempty"`
+}
+
+func (m *ServerReflectionResponse) Reset()                    { *m = ServerReflectionResponse{} }
+func (m *ServerReflectionResponse) String() string            { return proto.CompactTextString(m) }
+func (*ServerReflectionResponse) ProtoMessage()               {}
+func (*ServerReflectionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *ServerReflectionResponse) GetOriginalRequest() *ServerReflectionRequest {
+	if m != nil {
+		return m.OriginalRequest
+	}
+	return nil
+}
+
+func (m *ServerReflectionResponse) GetFileDescriptorResponse() *FileDescriptorResponse {
+	if m != nil {
+		return m.FileDescriptorResponse
+	}
+	return nil
+}
+
+func (m *ServerReflectionResponse) GetAllExtensionNumbersResponse() *ExtensionNumberResponse {
+	if m != nil {
+		return m.AllExtensionNumbersResponse
+	}
+	return nil
+}
+
+func (m *ServerReflectionResponse) GetListServicesResponse() *ListServiceResponse {
+	if m != nil {
+		return m.ListServicesResponse
+	}
+	return nil
+}
+
+func (m *ServerReflectionResponse) GetErrorResponse() *ErrorResponse {
+	if m != nil {
+		return m.ErrorResponse
+	}
+	return nil
+}
+
+type FileDescriptorResponse struct {
+	// *
+	// Serialized FileDescriptorProtos of the file requested and the files it imports.
+	FileDescriptorProto [][]byte `protobuf:"bytes,1,rep,name=file_descriptor_proto,proto3" json:"file_descriptor_proto,omitempty"`
+}
+
+func (m *FileDescriptorResponse) Reset()                    { *m = FileDescriptorResponse{} }
+func (m *FileDescriptorResponse) String() string            { return proto.CompactTextString(m) }
+func (*FileDescriptorResponse) ProtoMessage()               {}
+func (*FileDescriptorResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+type ExtensionNumberResponse struct {
+	BaseTypeName    string  `protobuf:"bytes,1,opt,name=base_type_name" json:"base_type_name,omitempty"`
+	ExtensionNumber []int32 `protobuf:"varint,2,rep,packed,name=extension_number" json:"extension_number,omitempty"`
+}
+
+func (m *ExtensionNumberResponse) Reset()                    { *m = ExtensionNumberResponse{} }
+func (m *ExtensionNumberResponse) String() string            { return proto.CompactTextString(m) }
+func (*ExtensionNumberResponse) ProtoMessage()               {}
+func (*ExtensionNumberResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+type ListServiceResponse struct {
+	Service []*ServiceResponse `protobuf:"bytes,1,rep,name=service" json:"service,omitempty"`
+}
+
+func (m *ListServiceResponse) Reset()                    { *m = ListServiceResponse{} }
+func (m *ListServiceResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListServiceResponse) ProtoMessage()               {}
+func (*ListServiceResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *ListServiceResponse) GetService() []*ServiceResponse {
+	if m != nil {
+		return m.Service
+	}
+	return nil
+}
+
+type ServiceResponse struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *ServiceResponse) Reset()                    { *m = ServiceResponse{} }
+func (m *ServiceResponse) String() string            { return proto.CompactTextString(m) }
+func (*ServiceResponse) ProtoMessage()               {}
+func (*ServiceResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+type ErrorResponse struct {
+	ErrorCode    int32  `protobuf:"varint,1,opt,name=error_code" json:"error_code,omitempty"`
+	ErrorMessage string `protobuf:"bytes,2,opt,name=error_message" json:"error_message,omitempty"`
+}
+
+func (m *ErrorResponse) Reset()                    { *m = ErrorResponse{} }
+func (m *ErrorResponse) String() string            { return proto.CompactTextString(m) }
+func (*ErrorResponse) ProtoMessage()               {}
+func (*ErrorResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func init() {
+	proto.RegisterType((*ServerReflectionRequest)(nil), "grpc.reflection.v1alpha.ServerReflectionRequest")
+	proto.RegisterType((*ExtensionRequest)(nil), "grpc.reflection.v1alpha.ExtensionRequest")
+	proto.RegisterType((*ServerReflectionResponse)(nil), "grpc.reflection.v1alpha.ServerReflectionResponse")
+	proto.RegisterType((*FileDescriptorResponse)(nil), "grpc.reflection.v1alpha.FileDescriptorResponse")
+	proto.RegisterType((*ExtensionNumberResponse)(nil), "grpc.reflection.v1alpha.ExtensionNumberResponse")
+	proto.RegisterType((*ListServiceResponse)(nil), "grpc.reflection.v1alpha.ListServiceResponse")
+	proto.RegisterType((*ServiceResponse)(nil), "grpc.reflection.v1alpha.ServiceResponse")
+	proto.RegisterType((*ErrorResponse)(nil), "grpc.reflection.v1alpha.ErrorResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Client API for ServerReflection service
+
+type ServerReflectionClient interface {
+	ServerReflectionInfo(ctx context.Context, opts ...grpc.CallOption) (ServerReflection_ServerReflectionInfoClient, error)
+}
+
+type serverReflectionClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewServerReflectionClient(cc *grpc.ClientConn) ServerReflectionClient {
+	return &serverReflectionClient{cc}
+}
+
+func (c *serverReflectionClient) ServerReflectionInfo(ctx context.Context, opts ...grpc.CallOption) (ServerReflection_ServerReflectionInfoClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_ServerReflection_serviceDesc.Streams[0], c.cc, "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serverReflectionServerReflectionInfoClient{stream}
+	return x, nil
+}
+
+type ServerReflection_ServerReflectionInfoClient interface {
+	Send(*ServerReflectionRequest) error
+	Recv() (*ServerReflectionResponse, error)
+	grpc.ClientStream
+}
+
+type serverReflectionServerReflectionInfoClient struct {
+	grpc.ClientStream
+}
+
+func (x *serverReflectionServerReflectionInfoClient) Send(m *ServerReflectionRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *serverReflectionServerReflectionInfoClient) Recv() (*ServerReflectionResponse, error) {
+	m := new(ServerReflectionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for ServerReflection service
+
+type ServerReflectionServer interface {
+	ServerReflectionInfo(ServerReflection_ServerReflectionInfoServer) error
+}
+
+func RegisterServerReflectionServer(s *grpc.Server, srv ServerReflectionServer) {
+	s.RegisterService(&_ServerReflection_serviceDesc, srv)
+}
+
+func _ServerReflection_ServerReflectionInfo_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ServerReflectionServer).ServerReflectionInfo(&serverReflectionServerReflectionInfoServer{stream})
+}
+
+type ServerReflection_ServerReflectionInfoServer interface {
+	Send(*ServerReflectionResponse) error
+	Recv() (*ServerReflectionRequest, error)
+	grpc.ServerStream
+}
+
+type serverReflectionServerReflectionInfoServer struct {
+	grpc.ServerStream
+}
+
+func (x *serverReflectionServerReflectionInfoServer) Send(m *ServerReflectionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *serverReflectionServerReflectionInfoServer) Recv() (*ServerReflectionRequest, error) {
+	m := new(ServerReflectionRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _ServerReflection_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc.reflection.v1alpha.ServerReflection",
+	HandlerType: (*ServerReflectionServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ServerReflectionInfo",
+			Handler:       _ServerReflection_ServerReflectionInfo_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+var fileDescriptor0 = []byte{
+	// 511 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x93, 0xcf, 0x6e, 0xd3, 0x4e,
+	0x10, 0xc7, 0x7f, 0xfb, 0xcb, 0x3f, 0x75, 0x92, 0xb6, 0x91, 0xa1, 0xc9, 0x82, 0xd4, 0x12, 0x59,
+	0x02, 0x19, 0x09, 0xa5, 0xad, 0x39, 0x20, 0x38, 0x70, 0xa2, 0x48, 0x40, 0x84, 0xa0, 0xbd, 0x70,
+	0x5b, 0xd9, 0xce, 0x24, 0x5d, 0x69, 0xb3, 0x6b, 0x76, 0xb7, 0x11, 0x39, 0xf2, 0x12, 0xbc, 0x09,
+	0x8f, 0xc5, 0x3b, 0x20, 0xaf, 0x13, 0xc7, 0x75, 0xeb, 0xa2, 0x9e, 0x6c, 0xcd, 0xec, 0x7c, 0x66,
+	0xe6, 0xbb, 0xdf, 0x05, 0x3f, 0xd5, 0xca, 0x2a, 0x73, 0xac, 0x71, 0x26, 0x30, 0xb1, 0x5c, 0xc9,
+	0xd2, 0xef, 0xd8, 0x25, 0xbd, 0xe1, 0x5c, 0xa7, 0xc9, 0xb8, 0x14, 0x5e, 0x9e, 0x46, 0x22, 0xbd,
+	0x8c, 0xfc, 0x3f, 0x04, 0x86, 0x17, 0xa8, 0x97, 0xa8, 0xcf, 0x8b, 0xe4, 0x39, 0x7e, 0xbf, 0x42,
+	0x63, 0xbd, 0x1e, 0x34, 0x2f, 0x95, 0xb1, 0x94, 0x8c, 0x48, 0xb0, 0xe3, 0x51, 0xe8, 0xcf, 0xb8,
+	0x40, 0x16, 0xaf, 0x58, 0xf6, 0x95, 0xd1, 0x02, 0x69, 0xc3, 0x65, 0x8e, 0x60, 0xe0, 0x32, 0x89,
+	0x92, 0x36, 0xe2, 0x92, 0xcb, 0x39, 0x33, 0xab, 0x45, 0xac, 0x04, 0x6d, 0xba, 0xfc, 0x04, 0x1e,
+	0x55, 0xf3, 0xf8, 0xc3, 0xa2, 0x34, 0x5c, 0x49, 0xda, 0x1a, 0x91, 0xa0, 0x1b, 0x3e, 0x1f, 0xd7,
+	0x0c, 0x38, 0x3e, 0xdb, 0x9c, 0xdc, 0x4c, 0xf5, 0x14, 0x0e, 0x23, 0x21, 0xb6, 0x04, 0x26, 0xaf,
+	0x16, 0x31, 0x6a, 0xc3, 0xd4, 0x8c, 0xd9, 0x55, 0x8a, 0xb4, 0xed, 0x9a, 0x1e, 0xc0, 0xae, 0xe0,
+	0xc6, 0x32, 0x83, 0x7a, 0xc9, 0x13, 0x34, 0xb4, 0x93, 0x85, 0xfd, 0x33, 0xe8, 0xdf, 0x20, 0x0e,
+	0x61, 0xbf, 0x34, 0x9a, 0x63, 0x14, 0x2b, 0x57, 0xdb, 0xd0, 0xff, 0x47, 0x24, 0x68, 0xf9, 0xbf,
+	0x1b, 0x40, 0x6f, 0xca, 0x66, 0x52, 0x25, 0x0d, 0x7a, 0x1e, 0xc0, 0x32, 0x12, 0x7c, 0xca, 0x4a,
+	0xea, 0x7d, 0x84, 0xbe, 0xd2, 0x7c, 0xce, 0x65, 0x24, 0x98, 0xce, 0xfb, 0x3a, 0x54, 0x37, 0x3c,
+	0xa9, 0x5d, 0xbd, 0xee, 0x5e, 0xbe, 0x02, 0x75, 0x7a, 0x4e, 0xd1, 0x24, 0x9a, 0xa7, 0x56, 0x69,
+	0xa6, 0xd7, 0xbd, 0x9d, 0xe2, 0xdd, 0xf0, 0xb8, 0x96, 0xf9, 0x9e, 0x0b, 0x7c, 0x57, 0xd4, 0x15,
+	0x23, 0x7f, 0x83, 0xa3, 0xdb, 0x45, 0x2d, 0xc0, 0xad, 0x7f, 0x0c, 0x5b, 0xa8, 0xfa, 0xd9, 0x55,
+	0x16, 0xe4, 0x09, 0x0c, 0xae, 0xdd, 0xc3, 0x96, 0xd8, 0x76, 0xc4, 0x17, 0xb5, 0xc4, 0x09, 0x37,
+	0xf6, 0x22, 0xaf, 0x2a, 0x68, 0x6f, 0x61, 0x0f, 0xb5, 0x2e, 0x2f, 0xdc, 0x71, 0x94, 0x67, 0xf5,
+	0x73, 0x65, 0xc7, 0x37, 0xf5, 0xfe, 0x2b, 0x18, 0xd4, 0x28, 0x70, 0x08, 0x07, 0x55, 0x51, 0xdd,
+	0xd3, 0xa1, 0x64, 0xd4, 0x08, 0x7a, 0xfe, 0x27, 0x18, 0xd6, 0x6d, 0x38, 0x80, 0xbd, 0x38, 0x32,
+	0xe8, 0x8c, 0xc3, 0xdc, 0xb3, 0xb8, 0xcb, 0x3d, 0x8d, 0xa0, 0xe5, 0x7f, 0x81, 0x07, 0xb7, 0x2d,
+	0xf7, 0x1a, 0x3a, 0x6b, 0x95, 0x5c, 0xd3, 0x6e, 0x18, 0xdc, 0x69, 0x8d, 0x52, 0xa9, 0xff, 0x04,
+	0xf6, 0xab, 0xb4, 0x1e, 0x34, 0xb7, 0xc3, 0xf8, 0x6f, 0x60, 0xf7, 0x9a, 0x12, 0x99, 0x49, 0x73,
+	0x25, 0x13, 0x35, 0xcd, 0x0f, 0xb5, 0xb2, 0x37, 0x93, 0xc7, 0x16, 0x68, 0x4c, 0x34, 0x47, 0xe7,
+	0xd0, 0x9d, 0xf0, 0x17, 0x81, 0x7e, 0xd5, 0x8b, 0xde, 0x4f, 0x02, 0x0f, 0xab, 0xc1, 0x0f, 0x72,
+	0xa6, 0xbc, 0x7b, 0xfb, 0xf9, 0xf1, 0xe9, 0x3d, 0x2a, 0xd6, 0xfb, 0xfe, 0x17, 0x90, 0x13, 0x12,
+	0xb7, 0xdd, 0x0d, 0xbd, 0xfc, 0x3b, 0x00, 0x03, 0x67, 0x00, 0x26, 0x02, 0x05, 0x00, 0x00,
+}
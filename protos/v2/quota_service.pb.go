@@ -0,0 +1,415 @@
+// Code generated by protoc-gen-go.
+// source: protos/v2/quota_service.proto
+// DO NOT EDIT!
+
+/*
+Package quotaservice_v2 is a generated protocol buffer package.
+
+It is generated from these files:
+
+	protos/v2/quota_service.proto
+
+It has these top-level messages:
+
+	Caller
+	AllowRequest
+	Entry
+	AllowResponse
+	EntryResult
+	DenyReason
+*/
+package quotaservice_v2
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type Priority int32
+
+const (
+	Priority_PRIORITY_NORMAL Priority = 0
+	Priority_PRIORITY_HIGH   Priority = 1
+)
+
+var Priority_name = map[int32]string{
+	0: "PRIORITY_NORMAL",
+	1: "PRIORITY_HIGH",
+}
+var Priority_value = map[string]int32{
+	"PRIORITY_NORMAL": 0,
+	"PRIORITY_HIGH":   1,
+}
+
+func (x Priority) String() string {
+	return proto.EnumName(Priority_name, int32(x))
+}
+func (Priority) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type EntryResult_Status int32
+
+const (
+	EntryResult_OK       EntryResult_Status = 0
+	EntryResult_REJECTED EntryResult_Status = 1
+)
+
+var EntryResult_Status_name = map[int32]string{
+	0: "OK",
+	1: "REJECTED",
+}
+var EntryResult_Status_value = map[string]int32{
+	"OK":       0,
+	"REJECTED": 1,
+}
+
+func (x EntryResult_Status) String() string {
+	return proto.EnumName(EntryResult_Status_name, int32(x))
+}
+func (EntryResult_Status) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{4, 0} }
+
+// *
+// Numbered as v1's AllowResponse.Status. Clients should treat codes they don't know as
+// SERVER_ERROR, as newer servers may add codes.
+type DenyReason_Code int32
+
+const (
+	DenyReason_UNKNOWN                   DenyReason_Code = 0
+	DenyReason_TIMEOUT                   DenyReason_Code = 1
+	DenyReason_NO_BUCKET                 DenyReason_Code = 2
+	DenyReason_TOO_MANY_BUCKETS          DenyReason_Code = 3
+	DenyReason_TOO_MANY_TOKENS_REQUESTED DenyReason_Code = 4
+	DenyReason_INVALID_REQUEST           DenyReason_Code = 5
+	DenyReason_SERVER_ERROR              DenyReason_Code = 6
+	DenyReason_TOO_MANY_WAITERS          DenyReason_Code = 8
+	DenyReason_DENIED                    DenyReason_Code = 9
+	DenyReason_PERMISSION_DENIED         DenyReason_Code = 10
+)
+
+var DenyReason_Code_name = map[int32]string{
+	0:  "UNKNOWN",
+	1:  "TIMEOUT",
+	2:  "NO_BUCKET",
+	3:  "TOO_MANY_BUCKETS",
+	4:  "TOO_MANY_TOKENS_REQUESTED",
+	5:  "INVALID_REQUEST",
+	6:  "SERVER_ERROR",
+	8:  "TOO_MANY_WAITERS",
+	9:  "DENIED",
+	10: "PERMISSION_DENIED",
+}
+var DenyReason_Code_value = map[string]int32{
+	"UNKNOWN":                   0,
+	"TIMEOUT":                   1,
+	"NO_BUCKET":                 2,
+	"TOO_MANY_BUCKETS":          3,
+	"TOO_MANY_TOKENS_REQUESTED": 4,
+	"INVALID_REQUEST":           5,
+	"SERVER_ERROR":              6,
+	"TOO_MANY_WAITERS":          8,
+	"DENIED":                    9,
+	"PERMISSION_DENIED":         10,
+}
+
+func (x DenyReason_Code) String() string {
+	return proto.EnumName(DenyReason_Code_name, int32(x))
+}
+func (DenyReason_Code) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{5, 0} }
+
+type Caller struct {
+	// *
+	// Identifies the caller, such as by user or client ID, so that buckets with an
+	// enforcement_percent consistently enforce their limits on the same callers. Defaults to the
+	// identity the client authenticated as.
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// *
+	// Labels describing the caller, such as its service name, that events and grant records of the
+	// request carry.
+	Labels map[string]string `protobuf:"bytes,2,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Caller) Reset()                    { *m = Caller{} }
+func (m *Caller) String() string            { return proto.CompactTextString(m) }
+func (*Caller) ProtoMessage()               {}
+func (*Caller) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *Caller) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+type AllowRequest struct {
+	Caller   *Caller  `protobuf:"bytes,1,opt,name=caller" json:"caller,omitempty"`
+	Priority Priority `protobuf:"varint,2,opt,name=priority,enum=quotaservice.v2.Priority" json:"priority,omitempty"`
+	Entries  []*Entry `protobuf:"bytes,3,rep,name=entries" json:"entries,omitempty"`
+	// *
+	// Evaluate whether each entry's tokens would be granted without claiming any, as v1's dry_run
+	// does.
+	DryRun bool `protobuf:"varint,4,opt,name=dry_run" json:"dry_run,omitempty"`
+}
+
+func (m *AllowRequest) Reset()                    { *m = AllowRequest{} }
+func (m *AllowRequest) String() string            { return proto.CompactTextString(m) }
+func (*AllowRequest) ProtoMessage()               {}
+func (*AllowRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *AllowRequest) GetCaller() *Caller {
+	if m != nil {
+		return m.Caller
+	}
+	return nil
+}
+
+func (m *AllowRequest) GetEntries() []*Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+type Entry struct {
+	Namespace  string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+	BucketName string `protobuf:"bytes,2,opt,name=bucket_name" json:"bucket_name,omitempty"`
+	// *
+	// Number of tokens requested. Defaults to 1.
+	Tokens int64 `protobuf:"varint,3,opt,name=tokens" json:"tokens,omitempty"`
+	// *
+	// Fewest and most tokens to grant, if any amount in between will do. Used instead of tokens
+	// when max_tokens is set, with min_tokens defaulting to 1.
+	MinTokens int64 `protobuf:"varint,4,opt,name=min_tokens" json:"min_tokens,omitempty"`
+	MaxTokens int64 `protobuf:"varint,5,opt,name=max_tokens" json:"max_tokens,omitempty"`
+	// *
+	// Label the bucket's costs table prices the tokens by, so tokens_granted counts priced tokens.
+	CostLabel string `protobuf:"bytes,6,opt,name=cost_label" json:"cost_label,omitempty"`
+	// *
+	// Max wait time, in millis. Defaults to 0, which assumes no waiting.
+	MaxWaitMillis int64 `protobuf:"varint,7,opt,name=max_wait_millis" json:"max_wait_millis,omitempty"`
+	// *
+	// Most debt, in millis, buckets may lend the entry, if lower than their max_debt_millis.
+	// Defaults to 0, which lends as much as the buckets allow.
+	MaxDebtMillis int64 `protobuf:"varint,8,opt,name=max_debt_millis" json:"max_debt_millis,omitempty"`
+}
+
+func (m *Entry) Reset()                    { *m = Entry{} }
+func (m *Entry) String() string            { return proto.CompactTextString(m) }
+func (*Entry) ProtoMessage()               {}
+func (*Entry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type AllowResponse struct {
+	// *
+	// Results of the request's entries, in the same order.
+	Results []*EntryResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *AllowResponse) Reset()                    { *m = AllowResponse{} }
+func (m *AllowResponse) String() string            { return proto.CompactTextString(m) }
+func (*AllowResponse) ProtoMessage()               {}
+func (*AllowResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *AllowResponse) GetResults() []*EntryResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type EntryResult struct {
+	Status EntryResult_Status `protobuf:"varint,1,opt,name=status,enum=quotaservice.v2.EntryResult_Status" json:"status,omitempty"`
+	// *
+	// Number of tokens granted, if status == OK.
+	TokensGranted int64 `protobuf:"varint,2,opt,name=tokens_granted" json:"tokens_granted,omitempty"`
+	// *
+	// Wait for this many millis before proceeding, if status == OK.
+	WaitMillis int64 `protobuf:"varint,3,opt,name=wait_millis" json:"wait_millis,omitempty"`
+	// *
+	// Lease on the tokens granted by concurrency buckets, to pass to v1's Release.
+	LeaseId string `protobuf:"bytes,4,opt,name=lease_id" json:"lease_id,omitempty"`
+	// *
+	// Estimate of the tokens left in the bucket and its parents, as in v1's AllowResponse.
+	TokensRemaining int64 `protobuf:"varint,5,opt,name=tokens_remaining" json:"tokens_remaining,omitempty"`
+	// *
+	// Estimate of how many millis to wait before retrying, if deny_reason is retryable.
+	RetryAfterMillis int64 `protobuf:"varint,6,opt,name=retry_after_millis" json:"retry_after_millis,omitempty"`
+	// *
+	// Why the entry was refused, if status == REJECTED.
+	DenyReason *DenyReason `protobuf:"bytes,7,opt,name=deny_reason" json:"deny_reason,omitempty"`
+}
+
+func (m *EntryResult) Reset()                    { *m = EntryResult{} }
+func (m *EntryResult) String() string            { return proto.CompactTextString(m) }
+func (*EntryResult) ProtoMessage()               {}
+func (*EntryResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *EntryResult) GetDenyReason() *DenyReason {
+	if m != nil {
+		return m.DenyReason
+	}
+	return nil
+}
+
+type DenyReason struct {
+	Code DenyReason_Code `protobuf:"varint,1,opt,name=code,enum=quotaservice.v2.DenyReason_Code" json:"code,omitempty"`
+	// *
+	// Describes the refusal for people, rather than programs.
+	Message string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	// *
+	// Whether the entry may be granted if retried, after retry_after_millis.
+	Retryable bool `protobuf:"varint,3,opt,name=retryable" json:"retryable,omitempty"`
+	// *
+	// Further detail about the refusal, keyed by name, for servers to add to without changing the
+	// API.
+	Details map[string]string `protobuf:"bytes,4,rep,name=details" json:"details,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *DenyReason) Reset()                    { *m = DenyReason{} }
+func (m *DenyReason) String() string            { return proto.CompactTextString(m) }
+func (*DenyReason) ProtoMessage()               {}
+func (*DenyReason) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *DenyReason) GetDetails() map[string]string {
+	if m != nil {
+		return m.Details
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Caller)(nil), "quotaservice.v2.Caller")
+	proto.RegisterType((*AllowRequest)(nil), "quotaservice.v2.AllowRequest")
+	proto.RegisterType((*Entry)(nil), "quotaservice.v2.Entry")
+	proto.RegisterType((*AllowResponse)(nil), "quotaservice.v2.AllowResponse")
+	proto.RegisterType((*EntryResult)(nil), "quotaservice.v2.EntryResult")
+	proto.RegisterType((*DenyReason)(nil), "quotaservice.v2.DenyReason")
+	proto.RegisterEnum("quotaservice.v2.Priority", Priority_name, Priority_value)
+	proto.RegisterEnum("quotaservice.v2.EntryResult_Status", EntryResult_Status_name, EntryResult_Status_value)
+	proto.RegisterEnum("quotaservice.v2.DenyReason_Code", DenyReason_Code_name, DenyReason_Code_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Client API for QuotaService service
+
+type QuotaServiceClient interface {
+	// *
+	// Claims tokens for each of the request's entries, in order. Entries are served independently,
+	// so some may be granted while others are refused.
+	Allow(ctx context.Context, in *AllowRequest, opts ...grpc.CallOption) (*AllowResponse, error)
+}
+
+type quotaServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewQuotaServiceClient(cc *grpc.ClientConn) QuotaServiceClient {
+	return &quotaServiceClient{cc}
+}
+
+func (c *quotaServiceClient) Allow(ctx context.Context, in *AllowRequest, opts ...grpc.CallOption) (*AllowResponse, error) {
+	out := new(AllowResponse)
+	err := grpc.Invoke(ctx, "/quotaservice.v2.QuotaService/Allow", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for QuotaService service
+
+type QuotaServiceServer interface {
+	// *
+	// Claims tokens for each of the request's entries, in order. Entries are served independently,
+	// so some may be granted while others are refused.
+	Allow(context.Context, *AllowRequest) (*AllowResponse, error)
+}
+
+func RegisterQuotaServiceServer(s *grpc.Server, srv QuotaServiceServer) {
+	s.RegisterService(&_QuotaService_serviceDesc, srv)
+}
+
+func _QuotaService_Allow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(AllowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QuotaServiceServer).Allow(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _QuotaService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "quotaservice.v2.QuotaService",
+	HandlerType: (*QuotaServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Allow",
+			Handler:    _QuotaService_Allow_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+var fileDescriptor0 = []byte{
+	// 763 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x54, 0xdd, 0x72, 0xdb, 0x44,
+	0x14, 0x8e, 0xfc, 0x23, 0xdb, 0x47, 0x4e, 0xbc, 0xd9, 0x40, 0xab, 0x06, 0xd2, 0xc9, 0x88, 0x8b,
+	0x7a, 0x60, 0xaa, 0x32, 0xea, 0x0d, 0x70, 0xc1, 0x8c, 0x89, 0x77, 0x88, 0x48, 0x22, 0xa5, 0x92,
+	0xd2, 0xd2, 0xab, 0x9d, 0xb5, 0xb5, 0x64, 0x34, 0x91, 0xa5, 0x74, 0x77, 0x9d, 0xd6, 0x8f, 0xc0,
+	0x2d, 0x0f, 0xc0, 0x1b, 0xf0, 0x16, 0x3c, 0x18, 0xa3, 0x95, 0x6c, 0x0c, 0xe9, 0xcf, 0xe5, 0xf9,
+	0xce, 0x77, 0x8e, 0xce, 0xf7, 0xe9, 0x9c, 0x85, 0xa3, 0x5b, 0x51, 0xaa, 0x52, 0x3e, 0xbb, 0xf3,
+	0x9e, 0xbd, 0x59, 0x96, 0x8a, 0x51, 0xc9, 0xc5, 0x5d, 0x36, 0xe7, 0xae, 0xc6, 0xf1, 0x48, 0x83,
+	0x6b, 0xec, 0xce, 0x73, 0xde, 0x81, 0x79, 0xc2, 0xf2, 0x9c, 0x0b, 0x0c, 0xd0, 0xca, 0x52, 0xdb,
+	0x38, 0x36, 0xc6, 0x03, 0xfc, 0x1c, 0xcc, 0x9c, 0xcd, 0x78, 0x2e, 0xed, 0xd6, 0x71, 0x7b, 0x6c,
+	0x79, 0x5f, 0xb9, 0xff, 0xab, 0x73, 0xeb, 0x22, 0xf7, 0x5c, 0xb3, 0x48, 0xa1, 0xc4, 0xea, 0xf0,
+	0x29, 0x58, 0x5b, 0x21, 0xb6, 0xa0, 0x7d, 0xc3, 0x57, 0x4d, 0xc3, 0x5d, 0xe8, 0xde, 0xb1, 0x7c,
+	0xc9, 0xed, 0x56, 0x15, 0xfe, 0xd0, 0xfa, 0xce, 0x70, 0xfe, 0x34, 0x60, 0x38, 0xc9, 0xf3, 0xf2,
+	0x6d, 0xc4, 0xdf, 0x2c, 0xb9, 0x54, 0xf8, 0x09, 0x98, 0x73, 0xdd, 0x55, 0xd7, 0x58, 0xde, 0xc3,
+	0x0f, 0x7c, 0x14, 0x7f, 0x03, 0xfd, 0x5b, 0x91, 0x95, 0x22, 0x53, 0x2b, 0xdd, 0x6f, 0xcf, 0x7b,
+	0x74, 0x8f, 0x7a, 0xd9, 0x10, 0xf0, 0x13, 0xe8, 0xf1, 0x42, 0x89, 0x8c, 0x4b, 0xbb, 0xad, 0xb5,
+	0x3c, 0xb8, 0xc7, 0xad, 0xe7, 0x1d, 0x41, 0x2f, 0x15, 0x2b, 0x2a, 0x96, 0x85, 0xdd, 0x39, 0x36,
+	0xc6, 0x7d, 0xe7, 0x2f, 0x03, 0xba, 0x75, 0x6a, 0x1f, 0x06, 0x05, 0x5b, 0x70, 0x79, 0xcb, 0xe6,
+	0xbc, 0x11, 0x74, 0x00, 0xd6, 0x6c, 0x39, 0xbf, 0xe1, 0x8a, 0x56, 0x99, 0x5a, 0x16, 0xde, 0x03,
+	0x53, 0x95, 0x37, 0xbc, 0xa8, 0x3e, 0x65, 0x8c, 0xdb, 0x18, 0x03, 0x2c, 0xb2, 0x82, 0x36, 0x58,
+	0x67, 0x83, 0xb1, 0x77, 0x6b, 0xac, 0xbb, 0xc6, 0xe6, 0xa5, 0x54, 0x54, 0x7b, 0x6e, 0x9b, 0xba,
+	0xd7, 0x43, 0x18, 0x55, 0xbc, 0xb7, 0x2c, 0x53, 0x74, 0x91, 0xe5, 0x79, 0x26, 0xed, 0x9e, 0x26,
+	0x37, 0x89, 0x94, 0xcf, 0x36, 0x89, 0x7e, 0x95, 0x70, 0x7e, 0x84, 0xdd, 0xc6, 0x4f, 0x79, 0x5b,
+	0x16, 0x92, 0xe3, 0xa7, 0xd0, 0x13, 0x5c, 0x2e, 0x73, 0x25, 0x6d, 0x43, 0x4b, 0xff, 0xf2, 0xfd,
+	0xd2, 0x23, 0x4d, 0x72, 0x7e, 0x6f, 0x81, 0xb5, 0x15, 0x57, 0x4b, 0x20, 0x15, 0x53, 0x4b, 0xa9,
+	0x25, 0xef, 0xbd, 0x67, 0x09, 0xb6, 0xd8, 0x6e, 0xac, 0xa9, 0xf8, 0x01, 0xec, 0xd5, 0xd2, 0xe8,
+	0xb5, 0x60, 0x85, 0xe2, 0xa9, 0xb6, 0xa6, 0x5d, 0xf9, 0xb5, 0x2d, 0xa5, 0xf6, 0x07, 0x41, 0x3f,
+	0xe7, 0x4c, 0x72, 0x9a, 0xa5, 0xda, 0x9d, 0x01, 0xb6, 0x01, 0x35, 0xe5, 0x82, 0x2f, 0x58, 0x56,
+	0x64, 0xc5, 0x75, 0xe3, 0xd1, 0x21, 0x60, 0xc1, 0x95, 0x58, 0x51, 0xf6, 0x9b, 0xe2, 0x62, 0xdd,
+	0xc7, 0xd4, 0xb9, 0x6f, 0xc1, 0x4a, 0x79, 0xb1, 0xa2, 0x82, 0x33, 0x59, 0x16, 0xda, 0x27, 0xcb,
+	0xfb, 0xe2, 0xde, 0xb8, 0x53, 0x5e, 0xac, 0x22, 0x4d, 0x71, 0x1e, 0x83, 0xd9, 0x0c, 0x6c, 0x42,
+	0x2b, 0x3c, 0x43, 0x3b, 0x78, 0x08, 0xfd, 0x88, 0xfc, 0x42, 0x4e, 0x12, 0x32, 0x45, 0x86, 0xf3,
+	0x47, 0x1b, 0xe0, 0x5f, 0x3a, 0x76, 0xa1, 0x33, 0x2f, 0x53, 0xde, 0x18, 0x71, 0xfc, 0x91, 0xce,
+	0xee, 0x49, 0x99, 0xf2, 0x6a, 0x97, 0x16, 0x5c, 0x4a, 0x76, 0xbd, 0xde, 0x8c, 0x7d, 0x18, 0xe8,
+	0xe9, 0xd9, 0x2c, 0xe7, 0x5a, 0x7c, 0x1f, 0x7f, 0x0f, 0xbd, 0x94, 0x2b, 0x96, 0xe5, 0xd5, 0x66,
+	0x54, 0x7f, 0x67, 0xfc, 0xb1, 0xb6, 0xd3, 0x9a, 0x5a, 0x5f, 0x9a, 0x0b, 0xc3, 0xed, 0xf8, 0x93,
+	0xa7, 0xf6, 0xb7, 0x01, 0x1d, 0x3d, 0x97, 0x05, 0xbd, 0xab, 0xe0, 0x2c, 0x08, 0x5f, 0x05, 0x68,
+	0xa7, 0x0a, 0x12, 0xff, 0x82, 0x84, 0x57, 0x09, 0x32, 0xf0, 0x2e, 0x0c, 0x82, 0x90, 0xfe, 0x74,
+	0x75, 0x72, 0x46, 0x12, 0xd4, 0xc2, 0x9f, 0x01, 0x4a, 0xc2, 0x90, 0x5e, 0x4c, 0x82, 0xd7, 0x0d,
+	0x18, 0xa3, 0x36, 0x3e, 0x82, 0x47, 0x1b, 0x34, 0x09, 0xcf, 0x48, 0x10, 0xd3, 0x88, 0xbc, 0xb8,
+	0x22, 0x71, 0x65, 0x5a, 0x07, 0x1f, 0xc0, 0xc8, 0x0f, 0x5e, 0x4e, 0xce, 0xfd, 0xe9, 0x1a, 0x46,
+	0x5d, 0x8c, 0x60, 0x18, 0x93, 0xe8, 0x25, 0x89, 0x28, 0x89, 0xa2, 0x30, 0x42, 0xe6, 0x7f, 0x7a,
+	0xbf, 0x9a, 0xf8, 0x09, 0x89, 0x62, 0xd4, 0xc7, 0x00, 0xe6, 0x94, 0x04, 0x3e, 0x99, 0xa2, 0x01,
+	0xfe, 0x1c, 0xf6, 0x2f, 0x49, 0x74, 0xe1, 0xc7, 0xb1, 0x1f, 0x06, 0xb4, 0x81, 0xe1, 0x6b, 0x0f,
+	0xfa, 0x9b, 0xb3, 0x3e, 0x80, 0xd1, 0x65, 0xe4, 0x87, 0x91, 0x9f, 0xbc, 0xa6, 0x41, 0x18, 0x5d,
+	0x4c, 0xce, 0xd1, 0x0e, 0xde, 0x87, 0xdd, 0x0d, 0x78, 0xea, 0xff, 0x7c, 0x8a, 0x0c, 0xef, 0x57,
+	0x18, 0xbe, 0xa8, 0x5c, 0x8d, 0x6b, 0x57, 0xf1, 0x29, 0x74, 0xf5, 0x91, 0xe0, 0xa3, 0x7b, 0x6e,
+	0x6f, 0x3f, 0x46, 0x87, 0x8f, 0x3f, 0x94, 0xae, 0x6f, 0xcb, 0xd9, 0x99, 0x99, 0xfa, 0x45, 0x7d,
+	0xfe, 0xcf, 0x00, 0x5b, 0x68, 0xd0, 0x69, 0x72, 0x05, 0x00, 0x00,
+}
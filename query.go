@@ -0,0 +1,171 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func (s *server) Query(namespace, name string, tokensRequested int64) (int64, time.Duration, error) {
+	// Looking doesn't create dynamic buckets, so those that don't exist yet are reported as full.
+	b := s.bucketContainer.peekBucket(namespace, name)
+	if b == nil {
+		return 0, 0, newError("No such bucket "+config.FullyQualifiedName(namespace, name), ER_NO_BUCKET)
+	}
+
+	available := int64(-1)
+	var wait time.Duration
+	for _, r := range limiting(append([]*expirableBucket{b}, s.bucketContainer.parents(namespace, b)...)) {
+		if r.currentMode() == config.BucketModeAlwaysDeny {
+			return 0, 0, nil
+		}
+
+		sr, ok := r.Bucket.(StatusReporter)
+		if !ok {
+			continue
+		}
+
+		status := sr.Status()
+		if available < 0 || status.Tokens < available {
+			available = status.Tokens
+		}
+
+		if w := r.projectedWait(status.Tokens, status.DebtMillis, tokensRequested); w > wait {
+			wait = w
+		}
+	}
+
+	if available < 0 {
+		// None of the buckets can report their state.
+		available = 0
+	}
+
+	return available, wait, nil
+}
+
+func (s *server) DryRun(namespace, name, operation string, minTokens, maxTokens int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (*Grant, error) {
+	c, e := s.dryRun(namespace, name, operation, minTokens, maxTokens, maxWaitMillisOverride, maxDebtMillisOverride, priority, caller)
+	if e != nil {
+		c.Grant = Grant{}
+	}
+
+	c.estimate(e)
+	return &c.Grant, e
+}
+
+// dryRun projects the claim Claim would make, as DryRun reports it.
+func (s *server) dryRun(namespace, name, operation string, minTokens, maxTokens int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (*claimed, error) {
+	c := &claimed{}
+	// Dry runs change nothing, so dynamic buckets that don't exist yet are projected to be full.
+	b := s.bucketContainer.peekBucket(namespace, name)
+	if b == nil {
+		return c, newError("No such bucket "+config.FullyQualifiedName(namespace, name), ER_NO_BUCKET)
+	}
+
+	c.bucket, c.parents = b, s.bucketContainer.parents(namespace, b)
+	if e := s.price(namespace, name, c, operation, minTokens, maxTokens, priority); e != nil {
+		return c, e
+	}
+
+	if b.Config().MaxTokensPerRequest < c.Tokens && b.Config().MaxTokensPerRequest > 0 {
+		return c, newError(fmt.Sprintf("Too many tokens requested. Bucket %v:%v, tokensRequested=%v, maxTokensPerRequest=%v",
+			namespace, name, c.Tokens, b.Config().MaxTokensPerRequest),
+			ER_TOO_MANY_TOKENS_REQUESTED)
+	}
+
+	for _, r := range append([]*expirableBucket{b}, c.parents...) {
+		if r.currentMode() == config.BucketModeAlwaysDeny {
+			return c, newError(fmt.Sprintf("Bucket %v:%v is set to deny all requests", namespace, r.Config().Name), ER_DENIED)
+		}
+	}
+
+	maxWaitTime := b.maxWaitTime(maxWaitMillisOverride)
+	maxDebt := time.Duration(maxDebtMillisOverride) * time.Millisecond
+	for _, r := range limiting(append([]*expirableBucket{b}, c.parents...)) {
+		// Refusals stand as they would were tokens claimed.
+		stands := r.currentMode() != config.BucketModeShadow && r.enforces(caller.ID)
+		if r.heldBack(c.Tokens, priority) && stands {
+			return c, newError(fmt.Sprintf("Tokens in %v:%v are held back for high priority requests", namespace, r.Config().Name),
+				ER_TIMEOUT)
+		}
+
+		sr, ok := r.Bucket.(StatusReporter)
+		if !ok {
+			continue
+		}
+
+		w, granted := r.projectedGrant(sr.Status(), c.Tokens, maxWaitTime, maxDebt)
+		if !granted && stands {
+			return c, newError(fmt.Sprintf("Tokens in %v:%v aren't projected to be available in time", namespace, r.Config().Name),
+				ER_TIMEOUT)
+		}
+
+		if granted && w > c.Wait {
+			c.Wait = w
+		}
+	}
+
+	return c, nil
+}
+
+// projectedGrant estimates whether the bucket, in the state status reports, would grant
+// tokensRequested tokens within maxWaitTime, lending no more than maxDebt if it's positive and
+// lower than the bucket's max debt, and how long the caller would wait for them.
+func (e *expirableBucket) projectedGrant(status *admin.BucketStatus, tokensRequested int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
+	cfg := e.Config()
+	if limit := time.Duration(cfg.MaxDebtMillis) * time.Millisecond; maxDebt <= 0 || maxDebt > limit {
+		maxDebt = limit
+	}
+
+	projected := e.projectedWait(status.Tokens, status.DebtMillis, tokensRequested)
+	switch cfg.Algorithm {
+	case config.ConcurrencyAlgorithm:
+		// Tokens are freed as leases are released, so can't be projected to be.
+		return 0, tokensRequested <= status.Tokens
+	case config.SlidingWindowAlgorithm:
+		return projected, projected <= maxWaitTime
+	case config.GCRAAlgorithm:
+		return projected, projected <= maxWaitTime && projected <= maxDebt
+	default:
+		// Callers wait out the debt the bucket is already in, while tokens it doesn't have are lent.
+		wait := time.Duration(status.DebtMillis) * time.Millisecond
+		return wait, wait <= maxWaitTime && projected <= maxDebt
+	}
+}
+
+// projectedWait estimates how long a caller would wait for tokensRequested tokens from a bucket
+// holding tokens, or in debt by debtMillis, from the rate it frees tokens at. Concurrency buckets
+// free tokens as leases are released rather than at a rate, so they're never projected to wait.
+func (e *expirableBucket) projectedWait(tokens, debtMillis, tokensRequested int64) time.Duration {
+	if tokensRequested <= tokens {
+		return 0
+	}
+
+	cfg := e.Config()
+	var nanosPerToken int64
+	switch cfg.Algorithm {
+	case config.ConcurrencyAlgorithm:
+		return 0
+	case config.SlidingWindowAlgorithm:
+		if cfg.Size > 0 {
+			nanosPerToken = cfg.WindowMillis * int64(time.Millisecond) / cfg.Size
+		}
+	case config.GCRAAlgorithm:
+		if cfg.Adaptive == nil && cfg.EmissionInterval > 0 {
+			nanosPerToken = cfg.EmissionInterval
+			break
+		}
+		fallthrough
+	default:
+		if rate := e.currentFillRate(); rate > 0 {
+			nanosPerToken = cfg.NanosPerToken(rate)
+		}
+	}
+
+	return time.Duration(debtMillis)*time.Millisecond + time.Duration((tokensRequested-tokens)*nanosPerToken)
+}
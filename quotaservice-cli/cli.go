@@ -3,9 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
-	"os"
-	"net/http"
 	"io/ioutil"
+	"net/http"
+	"os"
 )
 
 const help = `Usage: quotaservice-cli -h host -p port (-n namespace) [COMMAND]
@@ -23,7 +23,7 @@ const help = `Usage: quotaservice-cli -h host -p port (-n namespace) [COMMAND]
 // TODO(manik) finish CLI
 func main() {
 	flag.Usage = func() {
-		fmt.Println(help)
+		fmt.Print(help)
 	}
 
 	port := flag.Int("p", 8080, "Specify port to use.  Defaults to 8000.")
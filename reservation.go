@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// defaultReservationTTL is how long reservations are held if callers don't say.
+const defaultReservationTTL = time.Minute
+
+// reservation holds tokens taken from a bucket and its parents until it's committed, when they're
+// consumed, or cancelled or expired, when they're returned.
+type reservation struct {
+	namespace, name string
+	tokens          int64
+	buckets         []*expirableBucket
+	expiry          *time.Timer
+}
+
+func (s *server) Reserve(ctx context.Context, namespace, name, operation string, tokensRequested int64, maxWaitMillisOverride int64, ttl time.Duration, priority Priority) (string, int64, time.Duration, error) {
+	c, e := s.claim(ctx, namespace, name, operation, tokensRequested, tokensRequested, maxWaitMillisOverride, 0, priority, Caller{}, true)
+	if e != nil {
+		return "", 0, 0, e
+	}
+
+	b := make([]byte, 8)
+	if _, e := rand.Read(b); e != nil {
+		returnTokens(c.taken, c.Tokens)
+		return "", 0, 0, e
+	}
+
+	if ttl <= 0 {
+		ttl = defaultReservationTTL
+	}
+
+	id := hex.EncodeToString(b)
+	r := &reservation{namespace: namespace, name: name, tokens: c.Tokens, buckets: c.taken}
+
+	s.reservationsLock.Lock()
+	defer s.reservationsLock.Unlock()
+
+	if s.reservations == nil {
+		s.reservations = make(map[string]*reservation)
+	}
+
+	s.reservations[id] = r
+	r.expiry = time.AfterFunc(ttl, func() {
+		if s.takeReservation(id) != nil {
+			logging.Printf("Reservation %v of %v tokens on %v expired", id, r.tokens, config.FullyQualifiedName(r.namespace, r.name))
+			returnTokens(r.buckets, r.tokens)
+		}
+	})
+
+	return id, c.Tokens, c.Wait, nil
+}
+
+func (s *server) CommitReservation(reservationID string) error {
+	r := s.takeReservation(reservationID)
+	if r == nil {
+		return newError("No reservation "+reservationID, ER_NO_RESERVATION)
+	}
+
+	r.expiry.Stop()
+	return nil
+}
+
+func (s *server) CancelReservation(reservationID string) error {
+	r := s.takeReservation(reservationID)
+	if r == nil {
+		return newError("No reservation "+reservationID, ER_NO_RESERVATION)
+	}
+
+	r.expiry.Stop()
+	returnTokens(r.buckets, r.tokens)
+	return nil
+}
+
+// takeReservation removes and returns a reservation, or nil if there's no such reservation, so that
+// only one of committing, cancelling or expiring it goes ahead.
+func (s *server) takeReservation(id string) *reservation {
+	s.reservationsLock.Lock()
+	defer s.reservationsLock.Unlock()
+
+	r := s.reservations[id]
+	delete(s.reservations, id)
+	return r
+}
+
+// returnTokens puts tokens back in the buckets they were taken from, where the buckets can take them.
+func returnTokens(buckets []*expirableBucket, numTokens int64) {
+	for _, b := range buckets {
+		if r, ok := b.Bucket.(TokenReturner); ok {
+			r.ReturnTokens(numTokens)
+		}
+	}
+}
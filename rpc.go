@@ -3,7 +3,47 @@
 
 package quotaservice
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// Priority is the class of a request for tokens.
+type Priority int
+
+const (
+	PRIORITY_NORMAL Priority = iota
+	// May also claim the tokens buckets hold back for high priority requests.
+	PRIORITY_HIGH
+)
+
+// Caller identifies who's asking for tokens.
+type Caller struct {
+	// ID identifies the caller, such as by user or client ID. Buckets with an enforcement percent
+	// decide whether their refusals apply by hashing it, if it's not empty.
+	ID string
+	// Labels describe the caller, such as by the name of its service, for listeners and the grant
+	// journal to tell callers of a bucket apart by.
+	Labels map[string]string
+}
+
+// Grant describes the tokens a claim was granted, as Claim and DryRun report them.
+type Grant struct {
+	// Tokens is the number of tokens granted, and LeaseID the lease on them, if any.
+	Tokens  int64
+	LeaseID string
+	// Wait is how long the caller has to wait before using the tokens.
+	Wait time.Duration
+	// Remaining is the fewest tokens left in the bucket claimed from and the parents limiting it,
+	// of those that can report them, once the claim is served; -1 if none can.
+	Remaining int64
+	// RetryAfter is how long until the tokens needed by a claim refused for want of them are
+	// projected to be available.
+	RetryAfter time.Duration
+	// Limit is the size of the bucket claimed from, the most tokens it holds at once; 0 if there's
+	// no such bucket.
+	Limit int64
+}
 
 // QuotaService is the interface used by RPC subsystems when fielding remote requests for quotas.
 type QuotaService interface {
@@ -16,6 +56,75 @@ type QuotaService interface {
 	// tokens could not be obtained, and will contain more context once cast to
 	// quotaservice.QoutaServiceError.
 	Allow(namespace, name string, tokensRequested int64, maxWaitMillisOverride int64) (waitTime time.Duration, err error)
+
+	// Lease is Allow, also returning the ID of the lease granted on the tokens by buckets that limit
+	// concurrency rather than rate. Those tokens aren't available to anyone else until the lease is
+	// passed to Release, or expires. Other buckets don't grant leases, returning an empty ID. Unlike
+	// Allow, which claims tokens at PRIORITY_NORMAL, Lease claims them at the priority given. If
+	// maxDebtMillisOverride is positive and lower than the max debt of the bucket or its parents,
+	// they lend no more than it, so that latency-sensitive callers are refused rather than kept
+	// waiting on tokens borrowed far into the future. Events and grant records of the request
+	// carry caller.
+	// Callers wait no longer than ctx's deadline, and those queued for a FIFO bucket's tokens give
+	// up as soon as ctx is done, such as when the client making the request cancels it.
+	Lease(ctx context.Context, namespace, name string, tokensRequested int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (leaseID string, waitTime time.Duration, err error)
+
+	// LeaseRange is Lease for callers that will take any number of tokens between minTokens and
+	// maxTokens. As many tokens are granted as the bucket and its parents hold, but no fewer than
+	// minTokens, which may mean waiting for them as Allow does.
+	LeaseRange(ctx context.Context, namespace, name string, minTokens, maxTokens int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (tokensGranted int64, leaseID string, waitTime time.Duration, err error)
+
+	// Claim is LeaseRange for an operation, whose tokens are priced by the bucket's costs table,
+	// reporting the grant along with the tokens the bucket and its parents have left, as they're
+	// found in claiming them, so that endpoints can tell clients without looking the bucket up
+	// again. Claims refused for want of tokens, with ER_TIMEOUT or ER_TOO_MANY_WAITERS, are also
+	// reported, with how long until those needed are projected to be available. Operations the
+	// table doesn't price, and the empty operation, cost a token. Claims whose price overflows are
+	// refused with ER_TOO_MANY_TOKENS_REQUESTED, as are those priced above the bucket's
+	// maxTokensPerRequest.
+	Claim(ctx context.Context, namespace, name, operation string, minTokens, maxTokens int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (*Grant, error)
+
+	// Query reports the tokens a bucket and its parents have available, and how long a caller
+	// would wait for tokensRequested of them, without claiming any. Dynamic buckets that don't
+	// exist yet aren't created, and are reported as full.
+	Query(namespace, name string, tokensRequested int64) (tokensAvailable int64, waitTime time.Duration, err error)
+
+	// DryRun reports the grant Claim would make, or the error it would be refused with, without
+	// claiming any tokens, emitting events or recording grants. Waits are projected from the state
+	// buckets report, as Query projects them, so buckets that can't report it are assumed to grant
+	// requests straight away. Grants are leaseless.
+	DryRun(namespace, name, operation string, minTokens, maxTokens int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (*Grant, error)
+
+	// Limit returns the size of a bucket, the most tokens it holds at once, so that endpoints can
+	// tell clients their limit. Dynamic buckets that don't exist yet aren't created, and are
+	// reported as their template would create them. Returns 0 if there's no such bucket.
+	Limit(namespace, name string) int64
+
+	// Feedback reports the health of the downstream an adaptive bucket protects, in terms of the
+	// error rate, from 0 to 1, and latency clients have seen, returning the bucket's fill rate once
+	// adapted to it. Errors with ER_NOT_ADAPTIVE if the bucket doesn't adapt its fill rate.
+	Feedback(namespace, name string, errorRate float64, latency time.Duration) (fillRate int64, err error)
+
+	// Release returns the tokens held by a lease to its bucket. Errors with ER_NO_LEASE if there's
+	// no such lease, such as when it has already been released or has expired, and ER_NO_BUCKET if
+	// there's no such bucket, such as when a dynamic bucket has been evicted along with its leases.
+	// Dynamic buckets aren't created to be released to.
+	Release(namespace, name, leaseID string) error
+
+	// Reserve takes tokens of an operation as Claim does, holding them under a reservation until
+	// it's committed, when they're consumed, or cancelled, when they're returned to the bucket.
+	// Reservations that are neither are cancelled after ttl, or a minute if ttl is 0. Errors with
+	// ER_NOT_RESERVABLE if the bucket, or one of its parents, can't take tokens back. Callers give
+	// up waiting for tokens once ctx is done, as they do with Lease.
+	Reserve(ctx context.Context, namespace, name, operation string, tokensRequested int64, maxWaitMillisOverride int64, ttl time.Duration, priority Priority) (reservationID string, tokensReserved int64, waitTime time.Duration, err error)
+
+	// CommitReservation consumes the tokens held by a reservation. Errors with ER_NO_RESERVATION if
+	// there's no such reservation, such as when it has already been cancelled or has expired.
+	CommitReservation(reservationID string) error
+
+	// CancelReservation returns the tokens held by a reservation to the buckets they were taken
+	// from. Errors as CommitReservation does.
+	CancelReservation(reservationID string) error
 }
 
 // RpcEndpoint defines a subsystem that listens on a network socket for external systems to
@@ -0,0 +1,166 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package auth authenticates the clients of gRPC-based and HTTP RPC endpoints, so that endpoints
+// serving different clients can each require credentials of their own, and authorizes them to
+// claim tokens from the namespaces they were permitted, so that one client can't use another's
+// quota.
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// authorizationKey is the metadata key clients send bearer tokens in.
+const authorizationKey = "authorization"
+
+// AllNamespaces, listed among an identity's namespaces, permits it all of them.
+const AllNamespaces = "*"
+
+// spiffeScheme is the URI scheme of SPIFFE IDs.
+const spiffeScheme = "spiffe"
+
+// Authenticator authenticates the client making a request.
+type Authenticator interface {
+	// Authenticate returns the identity of the client whose request ctx is the context of, or an
+	// error if the request doesn't carry valid credentials.
+	Authenticate(ctx context.Context) (identity string, err error)
+}
+
+// identityKey is the key of the context value requests carry their client's identity in.
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying the identity its request's client authenticated as.
+func NewContext(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the identity the client of ctx's request authenticated as, if it did.
+func FromContext(ctx context.Context) (identity string, ok bool) {
+	identity, ok = ctx.Value(identityKey{}).(string)
+	return
+}
+
+// RequestContext returns the context of an HTTP request as Authenticators read that of a gRPC
+// request: carrying its Authorization header as metadata, and the TLS connection it was made on,
+// if any, as its peer's.
+func RequestContext(r *http.Request) context.Context {
+	ctx := metadata.NewContext(r.Context(), metadata.MD{authorizationKey: r.Header["Authorization"]})
+	if r.TLS == nil {
+		return ctx
+	}
+
+	addr, _ := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	return peer.NewContext(ctx, &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{State: *r.TLS}})
+}
+
+// Authorizer decides whether an authenticated client may use a namespace's buckets.
+type Authorizer interface {
+	// Authorize returns an error if the client authenticated as identity may not use the buckets
+	// of the namespace.
+	Authorize(identity, namespace string) error
+}
+
+type tokenAuthenticator struct {
+	tokens map[string]string
+}
+
+// NewTokenAuthenticator creates an Authenticator accepting the bearer tokens given, as tokens to
+// the identities they were issued to, in the request's "authorization" metadata.
+func NewTokenAuthenticator(tokens map[string]string) Authenticator {
+	return &tokenAuthenticator{tokens}
+}
+
+func (a *tokenAuthenticator) Authenticate(ctx context.Context) (string, error) {
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return "", errors.New("No credentials provided")
+	}
+
+	for _, v := range md[authorizationKey] {
+		if !strings.HasPrefix(v, "Bearer ") {
+			continue
+		}
+
+		token := strings.TrimPrefix(v, "Bearer ")
+		for t, identity := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return identity, nil
+			}
+		}
+
+		return "", errors.New("Invalid token")
+	}
+
+	return "", errors.New("No credentials provided")
+}
+
+type certAuthenticator struct{}
+
+// NewCertAuthenticator creates an Authenticator accepting clients that presented a certificate
+// the endpoint's TLS config verified, identifying them by the SPIFFE ID among its URI SANs, such
+// as "spiffe://example.org/billing", or by its common name if it has none. Endpoints using it
+// must verify client certificates, as certs.Config does once it has a ClientCAFile.
+func NewCertAuthenticator() Authenticator {
+	return certAuthenticator{}
+}
+
+func (certAuthenticator) Authenticate(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", errors.New("No client certificate provided")
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return "", errors.New("No client certificate provided")
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+	for _, uri := range cert.URIs {
+		if uri.Scheme == spiffeScheme {
+			return uri.String(), nil
+		}
+	}
+
+	return cert.Subject.CommonName, nil
+}
+
+type namespaceAuthorizer struct {
+	namespaces map[string]map[string]bool
+}
+
+// NewNamespaceAuthorizer creates an Authorizer permitting identities the namespaces given, as
+// identities to the namespaces they may use. Identities permitted AllNamespaces may use any
+// namespace, while those not given may use none.
+func NewNamespaceAuthorizer(namespaces map[string][]string) Authorizer {
+	a := &namespaceAuthorizer{make(map[string]map[string]bool, len(namespaces))}
+	for identity, names := range namespaces {
+		permitted := make(map[string]bool, len(names))
+		for _, n := range names {
+			permitted[n] = true
+		}
+		a.namespaces[identity] = permitted
+	}
+
+	return a
+}
+
+func (a *namespaceAuthorizer) Authorize(identity, namespace string) error {
+	permitted := a.namespaces[identity]
+	if permitted[namespace] || permitted[AllNamespaces] {
+		return nil
+	}
+
+	return fmt.Errorf("%q may not use namespace %v", identity, namespace)
+}
@@ -0,0 +1,111 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestTokenAuthenticator(t *testing.T) {
+	a := NewTokenAuthenticator(map[string]string{"s3cr3t": "billing"})
+	tests := []struct {
+		md       metadata.MD
+		identity string
+		ok       bool
+	}{
+		{metadata.Pairs(authorizationKey, "Bearer s3cr3t"), "billing", true},
+		{metadata.Pairs(authorizationKey, "Bearer wrong"), "", false},
+		{metadata.Pairs(authorizationKey, "Basic s3cr3t"), "", false},
+		{metadata.Pairs("priority", "high"), "", false},
+		{nil, "", false}}
+
+	for _, test := range tests {
+		ctx := context.TODO()
+		if test.md != nil {
+			ctx = metadata.NewContext(ctx, test.md)
+		}
+
+		identity, err := a.Authenticate(ctx)
+		if identity != test.identity || (err == nil) != test.ok {
+			t.Errorf("Expecting %v to authenticate as %q: %v. Got %q, error %v", test.md, test.identity, test.ok, identity, err)
+		}
+	}
+}
+
+func TestCertAuthenticator(t *testing.T) {
+	a := NewCertAuthenticator()
+	if _, err := a.Authenticate(context.TODO()); err == nil {
+		t.Fatal("Expecting requests without a peer not to authenticate")
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	ctx := peer.NewContext(context.TODO(), &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{}})
+	if _, err := a.Authenticate(ctx); err == nil {
+		t.Fatal("Expecting clients without verified certificates not to authenticate")
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	ctx = peer.NewContext(context.TODO(), &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{State: state}})
+	if identity, err := a.Authenticate(ctx); err != nil || identity != "billing" {
+		t.Fatalf("Expecting the client to authenticate as billing. Got %q, error %v", identity, err)
+	}
+
+	cert.URIs = []*url.URL{{Scheme: "https", Host: "example.org"}, {Scheme: "spiffe", Host: "example.org", Path: "/billing"}}
+	if identity, err := a.Authenticate(ctx); err != nil || identity != "spiffe://example.org/billing" {
+		t.Fatalf("Expecting the client to authenticate by its SPIFFE ID. Got %q, error %v", identity, err)
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	r := httptest.NewRequest("GET", "/allow", nil)
+	if _, err := NewTokenAuthenticator(map[string]string{"s3cr3t": "billing"}).Authenticate(RequestContext(r)); err == nil {
+		t.Fatal("Expecting HTTP requests without an Authorization header not to authenticate")
+	}
+
+	r.Header.Set("Authorization", "Bearer s3cr3t")
+	if identity, err := NewTokenAuthenticator(map[string]string{"s3cr3t": "billing"}).Authenticate(RequestContext(r)); err != nil || identity != "billing" {
+		t.Fatalf("Expecting the HTTP request to authenticate by its token. Got %q, error %v", identity, err)
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "payments"}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	if identity, err := NewCertAuthenticator().Authenticate(RequestContext(r)); err != nil || identity != "payments" {
+		t.Fatalf("Expecting the HTTP request to authenticate by its certificate. Got %q, error %v", identity, err)
+	}
+}
+
+func TestNamespaceAuthorizer(t *testing.T) {
+	a := NewNamespaceAuthorizer(map[string][]string{
+		"billing": {"billing", "payments"},
+		"admin":   {AllNamespaces}})
+	tests := []struct {
+		identity  string
+		namespace string
+		ok        bool
+	}{
+		{"billing", "billing", true},
+		{"billing", "payments", true},
+		{"billing", "ads", false},
+		{"admin", "ads", true},
+		{"ads", "ads", false},
+		{"", "billing", false}}
+
+	for _, test := range tests {
+		if err := a.Authorize(test.identity, test.namespace); (err == nil) != test.ok {
+			t.Errorf("Expecting %q to be permitted namespace %v: %v. Error %v", test.identity, test.namespace, test.ok, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package certs configures TLS for RPC endpoints, with certificates that can be rotated without
+// restarting the server.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// Source provides the certificate servers identify themselves with. It's asked for the
+// certificate on each handshake, so sources that return a new one rotate it.
+type Source interface {
+	Certificate() (*tls.Certificate, error)
+}
+
+// Config configures TLS for an RPC endpoint.
+type Config struct {
+	// Certificate the endpoint identifies itself with.
+	Certificate Source
+	// ClientCAFile, if set, is a PEM encoded file of the CAs that client certificates must be
+	// issued by. Clients without a certificate are still served, unless RequireClientCerts is set.
+	ClientCAFile       string
+	RequireClientCerts bool
+}
+
+// ServerTLSConfig loads the client CAs, returning a tls.Config for the endpoint's server.
+func (c *Config) ServerTLSConfig() (*tls.Config, error) {
+	if c.Certificate == nil {
+		return nil, errors.New("No certificate source")
+	}
+
+	// Fail on start, rather than on each handshake, if there's no certificate to begin with.
+	if _, e := c.Certificate.Certificate(); e != nil {
+		return nil, e
+	}
+
+	cfg := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.Certificate.Certificate()
+		},
+		MinVersion: tls.VersionTLS12}
+
+	if c.RequireClientCerts && c.ClientCAFile == "" {
+		return nil, errors.New("Requiring client certificates needs a client CA file")
+	}
+
+	if c.ClientCAFile != "" {
+		pem, e := ioutil.ReadFile(c.ClientCAFile)
+		if e != nil {
+			return nil, e
+		}
+
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No certificates found in " + c.ClientCAFile)
+		}
+
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if c.RequireClientCerts {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return cfg, nil
+}
+
+// FileSource is a Source loading the certificate from PEM encoded certificate and private key
+// files, which it loads again whenever either is modified, so that certificates are rotated by
+// replacing the files.
+type FileSource struct {
+	certFile, keyFile string
+
+	sync.Mutex
+	cert *tls.Certificate
+	// Modification times of the files the certificate was loaded from.
+	certModified, keyModified time.Time
+}
+
+// NewFileSource creates a FileSource for the files, loading the certificate.
+func NewFileSource(certFile, keyFile string) (*FileSource, error) {
+	s := &FileSource{certFile: certFile, keyFile: keyFile}
+	if _, e := s.Certificate(); e != nil {
+		return nil, e
+	}
+
+	return s, nil
+}
+
+// Certificate returns the certificate, loading it again if the files have been modified since it
+// was loaded. Should loading fail, such as while the files are being replaced, the certificate
+// loaded last is returned.
+func (s *FileSource) Certificate() (*tls.Certificate, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	certModified, e := modified(s.certFile)
+	if e != nil {
+		return s.stale(e)
+	}
+
+	keyModified, e := modified(s.keyFile)
+	if e != nil {
+		return s.stale(e)
+	}
+
+	if s.cert != nil && certModified.Equal(s.certModified) && keyModified.Equal(s.keyModified) {
+		return s.cert, nil
+	}
+
+	cert, e := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if e != nil {
+		return s.stale(e)
+	}
+
+	if s.cert != nil {
+		logging.Printf("Loaded rotated certificate from %v", s.certFile)
+	}
+
+	s.cert, s.certModified, s.keyModified = &cert, certModified, keyModified
+	return s.cert, nil
+}
+
+// stale returns the certificate loaded last, if any, or e otherwise. Must be called with the lock
+// held.
+func (s *FileSource) stale(e error) (*tls.Certificate, error) {
+	if s.cert == nil {
+		return nil, e
+	}
+
+	logging.Warnf("Unable to load certificate from %v, so serving the one loaded last. Error: %v", s.certFile, e)
+	return s.cert, nil
+}
+
+func modified(file string) (time.Time, error) {
+	fi, e := os.Stat(file)
+	if e != nil {
+		return time.Time{}, e
+	}
+
+	return fi.ModTime(), nil
+}
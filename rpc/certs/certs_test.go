@@ -0,0 +1,114 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+func TestServerTLSConfig(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_certs")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := helpers.NewCert(t, "ca", nil, nil)
+	server, serverKey := helpers.NewCert(t, "server", ca, caKey)
+	client, clientKey := helpers.NewCert(t, "client", ca, caKey)
+
+	certFile := helpers.WritePEM(t, dir, "server.pem", "CERTIFICATE", server.Raw)
+	keyFile := helpers.WriteKey(t, dir, "server.key", serverKey)
+	source, e := NewFileSource(certFile, keyFile)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cfg := &Config{
+		Certificate:        source,
+		ClientCAFile:       helpers.WritePEM(t, dir, "ca.pem", "CERTIFICATE", ca.Raw),
+		RequireClientCerts: true}
+	tlsCfg, e := cfg.ServerTLSConfig()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	l, e := tls.Listen("tcp", "127.0.0.1:0", tlsCfg)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, e := l.Accept()
+			if e != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// handshake returns the name the server identified itself with.
+	handshake := func(cert *x509.Certificate, key *ecdsa.PrivateKey) (string, error) {
+		roots := x509.NewCertPool()
+		roots.AddCert(ca)
+		clientCfg := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			clientCfg.Certificates = []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}
+		}
+
+		conn, e := tls.Dial("tcp", l.Addr().String(), clientCfg)
+		if e != nil {
+			return "", e
+		}
+		defer conn.Close()
+
+		// Servers refusing the client's certificate do so once they've read it.
+		if _, e := conn.Read(make([]byte, 1)); e != nil && e.Error() != "EOF" {
+			return "", e
+		}
+
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+	}
+
+	if name, e := handshake(client, clientKey); e != nil || name != "server" {
+		t.Fatalf("Expecting clients with a certificate to be served. Server %v, error %v", name, e)
+	}
+
+	if _, e := handshake(nil, nil); e == nil {
+		t.Fatal("Expecting clients without a certificate to be refused")
+	}
+
+	// Certificates are rotated by replacing the files.
+	rotated, rotatedKey := helpers.NewCert(t, "rotated", ca, caKey)
+	helpers.WritePEM(t, dir, "server.pem", "CERTIFICATE", rotated.Raw)
+	helpers.WriteKey(t, dir, "server.key", rotatedKey)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	if name, e := handshake(client, clientKey); e != nil || name != "rotated" {
+		t.Fatalf("Expecting the rotated certificate to be served. Server %v, error %v", name, e)
+	}
+
+	// Files that can't be loaded, such as while they're replaced, leave the last certificate.
+	ioutil.WriteFile(keyFile, []byte("partial"), 0600)
+	os.Chtimes(keyFile, later.Add(time.Minute), later.Add(time.Minute))
+	if name, e := handshake(client, clientKey); e != nil || name != "rotated" {
+		t.Fatalf("Expecting the certificate loaded last to be served. Server %v, error %v", name, e)
+	}
+
+	if _, e := (&Config{Certificate: source, RequireClientCerts: true}).ServerTLSConfig(); e == nil {
+		t.Fatal("Expecting client certificates to need a client CA file to be required")
+	}
+}
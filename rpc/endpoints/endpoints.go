@@ -0,0 +1,250 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package endpoints creates the RPC endpoints a server listens on from their configs, so that one
+// deployment can serve clients speaking different protocols, with TLS, authentication and limits
+// set for each endpoint.
+package endpoints
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/rpc/auth"
+	"github.com/maniksurtani/quotaservice/rpc/certs"
+	"github.com/maniksurtani/quotaservice/rpc/envoy"
+	"github.com/maniksurtani/quotaservice/rpc/grpc"
+	"github.com/maniksurtani/quotaservice/rpc/http"
+	"github.com/maniksurtani/quotaservice/rpc/limits"
+	"github.com/maniksurtani/quotaservice/rpc/thrift"
+	"gopkg.in/yaml.v2"
+)
+
+// Types of endpoint.
+const (
+	// TypeGRPC serves the quota service's own gRPC API.
+	TypeGRPC = "grpc"
+	// TypeHTTP serves the quota service over HTTP.
+	TypeHTTP = "http"
+	// TypeEnvoy serves Envoy's rate limit service protocol.
+	TypeEnvoy = "envoy"
+	// TypeThrift serves the quota service's Allow API to Thrift clients.
+	TypeThrift = "thrift"
+)
+
+// Config configures an RPC endpoint.
+type Config struct {
+	// Type of the endpoint, such as TypeGRPC.
+	Type string `yaml:"type"`
+	// Hostport the endpoint listens on, in the form "host:port".
+	Hostport string `yaml:"hostport"`
+	// TLS, if set, has the endpoint serve TLS.
+	TLS *TLSConfig `yaml:"tls"`
+	// Tokens, if set, are the bearer tokens clients must authenticate with, as tokens to the
+	// identities they were issued to.
+	Tokens map[string]string `yaml:"tokens"`
+	// ClientCertAuth has clients authenticate with certificates the endpoint's client CAs issued.
+	ClientCertAuth bool `yaml:"client_cert_auth"`
+	// Namespaces, if set, are the namespaces each identity clients authenticate as may use, listed
+	// under those identities. Identities may be permitted all namespaces with auth.AllNamespaces.
+	Namespaces map[string][]string `yaml:"namespaces"`
+	Limits     limits.Limits       `yaml:"limits"`
+	// Mappings of an Envoy endpoint's descriptors to buckets.
+	Mappings []envoy.Mapping `yaml:"mappings"`
+	// H2C has an HTTP endpoint also serve cleartext HTTP/2.
+	H2C bool `yaml:"h2c"`
+}
+
+// TLSConfig has the files an endpoint's TLS is configured from, as certs.Config describes them.
+type TLSConfig struct {
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ClientCAFile       string `yaml:"client_ca_file"`
+	RequireClientCerts bool   `yaml:"require_client_certs"`
+}
+
+// endpointFile is the layout of files endpoints are loaded from.
+type endpointFile struct {
+	Endpoints []Config `yaml:"endpoints"`
+}
+
+// Load reads the configs listed under "endpoints" in the YAML file.
+func Load(filename string) ([]Config, error) {
+	contents, e := ioutil.ReadFile(filename)
+	if e != nil {
+		return nil, e
+	}
+
+	f := &endpointFile{}
+	if e := yaml.Unmarshal(contents, f); e != nil {
+		return nil, fmt.Errorf("%v (in %v)", e, filename)
+	}
+
+	return f.Endpoints, nil
+}
+
+// New creates the endpoints the configs configure, in order, erroring if any is invalid or if
+// two share a hostport.
+func New(cfgs ...Config) ([]quotaservice.RpcEndpoint, error) {
+	hostports := make(map[string]bool)
+	endpoints := make([]quotaservice.RpcEndpoint, 0, len(cfgs))
+	for i := range cfgs {
+		cfg := &cfgs[i]
+		if hostports[cfg.Hostport] {
+			return nil, fmt.Errorf("Endpoint %v: more than one endpoint listens on %v", i, cfg.Hostport)
+		}
+		hostports[cfg.Hostport] = true
+
+		endpoint, e := newEndpoint(cfg)
+		if e != nil {
+			return nil, fmt.Errorf("Endpoint %v: %v", i, e)
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, nil
+}
+
+func newEndpoint(cfg *Config) (quotaservice.RpcEndpoint, error) {
+	if !strings.Contains(cfg.Hostport, ":") {
+		return nil, fmt.Errorf("hostport should be in the format 'host:port', but is %q", cfg.Hostport)
+	}
+
+	if len(cfg.Mappings) > 0 && cfg.Type != TypeEnvoy {
+		return nil, fmt.Errorf("Only %v endpoints have mappings", TypeEnvoy)
+	}
+
+	if cfg.H2C && cfg.Type != TypeHTTP {
+		return nil, fmt.Errorf("Only %v endpoints serve cleartext HTTP/2", TypeHTTP)
+	}
+
+	a, e := authenticator(cfg)
+	if e != nil {
+		return nil, e
+	}
+
+	var authorizer auth.Authorizer
+	if len(cfg.Namespaces) > 0 {
+		if a == nil {
+			return nil, fmt.Errorf("Authorizing namespaces needs clients to authenticate")
+		}
+		authorizer = auth.NewNamespaceAuthorizer(cfg.Namespaces)
+	}
+
+	var tlsCfg *certs.Config
+	if cfg.TLS != nil {
+		source, e := certs.NewFileSource(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if e != nil {
+			return nil, e
+		}
+
+		tlsCfg = &certs.Config{
+			Certificate:        source,
+			ClientCAFile:       cfg.TLS.ClientCAFile,
+			RequireClientCerts: cfg.TLS.RequireClientCerts}
+	}
+
+	switch cfg.Type {
+	case TypeGRPC:
+		endpoint := grpc.New(cfg.Hostport)
+		if tlsCfg != nil {
+			if e := endpoint.UseTLS(tlsCfg); e != nil {
+				return nil, e
+			}
+		}
+
+		if a != nil {
+			endpoint.UseAuth(a)
+		}
+
+		if authorizer != nil {
+			endpoint.UseAuthorizer(authorizer)
+		}
+		endpoint.UseLimits(cfg.Limits)
+		return endpoint, nil
+	case TypeEnvoy:
+		endpoint := envoy.New(cfg.Hostport, cfg.Mappings...)
+		if tlsCfg != nil {
+			if e := endpoint.UseTLS(tlsCfg); e != nil {
+				return nil, e
+			}
+		}
+
+		if a != nil {
+			endpoint.UseAuth(a)
+		}
+
+		if authorizer != nil {
+			endpoint.UseAuthorizer(authorizer)
+		}
+		endpoint.UseLimits(cfg.Limits)
+		return endpoint, nil
+	case TypeHTTP:
+		if cfg.Limits != (limits.Limits{}) {
+			return nil, fmt.Errorf("%v endpoints don't support limits", TypeHTTP)
+		}
+
+		port, e := strconv.Atoi(cfg.Hostport[strings.LastIndex(cfg.Hostport, ":")+1:])
+		if e != nil {
+			return nil, fmt.Errorf("Invalid port in %v", cfg.Hostport)
+		}
+
+		endpoint := http.New(port)
+		if tlsCfg != nil {
+			if e := endpoint.UseTLS(tlsCfg); e != nil {
+				return nil, e
+			}
+		}
+
+		if cfg.H2C {
+			if e := endpoint.UseH2C(); e != nil {
+				return nil, e
+			}
+		}
+
+		if a != nil {
+			endpoint.UseAuth(a)
+		}
+
+		if authorizer != nil {
+			endpoint.UseAuthorizer(authorizer)
+		}
+		return endpoint, nil
+	case TypeThrift:
+		if a != nil {
+			return nil, fmt.Errorf("%v endpoints don't support authentication", TypeThrift)
+		}
+
+		endpoint := thrift.New(cfg.Hostport)
+		if tlsCfg != nil {
+			if e := endpoint.UseTLS(tlsCfg); e != nil {
+				return nil, e
+			}
+		}
+		endpoint.UseLimits(cfg.Limits)
+		return endpoint, nil
+	default:
+		return nil, fmt.Errorf("Unknown endpoint type %q", cfg.Type)
+	}
+}
+
+// authenticator returns the endpoint's Authenticator, or nil if clients needn't authenticate.
+func authenticator(cfg *Config) (auth.Authenticator, error) {
+	switch {
+	case len(cfg.Tokens) > 0 && cfg.ClientCertAuth:
+		return nil, fmt.Errorf("Clients can authenticate with tokens or certificates, not both")
+	case len(cfg.Tokens) > 0:
+		return auth.NewTokenAuthenticator(cfg.Tokens), nil
+	case cfg.ClientCertAuth:
+		if cfg.TLS == nil || cfg.TLS.ClientCAFile == "" {
+			return nil, fmt.Errorf("Authenticating client certificates needs a client CA file")
+		}
+		return auth.NewCertAuthenticator(), nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,219 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package endpoints
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos"
+	"github.com/maniksurtani/quotaservice/rpc/envoy"
+	qsgrpc "github.com/maniksurtani/quotaservice/rpc/grpc"
+	"github.com/maniksurtani/quotaservice/rpc/http"
+	"github.com/maniksurtani/quotaservice/rpc/limits"
+	"github.com/maniksurtani/quotaservice/rpc/thrift"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNew(t *testing.T) {
+	endpoints, e := New(
+		Config{Type: TypeGRPC, Hostport: "localhost:10990", Limits: limits.Limits{MaxConcurrentStreams: 100}},
+		Config{Type: TypeHTTP, Hostport: "localhost:8080", H2C: true, Tokens: map[string]string{"t": "http"},
+			Namespaces: map[string][]string{"http": {"ns"}}},
+		Config{Type: TypeEnvoy, Hostport: "localhost:8081", Tokens: map[string]string{"t": "envoy"},
+			Mappings: []envoy.Mapping{{Keys: []string{"k"}, Namespace: "ns", Bucket: "{k}"}}},
+		Config{Type: TypeThrift, Hostport: "localhost:9090"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if _, ok := endpoints[0].(*qsgrpc.GrpcEndpoint); !ok {
+		t.Errorf("Expecting a gRPC endpoint, got %T", endpoints[0])
+	}
+
+	if _, ok := endpoints[1].(*http.HttpEndpoint); !ok {
+		t.Errorf("Expecting an HTTP endpoint, got %T", endpoints[1])
+	}
+
+	if _, ok := endpoints[2].(*envoy.EnvoyEndpoint); !ok {
+		t.Errorf("Expecting an Envoy endpoint, got %T", endpoints[2])
+	}
+
+	if _, ok := endpoints[3].(*thrift.ThriftEndpoint); !ok {
+		t.Errorf("Expecting a Thrift endpoint, got %T", endpoints[3])
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfgs []Config
+	}{
+		{"unknown type", []Config{{Type: "soap", Hostport: "localhost:9090"}}},
+		{"no port", []Config{{Type: TypeGRPC, Hostport: "localhost"}}},
+		{"shared hostport", []Config{{Type: TypeGRPC, Hostport: "localhost:10990"}, {Type: TypeEnvoy, Hostport: "localhost:10990"}}},
+		{"mappings", []Config{{Type: TypeGRPC, Hostport: "localhost:10990", Mappings: []envoy.Mapping{{Namespace: "ns"}}}}},
+		{"tokens and certs", []Config{{Type: TypeGRPC, Hostport: "localhost:10990", Tokens: map[string]string{"t": "a"}, ClientCertAuth: true}}},
+		{"namespaces without auth", []Config{{Type: TypeGRPC, Hostport: "localhost:10990", Namespaces: map[string][]string{"billing": {"billing"}}}}},
+		{"certs without CA", []Config{{Type: TypeGRPC, Hostport: "localhost:10990", ClientCertAuth: true}}},
+		{"HTTP limits", []Config{{Type: TypeHTTP, Hostport: "localhost:8080", Limits: limits.Limits{MaxConnections: 1}}}},
+		{"gRPC h2c", []Config{{Type: TypeGRPC, Hostport: "localhost:10990", H2C: true}}},
+		{"Thrift auth", []Config{{Type: TypeThrift, Hostport: "localhost:9090", Tokens: map[string]string{"t": "a"}}}},
+		{"missing certificate", []Config{{Type: TypeGRPC, Hostport: "localhost:10990", TLS: &TLSConfig{CertFile: "/nonexistent.pem", KeyFile: "/nonexistent.key"}}}}}
+
+	for _, test := range tests {
+		if _, e := New(test.cfgs...); e == nil {
+			t.Errorf("Expecting an error for %v", test.name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	f, e := ioutil.TempFile("", "qs_test_endpoints")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(`endpoints:
+  - type: grpc
+    hostport: 0.0.0.0:10990
+    tls:
+      cert_file: /etc/qs/server.pem
+      key_file: /etc/qs/server.key
+    limits:
+      max_connections: 1000
+      max_concurrent_requests: 5000
+      keepalive:
+        time_millis: 30000
+        max_age_millis: 600000
+  - type: envoy
+    hostport: 0.0.0.0:8081
+    tokens:
+      s3cr3t: envoy
+    mappings:
+      - keys: [remote_address]
+        namespace: edge
+        bucket: "{remote_address}"
+`)
+	f.Close()
+
+	cfgs, e := Load(f.Name())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	expected := []Config{
+		{
+			Type:     TypeGRPC,
+			Hostport: "0.0.0.0:10990",
+			TLS:      &TLSConfig{CertFile: "/etc/qs/server.pem", KeyFile: "/etc/qs/server.key"},
+			Limits: limits.Limits{
+				MaxConnections:        1000,
+				MaxConcurrentRequests: 5000,
+				Keepalive:             limits.Keepalive{TimeMillis: 30000, MaxAgeMillis: 600000}}},
+		{
+			Type:     TypeEnvoy,
+			Hostport: "0.0.0.0:8081",
+			Tokens:   map[string]string{"s3cr3t": "envoy"},
+			Mappings: []envoy.Mapping{{Keys: []string{"remote_address"}, Namespace: "edge", Bucket: "{remote_address}"}}}}
+	if !reflect.DeepEqual(cfgs, expected) {
+		t.Fatalf("Expecting %+v, got %+v", expected, cfgs)
+	}
+}
+
+func TestIndependentAuth(t *testing.T) {
+	open, secured := freeHostport(t), freeHostport(t)
+	endpoints, e := New(
+		Config{Type: TypeGRPC, Hostport: open},
+		Config{Type: TypeGRPC, Hostport: secured, Tokens: map[string]string{"s3cr3t": "billing"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cfg := config.NewDefaultServiceConfig()
+	cfg.GlobalDefaultBucket = config.NewDefaultBucketConfig()
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), endpoints...)
+	s.Start()
+	defer s.Stop()
+
+	req := &pb.AllowRequest{Namespace: "ns", BucketName: "b"}
+	if rsp, e := newClient(t, open).Allow(context.TODO(), req); e != nil || rsp.Status != pb.AllowResponse_OK {
+		t.Fatalf("Expecting the open endpoint to serve requests without credentials. Response %v, error %v", rsp, e)
+	}
+
+	client := newClient(t, secured)
+	if _, e := client.Allow(context.TODO(), req); grpc.Code(e) != codes.Unauthenticated {
+		t.Fatalf("Expecting the secured endpoint to require credentials. Error %v", e)
+	}
+
+	ctx := metadata.NewContext(context.TODO(), metadata.Pairs("authorization", "Bearer s3cr3t"))
+	if rsp, e := client.Allow(ctx, req); e != nil || rsp.Status != pb.AllowResponse_OK {
+		t.Fatalf("Expecting the secured endpoint to serve authenticated requests. Response %v, error %v", rsp, e)
+	}
+}
+
+func TestAuthorizedNamespaces(t *testing.T) {
+	hostport := freeHostport(t)
+	endpoints, e := New(Config{
+		Type:       TypeGRPC,
+		Hostport:   hostport,
+		Tokens:     map[string]string{"s3cr3t": "billing"},
+		Namespaces: map[string][]string{"billing": {"billing"}}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cfg := config.NewDefaultServiceConfig()
+	cfg.GlobalDefaultBucket = config.NewDefaultBucketConfig()
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), endpoints...)
+	s.Start()
+	defer s.Stop()
+
+	client := newClient(t, hostport)
+	ctx := metadata.NewContext(context.TODO(), metadata.Pairs("authorization", "Bearer s3cr3t"))
+	req := &pb.AllowRequest{Namespace: "billing", BucketName: "b"}
+	if rsp, e := client.Allow(ctx, req); e != nil || rsp.Status != pb.AllowResponse_OK {
+		t.Fatalf("Expecting requests for permitted namespaces to be served. Response %v, error %v", rsp, e)
+	}
+
+	req = &pb.AllowRequest{Namespace: "ads", BucketName: "b"}
+	if _, e := client.Allow(ctx, req); grpc.Code(e) != codes.PermissionDenied {
+		t.Fatalf("Expecting requests for other namespaces to be denied. Error %v", e)
+	}
+
+	query := &pb.QueryRequest{Namespace: "ads", BucketName: "b"}
+	if _, e := client.Query(ctx, query); grpc.Code(e) != codes.PermissionDenied {
+		t.Fatalf("Expecting queries of other namespaces to be denied. Error %v", e)
+	}
+}
+
+func freeHostport(t *testing.T) string {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func newClient(t *testing.T, hostport string) pb.QuotaServiceClient {
+	conn, e := grpc.Dial(hostport, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return pb.NewQuotaServiceClient(conn)
+}
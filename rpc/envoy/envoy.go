@@ -0,0 +1,224 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package envoy implements Envoy's rate limit service protocol, so that Envoy and Istio sidecars
+// can use the quota service as their global rate limiter. Each descriptor Envoy sends is mapped to
+// a bucket, which it claims tokens from without waiting.
+package envoy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/lifecycle"
+	"github.com/maniksurtani/quotaservice/logging"
+	pb "github.com/maniksurtani/quotaservice/protos/envoy"
+	"github.com/maniksurtani/quotaservice/rpc/auth"
+	"github.com/maniksurtani/quotaservice/rpc/certs"
+	"github.com/maniksurtani/quotaservice/rpc/limits"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+)
+
+// Mapping maps the descriptors Envoy sends for a domain to the bucket they're rate limited by.
+type Mapping struct {
+	// Domain the mapping applies to, or empty if it applies to every domain.
+	Domain string `yaml:"domain"`
+	// Keys of the entries descriptors must have, in order, to be mapped.
+	Keys []string `yaml:"keys,flow"`
+	// Namespace and bucket to claim tokens from, in which {domain} is replaced with the request's
+	// domain, and {key} with the value of the descriptor's entry with the key.
+	Namespace string `yaml:"namespace"`
+	Bucket    string `yaml:"bucket"`
+}
+
+func (m *Mapping) matches(domain string, d *pb.RateLimitDescriptor) bool {
+	if m.Domain != "" && m.Domain != domain || len(m.Keys) != len(d.Entries) {
+		return false
+	}
+
+	for i, k := range m.Keys {
+		if d.Entries[i].Key != k {
+			return false
+		}
+	}
+
+	return true
+}
+
+type EnvoyEndpoint struct {
+	hostport      string
+	mappings      []Mapping
+	grpcServer    *grpc.Server
+	currentStatus lifecycle.Status
+	qs            quotaservice.QuotaService
+	tlsConfig     *tls.Config
+	authenticator auth.Authenticator
+	authorizer    auth.Authorizer
+	limits        limits.Limits
+	requests      *limits.RequestLimiter
+}
+
+// New creates a new EnvoyEndpoint, listening on hostport, in the form "host:port". Descriptors are
+// mapped by the first of the mappings that matches them. Those no mapping matches claim tokens
+// from the bucket in the namespace named after the domain that's named after their entries, as
+// in "key1=value1,key2=value2".
+func New(hostport string, mappings ...Mapping) *EnvoyEndpoint {
+	if !strings.Contains(hostport, ":") {
+		panic(fmt.Sprintf("hostport should be in the format 'host:port', but is currently %v",
+			hostport))
+	}
+	return &EnvoyEndpoint{hostport: hostport, mappings: mappings}
+}
+
+// UseTLS has the endpoint serve TLS as cfg configures it, erroring if its certificates can't be
+// loaded. Must be called before the endpoint starts.
+func (e *EnvoyEndpoint) UseTLS(cfg *certs.Config) error {
+	tlsConfig, err := cfg.ServerTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	e.tlsConfig = tlsConfig
+	return nil
+}
+
+// UseAuth has the endpoint serve only the requests a authenticates, rejecting others with
+// codes.Unauthenticated. Must be called before the endpoint starts.
+func (e *EnvoyEndpoint) UseAuth(a auth.Authenticator) {
+	e.authenticator = a
+}
+
+// UseAuthorizer has the endpoint serve only the requests whose descriptors map to namespaces a
+// permits the client's identity, rejecting others with codes.PermissionDenied before claiming any
+// tokens. Clients must authenticate, so UseAuth must be called too. Must be called before the
+// endpoint starts.
+func (e *EnvoyEndpoint) UseAuthorizer(a auth.Authorizer) {
+	e.authorizer = a
+}
+
+// UseLimits has the endpoint limit the connections and requests it serves at once. Must be called
+// before the endpoint starts.
+func (e *EnvoyEndpoint) UseLimits(l limits.Limits) {
+	e.limits = l
+	e.requests = l.Requests()
+}
+
+func (e *EnvoyEndpoint) Init(qs quotaservice.QuotaService) {
+	e.qs = qs
+}
+
+func (e *EnvoyEndpoint) Start() {
+	lis, err := e.limits.Listen(e.hostport)
+	if err != nil {
+		logging.Fatalf("Cannot start Envoy rate limit service on port %v. Error %v", e.hostport, err)
+		panic(fmt.Sprintf("Cannot start Envoy rate limit service on port %v. Error %v", e.hostport, err))
+	}
+
+	opts := e.limits.ServerOptions()
+	if e.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(e.tlsConfig)))
+	}
+
+	e.grpcServer = grpc.NewServer(opts...)
+	pb.RegisterRateLimitServiceServer(e.grpcServer, e)
+	go e.grpcServer.Serve(lis)
+	e.currentStatus = lifecycle.Started
+	logging.Printf("Starting Envoy rate limit service on %v", e.hostport)
+}
+
+func (e *EnvoyEndpoint) Stop() {
+	e.grpcServer.Stop()
+	e.currentStatus = lifecycle.Stopped
+}
+
+// ShouldRateLimit claims hits_addend tokens from the bucket of each of the request's descriptors,
+// limiting the request if any of them refuses. Descriptors whose bucket doesn't exist aren't
+// limited. Errors other than refusals fail the request, so Envoy applies its failure mode.
+func (e *EnvoyEndpoint) ShouldRateLimit(ctx context.Context, req *pb.RateLimitRequest) (*pb.RateLimitResponse, error) {
+	if !e.requests.Admit() {
+		logging.Printf("Too many requests in flight on %v", e.hostport)
+		return nil, grpc.Errorf(codes.ResourceExhausted, "Too many requests in flight")
+	}
+	defer e.requests.Done()
+
+	var identity string
+	if e.authenticator != nil {
+		var err error
+		if identity, err = e.authenticator.Authenticate(ctx); err != nil {
+			logging.Printf("Unauthenticated request. Error %v", err)
+			return nil, grpc.Errorf(codes.Unauthenticated, "%v", err)
+		}
+	}
+
+	if req.Domain == "" || len(req.Descriptors) == 0 {
+		logging.Debugf("Invalid request %+v", req)
+		return nil, grpc.Errorf(codes.InvalidArgument, "Requests need a domain and descriptors")
+	}
+
+	var tokensRequested int64 = 1
+	if req.HitsAddend > 0 {
+		tokensRequested = int64(req.HitsAddend)
+	}
+
+	namespaces, names := make([]string, len(req.Descriptors)), make([]string, len(req.Descriptors))
+	for i, d := range req.Descriptors {
+		namespaces[i], names[i] = e.bucket(req.Domain, d)
+		if e.authorizer == nil {
+			continue
+		}
+
+		if err := e.authorizer.Authorize(identity, namespaces[i]); err != nil {
+			logging.Printf("Unauthorized request. Error %v", err)
+			return nil, grpc.Errorf(codes.PermissionDenied, "%v", err)
+		}
+	}
+
+	rsp := &pb.RateLimitResponse{OverallCode: pb.RateLimitResponse_OK}
+	for i := range req.Descriptors {
+		namespace, name := namespaces[i], names[i]
+		status := &pb.RateLimitResponse_DescriptorStatus{Code: pb.RateLimitResponse_OK}
+		if _, err := e.qs.Allow(namespace, name, tokensRequested, 0); err != nil {
+			qsErr, ok := err.(quotaservice.QuotaServiceError)
+			if !ok {
+				logging.Printf("Caught error %v", err)
+				return nil, grpc.Errorf(codes.Internal, "%v", err)
+			}
+
+			if qsErr.Reason != quotaservice.ER_NO_BUCKET {
+				status.Code = pb.RateLimitResponse_OVER_LIMIT
+				rsp.OverallCode = pb.RateLimitResponse_OVER_LIMIT
+			}
+		}
+
+		rsp.Statuses = append(rsp.Statuses, status)
+	}
+
+	return rsp, nil
+}
+
+// bucket returns the namespace and name of the bucket the descriptor is rate limited by.
+func (e *EnvoyEndpoint) bucket(domain string, d *pb.RateLimitDescriptor) (string, string) {
+	for i := range e.mappings {
+		if m := &e.mappings[i]; m.matches(domain, d) {
+			replacements := []string{"{domain}", domain}
+			for _, entry := range d.Entries {
+				replacements = append(replacements, "{"+entry.Key+"}", entry.Value)
+			}
+
+			r := strings.NewReplacer(replacements...)
+			return r.Replace(m.Namespace), r.Replace(m.Bucket)
+		}
+	}
+
+	entries := make([]string, len(d.Entries))
+	for i, entry := range d.Entries {
+		entries[i] = entry.Key + "=" + entry.Value
+	}
+
+	return domain, strings.Join(entries, ",")
+}
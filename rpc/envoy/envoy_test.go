@@ -0,0 +1,151 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package envoy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos/envoy"
+	"github.com/maniksurtani/quotaservice/rpc/auth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func descriptor(kvs ...string) *pb.RateLimitDescriptor {
+	d := &pb.RateLimitDescriptor{}
+	for i := 0; i < len(kvs); i += 2 {
+		d.Entries = append(d.Entries, &pb.RateLimitDescriptor_Entry{Key: kvs[i], Value: kvs[i+1]})
+	}
+
+	return d
+}
+
+func statusCodes(rsp *pb.RateLimitResponse) []pb.RateLimitResponse_Code {
+	var c []pb.RateLimitResponse_Code
+	for _, s := range rsp.Statuses {
+		c = append(c, s.Code)
+	}
+
+	return c
+}
+
+func TestShouldRateLimit(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+	ns.DynamicBucketTemplate.Size = 2
+	ns.DynamicBucketTemplate.FillRate = 1
+	ns.DynamicBucketTemplate.MaxDebtMillis = 0
+	cfg.AddNamespace("edge", ns)
+
+	ns = config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 3
+	b.FillRate = 1
+	b.MaxDebtMillis = 0
+	ns.AddBucket("generic_key=api", b)
+	cfg.AddNamespace("ingress", ns)
+
+	e := New("localhost:0", Mapping{Domain: "ingress", Keys: []string{"remote_address"}, Namespace: "edge", Bucket: "{domain}.{remote_address}"})
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), e)
+	s.Start()
+	defer s.Stop()
+
+	req := &pb.RateLimitRequest{
+		Domain:      "ingress",
+		Descriptors: []*pb.RateLimitDescriptor{descriptor("remote_address", "10.0.0.1"), descriptor("generic_key", "api")}}
+	for i := 0; i < 2; i++ {
+		rsp, err := e.ShouldRateLimit(context.TODO(), req)
+		if err != nil || rsp.OverallCode != pb.RateLimitResponse_OK {
+			t.Fatalf("Expecting request %v to be allowed. Response %v, error %v", i, rsp, err)
+		}
+	}
+
+	// The mapped bucket is empty, while the unmapped descriptor's bucket has a token left.
+	rsp, _ := e.ShouldRateLimit(context.TODO(), req)
+	expected := []pb.RateLimitResponse_Code{pb.RateLimitResponse_OVER_LIMIT, pb.RateLimitResponse_OK}
+	if rsp.OverallCode != pb.RateLimitResponse_OVER_LIMIT || !reflect.DeepEqual(statusCodes(rsp), expected) {
+		t.Fatalf("Expecting the request to be limited by its first descriptor. Response %v", rsp)
+	}
+
+	// Other callers have buckets of their own.
+	rsp, _ = e.ShouldRateLimit(context.TODO(), &pb.RateLimitRequest{
+		Domain:      "ingress",
+		Descriptors: []*pb.RateLimitDescriptor{descriptor("remote_address", "10.0.0.2")},
+		HitsAddend:  2})
+	if rsp.OverallCode != pb.RateLimitResponse_OK {
+		t.Fatalf("Expecting other callers to be allowed. Response %v", rsp)
+	}
+
+	// Descriptors without buckets aren't limited.
+	rsp, _ = e.ShouldRateLimit(context.TODO(), &pb.RateLimitRequest{
+		Domain:      "ingress",
+		Descriptors: []*pb.RateLimitDescriptor{descriptor("path", "/")}})
+	if rsp.OverallCode != pb.RateLimitResponse_OK {
+		t.Fatalf("Expecting descriptors without buckets not to be limited. Response %v", rsp)
+	}
+
+	if _, err := e.ShouldRateLimit(context.TODO(), &pb.RateLimitRequest{Domain: "ingress"}); grpc.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expecting requests without descriptors to be invalid. Error %v", err)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	e := New("localhost:0",
+		Mapping{Domain: "a", Keys: []string{"k1", "k2"}, Namespace: "ns", Bucket: "{k2}-{k1}"},
+		Mapping{Keys: []string{"k1"}, Namespace: "{domain}", Bucket: "by_k1"})
+
+	tests := []struct {
+		domain              string
+		d                   *pb.RateLimitDescriptor
+		namespace, expected string
+	}{
+		{"a", descriptor("k1", "v1", "k2", "v2"), "ns", "v2-v1"},
+		{"b", descriptor("k1", "v1", "k2", "v2"), "b", "k1=v1,k2=v2"},
+		{"b", descriptor("k1", "v1"), "b", "by_k1"},
+		{"a", descriptor("k2", "v2", "k1", "v1"), "a", "k2=v2,k1=v1"}}
+
+	for _, test := range tests {
+		if namespace, name := e.bucket(test.domain, test.d); namespace != test.namespace || name != test.expected {
+			t.Errorf("Expecting %v in domain %v to map to %v:%v, got %v:%v", test.d, test.domain, test.namespace, test.expected, namespace, name)
+		}
+	}
+}
+
+func TestShouldRateLimitAuthorized(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+	ns.DynamicBucketTemplate.Size = 1
+	ns.DynamicBucketTemplate.FillRate = 1
+	ns.DynamicBucketTemplate.MaxDebtMillis = 0
+	cfg.AddNamespace("edge", ns)
+
+	e := New("localhost:0", Mapping{Keys: []string{"remote_address"}, Namespace: "edge", Bucket: "{remote_address}"})
+	e.UseAuth(auth.NewTokenAuthenticator(map[string]string{"s3cr3t": "gateway"}))
+	e.UseAuthorizer(auth.NewNamespaceAuthorizer(map[string][]string{"gateway": {"edge"}}))
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), e)
+	s.Start()
+	defer s.Stop()
+
+	ctx := metadata.NewContext(context.TODO(), metadata.Pairs("authorization", "Bearer s3cr3t"))
+	req := &pb.RateLimitRequest{
+		Domain:      "ingress",
+		Descriptors: []*pb.RateLimitDescriptor{descriptor("remote_address", "10.0.0.1"), descriptor("generic_key", "api")}}
+	if _, err := e.ShouldRateLimit(ctx, req); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expecting descriptors mapping to other namespaces to be denied. Error %v", err)
+	}
+
+	// Denied requests claim no tokens, not even from the namespaces they may use.
+	req.Descriptors = req.Descriptors[:1]
+	if rsp, err := e.ShouldRateLimit(ctx, req); err != nil || rsp.OverallCode != pb.RateLimitResponse_OK {
+		t.Fatalf("Expecting the permitted descriptor to be allowed. Response %v, error %v", rsp, err)
+	}
+}
@@ -4,25 +4,58 @@
 package grpc
 
 import (
+	"crypto/tls"
 	"fmt"
-	"net"
+	"io"
 	"strings"
 
 	"github.com/maniksurtani/quotaservice"
 	"github.com/maniksurtani/quotaservice/lifecycle"
 	"github.com/maniksurtani/quotaservice/logging"
 	pb "github.com/maniksurtani/quotaservice/protos"
+	healthpb "github.com/maniksurtani/quotaservice/protos/health"
+	rpb "github.com/maniksurtani/quotaservice/protos/reflection"
+	pbv2 "github.com/maniksurtani/quotaservice/protos/v2"
+	"github.com/maniksurtani/quotaservice/rpc/auth"
+	"github.com/maniksurtani/quotaservice/rpc/certs"
+	"github.com/maniksurtani/quotaservice/rpc/limits"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
 	"time"
 )
 
+// priorityKey is the metadata key requests carry their priority in. Requests whose priority is
+// "high" are served at quotaservice.PRIORITY_HIGH, all others at quotaservice.PRIORITY_NORMAL.
+const priorityKey = "priority"
+
+// callerIDKey is the metadata key requests may identify their caller in, if they don't set
+// caller_id. Metadata keys prefixed with callerLabelPrefix, such as "caller-label-service", are
+// caller labels, for those the request's caller_labels don't set.
+const (
+	callerIDKey       = "caller-id"
+	callerLabelPrefix = "caller-label-"
+)
+
+// serviceName is the name the quota service is known by to gRPC's health checking protocol. The
+// server as a whole is known by the empty name.
+const serviceName = "quotaservice.QuotaService"
+
 type GrpcEndpoint struct {
 	hostport      string
 	grpcServer    *grpc.Server
 	currentStatus lifecycle.Status
 	qs            quotaservice.QuotaService
+	tlsConfig     *tls.Config
+	authenticator auth.Authenticator
+	authorizer    auth.Authorizer
+	limits        limits.Limits
+	health        *healthServer
+	interceptors  []AllowInterceptor
+	allowHandler  AllowHandler
 }
 
 // New creates a new GrpcEndpoint, listening on hostport. Hostport is a string in the form
@@ -32,7 +65,48 @@ func New(hostport string) *GrpcEndpoint {
 		panic(fmt.Sprintf("hostport should be in the format 'host:port', but is currently %v",
 			hostport))
 	}
-	return &GrpcEndpoint{hostport: hostport}
+	return &GrpcEndpoint{hostport: hostport, health: newHealthServer()}
+}
+
+// UseTLS has the endpoint serve TLS as cfg configures it, erroring if its certificates can't be
+// loaded. Must be called before the endpoint starts.
+func (g *GrpcEndpoint) UseTLS(cfg *certs.Config) error {
+	tlsConfig, err := cfg.ServerTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	g.tlsConfig = tlsConfig
+	return nil
+}
+
+// UseAuth has the endpoint serve only the quota service requests a authenticates, rejecting others
+// with codes.Unauthenticated. Health checks and reflection aren't authenticated. Must be called
+// before the endpoint starts.
+func (g *GrpcEndpoint) UseAuth(a auth.Authenticator) {
+	g.authenticator = a
+}
+
+// UseAuthorizer has the endpoint serve only the requests for namespaces a permits the client's
+// identity, rejecting others with codes.PermissionDenied. Clients must authenticate, so UseAuth
+// must be called too. Must be called before the endpoint starts.
+func (g *GrpcEndpoint) UseAuthorizer(a auth.Authorizer) {
+	g.authorizer = a
+}
+
+// UseInterceptors has the endpoint call interceptors around each Allow request it serves, on
+// unary calls and streams alike, once the client has authenticated. Interceptors are called in
+// the order they're added, the first outermost. Namespaces are authorized once the interceptors
+// have called the handler they're given, so requests they rewrite are authorized as rewritten.
+// Must be called before the endpoint starts.
+func (g *GrpcEndpoint) UseInterceptors(interceptors ...AllowInterceptor) {
+	g.interceptors = append(g.interceptors, interceptors...)
+}
+
+// UseLimits has the endpoint limit the connections and requests it serves at once. Must be called
+// before the endpoint starts.
+func (g *GrpcEndpoint) UseLimits(l limits.Limits) {
+	g.limits = l
 }
 
 func (g *GrpcEndpoint) Init(qs quotaservice.QuotaService) {
@@ -40,40 +114,128 @@ func (g *GrpcEndpoint) Init(qs quotaservice.QuotaService) {
 }
 
 func (g *GrpcEndpoint) Start() {
-	lis, err := net.Listen("tcp", g.hostport)
+	lis, err := g.limits.Listen(g.hostport)
 	if err != nil {
 		logging.Fatalf("Cannot start server on port %v. Error %v", g.hostport, err)
 		panic(fmt.Sprintf("Cannot start server on port %v. Error %v", g.hostport, err))
 	}
 
 	grpclog.SetLogger(logging.CurrentLogger())
-	g.grpcServer = grpc.NewServer()
+	opts := g.limits.ServerOptions()
+	if g.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(g.tlsConfig)))
+	}
+
+	reflection, err := newReflectionServer(
+		descriptor(&pb.AllowRequest{}),
+		descriptor(&pbv2.AllowRequest{}),
+		descriptor(&healthpb.HealthCheckRequest{}),
+		descriptor(&rpb.ServerReflectionRequest{}))
+	if err != nil {
+		panic(fmt.Sprintf("Cannot read the descriptors of gRPC services. Error %v", err))
+	}
+
+	g.allowHandler = chain(g.allow, g.interceptors...)
+	g.grpcServer = grpc.NewServer(opts...)
 	// Each service should be registered
-	pb.RegisterQuotaServiceServer(g.grpcServer, g)
+	requests := g.limits.Requests()
+	if requests != nil {
+		pb.RegisterQuotaServiceServer(g.grpcServer, &limitedService{g, requests})
+	} else {
+		pb.RegisterQuotaServiceServer(g.grpcServer, g)
+	}
+	pbv2.RegisterQuotaServiceServer(g.grpcServer, &v2Service{g, requests})
+	healthpb.RegisterHealthServer(g.grpcServer, g.health)
+	rpb.RegisterServerReflectionServer(g.grpcServer, reflection)
 	go g.grpcServer.Serve(lis)
+	g.health.setServingStatus(healthpb.HealthCheckResponse_SERVING, "", serviceName, v2ServiceName)
 	g.currentStatus = lifecycle.Started
 	logging.Printf("Starting server on %v", g.hostport)
 	logging.Printf("Server status: %v", g.currentStatus)
 }
 
+// Stop reports the endpoint as not serving to health checks, so load balancers stop sending it
+// requests.
 func (g *GrpcEndpoint) Stop() {
+	g.health.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING, "", serviceName, v2ServiceName)
 	g.currentStatus = lifecycle.Stopped
 }
 
 func (g *GrpcEndpoint) Allow(ctx context.Context, req *pb.AllowRequest) (*pb.AllowResponse, error) {
+	ctx, err := g.authenticated(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.allowHandler(ctx, req)
+}
+
+// AllowStream serves the Allow requests sent on the stream one at a time, so responses are sent
+// in the order requests were. Returns once the client closes its end of the stream, or with the
+// error of the first request that fails, such as with codes.PermissionDenied once it requests
+// tokens from a namespace it may not use.
+func (g *GrpcEndpoint) AllowStream(stream pb.QuotaService_AllowStreamServer) error {
+	ctx, err := g.authenticated(stream.Context())
+	if err != nil {
+		return err
+	}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		rsp, err := g.allowHandler(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		if err = stream.Send(rsp); err != nil {
+			return err
+		}
+	}
+}
+
+// allow serves an Allow request, once the endpoint's interceptors have, by the client whose
+// identity ctx carries, if it authenticated.
+func (g *GrpcEndpoint) allow(ctx context.Context, req *pb.AllowRequest) (*pb.AllowResponse, error) {
+	identity, _ := auth.FromContext(ctx)
 	rsp := new(pb.AllowResponse)
 	if invalid(req) {
-		logging.Printf("Invalid request %+v", req)
+		logging.Debugf("Invalid request %+v", req)
 		rsp.Status = pb.AllowResponse_REJECTED_INVALID_REQUEST
 		return rsp, nil
 	}
 
+	if err := g.authorize(identity, req.Namespace); err != nil {
+		return nil, err
+	}
+
 	var tokensRequested int64 = 1
 	if req.TokensRequested > 0 {
 		tokensRequested = req.TokensRequested
 	}
 
-	wait, err := g.qs.Allow(req.Namespace, req.BucketName, tokensRequested, req.MaxWaitMillisOverride)
+	c := caller(ctx, req, identity)
+	minTokens, maxTokens := tokensRequested, tokensRequested
+	if req.MaxTokens > 0 {
+		minTokens, maxTokens = req.MinTokens, req.MaxTokens
+		if minTokens == 0 {
+			minTokens = 1
+		}
+	}
+
+	var grant *quotaservice.Grant
+	var err error
+	if req.DryRun {
+		grant, err = g.qs.DryRun(req.Namespace, req.BucketName, req.Operation, minTokens, maxTokens, req.MaxWaitMillisOverride, req.MaxDebtMillisOverride, priority(ctx), c)
+	} else {
+		grant, err = g.qs.Claim(ctx, req.Namespace, req.BucketName, req.Operation, minTokens, maxTokens, req.MaxWaitMillisOverride, req.MaxDebtMillisOverride, priority(ctx), c)
+	}
 
 	if err != nil {
 		if qsErr, ok := err.(quotaservice.QuotaServiceError); ok {
@@ -84,15 +246,306 @@ func (g *GrpcEndpoint) Allow(ctx context.Context, req *pb.AllowRequest) (*pb.All
 		}
 	} else {
 		rsp.Status = pb.AllowResponse_OK
-		rsp.TokensGranted = req.TokensRequested
+		rsp.TokensGranted = grant.Tokens
+		rsp.WaitMillis = grant.Wait.Nanoseconds() / int64(time.Millisecond)
+		rsp.LeaseId = grant.LeaseID
+	}
+
+	if grant.Remaining >= 0 {
+		rsp.TokensRemaining = grant.Remaining
+	}
+
+	// Rounded up, so clients retrying after it don't retry too soon.
+	rsp.RetryAfterMillis = int64((grant.RetryAfter + time.Millisecond - 1) / time.Millisecond)
+	return rsp, nil
+}
+
+func (g *GrpcEndpoint) Release(ctx context.Context, req *pb.ReleaseRequest) (*pb.ReleaseResponse, error) {
+	identity, err := g.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := new(pb.ReleaseResponse)
+	if req.BucketName == "" || req.Namespace == "" || req.LeaseId == "" {
+		logging.Debugf("Invalid request %+v", req)
+		rsp.Status = pb.ReleaseResponse_REJECTED_INVALID_REQUEST
+		return rsp, nil
+	}
+
+	if err := g.authorize(identity, req.Namespace); err != nil {
+		return nil, err
+	}
+
+	if err := g.qs.Release(req.Namespace, req.BucketName, req.LeaseId); err != nil {
+		if qsErr, ok := err.(quotaservice.QuotaServiceError); ok {
+			rsp.Status = toPBReleaseStatus(qsErr)
+		} else {
+			logging.Printf("Caught error %v", err)
+			rsp.Status = pb.ReleaseResponse_REJECTED_SERVER_ERROR
+		}
+	} else {
+		rsp.Status = pb.ReleaseResponse_OK
+	}
+
+	return rsp, nil
+}
+
+func (g *GrpcEndpoint) Reserve(ctx context.Context, req *pb.ReserveRequest) (*pb.ReserveResponse, error) {
+	identity, err := g.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := new(pb.ReserveResponse)
+	if req.BucketName == "" || req.Namespace == "" {
+		logging.Debugf("Invalid request %+v", req)
+		rsp.Status = pb.AllowResponse_REJECTED_INVALID_REQUEST
+		return rsp, nil
+	}
+
+	if err := g.authorize(identity, req.Namespace); err != nil {
+		return nil, err
+	}
+
+	var tokensRequested int64 = 1
+	if req.TokensRequested > 0 {
+		tokensRequested = req.TokensRequested
+	}
+
+	ttl := time.Duration(req.TtlMillis) * time.Millisecond
+	reservationID, tokensReserved, wait, err := g.qs.Reserve(ctx, req.Namespace, req.BucketName, req.Operation, tokensRequested, req.MaxWaitMillisOverride, ttl, priority(ctx))
+
+	if err != nil {
+		if qsErr, ok := err.(quotaservice.QuotaServiceError); ok {
+			rsp.Status = toPBStatus(qsErr)
+		} else {
+			logging.Printf("Caught error %v", err)
+			rsp.Status = pb.AllowResponse_REJECTED_SERVER_ERROR
+		}
+	} else {
+		rsp.Status = pb.AllowResponse_OK
+		rsp.TokensReserved = tokensReserved
 		rsp.WaitMillis = wait.Nanoseconds() / int64(time.Millisecond)
+		rsp.ReservationId = reservationID
 	}
 
 	return rsp, nil
 }
 
+func (g *GrpcEndpoint) CommitReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.ReservationResponse, error) {
+	if _, err := g.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	return reservationResponse(req, g.qs.CommitReservation), nil
+}
+
+func (g *GrpcEndpoint) CancelReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.ReservationResponse, error) {
+	if _, err := g.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	return reservationResponse(req, g.qs.CancelReservation), nil
+}
+
+func (g *GrpcEndpoint) Feedback(ctx context.Context, req *pb.FeedbackRequest) (*pb.FeedbackResponse, error) {
+	identity, err := g.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := new(pb.FeedbackResponse)
+	if req.BucketName == "" || req.Namespace == "" || req.ErrorRate < 0 || req.ErrorRate > 1 || req.LatencyMillis < 0 {
+		logging.Debugf("Invalid request %+v", req)
+		rsp.Status = pb.FeedbackResponse_REJECTED_INVALID_REQUEST
+		return rsp, nil
+	}
+
+	if err := g.authorize(identity, req.Namespace); err != nil {
+		return nil, err
+	}
+
+	latency := time.Duration(req.LatencyMillis) * time.Millisecond
+	fillRate, err := g.qs.Feedback(req.Namespace, req.BucketName, req.ErrorRate, latency)
+	if err != nil {
+		if qsErr, ok := err.(quotaservice.QuotaServiceError); ok {
+			rsp.Status = toPBFeedbackStatus(qsErr)
+		} else {
+			logging.Printf("Caught error %v", err)
+			rsp.Status = pb.FeedbackResponse_REJECTED_SERVER_ERROR
+		}
+	} else {
+		rsp.Status = pb.FeedbackResponse_OK
+		rsp.FillRate = fillRate
+	}
+
+	return rsp, nil
+}
+
+func (g *GrpcEndpoint) Query(ctx context.Context, req *pb.QueryRequest) (*pb.QueryResponse, error) {
+	identity, err := g.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := new(pb.QueryResponse)
+	if req.BucketName == "" || req.Namespace == "" || req.TokensRequested < 0 {
+		logging.Debugf("Invalid request %+v", req)
+		rsp.Status = pb.QueryResponse_REJECTED_INVALID_REQUEST
+		return rsp, nil
+	}
+
+	if err := g.authorize(identity, req.Namespace); err != nil {
+		return nil, err
+	}
+
+	var tokensRequested int64 = 1
+	if req.TokensRequested > 0 {
+		tokensRequested = req.TokensRequested
+	}
+
+	available, wait, err := g.qs.Query(req.Namespace, req.BucketName, tokensRequested)
+	if err != nil {
+		if qsErr, ok := err.(quotaservice.QuotaServiceError); ok {
+			rsp.Status = toPBQueryStatus(qsErr)
+		} else {
+			logging.Printf("Caught error %v", err)
+			rsp.Status = pb.QueryResponse_REJECTED_SERVER_ERROR
+		}
+	} else {
+		rsp.Status = pb.QueryResponse_OK
+		rsp.TokensAvailable = available
+		rsp.WaitMillis = wait.Nanoseconds() / int64(time.Millisecond)
+	}
+
+	return rsp, nil
+}
+
+// reservationResponse commits or cancels the reservation requested, as f does.
+func reservationResponse(req *pb.ReservationRequest, f func(reservationID string) error) *pb.ReservationResponse {
+	rsp := new(pb.ReservationResponse)
+	if req.ReservationId == "" {
+		logging.Debugf("Invalid request %+v", req)
+		rsp.Status = pb.ReservationResponse_REJECTED_INVALID_REQUEST
+		return rsp
+	}
+
+	if err := f(req.ReservationId); err == nil {
+		rsp.Status = pb.ReservationResponse_OK
+	} else if qsErr, ok := err.(quotaservice.QuotaServiceError); ok && qsErr.Reason == quotaservice.ER_NO_RESERVATION {
+		rsp.Status = pb.ReservationResponse_REJECTED_NO_RESERVATION
+	} else {
+		logging.Printf("Caught error %v", err)
+		rsp.Status = pb.ReservationResponse_REJECTED_SERVER_ERROR
+	}
+
+	return rsp
+}
+
+// authenticate returns the identity of the client making the request, if the endpoint has an
+// authenticator, or an error to respond to the request with if the authenticator doesn't
+// authenticate it.
+func (g *GrpcEndpoint) authenticate(ctx context.Context) (string, error) {
+	if g.authenticator == nil {
+		return "", nil
+	}
+
+	identity, err := g.authenticator.Authenticate(ctx)
+	if err != nil {
+		logging.Printf("Unauthenticated request. Error %v", err)
+		return "", grpc.Errorf(codes.Unauthenticated, "%v", err)
+	}
+
+	return identity, nil
+}
+
+// authenticated returns a copy of ctx carrying the identity of the client making the request, if
+// the endpoint has an authenticator, or an error to respond to the request with if the
+// authenticator doesn't authenticate it.
+func (g *GrpcEndpoint) authenticated(ctx context.Context) (context.Context, error) {
+	if g.authenticator == nil {
+		return ctx, nil
+	}
+
+	identity, err := g.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.NewContext(ctx, identity), nil
+}
+
+// authorize returns an error to respond to the request with if the endpoint has an authorizer,
+// and it doesn't permit the client authenticated as identity to use the namespace.
+func (g *GrpcEndpoint) authorize(identity, namespace string) error {
+	if g.authorizer == nil {
+		return nil
+	}
+
+	if err := g.authorizer.Authorize(identity, namespace); err != nil {
+		logging.Printf("Unauthorized request. Error %v", err)
+		return grpc.Errorf(codes.PermissionDenied, "%v", err)
+	}
+
+	return nil
+}
+
+// descriptor returns the gzipped FileDescriptorProto of the file declaring the message.
+func descriptor(m interface {
+	Descriptor() ([]byte, []int)
+}) []byte {
+	d, _ := m.Descriptor()
+	return d
+}
+
+// caller returns who's making the request: the caller_id and caller_labels it sets, completed from
+// its metadata. Requests that don't identify their caller either way are identified as the client
+// authenticated as identity.
+func caller(ctx context.Context, req *pb.AllowRequest, identity string) quotaservice.Caller {
+	c := quotaservice.Caller{ID: req.CallerId, Labels: make(map[string]string)}
+	if md, ok := metadata.FromContext(ctx); ok {
+		if c.ID == "" && len(md[callerIDKey]) > 0 {
+			c.ID = md[callerIDKey][0]
+		}
+
+		for k, v := range md {
+			if strings.HasPrefix(k, callerLabelPrefix) && len(v) > 0 {
+				c.Labels[strings.TrimPrefix(k, callerLabelPrefix)] = v[0]
+			}
+		}
+	}
+
+	for k, v := range req.CallerLabels {
+		c.Labels[k] = v
+	}
+
+	if len(c.Labels) == 0 {
+		c.Labels = nil
+	}
+
+	if c.ID == "" {
+		c.ID = identity
+	}
+
+	return c
+}
+
+func priority(ctx context.Context) quotaservice.Priority {
+	if md, ok := metadata.FromContext(ctx); ok {
+		for _, p := range md[priorityKey] {
+			if strings.EqualFold(p, "high") {
+				return quotaservice.PRIORITY_HIGH
+			}
+		}
+	}
+
+	return quotaservice.PRIORITY_NORMAL
+}
+
 func invalid(req *pb.AllowRequest) bool {
-	return req.BucketName == "" || req.Namespace == ""
+	return req.BucketName == "" || req.Namespace == "" || req.MinTokens < 0 || req.MaxTokens < 0 ||
+		req.MinTokens > req.MaxTokens && req.MaxTokens > 0 || req.MaxDebtMillisOverride < 0
 }
 
 func toPBStatus(qsErr quotaservice.QuotaServiceError) (r pb.AllowResponse_Status) {
@@ -105,9 +558,54 @@ func toPBStatus(qsErr quotaservice.QuotaServiceError) (r pb.AllowResponse_Status
 		r = pb.AllowResponse_REJECTED_TOO_MANY_TOKENS_REQUESTED
 	case quotaservice.ER_TIMEOUT:
 		r = pb.AllowResponse_REJECTED_TIMEOUT
+	case quotaservice.ER_NOT_RESERVABLE:
+		r = pb.AllowResponse_REJECTED_NOT_RESERVABLE
+	case quotaservice.ER_TOO_MANY_WAITERS:
+		r = pb.AllowResponse_REJECTED_TOO_MANY_WAITERS
+	case quotaservice.ER_DENIED:
+		r = pb.AllowResponse_REJECTED_DENIED
 	default:
 		r = pb.AllowResponse_REJECTED_SERVER_ERROR
 	}
 
 	return
 }
+
+func toPBReleaseStatus(qsErr quotaservice.QuotaServiceError) (r pb.ReleaseResponse_Status) {
+	switch qsErr.Reason {
+	case quotaservice.ER_NO_BUCKET:
+		r = pb.ReleaseResponse_REJECTED_NO_BUCKET
+	case quotaservice.ER_NO_LEASE:
+		r = pb.ReleaseResponse_REJECTED_NO_LEASE
+	default:
+		r = pb.ReleaseResponse_REJECTED_SERVER_ERROR
+	}
+
+	return
+}
+
+func toPBFeedbackStatus(qsErr quotaservice.QuotaServiceError) (r pb.FeedbackResponse_Status) {
+	switch qsErr.Reason {
+	case quotaservice.ER_NO_BUCKET:
+		r = pb.FeedbackResponse_REJECTED_NO_BUCKET
+	case quotaservice.ER_NOT_ADAPTIVE:
+		r = pb.FeedbackResponse_REJECTED_NOT_ADAPTIVE
+	default:
+		r = pb.FeedbackResponse_REJECTED_SERVER_ERROR
+	}
+
+	return
+}
+
+func toPBQueryStatus(qsErr quotaservice.QuotaServiceError) (r pb.QueryResponse_Status) {
+	switch qsErr.Reason {
+	case quotaservice.ER_NO_BUCKET:
+		r = pb.QueryResponse_REJECTED_NO_BUCKET
+	case quotaservice.ER_TOO_MANY_BUCKETS:
+		r = pb.QueryResponse_REJECTED_TOO_MANY_BUCKETS
+	default:
+		r = pb.QueryResponse_REJECTED_SERVER_ERROR
+	}
+
+	return
+}
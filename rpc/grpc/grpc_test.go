@@ -0,0 +1,149 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos"
+	"github.com/maniksurtani/quotaservice/rpc/limits"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestCaller(t *testing.T) {
+	md := metadata.Pairs(callerIDKey, "from-metadata", callerLabelPrefix+"service", "billing", callerLabelPrefix+"region", "us-east")
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		req      *pb.AllowRequest
+		identity string
+		expected quotaservice.Caller
+	}{
+		{"none", nil, &pb.AllowRequest{}, "", quotaservice.Caller{}},
+		{"identity", nil, &pb.AllowRequest{}, "authenticated", quotaservice.Caller{ID: "authenticated"}},
+		{"request", nil,
+			&pb.AllowRequest{CallerId: "from-request", CallerLabels: map[string]string{"service": "ads"}}, "authenticated",
+			quotaservice.Caller{ID: "from-request", Labels: map[string]string{"service": "ads"}}},
+		{"metadata", md, &pb.AllowRequest{}, "authenticated",
+			quotaservice.Caller{ID: "from-metadata", Labels: map[string]string{"service": "billing", "region": "us-east"}}},
+		{"request overrides metadata", md,
+			&pb.AllowRequest{CallerId: "from-request", CallerLabels: map[string]string{"service": "ads"}}, "",
+			quotaservice.Caller{ID: "from-request", Labels: map[string]string{"service": "ads", "region": "us-east"}}}}
+
+	for _, test := range tests {
+		ctx := context.TODO()
+		if test.md != nil {
+			ctx = metadata.NewContext(ctx, test.md)
+		}
+
+		if c := caller(ctx, test.req, test.identity); !reflect.DeepEqual(c, test.expected) {
+			t.Errorf("%v: expecting caller %+v, got %+v", test.name, test.expected, c)
+		}
+	}
+}
+
+func TestAllowEstimates(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 2
+	b.FillRate = 1
+	b.WaitTimeoutMillis = 0
+	b.MaxDebtMillis = 0
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("ns", ns)
+
+	g := New("localhost:0")
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), g)
+	s.Start()
+	defer s.Stop()
+
+	req := &pb.AllowRequest{Namespace: "ns", BucketName: "b"}
+	for remaining := int64(1); remaining >= 0; remaining-- {
+		rsp, err := g.Allow(context.TODO(), req)
+		if err != nil || rsp.Status != pb.AllowResponse_OK || rsp.TokensRemaining != remaining || rsp.RetryAfterMillis != 0 {
+			t.Fatalf("Expecting tokens to be granted with %v remaining. Response %v, error %v", remaining, rsp, err)
+		}
+	}
+
+	rsp, err := g.Allow(context.TODO(), req)
+	if err != nil || rsp.Status != pb.AllowResponse_REJECTED_TIMEOUT || rsp.TokensRemaining != 0 {
+		t.Fatalf("Expecting the request to be refused. Response %v, error %v", rsp, err)
+	}
+
+	// The bucket frees a token a second.
+	if rsp.RetryAfterMillis <= 0 || rsp.RetryAfterMillis > 1000 {
+		t.Fatalf("Expecting to be told to retry within a second. Response %v", rsp)
+	}
+
+	req.BucketName = "missing"
+	if rsp, _ = g.Allow(context.TODO(), req); rsp.Status != pb.AllowResponse_REJECTED_NO_BUCKET || rsp.RetryAfterMillis != 0 {
+		t.Fatalf("Expecting no retry-after for missing buckets. Response %v", rsp)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 2
+	b.FillRate = 1
+	b.WaitTimeoutMillis = 0
+	b.MaxDebtMillis = 0
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("ns", ns)
+
+	g := New("localhost:0")
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), g)
+	s.Start()
+	defer s.Stop()
+
+	req := &pb.AllowRequest{Namespace: "ns", BucketName: "b", TokensRequested: 2, DryRun: true}
+	for i := 0; i < 3; i++ {
+		rsp, err := g.Allow(context.TODO(), req)
+		if err != nil || rsp.Status != pb.AllowResponse_OK || rsp.TokensGranted != 2 || rsp.TokensRemaining != 2 {
+			t.Fatalf("Expecting dry run %v to be granted without claiming tokens. Response %v, error %v", i, rsp, err)
+		}
+	}
+
+	req.TokensRequested = 3
+	if rsp, err := g.Allow(context.TODO(), req); err != nil || rsp.Status != pb.AllowResponse_REJECTED_TIMEOUT {
+		t.Fatalf("Expecting the dry run to be refused. Response %v, error %v", rsp, err)
+	}
+
+	req = &pb.AllowRequest{Namespace: "ns", BucketName: "b", MinTokens: 1, MaxTokens: 5, DryRun: true}
+	if rsp, err := g.Allow(context.TODO(), req); err != nil || rsp.Status != pb.AllowResponse_OK || rsp.TokensGranted != 2 {
+		t.Fatalf("Expecting the dry run to be granted the tokens the bucket has. Response %v, error %v", rsp, err)
+	}
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	cfg.GlobalDefaultBucket = config.NewDefaultBucketConfig()
+
+	g := New("localhost:0")
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), g)
+	s.Start()
+	defer s.Stop()
+
+	l := &limits.Limits{MaxConcurrentRequests: 1}
+	service := &limitedService{g, l.Requests()}
+	req := &pb.AllowRequest{Namespace: "ns", BucketName: "b"}
+	if rsp, err := service.Allow(context.TODO(), req); err != nil || rsp.Status != pb.AllowResponse_OK {
+		t.Fatalf("Expecting the request to be served. Response %v, error %v", rsp, err)
+	}
+
+	// A request is in flight.
+	service.requests.Admit()
+	if _, err := service.Allow(context.TODO(), req); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expecting requests beyond the limit to be rejected. Error %v", err)
+	}
+}
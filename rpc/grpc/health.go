@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"sync"
+
+	healthpb "github.com/maniksurtani/quotaservice/protos/health"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// healthServer implements gRPC's health checking protocol, reporting the status of each service,
+// or of the server as a whole under the empty service name.
+type healthServer struct {
+	sync.Mutex
+	statuses map[string]healthpb.HealthCheckResponse_ServingStatus
+	// Closed, and replaced, whenever a status changes.
+	changed chan struct{}
+}
+
+func newHealthServer() *healthServer {
+	return &healthServer{
+		statuses: make(map[string]healthpb.HealthCheckResponse_ServingStatus),
+		changed:  make(chan struct{})}
+}
+
+func (h *healthServer) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus, services ...string) {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, service := range services {
+		h.statuses[service] = status
+	}
+
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// status returns the service's status, and a channel closed once any status changes.
+func (h *healthServer) status(service string) (healthpb.HealthCheckResponse_ServingStatus, bool, <-chan struct{}) {
+	h.Lock()
+	defer h.Unlock()
+
+	status, known := h.statuses[service]
+	return status, known, h.changed
+}
+
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	status, known, _ := h.status(req.Service)
+	if !known {
+		return nil, grpc.Errorf(codes.NotFound, "Unknown service %v", req.Service)
+	}
+
+	return &healthpb.HealthCheckResponse{Status: status}, nil
+}
+
+// Watch sends the service's status, and again whenever it changes, until the client goes away.
+// Services that don't exist are SERVICE_UNKNOWN, should they be registered later.
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		status, known, changed := h.status(req.Service)
+		if !known {
+			status = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+
+		if status != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: status}); err != nil {
+				return err
+			}
+			last = status
+		}
+
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	healthpb "github.com/maniksurtani/quotaservice/protos/health"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestHealthCheck(t *testing.T) {
+	h := newHealthServer()
+	if _, err := h.Check(context.TODO(), &healthpb.HealthCheckRequest{Service: serviceName}); grpc.Code(err) != codes.NotFound {
+		t.Fatalf("Expecting unknown services not to be found. Error %v", err)
+	}
+
+	h.setServingStatus(healthpb.HealthCheckResponse_SERVING, "", serviceName)
+	for _, service := range []string{"", serviceName} {
+		rsp, err := h.Check(context.TODO(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil || rsp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("Expecting %q to be serving. Response %v, error %v", service, rsp, err)
+		}
+	}
+}
+
+func TestHealthWatch(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newHealthServer()
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, h)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{Service: serviceName})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(expected healthpb.HealthCheckResponse_ServingStatus) {
+		rsp, err := stream.Recv()
+		if err != nil || rsp.Status != expected {
+			t.Fatalf("Expecting status %v. Response %v, error %v", expected, rsp, err)
+		}
+	}
+
+	expect(healthpb.HealthCheckResponse_SERVICE_UNKNOWN)
+	h.setServingStatus(healthpb.HealthCheckResponse_SERVING, serviceName)
+	expect(healthpb.HealthCheckResponse_SERVING)
+
+	// Changes to other services aren't sent.
+	h.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING, "")
+	h.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING, serviceName)
+	expect(healthpb.HealthCheckResponse_NOT_SERVING)
+}
@@ -0,0 +1,30 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	pb "github.com/maniksurtani/quotaservice/protos"
+	"golang.org/x/net/context"
+)
+
+// AllowHandler serves an Allow request.
+type AllowHandler func(ctx context.Context, req *pb.AllowRequest) (*pb.AllowResponse, error)
+
+// AllowInterceptor is called around an Allow request, such as to log it, rewrite it, or refuse it
+// with rules of the embedder's own. It serves the request by calling next, which it may call with
+// a request of its own, or may respond without calling next at all, with a response or an error.
+// The identity of the client, if it authenticated, can be had with auth.FromContext.
+type AllowInterceptor func(ctx context.Context, req *pb.AllowRequest, next AllowHandler) (*pb.AllowResponse, error)
+
+// chain returns a handler calling the interceptors around h, the first outermost.
+func chain(h AllowHandler, interceptors ...AllowInterceptor) AllowHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], h
+		h = func(ctx context.Context, req *pb.AllowRequest) (*pb.AllowResponse, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+
+	return h
+}
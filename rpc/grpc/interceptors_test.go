@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos"
+	"github.com/maniksurtani/quotaservice/rpc/auth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) AllowInterceptor {
+		return func(ctx context.Context, req *pb.AllowRequest, next AllowHandler) (*pb.AllowResponse, error) {
+			calls = append(calls, name)
+			return next(ctx, req)
+		}
+	}
+
+	h := chain(func(ctx context.Context, req *pb.AllowRequest) (*pb.AllowResponse, error) {
+		calls = append(calls, "handler")
+		return &pb.AllowResponse{}, nil
+	}, record("first"), record("second"))
+
+	h(context.TODO(), &pb.AllowRequest{})
+	if expected := []string{"first", "second", "handler"}; !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("Expecting calls %v, got %v", expected, calls)
+	}
+}
+
+func TestUseInterceptors(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	cfg.GlobalDefaultBucket = config.NewDefaultBucketConfig()
+
+	var identities []string
+	g := New("localhost:0")
+	g.UseAuth(auth.NewTokenAuthenticator(map[string]string{"s3cr3t": "billing"}))
+	g.UseInterceptors(
+		func(ctx context.Context, req *pb.AllowRequest, next AllowHandler) (*pb.AllowResponse, error) {
+			identity, _ := auth.FromContext(ctx)
+			identities = append(identities, identity)
+			return next(ctx, req)
+		},
+		func(ctx context.Context, req *pb.AllowRequest, next AllowHandler) (*pb.AllowResponse, error) {
+			if req.BucketName == "forbidden" {
+				return &pb.AllowResponse{Status: pb.AllowResponse_REJECTED_DENIED}, nil
+			}
+
+			// Requests are rewritten, rather than mutated, so callers' requests are left alone.
+			rewritten := *req
+			rewritten.TokensRequested = 2
+			return next(ctx, &rewritten)
+		})
+
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), g)
+	s.Start()
+	defer s.Stop()
+
+	if _, err := g.Allow(context.TODO(), &pb.AllowRequest{Namespace: "ns", BucketName: "b"}); grpc.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Expecting clients to authenticate before interceptors are called. Error %v", err)
+	}
+
+	ctx := metadata.NewContext(context.TODO(), metadata.Pairs("authorization", "Bearer s3cr3t"))
+	rsp, err := g.Allow(ctx, &pb.AllowRequest{Namespace: "ns", BucketName: "b"})
+	if err != nil || rsp.Status != pb.AllowResponse_OK || rsp.TokensGranted != 2 {
+		t.Fatalf("Expecting the rewritten request to be granted. Response %v, error %v", rsp, err)
+	}
+
+	if rsp, err = g.Allow(ctx, &pb.AllowRequest{Namespace: "ns", BucketName: "forbidden"}); err != nil || rsp.Status != pb.AllowResponse_REJECTED_DENIED {
+		t.Fatalf("Expecting the interceptor to deny the request. Response %v, error %v", rsp, err)
+	}
+
+	if expected := []string{"billing", "billing"}; !reflect.DeepEqual(identities, expected) {
+		t.Fatalf("Expecting interceptors to be called with identities %v, got %v", expected, identities)
+	}
+}
@@ -0,0 +1,102 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"github.com/maniksurtani/quotaservice/logging"
+	pb "github.com/maniksurtani/quotaservice/protos"
+	"github.com/maniksurtani/quotaservice/rpc/limits"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// limitedService serves the quota service as the endpoint does, rejecting requests beyond those
+// its limiter admits with codes.ResourceExhausted. Streams are admitted, and hold their slot,
+// for as long as they're open.
+type limitedService struct {
+	*GrpcEndpoint
+	requests *limits.RequestLimiter
+}
+
+func (s *limitedService) admit() error {
+	if !s.requests.Admit() {
+		logging.Printf("Too many requests in flight on %v", s.hostport)
+		return grpc.Errorf(codes.ResourceExhausted, "Too many requests in flight")
+	}
+
+	return nil
+}
+
+func (s *limitedService) Allow(ctx context.Context, req *pb.AllowRequest) (*pb.AllowResponse, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	defer s.requests.Done()
+
+	return s.GrpcEndpoint.Allow(ctx, req)
+}
+
+func (s *limitedService) AllowStream(stream pb.QuotaService_AllowStreamServer) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+	defer s.requests.Done()
+
+	return s.GrpcEndpoint.AllowStream(stream)
+}
+
+func (s *limitedService) Release(ctx context.Context, req *pb.ReleaseRequest) (*pb.ReleaseResponse, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	defer s.requests.Done()
+
+	return s.GrpcEndpoint.Release(ctx, req)
+}
+
+func (s *limitedService) Reserve(ctx context.Context, req *pb.ReserveRequest) (*pb.ReserveResponse, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	defer s.requests.Done()
+
+	return s.GrpcEndpoint.Reserve(ctx, req)
+}
+
+func (s *limitedService) CommitReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.ReservationResponse, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	defer s.requests.Done()
+
+	return s.GrpcEndpoint.CommitReservation(ctx, req)
+}
+
+func (s *limitedService) CancelReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.ReservationResponse, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	defer s.requests.Done()
+
+	return s.GrpcEndpoint.CancelReservation(ctx, req)
+}
+
+func (s *limitedService) Feedback(ctx context.Context, req *pb.FeedbackRequest) (*pb.FeedbackResponse, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	defer s.requests.Done()
+
+	return s.GrpcEndpoint.Feedback(ctx, req)
+}
+
+func (s *limitedService) Query(ctx context.Context, req *pb.QueryRequest) (*pb.QueryResponse, error) {
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	defer s.requests.Done()
+
+	return s.GrpcEndpoint.Query(ctx, req)
+}
@@ -0,0 +1,218 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	rpb "github.com/maniksurtani/quotaservice/protos/reflection"
+	"google.golang.org/grpc/codes"
+)
+
+// reflectionServer implements gRPC's server reflection protocol, describing the services of the
+// files it's created with.
+type reflectionServer struct {
+	// Serialized FileDescriptorProtos, by file name.
+	files map[string][]byte
+	// Files' imports, by file name.
+	dependencies map[string][]string
+	// Name of the file declaring each fully qualified message, enum, service and method.
+	symbols  map[string]string
+	messages map[string]bool
+	services []string
+}
+
+// newReflectionServer creates a reflection server for the files whose gzipped FileDescriptorProtos
+// are given, as the Descriptor methods of generated messages return them.
+func newReflectionServer(descriptors ...[]byte) (*reflectionServer, error) {
+	r := &reflectionServer{
+		files:        make(map[string][]byte),
+		dependencies: make(map[string][]string),
+		symbols:      make(map[string]string),
+		messages:     make(map[string]bool)}
+
+	for _, gz := range descriptors {
+		zr, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := ioutil.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+
+		fd := &fileDescriptor{}
+		if err := proto.Unmarshal(b, fd); err != nil {
+			return nil, err
+		}
+
+		r.files[fd.Name] = b
+		r.dependencies[fd.Name] = fd.Dependencies
+		prefix := fd.Package + "."
+		for _, m := range fd.Messages {
+			r.addMessage(fd.Name, prefix, m)
+		}
+
+		for _, e := range fd.Enums {
+			r.symbols[prefix+e.Name] = fd.Name
+		}
+
+		for _, s := range fd.Services {
+			r.symbols[prefix+s.Name] = fd.Name
+			r.services = append(r.services, prefix+s.Name)
+			for _, m := range s.Methods {
+				r.symbols[prefix+s.Name+"."+m.Name] = fd.Name
+			}
+		}
+	}
+
+	sort.Strings(r.services)
+	return r, nil
+}
+
+func (r *reflectionServer) addMessage(file, prefix string, m *messageDescriptor) {
+	name := prefix + m.Name
+	r.symbols[name] = file
+	r.messages[name] = true
+	for _, nested := range m.Nested {
+		r.addMessage(file, name+".", nested)
+	}
+
+	for _, e := range m.Enums {
+		r.symbols[name+"."+e.Name] = file
+	}
+}
+
+func (r *reflectionServer) ServerReflectionInfo(stream rpb.ServerReflection_ServerReflectionInfoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err = stream.Send(r.respond(req)); err != nil {
+			return err
+		}
+	}
+}
+
+// respond answers a reflection request. Requests that set none of the request fields list services,
+// since listing them is requested with a field that may be empty.
+func (r *reflectionServer) respond(req *rpb.ServerReflectionRequest) *rpb.ServerReflectionResponse {
+	rsp := &rpb.ServerReflectionResponse{ValidHost: req.Host, OriginalRequest: req}
+	switch {
+	case req.FileByFilename != "":
+		if _, ok := r.files[req.FileByFilename]; !ok {
+			return notFound(rsp, "Unknown file "+req.FileByFilename)
+		}
+		rsp.FileDescriptorResponse = r.fileResponse(req.FileByFilename)
+	case req.FileContainingSymbol != "":
+		file, ok := r.symbols[req.FileContainingSymbol]
+		if !ok {
+			return notFound(rsp, "Unknown symbol "+req.FileContainingSymbol)
+		}
+		rsp.FileDescriptorResponse = r.fileResponse(file)
+	case req.FileContainingExtension != nil:
+		// Proto3 files declare no extensions.
+		return notFound(rsp, "Unknown extension of "+req.FileContainingExtension.ContainingType)
+	case req.AllExtensionNumbersOfType != "":
+		if !r.messages[req.AllExtensionNumbersOfType] {
+			return notFound(rsp, "Unknown type "+req.AllExtensionNumbersOfType)
+		}
+		rsp.AllExtensionNumbersResponse = &rpb.ExtensionNumberResponse{BaseTypeName: req.AllExtensionNumbersOfType}
+	default:
+		rsp.ListServicesResponse = &rpb.ListServiceResponse{}
+		for _, s := range r.services {
+			rsp.ListServicesResponse.Service = append(rsp.ListServicesResponse.Service, &rpb.ServiceResponse{Name: s})
+		}
+	}
+
+	return rsp
+}
+
+// fileResponse returns the file's descriptor, followed by those of the files it imports.
+func (r *reflectionServer) fileResponse(file string) *rpb.FileDescriptorResponse {
+	rsp := &rpb.FileDescriptorResponse{}
+	seen := make(map[string]bool)
+	var add func(file string)
+	add = func(file string) {
+		b, ok := r.files[file]
+		if !ok || seen[file] {
+			return
+		}
+
+		seen[file] = true
+		rsp.FileDescriptorProto = append(rsp.FileDescriptorProto, b)
+		for _, d := range r.dependencies[file] {
+			add(d)
+		}
+	}
+
+	add(file)
+	return rsp
+}
+
+func notFound(rsp *rpb.ServerReflectionResponse, msg string) *rpb.ServerReflectionResponse {
+	rsp.ErrorResponse = &rpb.ErrorResponse{ErrorCode: int32(codes.NotFound), ErrorMessage: msg}
+	return rsp
+}
+
+// The parts of google/protobuf/descriptor.proto that name a file's symbols.
+type fileDescriptor struct {
+	Name         string               `protobuf:"bytes,1,opt,name=name"`
+	Package      string               `protobuf:"bytes,2,opt,name=package"`
+	Dependencies []string             `protobuf:"bytes,3,rep,name=dependency"`
+	Messages     []*messageDescriptor `protobuf:"bytes,4,rep,name=message_type"`
+	Enums        []*enumDescriptor    `protobuf:"bytes,5,rep,name=enum_type"`
+	Services     []*serviceDescriptor `protobuf:"bytes,6,rep,name=service"`
+}
+
+type messageDescriptor struct {
+	Name   string               `protobuf:"bytes,1,opt,name=name"`
+	Nested []*messageDescriptor `protobuf:"bytes,3,rep,name=nested_type"`
+	Enums  []*enumDescriptor    `protobuf:"bytes,4,rep,name=enum_type"`
+}
+
+type enumDescriptor struct {
+	Name string `protobuf:"bytes,1,opt,name=name"`
+}
+
+type serviceDescriptor struct {
+	Name    string              `protobuf:"bytes,1,opt,name=name"`
+	Methods []*methodDescriptor `protobuf:"bytes,2,rep,name=method"`
+}
+
+type methodDescriptor struct {
+	Name string `protobuf:"bytes,1,opt,name=name"`
+}
+
+func (m *fileDescriptor) Reset()         { *m = fileDescriptor{} }
+func (m *fileDescriptor) String() string { return proto.CompactTextString(m) }
+func (*fileDescriptor) ProtoMessage()    {}
+
+func (m *messageDescriptor) Reset()         { *m = messageDescriptor{} }
+func (m *messageDescriptor) String() string { return proto.CompactTextString(m) }
+func (*messageDescriptor) ProtoMessage()    {}
+
+func (m *enumDescriptor) Reset()         { *m = enumDescriptor{} }
+func (m *enumDescriptor) String() string { return proto.CompactTextString(m) }
+func (*enumDescriptor) ProtoMessage()    {}
+
+func (m *serviceDescriptor) Reset()         { *m = serviceDescriptor{} }
+func (m *serviceDescriptor) String() string { return proto.CompactTextString(m) }
+func (*serviceDescriptor) ProtoMessage()    {}
+
+func (m *methodDescriptor) Reset()         { *m = methodDescriptor{} }
+func (m *methodDescriptor) String() string { return proto.CompactTextString(m) }
+func (*methodDescriptor) ProtoMessage()    {}
@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/maniksurtani/quotaservice/protos"
+	healthpb "github.com/maniksurtani/quotaservice/protos/health"
+	rpb "github.com/maniksurtani/quotaservice/protos/reflection"
+	"google.golang.org/grpc/codes"
+)
+
+func TestReflection(t *testing.T) {
+	r, err := newReflectionServer(descriptor(&pb.AllowRequest{}), descriptor(&healthpb.HealthCheckRequest{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := r.respond(&rpb.ServerReflectionRequest{Host: "localhost", ListServices: "*"})
+	var services []string
+	for _, s := range rsp.ListServicesResponse.Service {
+		services = append(services, s.Name)
+	}
+
+	if expected := []string{"grpc.health.v1.Health", serviceName}; !reflect.DeepEqual(services, expected) {
+		t.Fatalf("Expecting services %v, got %v", expected, services)
+	}
+
+	if rsp.ValidHost != "localhost" || rsp.OriginalRequest.ListServices != "*" {
+		t.Fatalf("Expecting the request to be echoed. Response %v", rsp)
+	}
+
+	for _, symbol := range []string{serviceName, serviceName + ".Allow", "quotaservice.AllowResponse.Status"} {
+		rsp = r.respond(&rpb.ServerReflectionRequest{FileContainingSymbol: symbol})
+		if rsp.FileDescriptorResponse == nil || len(rsp.FileDescriptorResponse.FileDescriptorProto) != 1 {
+			t.Fatalf("Expecting the file declaring %v. Response %v", symbol, rsp)
+		}
+
+		fd := &fileDescriptor{}
+		if err := proto.Unmarshal(rsp.FileDescriptorResponse.FileDescriptorProto[0], fd); err != nil || fd.Package != "quotaservice" {
+			t.Fatalf("Expecting the quotaservice file for %v, got %v. Error %v", symbol, fd, err)
+		}
+	}
+
+	rsp = r.respond(&rpb.ServerReflectionRequest{FileByFilename: "protos/health/health.proto"})
+	if rsp.FileDescriptorResponse == nil {
+		t.Fatalf("Expecting protos/health/health.proto to be found. Response %v", rsp)
+	}
+
+	rsp = r.respond(&rpb.ServerReflectionRequest{AllExtensionNumbersOfType: "quotaservice.AllowRequest"})
+	if rsp.AllExtensionNumbersResponse == nil || len(rsp.AllExtensionNumbersResponse.ExtensionNumber) != 0 {
+		t.Fatalf("Expecting messages to have no extensions. Response %v", rsp)
+	}
+
+	for _, req := range []*rpb.ServerReflectionRequest{
+		{FileContainingSymbol: "quotaservice.Missing"},
+		{FileByFilename: "missing.proto"},
+		{AllExtensionNumbersOfType: serviceName},
+		{FileContainingExtension: &rpb.ExtensionRequest{ContainingType: "quotaservice.AllowRequest", ExtensionNumber: 100}}} {
+		rsp = r.respond(req)
+		if rsp.ErrorResponse == nil || rsp.ErrorResponse.ErrorCode != int32(codes.NotFound) {
+			t.Errorf("Expecting %v not to be found. Response %v", req, rsp)
+		}
+	}
+}
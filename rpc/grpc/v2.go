@@ -0,0 +1,141 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"github.com/maniksurtani/quotaservice/logging"
+	pb "github.com/maniksurtani/quotaservice/protos"
+	pbv2 "github.com/maniksurtani/quotaservice/protos/v2"
+	"github.com/maniksurtani/quotaservice/rpc/limits"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// v2ServiceName is the name version 2 of the API is known by to gRPC's health checking protocol.
+const v2ServiceName = "quotaservice.v2.QuotaService"
+
+// maxEntries is the most entries a v2 request may have, so that one request can't hold the
+// endpoint for as long as many would.
+const maxEntries = 100
+
+// denyMessages describe the reasons v2 entries are refused for.
+var denyMessages = map[pbv2.DenyReason_Code]string{
+	pbv2.DenyReason_TIMEOUT:                   "Tokens not available within the max wait time",
+	pbv2.DenyReason_NO_BUCKET:                 "No such bucket",
+	pbv2.DenyReason_TOO_MANY_BUCKETS:          "Dynamic bucket couldn't be created",
+	pbv2.DenyReason_TOO_MANY_TOKENS_REQUESTED: "More tokens requested than the bucket allows per request",
+	pbv2.DenyReason_INVALID_REQUEST:           "Entries need a namespace, a bucket and valid token counts",
+	pbv2.DenyReason_SERVER_ERROR:              "Server error",
+	pbv2.DenyReason_TOO_MANY_WAITERS:          "Too many callers already waiting on the bucket",
+	pbv2.DenyReason_DENIED:                    "Bucket denies all requests"}
+
+// v2Service serves version 2 of the API by translating each entry of its requests into a v1
+// AllowRequest, served as the endpoint serves Allow, interceptors and all. v2 requests count
+// against the endpoint's request limiter, if it has one.
+type v2Service struct {
+	*GrpcEndpoint
+	requests *limits.RequestLimiter
+}
+
+func (s *v2Service) Allow(ctx context.Context, req *pbv2.AllowRequest) (*pbv2.AllowResponse, error) {
+	if !s.requests.Admit() {
+		logging.Printf("Too many requests in flight on %v", s.hostport)
+		return nil, grpc.Errorf(codes.ResourceExhausted, "Too many requests in flight")
+	}
+	defer s.requests.Done()
+
+	ctx, err := s.authenticated(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Entries) == 0 || len(req.Entries) > maxEntries {
+		return nil, grpc.Errorf(codes.InvalidArgument, "Requests need between 1 and %v entries", maxEntries)
+	}
+
+	ctx = withPriority(ctx, req.Priority)
+	rsp := &pbv2.AllowResponse{Results: make([]*pbv2.EntryResult, 0, len(req.Entries))}
+	for _, e := range req.Entries {
+		v1Rsp, err := s.allowHandler(ctx, toV1(req, e))
+		if grpc.Code(err) == codes.PermissionDenied {
+			rsp.Results = append(rsp.Results, rejected(pbv2.DenyReason_PERMISSION_DENIED, grpc.ErrorDesc(err)))
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		rsp.Results = append(rsp.Results, fromV1(v1Rsp))
+	}
+
+	return rsp, nil
+}
+
+// withPriority has the v1 requests served with ctx served at the priority given, whatever the
+// client's metadata says.
+func withPriority(ctx context.Context, p pbv2.Priority) context.Context {
+	md, _ := metadata.FromContext(ctx)
+	md = md.Copy()
+	delete(md, priorityKey)
+	if p == pbv2.Priority_PRIORITY_HIGH {
+		md[priorityKey] = []string{"high"}
+	}
+
+	return metadata.NewContext(ctx, md)
+}
+
+func toV1(req *pbv2.AllowRequest, e *pbv2.Entry) *pb.AllowRequest {
+	c := req.Caller
+	if c == nil {
+		c = &pbv2.Caller{}
+	}
+
+	return &pb.AllowRequest{
+		Namespace:             e.Namespace,
+		BucketName:            e.BucketName,
+		TokensRequested:       e.Tokens,
+		MaxWaitMillisOverride: e.MaxWaitMillis,
+		MinTokens:             e.MinTokens,
+		MaxTokens:             e.MaxTokens,
+		Operation:             e.CostLabel,
+		MaxDebtMillisOverride: e.MaxDebtMillis,
+		CallerId:              c.Id,
+		CallerLabels:          c.Labels,
+		DryRun:                req.DryRun}
+}
+
+func fromV1(rsp *pb.AllowResponse) *pbv2.EntryResult {
+	if rsp.Status != pb.AllowResponse_OK {
+		// Deny reasons are numbered as v1's statuses.
+		r := rejected(pbv2.DenyReason_Code(rsp.Status), "")
+		r.TokensRemaining = rsp.TokensRemaining
+		r.RetryAfterMillis = rsp.RetryAfterMillis
+		return r
+	}
+
+	return &pbv2.EntryResult{
+		Status:          pbv2.EntryResult_OK,
+		TokensGranted:   rsp.TokensGranted,
+		WaitMillis:      rsp.WaitMillis,
+		LeaseId:         rsp.LeaseId,
+		TokensRemaining: rsp.TokensRemaining}
+}
+
+// rejected returns the result of an entry refused for the reason given, described by msg, or by
+// the reason's own message if msg is empty.
+func rejected(code pbv2.DenyReason_Code, msg string) *pbv2.EntryResult {
+	if msg == "" {
+		msg = denyMessages[code]
+	}
+
+	return &pbv2.EntryResult{
+		Status: pbv2.EntryResult_REJECTED,
+		DenyReason: &pbv2.DenyReason{
+			Code:      code,
+			Message:   msg,
+			Retryable: code == pbv2.DenyReason_TIMEOUT || code == pbv2.DenyReason_TOO_MANY_WAITERS}}
+}
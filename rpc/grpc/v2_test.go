@@ -0,0 +1,154 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package grpc
+
+import (
+	"testing"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/config"
+	rpb "github.com/maniksurtani/quotaservice/protos/reflection"
+	pbv2 "github.com/maniksurtani/quotaservice/protos/v2"
+	"github.com/maniksurtani/quotaservice/rpc/auth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestV2Allow(t *testing.T) {
+	g := New("localhost:0")
+	s := quotaservice.New(v2Config(), memory.NewBucketFactory(), g)
+	s.Start()
+	defer s.Stop()
+
+	v2 := &v2Service{g, nil}
+	entry := &pbv2.Entry{Namespace: "ns", BucketName: "b"}
+	rsp, err := v2.Allow(context.TODO(), &pbv2.AllowRequest{
+		Caller: &pbv2.Caller{Id: "c"},
+		Entries: []*pbv2.Entry{
+			entry,
+			entry,
+			entry,
+			{Namespace: "ns", BucketName: "missing"},
+			{Namespace: "ns", BucketName: "denied"},
+			{Namespace: "ns"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pbv2.DenyReason_Code{
+		pbv2.DenyReason_UNKNOWN,
+		pbv2.DenyReason_UNKNOWN,
+		pbv2.DenyReason_TIMEOUT,
+		pbv2.DenyReason_NO_BUCKET,
+		pbv2.DenyReason_DENIED,
+		pbv2.DenyReason_INVALID_REQUEST}
+	if len(rsp.Results) != len(expected) {
+		t.Fatalf("Expecting %v results, got %v", len(expected), rsp.Results)
+	}
+
+	for i, r := range rsp.Results {
+		if expected[i] == pbv2.DenyReason_UNKNOWN {
+			if r.Status != pbv2.EntryResult_OK || r.TokensGranted != 1 || r.DenyReason != nil {
+				t.Errorf("Expecting entry %v to be granted, got %v", i, r)
+			}
+			continue
+		}
+
+		if r.Status != pbv2.EntryResult_REJECTED || r.DenyReason == nil || r.DenyReason.Code != expected[i] || r.DenyReason.Message == "" {
+			t.Errorf("Expecting entry %v to be refused with %v, got %v", i, expected[i], r)
+		}
+	}
+
+	if timeout := rsp.Results[2]; !timeout.DenyReason.Retryable || timeout.RetryAfterMillis <= 0 {
+		t.Errorf("Expecting the timed out entry to be retryable, got %v", timeout)
+	}
+
+	if denied := rsp.Results[4]; denied.DenyReason.Retryable {
+		t.Errorf("Expecting the denied entry not to be retryable, got %v", denied)
+	}
+
+	for _, entries := range [][]*pbv2.Entry{nil, make([]*pbv2.Entry, maxEntries+1)} {
+		if _, err := v2.Allow(context.TODO(), &pbv2.AllowRequest{Entries: entries}); grpc.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expecting %v entries to be invalid, got %v", len(entries), err)
+		}
+	}
+}
+
+func TestV2PermissionDenied(t *testing.T) {
+	g := New("localhost:0")
+	g.UseAuth(auth.NewTokenAuthenticator(map[string]string{"s3cr3t": "billing"}))
+	g.UseAuthorizer(auth.NewNamespaceAuthorizer(map[string][]string{"billing": {"ns"}}))
+	s := quotaservice.New(v2Config(), memory.NewBucketFactory(), g)
+	s.Start()
+	defer s.Stop()
+
+	v2 := &v2Service{g, nil}
+	req := &pbv2.AllowRequest{Entries: []*pbv2.Entry{{Namespace: "ns", BucketName: "b"}, {Namespace: "other", BucketName: "b"}}}
+	if _, err := v2.Allow(context.TODO(), req); grpc.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Expecting the request to be unauthenticated, got %v", err)
+	}
+
+	ctx := metadata.NewContext(context.TODO(), metadata.Pairs("authorization", "Bearer s3cr3t"))
+	rsp, err := v2.Allow(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := rsp.Results[0]; r.Status != pbv2.EntryResult_OK {
+		t.Errorf("Expecting the permitted namespace's entry to be granted, got %v", r)
+	}
+
+	if r := rsp.Results[1]; r.Status != pbv2.EntryResult_REJECTED || r.DenyReason == nil || r.DenyReason.Code != pbv2.DenyReason_PERMISSION_DENIED {
+		t.Errorf("Expecting the other namespace's entry to be denied permission, got %v", r)
+	}
+}
+
+func TestWithPriority(t *testing.T) {
+	ctx := metadata.NewContext(context.TODO(), metadata.Pairs(priorityKey, "high", callerIDKey, "c"))
+	if p := priority(withPriority(ctx, pbv2.Priority_PRIORITY_NORMAL)); p != quotaservice.PRIORITY_NORMAL {
+		t.Errorf("Expecting the request's priority to override the metadata's, got %v", p)
+	}
+
+	high := withPriority(context.TODO(), pbv2.Priority_PRIORITY_HIGH)
+	if p := priority(high); p != quotaservice.PRIORITY_HIGH {
+		t.Errorf("Expecting high priority, got %v", p)
+	}
+
+	if md, _ := metadata.FromContext(withPriority(ctx, pbv2.Priority_PRIORITY_HIGH)); len(md[callerIDKey]) != 1 {
+		t.Errorf("Expecting the rest of the metadata to be kept, got %v", md)
+	}
+}
+
+func TestV2Reflection(t *testing.T) {
+	r, err := newReflectionServer(descriptor(&pbv2.AllowRequest{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := r.respond(&rpb.ServerReflectionRequest{ListServices: "*"})
+	if services := rsp.ListServicesResponse.GetService(); len(services) != 1 || services[0].Name != v2ServiceName {
+		t.Fatalf("Expecting %v, got %v", v2ServiceName, services)
+	}
+}
+
+// v2Config has a bucket ns/b holding 2 tokens, refilled once a second, and a bucket ns/denied
+// denying all requests.
+func v2Config() *config.ServiceConfig {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 2
+	b.FillRate = 1
+	b.WaitTimeoutMillis = 0
+	b.MaxDebtMillis = 0
+	ns.AddBucket("b", b)
+	denied := config.NewDefaultBucketConfig()
+	denied.Mode = config.BucketModeAlwaysDeny
+	ns.AddBucket("denied", denied)
+	cfg.AddNamespace("ns", ns)
+	return cfg
+}
@@ -4,17 +4,68 @@
 package http
 
 import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
 	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/compress"
 	"github.com/maniksurtani/quotaservice/lifecycle"
+	"github.com/maniksurtani/quotaservice/logging"
+	pb "github.com/maniksurtani/quotaservice/protos"
+	"github.com/maniksurtani/quotaservice/rpc/auth"
+	"github.com/maniksurtani/quotaservice/rpc/certs"
 )
 
 const defaultPort = 80
 
-// HttpEndpoint is an HTTP-based implementation of an RPC endpoint
+// Headers telling clients their limits, in the form proxies and HTTP-native clients understand.
+const (
+	limitHeader      = "X-RateLimit-Limit"
+	remainingHeader  = "X-RateLimit-Remaining"
+	retryAfterHeader = "Retry-After"
+)
+
+// status is how requests refused for a reason are responded to.
+type status struct {
+	code int
+	pb   pb.AllowResponse_Status
+}
+
+var statuses = map[quotaservice.ErrorReason]status{
+	quotaservice.ER_TIMEOUT:                   {http.StatusTooManyRequests, pb.AllowResponse_REJECTED_TIMEOUT},
+	quotaservice.ER_TOO_MANY_WAITERS:          {http.StatusTooManyRequests, pb.AllowResponse_REJECTED_TOO_MANY_WAITERS},
+	quotaservice.ER_DENIED:                    {http.StatusTooManyRequests, pb.AllowResponse_REJECTED_DENIED},
+	quotaservice.ER_NO_BUCKET:                 {http.StatusNotFound, pb.AllowResponse_REJECTED_NO_BUCKET},
+	quotaservice.ER_TOO_MANY_TOKENS_REQUESTED: {http.StatusBadRequest, pb.AllowResponse_REJECTED_TOO_MANY_TOKENS_REQUESTED},
+	quotaservice.ER_TOO_MANY_BUCKETS:          {http.StatusServiceUnavailable, pb.AllowResponse_REJECTED_TOO_MANY_BUCKETS}}
+
+// allowResponse is the JSON body of responses to Allow requests. Statuses are named as in the
+// gRPC API's AllowResponse.
+type allowResponse struct {
+	Status        string `json:"status"`
+	TokensGranted int64  `json:"tokens_granted,omitempty"`
+	WaitMillis    int64  `json:"wait_millis,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// HttpEndpoint is an HTTP-based implementation of an RPC endpoint. It serves Allow requests on
+// /allow, telling clients their limits with X-RateLimit-Limit, X-RateLimit-Remaining and
+// Retry-After headers.
 type HttpEndpoint struct {
 	port          int
 	currentStatus lifecycle.Status
 	qs            quotaservice.QuotaService
+	tlsConfig     *tls.Config
+	h2c           bool
+	authenticator auth.Authenticator
+	authorizer    auth.Authorizer
+	server        *http.Server
 }
 
 func New(port int) *HttpEndpoint {
@@ -25,14 +76,204 @@ func NewDefault() *HttpEndpoint {
 	return New(defaultPort)
 }
 
+// UseTLS has the endpoint serve TLS as cfg configures it, erroring if its certificates can't be
+// loaded. Must be called before the endpoint starts.
+func (h *HttpEndpoint) UseTLS(cfg *certs.Config) error {
+	if h.h2c {
+		return fmt.Errorf("TLS can't be served with cleartext HTTP/2")
+	}
+
+	tlsConfig, err := cfg.ServerTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	h.tlsConfig = tlsConfig
+	return nil
+}
+
+// UseH2C has the endpoint also serve cleartext HTTP/2 to clients that speak it with prior
+// knowledge, for meshes whose proxies terminate TLS, so that high-QPS callers can multiplex their
+// requests on a connection. HTTP/1.1 clients are still served. Clients upgrading from HTTP/1.1
+// aren't, and endpoints serving TLS can't use it. Must be called before the endpoint starts.
+func (h *HttpEndpoint) UseH2C() error {
+	if h.tlsConfig != nil {
+		return fmt.Errorf("Cleartext HTTP/2 can't be served with TLS")
+	}
+
+	h.h2c = true
+	return nil
+}
+
+// UseAuth has the endpoint serve only the requests a authenticates, rejecting others with
+// 401 Unauthorized. Clients send bearer tokens in the Authorization header, and certificates over
+// TLS. Must be called before the endpoint starts.
+func (h *HttpEndpoint) UseAuth(a auth.Authenticator) {
+	h.authenticator = a
+}
+
+// UseAuthorizer has the endpoint serve only the requests for namespaces a permits the client's
+// identity, rejecting others with 403 Forbidden. Clients must authenticate, so UseAuth must be
+// called too. Must be called before the endpoint starts.
+func (h *HttpEndpoint) UseAuthorizer(a auth.Authorizer) {
+	h.authorizer = a
+}
+
 func (h *HttpEndpoint) Init(qs quotaservice.QuotaService) {
 	h.qs = qs
 }
 
 func (h *HttpEndpoint) Start() {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%v", h.port))
+	if err != nil {
+		logging.Fatalf("Cannot start server on port %v. Error %v", h.port, err)
+		panic(fmt.Sprintf("Cannot start server on port %v. Error %v", h.port, err))
+	}
+
+	if h.tlsConfig != nil {
+		lis = tls.NewListener(lis, h.tlsConfig)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/allow", h.allow)
+	h.server = &http.Server{Handler: compress.Handler(mux)}
+	if h.h2c {
+		h.server.Protocols = &http.Protocols{}
+		h.server.Protocols.SetHTTP1(true)
+		h.server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	go h.server.Serve(lis)
 	h.currentStatus = lifecycle.Started
+	logging.Printf("Starting HTTP server on port %v", h.port)
 }
 
 func (h *HttpEndpoint) Stop() {
+	if h.server != nil {
+		h.server.Close()
+	}
 	h.currentStatus = lifecycle.Stopped
 }
+
+// allow serves an Allow request for the tokens of the bucket named by its "namespace" and
+// "bucket" parameters. "tokens", "max_wait_millis" and "caller_id" are optional, as their
+// counterparts in the gRPC API's AllowRequest are.
+func (h *HttpEndpoint) allow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		w.Header().Set("Allow", "GET, POST")
+		writeResponse(w, http.StatusMethodNotAllowed, &allowResponse{
+			Status: pb.AllowResponse_REJECTED_INVALID_REQUEST.String(),
+			Error:  fmt.Sprintf("Method %v not allowed", r.Method)})
+		return
+	}
+
+	ctx := r.Context()
+	var identity string
+	if h.authenticator != nil {
+		var err error
+		if identity, err = h.authenticator.Authenticate(auth.RequestContext(r)); err != nil {
+			logging.Printf("Unauthenticated request. Error %v", err)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeResponse(w, http.StatusUnauthorized, &allowResponse{
+				Status: pb.AllowResponse_REJECTED_INVALID_REQUEST.String(),
+				Error:  err.Error()})
+			return
+		}
+		ctx = auth.NewContext(ctx, identity)
+	}
+
+	namespace, name := r.FormValue("namespace"), r.FormValue("bucket")
+	tokensRequested, err := intParam(r, "tokens", 1)
+	if err != nil || namespace == "" || name == "" || tokensRequested < 1 {
+		logging.Printf("Invalid request %v", r.URL)
+		writeResponse(w, http.StatusBadRequest, &allowResponse{
+			Status: pb.AllowResponse_REJECTED_INVALID_REQUEST.String(),
+			Error:  "Requests need a namespace, a bucket and a positive number of tokens"})
+		return
+	}
+
+	maxWaitMillis, err := intParam(r, "max_wait_millis", 0)
+	if err != nil {
+		logging.Printf("Invalid request %v", r.URL)
+		writeResponse(w, http.StatusBadRequest, &allowResponse{
+			Status: pb.AllowResponse_REJECTED_INVALID_REQUEST.String(),
+			Error:  err.Error()})
+		return
+	}
+
+	if h.authorizer != nil {
+		if err = h.authorizer.Authorize(identity, namespace); err != nil {
+			logging.Printf("Unauthorized request. Error %v", err)
+			writeResponse(w, http.StatusForbidden, &allowResponse{
+				Status: pb.AllowResponse_REJECTED_INVALID_REQUEST.String(),
+				Error:  err.Error()})
+			return
+		}
+	}
+
+	caller := quotaservice.Caller{ID: r.FormValue("caller_id")}
+	grant, err := h.qs.Claim(ctx, namespace, name, "", tokensRequested, tokensRequested, maxWaitMillis, 0, quotaservice.PRIORITY_NORMAL, caller)
+	if err == nil {
+		setHeaders(w, grant, false)
+		writeResponse(w, http.StatusOK, &allowResponse{
+			Status:        pb.AllowResponse_OK.String(),
+			TokensGranted: grant.Tokens,
+			WaitMillis:    grant.Wait.Nanoseconds() / int64(time.Millisecond)})
+		return
+	}
+
+	qsErr, ok := err.(quotaservice.QuotaServiceError)
+	s, known := statuses[qsErr.Reason]
+	if !ok || !known {
+		logging.Printf("Caught error %v", err)
+		writeResponse(w, http.StatusInternalServerError, &allowResponse{
+			Status: pb.AllowResponse_REJECTED_SERVER_ERROR.String(),
+			Error:  err.Error()})
+		return
+	}
+
+	if s.code == http.StatusTooManyRequests {
+		setHeaders(w, grant, qsErr.Reason != quotaservice.ER_DENIED)
+	}
+	writeResponse(w, s.code, &allowResponse{Status: s.pb.String(), Error: err.Error()})
+}
+
+// setHeaders tells the client the limit of the bucket it claimed tokens from and the tokens
+// remaining in it and its parents, as the claim found them, and if retry is set, how many seconds
+// until the tokens it needed are projected to be available, rounded up. Buckets that can't report
+// them are left out.
+func setHeaders(w http.ResponseWriter, grant *quotaservice.Grant, retry bool) {
+	if grant.Limit > 0 {
+		w.Header().Set(limitHeader, strconv.FormatInt(grant.Limit, 10))
+	}
+
+	if grant.Remaining >= 0 {
+		w.Header().Set(remainingHeader, strconv.FormatInt(grant.Remaining, 10))
+	}
+
+	if retry && grant.RetryAfter > 0 {
+		w.Header().Set(retryAfterHeader, strconv.Itoa(int(math.Ceil(grant.RetryAfter.Seconds()))))
+	}
+}
+
+// intParam parses the request's integer parameter, returning def if it isn't set.
+func intParam(r *http.Request, name string, def int64) (int64, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return def, nil
+	}
+
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %v %q", name, v)
+	}
+
+	return i, nil
+}
+
+func writeResponse(w http.ResponseWriter, code int, rsp *allowResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(rsp); err != nil {
+		logging.Warnf("Cannot write response. Error %v", err)
+	}
+}
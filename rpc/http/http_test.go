@@ -0,0 +1,155 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/rpc/auth"
+)
+
+func TestAllow(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 2
+	b.FillRate = 1
+	b.WaitTimeoutMillis = 0
+	b.MaxDebtMillis = 0
+	b.MaxTokensPerRequest = 2
+	ns.AddBucket("b", b)
+	denied := config.NewDefaultBucketConfig()
+	denied.Mode = config.BucketModeAlwaysDeny
+	ns.AddBucket("denied", denied)
+	cfg.AddNamespace("ns", ns)
+
+	h := New(0)
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), h)
+	s.Start()
+	defer s.Stop()
+
+	tests := []struct {
+		method, url                  string
+		code                         int
+		status                       string
+		limit, remaining, retryAfter string
+	}{
+		{"GET", "/allow?namespace=ns&bucket=b", http.StatusOK, "OK", "2", "1", ""},
+		{"POST", "/allow?namespace=ns&bucket=b", http.StatusOK, "OK", "2", "0", ""},
+		{"GET", "/allow?namespace=ns&bucket=b", http.StatusTooManyRequests, "REJECTED_TIMEOUT", "2", "0", "1"},
+		{"GET", "/allow?namespace=ns&bucket=denied", http.StatusTooManyRequests, "REJECTED_DENIED", "100", "0", ""},
+		{"GET", "/allow?namespace=ns&bucket=missing", http.StatusNotFound, "REJECTED_NO_BUCKET", "", "", ""},
+		{"GET", "/allow?namespace=ns&bucket=b&tokens=3", http.StatusBadRequest, "REJECTED_TOO_MANY_TOKENS_REQUESTED", "", "", ""},
+		{"GET", "/allow?namespace=ns", http.StatusBadRequest, "REJECTED_INVALID_REQUEST", "", "", ""},
+		{"GET", "/allow?namespace=ns&bucket=b&tokens=x", http.StatusBadRequest, "REJECTED_INVALID_REQUEST", "", "", ""},
+		{"DELETE", "/allow?namespace=ns&bucket=b", http.StatusMethodNotAllowed, "REJECTED_INVALID_REQUEST", "", "", ""}}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.allow(w, httptest.NewRequest(test.method, test.url, nil))
+
+		rsp := &allowResponse{}
+		if err := json.NewDecoder(w.Body).Decode(rsp); err != nil {
+			t.Fatalf("%v %v: cannot decode response. Error %v", test.method, test.url, err)
+		}
+
+		if w.Code != test.code || rsp.Status != test.status {
+			t.Errorf("%v %v: expecting %v %v, got %v %v", test.method, test.url, test.code, test.status, w.Code, rsp.Status)
+		}
+
+		headers := []string{w.Header().Get(limitHeader), w.Header().Get(remainingHeader), w.Header().Get(retryAfterHeader)}
+		if expected := []string{test.limit, test.remaining, test.retryAfter}; headers[0] != expected[0] || headers[1] != expected[1] || headers[2] != expected[2] {
+			t.Errorf("%v %v: expecting limit, remaining and retry-after headers %q, got %q", test.method, test.url, expected, headers)
+		}
+	}
+}
+
+func TestAllowAuth(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	for _, name := range []string{"billing", "payments"} {
+		ns := config.NewDefaultNamespaceConfig()
+		ns.AddBucket("b", config.NewDefaultBucketConfig())
+		cfg.AddNamespace(name, ns)
+	}
+
+	h := New(0)
+	h.UseAuth(auth.NewTokenAuthenticator(map[string]string{"s3cr3t": "billing"}))
+	h.UseAuthorizer(auth.NewNamespaceAuthorizer(map[string][]string{"billing": {"billing"}}))
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), h)
+	s.Start()
+	defer s.Stop()
+
+	tests := []struct {
+		namespace, authorization string
+		code                     int
+	}{
+		{"billing", "", http.StatusUnauthorized},
+		{"billing", "Bearer wrong", http.StatusUnauthorized},
+		{"billing", "Bearer s3cr3t", http.StatusOK},
+		{"payments", "Bearer s3cr3t", http.StatusForbidden}}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/allow?bucket=b&namespace="+test.namespace, nil)
+		if test.authorization != "" {
+			r.Header.Set("Authorization", test.authorization)
+		}
+		h.allow(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%v with %q: expecting %v, got %v", test.namespace, test.authorization, test.code, w.Code)
+		}
+	}
+}
+
+func TestH2C(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("ns", ns)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	h := New(port)
+	if err := h.UseH2C(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), h)
+	s.Start()
+	defer s.Stop()
+
+	protocols := &http.Protocols{}
+	protocols.SetUnencryptedHTTP2(true)
+	h2c := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	url := fmt.Sprintf("http://127.0.0.1:%v/allow?namespace=ns&bucket=b", port)
+	for _, client := range []*http.Client{h2c, http.DefaultClient} {
+		rsp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Errorf("%v: expecting %v, got %v", rsp.Proto, http.StatusOK, rsp.StatusCode)
+		}
+
+		if client == h2c && rsp.ProtoMajor != 2 {
+			t.Errorf("Expecting HTTP/2, got %v", rsp.Proto)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package limits bounds the connections and concurrent requests RPC endpoints serve, so that
+// endpoints serving different clients can't starve each other, and a misbehaving fleet of clients
+// can't exhaust the server's resources.
+package limits
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Limits of an RPC endpoint. Zero values are unlimited.
+type Limits struct {
+	// MaxConnections is the number of connections the endpoint holds open at once. Further clients
+	// wait to be accepted until others disconnect.
+	MaxConnections int `yaml:"max_connections"`
+	// MaxConcurrentStreams is the number of requests each connection may have in flight at once.
+	MaxConcurrentStreams uint32 `yaml:"max_concurrent_streams"`
+	// MaxConcurrentRequests is the number of requests the endpoint serves at once, across all of
+	// its connections. Further requests are rejected, rather than queued.
+	MaxConcurrentRequests int `yaml:"max_concurrent_requests"`
+	// Keepalive settings of the endpoint's connections.
+	Keepalive Keepalive `yaml:"keepalive"`
+}
+
+// Keepalive settings of an endpoint's connections. Zero values are disabled.
+type Keepalive struct {
+	// TimeMillis is how often idle connections are probed with TCP keepalives, so that those to
+	// clients that have gone away are closed.
+	TimeMillis int64 `yaml:"time_millis"`
+	// MaxIdleMillis is how long a connection may go without the client sending anything before
+	// it's closed.
+	MaxIdleMillis int64 `yaml:"max_idle_millis"`
+	// MaxAgeMillis is how long a connection may stay open before it's closed, so that clients
+	// reconnect, spreading themselves across the instances behind a load balancer. Requests in
+	// flight on the connection fail, for clients to retry.
+	MaxAgeMillis int64 `yaml:"max_age_millis"`
+}
+
+// Listen listens on hostport, accepting no more than MaxConnections connections at once, and
+// closing connections as their Keepalive settings have them closed.
+func (l *Limits) Listen(hostport string) (net.Listener, error) {
+	lis, err := net.Listen("tcp", hostport)
+	if err != nil || (l.MaxConnections <= 0 && l.Keepalive == Keepalive{}) {
+		return lis, err
+	}
+
+	limited := &limitedListener{
+		Listener:  lis,
+		keepalive: l.Keepalive,
+		closed:    make(chan struct{})}
+	if l.MaxConnections > 0 {
+		limited.slots = make(chan struct{}, l.MaxConnections)
+	}
+
+	return limited, nil
+}
+
+// ServerOptions returns the options applying the limits to a gRPC server.
+func (l *Limits) ServerOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if l.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(l.MaxConcurrentStreams))
+	}
+
+	return opts
+}
+
+// Requests returns the limiter of the requests the endpoint serves at once, or nil if they're
+// unlimited.
+func (l *Limits) Requests() *RequestLimiter {
+	if l.MaxConcurrentRequests <= 0 {
+		return nil
+	}
+
+	return &RequestLimiter{make(chan struct{}, l.MaxConcurrentRequests)}
+}
+
+// RequestLimiter limits the requests an endpoint serves at once. A nil RequestLimiter admits all
+// requests.
+type RequestLimiter struct {
+	slots chan struct{}
+}
+
+// Admit holds a slot for a request, returning false if none are free. Requests admitted must be
+// passed to Done once served.
+func (r *RequestLimiter) Admit() bool {
+	if r == nil {
+		return true
+	}
+
+	select {
+	case r.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done frees the slot held by a request Admit admitted.
+func (r *RequestLimiter) Done() {
+	if r != nil {
+		<-r.slots
+	}
+}
+
+// limitedListener holds a slot for each connection it accepts, until the connection is closed, if
+// it has slots. It applies its keepalive settings to the connections it accepts.
+type limitedListener struct {
+	net.Listener
+	slots     chan struct{}
+	keepalive Keepalive
+	// Closed once the listener is, so Accept doesn't wait for a slot forever.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-l.closed:
+			return nil, errors.New("Listener closed")
+		}
+		release = func() { <-l.slots }
+	}
+
+	c, err := l.Listener.Accept()
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	if tcp, ok := c.(*net.TCPConn); ok && l.keepalive.TimeMillis > 0 {
+		tcp.SetKeepAlive(true)
+		tcp.SetKeepAlivePeriod(time.Duration(l.keepalive.TimeMillis) * time.Millisecond)
+	}
+
+	conn := &limitedConn{Conn: c, release: release, lastRead: time.Now().UnixNano()}
+	if l.keepalive.MaxIdleMillis > 0 {
+		maxIdle := time.Duration(l.keepalive.MaxIdleMillis) * time.Millisecond
+		conn.idleTimer = time.AfterFunc(maxIdle, func() { conn.checkIdle(maxIdle) })
+	}
+
+	if l.keepalive.MaxAgeMillis > 0 {
+		conn.ageTimer = time.AfterFunc(time.Duration(l.keepalive.MaxAgeMillis)*time.Millisecond, func() { conn.Close() })
+	}
+
+	return conn, nil
+}
+
+func (l *limitedListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+type limitedConn struct {
+	net.Conn
+	// When the client last sent anything, in Unix nanos. Accessed atomically.
+	lastRead  int64
+	once      sync.Once
+	release   func()
+	idleTimer *time.Timer
+	ageTimer  *time.Timer
+}
+
+func (c *limitedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+	}
+
+	return n, err
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		for _, t := range []*time.Timer{c.idleTimer, c.ageTimer} {
+			if t != nil {
+				t.Stop()
+			}
+		}
+		c.release()
+	})
+	return err
+}
+
+// checkIdle closes the connection if the client has sent nothing for maxIdle, checking again
+// once it would have otherwise.
+func (c *limitedConn) checkIdle(maxIdle time.Duration) {
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&c.lastRead)))
+	if idle >= maxIdle {
+		c.Close()
+		return
+	}
+
+	c.idleTimer.Reset(maxIdle - idle)
+}
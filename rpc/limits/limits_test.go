@@ -0,0 +1,180 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package limits
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMaxConnections(t *testing.T) {
+	l := &Limits{MaxConnections: 1}
+	lis, err := l.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", lis.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+
+	first := <-accepted
+	select {
+	case <-accepted:
+		t.Fatal("Expecting the second connection to wait for the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case c := <-accepted:
+		defer c.Close()
+	case <-time.After(time.Second):
+		t.Fatal("Expecting the second connection to be accepted once the first is closed")
+	}
+
+	// Closing the listener stops Accept waiting for a slot.
+	c, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	lis.Close()
+	select {
+	case _, ok := <-accepted:
+		if ok {
+			t.Fatal("Expecting no more connections to be accepted")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expecting Accept to return once the listener is closed")
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	l := &Limits{Keepalive: Keepalive{TimeMillis: 1000, MaxIdleMillis: 50, MaxAgeMillis: 300}}
+	lis, err := l.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	closed := func(c net.Conn, within time.Duration) bool {
+		c.SetReadDeadline(time.Now().Add(within))
+		_, err := c.Read(make([]byte, 1))
+		netErr, timedOut := err.(net.Error)
+		return err != nil && !(timedOut && netErr.Timeout())
+	}
+
+	// Clients that send nothing are disconnected once they've been idle too long.
+	idle, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+
+	c, err := lis.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go c.Read(make([]byte, 1))
+
+	if !closed(idle, time.Second) {
+		t.Fatal("Expecting idle connections to be closed")
+	}
+
+	// Busy clients are only disconnected once their connection reaches its max age.
+	busy, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	c, err = lis.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		b := make([]byte, 1)
+		for {
+			if _, err := c.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	for time.Since(start) < 150*time.Millisecond {
+		if _, err := busy.Write([]byte{0}); err != nil {
+			t.Fatalf("Expecting busy connections to be kept open. Error %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if closed(busy, time.Millisecond) {
+		t.Fatal("Expecting busy connections to be kept open")
+	}
+
+	for time.Since(start) < time.Second {
+		busy.Write([]byte{0})
+		if closed(busy, 10*time.Millisecond) {
+			return
+		}
+	}
+	t.Fatal("Expecting connections to be closed once they reach their max age")
+}
+
+func TestRequests(t *testing.T) {
+	if r := (&Limits{}).Requests(); r != nil || !r.Admit() {
+		t.Fatal("Expecting requests to be unlimited without a limit")
+	}
+
+	r := (&Limits{MaxConcurrentRequests: 2}).Requests()
+	if !r.Admit() || !r.Admit() {
+		t.Fatal("Expecting requests to be admitted up to the limit")
+	}
+
+	if r.Admit() {
+		t.Fatal("Expecting requests beyond the limit to be rejected")
+	}
+
+	r.Done()
+	if !r.Admit() {
+		t.Fatal("Expecting requests to be admitted once others are done")
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	l := &Limits{}
+	lis, err := l.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	if _, limited := lis.(*limitedListener); limited {
+		t.Fatal("Expecting listeners without limits not to be limited")
+	}
+
+	if len(l.ServerOptions()) != 0 {
+		t.Fatal("Expecting no server options without limits")
+	}
+}
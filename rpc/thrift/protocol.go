@@ -0,0 +1,271 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package thrift
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Types of values in Thrift's binary protocol.
+const (
+	typeStop   byte = 0
+	typeBool   byte = 2
+	typeByte   byte = 3
+	typeDouble byte = 4
+	typeI16    byte = 6
+	typeI32    byte = 8
+	typeI64    byte = 10
+	typeString byte = 11
+	typeStruct byte = 12
+	typeMap    byte = 13
+	typeSet    byte = 14
+	typeList   byte = 15
+)
+
+// Types of messages.
+const (
+	messageCall      byte = 1
+	messageReply     byte = 2
+	messageException byte = 3
+	messageOneway    byte = 4
+)
+
+// Types of TApplicationException.
+const (
+	exceptionUnknownMethod int32 = 1
+	exceptionProtocolError int32 = 7
+)
+
+const (
+	// Strict messages start with the version, ORed with the type of message.
+	version1    = 0x80010000
+	versionMask = 0xffff0000
+	// maxFrameSize bounds the frames read, so that clients can't have the server allocate
+	// arbitrarily large buffers.
+	maxFrameSize = 1 << 20
+	// maxDepth bounds how deeply nested the values skipped may be.
+	maxDepth = 64
+)
+
+var errTruncated = errors.New("Truncated message")
+
+// message is the header of a message.
+type message struct {
+	name  string
+	mtype byte
+	seqID int32
+}
+
+// readFrame reads a frame of the framed transport, its length followed by its contents.
+func readFrame(r io.Reader) ([]byte, error) {
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+
+	if size < 0 || size > maxFrameSize {
+		return nil, fmt.Errorf("Invalid frame size %v", size)
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+// writeFrame writes the contents of a frame, preceded by their length.
+func writeFrame(w io.Writer, frame []byte) error {
+	b := make([]byte, 4, 4+len(frame))
+	binary.BigEndian.PutUint32(b, uint32(len(frame)))
+	_, err := w.Write(append(b, frame...))
+	return err
+}
+
+// decoder reads values of the binary protocol. Once it has failed, it reads zero values, keeping
+// the error for err.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	if n < 0 || n > len(d.b) {
+		d.err = errTruncated
+		return nil
+	}
+
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if b := d.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) i16() int16 {
+	if b := d.read(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) i32() int32 {
+	if b := d.read(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) i64() int64 {
+	if b := d.read(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.read(int(d.i32())))
+}
+
+// message reads a message header, in the strict form or the old one, which starts with the name.
+func (d *decoder) message() message {
+	m := message{}
+	v := d.i32()
+	if v < 0 {
+		if uint32(v)&versionMask != version1 {
+			if d.err == nil {
+				d.err = fmt.Errorf("Unsupported version %x", uint32(v)&versionMask)
+			}
+			return m
+		}
+
+		m.mtype = byte(v)
+		m.name = d.string()
+	} else {
+		m.name = string(d.read(int(v)))
+		m.mtype = d.byte()
+	}
+
+	m.seqID = d.i32()
+	return m
+}
+
+// field reads the header of a struct's field, returning typeStop once all fields have been read.
+func (d *decoder) field() (fieldType byte, id int16) {
+	if fieldType = d.byte(); fieldType == typeStop {
+		return
+	}
+
+	return fieldType, d.i16()
+}
+
+// skip reads a value of the type, discarding it.
+func (d *decoder) skip(valueType byte, depth int) {
+	if depth > maxDepth {
+		if d.err == nil {
+			d.err = errors.New("Values nested too deeply")
+		}
+		return
+	}
+
+	switch valueType {
+	case typeBool, typeByte:
+		d.read(1)
+	case typeI16:
+		d.read(2)
+	case typeI32:
+		d.read(4)
+	case typeI64, typeDouble:
+		d.read(8)
+	case typeString:
+		d.string()
+	case typeStruct:
+		for d.err == nil {
+			t, _ := d.field()
+			if t == typeStop {
+				return
+			}
+			d.skip(t, depth+1)
+		}
+	case typeMap:
+		k, v, n := d.byte(), d.byte(), d.i32()
+		for i := int32(0); i < n && d.err == nil; i++ {
+			d.skip(k, depth+1)
+			d.skip(v, depth+1)
+		}
+	case typeSet, typeList:
+		e, n := d.byte(), d.i32()
+		for i := int32(0); i < n && d.err == nil; i++ {
+			d.skip(e, depth+1)
+		}
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("Unknown type %v", valueType)
+		}
+	}
+}
+
+// expect records an error if a field of the type given was expected to be of another type.
+func (d *decoder) expect(got, expected byte, id int16) bool {
+	if got != expected {
+		if d.err == nil {
+			d.err = fmt.Errorf("Field %v should be of type %v, but is %v", id, expected, got)
+		}
+		return false
+	}
+
+	return true
+}
+
+// encoder writes values of the binary protocol.
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) i16(v int16) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *encoder) i32(v int32) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *encoder) i64(v int64) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *encoder) string(v string) {
+	e.i32(int32(len(v)))
+	e.WriteString(v)
+}
+
+// message writes a message header in the strict form.
+func (e *encoder) message(m message) {
+	e.i32(int32(uint32(version1) | uint32(m.mtype)))
+	e.string(m.name)
+	e.i32(m.seqID)
+}
+
+func (e *encoder) field(fieldType byte, id int16) {
+	e.WriteByte(fieldType)
+	e.i16(id)
+}
+
+func (e *encoder) stop() {
+	e.WriteByte(typeStop)
+}
@@ -0,0 +1,48 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// The quota service's Allow API for Thrift clients, served by the endpoint in rpc/thrift over the
+// framed transport and binary protocol. Fields mirror those of the same names in
+// protos/quota_service.proto.
+
+namespace go quotaservice
+namespace java com.github.maniksurtani.quotaservice
+
+enum Status {
+  OK = 0,                                 // Tokens granted
+  REJECTED_TIMEOUT = 1,                   // Tokens not available within max wait time
+  REJECTED_NO_BUCKET = 2,                 // No valid bucket
+  REJECTED_TOO_MANY_BUCKETS = 3,          // Dynamic bucket couldn't be created
+  REJECTED_TOO_MANY_TOKENS_REQUESTED = 4,
+  REJECTED_INVALID_REQUEST = 5,
+  REJECTED_SERVER_ERROR = 6,
+  REJECTED_TOO_MANY_WAITERS = 8,          // Too many callers already waiting on the bucket
+  REJECTED_DENIED = 9                     // Bucket is set to deny all requests
+}
+
+struct AllowRequest {
+  1: required string namespace,
+  2: required string bucket_name,
+  // Defaults to 1.
+  3: optional i64 tokens_requested,
+  // Defaults to the bucket's wait timeout, and can't exceed it.
+  4: optional i64 max_wait_millis_override,
+  5: optional string caller_id
+}
+
+struct AllowResponse {
+  1: Status status,
+  // If status == OK
+  2: optional i64 tokens_granted,
+  // Wait for this many millis before proceeding, if status == OK.
+  3: optional i64 wait_millis,
+  // Estimate of the tokens left in the bucket and its parents.
+  4: optional i64 tokens_remaining,
+  // Estimate of how many millis to wait before retrying, if status is REJECTED_TIMEOUT or
+  // REJECTED_TOO_MANY_WAITERS.
+  5: optional i64 retry_after_millis
+}
+
+service QuotaService {
+  AllowResponse allow(1: AllowRequest request)
+}
@@ -0,0 +1,353 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+// Package thrift serves the quota service's Allow API to Thrift clients, as quota_service.thrift
+// describes it, over Thrift's framed transport and binary protocol.
+package thrift
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/lifecycle"
+	"github.com/maniksurtani/quotaservice/logging"
+	pb "github.com/maniksurtani/quotaservice/protos"
+	"github.com/maniksurtani/quotaservice/rpc/certs"
+	"github.com/maniksurtani/quotaservice/rpc/limits"
+)
+
+// allowMethod is the name of the service's only method.
+const allowMethod = "allow"
+
+// statuses are the statuses of requests refused for a reason.
+var statuses = map[quotaservice.ErrorReason]pb.AllowResponse_Status{
+	quotaservice.ER_NO_BUCKET:                 pb.AllowResponse_REJECTED_NO_BUCKET,
+	quotaservice.ER_TOO_MANY_BUCKETS:          pb.AllowResponse_REJECTED_TOO_MANY_BUCKETS,
+	quotaservice.ER_TOO_MANY_TOKENS_REQUESTED: pb.AllowResponse_REJECTED_TOO_MANY_TOKENS_REQUESTED,
+	quotaservice.ER_TIMEOUT:                   pb.AllowResponse_REJECTED_TIMEOUT,
+	quotaservice.ER_TOO_MANY_WAITERS:          pb.AllowResponse_REJECTED_TOO_MANY_WAITERS,
+	quotaservice.ER_DENIED:                    pb.AllowResponse_REJECTED_DENIED}
+
+// allowRequest is an AllowRequest of quota_service.thrift.
+type allowRequest struct {
+	namespace       string
+	bucketName      string
+	tokensRequested int64
+	// -1 if the client didn't set it.
+	maxWaitMillisOverride int64
+	callerID              string
+}
+
+// allowResponse is an AllowResponse of quota_service.thrift, whose statuses are numbered as those
+// of the gRPC API's.
+type allowResponse struct {
+	status           pb.AllowResponse_Status
+	tokensGranted    int64
+	waitMillis       int64
+	tokensRemaining  int64
+	retryAfterMillis int64
+}
+
+// ThriftEndpoint is a Thrift-based implementation of an RPC endpoint, for clients that can't use
+// the gRPC one. It serves the Allow API over the framed transport and binary protocol.
+type ThriftEndpoint struct {
+	hostport      string
+	currentStatus lifecycle.Status
+	qs            quotaservice.QuotaService
+	tlsConfig     *tls.Config
+	limits        limits.Limits
+	requests      *limits.RequestLimiter
+	listener      net.Listener
+	// Connections being served, closed when the endpoint stops.
+	conns map[net.Conn]bool
+	sync.Mutex
+}
+
+// New creates a new ThriftEndpoint, listening on hostport, in the form "host:port".
+func New(hostport string) *ThriftEndpoint {
+	if !strings.Contains(hostport, ":") {
+		panic(fmt.Sprintf("hostport should be in the format 'host:port', but is currently %v",
+			hostport))
+	}
+	return &ThriftEndpoint{hostport: hostport, conns: make(map[net.Conn]bool)}
+}
+
+// UseTLS has the endpoint serve TLS as cfg configures it, erroring if its certificates can't be
+// loaded. Must be called before the endpoint starts.
+func (t *ThriftEndpoint) UseTLS(cfg *certs.Config) error {
+	tlsConfig, err := cfg.ServerTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	t.tlsConfig = tlsConfig
+	return nil
+}
+
+// UseLimits has the endpoint limit the connections and requests it serves at once. Connections
+// serve a request at a time, as Thrift clients send them, so MaxConcurrentStreams doesn't apply.
+// Must be called before the endpoint starts.
+func (t *ThriftEndpoint) UseLimits(l limits.Limits) {
+	t.limits = l
+	t.requests = l.Requests()
+}
+
+func (t *ThriftEndpoint) Init(qs quotaservice.QuotaService) {
+	t.qs = qs
+}
+
+func (t *ThriftEndpoint) Start() {
+	lis, err := t.limits.Listen(t.hostport)
+	if err != nil {
+		logging.Fatalf("Cannot start server on port %v. Error %v", t.hostport, err)
+		panic(fmt.Sprintf("Cannot start server on port %v. Error %v", t.hostport, err))
+	}
+
+	if t.tlsConfig != nil {
+		lis = tls.NewListener(lis, t.tlsConfig)
+	}
+
+	t.listener = lis
+	go t.accept(lis)
+	t.currentStatus = lifecycle.Started
+	logging.Printf("Starting Thrift server on %v", t.hostport)
+}
+
+func (t *ThriftEndpoint) Stop() {
+	if t.listener != nil {
+		t.listener.Close()
+	}
+
+	t.Lock()
+	for c := range t.conns {
+		c.Close()
+	}
+	t.Unlock()
+	t.currentStatus = lifecycle.Stopped
+}
+
+func (t *ThriftEndpoint) accept(lis net.Listener) {
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			return
+		}
+
+		t.Lock()
+		t.conns[c] = true
+		t.Unlock()
+		go t.serve(c)
+	}
+}
+
+// serve serves the requests sent on the connection, one at a time, until the client closes it or
+// sends a message that can't be read.
+func (t *ThriftEndpoint) serve(c net.Conn) {
+	defer func() {
+		t.Lock()
+		delete(t.conns, c)
+		t.Unlock()
+		c.Close()
+	}()
+
+	r, w := bufio.NewReader(c), bufio.NewWriter(c)
+	for {
+		frame, err := readFrame(r)
+		if err != nil {
+			// Clients going away aren't worth logging, unlike those sending invalid frames.
+			if _, ok := err.(net.Error); !ok && err != io.EOF && err != io.ErrUnexpectedEOF {
+				logging.Warnf("Cannot read Thrift request from %v. Error %v", c.RemoteAddr(), err)
+			}
+			return
+		}
+
+		reply, err := t.handle(frame)
+		if err != nil {
+			logging.Printf("Invalid Thrift message from %v. Error %v", c.RemoteAddr(), err)
+			return
+		}
+
+		if reply == nil {
+			continue
+		}
+
+		if err = writeFrame(w, reply); err == nil {
+			err = w.Flush()
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle serves the message in the frame, returning the reply to send, if any, or an error if the
+// message can't be read at all, so that the connection should be closed.
+func (t *ThriftEndpoint) handle(frame []byte) ([]byte, error) {
+	d := &decoder{b: frame}
+	m := d.message()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if m.mtype != messageCall && m.mtype != messageOneway {
+		return nil, fmt.Errorf("Unexpected message type %v", m.mtype)
+	}
+
+	var reply []byte
+	if m.name != allowMethod {
+		reply = exception(m, exceptionUnknownMethod, fmt.Sprintf("Unknown method %v", m.name))
+	} else if req := readAllowArgs(d); d.err != nil {
+		reply = exception(m, exceptionProtocolError, d.err.Error())
+	} else {
+		e := &encoder{}
+		e.message(message{name: m.name, mtype: messageReply, seqID: m.seqID})
+		// The result struct's success field.
+		e.field(typeStruct, 0)
+		t.allow(req).write(e)
+		e.stop()
+		reply = e.Bytes()
+	}
+
+	if m.mtype == messageOneway {
+		return nil, nil
+	}
+
+	return reply, nil
+}
+
+// allow serves an Allow request as the gRPC endpoint does.
+func (t *ThriftEndpoint) allow(req *allowRequest) *allowResponse {
+	rsp := &allowResponse{}
+	if req.namespace == "" || req.bucketName == "" || req.tokensRequested < 0 {
+		logging.Debugf("Invalid request %+v", req)
+		rsp.status = pb.AllowResponse_REJECTED_INVALID_REQUEST
+		return rsp
+	}
+
+	if !t.requests.Admit() {
+		logging.Printf("Too many requests in flight on %v", t.hostport)
+		rsp.status = pb.AllowResponse_REJECTED_SERVER_ERROR
+		return rsp
+	}
+	defer t.requests.Done()
+
+	var tokensRequested int64 = 1
+	if req.tokensRequested > 0 {
+		tokensRequested = req.tokensRequested
+	}
+
+	caller := quotaservice.Caller{ID: req.callerID}
+	grant, err := t.qs.Claim(context.Background(), req.namespace, req.bucketName, "", tokensRequested, tokensRequested, req.maxWaitMillisOverride, 0, quotaservice.PRIORITY_NORMAL, caller)
+	if err == nil {
+		rsp.status = pb.AllowResponse_OK
+		rsp.tokensGranted = grant.Tokens
+		rsp.waitMillis = grant.Wait.Nanoseconds() / int64(time.Millisecond)
+	} else if qsErr, ok := err.(quotaservice.QuotaServiceError); ok && statuses[qsErr.Reason] != 0 {
+		rsp.status = statuses[qsErr.Reason]
+	} else {
+		logging.Printf("Caught error %v", err)
+		rsp.status = pb.AllowResponse_REJECTED_SERVER_ERROR
+	}
+
+	if grant.Remaining >= 0 {
+		rsp.tokensRemaining = grant.Remaining
+	}
+	rsp.retryAfterMillis = int64((grant.RetryAfter + time.Millisecond - 1) / time.Millisecond)
+	return rsp
+}
+
+// readAllowArgs reads the arguments of a call to allow, skipping fields it doesn't know.
+func readAllowArgs(d *decoder) *allowRequest {
+	req := &allowRequest{maxWaitMillisOverride: -1}
+	for d.err == nil {
+		fieldType, id := d.field()
+		switch {
+		case fieldType == typeStop:
+			return req
+		case id == 1 && d.expect(fieldType, typeStruct, id):
+			readAllowRequest(d, req)
+		default:
+			d.skip(fieldType, 0)
+		}
+	}
+
+	return req
+}
+
+func readAllowRequest(d *decoder, req *allowRequest) {
+	for d.err == nil {
+		fieldType, id := d.field()
+		if fieldType == typeStop {
+			return
+		}
+
+		switch id {
+		case 1:
+			if d.expect(fieldType, typeString, id) {
+				req.namespace = d.string()
+			}
+		case 2:
+			if d.expect(fieldType, typeString, id) {
+				req.bucketName = d.string()
+			}
+		case 3:
+			if d.expect(fieldType, typeI64, id) {
+				req.tokensRequested = d.i64()
+			}
+		case 4:
+			if d.expect(fieldType, typeI64, id) {
+				req.maxWaitMillisOverride = d.i64()
+			}
+		case 5:
+			if d.expect(fieldType, typeString, id) {
+				req.callerID = d.string()
+			}
+		default:
+			d.skip(fieldType, 0)
+		}
+	}
+}
+
+func (r *allowResponse) write(e *encoder) {
+	e.field(typeI32, 1)
+	e.i32(int32(r.status))
+	if r.status == pb.AllowResponse_OK {
+		e.field(typeI64, 2)
+		e.i64(r.tokensGranted)
+		e.field(typeI64, 3)
+		e.i64(r.waitMillis)
+	}
+
+	switch r.status {
+	case pb.AllowResponse_OK, pb.AllowResponse_REJECTED_TIMEOUT, pb.AllowResponse_REJECTED_TOO_MANY_WAITERS:
+		e.field(typeI64, 4)
+		e.i64(r.tokensRemaining)
+	}
+
+	if r.retryAfterMillis > 0 {
+		e.field(typeI64, 5)
+		e.i64(r.retryAfterMillis)
+	}
+	e.stop()
+}
+
+// exception returns a reply to the call m with a TApplicationException.
+func exception(m message, exceptionType int32, msg string) []byte {
+	e := &encoder{}
+	e.message(message{name: m.name, mtype: messageException, seqID: m.seqID})
+	e.field(typeString, 1)
+	e.string(msg)
+	e.field(typeI32, 2)
+	e.i32(exceptionType)
+	e.stop()
+	return e.Bytes()
+}
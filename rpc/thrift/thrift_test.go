@@ -0,0 +1,246 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package thrift
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/maniksurtani/quotaservice"
+	"github.com/maniksurtani/quotaservice/buckets/memory"
+	"github.com/maniksurtani/quotaservice/config"
+	pb "github.com/maniksurtani/quotaservice/protos"
+)
+
+func TestAllow(t *testing.T) {
+	c, stop := connect(t)
+	defer stop()
+
+	tests := []struct {
+		namespace, bucket string
+		tokens            int64
+		expected          allowResponse
+	}{
+		{"ns", "b", 0, allowResponse{status: pb.AllowResponse_OK, tokensGranted: 1, tokensRemaining: 1}},
+		{"ns", "b", 1, allowResponse{status: pb.AllowResponse_OK, tokensGranted: 1}},
+		{"ns", "b", 1, allowResponse{status: pb.AllowResponse_REJECTED_TIMEOUT, retryAfterMillis: 1000}},
+		{"ns", "denied", 1, allowResponse{status: pb.AllowResponse_REJECTED_DENIED}},
+		{"ns", "missing", 1, allowResponse{status: pb.AllowResponse_REJECTED_NO_BUCKET}},
+		{"ns", "b", 3, allowResponse{status: pb.AllowResponse_REJECTED_TOO_MANY_TOKENS_REQUESTED}},
+		{"ns", "", 1, allowResponse{status: pb.AllowResponse_REJECTED_INVALID_REQUEST}}}
+
+	for i, test := range tests {
+		d := c.call(t, messageCall, allowMethod, int32(i), func(e *encoder) {
+			e.field(typeStruct, 1)
+			e.field(typeString, 1)
+			e.string(test.namespace)
+			e.field(typeString, 2)
+			e.string(test.bucket)
+			if test.tokens > 0 {
+				e.field(typeI64, 3)
+				e.i64(test.tokens)
+			}
+			e.field(typeI64, 4)
+			e.i64(0)
+			e.stop()
+			e.stop()
+		})
+
+		m := d.message()
+		if m.mtype != messageReply || m.name != allowMethod || m.seqID != int32(i) {
+			t.Fatalf("%v/%v: expecting a reply to call %v, got %+v", test.namespace, test.bucket, i, m)
+		}
+
+		if rsp := readAllowResult(t, d); *rsp != test.expected {
+			t.Errorf("%v/%v: expecting %+v, got %+v", test.namespace, test.bucket, test.expected, *rsp)
+		}
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	c, stop := connect(t)
+	defer stop()
+
+	d := c.call(t, messageCall, allowMethod, 1, func(e *encoder) {
+		e.field(typeList, 7)
+		e.WriteByte(typeI32)
+		e.i32(2)
+		e.i32(1)
+		e.i32(2)
+		e.field(typeStruct, 1)
+		e.field(typeMap, 9)
+		e.WriteByte(typeString)
+		e.WriteByte(typeStruct)
+		e.i32(1)
+		e.string("k")
+		e.field(typeBool, 1)
+		e.WriteByte(1)
+		e.stop()
+		e.field(typeString, 1)
+		e.string("ns")
+		e.field(typeString, 2)
+		e.string("b")
+		e.stop()
+		e.stop()
+	})
+
+	if m := d.message(); m.mtype != messageReply {
+		t.Fatalf("Expecting a reply, got %+v", m)
+	}
+
+	if rsp := readAllowResult(t, d); rsp.status != pb.AllowResponse_OK || rsp.tokensGranted != 1 {
+		t.Errorf("Expecting a token, got %+v", *rsp)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c, stop := connect(t)
+	defer stop()
+
+	d := c.call(t, messageCall, "reserve", 3, func(e *encoder) { e.stop() })
+	if m := d.message(); m.mtype != messageException || m.name != "reserve" || m.seqID != 3 {
+		t.Fatalf("Expecting an exception, got %+v", m)
+	}
+
+	var exceptionType int32
+	for d.err == nil {
+		fieldType, id := d.field()
+		if fieldType == typeStop {
+			break
+		}
+
+		if id == 2 && d.expect(fieldType, typeI32, id) {
+			exceptionType = d.i32()
+		} else {
+			d.skip(fieldType, 0)
+		}
+	}
+
+	if d.err != nil || exceptionType != exceptionUnknownMethod {
+		t.Errorf("Expecting an unknown method exception, got type %v. Error %v", exceptionType, d.err)
+	}
+}
+
+func TestOldMessages(t *testing.T) {
+	e := &encoder{}
+	e.string(allowMethod)
+	e.WriteByte(messageCall)
+	e.i32(5)
+
+	d := &decoder{b: e.Bytes()}
+	if m := d.message(); d.err != nil || m != (message{allowMethod, messageCall, 5}) {
+		t.Errorf("Expecting a call to allow, got %+v. Error %v", m, d.err)
+	}
+}
+
+func TestSkipTruncated(t *testing.T) {
+	e := &encoder{}
+	e.WriteByte(typeI64)
+	e.i32(1000)
+
+	d := &decoder{b: e.Bytes()}
+	d.skip(typeList, 0)
+	if d.err != errTruncated {
+		t.Errorf("Expecting a truncated message, got %v", d.err)
+	}
+}
+
+type client struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// connect starts a server whose bucket ns/b holds 2 tokens, refilled once a second, and whose
+// bucket ns/denied denies all requests, connecting to its Thrift endpoint.
+func connect(t *testing.T) (*client, func()) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 2
+	b.FillRate = 1
+	b.WaitTimeoutMillis = 0
+	b.MaxDebtMillis = 0
+	b.MaxTokensPerRequest = 2
+	ns.AddBucket("b", b)
+	denied := config.NewDefaultBucketConfig()
+	denied.Mode = config.BucketModeAlwaysDeny
+	ns.AddBucket("denied", denied)
+	cfg.AddNamespace("ns", ns)
+
+	endpoint := New("localhost:0")
+	s := quotaservice.New(cfg, memory.NewBucketFactory(), endpoint)
+	s.Start()
+
+	conn, err := net.Dial("tcp", endpoint.listener.Addr().String())
+	if err != nil {
+		s.Stop()
+		t.Fatalf("Cannot connect. Error %v", err)
+	}
+
+	return &client{conn, bufio.NewReader(conn)}, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+// call sends a message whose arguments args writes, returning a decoder of the reply.
+func (c *client) call(t *testing.T, mtype byte, name string, seqID int32, args func(*encoder)) *decoder {
+	e := &encoder{}
+	e.message(message{name: name, mtype: mtype, seqID: seqID})
+	args(e)
+	if err := writeFrame(c, e.Bytes()); err != nil {
+		t.Fatalf("Cannot send %v. Error %v", name, err)
+	}
+
+	frame, err := readFrame(c.r)
+	if err != nil {
+		t.Fatalf("Cannot read reply to %v. Error %v", name, err)
+	}
+
+	return &decoder{b: frame}
+}
+
+func readAllowResult(t *testing.T, d *decoder) *allowResponse {
+	rsp := &allowResponse{}
+	for d.err == nil {
+		fieldType, id := d.field()
+		if fieldType == typeStop {
+			break
+		}
+
+		if id != 0 || !d.expect(fieldType, typeStruct, id) {
+			d.skip(fieldType, 0)
+			continue
+		}
+
+		for d.err == nil {
+			fieldType, id := d.field()
+			if fieldType == typeStop {
+				break
+			}
+
+			switch id {
+			case 1:
+				rsp.status = pb.AllowResponse_Status(d.i32())
+			case 2:
+				rsp.tokensGranted = d.i64()
+			case 3:
+				rsp.waitMillis = d.i64()
+			case 4:
+				rsp.tokensRemaining = d.i64()
+			case 5:
+				rsp.retryAfterMillis = d.i64()
+			default:
+				d.skip(fieldType, 0)
+			}
+		}
+	}
+
+	if d.err != nil {
+		t.Fatalf("Cannot read result. Error %v", d.err)
+	}
+
+	return rsp
+}
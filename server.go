@@ -4,11 +4,18 @@
 package quotaservice
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
 	"github.com/maniksurtani/quotaservice/lifecycle"
 	"github.com/maniksurtani/quotaservice/logging"
 
@@ -20,31 +27,83 @@ import (
 
 // Implements the quotaservice.Server interface
 type server struct {
-	cfgs              *config.ServiceConfig
-	currentStatus     lifecycle.Status
-	stopper           *chan int
-	bucketContainer   *bucketContainer
-	bucketFactory     BucketFactory
-	rpcEndpoints      []RpcEndpoint
-	listener          Listener
-	eventQueueBufSize int
-	producer          *EventProducer
-	p                 config.ConfigPersister
+	cfgs             *config.ServiceConfig
+	currentStatus    lifecycle.Status
+	stopper          *chan int
+	bucketContainer  *bucketContainer
+	bucketFactory    BucketFactory
+	rpcEndpoints     []RpcEndpoint
+	producers        []*EventProducer // One per listener
+	p                config.ConfigPersister
+	persisterOnce    sync.Once
+	persisterStopper chan struct{} // Stops listening for changes to persisted configs
+	cfgLock          sync.Mutex    // Serializes changes to cfgs
+	cfgFile          string
+	cfgFilePollFreq  time.Duration
+	cfgFileWatcher   *config.ConfigFileWatcher
+	signer           *config.ConfigSigner
+	adminOpts        admin.Options
+	adminLimit       *config.BucketConfig
+	adminLimiterOnce sync.Once
+	adminLimiter     *bucketContainer // Buckets limiting changes made via the admin API
+	adminServersLock sync.Mutex
+	adminServers     []*http.Server // Admin listeners, closed when the server stops
+	reservationsLock sync.Mutex
+	reservations     map[string]*reservation // By ID
+	scheduleStopper  chan struct{}           // Stops applying bucket schedules
+	snapshotStore    SnapshotStore
+	snapshotInterval time.Duration
+	snapshotStopper  chan struct{} // Stops snapshotting buckets
+	snapshotDone     chan struct{} // Closed once buckets are no longer snapshotted
+	restoreStore     SnapshotStore
+	restoreMaxAge    time.Duration
+	journal          Journal // Records requests' grants and denials, if set
+	clock            clock.Clock
+	onExhausted      []Listener // Called as buckets become exhausted
+	onRecovered      []Listener // Called as buckets recover from exhaustion
+	webhooks         []*webhookWatcher
+	webhookStopper   chan struct{} // Stops checking buckets against webhooks' thresholds
 }
 
+// scheduleCheckInterval is how often buckets' schedules are checked for windows starting or ending.
+const scheduleCheckInterval = 5 * time.Second
+
 func (s *server) String() string {
 	return fmt.Sprintf("Quota Server running with status %v", s.currentStatus)
 }
 
 func (s *server) Start() (bool, error) {
 	// Set up listeners
-	if s.listener != nil {
-		s.producer = registerListener(s.listener, s.eventQueueBufSize)
+	for _, p := range s.producers {
+		go p.notifyListeners()
 	}
 
 	// Initialize buckets
 	s.bucketFactory.Init(s.cfgs)
-	s.bucketContainer = NewBucketContainer(s.cfgs, s.bucketFactory, s)
+	s.bucketContainer = newBucketContainer(s.cfgs, s.bucketFactory, s, s.clock)
+	if s.restoreStore != nil {
+		s.restoreBuckets()
+	}
+
+	if s.cfgFile != "" {
+		s.cfgFileWatcher = config.NewConfigFileWatcher(s.cfgFile, s.cfgFilePollFreq)
+		go s.listenForConfigFileChanges(s.cfgFileWatcher)
+	}
+
+	s.scheduleStopper = make(chan struct{})
+	go s.applySchedules(s.scheduleStopper)
+
+	if len(s.webhooks) > 0 {
+		s.webhookStopper = make(chan struct{})
+		for _, w := range s.webhooks {
+			go w.watch(s.clock.NewTicker(webhookCheckInterval), s.webhookStopper)
+		}
+	}
+
+	if s.snapshotStore != nil {
+		s.snapshotStopper, s.snapshotDone = make(chan struct{}), make(chan struct{})
+		go s.snapshotBuckets(s.clock.NewTicker(s.snapshotInterval), s.snapshotStopper, s.snapshotDone)
+	}
 
 	// Start the RPC servers
 	for _, rpcServer := range s.rpcEndpoints {
@@ -59,59 +118,685 @@ func (s *server) Start() (bool, error) {
 func (s *server) Stop() (bool, error) {
 	s.currentStatus = lifecycle.Stopped
 
+	if s.cfgFileWatcher != nil {
+		s.cfgFileWatcher.Stop()
+		s.cfgFileWatcher = nil
+	}
+
+	if s.scheduleStopper != nil {
+		close(s.scheduleStopper)
+		s.scheduleStopper = nil
+	}
+
+	if s.webhookStopper != nil {
+		close(s.webhookStopper)
+		s.webhookStopper = nil
+	}
+
+	if s.snapshotStopper != nil {
+		close(s.snapshotStopper)
+		<-s.snapshotDone
+		s.snapshotStopper, s.snapshotDone = nil, nil
+		// Buckets' latest levels, for servers started in place of this one, saved once snapshots
+		// underway are, so that they can't overwrite it.
+		s.saveSnapshot()
+	}
+
 	// Stop the RPC servers
 	for _, rpcServer := range s.rpcEndpoints {
 		rpcServer.Stop()
 	}
 
+	s.adminServersLock.Lock()
+	for _, srv := range s.adminServers {
+		srv.Close()
+	}
+	s.adminServers = nil
+	s.adminServersLock.Unlock()
+
+	if s.persisterStopper != nil {
+		close(s.persisterStopper)
+		s.persisterStopper = nil
+	}
+
 	return true, nil
 }
 
 func (s *server) Allow(namespace, name string, tokensRequested int64, maxWaitMillisOverride int64) (time.Duration, error) {
+	_, w, e := s.Lease(context.Background(), namespace, name, tokensRequested, maxWaitMillisOverride, 0, PRIORITY_NORMAL, Caller{})
+	return w, e
+}
+
+func (s *server) Lease(ctx context.Context, namespace, name string, tokensRequested int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (string, time.Duration, error) {
+	c, e := s.claim(ctx, namespace, name, "", tokensRequested, tokensRequested, maxWaitMillisOverride, maxDebtMillisOverride, priority, caller, false)
+	return c.LeaseID, c.Wait, e
+}
+
+func (s *server) LeaseRange(ctx context.Context, namespace, name string, minTokens, maxTokens int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (int64, string, time.Duration, error) {
+	c, e := s.claim(ctx, namespace, name, "", minTokens, maxTokens, maxWaitMillisOverride, maxDebtMillisOverride, priority, caller, false)
+	if e != nil {
+		return 0, "", 0, e
+	}
+
+	return c.Tokens, c.LeaseID, c.Wait, nil
+}
+
+func (s *server) Claim(ctx context.Context, namespace, name, operation string, minTokens, maxTokens int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller) (*Grant, error) {
+	c, e := s.claim(ctx, namespace, name, operation, minTokens, maxTokens, maxWaitMillisOverride, maxDebtMillisOverride, priority, caller, false)
+	if e != nil {
+		c.Grant = Grant{}
+	}
+
+	c.estimate(e)
+	return &c.Grant, e
+}
+
+// available returns the tokens between minTokens and maxTokens that a bucket and its parents hold,
+// capped by the bucket's maxTokensPerRequest, and leaving out those held back for high priority
+// requests unless priority is high. Buckets that can't report their state are assumed to hold
+// maxTokens.
+func (s *server) available(b *expirableBucket, parents []*expirableBucket, minTokens, maxTokens int64, priority Priority) int64 {
+	n := maxTokens
+	if max := b.Config().MaxTokensPerRequest; max > 0 && max < n {
+		n = max
+	}
+
+	for _, r := range limiting(append([]*expirableBucket{b}, parents...)) {
+		if sr, ok := r.Bucket.(StatusReporter); ok {
+			t := sr.Status().Tokens
+			if priority < PRIORITY_HIGH {
+				t -= r.Config().HighPriorityReserve
+			}
+
+			if t < n {
+				n = t
+			}
+		}
+	}
+
+	if n < minTokens {
+		n = minTokens
+	}
+
+	return n
+}
+
+// claimed is the outcome of a claim for tokens.
+type claimed struct {
+	Grant
+	// The bucket requested, if there is one, and its parents.
+	bucket  *expirableBucket
+	parents []*expirableBucket
+	// The fewest tokens the claim needed, once priced.
+	needed int64
+	// The buckets tokens were taken from.
+	taken []*expirableBucket
+}
+
+// price prices the tokens a claim for an operation needs, between minTokens and maxTokens, by the
+// costs of the bucket requested, setting as many as it and its parents hold to be claimed. Fails
+// with ER_TOO_MANY_TOKENS_REQUESTED if they overflow.
+func (s *server) price(namespace, name string, c *claimed, operation string, minTokens, maxTokens int64, priority Priority) error {
+	cost := c.bucket.Config().Cost(operation)
+	if cost > 1 && (minTokens > math.MaxInt64/cost || maxTokens > math.MaxInt64/cost) {
+		return newError(fmt.Sprintf("Too many tokens requested. Bucket %v:%v, tokensRequested=%v, cost of %v=%v",
+			namespace, name, maxTokens, operation, cost),
+			ER_TOO_MANY_TOKENS_REQUESTED)
+	}
+
+	c.needed, c.Tokens = minTokens*cost, maxTokens*cost
+	if c.needed < c.Tokens {
+		c.Tokens = s.available(c.bucket, c.parents, c.needed, c.Tokens, priority)
+	}
+
+	return nil
+}
+
+// estimate sets the size of the bucket claimed from and the fewest tokens left in it and the
+// parents limiting it, of those that can report them, and if the claim was refused for want of
+// tokens, with e, how long until the tokens it needed are projected to be available. Buckets set to deny
+// all requests have none left, while claims refused for other reasons are left without estimates.
+func (c *claimed) estimate(e error) {
+	c.Remaining = -1
+	if c.bucket == nil {
+		return
+	}
+
+	c.Limit = c.bucket.Config().Size
+	wanting := false
+	if qsErr, ok := e.(QuotaServiceError); ok {
+		if qsErr.Reason == ER_DENIED {
+			c.Remaining = 0
+		}
+		wanting = qsErr.Reason == ER_TIMEOUT || qsErr.Reason == ER_TOO_MANY_WAITERS
+	}
+
+	if e != nil && !wanting {
+		return
+	}
+
+	for _, r := range limiting(append([]*expirableBucket{c.bucket}, c.parents...)) {
+		sr, ok := r.Bucket.(StatusReporter)
+		if !ok {
+			continue
+		}
+
+		status := sr.Status()
+		if c.Remaining < 0 || status.Tokens < c.Remaining {
+			c.Remaining = status.Tokens
+		}
+
+		if w := r.projectedWait(status.Tokens, status.DebtMillis, c.needed); wanting && w > c.RetryAfter {
+			c.RetryAfter = w
+		}
+	}
+}
+
+// claim claims tokens of an operation from the bucket requested as claimTokens does, as many
+// between minTokens and maxTokens as it and its parents hold, once priced by its costs, recording
+// the outcome in the bucket's usage stats and the journal. The claim is returned even if it's
+// refused, along with the error, holding the bucket requested if there's one.
+func (s *server) claim(ctx context.Context, namespace, name, operation string, minTokens, maxTokens int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller, reserving bool) (*claimed, error) {
+	c := &claimed{Grant: Grant{Tokens: maxTokens}}
+	b, e := s.requestedBucket(namespace, name)
+	if e == nil {
+		c.bucket, c.parents = b, s.bucketContainer.parents(namespace, b)
+		if e = s.price(namespace, name, c, operation, minTokens, maxTokens, priority); e == nil {
+			e = s.allowsTokens(namespace, name, b, c.Tokens, caller)
+		}
+	}
+
+	if e == nil {
+		c.LeaseID, c.taken, c.Wait, e = s.claimTokens(ctx, namespace, name, b, c.parents, c.Tokens, maxWaitMillisOverride, maxDebtMillisOverride, priority, caller, reserving)
+	}
+
+	if b != nil {
+		b.usage.record(s.clock.Now(), c.Tokens, e)
+	}
+	s.journalGrant(namespace, name, c.Tokens, caller, c.Wait, e)
+	return c, e
+}
+
+// requestedBucket finds the bucket requests for tokens from name are served by, failing if there's
+// none.
+func (s *server) requestedBucket(namespace, name string) (*expirableBucket, error) {
 	b, e := s.bucketContainer.FindBucket(namespace, name)
 	if e != nil {
 		// Attempted to create a dynamic bucket and failed.
-		s.Emit(newBucketMissedEvent(namespace, name, true))
-		return 0, newError("Cannot create dynamic bucket "+config.FullyQualifiedName(namespace, name), ER_TOO_MANY_BUCKETS)
+		s.Emit(newBucketMissedEvent(namespace, name, true, nil))
+		return nil, newError("Cannot create dynamic bucket "+config.FullyQualifiedName(namespace, name), ER_TOO_MANY_BUCKETS)
 	}
 
 	if b == nil {
-		s.Emit(newBucketMissedEvent(namespace, name, false))
-		return 0, newError("No such bucket "+config.FullyQualifiedName(namespace, name), ER_NO_BUCKET)
+		s.Emit(newBucketMissedEvent(namespace, name, false, nil))
+		return nil, newError("No such bucket "+config.FullyQualifiedName(namespace, name), ER_NO_BUCKET)
 	}
 
+	return b, nil
+}
+
+// allowsTokens fails if b, the bucket requested as name, doesn't allow as many tokens per request.
+func (s *server) allowsTokens(namespace, name string, b *expirableBucket, tokensRequested int64, caller Caller) error {
 	if b.Config().MaxTokensPerRequest < tokensRequested && b.Config().MaxTokensPerRequest > 0 {
-		s.Emit(newTooManyTokensRequestedEvent(namespace, name, b.Dynamic(), tokensRequested))
-		return 0, newError(fmt.Sprintf("Too many tokens requested. Bucket %v:%v, tokensRequested=%v, maxTokensPerRequest=%v",
+		s.Emit(newTooManyTokensRequestedEvent(namespace, name, b.Dynamic(), b.Config(), tokensRequested, caller))
+		return newError(fmt.Sprintf("Too many tokens requested. Bucket %v:%v, tokensRequested=%v, maxTokensPerRequest=%v",
 			namespace, name, tokensRequested, b.Config().MaxTokensPerRequest),
 			ER_TOO_MANY_TOKENS_REQUESTED)
 	}
 
-	maxWaitTime := time.Millisecond
-	if maxWaitMillisOverride > -1 && maxWaitMillisOverride < b.Config().WaitTimeoutMillis {
-		// Use the max wait time override from the request.
-		maxWaitTime *= time.Duration(maxWaitMillisOverride)
-	} else {
-		// Fall back to the max wait time configured on the bucket.
-		maxWaitTime *= time.Duration(b.Config().WaitTimeoutMillis)
+	return nil
+}
+
+// claimTokens takes tokens from b, the bucket requested as name, and its parents, returning the
+// lease granted by the bucket, if any, and the buckets taken from. Buckets lend no more than
+// maxDebtMillisOverride, if it's positive and lower than their max debt. If reserving, buckets that
+// can't take tokens back are refused. Buckets set to deny all requests refuse them, while those set to allow all requests are
+// bypassed, neither limiting them nor giving up tokens. Buckets in shadow mode grant requests they
+// would refuse, without making callers wait, as do buckets that don't enforce their refusals on
+// caller. Callers give up waiting for tokens once ctx is done.
+func (s *server) claimTokens(ctx context.Context, namespace, name string, b *expirableBucket, parents []*expirableBucket, tokensRequested int64, maxWaitMillisOverride, maxDebtMillisOverride int64, priority Priority, caller Caller, reserving bool) (string, []*expirableBucket, time.Duration, error) {
+	for _, r := range append([]*expirableBucket{b}, parents...) {
+		if r.currentMode() == config.BucketModeAlwaysDeny {
+			return "", nil, 0, newError(fmt.Sprintf("Bucket %v:%v is set to deny all requests", namespace, r.Config().Name), ER_DENIED)
+		}
+	}
+
+	bypassed := b.currentMode() == config.BucketModeAlwaysAllow
+	parents = limiting(parents)
+	claimed := parents
+	if !bypassed {
+		claimed = append([]*expirableBucket{b}, parents...)
+	}
+
+	if reserving {
+		for _, r := range claimed {
+			if _, ok := r.Bucket.(TokenReturner); !ok {
+				return "", nil, 0, newError(fmt.Sprintf("Bucket %v:%v can't hold reservations", namespace, r.Config().Name),
+					ER_NOT_RESERVABLE)
+			}
+		}
+	}
+
+	// Buckets that don't enforce their refusals are left out once they've reported them.
+	var limited []*expirableBucket
+	for _, r := range claimed {
+		if !r.heldBack(tokensRequested, priority) {
+			limited = append(limited, r)
+		} else if s.refuse(namespace, r.Config().Name, r, tokensRequested, caller) {
+			return "", nil, 0, newError(fmt.Sprintf("Tokens in %v:%v are held back for high priority requests", namespace, r.Config().Name),
+				ER_TIMEOUT)
+		}
 	}
 
-	w, success := b.Take(tokensRequested, maxWaitTime)
+	maxWaitTime := b.maxWaitTime(maxWaitMillisOverride)
+
+	// Tokens must be available in the bucket and every parent. If one has run out, the lease is
+	// released and tokens are returned to the buckets that can take them back.
+	maxDebt := time.Duration(maxDebtMillisOverride) * time.Millisecond
+	var leaseID string
+	var w time.Duration
+	var taken []*expirableBucket
+	for _, r := range limited {
+		rName, desc := name, fmt.Sprintf("%v:%v", namespace, name)
+		if r != b {
+			rName = r.Config().Name
+			desc = fmt.Sprintf("parent %v:%v of %v", namespace, rName, name)
+		}
+
+		admitted := r.admit()
+		var id string
+		var rw time.Duration
+		var success bool
+		if admitted && r == b {
+			id, rw, success = b.lease(ctx, tokensRequested, maxWaitTime, maxDebt)
+			r.leave(rw)
+		} else if admitted {
+			rw, success = r.take(ctx, tokensRequested, maxWaitTime, maxDebt)
+			r.leave(rw)
+		}
+
+		if !admitted || !success {
+			if !s.refuse(namespace, rName, r, tokensRequested, caller) {
+				continue
+			}
+
+			if leaseID != "" {
+				b.Bucket.(Leaser).Release(leaseID)
+			}
+			returnTokens(taken, tokensRequested)
+
+			if !admitted {
+				return "", nil, 0, newError("Too many callers waiting on "+desc, ER_TOO_MANY_WAITERS)
+			}
+
+			if ctx.Err() != nil {
+				return "", nil, 0, newError(fmt.Sprintf("Gave up waiting on %v: %v", desc, ctx.Err()), ER_TIMEOUT)
+			}
+			// Could not claim tokens within the given max wait time
+			return "", nil, 0, newError("Timed out waiting on "+desc, ER_TIMEOUT)
+		}
+
+		if r == b {
+			leaseID = id
+		}
 
-	if !success {
-		// Could not claim tokens within the given max wait time
-		s.Emit(newTimedOutEvent(namespace, name, b.Dynamic(), tokensRequested))
-		return 0, newError(fmt.Sprintf("Timed out waiting on %v:%v", namespace, name), ER_TIMEOUT)
+		taken = append(taken, r)
+		// Buckets in shadow mode don't make callers wait.
+		if rw > w && r.currentMode() != config.BucketModeShadow {
+			w = rw
+		}
 	}
 
 	// The only positive result
-	s.Emit(newTokensServedEvent(namespace, name, b.Dynamic(), tokensRequested, w))
-	return w, nil
+	s.Emit(newTokensServedEvent(namespace, name, b.Dynamic(), b.Config(), tokensRequested, caller, w))
+	for _, r := range taken {
+		s.served(namespace, r.Config().Name, r)
+	}
+	return leaseID, taken, w, nil
+}
+
+// refuse reports that bucket r, named name, refused a request by caller for tokensRequested tokens,
+// returning whether the refusal stands. Refusals by buckets in shadow mode, or that don't enforce
+// their refusals on caller, are reported as shadow refusals, and don't.
+func (s *server) refuse(namespace, name string, r *expirableBucket, tokensRequested int64, caller Caller) bool {
+	if r.currentMode() == config.BucketModeShadow || !r.enforces(caller.ID) {
+		s.Emit(newShadowRefusedEvent(namespace, name, r.Dynamic(), r.Config(), tokensRequested, caller))
+		return false
+	}
+
+	s.Emit(newTimedOutEvent(namespace, name, r.Dynamic(), r.Config(), tokensRequested, caller))
+	s.refused(namespace, name, r)
+	return true
+}
+
+func (s *server) Limit(namespace, name string) int64 {
+	b := s.bucketContainer.peekBucket(namespace, name)
+	if b == nil {
+		return 0
+	}
+
+	return b.Config().Size
+}
+
+func (s *server) Release(namespace, name, leaseID string) error {
+	// Releasing doesn't create dynamic buckets, whose leases go with them once evicted.
+	b := s.bucketContainer.peekBucket(namespace, name)
+	if b != nil {
+		if _, standIn := b.Bucket.(*templateBucket); standIn {
+			b = nil
+		}
+	}
+
+	if b == nil {
+		return newError("No such bucket "+config.FullyQualifiedName(namespace, name), ER_NO_BUCKET)
+	}
+
+	l, ok := b.Bucket.(Leaser)
+	if !ok || !l.Release(leaseID) {
+		return newError(fmt.Sprintf("No lease %v on %v:%v", leaseID, namespace, name), ER_NO_LEASE)
+	}
+
+	return nil
 }
 
 func (s *server) ServeAdminConsole(mux *http.ServeMux, assetsDir string, p config.ConfigPersister) {
-	admin.ServeAdminConsole(s, mux, assetsDir)
-	s.p = p
+	admin.ServeAdminConsole(s, mux, assetsDir, s.adminOpts)
+	if p != nil {
+		s.usePersister(p)
+	}
+}
+
+// usePersister has configs persisted with p, listening for changes to them, the first time an
+// admin console is served with a persister. Admin consoles served since share it.
+func (s *server) usePersister(p config.ConfigPersister) {
+	s.persisterOnce.Do(func() {
+		s.p = p
+		s.persisterStopper = make(chan struct{})
+		go s.listenForConfigChanges(p, s.persisterStopper)
+	})
+
+	if p != s.p {
+		logging.Warnf("Ignoring ConfigPersister %T; configs are already persisted with the first one the admin console was served with", p)
+	}
+}
+
+func (s *server) ServeAdminConsoleTLS(addr string, tlsConfig admin.TLSConfig, assetsDir string, p config.ConfigPersister) error {
+	srv, e := s.newAdminServer(addr, &tlsConfig, assetsDir, p)
+	if e != nil {
+		return e
+	}
+
+	// Served until the server stops, so callers needn't close it themselves.
+	s.addAdminServer(srv)
+	return srv.ListenAndServeTLS("", "")
+}
+
+func (s *server) ListenAdmin(addr string, tlsConfig *admin.TLSConfig, assetsDir string, p config.ConfigPersister) (net.Addr, error) {
+	srv, e := s.newAdminServer(addr, tlsConfig, assetsDir, p)
+	if e != nil {
+		return nil, e
+	}
+
+	l, e := net.Listen("tcp", addr)
+	if e != nil {
+		return nil, e
+	}
+
+	if srv.TLSConfig != nil {
+		l = tls.NewListener(l, srv.TLSConfig)
+	}
+
+	s.addAdminServer(srv)
+	logging.Printf("Serving admin console on %v.", l.Addr())
+	go func() {
+		if e := srv.Serve(l); e != nil && e != http.ErrServerClosed {
+			logging.Printf("Stopped serving admin console on %v. Error: %v", l.Addr(), e)
+		}
+	}()
+
+	return l.Addr(), nil
+}
+
+func (s *server) addAdminServer(srv *http.Server) {
+	s.adminServersLock.Lock()
+	defer s.adminServersLock.Unlock()
+	s.adminServers = append(s.adminServers, srv)
+}
+
+// newAdminServer creates an HTTP server for the admin console, using TLS if tlsConfig is set.
+func (s *server) newAdminServer(addr string, tlsConfig *admin.TLSConfig, assetsDir string, p config.ConfigPersister) (*http.Server, error) {
+	var tlsCfg *tls.Config
+	if tlsConfig != nil {
+		var e error
+		if tlsCfg, e = tlsConfig.ServerTLSConfig(); e != nil {
+			return nil, e
+		}
+	}
+
+	mux := http.NewServeMux()
+	s.ServeAdminConsole(mux, assetsDir, p)
+
+	var h http.Handler = mux
+	if tlsConfig != nil && tlsConfig.ClientCAFile != "" {
+		h = admin.RequireClientCertsForChanges(mux)
+	}
+
+	return &http.Server{Addr: addr, Handler: h, TLSConfig: tlsCfg}, nil
+}
+
+// listenForConfigChanges applies configs read from the ConfigPersister whenever it reports a
+// change. This picks up changes made by other nodes sharing the same persister.
+func (s *server) listenForConfigChanges(p config.ConfigPersister, stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-p.ConfigChangedWatcher():
+			if cfg := s.readPersistedConfig(p); cfg != nil {
+				s.applyConfig(cfg)
+			}
+		}
+	}
+}
+
+// readPersistedConfig reads the config persisted by p, returning nil, having logged why, if it
+// can't be read or its signature doesn't verify.
+func (s *server) readPersistedConfig(p config.ConfigPersister) *config.ServiceConfig {
+	r, e := p.ReadPersistedConfig()
+	if e != nil {
+		logging.Warnf("Unable to read persisted config. Error: %v", e)
+		return nil
+	}
+
+	cfg, e := config.Unmarshal(r)
+	if e != nil {
+		logging.Warnf("Unable to unmarshal persisted config. Error: %v", e)
+		return nil
+	}
+
+	if s.signer != nil {
+		if e = s.signer.Verify(cfg); e != nil {
+			logging.Warnf("Ignoring persisted config version %v. Error: %v", cfg.Version, e)
+			return nil
+		}
+	}
+
+	return cfg
+}
+
+// applyConfig replaces the current config with cfg, if cfg is newer.
+func (s *server) applyConfig(cfg *config.ServiceConfig) {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
+	if cfg.Version <= s.cfgs.Version {
+		// Nothing new; most likely our own change being reported back.
+		return
+	}
+
+	logging.Printf("Applying config version %v; replacing version %v", cfg.Version, s.cfgs.Version)
+	s.bucketContainer.replaceConfig(cfg)
+	s.cfgs = cfg
+	s.Emit(newConfigChangedEvent(cfg.Version, cfg.User))
+}
+
+func (s *server) RequireSignedConfigs(key []byte) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot require signed configs after server has started!")
+	}
+
+	s.signer = config.NewConfigSigner(key)
+}
+
+func (s *server) RequireAdminAuthentication(auth admin.Authenticator) {
+	s.adminOpts.Authenticator = auth
+}
+
+func (s *server) AllowAdminCORS(cors admin.CORSConfig) {
+	s.adminOpts.CORS = &cors
+}
+
+func (s *server) RateLimitAdminChanges(limit *config.BucketConfig) {
+	s.adminLimit = limit.Clone().ApplyDefaults()
+	s.adminOpts.RateLimiter = s.allowAdminChange
+}
+
+// allowAdminChange claims a token from the client's bucket in the admin namespace, which is held
+// apart from the service's own config. The container is created on first use, as bucket factories
+// are only initialized once the server has started.
+func (s *server) allowAdminChange(client string) (time.Duration, bool) {
+	s.adminLimiterOnce.Do(func() {
+		ns := config.NewDefaultNamespaceConfig()
+		ns.Name = config.AdminNamespace
+		ns.DynamicBucketTemplate = s.adminLimit
+		cfg := &config.ServiceConfig{Namespaces: map[string]*config.NamespaceConfig{config.AdminNamespace: ns}}
+		s.adminLimiter = newBucketContainer(cfg, s.bucketFactory, s, s.clock)
+	})
+
+	b, e := s.adminLimiter.FindBucket(config.AdminNamespace, client)
+	if e != nil || b == nil {
+		logging.Warnf("Unable to rate limit admin changes by %v: %v", client, e)
+		return 0, true
+	}
+
+	if _, ok := b.Take(1, 0); !ok {
+		return time.Duration(s.adminLimit.NanosPerToken(s.adminLimit.FillRate)), false
+	}
+
+	return 0, true
+}
+
+func (s *server) StageAdminChanges(staging admin.StagingConfig) {
+	s.adminOpts.Staging = &staging
+}
+
+func (s *server) SetAdminDevelopmentMode(development bool) {
+	s.adminOpts.Development = development
+}
+
+func (s *server) BucketStatus(namespace, name string) (*admin.BucketStatus, error) {
+	b := s.bucketContainer.liveBucket(namespace, name)
+	if b == nil {
+		return nil, config.NotFoundError{Message: "No live bucket " + config.FullyQualifiedName(namespace, name)}
+	}
+
+	return b.status(namespace, name)
+}
+
+func (s *server) DynamicBuckets(namespace string) ([]*admin.BucketStatus, error) {
+	buckets, e := s.bucketContainer.dynamicBuckets(namespace)
+	if e != nil {
+		return nil, e
+	}
+
+	names := make([]string, 0, len(buckets))
+	for name := range buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statuses := make([]*admin.BucketStatus, len(names))
+	for i, name := range names {
+		if statuses[i], e = buckets[name].status(namespace, name); e != nil {
+			return nil, e
+		}
+	}
+
+	return statuses, nil
+}
+
+func (s *server) EvictDynamicBucket(namespace, name, user string) error {
+	if e := s.bucketContainer.evictDynamicBucket(namespace, name); e != nil {
+		return e
+	}
+
+	logging.Printf("Evicted dynamic bucket %v, as requested by %q", config.FullyQualifiedName(namespace, name), user)
+	return nil
+}
+
+func (s *server) VerifySignature(payload []byte, signature string) error {
+	if s.signer == nil {
+		return nil
+	}
+
+	return s.signer.VerifyPayload(payload, signature)
+}
+
+func (s *server) WatchConfigFile(filename string, pollFreq time.Duration) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot watch config file after server has started!")
+	}
+
+	if pollFreq <= 0 {
+		panic("Config file poll frequency must be greater than 0")
+	}
+
+	s.cfgFile = filename
+	s.cfgFilePollFreq = pollFreq
+}
+
+// listenForConfigFileChanges applies configs read from a config file whenever it changes. Configs
+// in files aren't versioned, so each change is applied as the next version of the current config,
+// and persisted if a ConfigPersister is available.
+func (s *server) listenForConfigFileChanges(w *config.ConfigFileWatcher) {
+	for cfg := range w.Configs() {
+		s.cfgLock.Lock()
+		logging.Printf("Applying config from file %v; replacing version %v", s.cfgFile, s.cfgs.Version)
+		s.replaceConfig(cfg, "")
+		s.cfgLock.Unlock()
+	}
+}
+
+// applySchedules re-applies the current config whenever a window in a bucket's schedule starts or
+// ends, until stop is closed, so that the buckets it changes are replaced all at once.
+func (s *server) applySchedules(stop chan struct{}) {
+	t := s.clock.NewTicker(scheduleCheckInterval)
+	defer t.Stop()
+
+	last := s.clock.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-t.C():
+			s.cfgLock.Lock()
+			if s.cfgs.ScheduleChanged(last, now) {
+				logging.Printf("Applying bucket schedules to config version %v", s.cfgs.Version)
+				s.bucketContainer.replaceConfig(s.cfgs)
+			}
+			s.cfgLock.Unlock()
+			last = now
+		}
+	}
+}
+
+// replaceConfig replaces the current config with cfg, as the next version of the current config
+// changed by user, and persists it. Should only be called while holding cfgLock.
+func (s *server) replaceConfig(cfg *config.ServiceConfig, user string) error {
+	cfg.Version = s.cfgs.Version
+	s.bucketContainer.replaceConfig(cfg)
+	s.cfgs = cfg
+	return s.saveUpdatedConfigs(user)
 }
 
 func (s *server) SetLogger(logger logging.Logger) {
@@ -121,7 +806,18 @@ func (s *server) SetLogger(logger logging.Logger) {
 	logging.SetLogger(logger)
 }
 
+func (s *server) SetClock(c clock.Clock) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot set clock after server has started!")
+	}
+	s.clock = c
+}
+
 func (s *server) SetListener(listener Listener, eventQueueBufSize int) {
+	s.AddListener(listener, eventQueueBufSize)
+}
+
+func (s *server) AddListener(listener Listener, eventQueueBufSize int) *EventProducer {
 	if s.currentStatus == lifecycle.Started {
 		panic("Cannot add listener after server has started!")
 	}
@@ -130,34 +826,57 @@ func (s *server) SetListener(listener Listener, eventQueueBufSize int) {
 		panic("Event queue buffer size must be greater than 0")
 	}
 
-	s.listener = listener
-	s.eventQueueBufSize = eventQueueBufSize
+	p := newEventProducer(listener, eventQueueBufSize)
+	s.producers = append(s.producers, p)
+	return p
 }
 
 func (s *server) Emit(e Event) {
-	if s.producer != nil {
-		s.producer.Emit(e)
+	for _, p := range s.producers {
+		p.Emit(e)
 	}
 }
 
 // Implements admin.Administrable
 func (s *server) Configs() *config.ServiceConfig {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
 	return s.cfgs
 }
 
-func (s *server) DeleteBucket(namespace, name string) error {
+func (s *server) DeleteBucket(namespace, name, user string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
+	if ns := s.cfgs.Namespaces[namespace]; ns != nil {
+		if e := ns.ValidateBucketDeletion(name); e != nil {
+			return e
+		}
+	}
+
 	err := s.bucketContainer.deleteBucket(namespace, name)
 	if err != nil {
 		return err
 	}
 
-	s.saveUpdatedConfigs()
-	return nil
+	return s.saveUpdatedConfigs(user)
 }
 
-func (s *server) AddBucket(namespace string, b *pb.BucketConfig) error {
+func (s *server) AddBucket(namespace string, b *pb.BucketConfig, user string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
+	if e := s.validateBucket(namespace, b); e != nil {
+		return e
+	}
+
+	return s.addBucket(namespace, b, user)
+}
+
+func (s *server) addBucket(namespace string, b *pb.BucketConfig, user string) error {
 	if !s.bucketContainer.NamespaceExists(namespace) && namespace != config.GlobalNamespace {
-		return errors.New("Namespace doesn't exist")
+		return config.NotFoundError{Message: "Namespace doesn't exist"}
 	}
 
 	if namespace == config.GlobalNamespace {
@@ -167,65 +886,200 @@ func (s *server) AddBucket(namespace string, b *pb.BucketConfig) error {
 		}
 	} else {
 		if s.bucketContainer.Exists(namespace, b.Name) {
-			return errors.New("Bucket already exists")
+			return config.AlreadyExistsError{Message: "Bucket already exists"}
 		}
 
 		s.bucketContainer.RLock()
 		defer s.bucketContainer.RUnlock()
 		ns := s.bucketContainer.namespaces[namespace]
-		s.bucketContainer.createNewNamedBucketFromCfg(namespace,
-			b.Name, ns, config.BucketFromProto(b, ns.cfg), false)
+		bCfg := config.BucketFromProto(b, ns.cfg)
+		ns.cfg.AddBucket(b.Name, bCfg)
+		s.bucketContainer.createNewNamedBucketFromCfg(namespace, b.Name, ns, bCfg, false)
 	}
 
-	s.saveUpdatedConfigs()
-	return nil
+	return s.saveUpdatedConfigs(user)
 }
 
-func (s *server) UpdateBucket(namespace string, b *pb.BucketConfig) error {
+func (s *server) UpdateBucket(namespace string, b *pb.BucketConfig, version int, user string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
+	if e := s.cfgs.CheckVersion(version); e != nil {
+		return e
+	}
+
+	if e := s.validateBucket(namespace, b); e != nil {
+		return e
+	}
+
 	// Simple delete and add?
 	e := s.bucketContainer.deleteBucket(namespace, b.Name)
 	if e != nil {
 		return e
 	}
 
-	return s.AddBucket(namespace, b)
+	return s.addBucket(namespace, b, user)
+}
+
+// validateBucket validates a bucket about to be added to a namespace. Should only be called while
+// holding cfgLock.
+func (s *server) validateBucket(namespace string, b *pb.BucketConfig) error {
+	return config.BucketFromProto(b, s.cfgs.Namespaces[namespace]).Validate()
 }
 
-func (s *server) DeleteNamespace(n string) error {
+func (s *server) DeleteNamespace(n, user string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
 	err := s.bucketContainer.deleteNamespace(n)
 	if err != nil {
 		return err
 	}
 
-	s.saveUpdatedConfigs()
-	return nil
+	return s.saveUpdatedConfigs(user)
+}
+
+func (s *server) AddNamespace(n *pb.NamespaceConfig, user string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
+	if e := config.NamespaceFromProto(n).Validate(); e != nil {
+		return e
+	}
+
+	return s.addNamespace(n, user)
 }
 
-func (s *server) AddNamespace(n *pb.NamespaceConfig) error {
+func (s *server) addNamespace(n *pb.NamespaceConfig, user string) error {
 	e := s.bucketContainer.createNamespace(config.NamespaceFromProto(n))
 	if e != nil {
 		return e
 	}
-	s.saveUpdatedConfigs()
-	return nil
+	return s.saveUpdatedConfigs(user)
 }
 
-func (s *server) UpdateNamespace(n *pb.NamespaceConfig) error {
+func (s *server) UpdateNamespace(n *pb.NamespaceConfig, version int, user string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
+	if e := s.cfgs.CheckVersion(version); e != nil {
+		return e
+	}
+
+	if e := config.NamespaceFromProto(n).Validate(); e != nil {
+		return e
+	}
+
 	err := s.bucketContainer.deleteNamespace(n.Name)
 	if err != nil {
 		return err
 	}
 
-	return s.AddNamespace(n)
+	return s.addNamespace(n, user)
+}
+
+func (s *server) ReplaceConfig(cfg *config.ServiceConfig, version int, user string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
+	if e := s.cfgs.CheckVersion(version); e != nil {
+		return e
+	}
+
+	if e := cfg.Validate(); e != nil {
+		return e
+	}
+
+	logging.Printf("Replacing config version %v", version)
+	return s.replaceConfig(cfg, user)
 }
 
-func (s *server) saveUpdatedConfigs() error {
+func (s *server) HistoricalConfigs() ([]*config.ServiceConfig, error) {
+	if s.p == nil {
+		return nil, errors.New("No ConfigPersister available to read config history from")
+	}
+
+	readers, e := s.p.ReadHistoricalConfigs()
+	if e != nil {
+		return nil, e
+	}
+
+	cfgs := make([]*config.ServiceConfig, len(readers))
+	for i, r := range readers {
+		if cfgs[i], e = config.Unmarshal(r); e != nil {
+			return nil, e
+		}
+	}
+
+	return cfgs, nil
+}
+
+func (s *server) RollbackConfig(version int, user string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+
+	history, e := s.HistoricalConfigs()
+	if e != nil {
+		return e
+	}
+
+	for _, cfg := range history {
+		if cfg.Version == version {
+			if s.signer != nil {
+				if e = s.signer.Verify(cfg); e != nil {
+					return e
+				}
+			}
+
+			// Limits may have been tightened since the version was live.
+			if e = cfg.Validate(); e != nil {
+				return e
+			}
+
+			logging.Printf("Rolling back to config version %v; replacing version %v", version, s.cfgs.Version)
+			return s.replaceConfig(cfg, user)
+		}
+	}
+
+	return config.UnknownVersionError{Version: version}
+}
+
+// saveUpdatedConfigs bumps the version of the current config, records who changed it, signs it if
+// configs are signed, notifies listeners, and persists it if a ConfigPersister is available. Should
+// only be called while holding cfgLock.
+func (s *server) saveUpdatedConfigs(user string) error {
+	s.cfgs.Version++
+	s.cfgs.Date = s.clock.Now().UnixNano() / int64(time.Millisecond)
+	s.cfgs.User = user
+	if s.signer != nil {
+		if e := s.signer.Sign(s.cfgs); e != nil {
+			return e
+		}
+	}
+
+	// Listeners are told of changes once they're persisted, so never of versions that lose.
 	if s.p != nil {
 		r, e := config.Marshal(s.cfgs)
 		if e != nil {
 			return e
 		}
-		return s.p.PersistAndNotify(r)
+
+		e = s.p.PersistAndNotify(r)
+		if _, ok := e.(config.VersionMismatchError); ok {
+			// Another node changed the config first, so the change is undone in favour of theirs.
+			if cfg := s.readPersistedConfig(s.p); cfg != nil {
+				logging.Printf("Config version %v lost to a change by another node; applying version %v", s.cfgs.Version, cfg.Version)
+				s.bucketContainer.replaceConfig(cfg)
+				s.cfgs = cfg
+				s.Emit(newConfigChangedEvent(cfg.Version, cfg.User))
+			}
+		}
+
+		if e != nil {
+			return e
+		}
 	}
+
+	s.Emit(newConfigChangedEvent(s.cfgs.Version, user))
 	return nil
 }
@@ -4,9 +4,26 @@
 package quotaservice
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
 	"github.com/maniksurtani/quotaservice/config"
 	"github.com/maniksurtani/quotaservice/test/helpers"
-	"testing"
 )
 
 func TestWithNoRpcs(t *testing.T) {
@@ -20,3 +37,1449 @@ func TestValidServer(t *testing.T) {
 	s.Start()
 	defer s.Stop()
 }
+
+func TestListenAdmin(t *testing.T) {
+	s := New(config.NewDefaultServiceConfig(), &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	addr, e := s.ListenAdmin("localhost:0", nil, "", nil)
+	if e != nil {
+		s.Stop()
+		t.Fatal("Unable to listen for admin requests ", e)
+	}
+
+	rsp, e := http.Get("http://" + addr.String() + "/api/config/export")
+	if e != nil {
+		s.Stop()
+		t.Fatal("Unable to reach admin listener ", e)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting configs to be served. Status %v", rsp.Status)
+	}
+
+	s.Stop()
+	if _, e := http.Get("http://" + addr.String() + "/api/config/export"); e == nil {
+		t.Fatal("Admin listener should have been closed when the server stopped.")
+	}
+}
+
+func TestServeAdminConsoleTLS(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_admin_tls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := helpers.NewCert(t, "ca", nil, nil)
+	cert, key := helpers.NewCert(t, "server", ca, caKey)
+	tlsConfig := admin.TLSConfig{
+		CertFile: helpers.WritePEM(t, dir, "server.pem", "CERTIFICATE", cert.Raw),
+		KeyFile:  helpers.WriteKey(t, dir, "server.key", key)}
+
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s := New(config.NewDefaultServiceConfig(), &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	served := make(chan error, 1)
+	go func() {
+		served <- s.ServeAdminConsoleTLS(addr, tlsConfig, "", nil)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	var rsp *http.Response
+	for i := 0; i < 50; i++ {
+		if rsp, e = c.Get("https://" + addr + "/api/config/export"); e == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if e != nil {
+		s.Stop()
+		t.Fatal("Unable to reach admin console ", e)
+	}
+	rsp.Body.Close()
+
+	s.Stop()
+	select {
+	case e := <-served:
+		if e != http.ErrServerClosed {
+			t.Fatal("Expecting the admin console to be closed when the server stopped. Error ", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Admin console should have been closed when the server stopped.")
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	f, e := ioutil.TempFile("", "qs_test_server_cfg")
+	if e != nil {
+		t.Fatal("Unable to create config file ", e)
+	}
+	defer os.Remove(f.Name())
+
+	writeConfigFile(t, f.Name(), 10, 20)
+	bf := &MockBucketFactory{}
+	s := New(config.ReadConfigFromFile(f.Name()), bf, &MockEndpoint{}).(*server)
+	s.WatchConfigFile(f.Name(), 10*time.Millisecond)
+	s.Start()
+	defer s.Stop()
+
+	unchanged, _ := s.bucketContainer.FindBucket("ns", "unchanged")
+	changed, _ := s.bucketContainer.FindBucket("ns", "changed")
+
+	writeConfigFile(t, f.Name(), 10, 30)
+	deadline := time.Now().Add(time.Second)
+	for s.Configs().Version == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if s.Configs().Namespaces["ns"].Buckets["changed"].Size != 30 {
+		t.Fatal("Config file changes should have been applied.")
+	}
+
+	if b, _ := s.bucketContainer.FindBucket("ns", "unchanged"); b != unchanged {
+		t.Fatal("Bucket with unchanged config should have been carried over.")
+	}
+
+	if b, _ := s.bucketContainer.FindBucket("ns", "changed"); b == changed || b.Config().Size != 30 {
+		t.Fatal("Bucket with changed config should have been recreated.")
+	}
+}
+
+func writeConfigFile(t *testing.T, filename string, unchangedSize, changedSize int) {
+	yaml := fmt.Sprintf(`namespaces:
+  ns:
+    buckets:
+      unchanged:
+        size: %v
+      changed:
+        size: %v
+`, unchangedSize, changedSize)
+
+	// Write atomically, so the watcher never sees a partially written file.
+	tmp := filename + ".tmp"
+	if e := ioutil.WriteFile(tmp, []byte(yaml), 0644); e != nil {
+		t.Fatal("Unable to write config file ", e)
+	}
+
+	if e := os.Rename(tmp, filename); e != nil {
+		t.Fatal("Unable to write config file ", e)
+	}
+}
+
+// leasingBucket grants leases on tokens, remembering those that haven't been released.
+type leasingBucket struct {
+	MockBucket
+	sync.Mutex
+	leases map[string]bool
+}
+
+func (b *leasingBucket) Lease(numTokens int64, maxWaitTime time.Duration) (string, time.Duration, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	id := fmt.Sprintf("lease-%v", len(b.leases))
+	b.leases[id] = true
+	return id, 0, true
+}
+
+func (b *leasingBucket) Release(leaseID string) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	released := b.leases[leaseID]
+	delete(b.leases, leaseID)
+	return released
+}
+
+type leasingBucketFactory struct {
+	MockBucketFactory
+}
+
+func (bf *leasingBucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) Bucket {
+	if cfg.Algorithm != config.ConcurrencyAlgorithm {
+		return bf.MockBucketFactory.NewBucket(namespace, bucketName, cfg, dyn)
+	}
+
+	return &leasingBucket{MockBucket: MockBucket{cfg: cfg, dyn: dyn}, leases: make(map[string]bool)}
+}
+
+func TestLeases(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Algorithm = config.ConcurrencyAlgorithm
+	ns.AddBucket("concurrent", b)
+	ns.AddBucket("rate", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("ns", ns)
+	dyn := config.NewDefaultNamespaceConfig()
+	dyn.DynamicBucketTemplate = b.Clone()
+	cfg.AddNamespace("dyn", dyn)
+
+	s := New(cfg, &leasingBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	leaseID, _, e := qs.Lease(context.Background(), "ns", "concurrent", 1, 0, 0, PRIORITY_NORMAL, Caller{})
+	if e != nil || leaseID == "" {
+		t.Fatalf("Expecting a lease. Lease %q, error %v", leaseID, e)
+	}
+
+	if e := qs.Release("ns", "concurrent", leaseID); e != nil {
+		t.Fatal("Expecting the lease to be released ", e)
+	}
+
+	if e, ok := qs.Release("ns", "concurrent", leaseID).(QuotaServiceError); !ok || e.Reason != ER_NO_LEASE {
+		t.Fatalf("Expecting leases to only be released once. Error %v", e)
+	}
+
+	if _, e := qs.Allow("ns", "concurrent", 1, 0); e != nil {
+		t.Fatal("Expecting Allow to take tokens from concurrency buckets ", e)
+	}
+
+	if leaseID, _, e := qs.Lease(context.Background(), "ns", "rate", 1, 0, 0, PRIORITY_NORMAL, Caller{}); e != nil || leaseID != "" {
+		t.Fatalf("Expecting token buckets to grant tokens without leases. Lease %q, error %v", leaseID, e)
+	}
+
+	if e, ok := qs.Release("ns", "rate", "lease-0").(QuotaServiceError); !ok || e.Reason != ER_NO_LEASE {
+		t.Fatalf("Expecting token buckets to hold no leases. Error %v", e)
+	}
+
+	if e, ok := qs.Release("ns", "missing", "lease-0").(QuotaServiceError); !ok || e.Reason != ER_NO_BUCKET {
+		t.Fatalf("Expecting missing buckets to be reported. Error %v", e)
+	}
+
+	if e, ok := qs.Release("dyn", "missing", "lease-0").(QuotaServiceError); !ok || e.Reason != ER_NO_BUCKET {
+		t.Fatalf("Expecting missing dynamic buckets to be reported. Error %v", e)
+	}
+
+	if s.(*server).bucketContainer.liveBucket("dyn", "missing") != nil {
+		t.Fatal("Expecting releases not to create dynamic buckets")
+	}
+}
+
+func TestParents(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Ceiling = config.NewDefaultBucketConfig()
+	ns.Ceiling.Name = config.CeilingBucketName
+	tier := config.NewDefaultBucketConfig()
+	tier.Parent = config.CeilingBucketName
+	ns.AddBucket("tier", tier)
+	tpl := config.NewDefaultBucketConfig()
+	tpl.Parent = "tier"
+	ns.SetDynamicBucketTemplate(tpl)
+	cfg.AddNamespace("customers", ns)
+
+	bf := &MockBucketFactory{}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	if _, e := qs.Allow("customers", "c1", 1, 0); e != nil {
+		t.Fatal("Expecting tokens to be granted while every parent has them ", e)
+	}
+
+	bf.SetWaitTime("customers", "tier", 5*time.Millisecond)
+	if w, e := qs.Allow("customers", "c1", 1, 10); e != nil || w != 5*time.Millisecond {
+		t.Fatalf("Expecting to wait for the parent's tokens. Waited %v, error %v", w, e)
+	}
+
+	bf.SetWaitTime("customers", config.CeilingBucketName, time.Hour)
+	if _, e := qs.Allow("customers", "c2", 1, 10); e == nil || e.(QuotaServiceError).Reason != ER_TIMEOUT {
+		t.Fatalf("Expecting tokens to be refused once the ceiling has run out. Error %v", e)
+	}
+
+	if _, e := qs.Allow("customers", "tier", 1, 10); e == nil {
+		t.Fatal("Expecting parents to be capped by their own parents")
+	}
+
+	if e := s.(*server).DeleteBucket("customers", "tier", ""); e == nil {
+		t.Fatal("Expecting parents to be kept while other buckets name them")
+	}
+}
+
+func TestPooledNamespace(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Pooled = true
+	ns.Ceiling = config.NewDefaultBucketConfig()
+	ns.Ceiling.Name = config.CeilingBucketName
+	ns.AddBucket("named", config.NewDefaultBucketConfig())
+	capped := config.NewDefaultBucketConfig()
+	capped.Parent = config.CeilingBucketName
+	ns.AddBucket("capped", capped)
+	ns.SetDynamicBucketTemplate(config.NewDefaultBucketConfig())
+	cfg.AddNamespace("clients", ns)
+
+	bf := &MockBucketFactory{}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	if _, e := qs.Allow("clients", "c1", 1, 0); e != nil {
+		t.Fatal("Expecting tokens to be granted while the pool has them ", e)
+	}
+
+	bf.SetWaitTime("clients", config.CeilingBucketName, time.Hour)
+	for _, name := range []string{"named", "capped", "c1", "c2"} {
+		if _, e := qs.Allow("clients", name, 1, 10); e == nil || e.(QuotaServiceError).Reason != ER_TIMEOUT {
+			t.Fatalf("Expecting %v to be refused once the pool has run out. Error %v", name, e)
+		}
+	}
+
+	if _, e := qs.Allow("clients", config.CeilingBucketName, 1, 10); e == nil {
+		t.Fatal("Expecting the pool to still apply its own limit")
+	}
+}
+
+func TestGlobalRateCap(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	cfg.GlobalRateCap = 1000
+	for _, name := range []string{"a", "b"} {
+		ns := config.NewDefaultNamespaceConfig()
+		ns.SetDynamicBucketTemplate(config.NewDefaultBucketConfig())
+		cfg.AddNamespace(name, ns)
+	}
+
+	bf := &MockBucketFactory{}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	if _, e := qs.Allow("a", "x", 1, 0); e != nil {
+		t.Fatal("Expecting tokens to be granted while under the cap ", e)
+	}
+
+	bf.SetWaitTime(config.GlobalNamespace, config.RateCapBucketName, time.Hour)
+	for _, ns := range []string{"a", "b"} {
+		if _, e := qs.Allow(ns, "x", 1, 10); e == nil || e.(QuotaServiceError).Reason != ER_TIMEOUT {
+			t.Fatalf("Expecting namespace %v to be refused over the cap. Error %v", ns, e)
+		}
+	}
+
+	// Lifting the cap stops it applying.
+	uncapped := cfg.Clone()
+	uncapped.GlobalRateCap = 0
+	s.(*server).bucketContainer.replaceConfig(uncapped)
+	if _, e := qs.Allow("b", "x", 1, 10); e != nil {
+		t.Fatal("Expecting tokens to be granted once uncapped ", e)
+	}
+}
+
+func TestExhaustionCallbacks(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("n", ns)
+
+	bf := &MockBucketFactory{}
+	now := clock.NewFake(time.Unix(100, 0))
+	s := New(cfg, bf, &MockEndpoint{})
+	s.SetClock(now)
+	exhausted, recovered := make(chan Event, 10), make(chan Event, 10)
+	s.OnExhausted(func(e Event) { exhausted <- e })
+	s.OnRecovered(func(e Event) { recovered <- e })
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	expectCallback := func(c chan Event, eventType EventType) {
+		select {
+		case e := <-c:
+			if e.EventType() != eventType || e.Namespace() != "n" || e.BucketName() != "b" {
+				t.Fatalf("Expecting %v for n:b. Was %+v", eventType, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expecting %v", eventType)
+		}
+	}
+
+	expectNoCallbacks := func() {
+		time.Sleep(10 * time.Millisecond)
+		if len(exhausted) > 0 || len(recovered) > 0 {
+			t.Fatalf("Unexpected callbacks. Exhausted %v, recovered %v", len(exhausted), len(recovered))
+		}
+	}
+
+	bf.SetWaitTime("n", "b", time.Hour)
+	qs.Allow("n", "b", 1, 1)
+	expectCallback(exhausted, EVENT_BUCKET_EXHAUSTED)
+
+	// Only the first refusal exhausts the bucket.
+	qs.Allow("n", "b", 1, 1)
+	bf.SetWaitTime("n", "b", 0)
+	qs.Allow("n", "b", 1, 1)
+	expectNoCallbacks()
+
+	// Granting requests only recovers the bucket once it has refused none for a while.
+	now.Advance(exhaustionDebounce / 2)
+	qs.Allow("n", "b", 1, 1)
+	expectNoCallbacks()
+
+	now.Advance(exhaustionDebounce / 2)
+	qs.Allow("n", "b", 1, 1)
+	expectCallback(recovered, EVENT_BUCKET_RECOVERED)
+
+	qs.Allow("n", "b", 1, 1)
+	expectNoCallbacks()
+}
+
+// returningBucket counts the tokens put back into it.
+type returningBucket struct {
+	MockBucket
+	sync.Mutex
+	returned int64
+}
+
+func (b *returningBucket) ReturnTokens(numTokens int64) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.returned += numTokens
+}
+
+func (b *returningBucket) Returned() int64 {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.returned
+}
+
+// returningBucketFactory makes returningBuckets, apart from buckets called "plain".
+type returningBucketFactory struct {
+	MockBucketFactory
+	buckets map[string]*returningBucket
+}
+
+func (bf *returningBucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) Bucket {
+	if bucketName == "plain" {
+		return bf.MockBucketFactory.NewBucket(namespace, bucketName, cfg, dyn)
+	}
+
+	b := &returningBucket{MockBucket: MockBucket{cfg: cfg, dyn: dyn}}
+	bf.buckets[config.FullyQualifiedName(namespace, bucketName)] = b
+	return b
+}
+
+// debtLimitingBucket lends tokens for WaitTime, as long as that's within the max debt it's given.
+type debtLimitingBucket struct {
+	MockBucket
+	sync.Mutex
+	maxDebts []time.Duration
+}
+
+func (b *debtLimitingBucket) TakeWithMaxDebt(numTokens int64, maxWaitTime, maxDebt time.Duration) (time.Duration, bool) {
+	b.Lock()
+	b.maxDebts = append(b.maxDebts, maxDebt)
+	b.Unlock()
+
+	if b.WaitTime > maxDebt {
+		return 0, false
+	}
+
+	return b.Take(numTokens, maxWaitTime)
+}
+
+func (b *debtLimitingBucket) MaxDebts() []time.Duration {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.maxDebts
+}
+
+// debtLimitingBucketFactory makes debtLimitingBuckets.
+type debtLimitingBucketFactory struct {
+	MockBucketFactory
+	buckets map[string]*debtLimitingBucket
+}
+
+func (bf *debtLimitingBucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) Bucket {
+	b := &debtLimitingBucket{MockBucket: MockBucket{cfg: cfg, dyn: dyn}}
+	bf.buckets[config.FullyQualifiedName(namespace, bucketName)] = b
+	return b
+}
+
+func TestMaxDebtOverride(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Ceiling = config.NewDefaultBucketConfig()
+	ns.Ceiling.Name = config.CeilingBucketName
+	b := config.NewDefaultBucketConfig()
+	b.Parent = config.CeilingBucketName
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("ns", ns)
+
+	bf := &debtLimitingBucketFactory{buckets: make(map[string]*debtLimitingBucket)}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	leaf := bf.buckets[config.FullyQualifiedName("ns", "b")]
+	ceiling := bf.buckets[config.FullyQualifiedName("ns", config.CeilingBucketName)]
+	ceiling.WaitTime = 100 * time.Millisecond
+
+	if _, _, e := qs.Lease(context.Background(), "ns", "b", 1, 1000, 0, PRIORITY_NORMAL, Caller{}); e != nil || len(leaf.MaxDebts()) != 0 {
+		t.Fatalf("Expecting buckets to lend as much as they allow without an override. Error %v", e)
+	}
+
+	if _, _, e := qs.Lease(context.Background(), "ns", "b", 1, 1000, 50, PRIORITY_NORMAL, Caller{}); e == nil || e.(QuotaServiceError).Reason != ER_TIMEOUT {
+		t.Fatalf("Expecting requests needing more debt than the override to be refused. Error %v", e)
+	}
+
+	if d := ceiling.MaxDebts(); !reflect.DeepEqual(d, []time.Duration{50 * time.Millisecond}) {
+		t.Fatalf("Expecting the override to apply to parents. Was %v", d)
+	}
+
+	if _, _, e := qs.Lease(context.Background(), "ns", "b", 1, 1000, 200, PRIORITY_NORMAL, Caller{}); e != nil {
+		t.Fatalf("Expecting requests within the override to be granted. Error %v", e)
+	}
+
+	if d := leaf.MaxDebts(); !reflect.DeepEqual(d, []time.Duration{50 * time.Millisecond, 200 * time.Millisecond}) {
+		t.Fatalf("Expecting the override to apply to the bucket. Was %v", d)
+	}
+}
+
+func TestReservations(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Ceiling = config.NewDefaultBucketConfig()
+	ns.Ceiling.Name = config.CeilingBucketName
+	b := config.NewDefaultBucketConfig()
+	b.Parent = config.CeilingBucketName
+	ns.AddBucket("b", b)
+	ns.AddBucket("plain", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("ns", ns)
+
+	bf := &returningBucketFactory{buckets: make(map[string]*returningBucket)}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	leaf := bf.buckets[config.FullyQualifiedName("ns", "b")]
+	ceiling := bf.buckets[config.FullyQualifiedName("ns", config.CeilingBucketName)]
+
+	id, _, _, e := qs.Reserve(context.Background(), "ns", "b", "", 3, 0, 0, PRIORITY_NORMAL)
+	if e != nil || id == "" {
+		t.Fatalf("Expecting a reservation. Reservation %q, error %v", id, e)
+	}
+
+	if e := qs.CommitReservation(id); e != nil {
+		t.Fatal("Expecting the reservation to be committed ", e)
+	}
+
+	if leaf.Returned() != 0 || ceiling.Returned() != 0 {
+		t.Fatal("Expecting committed tokens to stay taken")
+	}
+
+	if e, ok := qs.CancelReservation(id).(QuotaServiceError); !ok || e.Reason != ER_NO_RESERVATION {
+		t.Fatalf("Expecting committed reservations to be gone. Error %v", e)
+	}
+
+	id, _, _, _ = qs.Reserve(context.Background(), "ns", "b", "", 3, 0, 0, PRIORITY_NORMAL)
+	if e := qs.CancelReservation(id); e != nil {
+		t.Fatal("Expecting the reservation to be cancelled ", e)
+	}
+
+	if leaf.Returned() != 3 || ceiling.Returned() != 3 {
+		t.Fatalf("Expecting cancelled tokens to be returned to the bucket and its parents. Returned %v and %v",
+			leaf.Returned(), ceiling.Returned())
+	}
+
+	if e, ok := qs.CommitReservation(id).(QuotaServiceError); !ok || e.Reason != ER_NO_RESERVATION {
+		t.Fatalf("Expecting cancelled reservations to be gone. Error %v", e)
+	}
+
+	id, _, _, _ = qs.Reserve(context.Background(), "ns", "b", "", 2, 0, time.Millisecond, PRIORITY_NORMAL)
+	for i := 0; leaf.Returned() != 5 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if leaf.Returned() != 5 {
+		t.Fatal("Expecting expired reservations to return their tokens")
+	}
+
+	if e, ok := qs.CommitReservation(id).(QuotaServiceError); !ok || e.Reason != ER_NO_RESERVATION {
+		t.Fatalf("Expecting expired reservations to be gone. Error %v", e)
+	}
+
+	if _, _, _, e := qs.Reserve(context.Background(), "ns", "plain", "", 1, 0, 0, PRIORITY_NORMAL); e == nil || e.(QuotaServiceError).Reason != ER_NOT_RESERVABLE {
+		t.Fatalf("Expecting buckets that can't take tokens back to refuse reservations. Error %v", e)
+	}
+}
+
+func TestLeaseRange(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Ceiling = config.NewDefaultBucketConfig()
+	ns.Ceiling.Name = config.CeilingBucketName
+	ns.Ceiling.Size = 50
+	b := config.NewDefaultBucketConfig()
+	b.Size = 100
+	b.Parent = config.CeilingBucketName
+	ns.AddBucket("b", b)
+	capped := config.NewDefaultBucketConfig()
+	capped.MaxTokensPerRequest = 5
+	ns.AddBucket("capped", capped)
+	cfg.AddNamespace("ns", ns)
+
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	if n, _, _, e := qs.LeaseRange(context.Background(), "ns", "b", 10, 500, 0, 0, PRIORITY_NORMAL, Caller{}); e != nil || n != 50 {
+		t.Fatalf("Expecting as many tokens as the bucket's parents hold. Granted %v, error %v", n, e)
+	}
+
+	if n, _, _, e := qs.LeaseRange(context.Background(), "ns", "b", 10, 20, 0, 0, PRIORITY_NORMAL, Caller{}); e != nil || n != 20 {
+		t.Fatalf("Expecting no more than the maximum. Granted %v, error %v", n, e)
+	}
+
+	if n, _, _, e := qs.LeaseRange(context.Background(), "ns", "b", 80, 500, 0, 0, PRIORITY_NORMAL, Caller{}); e != nil || n != 80 {
+		t.Fatalf("Expecting no fewer than the minimum. Granted %v, error %v", n, e)
+	}
+
+	if n, _, _, e := qs.LeaseRange(context.Background(), "ns", "capped", 1, 500, 0, 0, PRIORITY_NORMAL, Caller{}); e != nil || n != 5 {
+		t.Fatalf("Expecting no more than the maximum tokens per request. Granted %v, error %v", n, e)
+	}
+
+	if _, _, _, e := qs.LeaseRange(context.Background(), "ns", "capped", 10, 500, 0, 0, PRIORITY_NORMAL, Caller{}); e == nil || e.(QuotaServiceError).Reason != ER_TOO_MANY_TOKENS_REQUESTED {
+		t.Fatalf("Expecting minimums above the maximum tokens per request to be rejected. Error %v", e)
+	}
+
+	if _, _, _, e := qs.LeaseRange(context.Background(), "ns", "missing", 1, 500, 0, 0, PRIORITY_NORMAL, Caller{}); e == nil || e.(QuotaServiceError).Reason != ER_NO_BUCKET {
+		t.Fatalf("Expecting missing buckets to be reported. Error %v", e)
+	}
+}
+
+func TestMaxWaiters(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.MaxWaiters = 2
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("ns", ns)
+
+	bf := &MockBucketFactory{}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	if _, e := qs.Allow("ns", "b", 1, 0); e != nil {
+		t.Fatal("Expecting callers that don't wait to be allowed ", e)
+	}
+
+	bf.SetWaitTime("ns", "b", 50*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, e := qs.Allow("ns", "b", 1, 1000); e != nil {
+			t.Fatal("Expecting callers to wait for tokens ", e)
+		}
+	}
+
+	if _, e := qs.Allow("ns", "b", 1, 1000); e == nil || e.(QuotaServiceError).Reason != ER_TOO_MANY_WAITERS {
+		t.Fatalf("Expecting callers to be rejected once too many are waiting. Error %v", e)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, e := qs.Allow("ns", "b", 1, 1000); e != nil {
+		t.Fatal("Expecting callers to be allowed once others are done waiting ", e)
+	}
+}
+
+func TestPriorities(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 100
+	b.MaxTokensPerRequest = 100
+	b.HighPriorityReserve = 20
+	ns.AddBucket("shared", b)
+	cfg.AddNamespace("ns", ns)
+
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	if _, e := qs.Allow("ns", "shared", 80, 0); e != nil {
+		t.Fatal("Expecting normal priority requests to claim tokens that aren't held back ", e)
+	}
+
+	if _, e := qs.Allow("ns", "shared", 90, 0); e == nil || e.(QuotaServiceError).Reason != ER_TIMEOUT {
+		t.Fatalf("Expecting normal priority requests to be kept off tokens held back. Error %v", e)
+	}
+
+	if _, _, e := qs.Lease(context.Background(), "ns", "shared", 90, 0, 0, PRIORITY_HIGH, Caller{}); e != nil {
+		t.Fatal("Expecting high priority requests to claim tokens held back ", e)
+	}
+
+	if n, _, _, e := qs.LeaseRange(context.Background(), "ns", "shared", 1, 100, 0, 0, PRIORITY_NORMAL, Caller{}); e != nil || n != 80 {
+		t.Fatalf("Expecting ranges to leave out tokens held back. Granted %v, error %v", n, e)
+	}
+
+	if n, _, _, e := qs.LeaseRange(context.Background(), "ns", "shared", 1, 100, 0, 0, PRIORITY_HIGH, Caller{}); e != nil || n != 100 {
+		t.Fatalf("Expecting high priority ranges to include tokens held back. Granted %v, error %v", n, e)
+	}
+}
+
+func TestCost(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Costs = map[string]int64{"heavy_op": 5, "huge_op": math.MaxInt64 / 2}
+	ns.AddBucket("b", b)
+	capped := config.NewDefaultBucketConfig()
+	capped.Costs = map[string]int64{"heavy_op": 5}
+	capped.MaxTokensPerRequest = 10
+	ns.AddBucket("capped", capped)
+	cfg.AddNamespace("ns", ns)
+
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	for _, c := range []struct {
+		name, operation string
+		tokens, granted int64
+		reason          ErrorReason
+		refused         bool
+	}{
+		{"b", "heavy_op", 2, 10, 0, false},
+		{"b", "light_op", 2, 2, 0, false},
+		{"b", "", 2, 2, 0, false},
+		{"b", "huge_op", 3, 0, ER_TOO_MANY_TOKENS_REQUESTED, true},
+		{"capped", "heavy_op", 2, 10, 0, false},
+		{"capped", "heavy_op", 3, 0, ER_TOO_MANY_TOKENS_REQUESTED, true},
+		{"missing", "heavy_op", 1, 0, ER_NO_BUCKET, true}} {
+		grant, e := qs.Claim(context.Background(), "ns", c.name, c.operation, c.tokens, c.tokens, 0, 0, PRIORITY_NORMAL, Caller{})
+		if c.refused {
+			if e == nil || e.(QuotaServiceError).Reason != c.reason {
+				t.Fatalf("Expecting %v %v of %v to be refused with reason %v. Error %v", c.tokens, c.operation, c.name, c.reason, e)
+			}
+			continue
+		}
+
+		if e != nil || grant.Tokens != c.granted {
+			t.Fatalf("Expecting %v %v of %v to cost %v tokens. Grant %+v, error %v", c.tokens, c.operation, c.name, c.granted, grant, e)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 42
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("ns", ns)
+
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	if limit := qs.Limit("ns", "b"); limit != 42 {
+		t.Fatalf("Expecting a limit of 42. Was %v", limit)
+	}
+
+	if limit := qs.Limit("ns", "missing"); limit != 0 {
+		t.Fatalf("Expecting missing buckets to have no limit. Was %v", limit)
+	}
+}
+
+// adjustingBucket records the fill rates set on it.
+type adjustingBucket struct {
+	MockBucket
+	fillRates []int64
+}
+
+func (b *adjustingBucket) SetFillRate(fillRate int64) {
+	b.fillRates = append(b.fillRates, fillRate)
+}
+
+// adjustingBucketFactory makes adjustingBuckets, apart from buckets called "plain".
+type adjustingBucketFactory struct {
+	MockBucketFactory
+	buckets map[string]*adjustingBucket
+}
+
+func (bf *adjustingBucketFactory) NewBucket(namespace, bucketName string, cfg *config.BucketConfig, dyn bool) Bucket {
+	if bucketName == "plain" {
+		return bf.MockBucketFactory.NewBucket(namespace, bucketName, cfg, dyn)
+	}
+
+	b := &adjustingBucket{MockBucket: MockBucket{cfg: cfg, dyn: dyn}}
+	bf.buckets[config.FullyQualifiedName(namespace, bucketName)] = b
+	return b
+}
+
+func TestFeedback(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.FillRate = 100
+	b.Adaptive = &config.AdaptiveConfig{MaxErrorRate: 0.1, BackoffPercent: 50, Increase: 30, MinFillRate: 20}
+	ns.AddBucket("b", b)
+	ns.AddBucket("plain", config.NewDefaultBucketConfig())
+	ns.AddBucket("fixed", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("ns", ns)
+
+	bf := &adjustingBucketFactory{buckets: make(map[string]*adjustingBucket)}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	for _, c := range []struct {
+		errorRate float64
+		fillRate  int64
+	}{
+		{0.5, 50},
+		{0.5, 25},
+		{0.5, 20},
+		{0.5, 20},
+		{0, 50},
+		{0.05, 80},
+		{0, 100},
+		{0, 100}} {
+		if r, e := qs.Feedback("ns", "b", c.errorRate, 0); e != nil || r != c.fillRate {
+			t.Fatalf("Expecting an error rate of %v to bring the fill rate to %v. Was %v, error %v", c.errorRate, c.fillRate, r, e)
+		}
+	}
+
+	// Unchanged fill rates aren't set again.
+	expected := []int64{50, 25, 20, 50, 80, 100}
+	if rates := bf.buckets[config.FullyQualifiedName("ns", "b")].fillRates; !reflect.DeepEqual(rates, expected) {
+		t.Fatalf("Expecting fill rates %v to be set. Was %v", expected, rates)
+	}
+
+	for _, name := range []string{"plain", "fixed"} {
+		if _, e := qs.Feedback("ns", name, 0.5, 0); e == nil || e.(QuotaServiceError).Reason != ER_NOT_ADAPTIVE {
+			t.Fatalf("Expecting %v not to adapt. Error %v", name, e)
+		}
+	}
+
+	if _, e := qs.Feedback("ns", "missing", 0.5, 0); e == nil || e.(QuotaServiceError).Reason != ER_NO_BUCKET {
+		t.Fatalf("Expecting no such bucket. Error %v", e)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Ceiling = config.NewDefaultBucketConfig()
+	ns.Ceiling.Name = config.CeilingBucketName
+	ns.Ceiling.Size = 20
+	ns.Ceiling.FillRate = 5
+	b := config.NewDefaultBucketConfig()
+	b.Size = 100
+	b.FillRate = 10
+	b.Parent = config.CeilingBucketName
+	ns.AddBucket("b", b)
+	window := config.NewDefaultBucketConfig()
+	window.Algorithm = config.SlidingWindowAlgorithm
+	window.Size = 10
+	window.WindowMillis = 1000
+	ns.AddBucket("window", window)
+	leases := config.NewDefaultBucketConfig()
+	leases.Algorithm = config.ConcurrencyAlgorithm
+	leases.Size = 10
+	ns.AddBucket("leases", leases)
+	cfg.AddNamespace("ns", ns)
+
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	for _, c := range []struct {
+		name      string
+		tokens    int64
+		available int64
+		wait      time.Duration
+	}{
+		{"b", 5, 20, 0},
+		{"b", 30, 20, 2 * time.Second},
+		{"window", 15, 10, 500 * time.Millisecond},
+		{"leases", 15, 10, 0}} {
+		available, wait, e := qs.Query("ns", c.name, c.tokens)
+		if e != nil || available != c.available || wait != c.wait {
+			t.Fatalf("Expecting %v to have %v tokens available and project a wait of %v for %v. Had %v, waiting %v, error %v",
+				c.name, c.available, c.wait, c.tokens, available, wait, e)
+		}
+	}
+
+	if _, _, e := qs.Query("ns", "missing", 1); e == nil || e.(QuotaServiceError).Reason != ER_NO_BUCKET {
+		t.Fatalf("Expecting no such bucket. Error %v", e)
+	}
+}
+
+func TestQueryDoesntCreateBuckets(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+	ns.DynamicBucketTemplate.Size = 7
+	ns.MaxDynamicBuckets = 1
+	cfg.AddNamespace("ns", ns)
+
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+	a := s.(*server)
+	qs.Allow("ns", "live", 1, 0)
+
+	// Dynamic buckets that don't exist are reported as the template would create them.
+	if available, _, e := qs.Query("ns", "new", 1); e != nil || available != 7 {
+		t.Fatalf("Expecting a new dynamic bucket to be full. Available %v, error %v", available, e)
+	}
+
+	if limit := qs.Limit("ns", "new"); limit != 7 {
+		t.Fatalf("Expecting a new dynamic bucket's limit to be its template's size, got %v", limit)
+	}
+
+	if a.bucketContainer.liveBucket("ns", "new") != nil || a.bucketContainer.liveBucket("ns", "live") == nil {
+		t.Fatal("Expecting queries not to create or evict dynamic buckets.")
+	}
+
+	live := a.bucketContainer.liveBucket("ns", "live")
+	uses := atomic.LoadInt64(&live.uses)
+	if grant, e := qs.DryRun("ns", "new", "", 1, 5, 0, 0, PRIORITY_NORMAL, Caller{}); e != nil || grant.Tokens != 5 {
+		t.Fatalf("Expecting a dry run against a new dynamic bucket to be granted. Grant %+v, error %v", grant, e)
+	}
+	qs.DryRun("ns", "live", "", 1, 1, 0, 0, PRIORITY_NORMAL, Caller{})
+
+	if a.bucketContainer.liveBucket("ns", "new") != nil || atomic.LoadInt64(&live.uses) != uses {
+		t.Fatal("Expecting dry runs not to create buckets or count as their use.")
+	}
+}
+
+func TestClaimReportsRemainingTokens(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+	ns.DynamicBucketTemplate.Size = 7
+	ns.DynamicBucketTemplate.FillRate = 1
+	cfg.AddNamespace("ns", ns)
+
+	bf := &MockBucketFactory{}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	grant, e := qs.Claim(context.Background(), "ns", "b", "", 2, 2, 0, 0, PRIORITY_NORMAL, Caller{})
+	if e != nil || grant.Tokens != 2 || grant.Remaining != 7 || grant.RetryAfter != 0 {
+		t.Fatalf("Expecting tokens to be granted with the tokens remaining. Grant %+v, error %v", grant, e)
+	}
+
+	// Claims look their bucket up once.
+	b := s.(*server).bucketContainer.liveBucket("ns", "b")
+	uses := atomic.LoadInt64(&b.uses)
+	qs.Claim(context.Background(), "ns", "b", "", 1, 1, 0, 0, PRIORITY_NORMAL, Caller{})
+	if used := atomic.LoadInt64(&b.uses) - uses; used != 1 {
+		t.Fatalf("Expecting the claim to count as one use of its bucket, got %v", used)
+	}
+
+	bf.SetTokens("ns", "b", 0)
+	bf.SetWaitTime("ns", "b", time.Minute)
+	grant, e = qs.Claim(context.Background(), "ns", "b", "", 2, 2, 0, 0, PRIORITY_NORMAL, Caller{})
+	if qsErr, ok := e.(QuotaServiceError); !ok || qsErr.Reason != ER_TIMEOUT {
+		t.Fatalf("Expecting the claim to time out, got %v", e)
+	}
+
+	if grant.Tokens != 0 || grant.Remaining != 0 || grant.RetryAfter != 2*time.Second {
+		t.Fatalf("Expecting to be told to retry once 2 tokens are freed. Grant %+v", grant)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Ceiling = config.NewDefaultBucketConfig()
+	ns.Ceiling.Name = config.CeilingBucketName
+	ns.Ceiling.Size = 20
+	ns.Ceiling.FillRate = 5
+	b := config.NewDefaultBucketConfig()
+	b.Size = 100
+	b.FillRate = 10
+	b.Parent = config.CeilingBucketName
+	ns.AddBucket("b", b)
+	window := config.NewDefaultBucketConfig()
+	window.Algorithm = config.SlidingWindowAlgorithm
+	window.Size = 10
+	window.WindowMillis = 1000
+	ns.AddBucket("window", window)
+	leases := config.NewDefaultBucketConfig()
+	leases.Algorithm = config.ConcurrencyAlgorithm
+	leases.Size = 10
+	ns.AddBucket("leases", leases)
+	shadow := leases.Clone()
+	shadow.Mode = config.BucketModeShadow
+	ns.AddBucket("shadow", shadow)
+	denied := config.NewDefaultBucketConfig()
+	denied.Mode = config.BucketModeAlwaysDeny
+	ns.AddBucket("denied", denied)
+	cfg.AddNamespace("ns", ns)
+
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	for _, c := range []struct {
+		name             string
+		min, max         int64
+		maxWait, maxDebt int64
+		granted          int64
+		wait             time.Duration
+		reason           ErrorReason
+		refused          bool
+	}{
+		{"b", 5, 5, 0, 0, 5, 0, 0, false},
+		// Tokens the ceiling doesn't have are lent, without making the caller wait.
+		{"b", 30, 30, 0, 0, 30, 0, 0, false},
+		{"b", 30, 30, 0, 1000, 0, 0, ER_TIMEOUT, true},
+		{"b", 5, 50, 0, 0, 20, 0, 0, false},
+		{"window", 15, 15, 0, 0, 15, 500 * time.Millisecond, 0, false},
+		{"window", 15, 15, 100, 0, 0, 0, ER_TIMEOUT, true},
+		{"leases", 15, 15, 0, 0, 0, 0, ER_TIMEOUT, true},
+		{"shadow", 15, 15, 0, 0, 15, 0, 0, false},
+		{"denied", 1, 1, 0, 0, 0, 0, ER_DENIED, true},
+		{"missing", 1, 1, 0, 0, 0, 0, ER_NO_BUCKET, true}} {
+		maxWait := c.maxWait
+		if maxWait == 0 {
+			maxWait = -1
+		}
+
+		grant, e := qs.DryRun("ns", c.name, "", c.min, c.max, maxWait, c.maxDebt, PRIORITY_NORMAL, Caller{})
+		granted, wait := grant.Tokens, grant.Wait
+		if c.refused {
+			if e == nil || e.(QuotaServiceError).Reason != c.reason {
+				t.Fatalf("Expecting %v to refuse %v-%v tokens with reason %v. Error %v", c.name, c.min, c.max, c.reason, e)
+			}
+			continue
+		}
+
+		if e != nil || granted != c.granted || wait != c.wait {
+			t.Fatalf("Expecting %v to grant %v tokens of %v-%v, waiting %v. Granted %v, waiting %v, error %v",
+				c.name, c.granted, c.min, c.max, c.wait, granted, wait, e)
+		}
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	now := time.Now().UTC()
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	active := config.NewDefaultBucketConfig()
+	active.Schedule = []*config.ScheduleEntry{{
+		Start: now.Add(-time.Hour).Format("15:04"),
+		End:   now.Add(time.Hour).Format("15:04"),
+		Size:  active.Size * 2}}
+	ns.AddBucket("active", active)
+	inactive := config.NewDefaultBucketConfig()
+	inactive.Schedule = []*config.ScheduleEntry{{
+		Start: now.Add(6 * time.Hour).Format("15:04"),
+		End:   now.Add(7 * time.Hour).Format("15:04"),
+		Size:  inactive.Size * 2}}
+	ns.AddBucket("inactive", inactive)
+	cfg.AddNamespace("ns", ns)
+
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	bc := s.(*server).bucketContainer
+
+	b, _ := bc.FindBucket("ns", "active")
+	if b.Config().Size != active.Size*2 {
+		t.Fatalf("Expecting buckets to be created as scheduled. Size %v", b.Config().Size)
+	}
+
+	if b, _ := bc.FindBucket("ns", "inactive"); b.Config().Size != inactive.Size {
+		t.Fatalf("Expecting buckets outside their windows to be created as configured. Size %v", b.Config().Size)
+	}
+
+	bc.replaceConfig(cfg)
+	if carried, _ := bc.FindBucket("ns", "active"); carried != b {
+		t.Fatal("Expecting scheduled buckets to be carried over while their schedule hasn't changed")
+	}
+}
+
+func TestSchedulesCarryLevelsOver(t *testing.T) {
+	now := clock.NewFake(time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC))
+	window := []*config.ScheduleEntry{{Start: "13:00", End: "14:00", Size: 5}}
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	scheduled := config.NewDefaultBucketConfig()
+	scheduled.Schedule = window
+	ns.AddBucket("b", scheduled)
+	ns.DynamicBucketTemplate = config.NewDefaultBucketConfig()
+	ns.DynamicBucketTemplate.Schedule = window
+	cfg.AddNamespace("ns", ns)
+
+	bf := &MockBucketFactory{}
+	bc := newBucketContainer(cfg, bf, &MockEmitter{}, now)
+	bc.FindBucket("ns", "dyn")
+	bf.SetTokens("ns", "b", 3)
+	bf.SetTokens("ns", "dyn", 7)
+
+	now.Advance(90 * time.Minute)
+	bc.replaceConfig(cfg)
+	for name, tokens := range map[string]int64{"b": 3, "dyn": 7} {
+		b := bc.liveBucket("ns", name)
+		if b == nil || b.Config().Size != 5 {
+			t.Fatalf("Expecting %v to be recreated as its schedule has it. Was %+v", name, b)
+		}
+
+		if restored := b.Bucket.(*MockBucket).Restored; restored == nil || restored.Tokens != tokens {
+			t.Fatalf("Expecting %v to keep its level of %v tokens. Restored %+v", name, tokens, restored)
+		}
+	}
+}
+
+func TestBucketModes(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Ceiling = config.NewDefaultBucketConfig()
+	ns.Ceiling.Name = config.CeilingBucketName
+	b := config.NewDefaultBucketConfig()
+	b.Parent = config.CeilingBucketName
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("ns", ns)
+
+	bf := &MockBucketFactory{}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+	bc := s.(*server).bucketContainer
+
+	bf.SetWaitTime("ns", config.CeilingBucketName, time.Hour)
+	if _, e := qs.Allow("ns", "b", 1, 1000); e == nil || e.(QuotaServiceError).Reason != ER_TIMEOUT {
+		t.Fatalf("Expecting the ceiling to limit requests. Error %v", e)
+	}
+
+	ceiling := bc.namespaces["ns"].ceiling
+	cfg = cfg.Clone()
+	cfg.Namespaces["ns"].Ceiling.Mode = config.BucketModeAlwaysAllow
+	bc.replaceConfig(cfg)
+	if carried := bc.namespaces["ns"].ceiling; carried != ceiling {
+		t.Fatal("Expecting buckets to be carried over when only their mode changes")
+	}
+
+	if _, e := qs.Allow("ns", "b", 1, 1000); e != nil {
+		t.Fatalf("Expecting buckets set to allow all requests to be bypassed. Error %v", e)
+	}
+
+	if n, _, _ := qs.Query("ns", "b", 1); n != b.Size {
+		t.Fatalf("Expecting bypassed buckets not to count towards tokens available. Available %v", n)
+	}
+
+	cfg = cfg.Clone()
+	cfg.Namespaces["ns"].Buckets["b"].Mode = config.BucketModeAlwaysDeny
+	bc.replaceConfig(cfg)
+	if _, e := qs.Allow("ns", "b", 1, 1000); e == nil || e.(QuotaServiceError).Reason != ER_DENIED {
+		t.Fatalf("Expecting buckets set to deny all requests to refuse them. Error %v", e)
+	}
+
+	if status, _ := s.(*server).BucketStatus("ns", "b"); status == nil || status.Mode != config.BucketModeAlwaysDeny {
+		t.Fatalf("Expecting the bucket's mode to be reported. Status %+v", status)
+	}
+}
+
+func TestShadowMode(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	shadowed := config.NewDefaultBucketConfig()
+	shadowed.Mode = config.BucketModeShadow
+	ns.AddBucket("shadowed", shadowed)
+	ns.AddBucket("enforced", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("n", ns)
+	shadowNs := config.NewDefaultNamespaceConfig()
+	shadowNs.Shadow = true
+	shadowNs.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("shadow", shadowNs)
+
+	bf := &MockBucketFactory{}
+	s := New(cfg, bf, &MockEndpoint{})
+	events := make(chan Event, 100)
+	s.SetListener(func(e Event) { events <- e }, 100)
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	expectEvent := func(namespace, name string, eventType EventType) {
+		for {
+			select {
+			case e := <-events:
+				if e.EventType() == eventType && e.Namespace() == namespace && e.BucketName() == name {
+					return
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Expecting %v for %v:%v", eventType, namespace, name)
+			}
+		}
+	}
+
+	bf.SetWaitTime("n", "shadowed", time.Hour)
+	bf.SetWaitTime("n", "enforced", time.Hour)
+	bf.SetWaitTime("shadow", "b", time.Hour)
+	if _, e := qs.Allow("n", "shadowed", 1, 1000); e != nil {
+		t.Fatalf("Expecting buckets in shadow mode to grant requests they'd refuse. Error %v", e)
+	}
+	expectEvent("n", "shadowed", EVENT_SHADOW_REFUSED)
+
+	if _, e := qs.Allow("shadow", "b", 1, 1000); e != nil {
+		t.Fatalf("Expecting namespaces in shadow mode to put their buckets in shadow mode. Error %v", e)
+	}
+	expectEvent("shadow", "b", EVENT_SHADOW_REFUSED)
+
+	if _, e := qs.Allow("n", "enforced", 1, 1000); e == nil || e.(QuotaServiceError).Reason != ER_TIMEOUT {
+		t.Fatalf("Expecting other buckets to be enforced. Error %v", e)
+	}
+
+	bf.SetWaitTime("n", "shadowed", 500*time.Millisecond)
+	if w, e := qs.Allow("n", "shadowed", 1, 1000); e != nil || w != 0 {
+		t.Fatalf("Expecting buckets in shadow mode not to make callers wait. Waited %v, error %v", w, e)
+	}
+	expectEvent("n", "shadowed", EVENT_TOKENS_SERVED)
+}
+
+func TestEnforcementPercent(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	none := int64(0)
+	b.EnforcementPercent = &none
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("ns", ns)
+
+	bf := &MockBucketFactory{}
+	s := New(cfg, bf, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+	bc := s.(*server).bucketContainer
+
+	bf.SetWaitTime("ns", "b", time.Hour)
+	if _, _, e := qs.Lease(context.Background(), "ns", "b", 1, 1000, 0, PRIORITY_NORMAL, Caller{ID: "caller"}); e != nil {
+		t.Fatalf("Expecting refusals not to apply to callers that aren't enforced. Error %v", e)
+	}
+
+	bucket, _ := bc.FindBucket("ns", "b")
+	cfg = cfg.Clone()
+	all := int64(100)
+	cfg.Namespaces["ns"].Buckets["b"].EnforcementPercent = &all
+	bc.replaceConfig(cfg)
+	if carried, _ := bc.FindBucket("ns", "b"); carried != bucket {
+		t.Fatal("Expecting buckets to be carried over when only their enforcement percent changes")
+	}
+
+	if _, _, e := qs.Lease(context.Background(), "ns", "b", 1, 1000, 0, PRIORITY_NORMAL, Caller{ID: "caller"}); e == nil || e.(QuotaServiceError).Reason != ER_TIMEOUT {
+		t.Fatalf("Expecting refusals to apply to callers that are enforced. Error %v", e)
+	}
+}
+
+func TestReplaceConfigValidates(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.Start()
+	defer s.Stop()
+	a := s.(*server)
+
+	invalid := cfg.Clone()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.AddBucket("b", &config.BucketConfig{Size: -1})
+	invalid.AddNamespace("ns", ns)
+	if e := a.ReplaceConfig(invalid, cfg.Version, "user"); e == nil {
+		t.Fatal("Expecting invalid configs to be refused.")
+	} else if _, ok := e.(config.ValidationErrors); !ok {
+		t.Fatalf("Expecting validation errors. Was %v", e)
+	}
+
+	if a.Configs().Namespaces["ns"] != nil {
+		t.Fatal("Invalid config should not have been applied.")
+	}
+}
+
+func TestConfigChangesDatedByClock(t *testing.T) {
+	now := clock.NewFake(time.Unix(100, 0))
+	s := New(config.NewDefaultServiceConfig(), &MockBucketFactory{}, &MockEndpoint{})
+	s.SetClock(now)
+	s.Start()
+	defer s.Stop()
+	a := s.(*server)
+
+	ns := config.NewDefaultNamespaceConfig()
+	ns.Name = "ns"
+	if e := a.AddNamespace(ns.ToProto(), "user"); e != nil {
+		t.Fatal(e)
+	}
+
+	if date := a.Configs().Date; date != 100000 {
+		t.Fatalf("Expecting config changes to be dated by the server's clock. Date %v", date)
+	}
+}
+
+func TestRollbackConfigValidates(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_rollback")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	p, e := config.NewDiskConfigPersister(dir + "/cfg")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cfg := config.NewDefaultServiceConfig()
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	a := s.(*server)
+	a.p = p
+	s.Start()
+	defer s.Stop()
+
+	big := cfg.Clone()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.AddBucket("b", &config.BucketConfig{Size: 500})
+	big.AddNamespace("ns", ns)
+	if e := a.ReplaceConfig(big, cfg.Version, "user"); e != nil {
+		t.Fatal("Unable to replace config ", e)
+	}
+	bigVersion := a.Configs().Version
+
+	if e := a.ReplaceConfig(config.NewDefaultServiceConfig(), bigVersion, "user"); e != nil {
+		t.Fatal("Unable to replace config ", e)
+	}
+
+	defer config.SetLimits(config.BucketLimits{})
+	config.SetLimits(config.BucketLimits{MaxSize: 100})
+	if e := a.RollbackConfig(bigVersion, "user"); e == nil {
+		t.Fatal("Expecting rollback to a config exceeding the limits to be refused.")
+	} else if _, ok := e.(config.ValidationErrors); !ok {
+		t.Fatalf("Expecting validation errors. Was %v", e)
+	}
+
+	if a.Configs().Namespaces["ns"] != nil {
+		t.Fatal("Invalid config should not have been rolled back to.")
+	}
+
+	config.SetLimits(config.BucketLimits{})
+	if e := a.RollbackConfig(bigVersion, "user"); e != nil || a.Configs().Namespaces["ns"] == nil {
+		t.Fatal("Expecting rollback to a valid config. Error ", e)
+	}
+}
+
+// racedPersister refuses writes, as though another node had persisted a config first.
+type racedPersister struct {
+	config.ConfigPersister
+}
+
+func (p *racedPersister) PersistAndNotify(r io.Reader) error {
+	return config.VersionMismatchError{}
+}
+
+func TestLosingConfigChangeToAnotherNode(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_raced")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	disk, e := config.NewDiskConfigPersister(dir + "/cfg")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	theirs := config.NewDefaultServiceConfig()
+	theirs.Version = 5
+	theirs.AddNamespace("theirs", config.NewDefaultNamespaceConfig())
+	r, _ := config.Marshal(theirs)
+	if e = disk.PersistAndNotify(r); e != nil {
+		t.Fatal(e)
+	}
+
+	s := New(config.NewDefaultServiceConfig(), &MockBucketFactory{}, &MockEndpoint{})
+	a := s.(*server)
+	a.p = &racedPersister{disk}
+	events := make(chan Event, 100)
+	s.SetListener(func(e Event) { events <- e }, 100)
+	s.Start()
+	defer s.Stop()
+
+	mine := config.NewDefaultNamespaceConfig()
+	mine.Name = "mine"
+	e = a.AddNamespace(mine.ToProto(), "user")
+	if _, ok := e.(config.VersionMismatchError); !ok {
+		t.Fatalf("Expecting a version mismatch. Was %v", e)
+	}
+
+	if cfg := a.Configs(); cfg.Version != 5 || cfg.Namespaces["theirs"] == nil || cfg.Namespaces["mine"] != nil {
+		t.Fatalf("Expecting the other node's config to be applied. Was %+v", cfg)
+	}
+
+	// Listeners are only told of the version applied.
+	for {
+		select {
+		case e := <-events:
+			if e.EventType() != EVENT_CONFIG_CHANGED {
+				continue
+			}
+
+			if c := e.(ConfigChangedEvent); c.ConfigVersion() != 5 {
+				t.Fatalf("Expecting the losing version not to be announced. Event %+v", e)
+			}
+			return
+		case <-time.After(time.Second):
+			t.Fatalf("Expecting %v", EVENT_CONFIG_CHANGED)
+		}
+	}
+}
+
+func TestAdminConsolesSharePersister(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_test_admin_persister")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	first, _ := config.NewDiskConfigPersister(dir + "/first")
+	second, _ := config.NewDiskConfigPersister(dir + "/second")
+
+	s := New(config.NewDefaultServiceConfig(), &MockBucketFactory{}, &MockEndpoint{})
+	a := s.(*server)
+	s.Start()
+	s.ServeAdminConsole(http.NewServeMux(), "", first)
+	s.ServeAdminConsole(http.NewServeMux(), "", first)
+	s.ServeAdminConsole(http.NewServeMux(), "", second)
+	if a.p != first {
+		t.Fatal("Expecting the first persister to be kept.")
+	}
+	s.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// Nothing listens for changes once the server has stopped.
+	first.ConfigChangedWatcher() <- struct{}{}
+	time.Sleep(50 * time.Millisecond)
+	if len(first.ConfigChangedWatcher()) != 1 {
+		t.Fatal("Expecting the persister's changes not to be listened for after stopping.")
+	}
+}
@@ -0,0 +1,265 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/lifecycle"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+// BucketSnapshot is the level of a bucket when a Snapshot was taken.
+type BucketSnapshot struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Dynamic   bool   `json:"dynamic"`
+	// Tokens that could be taken straight away.
+	Tokens int64 `json:"tokens"`
+	// When tokens were last added to the bucket, in Unix millis.
+	LastFillMillis int64 `json:"last_fill_millis"`
+	// How far into the future tokens had already been claimed, in millis.
+	DebtMillis int64 `json:"debt_millis"`
+}
+
+// Snapshot is the level of every live bucket kept in memory at a point in time. Buckets kept in
+// shared backends such as Redis outlive servers anyway, so they aren't included.
+type Snapshot struct {
+	// When the snapshot was taken, in Unix millis.
+	TakenMillis int64             `json:"taken_millis"`
+	Buckets     []*BucketSnapshot `json:"buckets"`
+}
+
+// SnapshotStore persists the snapshots servers take of their buckets.
+type SnapshotStore interface {
+	// SaveSnapshot persists the snapshot, replacing any saved before it.
+	SaveSnapshot(s *Snapshot) error
+	// LatestSnapshot returns the snapshot saved last, or nil if none has been.
+	LatestSnapshot() (*Snapshot, error)
+}
+
+// DiskSnapshotStore is a SnapshotStore that saves snapshots to a file, as JSON.
+type DiskSnapshotStore struct {
+	location string
+}
+
+// NewDiskSnapshotStore creates a DiskSnapshotStore saving snapshots to the file at location.
+func NewDiskSnapshotStore(location string) *DiskSnapshotStore {
+	return &DiskSnapshotStore{location}
+}
+
+// SaveSnapshot writes the snapshot to a temporary file, renaming it over the last snapshot once
+// it's complete, so that a crash mid-write doesn't lose the last snapshot.
+func (d *DiskSnapshotStore) SaveSnapshot(s *Snapshot) error {
+	b, e := json.Marshal(s)
+	if e != nil {
+		return e
+	}
+
+	f, e := ioutil.TempFile(filepath.Dir(d.location), filepath.Base(d.location)+".tmp")
+	if e != nil {
+		return e
+	}
+
+	if _, e = f.Write(b); e == nil {
+		e = f.Close()
+	} else {
+		f.Close()
+	}
+
+	if e == nil {
+		e = os.Rename(f.Name(), d.location)
+	}
+
+	if e != nil {
+		os.Remove(f.Name())
+	}
+	return e
+}
+
+// LatestSnapshot reads the last snapshot saved to the file, returning nil if there's no file.
+func (d *DiskSnapshotStore) LatestSnapshot() (*Snapshot, error) {
+	b, e := ioutil.ReadFile(d.location)
+	if os.IsNotExist(e) {
+		return nil, nil
+	} else if e != nil {
+		return nil, e
+	}
+
+	s := &Snapshot{}
+	if e := json.Unmarshal(b, s); e != nil {
+		return nil, e
+	}
+	return s, nil
+}
+
+// snapshot returns the levels of the container's live buckets kept in memory, sorted by namespace
+// and name.
+func (bc *bucketContainer) snapshot(now time.Time) *Snapshot {
+	s := &Snapshot{TakenMillis: now.UnixNano() / 1e6, Buckets: make([]*BucketSnapshot, 0)}
+	add := func(namespace, name string, b *expirableBucket) {
+		if b == nil || b.Config().Backend() != config.BackendMemory {
+			return
+		}
+
+		if sr, ok := b.Bucket.(StatusReporter); ok {
+			status := sr.Status()
+			s.Buckets = append(s.Buckets, &BucketSnapshot{
+				Namespace:      namespace,
+				Name:           name,
+				Dynamic:        b.Dynamic(),
+				Tokens:         status.Tokens,
+				LastFillMillis: status.LastFillMillis,
+				DebtMillis:     status.DebtMillis})
+		}
+	}
+
+	bc.RLock()
+	defer bc.RUnlock()
+
+	for name, ns := range bc.namespaces {
+		ns.RLock()
+		add(name, config.DefaultBucketName, ns.defaultBucket)
+		add(name, config.CeilingBucketName, ns.ceiling)
+		for bucketName, b := range ns.buckets {
+			add(name, bucketName, b)
+		}
+		ns.RUnlock()
+	}
+
+	sort.Sort(bucketSnapshotsByName(s.Buckets))
+	return s
+}
+
+// restore sets the container's buckets to the levels the snapshot recorded, creating the dynamic
+// buckets it includes, and returns the number of buckets restored. Buckets that no longer exist,
+// moved to other backends or can't be restored are left as they are.
+func (bc *bucketContainer) restore(s *Snapshot) (restored int) {
+	at := time.Unix(0, s.TakenMillis*1e6)
+	for _, b := range s.Buckets {
+		bucket := bc.snapshotted(b)
+		if bucket == nil || bucket.Config().Backend() != config.BackendMemory {
+			continue
+		}
+
+		if r, ok := bucket.Bucket.(StateRestorer); ok {
+			r.RestoreState(b, at)
+			restored++
+		}
+	}
+
+	return
+}
+
+// snapshotted returns the live bucket the snapshot was taken of, creating it if it's dynamic, or
+// nil if there's no such bucket.
+func (bc *bucketContainer) snapshotted(b *BucketSnapshot) *expirableBucket {
+	bc.RLock()
+	ns := bc.namespaces[b.Namespace]
+	bc.RUnlock()
+	if ns == nil {
+		return nil
+	}
+
+	switch {
+	case b.Name == config.DefaultBucketName:
+		return ns.defaultBucket
+	case b.Name == config.CeilingBucketName:
+		return ns.ceiling
+	case b.Dynamic:
+		if bucket, _ := bc.FindBucket(b.Namespace, b.Name); bucket != nil && bucket.Dynamic() {
+			return bucket
+		}
+		return nil
+	}
+
+	ns.RLock()
+	defer ns.RUnlock()
+	return ns.buckets[b.Name]
+}
+
+type bucketSnapshotsByName []*BucketSnapshot
+
+func (b bucketSnapshotsByName) Len() int      { return len(b) }
+func (b bucketSnapshotsByName) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b bucketSnapshotsByName) Less(i, j int) bool {
+	if b[i].Namespace != b[j].Namespace {
+		return b[i].Namespace < b[j].Namespace
+	}
+	return b[i].Name < b[j].Name
+}
+
+func (s *server) SnapshotBuckets(store SnapshotStore, interval time.Duration) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot snapshot buckets after server has started!")
+	}
+
+	if interval <= 0 {
+		panic("Snapshot interval must be greater than 0")
+	}
+
+	s.snapshotStore = store
+	s.snapshotInterval = interval
+}
+
+func (s *server) RestoreBuckets(store SnapshotStore, maxAge time.Duration) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot restore buckets after server has started!")
+	}
+
+	s.restoreStore = store
+	s.restoreMaxAge = maxAge
+}
+
+// restoreBuckets restores the buckets from the latest snapshot, unless it's older than the max age.
+func (s *server) restoreBuckets() {
+	snapshot, e := s.restoreStore.LatestSnapshot()
+	if e != nil {
+		logging.Warnf("Unable to read snapshot to restore buckets from: %v", e)
+		return
+	}
+
+	if snapshot == nil {
+		return
+	}
+
+	age := s.clock.Now().Sub(time.Unix(0, snapshot.TakenMillis*1e6))
+	if age > s.restoreMaxAge {
+		logging.Printf("Not restoring buckets from snapshot taken %v ago, older than %v", age, s.restoreMaxAge)
+		return
+	}
+
+	restored := s.bucketContainer.restore(snapshot)
+	logging.Printf("Restored %v of %v buckets from snapshot taken %v ago", restored, len(snapshot.Buckets), age)
+}
+
+// snapshotBuckets saves a snapshot of the buckets whenever t ticks, until stop is closed, closing
+// done once it has stopped.
+func (s *server) snapshotBuckets(t clock.Ticker, stop, done chan struct{}) {
+	defer close(done)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C():
+			s.saveSnapshot()
+		}
+	}
+}
+
+func (s *server) saveSnapshot() {
+	snapshot := s.bucketContainer.snapshot(s.clock.Now())
+	if e := s.snapshotStore.SaveSnapshot(snapshot); e != nil {
+		logging.Warnf("Unable to save snapshot of %v buckets: %v", len(snapshot.Buckets), e)
+	}
+}
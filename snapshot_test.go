@@ -0,0 +1,256 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/test/helpers"
+)
+
+type memorySnapshotStore struct {
+	sync.Mutex
+	snapshots []*Snapshot
+}
+
+func (m *memorySnapshotStore) SaveSnapshot(s *Snapshot) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.snapshots = append(m.snapshots, s)
+	return nil
+}
+
+func (m *memorySnapshotStore) LatestSnapshot() (*Snapshot, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if len(m.snapshots) == 0 {
+		return nil, nil
+	}
+	return m.snapshots[len(m.snapshots)-1], nil
+}
+
+// waitForSnapshots waits for the store to have n snapshots, returning the latest.
+func (m *memorySnapshotStore) waitForSnapshots(t *testing.T, n int) *Snapshot {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		m.Lock()
+		if len(m.snapshots) >= n {
+			defer m.Unlock()
+			return m.snapshots[len(m.snapshots)-1]
+		}
+		m.Unlock()
+	}
+
+	t.Fatalf("Expecting %v snapshots", n)
+	return nil
+}
+
+func TestSnapshotBuckets(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Size = 7
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("ns", ns)
+
+	shared := config.NewDefaultNamespaceConfig()
+	shared.Backend = config.BackendRedis
+	shared.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("shared", shared)
+
+	now := clock.NewFake(time.Unix(100, 0))
+	store := &memorySnapshotStore{}
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.SetClock(now)
+	s.SnapshotBuckets(store, time.Minute)
+	s.Start()
+
+	now.Advance(time.Minute)
+	snapshot := store.waitForSnapshots(t, 1)
+	expected := &Snapshot{
+		TakenMillis: 160000,
+		Buckets:     []*BucketSnapshot{{Namespace: "ns", Name: "b", Tokens: 7}}}
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Fatalf("Expecting only buckets kept in memory to be snapshotted. Snapshot %+v", snapshot)
+	}
+
+	s.Stop()
+	store.waitForSnapshots(t, 2)
+
+	helpers.ExpectingPanic(t, func() {
+		s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+		s.Start()
+		defer s.Stop()
+		s.SnapshotBuckets(store, time.Minute)
+	})
+}
+
+// blockingSnapshotStore holds up saving its first snapshot until unblock is closed.
+type blockingSnapshotStore struct {
+	memorySnapshotStore
+	once            sync.Once
+	saving, unblock chan struct{}
+}
+
+func (b *blockingSnapshotStore) SaveSnapshot(s *Snapshot) error {
+	b.once.Do(func() {
+		close(b.saving)
+		<-b.unblock
+	})
+
+	return b.memorySnapshotStore.SaveSnapshot(s)
+}
+
+func TestStopSavesSnapshotLast(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("ns", ns)
+
+	now := clock.NewFake(time.Unix(100, 0))
+	store := &blockingSnapshotStore{saving: make(chan struct{}), unblock: make(chan struct{})}
+	s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+	s.SetClock(now)
+	s.SnapshotBuckets(store, time.Minute)
+	s.Start()
+
+	now.Advance(time.Minute)
+	<-store.saving
+	now.Advance(time.Minute)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Expecting Stop to wait for the snapshot underway")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(store.unblock)
+	<-stopped
+	if latest := store.waitForSnapshots(t, 2); latest.TakenMillis != 220000 {
+		t.Fatalf("Expecting the final snapshot to be saved last. Latest %+v", latest)
+	}
+}
+
+func TestDiskSnapshotStore(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_snapshots")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	location := filepath.Join(dir, "snapshot.json")
+	store := NewDiskSnapshotStore(location)
+	for _, tokens := range []int64{1, 2} {
+		snapshot := &Snapshot{TakenMillis: tokens, Buckets: []*BucketSnapshot{{Namespace: "ns", Name: "b", Tokens: tokens}}}
+		if e := store.SaveSnapshot(snapshot); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	b, e := ioutil.ReadFile(location)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	saved := &Snapshot{}
+	if e := json.Unmarshal(b, saved); e != nil || saved.TakenMillis != 2 || saved.Buckets[0].Tokens != 2 {
+		t.Fatalf("Expecting the latest snapshot to be saved. Saved %s", b)
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("Expecting temporary files to be cleaned up. Found %v files", len(files))
+	}
+}
+
+func TestRestoreBuckets(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	ns.AddBucket("b", config.NewDefaultBucketConfig())
+	ns.SetDynamicBucketTemplate(config.NewDefaultBucketConfig())
+	cfg.AddNamespace("ns", ns)
+
+	shared := config.NewDefaultNamespaceConfig()
+	shared.Backend = config.BackendRedis
+	shared.AddBucket("b", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("shared", shared)
+
+	store := &memorySnapshotStore{}
+	store.SaveSnapshot(&Snapshot{
+		TakenMillis: 70000,
+		Buckets: []*BucketSnapshot{
+			{Namespace: "ns", Name: "b", Tokens: 3},
+			{Namespace: "ns", Name: "d", Dynamic: true, Tokens: 4},
+			{Namespace: "ns", Name: "gone", Tokens: 5},
+			{Namespace: "shared", Name: "b", Tokens: 6}}})
+
+	restore := func(maxAge time.Duration) *MockBucketFactory {
+		bf := &MockBucketFactory{}
+		s := New(cfg, bf, &MockEndpoint{})
+		s.SetClock(clock.NewFake(time.Unix(100, 0)))
+		s.RestoreBuckets(store, maxAge)
+		s.Start()
+		s.Stop()
+		return bf
+	}
+
+	bf := restore(time.Minute)
+	if r := bf.bucket("ns", "b").Restored; r == nil || r.Tokens != 3 {
+		t.Fatalf("Expecting bucket to be restored. Restored %+v", r)
+	}
+
+	if r := bf.bucket("ns", "d").Restored; r == nil || r.Tokens != 4 {
+		t.Fatalf("Expecting dynamic bucket to be recreated and restored. Restored %+v", r)
+	}
+
+	if r := bf.bucket("shared", "b").Restored; r != nil {
+		t.Fatalf("Expecting buckets not kept in memory to be left alone. Restored %+v", r)
+	}
+
+	bf = restore(10 * time.Second)
+	if r := bf.bucket("ns", "b").Restored; r != nil {
+		t.Fatalf("Expecting stale snapshots to be ignored. Restored %+v", r)
+	}
+
+	helpers.ExpectingPanic(t, func() {
+		s := New(cfg, &MockBucketFactory{}, &MockEndpoint{})
+		s.Start()
+		defer s.Stop()
+		s.RestoreBuckets(store, time.Minute)
+	})
+}
+
+func TestDiskSnapshotStoreLatest(t *testing.T) {
+	dir, e := ioutil.TempDir("", "qs_snapshots")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewDiskSnapshotStore(filepath.Join(dir, "snapshot.json"))
+	if s, e := store.LatestSnapshot(); s != nil || e != nil {
+		t.Fatalf("Expecting no snapshot before one is saved. Got %+v, %v", s, e)
+	}
+
+	saved := &Snapshot{TakenMillis: 1, Buckets: []*BucketSnapshot{{Namespace: "ns", Name: "b", Tokens: 2}}}
+	store.SaveSnapshot(saved)
+	if s, e := store.LatestSnapshot(); e != nil || !reflect.DeepEqual(s, saved) {
+		t.Fatalf("Expecting the saved snapshot. Got %+v, %v", s, e)
+	}
+}
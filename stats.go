@@ -0,0 +1,142 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+// usageSlots is how many slots each of usageStats' rings has: a minute of seconds, and an hour of
+// minutes.
+const usageSlots = 60
+
+// usageSlot counts the requests a bucket served during one second or minute.
+type usageSlot struct {
+	// Unix second or minute counted, so that slots left over from an earlier lap of the ring are
+	// told apart.
+	at     int64
+	counts admin.UsageCounts
+}
+
+// usageStats counts the requests a bucket serves over rolling windows: per second for the last
+// minute, and per minute for the last 5 minutes and hour, so windows are accurate to their
+// slots, without the stats taking more room the more requests are served.
+type usageStats struct {
+	sync.Mutex
+	seconds [usageSlots]usageSlot
+	minutes [usageSlots]usageSlot
+}
+
+// record counts a request for tokens, granted unless it failed with e.
+func (u *usageStats) record(now time.Time, tokens int64, e error) {
+	u.Lock()
+	defer u.Unlock()
+
+	for _, s := range []*usageSlot{slotAt(&u.seconds, now.Unix()), slotAt(&u.minutes, now.Unix()/60)} {
+		if e == nil {
+			s.counts.Grants++
+			s.counts.TokensServed += tokens
+			continue
+		}
+
+		s.counts.Denials++
+		if qsErr, ok := e.(QuotaServiceError); ok && qsErr.Reason == ER_TIMEOUT {
+			s.counts.Timeouts++
+		}
+	}
+}
+
+// slotAt returns the ring's slot for at, clearing it of counts from an earlier lap.
+func slotAt(ring *[usageSlots]usageSlot, at int64) *usageSlot {
+	s := &ring[at%usageSlots]
+	if s.at != at {
+		*s = usageSlot{at: at}
+	}
+
+	return s
+}
+
+// usage returns the counts over the last minute, 5 minutes and hour.
+func (u *usageStats) usage(now time.Time) (minute, fiveMinutes, hour admin.UsageCounts) {
+	u.Lock()
+	defer u.Unlock()
+
+	second := now.Unix()
+	for _, s := range u.seconds {
+		if second-s.at < usageSlots {
+			addCounts(&minute, s.counts)
+		}
+	}
+
+	for _, s := range u.minutes {
+		if age := second/60 - s.at; age < 5 {
+			addCounts(&fiveMinutes, s.counts)
+			addCounts(&hour, s.counts)
+		} else if age < usageSlots {
+			addCounts(&hour, s.counts)
+		}
+	}
+
+	return
+}
+
+func addCounts(total *admin.UsageCounts, c admin.UsageCounts) {
+	total.Grants += c.Grants
+	total.TokensServed += c.TokensServed
+	total.Denials += c.Denials
+	total.Timeouts += c.Timeouts
+}
+
+func (s *server) BucketUsage(namespace, name string) (*admin.BucketUsage, error) {
+	b := s.bucketContainer.liveBucket(namespace, name)
+	if b == nil {
+		return nil, config.NotFoundError{Message: "No live bucket " + config.FullyQualifiedName(namespace, name)}
+	}
+
+	u := &admin.BucketUsage{Namespace: namespace, Name: name, Dynamic: b.Dynamic()}
+	u.OneMinute, u.FiveMinutes, u.OneHour = b.usage.usage(s.clock.Now())
+	return u, nil
+}
+
+func (s *server) AllBucketUsage() []*admin.BucketUsage {
+	type live struct {
+		namespace, name string
+		b               *expirableBucket
+	}
+
+	var buckets []live
+	add := func(namespace, name string, b *expirableBucket) {
+		if b != nil {
+			buckets = append(buckets, live{namespace, name, b})
+		}
+	}
+
+	bc := s.bucketContainer
+	bc.RLock()
+	add(config.GlobalNamespace, config.DefaultBucketName, bc.defaultBucket)
+	for name, ns := range bc.namespaces {
+		ns.RLock()
+		add(name, config.DefaultBucketName, ns.defaultBucket)
+		add(name, config.CeilingBucketName, ns.ceiling)
+		for bucketName, b := range ns.buckets {
+			add(name, bucketName, b)
+		}
+		ns.RUnlock()
+	}
+	bc.RUnlock()
+
+	now := s.clock.Now()
+	usage := make([]*admin.BucketUsage, len(buckets))
+	for i, l := range buckets {
+		u := &admin.BucketUsage{Namespace: l.namespace, Name: l.name, Dynamic: l.b.Dynamic()}
+		u.OneMinute, u.FiveMinutes, u.OneHour = l.b.usage.usage(now)
+		usage[i] = u
+	}
+
+	return usage
+}
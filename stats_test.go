@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/admin"
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func TestBucketUsage(t *testing.T) {
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.MaxTokensPerRequest = 10
+	ns.AddBucket("b", b)
+	cfg.AddNamespace("n", ns)
+
+	bf := &MockBucketFactory{}
+	c := clock.NewFake(time.Unix(1500000000, 0))
+	s := New(cfg, bf, &MockEndpoint{})
+	s.SetClock(c)
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	usage := func() *admin.BucketUsage {
+		u, e := s.(*server).BucketUsage("n", "b")
+		if e != nil {
+			t.Fatal(e)
+		}
+		return u
+	}
+
+	// An hour ago, over 5 minutes ago and 2 minutes ago.
+	qs.Allow("n", "b", 1, 0)
+	c.Advance(50 * time.Minute)
+	qs.Allow("n", "b", 2, 0)
+	c.Advance(8 * time.Minute)
+	qs.Allow("n", "b", 3, 0)
+	qs.Allow("n", "b", 100, 0)
+	c.Advance(2 * time.Minute)
+
+	// Just now.
+	qs.Allow("n", "b", 4, 0)
+	bf.SetWaitTime("n", "b", time.Hour)
+	qs.Allow("n", "b", 1, 0)
+
+	u := usage()
+	expected := admin.BucketUsage{
+		Namespace:   "n",
+		Name:        "b",
+		OneMinute:   admin.UsageCounts{Grants: 1, TokensServed: 4, Denials: 1, Timeouts: 1},
+		FiveMinutes: admin.UsageCounts{Grants: 2, TokensServed: 7, Denials: 2, Timeouts: 1},
+		OneHour:     admin.UsageCounts{Grants: 3, TokensServed: 9, Denials: 2, Timeouts: 1}}
+	if *u != expected {
+		t.Fatalf("Expecting usage %+v, got %+v", expected, *u)
+	}
+
+	c.Advance(90 * time.Minute)
+	if u = usage(); u.OneHour != (admin.UsageCounts{}) {
+		t.Fatalf("Expecting counts to have rolled out of the window, got %+v", u.OneHour)
+	}
+
+	if _, e := s.(*server).BucketUsage("n", "missing"); e == nil {
+		t.Fatal("Expecting no usage of a missing bucket")
+	}
+}
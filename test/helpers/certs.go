@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// NewCert creates a certificate for name, signed by parent, or self-signed if parent is nil.
+func NewCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")}}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+
+	der, e := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cert, e := x509.ParseCertificate(der)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return cert, key
+}
+
+// WriteKey writes key to a PEM file called name in dir, and returns the file's path.
+func WriteKey(t *testing.T, dir, name string, key *ecdsa.PrivateKey) string {
+	der, e := x509.MarshalECPrivateKey(key)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return WritePEM(t, dir, name, "EC PRIVATE KEY", der)
+}
+
+// WritePEM writes der as a PEM block of blockType to a file called name in dir, and returns the
+// file's path.
+func WritePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	filename := filepath.Join(dir, name)
+	if e := ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); e != nil {
+		t.Fatal(e)
+	}
+
+	return filename
+}
@@ -39,3 +39,45 @@ func BenchmarkQuotaRequests(b *testing.B) {
 			}
 		})
 }
+
+func BenchmarkStreamedQuotaRequests(b *testing.B) {
+	serverAddr := "127.0.0.1:10990"
+	conn, err := grpc.Dial(serverAddr, grpc.WithInsecure())
+	if err != nil {
+		grpclog.Fatalf("fail to dial: %v", err)
+	}
+	defer conn.Close()
+
+	client := pb.NewQuotaServiceClient(conn)
+
+	req := &pb.AllowRequest{
+		Namespace:       "test.namespace",
+		BucketName:      "one",
+		TokensRequested: 1}
+	b.ResetTimer()
+	b.SetParallelism(8)
+	b.RunParallel(
+		func(p *testing.PB) {
+			stream, err := client.AllowStream(context.TODO())
+			if err != nil {
+				grpclog.Fatalf("fail to open stream: %v", err)
+			}
+
+			// Requests are pipelined, with responses read as they arrive.
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					if _, err := stream.Recv(); err != nil {
+						return
+					}
+				}
+			}()
+
+			for p.Next() {
+				stream.Send(req)
+			}
+			stream.CloseSend()
+			<-done
+		})
+}
@@ -4,7 +4,6 @@
 package main
 
 import (
-	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -13,7 +12,7 @@ import (
 	"github.com/maniksurtani/quotaservice"
 	"github.com/maniksurtani/quotaservice/buckets/memory"
 	"github.com/maniksurtani/quotaservice/config"
-	"github.com/maniksurtani/quotaservice/rpc/grpc"
+	"github.com/maniksurtani/quotaservice/rpc/endpoints"
 )
 
 func main() {
@@ -30,18 +29,26 @@ func main() {
 	ns.AddBucket("xyz", config.NewDefaultBucketConfig())
 	cfg.AddNamespace("test.namespace2", ns)
 
-	server := quotaservice.New(cfg, memory.NewBucketFactory(), grpc.New("localhost:10990"))
+	rpcEndpoints, e := endpoints.New(
+		endpoints.Config{Type: endpoints.TypeGRPC, Hostport: "localhost:10990"},
+		endpoints.Config{Type: endpoints.TypeEnvoy, Hostport: "localhost:8081"})
+	if e != nil {
+		panic(e)
+	}
+
+	server := quotaservice.New(cfg, memory.NewBucketFactory(), rpcEndpoints...)
 	server.Start()
 
 	// Serve Admin Console
-	sm := http.NewServeMux()
 	p, _ := config.NewDiskConfigPersister("/tmp/qscfgs.dat")
-	server.ServeAdminConsole(sm, "", p)
-	http.ListenAndServe("localhost:8080", sm)
+	if _, e := server.ListenAdmin("localhost:8080", nil, "", p); e != nil {
+		panic(e)
+	}
 
 	// Block until SIGTERM, SIGKILL or SIGINT
 	sigs := make(chan os.Signal, 1)
 	var shutdown sync.WaitGroup
+	shutdown.Add(1)
 	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGKILL, syscall.SIGINT)
 
 	go func() {
@@ -5,6 +5,7 @@ package quotaservice
 
 import (
 	"fmt"
+	"github.com/maniksurtani/quotaservice/admin"
 	"github.com/maniksurtani/quotaservice/config"
 	"sync"
 	"time"
@@ -16,6 +17,9 @@ type MockBucket struct {
 	namespace, bucketName string
 	dyn                   bool
 	cfg                   *config.BucketConfig
+	Restored              *BucketSnapshot
+	// Tokens reported by Status, if set. Otherwise the bucket reports itself full.
+	Tokens *int64
 }
 
 func (b *MockBucket) Take(numTokens int64, maxWaitTime time.Duration) (time.Duration, bool) {
@@ -35,6 +39,21 @@ func (b *MockBucket) Dynamic() bool {
 	return b.dyn
 }
 func (b *MockBucket) Destroy() {}
+func (b *MockBucket) Status() *admin.BucketStatus {
+	b.RLock()
+	defer b.RUnlock()
+
+	if b.Tokens != nil {
+		return &admin.BucketStatus{Tokens: *b.Tokens}
+	}
+	return &admin.BucketStatus{Tokens: b.cfg.Size}
+}
+func (b *MockBucket) RestoreState(s *BucketSnapshot, at time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.Restored = s
+}
 
 type MockBucketFactory struct {
 	buckets map[string]*MockBucket
@@ -48,6 +67,14 @@ func (bf *MockBucketFactory) SetWaitTime(namespace, name string, d time.Duration
 	bucket.WaitTime = d
 }
 
+func (bf *MockBucketFactory) SetTokens(namespace, name string, tokens int64) {
+	bucket := bf.bucket(namespace, name)
+	bucket.Lock()
+	defer bucket.Unlock()
+
+	bucket.Tokens = &tokens
+}
+
 func (bf *MockBucketFactory) bucket(namespace, name string) *MockBucket {
 	fqn := config.FullyQualifiedName(namespace, name)
 	bucket := bf.buckets[fqn]
@@ -59,7 +86,7 @@ func (bf *MockBucketFactory) bucket(namespace, name string) *MockBucket {
 
 func (bf *MockBucketFactory) Init(cfg *config.ServiceConfig) {}
 func (bf *MockBucketFactory) NewBucket(namespace string, bucketName string, cfg *config.BucketConfig, dyn bool) Bucket {
-	b := &MockBucket{sync.RWMutex{}, 0, namespace, bucketName, dyn, cfg}
+	b := &MockBucket{sync.RWMutex{}, 0, namespace, bucketName, dyn, cfg, nil, nil}
 	if bf.buckets == nil {
 		bf.buckets = make(map[string]*MockBucket)
 	}
@@ -0,0 +1,356 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+	"github.com/maniksurtani/quotaservice/lifecycle"
+	"github.com/maniksurtani/quotaservice/logging"
+)
+
+const (
+	// webhookCheckInterval is how often buckets are checked against webhooks' thresholds.
+	webhookCheckInterval = time.Second
+	// webhookEventBufSize is how many events each webhook buffers before dropping them.
+	webhookEventBufSize   = 1000
+	defaultDebounce       = 5 * time.Minute
+	defaultMaxAttempts    = 3
+	defaultRetryBackoff   = time.Second
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+const (
+	// AlertUtilization alerts that a bucket has had more of its tokens claimed than the webhook's
+	// UtilizationThreshold.
+	AlertUtilization = "utilization"
+	// AlertSustainedDenials alerts that a bucket has been refusing requests for want of tokens for
+	// longer than the webhook's SustainedDenials.
+	AlertSustainedDenials = "sustained_denials"
+)
+
+// Webhook configures a URL that is POSTed an Alert, as JSON, when a bucket it watches is about to
+// be, or has long been, exhausted, such as to page a team via Slack or PagerDuty.
+type Webhook struct {
+	URL string
+	// Headers are set on each request, such as to authenticate to the webhook.
+	Headers map[string]string
+	// Buckets watched, as globs of their fully qualified names that path.Match reads, such as
+	// "payments:*". Defaults to all buckets.
+	Buckets []string
+	// UtilizationThreshold alerts once the share of a bucket's size claimed, between 0 and 1,
+	// reaches it. Buckets are checked once a second, if they've served tokens since. 0 disables
+	// utilization alerts. Only buckets that can report their status are checked.
+	UtilizationThreshold float64
+	// SustainedDenials alerts once a bucket has been exhausted, refusing requests for want of
+	// tokens, for this long. 0 disables denial alerts.
+	SustainedDenials time.Duration
+	// Debounce is how long after alerting for a bucket the webhook waits before alerting for it
+	// again, for the same reason. Defaults to 5 minutes.
+	Debounce time.Duration
+	// MaxAttempts is how many times an alert is POSTed before it's given up on, should the webhook
+	// fail or respond with anything other than a 2xx. Defaults to 3. Alerts still being retried
+	// when the server stops are given up on.
+	MaxAttempts int
+	// RetryBackoff is how long to wait before the first retry, doubling after each. Defaults to a
+	// second.
+	RetryBackoff time.Duration
+	// Client POSTs alerts. Defaults to a client timing out after 10 seconds.
+	Client *http.Client
+}
+
+// Alert is what webhooks are POSTed, as JSON.
+type Alert struct {
+	// AlertUtilization or AlertSustainedDenials.
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Bucket    string `json:"bucket"`
+	FQN       string `json:"fqn"`
+	// Share of the bucket's size claimed, and the threshold it reached, for utilization alerts.
+	Utilization float64 `json:"utilization,omitempty"`
+	Threshold   float64 `json:"threshold,omitempty"`
+	// How long the bucket has been refusing requests, for denial alerts.
+	DeniedForMillis int64             `json:"denied_for_millis,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	// When the alert was raised, in Unix millis.
+	TimeMillis int64 `json:"time_millis"`
+	// Describes the alert for people, under the field Slack's incoming webhooks post.
+	Text string `json:"text"`
+}
+
+func (s *server) AddWebhook(w Webhook) {
+	if s.currentStatus == lifecycle.Started {
+		panic("Cannot add webhook after server has started!")
+	}
+
+	if w.URL == "" {
+		panic("Webhooks need a URL")
+	}
+
+	if w.Debounce <= 0 {
+		w.Debounce = defaultDebounce
+	}
+
+	if w.MaxAttempts <= 0 {
+		w.MaxAttempts = defaultMaxAttempts
+	}
+
+	if w.RetryBackoff <= 0 {
+		w.RetryBackoff = defaultRetryBackoff
+	}
+
+	if w.Client == nil {
+		w.Client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+
+	watcher := &webhookWatcher{
+		Webhook:    w,
+		s:          s,
+		served:     make(map[bucketKey]bool),
+		exhausted:  make(map[bucketKey]time.Time),
+		lastDenied: make(map[bucketKey]time.Time),
+		alerted:    make(map[alertKey]time.Time)}
+	s.AddListener(watcher.observe, webhookEventBufSize)
+	s.webhooks = append(s.webhooks, watcher)
+}
+
+type bucketKey struct {
+	namespace, name string
+}
+
+type alertKey struct {
+	bucketKey
+	kind string
+}
+
+// webhookWatcher follows the events of the buckets a webhook watches, checking them against its
+// thresholds every webhookCheckInterval.
+type webhookWatcher struct {
+	Webhook
+	s *server
+
+	sync.Mutex
+	// Buckets that have served tokens since they were last checked.
+	served map[bucketKey]bool
+	// When exhausted buckets were exhausted, and last refused requests.
+	exhausted  map[bucketKey]time.Time
+	lastDenied map[bucketKey]time.Time
+	// When alerts were last raised, to debounce them.
+	alerted map[alertKey]time.Time
+}
+
+// watches tells whether the webhook watches the bucket.
+func (w *webhookWatcher) watches(namespace, name string) bool {
+	if len(w.Buckets) == 0 {
+		return true
+	}
+
+	fqn := config.FullyQualifiedName(namespace, name)
+	for _, pattern := range w.Buckets {
+		if ok, _ := path.Match(pattern, fqn); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *webhookWatcher) observe(e Event) {
+	if !w.watches(e.Namespace(), e.BucketName()) {
+		return
+	}
+
+	k := bucketKey{e.Namespace(), e.BucketName()}
+	now := w.s.clock.Now()
+
+	w.Lock()
+	defer w.Unlock()
+
+	switch e.EventType() {
+	case EVENT_TOKENS_SERVED:
+		w.served[k] = true
+	case EVENT_TIMEOUT_SERVING_TOKENS:
+		w.lastDenied[k] = now
+	case EVENT_BUCKET_EXHAUSTED:
+		w.exhausted[k] = now
+		w.lastDenied[k] = now
+	case EVENT_BUCKET_RECOVERED:
+		delete(w.exhausted, k)
+		delete(w.lastDenied, k)
+	case EVENT_BUCKET_REMOVED:
+		delete(w.served, k)
+		delete(w.exhausted, k)
+		delete(w.lastDenied, k)
+	}
+}
+
+// watch checks the buckets whenever t ticks, until stop is closed.
+func (w *webhookWatcher) watch(t clock.Ticker, stop chan struct{}) {
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C():
+			w.check(stop)
+		}
+	}
+}
+
+// check alerts for the buckets that have passed the webhook's thresholds, giving up on delivering
+// alerts once stop is closed.
+func (w *webhookWatcher) check(stop chan struct{}) {
+	now := w.s.clock.Now()
+
+	w.Lock()
+	served := w.served
+	w.served = make(map[bucketKey]bool)
+	var denied []*Alert
+	if w.SustainedDenials > 0 {
+		for k, since := range w.exhausted {
+			// Buckets only recover when they next serve tokens, so those that have since stopped
+			// refusing requests aren't alerted for.
+			if now.Sub(since) >= w.SustainedDenials && now.Sub(w.lastDenied[k]) < exhaustionDebounce {
+				denied = append(denied, &Alert{
+					Kind:            AlertSustainedDenials,
+					Namespace:       k.namespace,
+					Bucket:          k.name,
+					DeniedForMillis: int64(now.Sub(since) / time.Millisecond)})
+			}
+		}
+	}
+	w.Unlock()
+
+	for _, a := range denied {
+		a.Text = fmt.Sprintf("Bucket %v has been refusing requests for want of tokens for %v",
+			config.FullyQualifiedName(a.Namespace, a.Bucket), time.Duration(a.DeniedForMillis)*time.Millisecond)
+		w.alert(a, now, stop)
+	}
+
+	if w.UtilizationThreshold <= 0 {
+		return
+	}
+
+	for k := range served {
+		u, ok := w.utilization(k)
+		if ok && u >= w.UtilizationThreshold {
+			w.alert(&Alert{
+				Kind:        AlertUtilization,
+				Namespace:   k.namespace,
+				Bucket:      k.name,
+				Utilization: u,
+				Threshold:   w.UtilizationThreshold,
+				Text: fmt.Sprintf("Bucket %v is %.0f%% utilized, over its threshold of %.0f%%",
+					config.FullyQualifiedName(k.namespace, k.name), u*100, w.UtilizationThreshold*100)}, now, stop)
+		}
+	}
+}
+
+// utilization returns the share of the live bucket's size that's been claimed, telling whether
+// the bucket could report it.
+func (w *webhookWatcher) utilization(k bucketKey) (float64, bool) {
+	b := w.s.bucketContainer.liveBucket(k.namespace, k.name)
+	if b == nil || b.Config().Size <= 0 {
+		return 0, false
+	}
+
+	sr, ok := b.Bucket.(StatusReporter)
+	if !ok {
+		return 0, false
+	}
+
+	status := sr.Status()
+	if status.Tokens <= 0 {
+		return 1, true
+	}
+
+	return 1 - float64(status.Tokens)/float64(b.Config().Size), true
+}
+
+// alert POSTs the alert to the webhook in the background, as deliver does, unless it has alerted
+// for the bucket for the same reason within the debounce time.
+func (w *webhookWatcher) alert(a *Alert, now time.Time, stop chan struct{}) {
+	k := alertKey{bucketKey{a.Namespace, a.Bucket}, a.Kind}
+
+	w.Lock()
+	if last, ok := w.alerted[k]; ok && now.Sub(last) < w.Debounce {
+		w.Unlock()
+		return
+	}
+	w.alerted[k] = now
+	w.Unlock()
+
+	a.FQN = config.FullyQualifiedName(a.Namespace, a.Bucket)
+	a.TimeMillis = now.UnixNano() / int64(time.Millisecond)
+	if b := w.s.bucketContainer.liveBucket(a.Namespace, a.Bucket); b != nil {
+		a.Labels = b.Config().AllLabels()
+	}
+
+	go w.deliver(a, stop)
+}
+
+// deliver POSTs the alert, retrying with exponential backoff, as the server's clock tells it,
+// until the webhook accepts it, it has been attempted MaxAttempts times or stop is closed.
+func (w *webhookWatcher) deliver(a *Alert, stop chan struct{}) {
+	body, e := json.Marshal(a)
+	if e != nil {
+		logging.Errorf("Unable to marshal alert %+v: %v", a, e)
+		return
+	}
+
+	backoff := w.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		if e = w.post(body); e == nil {
+			return
+		}
+
+		if attempt == w.MaxAttempts {
+			logging.Errorf("Gave up alerting %v of %v after %v attempts: %v", w.URL, a.Text, attempt, e)
+			return
+		}
+
+		logging.Warnf("Unable to alert %v; retrying in %v. Error: %v", w.URL, backoff, e)
+		t := w.s.clock.NewTimer(backoff)
+		select {
+		case <-stop:
+			t.Stop()
+			return
+		case <-t.C():
+		}
+		backoff *= 2
+	}
+}
+
+func (w *webhookWatcher) post(body []byte) error {
+	req, e := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if e != nil {
+		return e
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.Headers {
+		req.Header.Set(name, value)
+	}
+
+	rsp, e := w.Client.Do(req)
+	if e != nil {
+		return e
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("Webhook responded %v", rsp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+// Licensed under the Apache License, Version 2.0
+// Details: https://raw.githubusercontent.com/maniksurtani/quotaservice/master/LICENSE
+
+package quotaservice
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maniksurtani/quotaservice/clock"
+	"github.com/maniksurtani/quotaservice/config"
+)
+
+func TestWebhook(t *testing.T) {
+	alerts := make(chan *Alert, 10)
+	var attempts int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			t.Errorf("Expecting the webhook's headers to be sent, got %v", r.Header)
+		}
+
+		// The first attempt fails, to be retried.
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		a := &Alert{}
+		if e := json.NewDecoder(r.Body).Decode(a); e != nil {
+			t.Error(e)
+		}
+		alerts <- a
+	}))
+	defer hook.Close()
+
+	cfg := config.NewDefaultServiceConfig()
+	ns := config.NewDefaultNamespaceConfig()
+	b := config.NewDefaultBucketConfig()
+	b.Labels = map[string]string{"team": "payments"}
+	ns.AddBucket("b", b)
+	ns.AddBucket("unwatched", config.NewDefaultBucketConfig())
+	cfg.AddNamespace("n", ns)
+
+	bf := &MockBucketFactory{}
+	c := clock.NewFake(time.Unix(1500000000, 0))
+	s := New(cfg, bf, &MockEndpoint{})
+	s.SetClock(c)
+	s.AddWebhook(Webhook{
+		URL:                  hook.URL,
+		Headers:              map[string]string{"Authorization": "Bearer s3cr3t"},
+		Buckets:              []string{"n:b"},
+		UtilizationThreshold: 0.9,
+		SustainedDenials:     30 * time.Second,
+		Debounce:             time.Minute,
+		RetryBackoff:         time.Millisecond})
+	s.Start()
+	defer s.Stop()
+	qs := s.(QuotaService)
+
+	// Advances the clock until an alert is delivered, calling step each time.
+	expectAlert := func(step func()) *Alert {
+		for i := 0; i < 50; i++ {
+			step()
+			c.Advance(webhookCheckInterval)
+			select {
+			case a := <-alerts:
+				return a
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+		t.Fatal("Expecting an alert")
+		return nil
+	}
+
+	expectNoAlert := func(step func()) {
+		for i := 0; i < 5; i++ {
+			step()
+			c.Advance(webhookCheckInterval)
+			select {
+			case a := <-alerts:
+				t.Fatalf("Expecting no alert, got %+v", a)
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}
+
+	bf.SetTokens("n", "b", 5)
+	bf.SetTokens("n", "unwatched", 5)
+	expectNoAlert(func() { qs.Allow("n", "unwatched", 1, 0) })
+
+	a := expectAlert(func() { qs.Allow("n", "b", 1, 0) })
+	if a.Kind != AlertUtilization || a.FQN != "n:b" || a.Utilization != 0.95 || a.Threshold != 0.9 ||
+		a.Labels["team"] != "payments" || a.Text == "" {
+		t.Fatalf("Expecting a utilization alert for n:b, got %+v", a)
+	}
+
+	if atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("Expecting the alert to be retried once, got %v attempts", attempts)
+	}
+
+	// Debounced.
+	expectNoAlert(func() { qs.Allow("n", "b", 1, 0) })
+
+	bf.SetWaitTime("n", "b", time.Hour)
+	start := c.Now()
+	a = expectAlert(func() { qs.Allow("n", "b", 1, 0) })
+	if a.Kind != AlertSustainedDenials || a.FQN != "n:b" || a.DeniedForMillis < 30000 {
+		t.Fatalf("Expecting a denial alert for n:b, got %+v", a)
+	}
+
+	if denied := c.Now().Sub(start); denied < 30*time.Second {
+		t.Fatalf("Expecting denials to be alerted once sustained for 30s, alerted after %v", denied)
+	}
+}
+
+func TestWebhookRetriesStopWithServer(t *testing.T) {
+	attempts := make(chan struct{}, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- struct{}{}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer hook.Close()
+
+	s := New(config.NewDefaultServiceConfig(), &MockBucketFactory{}, &MockEndpoint{})
+	s.SetClock(clock.NewFake(time.Unix(1500000000, 0)))
+	s.AddWebhook(Webhook{URL: hook.URL, MaxAttempts: 10, RetryBackoff: time.Hour})
+	w := s.(*server).webhooks[0]
+
+	stop := make(chan struct{})
+	delivered := make(chan struct{})
+	go func() {
+		w.deliver(&Alert{Kind: AlertUtilization, Text: "test"}, stop)
+		close(delivered)
+	}()
+
+	<-attempts
+	close(stop)
+	select {
+	case <-delivered:
+	case <-time.After(time.Second):
+		t.Fatal("Expecting retries to stop once the server stops")
+	}
+
+	if len(attempts) != 0 {
+		t.Fatalf("Expecting no further attempts, got %v", len(attempts))
+	}
+}